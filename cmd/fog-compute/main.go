@@ -0,0 +1,9 @@
+// Commande fog-compute: un nœud fog computing, configuré par l'environnement
+// et CONFIG_FILE (voir README).
+package main
+
+import "fog-compute/fognode"
+
+func main() {
+	fognode.Run()
+}
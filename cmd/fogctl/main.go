@@ -0,0 +1,554 @@
+// Commande fogctl: administration d'un ou plusieurs nœuds fog en ligne de
+// commande (soumission de tâches, suivi des événements, tâches rejetées,
+// métriques, drainage).
+//
+//	fogctl -nodes http://fog-1:8080,http://fog-2:8080 metrics
+//	fogctl submit -wait tache.json
+//	fogctl events -types completed,failed
+//	fogctl rejected retry task-123
+//	fogctl drain -wait 5m
+//
+// Les nœuds, la clé d'API, le jeton et le namespace se donnent par options
+// ou par FOG_NODES, FOG_API_KEY, FOG_TOKEN et FOG_TENANT.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"fog-compute/fogclient"
+)
+
+const usage = `Usage: fogctl [options] <commande> [arguments]
+
+Commandes:
+  submit [-wait] [-timeout d] FICHIER...  Soumet les tâches de fichiers JSON (objet ou tableau; - pour l'entrée standard)
+  get ID...                               Affiche l'état de tâches
+  list [-status s] [-type t] [-limit n] [-archived]
+                                          Liste les tâches
+  events [-types a,b] [-task ID]          Suit les événements des tâches jusqu'à Ctrl-C
+  rejected list                           Liste les tâches rejetées
+  rejected retry ID...                    Resoumet des tâches rejetées
+  metrics [CLÉ...]                        Affiche les métriques (toutes avec -json)
+  drain [-wait d]                         Ferme les admissions; -wait attend la fin du drainage
+  resume                                  Lève la pause et le drainage
+  maintenance                             Affiche l'état de pause et de drainage
+
+Options:
+`
+
+// node est un nœud ciblé par la commande
+type node struct {
+	url    string
+	client *fogclient.Client
+}
+
+// ctl porte les options communes aux commandes
+type ctl struct {
+	nodes   []node
+	jsonOut bool
+	out     io.Writer
+	mu      sync.Mutex // Sérialise les sorties des commandes menées en parallèle sur les nœuds
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	nodes := flag.String("nodes", envOr("FOG_NODES", "http://localhost:8080"), "URLs des nœuds, séparées par des virgules")
+	apiKey := flag.String("api-key", os.Getenv("FOG_API_KEY"), "Clé d'API (X-API-Key)")
+	token := flag.String("token", os.Getenv("FOG_TOKEN"), "Jeton Bearer (OIDC)")
+	tenant := flag.String("tenant", os.Getenv("FOG_TENANT"), "Namespace des requêtes (X-Fog-Tenant)")
+	jsonOut := flag.Bool("json", false, "Sortie JSON brute")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts []fogclient.Option
+	if *apiKey != "" {
+		opts = append(opts, fogclient.WithAPIKey(*apiKey))
+	}
+	if *token != "" {
+		opts = append(opts, fogclient.WithBearerToken(*token))
+	}
+	if *tenant != "" {
+		opts = append(opts, fogclient.WithTenant(*tenant))
+	}
+	c := &ctl{jsonOut: *jsonOut, out: os.Stdout}
+	for _, u := range strings.Split(*nodes, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			c.nodes = append(c.nodes, node{url: u, client: fogclient.New(u, opts...)})
+		}
+	}
+	if len(c.nodes) == 0 {
+		fatalf("aucun nœud: -nodes ou FOG_NODES requis")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := flag.Args()
+	var err error
+	switch args[0] {
+	case "submit":
+		err = c.submit(ctx, args[1:])
+	case "get":
+		err = c.get(ctx, args[1:])
+	case "list":
+		err = c.list(ctx, args[1:])
+	case "events":
+		err = c.events(ctx, args[1:])
+	case "rejected":
+		err = c.rejected(ctx, args[1:])
+	case "metrics":
+		err = c.metrics(ctx, args[1:])
+	case "drain":
+		err = c.drain(ctx, args[1:])
+	case "resume":
+		err = c.eachNode(ctx, func(ctx context.Context, n node) error {
+			status, err := n.client.Resume(ctx)
+			if err == nil {
+				c.printMaintenance(n, status)
+			}
+			return err
+		})
+	case "maintenance":
+		err = c.eachNode(ctx, func(ctx context.Context, n node) error {
+			status, err := n.client.Maintenance(ctx, 0)
+			if err == nil {
+				c.printMaintenance(n, status)
+			}
+			return err
+		})
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// submit soumet les tâches des fichiers donnés au premier nœud; un refus est
+// retenté sur les nœuds suivants
+func (c *ctl) submit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	wait := fs.Bool("wait", false, "Attendre la fin de chaque tâche")
+	timeout := fs.Duration("timeout", 0, "Délai maximal de l'attente (0: sans limite)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("submit: fichier de tâches requis")
+	}
+
+	var requests []fogclient.TaskRequest
+	for _, path := range fs.Args() {
+		reqs, err := readTasks(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		requests = append(requests, reqs...)
+	}
+
+	failed := 0
+	for _, req := range requests {
+		task, n, err := c.submitOne(ctx, req)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", req.Type, err)
+			continue
+		}
+		if *wait {
+			waitCtx, cancel := ctx, context.CancelFunc(func() {})
+			if *timeout > 0 {
+				waitCtx, cancel = context.WithTimeout(ctx, *timeout)
+			}
+			done, err := n.client.WaitForResult(waitCtx, task.ID)
+			cancel()
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: attente interrompue: %v\n", task.ID, err)
+			} else {
+				task = done
+				if !task.Succeeded() {
+					failed++
+				}
+			}
+		}
+		c.printTask(n, task)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d tâche(s) sur %d en échec", failed, len(requests))
+	}
+	return nil
+}
+
+// submitOne soumet une tâche au premier nœud qui l'accepte
+func (c *ctl) submitOne(ctx context.Context, req fogclient.TaskRequest) (*fogclient.Task, node, error) {
+	var lastErr error
+	for _, n := range c.nodes {
+		task, err := n.client.SubmitTask(ctx, req)
+		if err == nil {
+			return task, n, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", n.url, err)
+		var apiErr *fogclient.APIError
+		if errors.As(err, &apiErr) && !apiErr.Temporary() {
+			break // Tâche invalide: inutile d'essayer ailleurs
+		}
+	}
+	return nil, node{}, lastErr
+}
+
+// readTasks lit un fichier contenant une tâche ou un tableau de tâches
+func readTasks(path string) ([]fogclient.TaskRequest, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var reqs []fogclient.TaskRequest
+		err = json.Unmarshal(data, &reqs)
+		return reqs, err
+	}
+	var req fogclient.TaskRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return []fogclient.TaskRequest{req}, nil
+}
+
+// get affiche l'état de tâches, cherchées sur chaque nœud
+func (c *ctl) get(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return errors.New("get: identifiant de tâche requis")
+	}
+	missing := 0
+	for _, id := range ids {
+		found := false
+		for _, n := range c.nodes {
+			task, err := n.client.GetTask(ctx, id)
+			if fogclient.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.url, err)
+			}
+			c.printTask(n, task)
+			found = true
+			break
+		}
+		if !found {
+			missing++
+			fmt.Fprintf(os.Stderr, "%s: tâche introuvable\n", id)
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d tâche(s) introuvable(s)", missing)
+	}
+	return nil
+}
+
+// list liste les tâches de chaque nœud
+func (c *ctl) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var opts fogclient.ListOptions
+	fs.StringVar(&opts.Status, "status", "", "Statut des tâches")
+	fs.StringVar(&opts.Type, "type", "", "Type des tâches")
+	fs.IntVar(&opts.Limit, "limit", 0, "Nombre maximal de tâches par nœud")
+	fs.BoolVar(&opts.Archived, "archived", false, "Tâches archivées par la rétention")
+	fs.Parse(args)
+
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	if !c.jsonOut {
+		fmt.Fprintln(tw, "NŒUD\tID\tTYPE\tSTATUT\tSOUMISE")
+	}
+	err := c.eachNode(ctx, func(ctx context.Context, n node) error {
+		tasks, err := n.client.ListTasks(ctx, opts)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, t := range tasks {
+			if c.jsonOut {
+				c.writeJSON(map[string]interface{}{"node": n.url, "task": t})
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", n.url, t.ID, t.Type, t.Status, t.SubmittedAt.Format(time.RFC3339))
+		}
+		return nil
+	})
+	tw.Flush()
+	return err
+}
+
+// events suit les événements de tous les nœuds jusqu'à l'interruption
+func (c *ctl) events(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	types := fs.String("types", "", "Types d'événements, séparés par des virgules")
+	taskID := fs.String("task", "", "Événements d'une seule tâche")
+	fs.Parse(args)
+
+	filter := fogclient.EventFilter{TaskID: *taskID}
+	if *types != "" {
+		filter.Types = strings.Split(*types, ",")
+	}
+	err := c.eachNode(ctx, func(ctx context.Context, n node) error {
+		return n.client.StreamEvents(ctx, filter, func(ev fogclient.Event) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.jsonOut {
+				c.writeJSON(map[string]interface{}{"node": n.url, "event": ev})
+				return nil
+			}
+			line := fmt.Sprintf("%s %s %-9s %s %s", ev.At.Format("15:04:05.000"), n.url, ev.Type, ev.TaskID, ev.TaskType)
+			switch {
+			case ev.Reason != "":
+				line += " " + ev.Reason
+			case ev.DurationMs > 0:
+				line += fmt.Sprintf(" %dms", ev.DurationMs)
+			case ev.Progress != nil:
+				line += fmt.Sprintf(" %.0f%% %s", ev.Progress.Fraction*100, ev.Progress.Message)
+			}
+			fmt.Fprintln(c.out, line)
+			return nil
+		})
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil // Ctrl-C
+	}
+	return err
+}
+
+// rejected liste ou resoumet les tâches rejetées
+func (c *ctl) rejected(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("rejected: list ou retry attendu")
+	}
+	switch args[0] {
+	case "list":
+		tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+		if !c.jsonOut {
+			fmt.Fprintln(tw, "NŒUD\tID\tTYPE\tREJETÉE\tRAISON")
+		}
+		err := c.eachNode(ctx, func(ctx context.Context, n node) error {
+			tasks, err := n.client.ListRejected(ctx)
+			if err != nil {
+				return err
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for _, rt := range tasks {
+				if c.jsonOut {
+					c.writeJSON(map[string]interface{}{"node": n.url, "rejected": rt})
+					continue
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", n.url, rt.Task.ID, rt.Task.Type, rt.RejectedAt.Format(time.RFC3339), rt.RejectionReason)
+			}
+			return nil
+		})
+		tw.Flush()
+		return err
+
+	case "retry":
+		if len(args) < 2 {
+			return errors.New("rejected retry: identifiant de tâche requis")
+		}
+		failed := 0
+		for _, id := range args[1:] {
+			var lastErr error
+			retried := false
+			for _, n := range c.nodes {
+				task, err := n.client.RetryRejected(ctx, id)
+				if fogclient.IsNotFound(err) {
+					continue
+				}
+				if err != nil {
+					lastErr = fmt.Errorf("%s: %w", n.url, err)
+					break
+				}
+				c.printTask(n, task)
+				retried = true
+				break
+			}
+			if !retried {
+				failed++
+				if lastErr == nil {
+					lastErr = errors.New("tâche rejetée introuvable")
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, lastErr)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d réessai(s) en échec", failed)
+		}
+		return nil
+	}
+	return fmt.Errorf("rejected: sous-commande %q inconnue (list ou retry)", args[0])
+}
+
+// summaryMetrics sont les métriques affichées par défaut
+var summaryMetrics = []string{"tasks_processed", "tasks_rejected", "tasks_failed", "current_load", "avg_latency_ms", "rejected_queue_size", "failed_queue_size"}
+
+// metrics affiche les métriques demandées de chaque nœud
+func (c *ctl) metrics(ctx context.Context, keys []string) error {
+	if len(keys) == 0 && !c.jsonOut {
+		keys = summaryMetrics
+	}
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	return c.eachNode(ctx, func(ctx context.Context, n node) error {
+		m, err := n.client.Metrics(ctx)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.jsonOut {
+			if len(keys) > 0 {
+				selected := make(map[string]interface{}, len(keys))
+				for _, k := range keys {
+					selected[k] = m[k]
+				}
+				m = selected
+			}
+			c.writeJSON(map[string]interface{}{"node": n.url, "metrics": m})
+			return nil
+		}
+		fmt.Fprintln(c.out, n.url)
+		for _, k := range keys {
+			v, ok := m[k]
+			if !ok {
+				continue
+			}
+			if nested, isObject := v.(map[string]interface{}); isObject || v == nil {
+				b, _ := json.Marshal(nested)
+				fmt.Fprintf(c.out, "  %-24s %s\n", k, b)
+				continue
+			}
+			fmt.Fprintf(c.out, "  %-24s %v\n", k, v)
+		}
+		return nil
+	})
+}
+
+// drain ferme les admissions de chaque nœud, et attend leur vidage avec -wait
+func (c *ctl) drain(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	wait := fs.Duration("wait", 0, "Attendre la fin du drainage dans cette limite")
+	fs.Parse(args)
+
+	return c.eachNode(ctx, func(ctx context.Context, n node) error {
+		status, err := n.client.Drain(ctx)
+		if err != nil {
+			return err
+		}
+		deadline := time.Now().Add(*wait)
+		for !status.Drained && time.Now().Before(deadline) {
+			// Le nœud borne lui-même l'attente: on la renouvelle jusqu'à -wait
+			if status, err = n.client.Maintenance(ctx, min(time.Until(deadline), 30*time.Second)); err != nil {
+				return err
+			}
+		}
+		c.printMaintenance(n, status)
+		if *wait > 0 && !status.Drained {
+			return fmt.Errorf("drainage inachevé après %s: %d en queue, %d en cours", *wait, status.Queued, status.InFlight)
+		}
+		return nil
+	})
+}
+
+// eachNode mène fn en parallèle sur chaque nœud; les erreurs sont préfixées
+// par l'URL du nœud
+func (c *ctl) eachNode(ctx context.Context, fn func(context.Context, node) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(c.nodes))
+	for i, n := range c.nodes {
+		wg.Add(1)
+		go func(i int, n node) {
+			defer wg.Done()
+			if err := fn(ctx, n); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", n.url, err)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// printTask affiche une tâche: résumé sur une ligne, ou JSON complet
+func (c *ctl) printTask(n node, t *fogclient.Task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.jsonOut {
+		c.writeJSON(map[string]interface{}{"node": n.url, "task": t})
+		return
+	}
+	line := fmt.Sprintf("%s %s %s %s", n.url, t.ID, t.Type, t.Status)
+	if t.Archived {
+		line += " (archivée)"
+	}
+	if t.Done() && len(t.Result) > 0 {
+		line += " " + string(t.Result)
+	}
+	fmt.Fprintln(c.out, line)
+}
+
+// printMaintenance affiche l'état de maintenance d'un nœud
+func (c *ctl) printMaintenance(n node, s *fogclient.MaintenanceStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.jsonOut {
+		c.writeJSON(map[string]interface{}{"node": n.url, "maintenance": s})
+		return
+	}
+	state := "actif"
+	switch {
+	case s.Drained:
+		state = "drainé"
+	case s.Draining:
+		state = "drainage"
+	case s.Paused:
+		state = "en pause"
+	}
+	fmt.Fprintf(c.out, "%s %s (queue %d, en cours %d, soumissions acceptées: %t)\n", n.url, state, s.Queued, s.InFlight, s.AcceptingSubmissions)
+}
+
+// writeJSON écrit une valeur JSON sur une ligne; c.mu doit être détenu
+func (c *ctl) writeJSON(v interface{}) {
+	json.NewEncoder(c.out).Encode(v)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "fogctl: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,100 @@
+package fogclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RejectedTask est une tâche refusée à l'admission, conservée pour réessai
+type RejectedTask struct {
+	Task            Task       `json:"task"`
+	RejectedAt      time.Time  `json:"rejected_at"`
+	RejectionReason string     `json:"rejection_reason"`
+	NodeLoad        float64    `json:"node_load"`
+	QueueSize       int        `json:"queue_size"`
+	AutoRetries     int        `json:"auto_retries,omitempty"`
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`
+	LastRetryReason string     `json:"last_retry_reason,omitempty"`
+}
+
+// MaintenanceStatus est l'état de pause et de drainage d'un nœud
+type MaintenanceStatus struct {
+	Paused               bool       `json:"paused"`
+	AcceptingSubmissions bool       `json:"accepting_submissions"`
+	Draining             bool       `json:"draining"`
+	Drained              bool       `json:"drained"` // Queue vide, aucune exécution en cours
+	Queued               int        `json:"queued"`
+	InFlight             int        `json:"in_flight"`
+	PausedAt             *time.Time `json:"paused_at,omitempty"`
+	DrainStartedAt       *time.Time `json:"drain_started_at,omitempty"`
+	DrainedAt            *time.Time `json:"drained_at,omitempty"`
+}
+
+// ListRejected retourne les tâches rejetées du namespace du client
+// (GET /rejected-tasks)
+func (c *Client) ListRejected(ctx context.Context) ([]RejectedTask, error) {
+	var list struct {
+		Tasks []RejectedTask `json:"tasks"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/rejected-tasks", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Tasks, nil
+}
+
+// RetryRejected resoumet une tâche rejetée (POST /rejected-tasks/{id}/retry)
+// et retourne la tâche remise en queue
+func (c *Client) RetryRejected(ctx context.Context, id string) (*Task, error) {
+	var out struct {
+		Task Task `json:"task"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/rejected-tasks/"+url.PathEscape(id)+"/retry", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Task, nil
+}
+
+// Metrics retourne les métriques du nœud (GET /metrics), telles quelles
+func (c *Client) Metrics(ctx context.Context) (map[string]interface{}, error) {
+	var metrics map[string]interface{}
+	if _, err := c.do(ctx, http.MethodGet, "/metrics", nil, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// Drain ferme les admissions du nœud avant maintenance (POST /admin/drain);
+// les tâches en queue et en cours se terminent
+func (c *Client) Drain(ctx context.Context) (*MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	if _, err := c.do(ctx, http.MethodPost, "/admin/drain", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Resume lève la pause et le drainage du nœud (POST /admin/resume)
+func (c *Client) Resume(ctx context.Context) (*MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	if _, err := c.do(ctx, http.MethodPost, "/admin/resume", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Maintenance retourne l'état de pause et de drainage du nœud
+// (GET /admin/maintenance); wait > 0 attend la fin du drainage dans cette
+// limite
+func (c *Client) Maintenance(ctx context.Context, wait time.Duration) (*MaintenanceStatus, error) {
+	path := "/admin/maintenance"
+	if wait > 0 {
+		path += "?wait=" + url.QueryEscape(wait.String())
+	}
+	var status MaintenanceStatus
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
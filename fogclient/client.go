@@ -0,0 +1,230 @@
+// Package fogclient est le client Go de l'API HTTP d'un nœud fog computing:
+// soumission et suivi des tâches, attente de leur résultat et flux des
+// événements de leur cycle de vie.
+//
+//	c := fogclient.New("http://fog-node-1:8080", fogclient.WithAPIKey(key))
+//	task, err := c.SubmitTask(ctx, fogclient.TaskRequest{Type: "edge_analytics", Payload: payload})
+//	if err == nil {
+//		task, err = c.WaitForResult(ctx, task.ID)
+//	}
+//
+// Les refus temporaires du nœud (429, 502, 503, 504), et les erreurs réseau
+// des lectures, sont réessayés avec un délai croissant ou celui de
+// Retry-After; toutes les méthodes s'arrêtent à l'annulation de leur contexte.
+package fogclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix préfixe les routes de l'API versionnée du nœud
+const apiPrefix = "/api/v1"
+
+// Client appelle l'API d'un nœud fog. Il est utilisable par plusieurs
+// goroutines à la fois.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	token      string
+	tenant     string
+	maxRetries int           // Réessais d'une requête refusée temporairement
+	backoff    time.Duration // Premier délai entre deux tentatives, doublé ensuite
+	maxBackoff time.Duration
+	poll       time.Duration // Intervalle de WaitForResult
+}
+
+// Option configure un Client
+type Option func(*Client)
+
+// WithHTTPClient remplace le client HTTP (transport, TLS, proxy)
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAPIKey authentifie les requêtes par l'en-tête X-API-Key
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBearerToken authentifie les requêtes par un jeton OIDC
+// (Authorization: Bearer)
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithTenant place les requêtes dans un namespace (X-Fog-Tenant)
+func WithTenant(tenant string) Option {
+	return func(c *Client) { c.tenant = tenant }
+}
+
+// WithRetries fixe le nombre de réessais d'une requête refusée temporairement
+// et le premier délai entre deux tentatives (0 réessai: aucun)
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithPollInterval fixe l'intervalle entre deux consultations de WaitForResult
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Client) { c.poll = d }
+}
+
+// New crée un client pour le nœud d'URL baseURL (http://hôte:port)
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		backoff:    500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		poll:       500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError est une réponse en erreur du nœud
+type APIError struct {
+	StatusCode int
+	Message    string        // Corps de la réponse: raison du refus
+	RetryAfter time.Duration // Délai conseillé par le nœud (Retry-After), 0 s'il n'en donne pas
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("fog: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Temporary indique un refus que le nœud pourrait lever plus tard (surcharge,
+// drainage, quota de débit)
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// IsNotFound indique une tâche ou une ressource inconnue du nœud (ou d'un
+// autre namespace)
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do envoie une requête JSON et décode la réponse dans out (s'il est non
+// nil), en réessayant les refus temporaires. Les erreurs réseau ne sont
+// réessayées que pour GET: une soumission a pu être reçue avant la coupure.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) (*http.Response, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body, out)
+		if err == nil {
+			return resp, nil
+		}
+		var apiErr *APIError
+		retryable := method == http.MethodGet
+		if errors.As(err, &apiErr) {
+			retryable = apiErr.Temporary()
+		}
+		if !retryable || attempt >= c.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		wait := delay
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return resp, err
+		}
+		delay = min(delay*2, c.maxBackoff)
+	}
+}
+
+// send envoie une tentative de requête
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+apiPrefix+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp, responseError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("fog: réponse illisible: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// authorize ajoute l'authentification et le namespace du client
+func (c *Client) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Fog-Tenant", c.tenant)
+	}
+}
+
+// responseError construit l'APIError d'une réponse en erreur
+func responseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
+
+// sleep attend d, ou l'annulation du contexte
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,154 @@
+package fogclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types d'événements du cycle de vie des tâches
+const (
+	EventQueued    = "queued"
+	EventStarted   = "started"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+	EventRejected  = "rejected"
+	EventPreempted = "preempted"
+	EventProgress  = "progress"
+)
+
+// Event est un événement du cycle de vie d'une tâche (GET /events)
+type Event struct {
+	Seq        uint64    `json:"seq"`
+	Type       string    `json:"type"`
+	At         time.Time `json:"at"`
+	TaskID     string    `json:"task_id"`
+	TaskType   string    `json:"task_type,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Priority   int       `json:"priority"`
+	QueueClass string    `json:"queue_class,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	QueueMs    int64     `json:"queue_ms,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Progress   *Progress `json:"progress,omitempty"`
+}
+
+// EventFilter restreint le flux d'événements
+type EventFilter struct {
+	Types       []string // Types d'événements (vide: tous)
+	TaskID      string   // Événements d'une seule tâche
+	LastEventID uint64   // Reprendre après cet événement (rejoués s'ils sont encore en mémoire du nœud)
+}
+
+// errStopStream arrête StreamEvents sans erreur
+var errStopStream = errors.New("fogclient: fin du flux demandée")
+
+// StopStream, retourné par le gestionnaire de StreamEvents, arrête le flux
+// sans erreur
+func StopStream() error { return errStopStream }
+
+// StreamEvents suit le flux Server-Sent Events du nœud et appelle handle pour
+// chaque événement, dans l'ordre. Après une coupure, le flux est rouvert avec
+// Last-Event-ID pour que le nœud rejoue les événements manqués. StreamEvents
+// rend la main à l'annulation du contexte, quand handle retourne une erreur
+// (StopStream() pour un arrêt normal, qui retourne nil) ou quand le nœud refuse
+// le flux.
+func (c *Client) StreamEvents(ctx context.Context, filter EventFilter, handle func(Event) error) error {
+	lastID := filter.LastEventID
+	delay := c.backoff
+	for {
+		received, err := c.streamOnce(ctx, filter, lastID, func(ev Event) error {
+			lastID = ev.Seq
+			return handle(ev)
+		})
+		switch {
+		case errors.Is(err, errStopStream):
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && !apiErr.Temporary() {
+				return err
+			}
+			if received {
+				delay = c.backoff // Le flux a fonctionné: reconnexion rapide
+			}
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		delay = min(delay*2, c.maxBackoff)
+	}
+}
+
+// streamOnce ouvre le flux une fois et le lit jusqu'à sa fin; received
+// indique qu'au moins un événement a été reçu
+func (c *Client) streamOnce(ctx context.Context, filter EventFilter, lastID uint64, handle func(Event) error) (received bool, err error) {
+	q := url.Values{}
+	if len(filter.Types) > 0 {
+		q.Set("types", strings.Join(filter.Types, ","))
+	}
+	if filter.TaskID != "" {
+		q.Set("task_id", filter.TaskID)
+	}
+	path := "/events"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+apiPrefix+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(lastID, 10))
+	}
+	c.authorize(req)
+
+	// Le flux reste ouvert indéfiniment: pas de délai global de requête
+	hc := *c.httpClient
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// Fin d'un événement; "hello" ouvre le flux et n'est pas transmis
+			if data != "" && event != "hello" {
+				var ev Event
+				if err := json.Unmarshal([]byte(data), &ev); err == nil {
+					received = true
+					if err := handle(ev); err != nil {
+						return received, err
+					}
+				}
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	return received, scanner.Err()
+}
@@ -0,0 +1,195 @@
+package fogclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TaskRequest est une tâche à soumettre. Seuls Type et Payload sont requis:
+// le nœud estime les coûts omis.
+type TaskRequest struct {
+	ID          string                 `json:"id,omitempty"` // Identifiant fourni par le client (ID_SCHEME=external)
+	Type        string                 `json:"type"`
+	Payload     map[string]interface{} `json:"payload"`
+	DeviceID    string                 `json:"device_id,omitempty"`
+	Priority    int                    `json:"priority,omitempty"`
+	Criticality int                    `json:"criticality,omitempty"` // 1-5
+	CPUCost     float64                `json:"cpu_cost,omitempty"`    // 0.0-1.0
+	RAMCost     float64                `json:"ram_cost,omitempty"`    // 0.0-1.0
+	StorageCost float64                `json:"storage_cost,omitempty"`
+	Deadline    *time.Time             `json:"deadline,omitempty"`
+	ExecuteAt   *time.Time             `json:"execute_at,omitempty"`
+	TimeoutMs   int64                  `json:"timeout_ms,omitempty"`
+	Retry       *RetryPolicy           `json:"retry,omitempty"`
+	CallbackURL string                 `json:"callback_url,omitempty"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	DependsOn   []string               `json:"depends_on,omitempty"`
+	WorkflowID  string                 `json:"workflow_id,omitempty"`
+	GroupID     string                 `json:"group_id,omitempty"`
+}
+
+// RetryPolicy règle les réessais automatiques d'une tâche en échec
+type RetryPolicy struct {
+	MaxAttempts  int     `json:"max_attempts"` // Nombre total de tentatives, première incluse
+	BackoffMs    int64   `json:"backoff_ms,omitempty"`
+	MaxBackoffMs int64   `json:"max_backoff_ms,omitempty"`
+	Multiplier   float64 `json:"multiplier,omitempty"`
+}
+
+// Task est l'état d'une tâche tel que le nœud le rapporte
+type Task struct {
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	DeviceID      string                 `json:"device_id,omitempty"`
+	Payload       map[string]interface{} `json:"payload"`
+	Priority      int                    `json:"priority"`
+	Criticality   int                    `json:"criticality"`
+	SmartScore    float64                `json:"smart_score"`
+	CPUCost       float64                `json:"cpu_cost,omitempty"`
+	RAMCost       float64                `json:"ram_cost,omitempty"`
+	StorageCost   float64                `json:"storage_cost,omitempty"`
+	Deadline      *time.Time             `json:"deadline,omitempty"`
+	TimeoutMs     int64                  `json:"timeout_ms,omitempty"`
+	Attempts      int                    `json:"attempts"`
+	NextAttemptAt *time.Time             `json:"next_attempt_at,omitempty"`
+	TraceID       string                 `json:"trace_id,omitempty"`
+	QueueClass    string                 `json:"queue_class,omitempty"`
+	PriorityClass string                 `json:"priority_class,omitempty"`
+	Owner         string                 `json:"owner,omitempty"`
+	Tenant        string                 `json:"tenant,omitempty"`
+	DependsOn     []string               `json:"depends_on,omitempty"`
+	WorkflowID    string                 `json:"workflow_id,omitempty"`
+	GroupID       string                 `json:"group_id,omitempty"`
+	Progress      *Progress              `json:"progress,omitempty"`
+	Status        string                 `json:"status"`
+	Result        json.RawMessage        `json:"result,omitempty"`
+	SubmittedAt   time.Time              `json:"submitted_at"`
+	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
+
+	// Archived indique une tâche oubliée par la rétention du nœud et lue
+	// dans son archive
+	Archived bool `json:"-"`
+}
+
+// Progress est l'avancement déclaré de l'exécution en cours d'une tâche
+type Progress struct {
+	Fraction  float64   `json:"fraction"`
+	Message   string    `json:"message,omitempty"`
+	Attempt   int       `json:"attempt"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Done indique que la tâche a atteint son état final: terminée, en échec
+// définitif, échue ou rejetée. Une tâche en échec dont un réessai est planifié
+// n'est pas terminée.
+func (t *Task) Done() bool {
+	return t.CompletedAt != nil || t.Status == "rejected" || t.Status == "deadline_missed"
+}
+
+// Succeeded indique que la tâche s'est terminée avec succès
+func (t *Task) Succeeded() bool {
+	return t.Status == "completed"
+}
+
+// DecodeResult décode le résultat de la tâche dans v
+func (t *Task) DecodeResult(v interface{}) error {
+	return json.Unmarshal(t.Result, v)
+}
+
+// SubmitTask soumet une tâche (POST /tasks) et retourne son état initial. Un
+// refus d'admission est une *APIError (503 surcharge, 429 quota), réessayée
+// tant que WithRetries le permet.
+func (c *Client) SubmitTask(ctx context.Context, req TaskRequest) (*Task, error) {
+	var task Task
+	if _, err := c.do(ctx, http.MethodPost, "/tasks", req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// SubmitTaskSync soumet une tâche et attend sa fin côté nœud (POST
+// /tasks/sync), au plus timeout (0: délai du nœud, SYNC_WAIT_TIMEOUT). Sans
+// fin dans le délai, la tâche continue et son état courant est retourné:
+// Done() le distingue.
+func (c *Client) SubmitTaskSync(ctx context.Context, req TaskRequest, timeout time.Duration) (*Task, error) {
+	path := "/tasks/sync"
+	if timeout > 0 {
+		path += "?timeout=" + url.QueryEscape(timeout.String())
+	}
+	var task Task
+	if _, err := c.do(ctx, http.MethodPost, path, req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTask retourne l'état courant d'une tâche (GET /tasks/{id})
+func (c *Client) GetTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	resp, err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id), nil, &task)
+	if err != nil {
+		return nil, err
+	}
+	task.Archived = resp.Header.Get("X-Fog-Archived") == "true"
+	return &task, nil
+}
+
+// ListOptions filtre ListTasks
+type ListOptions struct {
+	Status   string
+	Type     string
+	Limit    int  // Défaut du nœud: 100
+	Archived bool // Tâches oubliées par la rétention et archivées
+}
+
+// ListTasks liste les tâches du namespace du client, des plus récentes aux
+// plus anciennes (GET /tasks)
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) ([]Task, error) {
+	q := url.Values{}
+	if opts.Status != "" {
+		q.Set("status", opts.Status)
+	}
+	if opts.Type != "" {
+		q.Set("type", opts.Type)
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Archived {
+		q.Set("archived", "true")
+	}
+	path := "/tasks"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var list struct {
+		Tasks []Task `json:"tasks"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Tasks, nil
+}
+
+// WaitForResult attend l'état final d'une tâche en la consultant à
+// l'intervalle de WithPollInterval, jusqu'à l'annulation du contexte. La
+// tâche est retournée quel que soit son devenir: Succeeded() distingue la
+// réussite de l'échec.
+func (c *Client) WaitForResult(ctx context.Context, id string) (*Task, error) {
+	for {
+		task, err := c.GetTask(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if task.Done() {
+			return task, nil
+		}
+		if err := sleep(ctx, c.poll); err != nil {
+			return task, err
+		}
+	}
+}
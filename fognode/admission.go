@@ -0,0 +1,338 @@
+package fognode
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAdmissionPolicies est l'ordre des vérifications quand ADMISSION_POLICIES n'est pas défini
+const defaultAdmissionPolicies = "load,queue,tenant,device,quota,resources,calendar,clock,deadline,energy"
+
+// AdmissionContext est l'instantané de l'état du nœud sur lequel les
+// politiques d'admission se prononcent
+type AdmissionContext struct {
+	Task             *Task
+	Request          *http.Request
+	Draining         bool
+	Load             float64
+	QueueSize        int
+	ClassQueued      int
+	ClassFull        bool
+	ClassReason      string
+	AvailableCPU     float64
+	AvailableRAM     float64
+	AvailableStorage float64
+	EnergyLevel      float64
+	Tenant           TenantUsage // Occupation du namespace de la tâche
+	Host             *HostSample // Ressources mesurées de l'hôte, nil si indisponibles
+	Power            PowerStatus // Batterie et source du nœud
+	PowerMode        string      // Mode d'alimentation courant
+}
+
+// admissionContext prend l'instantané de l'état du nœud pour admettre la tâche
+// et lui attribue sa classe d'admission et sa clé de lissage
+func (fc *FogCompute) admissionContext(task *Task, r *http.Request) *AdmissionContext {
+	task.QueueClass = admissionClass(task)
+	task.PriorityClass = priorityClass(task)
+	fc.assignOwner(task, r)
+	fc.assignTenant(task, r)
+	task.shapingKey = deviceKey(task, r)
+	if task.Owner != "" && requestIdentity(r) != nil {
+		task.shapingKey = "client:" + task.Owner
+	}
+	return fc.admissionSnapshot(task, r)
+}
+
+// admissionSnapshot prend l'instantané de l'état du nœud pour une tâche dont
+// la classe et l'identité sont déjà attribuées (réadmission)
+func (fc *FogCompute) admissionSnapshot(task *Task, r *http.Request) *AdmissionContext {
+	host := fc.host.Sample()
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	classFull, classReason := fc.queueClassFull(task.QueueClass)
+	ac := &AdmissionContext{
+		Task:             task,
+		Request:          r,
+		Draining:         fc.draining,
+		Load:             fc.loadLocked(host, time.Now()).Load,
+		QueueSize:        fc.scheduler.Len(),
+		ClassQueued:      fc.classQueued[task.QueueClass],
+		ClassFull:        classFull,
+		ClassReason:      classReason,
+		AvailableCPU:     fc.availableCPU,
+		AvailableRAM:     fc.availableRAM,
+		AvailableStorage: fc.availableStorage,
+		EnergyLevel:      fc.energyLevel,
+		Tenant:           fc.tenants.usageOf(tenantOf(task)),
+		Host:             host,
+		Power:            fc.powerStatusLocked(time.Now()),
+		PowerMode:        fc.powerModes.mode,
+	}
+	// Une tâche qui sera reportée après la fenêtre planifiée ne consomme pas la
+	// capacité que celle-ci retire
+	if _, ok := fc.deferralWindow(task, time.Now()); ok {
+		ac.AvailableCPU += fc.capacityCut.CPU
+		ac.AvailableRAM += fc.capacityCut.RAM
+	}
+	return ac
+}
+
+// Rejection décrit le refus d'une politique d'admission
+type Rejection struct {
+	Policy     string // Politique à l'origine du refus
+	Reason     string
+	Status     int           // Code HTTP retourné au client
+	RetryAfter time.Duration // Délai conseillé avant de réessayer (0 = non précisé)
+}
+
+// AdmissionPolicy est une vérification de la chaîne d'admission. Check retourne
+// nil si la tâche est acceptée par cette politique.
+type AdmissionPolicy interface {
+	// Name retourne le nom de la politique (valeur de ADMISSION_POLICIES)
+	Name() string
+	// Check se prononce sur la tâche à partir de l'instantané
+	Check(fc *FogCompute, ac *AdmissionContext) *Rejection
+}
+
+// admissionFunc adapte une fonction en AdmissionPolicy
+type admissionFunc struct {
+	name  string
+	check func(fc *FogCompute, ac *AdmissionContext) *Rejection
+}
+
+func (p admissionFunc) Name() string { return p.name }
+func (p admissionFunc) Check(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	return p.check(fc, ac)
+}
+
+// write répond au client avec le refus, et le délai conseillé s'il est connu
+func (rej *Rejection) write(w http.ResponseWriter) {
+	if rej.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rej.RetryAfter.Seconds()))))
+	}
+	http.Error(w, rej.Reason, rej.Status)
+}
+
+// reject construit un refus avec 503, le code par défaut des rejets
+func reject(format string, args ...interface{}) *Rejection {
+	return &Rejection{Reason: fmt.Sprintf(format, args...), Status: http.StatusServiceUnavailable}
+}
+
+var (
+	admissionFactoriesMu sync.Mutex
+	// admissionFactories associe chaque nom de politique à son constructeur
+	admissionFactories = map[string]func() AdmissionPolicy{
+		"load":      func() AdmissionPolicy { return admissionFunc{"load", checkLoad} },
+		"queue":     func() AdmissionPolicy { return admissionFunc{"queue", checkQueueClass} },
+		"tenant":    func() AdmissionPolicy { return admissionFunc{"tenant", checkTenant} },
+		"device":    func() AdmissionPolicy { return admissionFunc{"device", checkDevice} },
+		"quota":     func() AdmissionPolicy { return newClientQuotaPolicy() },
+		"resources": func() AdmissionPolicy { return admissionFunc{"resources", checkResources} },
+		"calendar":  func() AdmissionPolicy { return admissionFunc{"calendar", checkCalendar} },
+		"clock":     func() AdmissionPolicy { return admissionFunc{"clock", checkClock} },
+		"deadline":  func() AdmissionPolicy { return admissionFunc{"deadline", checkDeadline} },
+		"energy":    func() AdmissionPolicy { return admissionFunc{"energy", checkEnergy} },
+	}
+)
+
+// RegisterAdmissionPolicy enregistre une politique spécifique à un déploiement,
+// utilisable ensuite dans ADMISSION_POLICIES. À appeler avant la création du nœud.
+func RegisterAdmissionPolicy(name string, factory func() AdmissionPolicy) {
+	admissionFactoriesMu.Lock()
+	defer admissionFactoriesMu.Unlock()
+	admissionFactories[name] = factory
+}
+
+// admissionPolicyNames liste les politiques disponibles
+func admissionPolicyNames() []string {
+	admissionFactoriesMu.Lock()
+	defer admissionFactoriesMu.Unlock()
+
+	names := make([]string, 0, len(admissionFactories))
+	for name := range admissionFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AdmissionChain applique les politiques dans l'ordre configuré; la première
+// qui refuse détermine la réponse
+type AdmissionChain struct {
+	policies   []AdmissionPolicy
+	rejections map[string]int // Rejets par politique
+	mu         sync.Mutex
+}
+
+// newAdmissionChain construit la chaîne à partir d'une liste de noms
+func newAdmissionChain(names []string) *AdmissionChain {
+	chain := &AdmissionChain{rejections: make(map[string]int)}
+
+	admissionFactoriesMu.Lock()
+	defer admissionFactoriesMu.Unlock()
+	for _, name := range names {
+		factory, ok := admissionFactories[name]
+		if !ok {
+			slog.Warn("Politique d'admission inconnue ignorée", "policy", name)
+			continue
+		}
+		chain.policies = append(chain.policies, factory())
+	}
+	return chain
+}
+
+// loadAdmissionChain crée la chaîne configurée via ADMISSION_POLICIES
+func loadAdmissionChain() *AdmissionChain {
+	names := getEnvList("ADMISSION_POLICIES")
+	if len(names) == 0 {
+		names = strings.Split(defaultAdmissionPolicies, ",")
+	}
+	return newAdmissionChain(names)
+}
+
+// Names retourne l'ordre effectif des politiques
+func (c *AdmissionChain) Names() []string {
+	names := make([]string, len(c.policies))
+	for i, p := range c.policies {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Admit fait passer la tâche par chaque politique; retourne le premier refus
+func (c *AdmissionChain) Admit(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	rej := c.Check(fc, ac)
+	if rej != nil {
+		c.mu.Lock()
+		c.rejections[rej.Policy]++
+		c.mu.Unlock()
+	}
+	return rej
+}
+
+// Check passe la tâche par la chaîne sans compter le refus (réadmissions répétées)
+func (c *AdmissionChain) Check(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	for _, p := range c.policies {
+		if rej := p.Check(fc, ac); rej != nil {
+			rej.Policy = p.Name()
+			return rej
+		}
+	}
+	return nil
+}
+
+// Rejections retourne le nombre de rejets par politique
+func (c *AdmissionChain) Rejections() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string]int, len(c.rejections))
+	for name, n := range c.rejections {
+		out[name] = n
+	}
+	return out
+}
+
+// checkLoad rejette quand le nœud est surchargé
+func checkLoad(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	if ac.Load > fc.load.threshold {
+		return reject("Nœud surchargé: charge=%.2f (seuil %.2f), taille_queue=%d", ac.Load, fc.load.threshold, ac.QueueSize)
+	}
+	return nil
+}
+
+// checkQueueClass applique la limite de la classe d'admission: un afflux de
+// tâches best-effort ne peut pas faire rejeter les tâches critiques
+func checkQueueClass(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	if ac.ClassFull {
+		return reject("%s", ac.ClassReason)
+	}
+	return nil
+}
+
+// checkDevice applique le lissage par appareil: un capteur bavard ne doit pas évincer les autres
+func checkDevice(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	ok, reason, retryAfter := fc.shaper.Admit(ac.Task.shapingKey, ac.ClassQueued, fc.queueLimits[ac.Task.QueueClass])
+	if ok {
+		return nil
+	}
+	return &Rejection{Reason: reason, Status: http.StatusTooManyRequests, RetryAfter: retryAfter}
+}
+
+// checkResources vérifie la disponibilité des ressources
+func checkResources(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	t := ac.Task
+	if t.CPUCost > ac.AvailableCPU || t.RAMCost > ac.AvailableRAM || t.StorageCost > ac.AvailableStorage {
+		if fc.canPreempt(t) {
+			// Les tâches en cours les moins critiques lui céderont leurs ressources à la mise en queue
+			return nil
+		}
+		return reject("Ressources insuffisantes: CPU=%.2f/%.2f, RAM=%.2f/%.2f, Storage=%.2f/%.2f",
+			t.CPUCost, ac.AvailableCPU, t.RAMCost, ac.AvailableRAM, t.StorageCost, ac.AvailableStorage)
+	}
+	return fc.checkHostResources(ac)
+}
+
+// checkClock refuse les échéances quand l'horloge locale n'est pas fiable
+func checkClock(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	if ac.Task.Deadline != nil && fc.rejectUnsyncedDeadlines && !fc.clock.Synchronized() {
+		return reject("Horloge non synchronisée: impossible de garantir l'échéance de la tâche")
+	}
+	return nil
+}
+
+// checkDeadline refuse les tâches dont l'échéance est déjà dépassée
+func checkDeadline(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	if ac.Task.Deadline != nil && fc.dropMissedDeadlines && time.Now().After(*ac.Task.Deadline) {
+		return &Rejection{
+			Reason: fmt.Sprintf("Échéance déjà dépassée: %s", ac.Task.Deadline.Format(time.RFC3339)),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+	return nil
+}
+
+// checkEnergy protège la réserve d'énergie pour les tâches critiques
+func checkEnergy(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	if ac.Task.Criticality >= 4 && ac.EnergyLevel < 0.3 {
+		return reject("Niveau d'énergie bas pour tâche critique: énergie=%.2f", ac.EnergyLevel)
+	}
+	if rej := checkPowerMode(fc, ac); rej != nil {
+		return rej
+	}
+	return checkPower(fc, ac)
+}
+
+// clientQuotaPolicy limite le nombre de tâches en attente par client
+// (device_id ou IP); inactive tant que CLIENT_QUEUE_QUOTA vaut 0
+type clientQuotaPolicy struct {
+	limit int
+}
+
+func newClientQuotaPolicy() *clientQuotaPolicy {
+	return &clientQuotaPolicy{limit: getEnvInt("CLIENT_QUEUE_QUOTA", 0)}
+}
+
+func (p *clientQuotaPolicy) Name() string { return "quota" }
+
+func (p *clientQuotaPolicy) Check(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	if p.limit <= 0 {
+		return nil
+	}
+	if queued := fc.shaper.Queued(ac.Task.shapingKey); queued >= p.limit {
+		return &Rejection{
+			Reason:     fmt.Sprintf("Quota du client %s atteint: %d/%d tâches en attente", ac.Task.shapingKey, queued, p.limit),
+			Status:     http.StatusTooManyRequests,
+			RetryAfter: time.Second,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultAggregationPercentiles sont les percentiles calculés sans "percentiles" dans le payload
+var defaultAggregationPercentiles = []float64{50, 90, 95, 99}
+
+// SensorReading est une lecture de capteur à agréger
+type SensorReading struct {
+	SensorID  string          `json:"sensor_id"`
+	Value     *float64        `json:"value"`
+	Timestamp json.RawMessage `json:"timestamp,omitempty"` // RFC 3339, ou secondes Unix
+}
+
+// AggregationPayload est le payload d'une tâche data_aggregation
+type AggregationPayload struct {
+	Readings    []SensorReading `json:"readings"`
+	Query       *TelemetryQuery `json:"query,omitempty"`       // Lectures du store de télémétrie, à la place de readings
+	Bucket      string          `json:"bucket,omitempty"`      // Fenêtre de regroupement ("1m", "15m"); vide: une seule fenêtre
+	Percentiles []float64       `json:"percentiles,omitempty"` // 0-100
+}
+
+// AggregationGroup est la synthèse des lectures d'un capteur dans une fenêtre
+type AggregationGroup struct {
+	SensorID    string             `json:"sensor_id"`
+	BucketStart *time.Time         `json:"bucket_start,omitempty"`
+	Count       int                `json:"count"`
+	Sum         float64            `json:"sum"`
+	Avg         float64            `json:"avg"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Percentiles map[string]float64 `json:"percentiles"` // "p50", "p99"...
+}
+
+// parseReadingTime lit l'horodatage d'une lecture: chaîne RFC 3339 ou
+// nombre de secondes Unix
+func parseReadingTime(raw json.RawMessage) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		return time.Time{}, fmt.Errorf("horodatage %s: RFC 3339 ou secondes Unix attendu", raw)
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
+
+// interpolatedPercentile retourne le percentile p (0-100) de valeurs triées, par
+// interpolation linéaire entre les rangs voisins
+func interpolatedPercentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// aggregateData agrège les lectures de capteurs du payload par capteur et par
+// fenêtre de temps: nombre, somme, moyenne, minimum, maximum et percentiles.
+// Un payload sans lectures donne une agrégation vide.
+func aggregateData(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	var p AggregationPayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("payload data_aggregation invalide: %w", err)
+	}
+	if p.Readings, err = queryReadings(p.Readings, p.Query); err != nil {
+		return nil, err
+	}
+	var bucket time.Duration
+	if p.Bucket != "" {
+		if bucket, err = time.ParseDuration(p.Bucket); err != nil || bucket <= 0 {
+			return nil, fmt.Errorf("bucket invalide %q: durée positive attendue (ex: 1m)", p.Bucket)
+		}
+	}
+	percentiles := p.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = defaultAggregationPercentiles
+	}
+	for _, pct := range percentiles {
+		if pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("percentile %g hors de [0, 100]", pct)
+		}
+	}
+
+	type groupKey struct {
+		sensor string
+		start  int64 // UnixNano du début de la fenêtre
+	}
+	values := make(map[groupKey][]float64)
+	for i, r := range p.Readings {
+		if i%10000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if r.SensorID == "" {
+			return nil, fmt.Errorf("lecture %d: sensor_id requis", i)
+		}
+		if r.Value == nil || math.IsNaN(*r.Value) || math.IsInf(*r.Value, 0) {
+			return nil, fmt.Errorf("lecture %d: value numérique requise", i)
+		}
+		key := groupKey{sensor: r.SensorID}
+		if bucket > 0 {
+			if len(r.Timestamp) == 0 {
+				return nil, fmt.Errorf("lecture %d: timestamp requis avec bucket", i)
+			}
+			ts, err := parseReadingTime(r.Timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("lecture %d: %w", i, err)
+			}
+			key.start = ts.Truncate(bucket).UnixNano()
+		}
+		values[key] = append(values[key], *r.Value)
+	}
+
+	keys := make([]groupKey, 0, len(values))
+	sensors := make(map[string]bool)
+	for k := range values {
+		keys = append(keys, k)
+		sensors[k.sensor] = true
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].sensor != keys[j].sensor {
+			return keys[i].sensor < keys[j].sensor
+		}
+		return keys[i].start < keys[j].start
+	})
+
+	groups := make([]AggregationGroup, 0, len(keys))
+	for _, k := range keys {
+		vs := values[k]
+		sort.Float64s(vs)
+		g := AggregationGroup{
+			SensorID:    k.sensor,
+			Count:       len(vs),
+			Min:         vs[0],
+			Max:         vs[len(vs)-1],
+			Percentiles: make(map[string]float64, len(percentiles)),
+		}
+		if bucket > 0 {
+			start := time.Unix(0, k.start).UTC()
+			g.BucketStart = &start
+		}
+		for _, v := range vs {
+			g.Sum += v
+		}
+		g.Avg = g.Sum / float64(g.Count)
+		for _, pct := range percentiles {
+			g.Percentiles["p"+strconv.FormatFloat(pct, 'f', -1, 64)] = interpolatedPercentile(vs, pct)
+		}
+		groups = append(groups, g)
+	}
+
+	result := map[string]interface{}{
+		"operation": "data_aggregation",
+		"status":    "success",
+		"count":     len(p.Readings),
+		"sensors":   len(sensors),
+		"groups":    groups,
+	}
+	if bucket > 0 {
+		result["bucket"] = bucket.String()
+	}
+	return result, nil
+}
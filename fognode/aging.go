@@ -0,0 +1,104 @@
+package fognode
+
+import (
+	"context"
+	"time"
+)
+
+// AgingPolicy fait progresser les tâches qui attendent: toutes les
+// AGING_INTERVAL, le SmartScore des tâches en queue est recalculé et diminué
+// de AGING_RATE points par minute d'attente, jusqu'à AGING_MAX_BONUS. Une
+// tâche peu prioritaire finit ainsi par passer devant un flux continu de
+// tâches mieux notées.
+type AgingPolicy struct {
+	interval time.Duration // 0: vieillissement désactivé
+	rate     float64       // Points de score par minute d'attente
+	maxBonus float64
+}
+
+// taskAging est la politique de vieillissement courante (AGING_*)
+var taskAging AgingPolicy
+
+// loadAgingPolicy lit AGING_INTERVAL, AGING_RATE et AGING_MAX_BONUS
+func loadAgingPolicy() AgingPolicy {
+	return AgingPolicy{
+		interval: getEnvDuration("AGING_INTERVAL", 10*time.Second),
+		rate:     getEnvFloat("AGING_RATE", 1),
+		maxBonus: getEnvFloat("AGING_MAX_BONUS", 20),
+	}
+}
+
+// bonus retourne la réduction de score acquise après une attente
+func (a AgingPolicy) bonus(wait time.Duration) float64 {
+	if a.interval <= 0 || wait <= 0 {
+		return 0
+	}
+	b := a.rate * wait.Minutes()
+	if b > a.maxBonus {
+		return a.maxBonus
+	}
+	return b
+}
+
+// agedScore retourne le SmartScore d'une tâche en queue, diminué du bonus de son attente
+func (t *Task) agedScore() float64 {
+	return t.calculateScore() - taskAging.bonus(time.Since(t.queuedAt))
+}
+
+// QueueWaitStats rend visible l'attente en queue (/metrics)
+type QueueWaitStats struct {
+	OldestMs     int64   `json:"oldest_ms"` // Attente de la plus ancienne tâche en queue
+	OldestTaskID string  `json:"oldest_task_id,omitempty"`
+	MaxMs        int64   `json:"max_ms"` // Plus longue attente observée depuis le démarrage
+	Aged         int     `json:"aged"`   // Tâches en queue depuis au moins AGING_INTERVAL, dont le score a vieilli
+	Interval     string  `json:"aging_interval"`
+	Rate         float64 `json:"aging_rate"`
+	MaxBonus     float64 `json:"aging_max_bonus"`
+}
+
+// runAging recalcule périodiquement les scores des tâches en queue et réordonne la queue
+func (fc *FogCompute) runAging(ctx context.Context) {
+	if taskAging.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(taskAging.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fc.mu.Lock()
+			fc.scheduler.Rescore()
+			fc.mu.Unlock()
+		}
+	}
+}
+
+// queueWaitStatsLocked mesure l'attente des tâches en queue; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) queueWaitStatsLocked(now time.Time) QueueWaitStats {
+	stats := QueueWaitStats{
+		MaxMs:    fc.maxQueueWait.Milliseconds(),
+		Interval: taskAging.interval.String(),
+		Rate:     taskAging.rate,
+		MaxBonus: taskAging.maxBonus,
+	}
+	var oldest time.Duration
+	for _, t := range fc.tasks {
+		if t.Status != "queued" || t.queuedAt.IsZero() {
+			continue
+		}
+		wait := now.Sub(t.queuedAt)
+		if wait > oldest {
+			oldest, stats.OldestTaskID = wait, t.ID
+		}
+		if taskAging.interval > 0 && wait >= taskAging.interval {
+			stats.Aged++
+		}
+	}
+	if oldest > fc.maxQueueWait {
+		stats.MaxMs = oldest.Milliseconds()
+	}
+	stats.OldestMs = oldest.Milliseconds()
+	return stats
+}
@@ -0,0 +1,284 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Méthodes de détection d'anomalies de edge_analytics
+const (
+	AnomalyEWMA     = "ewma"     // Moyenne et variance à décroissance exponentielle
+	AnomalyZScore   = "zscore"   // Moyenne et écart-type d'une fenêtre glissante
+	AnomalySeasonal = "seasonal" // Référence propre à chaque position dans la saison
+)
+
+// minRelativeStd borne l'écart-type de référence à une fraction de la
+// moyenne: sur une série constante, tout écart obtient un score élevé mais fini
+const minRelativeStd = 1e-3
+
+// anomalyScore retourne l'écart de x à la moyenne de référence, en écarts-types
+func anomalyScore(x, mean, std float64) float64 {
+	return math.Abs(x-mean) / math.Max(std, math.Max(minRelativeStd*math.Abs(mean), 1e-9))
+}
+
+// AnalyticsPayload est le payload d'une tâche edge_analytics: des lectures
+// au format de data_aggregation, analysées par capteur dans l'ordre des
+// horodatages (ou de soumission, sans horodatage); les lectures d'un même
+// capteur sont toutes horodatées ou aucune
+type AnalyticsPayload struct {
+	Readings  []SensorReading `json:"readings"`
+	Query     *TelemetryQuery `json:"query,omitempty"`     // Lectures du store de télémétrie, à la place de readings
+	Method    string          `json:"method,omitempty"`    // ewma (défaut), zscore ou seasonal
+	Threshold *float64        `json:"threshold,omitempty"` // Score au-delà duquel un point est anormal, 0 accepté (défaut: 3)
+	Alpha     float64         `json:"alpha,omitempty"`     // Lissage ewma et seasonal, 0-1 (défaut: 0.3)
+	Window    int             `json:"window,omitempty"`    // Fenêtre zscore, en points (défaut: 20)
+	Season    int             `json:"season,omitempty"`    // Période seasonal, en points (requise)
+	Warmup    int             `json:"warmup,omitempty"`    // Points d'apprentissage sans détection (défaut: 5; en saisons pour seasonal: 2)
+}
+
+// Anomaly est un point jugé anormal
+type Anomaly struct {
+	SensorID  string     `json:"sensor_id"`
+	Index     int        `json:"index"` // Position du point dans la série du capteur
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Value     float64    `json:"value"`
+	Expected  float64    `json:"expected"` // Référence avant ce point
+	Score     float64    `json:"score"`    // Écart à la référence, en écarts-types
+	Direction string     `json:"direction"`
+}
+
+// SeriesSummary résume l'analyse de la série d'un capteur
+type SeriesSummary struct {
+	SensorID  string  `json:"sensor_id"`
+	Points    int     `json:"points"`
+	Anomalies int     `json:"anomalies"`
+	Mean      float64 `json:"mean"`
+	Std       float64 `json:"std"`
+}
+
+// seriesPoint est un point d'une série, avec son horodatage éventuel
+type seriesPoint struct {
+	value float64
+	at    *time.Time
+}
+
+// baseline est une référence à décroissance exponentielle (moyenne et variance)
+type baseline struct {
+	n              int
+	mean, variance float64
+}
+
+// score retourne l'écart de x à la référence, en écarts-types
+func (b *baseline) score(x float64) float64 {
+	return anomalyScore(x, b.mean, math.Sqrt(b.variance))
+}
+
+// update intègre x à la référence avec le lissage alpha
+func (b *baseline) update(x, alpha float64) {
+	b.n++
+	if b.n == 1 {
+		b.mean = x
+		return
+	}
+	diff := x - b.mean
+	incr := alpha * diff
+	b.mean += incr
+	b.variance = (1 - alpha) * (b.variance + diff*incr)
+}
+
+// decodeAnalyticsPayload lit le payload et applique les valeurs par défaut
+func decodeAnalyticsPayload(payload map[string]interface{}) (AnalyticsPayload, error) {
+	p := AnalyticsPayload{}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("payload edge_analytics invalide: %w", err)
+	}
+	if p.Readings, err = queryReadings(p.Readings, p.Query); err != nil {
+		return p, err
+	}
+	if p.Method == "" {
+		p.Method = AnomalyEWMA
+	}
+	if p.Threshold == nil {
+		threshold := 3.0
+		p.Threshold = &threshold
+	}
+	if p.Alpha == 0 {
+		p.Alpha = 0.3
+	}
+	if p.Window == 0 {
+		p.Window = 20
+	}
+	if p.Warmup == 0 {
+		p.Warmup = 5
+		if p.Method == AnomalySeasonal {
+			p.Warmup = 2
+		}
+	}
+	switch {
+	case p.Method != AnomalyEWMA && p.Method != AnomalyZScore && p.Method != AnomalySeasonal:
+		return p, fmt.Errorf("method %q inconnue: ewma, zscore ou seasonal", p.Method)
+	case *p.Threshold < 0:
+		return p, fmt.Errorf("threshold %g négatif", *p.Threshold)
+	case p.Alpha <= 0 || p.Alpha > 1:
+		return p, fmt.Errorf("alpha %g hors de ]0, 1]", p.Alpha)
+	case p.Window < 2:
+		return p, fmt.Errorf("window %d: au moins 2 points", p.Window)
+	case p.Warmup < 0:
+		return p, fmt.Errorf("warmup %d négatif", p.Warmup)
+	case p.Method == AnomalySeasonal && p.Season < 2:
+		return p, fmt.Errorf("season requise pour seasonal: au moins 2 points par saison")
+	}
+	return p, nil
+}
+
+// detectAnomalies analyse la série d'un capteur selon la méthode du payload
+func detectAnomalies(sensorID string, points []seriesPoint, p AnalyticsPayload) ([]Anomaly, SeriesSummary) {
+	anomalies := make([]Anomaly, 0)
+	flag := func(i int, expected, score float64) {
+		if score <= *p.Threshold {
+			return
+		}
+		direction := "high"
+		if points[i].value < expected {
+			direction = "low"
+		}
+		anomalies = append(anomalies, Anomaly{
+			SensorID:  sensorID,
+			Index:     i,
+			Timestamp: points[i].at,
+			Value:     points[i].value,
+			Expected:  expected,
+			Score:     math.Round(score*1000) / 1000,
+			Direction: direction,
+		})
+	}
+
+	switch p.Method {
+	case AnomalyEWMA:
+		var b baseline
+		for i, pt := range points {
+			if b.n >= p.Warmup && b.n > 1 {
+				flag(i, b.mean, b.score(pt.value))
+			}
+			b.update(pt.value, p.Alpha)
+		}
+	case AnomalyZScore:
+		for i := range points {
+			if i < p.Warmup || i < 2 {
+				continue
+			}
+			start := i - p.Window
+			if start < 0 {
+				start = 0
+			}
+			var sum, sq float64
+			for _, pt := range points[start:i] {
+				sum += pt.value
+			}
+			mean := sum / float64(i-start)
+			for _, pt := range points[start:i] {
+				sq += (pt.value - mean) * (pt.value - mean)
+			}
+			std := math.Sqrt(sq / float64(i-start-1))
+			flag(i, mean, anomalyScore(points[i].value, mean, std))
+		}
+	case AnomalySeasonal:
+		phases := make([]baseline, p.Season)
+		for i, pt := range points {
+			b := &phases[i%p.Season]
+			if b.n >= p.Warmup && b.n > 1 {
+				flag(i, b.mean, b.score(pt.value))
+			}
+			b.update(pt.value, p.Alpha)
+		}
+	}
+
+	summary := SeriesSummary{SensorID: sensorID, Points: len(points), Anomalies: len(anomalies)}
+	if len(points) > 0 {
+		var sum, sq float64
+		for _, pt := range points {
+			sum += pt.value
+		}
+		summary.Mean = sum / float64(len(points))
+		for _, pt := range points {
+			sq += (pt.value - summary.Mean) * (pt.value - summary.Mean)
+		}
+		summary.Std = math.Sqrt(sq / float64(len(points)))
+	}
+	return anomalies, summary
+}
+
+// performAnalytics détecte les anomalies des séries de capteurs du payload
+// (EWMA, z-score glissant ou référence saisonnière) et retourne les points
+// anormaux avec leur horodatage et leur score. L'avancement est signalé
+// capteur par capteur.
+func performAnalytics(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	p, err := decodeAnalyticsPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	reportProgress(ctx, 0, "Lecture des capteurs")
+	series := make(map[string][]seriesPoint)
+	for i, r := range p.Readings {
+		if r.SensorID == "" {
+			return nil, fmt.Errorf("lecture %d: sensor_id requis", i)
+		}
+		if r.Value == nil || math.IsNaN(*r.Value) || math.IsInf(*r.Value, 0) {
+			return nil, fmt.Errorf("lecture %d: value numérique requise", i)
+		}
+		pt := seriesPoint{value: *r.Value}
+		if len(r.Timestamp) > 0 {
+			ts, err := parseReadingTime(r.Timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("lecture %d: %w", i, err)
+			}
+			pt.at = &ts
+		}
+		// Un ordre mêlant horodatages et positions ne serait pas un ordre total
+		if prev := series[r.SensorID]; len(prev) > 0 && (prev[0].at == nil) != (pt.at == nil) {
+			return nil, fmt.Errorf("lecture %d: capteur %q mêlant lectures horodatées et non horodatées", i, r.SensorID)
+		}
+		series[r.SensorID] = append(series[r.SensorID], pt)
+	}
+	sensors := make([]string, 0, len(series))
+	for id, points := range series {
+		sensors = append(sensors, id)
+		if points[0].at != nil {
+			// Tri stable: les lectures de même horodatage gardent l'ordre du payload
+			sort.SliceStable(points, func(i, j int) bool { return points[i].at.Before(*points[j].at) })
+		}
+	}
+	sort.Strings(sensors)
+
+	anomalies := make([]Anomaly, 0)
+	summaries := make([]SeriesSummary, 0, len(sensors))
+	for i, id := range sensors {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		reportProgress(ctx, float64(i)/float64(len(sensors)), "Détection d'anomalies: "+id)
+		found, summary := detectAnomalies(id, series[id], p)
+		anomalies = append(anomalies, found...)
+		summaries = append(summaries, summary)
+	}
+
+	return map[string]interface{}{
+		"operation":     "edge_analytics",
+		"status":        "success",
+		"method":        p.Method,
+		"threshold":     *p.Threshold,
+		"count":         len(p.Readings),
+		"series":        summaries,
+		"anomaly_count": len(anomalies),
+		"anomalies":     anomalies,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// analyticsPayload décode un payload edge_analytics écrit en JSON
+func analyticsPayload(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestAnalyticsThreshold(t *testing.T) {
+	// Série alternée puis un point proche de la moyenne: score faible mais non nul
+	readings := `"readings":[` +
+		`{"sensor_id":"s","value":10},{"sensor_id":"s","value":11},{"sensor_id":"s","value":10},` +
+		`{"sensor_id":"s","value":11},{"sensor_id":"s","value":10},{"sensor_id":"s","value":11},` +
+		`{"sensor_id":"s","value":10.6}]`
+	tests := []struct {
+		name      string
+		payload   string
+		threshold float64
+		anomalies bool
+	}{
+		{"défaut", `{"method":"zscore",` + readings + `}`, 3, false},
+		{"zéro explicite", `{"method":"zscore","threshold":0,` + readings + `}`, 0, true},
+		{"explicite", `{"method":"zscore","threshold":1.5,` + readings + `}`, 1.5, false},
+	}
+	for _, tt := range tests {
+		result, err := performAnalytics(context.Background(), analyticsPayload(t, tt.payload))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := result["threshold"]; got != tt.threshold {
+			t.Errorf("%s: threshold = %v, attendu %v", tt.name, got, tt.threshold)
+		}
+		if got := result["anomaly_count"].(int) > 0; got != tt.anomalies {
+			t.Errorf("%s: anomalies = %v, attendu %v", tt.name, result["anomalies"], tt.anomalies)
+		}
+	}
+
+	if _, err := performAnalytics(context.Background(), analyticsPayload(t, `{"threshold":-1,`+readings+`}`)); err == nil {
+		t.Error("threshold négatif accepté")
+	}
+}
+
+// spikeFirst retourne une série de 8 points à 10 précédés d'un pic à 50,
+// le pic portant l'horodatage le plus récent si timed
+func spikeFirst(timed bool) string {
+	var b strings.Builder
+	b.WriteString(`{"readings":[`)
+	for i := 0; i <= 8; i++ {
+		value, ts := 10, i
+		if i == 0 {
+			value, ts = 50, 9
+		} else {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"sensor_id":"s","value":%d`, value)
+		if timed {
+			fmt.Fprintf(&b, `,"timestamp":%d`, ts)
+		}
+		b.WriteString("}")
+	}
+	b.WriteString("]}")
+	return b.String()
+}
+
+func TestAnalyticsOrdering(t *testing.T) {
+	// Le pic, premier du payload, est le plus récent: il n'est détecté
+	// qu'une fois la série triée par horodatage
+	result, err := performAnalytics(context.Background(), analyticsPayload(t, spikeFirst(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anomalies := result["anomalies"].([]Anomaly)
+	if len(anomalies) != 1 || anomalies[0].Index != 8 || anomalies[0].Value != 50 {
+		t.Fatalf("anomalies = %+v, attendu le pic en position 8", anomalies)
+	}
+	if anomalies[0].Timestamp == nil || anomalies[0].Timestamp.Unix() != 9 {
+		t.Errorf("horodatage du pic = %v", anomalies[0].Timestamp)
+	}
+
+	// Sans horodatage, l'ordre du payload fait foi: le pic en tête sert d'apprentissage
+	result, err = performAnalytics(context.Background(), analyticsPayload(t, spikeFirst(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range result["anomalies"].([]Anomaly) {
+		if a.Value == 50 {
+			t.Errorf("pic détecté malgré l'ordre du payload: %+v", a)
+		}
+	}
+}
+
+func TestAnalyticsMixedTimestamps(t *testing.T) {
+	mixed := []string{
+		`{"readings":[{"sensor_id":"s","value":1,"timestamp":1},{"sensor_id":"s","value":2}]}`,
+		`{"readings":[{"sensor_id":"s","value":1},{"sensor_id":"s","value":2,"timestamp":"2026-10-15T10:00:00Z"}]}`,
+	}
+	for _, raw := range mixed {
+		if _, err := performAnalytics(context.Background(), analyticsPayload(t, raw)); err == nil || !strings.Contains(err.Error(), "mêlant") {
+			t.Errorf("%s: erreur %v, attendu un refus de la série mixte", raw, err)
+		}
+	}
+
+	// Des capteurs distincts peuvent différer
+	raw := `{"readings":[{"sensor_id":"a","value":1,"timestamp":1},{"sensor_id":"b","value":2},{"sensor_id":"a","value":3,"timestamp":2}]}`
+	if _, err := performAnalytics(context.Background(), analyticsPayload(t, raw)); err != nil {
+		t.Errorf("capteurs distincts: %v", err)
+	}
+}
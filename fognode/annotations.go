@@ -0,0 +1,128 @@
+package fognode
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Limites des annotations d'une tâche
+const (
+	maxAnnotations      = 100  // Au-delà, les plus anciennes sont oubliées
+	maxAnnotationLength = 4096 // Caractères par note
+)
+
+// AnnotationAuthorHeader identifie l'opérateur auteur d'une annotation quand
+// le corps n'en précise pas
+const AnnotationAuthorHeader = "X-Fog-Operator"
+
+// Annotation est une note d'opérateur attachée à une tâche ou à une tâche
+// rejetée ("resoumise à la main, l'appareil était hors ligne")
+type Annotation struct {
+	Author string    `json:"author"`
+	Note   string    `json:"note"`
+	At     time.Time `json:"at"`
+}
+
+// AnnotationResponse est la réponse à l'ajout d'une annotation
+type AnnotationResponse struct {
+	TaskID      string       `json:"task_id"`
+	Target      string       `json:"target"` // "task" ou "rejected"
+	Annotations []Annotation `json:"annotations"`
+}
+
+// annotate ajoute une annotation à la tâche
+func (t *Task) annotate(a Annotation) {
+	if len(t.Annotations) >= maxAnnotations {
+		t.Annotations = t.Annotations[len(t.Annotations)-maxAnnotations+1:]
+	}
+	t.Annotations = append(t.Annotations, a)
+}
+
+// readAnnotation lit l'annotation du corps de la requête; l'auteur est, à
+// défaut, l'en-tête X-Fog-Operator, puis l'identité authentifiée ou l'adresse
+// du client
+func readAnnotation(r *http.Request) (Annotation, string) {
+	var body struct {
+		Author string `json:"author"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return Annotation{}, err.Error()
+	}
+	note := strings.TrimSpace(body.Note)
+	if note == "" {
+		return Annotation{}, "Note requise"
+	}
+	if len([]rune(note)) > maxAnnotationLength {
+		return Annotation{}, "Note trop longue"
+	}
+
+	author := strings.TrimSpace(body.Author)
+	if author == "" {
+		author = strings.TrimSpace(r.Header.Get(AnnotationAuthorHeader))
+	}
+	if identity := requestIdentity(r); author == "" && identity != nil {
+		author = identity.Name
+	}
+	if author == "" {
+		author, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	return Annotation{Author: author, Note: note, At: time.Now()}, ""
+}
+
+// handleAnnotateTask annote une tâche connue du nœud, ou à défaut la tâche
+// rejetée de même identifiant
+func (fc *FogCompute) handleAnnotateTask(w http.ResponseWriter, r *http.Request) {
+	fc.annotate(w, r, true)
+}
+
+// handleAnnotateRejectedTask annote une tâche rejetée
+func (fc *FogCompute) handleAnnotateRejectedTask(w http.ResponseWriter, r *http.Request) {
+	fc.annotate(w, r, false)
+}
+
+// annotate enregistre l'annotation de la requête sur la tâche {id}; les
+// annotations d'une tâche rejetée la suivent si elle est resoumise
+func (fc *FogCompute) annotate(w http.ResponseWriter, r *http.Request, tasks bool) {
+	taskID := mux.Vars(r)["id"]
+	annotation, invalid := readAnnotation(r)
+	if invalid != "" {
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+
+	tenant, all := fc.requestTenant(r)
+	fc.mu.Lock()
+	var response *AnnotationResponse
+	if task, ok := fc.tasks[taskID]; ok && tasks && visibleTo(task, tenant, all) {
+		task.annotate(annotation)
+		fc.saveTaskLocked(task)
+		response = &AnnotationResponse{TaskID: taskID, Target: "task", Annotations: task.Annotations}
+	} else {
+		for i := range fc.rejectedTasks {
+			rt := &fc.rejectedTasks[i]
+			if rt.Task.ID != taskID || !visibleTo(&rt.Task, tenant, all) {
+				continue
+			}
+			rt.Task.annotate(annotation)
+			fc.storeErr("save_rejected", taskID, fc.store.SaveRejected(*rt))
+			response = &AnnotationResponse{TaskID: taskID, Target: "rejected", Annotations: rt.Task.Annotations}
+			break
+		}
+	}
+	fc.mu.Unlock()
+
+	if response == nil {
+		http.Error(w, "Tâche non trouvée", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
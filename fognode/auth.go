@@ -0,0 +1,356 @@
+package fognode
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// APIKeyHeader porte la clé d'API des clients et des nœuds pairs
+const APIKeyHeader = "X-API-Key"
+
+// Portées des clés d'API. admin couvre toutes les autres; peer couvre submit
+// et read, dont les pairs ont besoin pour déléguer et suivre les tâches.
+const (
+	ScopeRead   = "read"   // Consultation (GET)
+	ScopeSubmit = "submit" // Soumission et resoumission de tâches
+	ScopePeer   = "peer"   // Gossip et registre des nœuds
+	ScopeAdmin  = "admin"  // Maintenance, purge, calendrier, annotations
+)
+
+// impliedScopes sont les portées accordées en plus par chaque portée
+var impliedScopes = map[string][]string{
+	ScopeAdmin: {ScopeRead, ScopeSubmit, ScopePeer},
+	ScopePeer:  {ScopeRead, ScopeSubmit},
+}
+
+// routeScopes associe les routes non GET à la portée requise; les GET
+// demandent read (admin sous /admin/ et /debug/), les routes absentes admin
+var routeScopes = map[string]string{
+	"POST /tasks":                           ScopeSubmit,
+	"POST /tasks/admission":                 ScopeSubmit,
+	"POST /tasks/sync":                      ScopeSubmit,
+	"POST /tasks/batch":                     ScopeSubmit,
+	"POST /rejected-tasks/{id}/retry":       ScopeSubmit,
+	"POST /failed-tasks/{id}/retry":         ScopeSubmit,
+	"POST /schedules":                       ScopeSubmit,
+	"POST /schedules/{id}/pause":            ScopeSubmit,
+	"POST /schedules/{id}/resume":           ScopeSubmit,
+	"DELETE /schedules/{id}":                ScopeSubmit,
+	"POST /tasks/{id}/progress":             ScopeSubmit,
+	"DELETE /cache/{key}":                   ScopeSubmit,
+	"POST /telemetry":                       ScopeSubmit,
+	"POST /devices":                         ScopeSubmit,
+	"POST /gossip":                          ScopePeer,
+	"POST /nodes":                           ScopePeer,
+	"POST /nodes/{id}/heartbeat":            ScopePeer,
+	"DELETE /nodes/{id}":                    ScopePeer,
+	"POST /standby/replicate":               ScopePeer,
+	"POST /tasks/{id}/annotations":          ScopeAdmin,
+	"POST /rejected-tasks/{id}/annotations": ScopeAdmin,
+}
+
+// ClientIdentity est l'identité authentifiée d'une requête: nom de la clé
+// d'API, revendication d'identité du jeton OIDC ou nom commun du certificat
+// client vérifié. Elle devient le
+// propriétaire des tâches soumises, la clé de leurs quotas et figure dans les
+// journaux d'audit.
+type ClientIdentity struct {
+	Name   string
+	Method string // "api_key", "jwt" ou "mtls"
+	scopes map[string]bool
+}
+
+// identityContextKey porte l'identité authentifiée dans le contexte de la requête
+type identityContextKey struct{}
+
+// requestIdentity retourne l'identité authentifiée de la requête, nil si le
+// nœud est ouvert ou la requête absente (MQTT)
+func requestIdentity(r *http.Request) *ClientIdentity {
+	if r == nil {
+		return nil
+	}
+	identity, _ := r.Context().Value(identityContextKey{}).(*ClientIdentity)
+	return identity
+}
+
+// requestClient retourne le nom de l'identité authentifiée de la requête, ou
+// l'adresse du client sur un nœud ouvert (journaux d'audit)
+func requestClient(r *http.Request) string {
+	if identity := requestIdentity(r); identity != nil {
+		return identity.Name
+	}
+	return r.RemoteAddr
+}
+
+// assignOwner fixe le propriétaire d'une tâche soumise: l'identité du client
+// authentifié, ou celle transmise par un pair qui relaie la tâche. Sur un
+// nœud authentifié, un propriétaire déclaré sans authentification (MQTT) est
+// ignoré.
+func (fc *FogCompute) assignOwner(task *Task, r *http.Request) {
+	identity := requestIdentity(r)
+	switch {
+	case identity != nil && !identity.isPeer():
+		task.Owner = identity.Name
+	case identity == nil && fc.auth.Enabled():
+		task.Owner = ""
+	}
+}
+
+// isPeer indique si l'identité est celle d'un nœud pair, qui relaie des
+// tâches dont il n'est pas le propriétaire
+func (id *ClientIdentity) isPeer() bool {
+	return id.scopes[ScopePeer]
+}
+
+// APIKey est une clé d'API configurée; seule son empreinte est conservée
+type APIKey struct {
+	Name   string
+	hash   [sha256.Size]byte
+	scopes map[string]bool
+}
+
+// AuthStats décrit les refus d'authentification
+type AuthStats struct {
+	Enabled      bool             `json:"enabled"`
+	Keys         int              `json:"keys"`
+	Unauthorized int64            `json:"unauthorized"`    // 401: clé absente ou inconnue
+	Forbidden    int64            `json:"forbidden"`       // 403: portée insuffisante
+	Requests     map[string]int64 `json:"requests_by_key"` // Requêtes admises par clé, "jwt" pour les jetons
+	JWT          *JWTStats        `json:"jwt,omitempty"`
+}
+
+// APIAuth vérifie la clé X-API-Key ou le jeton "Authorization: Bearer" de
+// chaque requête et sa portée pour la route demandée. Sans clé ni fournisseur
+// OIDC configuré, le nœud reste ouvert.
+type APIAuth struct {
+	keys         []APIKey
+	jwt          *JWTAuth        // nil sans OIDC_ISSUER ni OIDC_JWKS_URL
+	certScopes   map[string]bool // Portées des certificats clients vérifiés (TLS_CLIENT_SCOPES)
+	exempt       map[string]bool // Modèles de chemin sans authentification (sondes)
+	unauthorized int64
+	forbidden    int64
+	requests     map[string]int64
+	mu           sync.Mutex
+}
+
+// NewAPIAuth lit API_KEYS ("nom:clé:portée+portée,...", une clé préfixée par
+// "sha256:" est une empreinte hexadécimale), API_AUTH_EXEMPT et
+// TLS_CLIENT_SCOPES ("peer+read", défaut: peer)
+func NewAPIAuth() *APIAuth {
+	a := &APIAuth{
+		exempt:     make(map[string]bool),
+		requests:   make(map[string]int64),
+		jwt:        NewJWTAuth(),
+		certScopes: make(map[string]bool),
+	}
+	for _, scope := range strings.Split(getEnv("TLS_CLIENT_SCOPES", ScopePeer), "+") {
+		if !grantScope(a.certScopes, strings.TrimSpace(scope)) {
+			slog.Warn("Portée TLS_CLIENT_SCOPES inconnue ignorée", "scope", scope)
+		}
+	}
+	exempt := getEnvList("API_AUTH_EXEMPT")
+	if len(exempt) == 0 {
+		exempt = []string{"/health", "/healthz", "/readyz"}
+	}
+	for _, path := range exempt {
+		a.exempt[path] = true
+	}
+
+	for _, entry := range getEnvList("API_KEYS") {
+		key, err := parseAPIKey(entry)
+		if err != nil {
+			slog.Warn("Clé API_KEYS invalide ignorée", "error", err)
+			continue
+		}
+		a.keys = append(a.keys, key)
+	}
+	if len(a.keys) > 0 {
+		slog.Info("Authentification par clé d'API activée", "keys", len(a.keys))
+	}
+	return a
+}
+
+// parseAPIKey lit une entrée "nom:clé:portée+portée" de API_KEYS
+func parseAPIKey(entry string) (APIKey, error) {
+	parts := strings.Split(entry, ":")
+	hashed := len(parts) == 4 && parts[1] == "sha256"
+	if hashed {
+		parts = []string{parts[0], parts[2], parts[3]}
+	}
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return APIKey{}, fmt.Errorf("format attendu nom:clé:portées pour %q", parts[0])
+	}
+
+	key := APIKey{Name: parts[0], scopes: make(map[string]bool)}
+	if hashed {
+		raw, err := hex.DecodeString(parts[1])
+		if err != nil || len(raw) != sha256.Size {
+			return APIKey{}, fmt.Errorf("empreinte sha256 invalide pour %q", key.Name)
+		}
+		copy(key.hash[:], raw)
+	} else {
+		key.hash = sha256.Sum256([]byte(parts[1]))
+	}
+	for _, scope := range strings.Split(parts[2], "+") {
+		if !grantScope(key.scopes, scope) {
+			return APIKey{}, fmt.Errorf("portée %q inconnue pour %q", scope, key.Name)
+		}
+	}
+	return key, nil
+}
+
+// grantScope accorde une portée et celles qu'elle implique; retourne false
+// pour une portée inconnue
+func grantScope(scopes map[string]bool, scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeSubmit, ScopePeer, ScopeAdmin:
+		scopes[scope] = true
+		for _, implied := range impliedScopes[scope] {
+			scopes[implied] = true
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Enabled indique si des clés ou un fournisseur OIDC sont configurés
+func (a *APIAuth) Enabled() bool {
+	return len(a.keys) > 0 || a.jwt != nil
+}
+
+// lookup retourne la clé correspondant à la valeur présentée; toutes les
+// clés sont comparées pour ne pas révéler laquelle est proche
+func (a *APIAuth) lookup(presented string) *APIKey {
+	hash := sha256.Sum256([]byte(presented))
+	var found *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], a.keys[i].hash[:]) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	return found
+}
+
+// requiredScope retourne la portée exigée par une route
+func requiredScope(method, template string) string {
+	if scope, ok := routeScopes[method+" "+template]; ok {
+		return scope
+	}
+	if strings.HasPrefix(template, "/admin/") || strings.HasPrefix(template, "/debug/") {
+		return ScopeAdmin
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return ScopeRead
+	}
+	return ScopeAdmin
+}
+
+// authenticate retourne l'identité portée par le jeton Bearer, la clé d'API
+// ou, à défaut de clé, le certificat client vérifié de la requête; le message
+// explique un refus
+func (a *APIAuth) authenticate(r *http.Request) (*ClientIdentity, string) {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && a.jwt != nil {
+		identity, err := a.jwt.Authenticate(r.Context(), strings.TrimSpace(bearer))
+		if err != nil {
+			return nil, "Jeton invalide: " + err.Error()
+		}
+		return identity, ""
+	}
+	if cert := verifiedClientCert(r); cert != nil && r.Header.Get(APIKeyHeader) == "" {
+		return &ClientIdentity{Name: cert.Subject.CommonName, Method: "mtls", scopes: a.certScopes}, ""
+	}
+	if len(a.keys) == 0 {
+		return nil, "Jeton Bearer requis"
+	}
+	key := a.lookup(r.Header.Get(APIKeyHeader))
+	if key == nil {
+		return nil, "Clé d'API absente ou invalide"
+	}
+	return &ClientIdentity{Name: key.Name, Method: "api_key", scopes: key.scopes}, ""
+}
+
+// middleware refuse les requêtes sans clé ni jeton valide (401) ou dont
+// l'identité n'a pas la portée de la route (403), et transmet l'identité aux
+// handlers par le contexte de la requête
+func (a *APIAuth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, template := routeKey(r)
+		if !a.Enabled() || method == http.MethodOptions || a.exempt[template] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, invalid := a.authenticate(r)
+		if identity == nil {
+			a.mu.Lock()
+			a.unauthorized++
+			a.mu.Unlock()
+			if a.jwt != nil {
+				w.Header().Add("WWW-Authenticate", `Bearer error="invalid_token"`)
+			}
+			if len(a.keys) > 0 {
+				w.Header().Add("WWW-Authenticate", `ApiKey header="`+APIKeyHeader+`"`)
+			}
+			http.Error(w, invalid, http.StatusUnauthorized)
+			return
+		}
+		scope := requiredScope(method, template)
+		if !identity.scopes[scope] {
+			a.mu.Lock()
+			a.forbidden++
+			a.mu.Unlock()
+			slog.Warn("Portée insuffisante", "client", identity.Name, "auth", identity.Method, "route", method+" "+template, "scope", scope)
+			http.Error(w, fmt.Sprintf("Portée %s requise", scope), http.StatusForbidden)
+			return
+		}
+
+		counter := identity.Name
+		if identity.Method == "jwt" {
+			counter = "jwt"
+		}
+		a.mu.Lock()
+		a.requests[counter]++
+		a.mu.Unlock()
+		if scope == ScopeAdmin {
+			slog.Info("Audit: action d'administration", "client", identity.Name, "auth", identity.Method, "route", method+" "+template, "path", r.URL.Path)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity)))
+	})
+}
+
+// Stats retourne l'état de l'authentification
+func (a *APIAuth) Stats() AuthStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := AuthStats{
+		Enabled:      a.Enabled(),
+		Keys:         len(a.keys),
+		Unauthorized: a.unauthorized,
+		Forbidden:    a.forbidden,
+		Requests:     make(map[string]int64, len(a.requests)),
+	}
+	for name, n := range a.requests {
+		stats.Requests[name] = n
+	}
+	if a.jwt != nil {
+		jwt := a.jwt.Stats()
+		stats.JWT = &jwt
+	}
+	return stats
+}
+
+// setNodeAPIKey ajoute la clé NODE_API_KEY aux requêtes adressées aux autres
+// nœuds (gossip, registre, délégation), qui doit y avoir la portée peer
+func setNodeAPIKey(req *http.Request) {
+	if key := getEnv("NODE_API_KEY", ""); key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+}
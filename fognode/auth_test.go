@@ -0,0 +1,160 @@
+package fognode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testAPIAuth crée une authentification par clés d'API, sans OIDC
+func testAPIAuth(t *testing.T, entries ...string) *APIAuth {
+	t.Helper()
+	a := &APIAuth{
+		exempt:     map[string]bool{"/health": true},
+		requests:   make(map[string]int64),
+		certScopes: map[string]bool{ScopePeer: true, ScopeRead: true, ScopeSubmit: true},
+	}
+	for _, entry := range entries {
+		key, err := parseAPIKey(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.keys = append(a.keys, key)
+	}
+	return a
+}
+
+// testAuthRouter expose chaque route sous son chemin historique et sous
+// /api/v1, derrière le middleware d'authentification
+func testAuthRouter(a *APIAuth, routes []string) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(a.middleware)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	for _, route := range routes {
+		method, template, _ := strings.Cut(route, " ")
+		r.HandleFunc(APIPrefix+template, ok).Methods(method)
+		r.HandleFunc(template, ok).Methods(method)
+	}
+	return r
+}
+
+// routePath remplit les variables d'un modèle de route
+func routePath(template string) string {
+	var b strings.Builder
+	for {
+		before, rest, found := strings.Cut(template, "{")
+		b.WriteString(before)
+		if !found {
+			return b.String()
+		}
+		_, template, _ = strings.Cut(rest, "}")
+		b.WriteString("x")
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, template, want string
+	}{
+		{http.MethodGet, "/tasks", ScopeRead},
+		{http.MethodHead, "/tasks/{id}", ScopeRead},
+		{http.MethodGet, "/admin/maintenance", ScopeAdmin},
+		{http.MethodGet, "/debug/pprof/", ScopeAdmin},
+		{http.MethodPost, "/admin/drain", ScopeAdmin},
+		// Toute route non GET absente de routeScopes exige admin
+		{http.MethodPost, "/route/inconnue", ScopeAdmin},
+		{http.MethodDelete, "/tasks/{id}", ScopeAdmin},
+		{http.MethodPut, "/tasks", ScopeAdmin},
+		{http.MethodPatch, "/schedules/{id}", ScopeAdmin},
+	}
+	for route, scope := range routeScopes {
+		method, template, _ := strings.Cut(route, " ")
+		tests = append(tests, struct{ method, template, want string }{method, template, scope})
+	}
+	for _, tt := range tests {
+		if got := requiredScope(tt.method, tt.template); got != tt.want {
+			t.Errorf("requiredScope(%s %s) = %s, attendu %s", tt.method, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestAuthScopesEnforced(t *testing.T) {
+	a := testAPIAuth(t,
+		"lecteur:k-read:read",
+		"capteur:k-submit:submit",
+		"pair:k-peer:peer",
+		"ops:k-admin:admin",
+	)
+	routes := []string{"GET /tasks", "POST /admin/drain", "POST /route/inconnue"}
+	for route := range routeScopes {
+		routes = append(routes, route)
+	}
+	router := testAuthRouter(a, routes)
+
+	keys := map[string]string{ScopeRead: "k-read", ScopeSubmit: "k-submit", ScopePeer: "k-peer", ScopeAdmin: "k-admin"}
+	for _, route := range routes {
+		method, template, _ := strings.Cut(route, " ")
+		required := requiredScope(method, template)
+		for _, prefix := range []string{"", APIPrefix} {
+			path := prefix + routePath(template)
+			for scope, key := range keys {
+				granted := make(map[string]bool)
+				grantScope(granted, scope)
+				want := http.StatusForbidden
+				if granted[required] {
+					want = http.StatusOK
+				}
+
+				req := httptest.NewRequest(method, path, nil)
+				req.Header.Set(APIKeyHeader, key)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != want {
+					t.Errorf("%s %s avec la portée %s: %d, attendu %d (portée requise %s)", method, path, scope, rec.Code, want, required)
+				}
+			}
+		}
+	}
+}
+
+func TestAuthUnauthorized(t *testing.T) {
+	a := testAPIAuth(t, "lecteur:k-read:read", "ops:sha256:"+strings.Repeat("00", 32)+":admin")
+	router := testAuthRouter(a, []string{"GET /tasks", "POST /tasks", "GET /health"})
+
+	tests := []struct {
+		name, method, path, key string
+		want                    int
+	}{
+		{"clé absente", http.MethodGet, "/tasks", "", http.StatusUnauthorized},
+		{"clé absente, /api/v1", http.MethodGet, APIPrefix + "/tasks", "", http.StatusUnauthorized},
+		{"clé inconnue", http.MethodGet, "/tasks", "k-inconnue", http.StatusUnauthorized},
+		{"préfixe d'une clé", http.MethodGet, "/tasks", "k-rea", http.StatusUnauthorized},
+		{"empreinte présentée comme clé", http.MethodGet, "/tasks", strings.Repeat("00", 32), http.StatusUnauthorized},
+		{"portée insuffisante", http.MethodPost, "/tasks", "k-read", http.StatusForbidden},
+		{"portée insuffisante, /api/v1", http.MethodPost, APIPrefix + "/tasks", "k-read", http.StatusForbidden},
+		{"portée suffisante", http.MethodGet, APIPrefix + "/tasks", "k-read", http.StatusOK},
+		{"route exemptée", http.MethodGet, "/health", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set(APIKeyHeader, tt.key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: %d, attendu %d", tt.name, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && !strings.Contains(rec.Header().Get("WWW-Authenticate"), APIKeyHeader) {
+			t.Errorf("%s: WWW-Authenticate = %q", tt.name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	stats := a.Stats()
+	if stats.Unauthorized != 5 || stats.Forbidden != 2 || stats.Requests["lecteur"] != 1 {
+		t.Errorf("statistiques: %+v", stats)
+	}
+}
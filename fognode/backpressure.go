@@ -0,0 +1,231 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// overflowEntry est une soumission en attente d'admission
+type overflowEntry struct {
+	task *Task
+	r    *http.Request // Requête d'origine: identité du client pour la réadmission
+	at   time.Time
+}
+
+// OverflowAcceptance est la réponse 202 d'une soumission placée en débordement
+type OverflowAcceptance struct {
+	*Task
+	Position       int       `json:"overflow_position"`
+	EstimatedStart time.Time `json:"estimated_start"`
+}
+
+// BackpressureStats décrit la file de débordement (/metrics)
+type BackpressureStats struct {
+	Enabled  bool  `json:"enabled"`
+	Size     int   `json:"size"`
+	Max      int   `json:"max"`
+	OldestMs int64 `json:"oldest_ms"`
+	Accepted int64 `json:"accepted"` // Soumissions acceptées en débordement (202)
+	Admitted int64 `json:"admitted"` // Passées ensuite dans la queue
+	Expired  int64 `json:"expired"`  // Rejetées après OVERFLOW_MAX_WAIT ou leur échéance
+	Refused  int64 `json:"refused"`  // Refusées avec 429, file pleine
+}
+
+// Backpressure remplace, quand BACKPRESSURE est activé, le 503 d'une
+// soumission refusée par surcharge passagère par une acceptation différée: la
+// tâche attend dans une file de débordement bornée (OVERFLOW_QUEUE_MAX), le
+// client reçoit 202 avec une estimation de son démarrage, et la tâche est
+// réadmise dans l'ordre d'arrivée dès que la chaîne d'admission l'accepte.
+// File pleine, la soumission est refusée avec 429 et Retry-After.
+type Backpressure struct {
+	enabled  bool
+	max      int
+	maxWait  time.Duration
+	interval time.Duration
+
+	// Protégés par fc.mu
+	queue    []overflowEntry
+	accepted int64
+	admitted int64
+	expired  int64
+	refused  int64
+}
+
+// loadBackpressure lit BACKPRESSURE, OVERFLOW_QUEUE_MAX, OVERFLOW_MAX_WAIT et OVERFLOW_RETRY_INTERVAL
+func loadBackpressure() *Backpressure {
+	return &Backpressure{
+		enabled:  getEnvBool("BACKPRESSURE", false),
+		max:      getEnvInt("OVERFLOW_QUEUE_MAX", 100),
+		maxWait:  getEnvDuration("OVERFLOW_MAX_WAIT", 5*time.Minute),
+		interval: getEnvDuration("OVERFLOW_RETRY_INTERVAL", 500*time.Millisecond),
+	}
+}
+
+// estimatedWaitLocked estime l'attente avant le démarrage d'une tâche
+// précédée de ahead tâches en débordement; fc.mu doit être détenu
+func (fc *FogCompute) estimatedWaitLocked(task *Task, ahead int) time.Duration {
+	fc.metrics.mu.RLock()
+	each := fc.metrics.AvgLatency
+	fc.metrics.mu.RUnlock()
+	if each <= 0 {
+		each = fc.expectedRuntime(task)
+	}
+	workers := fc.numWorkers - fc.reservedWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	tasks := fc.scheduler.Len() + fc.inFlight + ahead
+	return time.Duration(tasks) * each / time.Duration(workers)
+}
+
+// overflowTask place en débordement une soumission refusée par surcharge
+// passagère (503), ou la refuse avec 429 si la file est pleine; false si la
+// contre-pression ne s'applique pas et que le refus doit suivre son cours
+func (fc *FogCompute) overflowTask(w http.ResponseWriter, span *Span, task *Task, rej *Rejection, ac *AdmissionContext) bool {
+	bp := fc.backpressure
+	if !bp.enabled || rej.Status != http.StatusServiceUnavailable {
+		return false
+	}
+	now := time.Now()
+
+	fc.mu.Lock()
+	if len(bp.queue) >= bp.max {
+		bp.refused++
+		retryAfter := fc.estimatedWaitLocked(task, len(bp.queue))
+		fc.mu.Unlock()
+
+		full := &Rejection{
+			Policy:     rej.Policy,
+			Reason:     fmt.Sprintf("File de débordement pleine (%d tâches): %s", bp.max, rej.Reason),
+			Status:     http.StatusTooManyRequests,
+			RetryAfter: retryAfter,
+		}
+		span.SetAttr("http.status_code", full.Status)
+		span.SetError(full.Reason)
+		task.Status = "rejected"
+		fc.rejectTask(*task, full.Reason, ac.Load, ac.QueueSize)
+		full.write(w)
+		return true
+	}
+	task.Status = "overflow"
+	fc.tasks[task.ID] = task
+	bp.queue = append(bp.queue, overflowEntry{task: task, r: ac.Request, at: now})
+	bp.accepted++
+	position := len(bp.queue)
+	accepted := *task
+	resp := OverflowAcceptance{
+		Task:           &accepted,
+		Position:       position,
+		EstimatedStart: now.Add(fc.estimatedWaitLocked(task, position-1)),
+	}
+	fc.saveTaskLocked(task)
+	fc.mu.Unlock()
+	fc.journal.Record(&accepted)
+
+	span.SetAttr("http.status_code", http.StatusAccepted)
+	slog.Info("Tâche acceptée en débordement", "task_id", task.ID, "reason", rej.Reason,
+		"position", position, "estimated_start", resp.EstimatedStart.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", apiPath(ac.Request, "/tasks/"+task.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+	return true
+}
+
+// runOverflow réadmet périodiquement les tâches en débordement
+func (fc *FogCompute) runOverflow(ctx context.Context) {
+	if !fc.backpressure.enabled {
+		return
+	}
+	ticker := time.NewTicker(fc.backpressure.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fc.drainOverflow()
+		}
+	}
+}
+
+// drainOverflow fait passer en queue, dans l'ordre d'arrivée, les tâches en
+// débordement que la chaîne d'admission accepte; la première encore refusée
+// par surcharge arrête le passage. Seul runOverflow retire des entrées.
+func (fc *FogCompute) drainOverflow() {
+	bp := fc.backpressure
+	for {
+		fc.mu.RLock()
+		if len(bp.queue) == 0 || fc.draining {
+			fc.mu.RUnlock()
+			return
+		}
+		e := bp.queue[0]
+		fc.mu.RUnlock()
+
+		now := time.Now()
+		reason := ""
+		switch {
+		case now.Sub(e.at) > bp.maxWait:
+			reason = fmt.Sprintf("Attente en débordement dépassée (%s)", bp.maxWait)
+		case e.task.Deadline != nil && now.After(*e.task.Deadline):
+			reason = fmt.Sprintf("Échéance dépassée en débordement: %s", e.task.Deadline.Format(time.RFC3339))
+		}
+		ac := fc.admissionSnapshot(e.task, e.r)
+		if reason == "" {
+			rej := fc.admission.Check(fc, ac)
+			if rej != nil && rej.Status == http.StatusServiceUnavailable {
+				return // Toujours en surcharge: la tête de file attend
+			}
+			if rej != nil {
+				reason = rej.Reason
+			}
+		}
+
+		fc.mu.Lock()
+		bp.queue = bp.queue[1:]
+		if reason != "" {
+			bp.expired++
+			delete(fc.tasks, e.task.ID)
+			fc.storeErr("delete", e.task.ID, fc.store.DeleteTask(e.task.ID))
+			e.task.Status = "rejected"
+			rejected := *e.task
+			fc.mu.Unlock()
+			fc.rejectTask(rejected, reason, ac.Load, ac.QueueSize)
+			continue
+		}
+		bp.admitted++
+		fc.enqueueWhenReadyLocked(e.task)
+		fc.mu.Unlock()
+		slog.Info("Tâche en débordement admise", "task_id", e.task.ID, "waited_ms", now.Sub(e.at).Milliseconds())
+	}
+}
+
+// restoreOverflowLocked remet en débordement une tâche persistée; fc.mu doit être détenu
+func (fc *FogCompute) restoreOverflowLocked(task *Task) {
+	fc.tasks[task.ID] = task
+	fc.backpressure.queue = append(fc.backpressure.queue, overflowEntry{task: task, at: time.Now()})
+}
+
+// backpressureStatsLocked retourne l'état de la file de débordement; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) backpressureStatsLocked(now time.Time) BackpressureStats {
+	bp := fc.backpressure
+	stats := BackpressureStats{
+		Enabled:  bp.enabled,
+		Size:     len(bp.queue),
+		Max:      bp.max,
+		Accepted: bp.accepted,
+		Admitted: bp.admitted,
+		Expired:  bp.expired,
+		Refused:  bp.refused,
+	}
+	if len(bp.queue) > 0 {
+		stats.OldestMs = now.Sub(bp.queue[0].at).Milliseconds()
+	}
+	return stats
+}
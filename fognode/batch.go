@@ -0,0 +1,106 @@
+package fognode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// BatchItemResult est le sort d'une tâche d'une soumission groupée
+type BatchItemResult struct {
+	Index        int    `json:"index"` // Position dans le tableau soumis
+	TaskID       string `json:"task_id,omitempty"`
+	Status       string `json:"status"`      // queued, waiting, delayed ou rejected
+	HTTPStatus   int    `json:"http_status"` // Code qu'aurait reçu la tâche soumise seule
+	Reason       string `json:"reason,omitempty"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// BatchResult est la réponse de POST /tasks/batch
+type BatchResult struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []BatchItemResult `json:"results"`
+}
+
+// handleSubmitBatch soumet un tableau de tâches en une requête: chacune passe
+// par la même validation et la même chaîne d'admission que POST /tasks, dans
+// l'ordre du tableau, et reçoit son propre résultat. Une tâche refusée n'est
+// ni déléguée ni mise en débordement: la passerelle la resoumet plus tard.
+func (fc *FogCompute) handleSubmitBatch(w http.ResponseWriter, r *http.Request) {
+	parent, _ := parseTraceParent(r.Header.Get(TraceParentHeader))
+	span := fc.tracer.Start("POST /tasks/batch", SpanKindServer, parent)
+	defer span.End()
+	w.Header().Set(TraceParentHeader, span.Context().traceParent())
+
+	var tasks []Task
+	if err := json.NewDecoder(r.Body).Decode(&tasks); err != nil {
+		span.SetError(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if fc.batchMax > 0 && len(tasks) > fc.batchMax {
+		span.SetError("lot trop grand")
+		http.Error(w, fmt.Sprintf("Lot de %d tâches: au plus %d (BATCH_MAX_TASKS)", len(tasks), fc.batchMax), http.StatusRequestEntityTooLarge)
+		return
+	}
+	fc.mu.RLock()
+	draining := fc.draining
+	fc.mu.RUnlock()
+	if draining {
+		span.SetError("drainage")
+		http.Error(w, "Nœud en cours de drainage: soumissions suspendues", http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttr("batch.size", len(tasks))
+
+	result := BatchResult{Results: make([]BatchItemResult, 0, len(tasks))}
+	for i := range tasks {
+		item := fc.submitBatchItem(r, span, &tasks[i])
+		item.Index = i
+		if item.Status == "rejected" {
+			result.Rejected++
+		} else {
+			result.Accepted++
+		}
+		result.Results = append(result.Results, item)
+	}
+	slog.Info("Lot de tâches soumis", "size", len(tasks), "accepted", result.Accepted, "rejected", result.Rejected,
+		"client", requestClient(r), "trace_id", span.Context().traceIDString())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// submitBatchItem valide, admet et met en queue une tâche d'un lot
+func (fc *FogCompute) submitBatchItem(r *http.Request, span *Span, task *Task) BatchItemResult {
+	task.trace = span.Context()
+	task.TraceID = task.trace.traceIDString()
+	task.addHop(httpHop(fc.node.ID, r))
+	task.applyDefaultCosts()
+	task.SmartScore = task.calculateScore()
+
+	if rej := fc.prepareSubmission(task); rej != nil {
+		return BatchItemResult{TaskID: task.ID, Status: "rejected", HTTPStatus: rej.Status, Reason: rej.Reason}
+	}
+	ac := fc.admissionContext(task, r)
+	if rej := fc.admission.Admit(fc, ac); rej != nil {
+		task.Status = "rejected"
+		fc.rejectTask(*task, rej.Reason, ac.Load, ac.QueueSize)
+		return BatchItemResult{
+			TaskID:       task.ID,
+			Status:       "rejected",
+			HTTPStatus:   rej.Status,
+			Reason:       rej.Reason,
+			RetryAfterMs: rej.RetryAfter.Milliseconds(),
+		}
+	}
+
+	fc.mu.Lock()
+	fc.enqueueWhenReadyLocked(task)
+	status := task.Status
+	fc.mu.Unlock()
+	fc.journal.Record(task)
+	return BatchItemResult{TaskID: task.ID, Status: status, HTTPStatus: http.StatusOK}
+}
@@ -0,0 +1,431 @@
+package fognode
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stockages des blobs de résultat (RESULT_BLOB_STORE)
+const (
+	BlobStoreLocal = "local"
+	BlobStoreS3    = "s3"
+)
+
+// blobNamePattern restreint les noms de blobs et les identifiants de tâche
+// qui composent leur clé: ni séparateur de chemin, ni ".."
+var blobNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// blobKey retourne la clé d'un blob, <task-id>/<name>. Les ":" acceptés dans
+// les identifiants externes (ID_PATTERN) y deviennent "~", absent de tout
+// identifiant et sûr dans un chemin comme dans une clé S3.
+func blobKey(taskID, name string) string {
+	return strings.ReplaceAll(taskID, ":", "~") + "/" + name
+}
+
+var (
+	errBlobNotFound = errors.New("blob introuvable")
+	errBlobTooLarge = errors.New("blob trop volumineux")
+)
+
+// BlobRef est la référence d'un blob de résultat, conservée dans Task.Result
+// à la place de son contenu. GET /tasks/{id}/result?name=<name> le renvoie.
+type BlobRef struct {
+	Key         string `json:"blob"` // <task-id>/<name>
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	SHA256      string `json:"sha256"`
+	Store       string `json:"store"`
+	URL         string `json:"url"`
+}
+
+// BlobStore conserve les blobs de résultat écrits par les handlers
+type BlobStore interface {
+	// Put enregistre le contenu d'un blob décrit par ref, relisible depuis
+	// le début
+	Put(ctx context.Context, ref BlobRef, body io.ReadSeeker) error
+	// Open ouvre un blob; errBlobNotFound s'il n'existe pas. Le lecteur
+	// retourné implémente io.ReadSeeker quand le stockage le permet.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ResultBlobs est le stockage des résultats volumineux (images, sorties de
+// modèles) que les handlers écrivent via storeResultBlob
+type ResultBlobs struct {
+	Store    string // local ou s3
+	MaxBytes int64  // Taille maximale d'un blob
+	store    BlobStore
+}
+
+// loadResultBlobs lit RESULT_BLOB_STORE, RESULT_BLOB_DIR, RESULT_BLOB_MAX_MB
+// et, pour S3 ou MinIO, RESULT_S3_*
+func loadResultBlobs() *ResultBlobs {
+	rb := &ResultBlobs{
+		Store:    getEnv("RESULT_BLOB_STORE", BlobStoreLocal),
+		MaxBytes: int64(getEnvInt("RESULT_BLOB_MAX_MB", 1024)) << 20,
+	}
+	if rb.MaxBytes <= 0 {
+		config.errorf("RESULT_BLOB_MAX_MB", "la taille maximale d'un blob doit être positive")
+	}
+	switch rb.Store {
+	case BlobStoreLocal:
+		rb.store = &localBlobStore{dir: getEnv("RESULT_BLOB_DIR", filepath.Join(getEnv("DATA_DIR", "data"), "blobs"))}
+	case BlobStoreS3:
+		s3 := &s3BlobStore{
+			endpoint:  strings.TrimRight(getEnv("RESULT_S3_ENDPOINT", "https://s3.amazonaws.com"), "/"),
+			bucket:    getEnv("RESULT_S3_BUCKET", ""),
+			region:    getEnv("RESULT_S3_REGION", "us-east-1"),
+			accessKey: getEnv("RESULT_S3_ACCESS_KEY", ""),
+			secretKey: getEnv("RESULT_S3_SECRET_KEY", ""),
+			client:    &http.Client{Timeout: 5 * time.Minute},
+		}
+		if s3.bucket == "" || s3.accessKey == "" || s3.secretKey == "" {
+			config.errorf("RESULT_S3_BUCKET", "RESULT_S3_BUCKET, RESULT_S3_ACCESS_KEY et RESULT_S3_SECRET_KEY sont requis")
+		}
+		if _, err := url.Parse(s3.endpoint); err != nil {
+			config.errorf("RESULT_S3_ENDPOINT", "URL invalide: %v", err)
+		}
+		rb.store = s3
+	default:
+		config.errorf("RESULT_BLOB_STORE", "stockage %q inconnu (local ou s3)", rb.Store)
+		rb.Store = BlobStoreLocal
+		rb.store = &localBlobStore{dir: filepath.Join(getEnv("DATA_DIR", "data"), "blobs")}
+	}
+	return rb
+}
+
+// put copie le contenu d'un blob dans un fichier temporaire, pour en
+// connaître la taille et l'empreinte, puis le confie au stockage
+func (rb *ResultBlobs) put(ctx context.Context, taskID, name, contentType string, r io.Reader) (BlobRef, error) {
+	if !blobNamePattern.MatchString(name) {
+		return BlobRef{}, fmt.Errorf("nom de blob invalide %q", name)
+	}
+	if !blobNamePattern.MatchString(strings.ReplaceAll(taskID, ":", "_")) {
+		return BlobRef{}, fmt.Errorf("identifiant de tâche %q inutilisable comme clé de blob", taskID)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	spool, err := os.CreateTemp("", "fog-blob-")
+	if err != nil {
+		return BlobRef{}, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(r, rb.MaxBytes+1))
+	if err != nil {
+		return BlobRef{}, err
+	}
+	if n > rb.MaxBytes {
+		return BlobRef{}, fmt.Errorf("%w: plus de %d Mo (RESULT_BLOB_MAX_MB)", errBlobTooLarge, rb.MaxBytes>>20)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return BlobRef{}, err
+	}
+
+	ref := BlobRef{
+		Key:         blobKey(taskID, name),
+		Name:        name,
+		ContentType: contentType,
+		SizeBytes:   n,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Store:       rb.Store,
+		URL:         APIPrefix + "/tasks/" + taskID + "/result?name=" + url.QueryEscape(name),
+	}
+	if err := rb.store.Put(ctx, ref, spool); err != nil {
+		return BlobRef{}, fmt.Errorf("écriture du blob %s: %w", ref.Key, err)
+	}
+	slog.Info("Blob de résultat enregistré", "task_id", taskID, "name", name, "size_bytes", n, "store", rb.Store)
+	return ref, nil
+}
+
+// storeResultBlob permet à un handler d'écrire un résultat volumineux dans le
+// stockage de blobs du nœud; il place la référence retournée dans son
+// résultat, et le contenu est servi par GET /tasks/{id}/result?name=<name>.
+// Un blob réécrit par une nouvelle tentative remplace le précédent.
+func storeResultBlob(ctx context.Context, name, contentType string, r io.Reader) (BlobRef, error) {
+	te, ok := taskExecution(ctx)
+	if !ok || te.PutBlob == nil {
+		return BlobRef{}, errors.New("écriture de blob hors d'un worker")
+	}
+	return te.PutBlob(ctx, name, contentType, r)
+}
+
+// resultBlobs retourne les références de blobs du résultat d'une tâche, à
+// toute profondeur. Seules les clés de la tâche sont retenues: un résultat ne
+// peut pas désigner le blob d'une autre.
+func resultBlobs(taskID string, result interface{}) []BlobRef {
+	if result == nil {
+		return nil
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil
+	}
+	var refs []BlobRef
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			key, _ := v["blob"].(string)
+			name, _ := v["name"].(string)
+			if _, hashed := v["sha256"].(string); hashed && key == blobKey(taskID, name) {
+				var ref BlobRef
+				if b, err := json.Marshal(v); err == nil && json.Unmarshal(b, &ref) == nil {
+					refs = append(refs, ref)
+				}
+				return
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(decoded)
+	return refs
+}
+
+// serveResultBlob diffuse un blob du résultat d'une tâche: celui de ?name=,
+// ou le seul blob du résultat. Retourne false si le résultat n'en contient
+// aucun.
+func (fc *FogCompute) serveResultBlob(w http.ResponseWriter, r *http.Request, taskID string, result interface{}) bool {
+	refs := resultBlobs(taskID, result)
+	if len(refs) == 0 {
+		return false
+	}
+	name := r.URL.Query().Get("name")
+	var ref *BlobRef
+	names := make([]string, 0, len(refs))
+	for i := range refs {
+		names = append(names, refs[i].Name)
+		if refs[i].Name == name || (name == "" && len(refs) == 1) {
+			ref = &refs[i]
+		}
+	}
+	if ref == nil {
+		if name == "" {
+			sort.Strings(names)
+			http.Error(w, "Plusieurs blobs dans le résultat, préciser ?name= parmi: "+strings.Join(names, ", "), http.StatusBadRequest)
+			return true
+		}
+		http.Error(w, "Blob non trouvé dans le résultat", http.StatusNotFound)
+		return true
+	}
+
+	body, err := fc.blobs.store.Open(r.Context(), ref.Key)
+	if errors.Is(err, errBlobNotFound) {
+		http.Error(w, "Blob non trouvé (expiré ou supprimé)", http.StatusNotFound)
+		return true
+	}
+	if err != nil {
+		slog.Error("Lecture du blob impossible", "task_id", taskID, "blob", ref.Key, "error", err)
+		http.Error(w, "Stockage de blobs indisponible", http.StatusBadGateway)
+		return true
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", ref.ContentType)
+	w.Header().Set("ETag", `"`+ref.SHA256+`"`)
+	w.Header().Set("X-Content-SHA256", ref.SHA256)
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, ref.Name, time.Time{}, seeker)
+		return true
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(ref.SizeBytes))
+	if _, err := io.Copy(w, body); err != nil {
+		slog.Warn("Diffusion du blob interrompue", "task_id", taskID, "blob", ref.Key, "error", err)
+	}
+	return true
+}
+
+// localBlobStore range les blobs sous RESULT_BLOB_DIR/<task-id>/<name>
+type localBlobStore struct {
+	dir string
+}
+
+func (s *localBlobStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s *localBlobStore) Put(ctx context.Context, ref BlobRef, body io.ReadSeeker) error {
+	path := s.path(ref.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Renommage atomique: un lecteur ne voit jamais de blob à moitié écrit
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return f, err
+}
+
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	path := s.path(key)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	os.Remove(filepath.Dir(path)) // Répertoire de la tâche, s'il est vide
+	return nil
+}
+
+// s3BlobStore range les blobs dans un bucket S3 ou MinIO (adressage par
+// chemin: <endpoint>/<bucket>/<task-id>/<name>), requêtes signées en AWS
+// Signature Version 4
+type s3BlobStore struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// objectURL retourne l'URL d'un objet du bucket
+func (s *s3BlobStore) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return s.endpoint + "/" + url.PathEscape(s.bucket) + "/" + strings.Join(segments, "/")
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, ref BlobRef, body io.ReadSeeker) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(ref.Key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = ref.SizeBytes
+	req.Header.Set("Content-Type", ref.ContentType)
+	s.sign(req, ref.SHA256)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("le stockage objet a répondu %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *s3BlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errBlobNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("le stockage objet a répondu %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return fmt.Errorf("le stockage objet a répondu %s", resp.Status)
+	}
+	return nil
+}
+
+// do envoie une requête sans corps sur un objet du bucket
+func (s *s3BlobStore) do(ctx context.Context, method, key string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, hexSHA256(nil))
+	return s.client.Do(req)
+}
+
+// sign ajoute à la requête la signature AWS Signature Version 4 de la
+// méthode, du chemin, de l'hôte et de l'empreinte du corps
+func (s *s3BlobStore) sign(req *http.Request, payloadHash string) {
+	now := time.Now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + stamp + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hexSHA256([]byte(canonical))
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{day, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package fognode
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestResultBlobsPutExternalID(t *testing.T) {
+	rb := &ResultBlobs{Store: BlobStoreLocal, MaxBytes: 1 << 20, store: &localBlobStore{dir: t.TempDir()}}
+	ctx := context.Background()
+
+	for _, id := range []string{"site:42", "site_42", "01J9Z3K8Q6W2D7X4M5N0P1R2S3"} {
+		ref, err := rb.put(ctx, id, "out.bin", "", strings.NewReader(id))
+		if err != nil {
+			t.Fatalf("put(%q): %v", id, err)
+		}
+		refs := resultBlobs(id, map[string]interface{}{"blob": ref})
+		if len(refs) != 1 || refs[0].Key != ref.Key {
+			t.Fatalf("resultBlobs(%q) = %+v, attendu la clé %q", id, refs, ref.Key)
+		}
+		body, err := rb.store.Open(ctx, ref.Key)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", ref.Key, err)
+		}
+		content, _ := io.ReadAll(body)
+		body.Close()
+		if string(content) != id {
+			t.Errorf("blob de %q = %q", id, content)
+		}
+	}
+
+	// "site:42" et "site_42" ne partagent pas de clé
+	if blobKey("site:42", "a") == blobKey("site_42", "a") {
+		t.Error("clés de blob en collision")
+	}
+
+	for _, id := range []string{"../etc", "a/b", ":x", ""} {
+		if _, err := rb.put(ctx, id, "out.bin", "", strings.NewReader("x")); err == nil {
+			t.Errorf("put(%q) accepté", id)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package fognode
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DataCache est le cache en mémoire alimenté par les tâches caching: les
+// entrées expirent à leur TTL et, au-delà de CACHE_MAX_ENTRIES entrées ou de
+// CACHE_MAX_MB, les moins récemment utilisées sont évincées. Les clés sont
+// propres à chaque namespace. Le cache ne survit pas à un redémarrage.
+type DataCache struct {
+	mu         sync.Mutex
+	lru        *list.List               // Entrées, de la plus récemment utilisée à la plus ancienne
+	items      map[string]*list.Element // Clé "namespace/clé" → entrée
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+
+	hits, misses, evictions, expirations int64
+}
+
+// CacheEntry est une entrée du cache (GET /cache/{key})
+type CacheEntry struct {
+	Key       string          `json:"key"`
+	Tenant    string          `json:"tenant"`
+	Value     json.RawMessage `json:"value"`
+	SizeBytes int             `json:"size_bytes"`
+	StoredAt  time.Time       `json:"stored_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// CacheStats résume l'occupation et l'efficacité du cache
+type CacheStats struct {
+	Entries     int     `json:"entries"`
+	Bytes       int64   `json:"bytes"`
+	MaxEntries  int     `json:"max_entries"`
+	MaxBytes    int64   `json:"max_bytes"`
+	Hits        int64   `json:"hits"`
+	Misses      int64   `json:"misses"`
+	HitRatio    float64 `json:"hit_ratio"`
+	Evictions   int64   `json:"evictions"`   // Entrées évincées faute de place
+	Expirations int64   `json:"expirations"` // Entrées supprimées à leur TTL
+}
+
+// dataCache est le cache des tâches caching (loadDataCache)
+var dataCache *DataCache
+
+// loadDataCache lit les limites CACHE_* du cache des tâches caching
+func loadDataCache() {
+	dataCache = &DataCache{
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		maxEntries: getEnvInt("CACHE_MAX_ENTRIES", 10000),
+		maxBytes:   int64(getEnvInt("CACHE_MAX_MB", 64)) << 20,
+		defaultTTL: getEnvDuration("CACHE_DEFAULT_TTL", time.Hour),
+		maxTTL:     getEnvDuration("CACHE_MAX_TTL", 24*time.Hour),
+	}
+	if dataCache.maxEntries <= 0 || dataCache.maxBytes <= 0 || dataCache.defaultTTL <= 0 || dataCache.maxTTL < dataCache.defaultTTL {
+		config.errorf("CACHE_*", "les limites du cache doivent être positives, CACHE_MAX_TTL au moins égal à CACHE_DEFAULT_TTL")
+	}
+}
+
+// cacheKey retourne la clé interne d'une entrée d'un namespace
+func cacheKey(tenant, key string) string {
+	return tenant + "/" + key
+}
+
+// Set enregistre une valeur pour ttl (0: CACHE_DEFAULT_TTL), en remplaçant
+// l'entrée existante; retourne l'entrée et le nombre d'entrées évincées
+func (c *DataCache) Set(tenant, key string, value json.RawMessage, ttl time.Duration, now time.Time) (CacheEntry, int, error) {
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
+	if ttl < 0 || ttl > c.maxTTL {
+		return CacheEntry{}, 0, fmt.Errorf("ttl %s hors de ]0, %s] (CACHE_MAX_TTL)", ttl, c.maxTTL)
+	}
+	entry := CacheEntry{
+		Key:       key,
+		Tenant:    tenant,
+		Value:     value,
+		SizeBytes: len(key) + len(value),
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if int64(entry.SizeBytes) > c.maxBytes {
+		return CacheEntry{}, 0, fmt.Errorf("valeur de %d octets supérieure à la taille du cache (CACHE_MAX_MB)", entry.SizeBytes)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := cacheKey(tenant, key)
+	if el, ok := c.items[id]; ok {
+		c.removeLocked(el)
+	}
+	c.items[id] = c.lru.PushFront(&entry)
+	c.bytes += int64(entry.SizeBytes)
+
+	evicted := 0
+	if len(c.items) > c.maxEntries || c.bytes > c.maxBytes {
+		c.purgeExpiredLocked(now) // Les entrées expirées partent avant les vivantes
+	}
+	for len(c.items) > c.maxEntries || c.bytes > c.maxBytes {
+		c.removeLocked(c.lru.Back())
+		c.evictions++
+		evicted++
+	}
+	return entry, evicted, nil
+}
+
+// Get retourne l'entrée non expirée d'une clé et la marque comme récemment utilisée
+func (c *DataCache) Get(tenant, key string, now time.Time) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[cacheKey(tenant, key)]
+	if ok && !now.Before(el.Value.(*CacheEntry).ExpiresAt) {
+		c.removeLocked(el)
+		c.expirations++
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return CacheEntry{}, false
+	}
+	c.hits++
+	c.lru.MoveToFront(el)
+	return *el.Value.(*CacheEntry), true
+}
+
+// Delete supprime l'entrée d'une clé; retourne false si elle était absente ou expirée
+func (c *DataCache) Delete(tenant, key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[cacheKey(tenant, key)]
+	if !ok {
+		return false
+	}
+	c.removeLocked(el)
+	return now.Before(el.Value.(*CacheEntry).ExpiresAt)
+}
+
+// Sweep supprime les entrées expirées
+func (c *DataCache) Sweep(now time.Time) {
+	c.mu.Lock()
+	c.purgeExpiredLocked(now)
+	c.mu.Unlock()
+}
+
+// Stats retourne l'occupation et les compteurs du cache
+func (c *DataCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := CacheStats{
+		Entries:     len(c.items),
+		Bytes:       c.bytes,
+		MaxEntries:  c.maxEntries,
+		MaxBytes:    c.maxBytes,
+		Hits:        c.hits,
+		Misses:      c.misses,
+		Evictions:   c.evictions,
+		Expirations: c.expirations,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRatio = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// purgeExpiredLocked supprime les entrées expirées; c.mu doit être détenu
+func (c *DataCache) purgeExpiredLocked(now time.Time) {
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if !now.Before(el.Value.(*CacheEntry).ExpiresAt) {
+			c.removeLocked(el)
+			c.expirations++
+		}
+		el = prev
+	}
+}
+
+// removeLocked retire une entrée; c.mu doit être détenu
+func (c *DataCache) removeLocked(el *list.Element) {
+	entry := c.lru.Remove(el).(*CacheEntry)
+	delete(c.items, cacheKey(entry.Tenant, entry.Key))
+	c.bytes -= int64(entry.SizeBytes)
+}
+
+// CachePayload est le payload d'une tâche caching
+type CachePayload struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	TTL   string          `json:"ttl,omitempty"` // Durée ("10m"); défaut: CACHE_DEFAULT_TTL
+}
+
+// cacheData enregistre la valeur du payload dans le cache, sous sa clé et
+// dans le namespace de la tâche; elle est ensuite lisible par GET /cache/{key}
+func cacheData(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	var p CachePayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("payload caching invalide: %w", err)
+	}
+	if p.Key == "" {
+		return nil, errors.New("payload caching: key requise")
+	}
+	if len(p.Value) == 0 {
+		return nil, errors.New("payload caching: value requise")
+	}
+	var ttl time.Duration
+	if p.TTL != "" {
+		if ttl, err = time.ParseDuration(p.TTL); err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("ttl invalide %q: durée positive attendue (ex: 10m)", p.TTL)
+		}
+	}
+	tenant := DefaultTenant
+	if te, ok := taskExecution(ctx); ok && te.Tenant != "" {
+		tenant = te.Tenant
+	}
+
+	entry, evicted, err := dataCache.Set(tenant, p.Key, p.Value, ttl, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"operation":  "caching",
+		"status":     "success",
+		"cached":     true,
+		"key":        entry.Key,
+		"ttl":        int64(entry.ExpiresAt.Sub(entry.StoredAt).Seconds()),
+		"expires_at": entry.ExpiresAt,
+		"size_bytes": entry.SizeBytes,
+		"evicted":    evicted,
+	}, nil
+}
+
+// handleGetCacheEntry retourne une entrée du cache du namespace du client
+func (fc *FogCompute) handleGetCacheEntry(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := fc.requestTenant(r)
+	if tenant == "" {
+		tenant = DefaultTenant // Administrateur sans namespace demandé
+	}
+	entry, ok := dataCache.Get(tenant, mux.Vars(r)["key"], time.Now())
+	if !ok {
+		http.Error(w, "Entrée de cache non trouvée", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// handleDeleteCacheEntry supprime une entrée du cache du namespace du client
+func (fc *FogCompute) handleDeleteCacheEntry(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := fc.requestTenant(r)
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	if !dataCache.Delete(tenant, mux.Vars(r)["key"], time.Now()) {
+		http.Error(w, "Entrée de cache non trouvée", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,175 @@
+package fognode
+
+import (
+	"container/list"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestDataCache crée un cache de maxEntries entrées et maxBytes octets
+func newTestDataCache(maxEntries int, maxBytes int64) *DataCache {
+	return &DataCache{
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		defaultTTL: time.Minute,
+		maxTTL:     time.Hour,
+	}
+}
+
+// lruKeys retourne les clés du cache, de la plus récemment utilisée à la plus ancienne
+func lruKeys(c *DataCache) []string {
+	var keys []string
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*CacheEntry).Key)
+	}
+	return keys
+}
+
+func mustSet(t *testing.T, c *DataCache, key string, ttl time.Duration, now time.Time) int {
+	t.Helper()
+	_, evicted, err := c.Set(DefaultTenant, key, json.RawMessage(`"v"`), ttl, now)
+	if err != nil {
+		t.Fatalf("Set(%s): %v", key, err)
+	}
+	return evicted
+}
+
+func TestDataCacheEvictionOrder(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestDataCache(3, 1<<20)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if evicted := mustSet(t, c, key, 0, now); evicted != 0 {
+			t.Fatalf("Set(%s): %d évictions dans un cache non plein", key, evicted)
+		}
+	}
+	// Plein: chaque ajout évince la moins récemment utilisée
+	for _, step := range []struct {
+		key     string
+		gone    string // Entrée évincée, vide: aucune
+		wantLRU []string
+	}{
+		{"d", "a", []string{"d", "c", "b"}},
+		{"e", "b", []string{"e", "d", "c"}},
+		{"c", "", []string{"c", "e", "d"}}, // Remplacement: aucune éviction, passe en tête
+		{"f", "d", []string{"f", "c", "e"}},
+	} {
+		evicted := mustSet(t, c, step.key, 0, now)
+		want := 0
+		if step.gone != "" {
+			want = 1
+		}
+		if evicted != want {
+			t.Errorf("Set(%s): %d évictions, attendu %d", step.key, evicted, want)
+		}
+		if got := lruKeys(c); !reflect.DeepEqual(got, step.wantLRU) {
+			t.Errorf("Set(%s): ordre %v, attendu %v", step.key, got, step.wantLRU)
+		}
+	}
+	if stats := c.Stats(); stats.Evictions != 3 || stats.Entries != 3 {
+		t.Errorf("statistiques: %+v", stats)
+	}
+
+	// La limite en octets évince de même, autant d'entrées que nécessaire
+	c = newTestDataCache(100, 12) // 1 octet de clé + 3 de valeur par entrée
+	for _, key := range []string{"a", "b", "c"} {
+		mustSet(t, c, key, 0, now)
+	}
+	if _, evicted, _ := c.Set(DefaultTenant, "d", json.RawMessage(`"vvvvv"`), 0, now); evicted != 2 {
+		t.Errorf("entrée de 8 octets: %d évictions, attendu 2", evicted)
+	}
+	if got, want := lruKeys(c), []string{"d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ordre %v, attendu %v", got, want)
+	}
+
+	// Les entrées expirées partent avant les vivantes, même plus récentes
+	c = newTestDataCache(3, 1<<20)
+	mustSet(t, c, "a", time.Hour, now)
+	mustSet(t, c, "b", time.Second, now)
+	mustSet(t, c, "c", time.Hour, now)
+	if evicted := mustSet(t, c, "d", time.Hour, now.Add(time.Second)); evicted != 0 {
+		t.Errorf("%d évictions alors qu'une entrée a expiré", evicted)
+	}
+	if got, want := lruKeys(c), []string{"d", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ordre %v, attendu %v", got, want)
+	}
+	if stats := c.Stats(); stats.Expirations != 1 || stats.Evictions != 0 {
+		t.Errorf("statistiques: %+v", stats)
+	}
+}
+
+func TestDataCacheTTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestDataCache(10, 1<<20)
+
+	entry, _, err := c.Set(DefaultTenant, "k", json.RawMessage(`1`), 10*time.Second, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entry.ExpiresAt.Equal(now.Add(10 * time.Second)) {
+		t.Fatalf("expiration %s, attendu %s", entry.ExpiresAt, now.Add(10*time.Second))
+	}
+	if _, ok := c.Get(DefaultTenant, "k", now.Add(10*time.Second-time.Nanosecond)); !ok {
+		t.Error("entrée expirée avant son TTL")
+	}
+	// Expirée exactement au TTL, et supprimée
+	if _, ok := c.Get(DefaultTenant, "k", now.Add(10*time.Second)); ok {
+		t.Error("entrée lue à l'instant de son expiration")
+	}
+	if stats := c.Stats(); stats.Entries != 0 || stats.Expirations != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("statistiques: %+v", stats)
+	}
+
+	// TTL par défaut, et bornes
+	entry, _, _ = c.Set(DefaultTenant, "k", json.RawMessage(`1`), 0, now)
+	if got := entry.ExpiresAt.Sub(now); got != c.defaultTTL {
+		t.Errorf("TTL par défaut %s, attendu %s", got, c.defaultTTL)
+	}
+	if _, _, err := c.Set(DefaultTenant, "k", json.RawMessage(`1`), c.maxTTL+time.Second, now); err == nil {
+		t.Error("TTL supérieur à CACHE_MAX_TTL accepté")
+	}
+	if _, _, err := c.Set(DefaultTenant, "k", json.RawMessage(`1`), -time.Second, now); err == nil {
+		t.Error("TTL négatif accepté")
+	}
+
+	// Sweep et Delete suivent la même borne
+	mustSet(t, c, "a", time.Second, now)
+	mustSet(t, c, "b", 2*time.Second, now)
+	c.Sweep(now.Add(time.Second))
+	if got, want := lruKeys(c), []string{"b", "k"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("après Sweep: %v, attendu %v", got, want)
+	}
+	if c.Delete(DefaultTenant, "b", now.Add(2*time.Second)) {
+		t.Error("Delete d'une entrée expirée signalée présente")
+	}
+}
+
+func TestDataCacheHitMovesToFront(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestDataCache(3, 1<<20)
+	for _, key := range []string{"a", "b", "c"} {
+		mustSet(t, c, key, 0, now)
+	}
+
+	if _, ok := c.Get(DefaultTenant, "a", now); !ok {
+		t.Fatal("a absente")
+	}
+	if got, want := lruKeys(c), []string{"a", "c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("après lecture de a: %v, attendu %v", got, want)
+	}
+	// Un échec de lecture, y compris dans un autre namespace, ne change pas l'ordre
+	c.Get(DefaultTenant, "z", now)
+	c.Get("autre", "b", now)
+	if got, want := lruKeys(c), []string{"a", "c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("après des échecs: %v, attendu %v", got, want)
+	}
+	// La lecture a protégé a de l'éviction suivante
+	mustSet(t, c, "d", 0, now)
+	if got, want := lruKeys(c), []string{"d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("après ajout de d: %v, attendu %v", got, want)
+	}
+}
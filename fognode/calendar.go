@@ -0,0 +1,406 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CapacityWindow est un changement de capacité planifié par l'opérateur
+// (maintenance du groupe électrogène, bridage thermique...). Les parts sont
+// relatives à la capacité nominale du nœud; une part absente est inchangée.
+type CapacityWindow struct {
+	ID        string    `json:"id"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	CPU       *float64  `json:"cpu,omitempty"` // Part du CPU disponible pendant la fenêtre (0: aucun)
+	RAM       *float64  `json:"ram,omitempty"` // Part de la RAM disponible pendant la fenêtre
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// cpuShare retourne la part du CPU disponible pendant la fenêtre
+func (cw CapacityWindow) cpuShare() float64 {
+	if cw.CPU == nil {
+		return 1
+	}
+	return *cw.CPU
+}
+
+// ramShare retourne la part de la RAM disponible pendant la fenêtre
+func (cw CapacityWindow) ramShare() float64 {
+	if cw.RAM == nil {
+		return 1
+	}
+	return *cw.RAM
+}
+
+// overlaps indique si la fenêtre recouvre l'intervalle [from, to)
+func (cw CapacityWindow) overlaps(from, to time.Time) bool {
+	return cw.Start.Before(to) && cw.End.After(from)
+}
+
+// capacityCut est la capacité retirée par les fenêtres actives
+type capacityCut struct {
+	CPU float64 `json:"cpu"`
+	RAM float64 `json:"ram"`
+}
+
+// ResourceCalendar tient les fenêtres de capacité planifiées, persistées dans
+// DATA_DIR/calendar.json. Pendant CALENDAR_PREDRAIN avant une fenêtre, le
+// nœud se prépare: les tâches sans échéance proche sont reportées après la
+// fenêtre et celles qui ne tiendraient pas dans la capacité réduite sont refusées
+// (et donc déléguées).
+type ResourceCalendar struct {
+	path    string
+	lead    time.Duration
+	windows []CapacityWindow // Triées par début
+	mu      sync.Mutex
+}
+
+// NewResourceCalendar lit CALENDAR_PREDRAIN et recharge les fenêtres persistées
+func NewResourceCalendar() *ResourceCalendar {
+	c := &ResourceCalendar{
+		path: filepath.Join(getEnv("DATA_DIR", "data"), "calendar.json"),
+		lead: getEnvDuration("CALENDAR_PREDRAIN", 15*time.Minute),
+	}
+	if err := readJSONFile(c.path, &c.windows); err != nil && !os.IsNotExist(err) {
+		slog.Error("Lecture du calendrier de capacité impossible", "path", c.path, "error", err)
+	}
+	return c
+}
+
+// Add planifie une fenêtre de capacité
+func (c *ResourceCalendar) Add(cw CapacityWindow) (CapacityWindow, error) {
+	if cw.Start.IsZero() || !cw.End.After(cw.Start) {
+		return cw, errors.New("fenêtre invalide: end doit suivre start")
+	}
+	if !cw.End.After(time.Now()) {
+		return cw, errors.New("fenêtre déjà terminée")
+	}
+	for _, share := range []*float64{cw.CPU, cw.RAM} {
+		if share != nil && (*share < 0 || *share > 1) {
+			return cw, errors.New("les parts cpu et ram doivent être comprises entre 0 et 1")
+		}
+	}
+	cw.CreatedAt = time.Now()
+	cw.ID = "cw-" + strconv.FormatInt(cw.CreatedAt.UnixNano(), 10)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.windows = append(c.windows, cw)
+	sort.Slice(c.windows, func(i, j int) bool { return c.windows[i].Start.Before(c.windows[j].Start) })
+	c.saveLocked()
+	return cw, nil
+}
+
+// Remove annule une fenêtre; false si elle est inconnue
+func (c *ResourceCalendar) Remove(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, cw := range c.windows {
+		if cw.ID == id {
+			c.windows = append(c.windows[:i], c.windows[i+1:]...)
+			c.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// Prune oublie les fenêtres terminées
+func (c *ResourceCalendar) Prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.windows[:0]
+	for _, cw := range c.windows {
+		if cw.End.After(now) {
+			kept = append(kept, cw)
+		}
+	}
+	if len(kept) != len(c.windows) {
+		c.windows = kept
+		c.saveLocked()
+	}
+}
+
+// saveLocked persiste les fenêtres; c.mu doit être détenu
+func (c *ResourceCalendar) saveLocked() {
+	if err := writeJSONFile(c.path, c.windows); err != nil {
+		slog.Error("Écriture du calendrier de capacité impossible", "path", c.path, "error", err)
+	}
+}
+
+// Windows retourne les fenêtres en cours ou à venir
+func (c *ResourceCalendar) Windows(now time.Time) []CapacityWindow {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	windows := make([]CapacityWindow, 0, len(c.windows))
+	for _, cw := range c.windows {
+		if cw.End.After(now) {
+			windows = append(windows, cw)
+		}
+	}
+	return windows
+}
+
+// share retourne les parts de capacité disponibles à l'instant donné (la
+// fenêtre la plus restrictive l'emporte en cas de chevauchement)
+func (c *ResourceCalendar) share(now time.Time) (cpu, ram float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cpu, ram = 1, 1
+	for _, cw := range c.windows {
+		if cw.overlaps(now, now.Add(time.Nanosecond)) {
+			if s := cw.cpuShare(); s < cpu {
+				cpu = s
+			}
+			if s := cw.ramShare(); s < ram {
+				ram = s
+			}
+		}
+	}
+	return cpu, ram
+}
+
+// next retourne la première fenêtre en cours ou débutant avant la fin du pré-drainage
+func (c *ResourceCalendar) next(now time.Time) (CapacityWindow, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cw := range c.windows {
+		if cw.overlaps(now, now.Add(c.lead)) {
+			return cw, true
+		}
+	}
+	return CapacityWindow{}, false
+}
+
+// restrictingWindow retourne la première fenêtre annoncée qui recouvre
+// [from, to) avec moins de capacité que la tâche n'en demande
+func restrictingWindow(windows []CapacityWindow, task *Task, from, to time.Time) (CapacityWindow, bool) {
+	for _, cw := range windows {
+		if cw.overlaps(from, to) && (task.CPUCost > cw.cpuShare() || task.RAMCost > cw.ramShare()) {
+			return cw, true
+		}
+	}
+	return CapacityWindow{}, false
+}
+
+// expectedRuntime estime la durée d'exécution d'une tâche: latence estimée,
+// sinon son timeout (hypothèse pessimiste)
+func (fc *FogCompute) expectedRuntime(task *Task) time.Duration {
+	if task.EstimatedLatency > 0 {
+		return task.EstimatedLatency
+	}
+	if task.TimeoutMs > 0 {
+		return time.Duration(task.TimeoutMs) * time.Millisecond
+	}
+	return fc.defaultTimeout
+}
+
+// deferralWindow retourne la fenêtre après laquelle une tâche doit être
+// reportée: une fenêtre en cours ou proche, que la tâche ne terminerait pas
+// avant, alors que son échéance (ou son absence d'échéance) permet d'attendre
+// la fin de la fenêtre
+func (fc *FogCompute) deferralWindow(task *Task, now time.Time) (CapacityWindow, bool) {
+	cw, ok := fc.calendar.next(now)
+	if !ok {
+		return cw, false
+	}
+	runtime := fc.expectedRuntime(task)
+	if now.Add(runtime).Before(cw.Start) {
+		return cw, false
+	}
+	if task.Deadline != nil && task.Deadline.Before(cw.End.Add(runtime)) {
+		return cw, false
+	}
+	return cw, true
+}
+
+// deferLocked reporte une tâche retirée de la queue après la fenêtre qui la
+// concerne; ses ressources sont libérées d'ici là. fc.mu doit être détenu.
+func (fc *FogCompute) deferLocked(task *Task) bool {
+	cw, ok := fc.deferralWindow(task, time.Now())
+	if !ok {
+		return false
+	}
+	fc.releaseLocked(task)
+	task.Status = "deferred"
+	resume := cw.End
+	task.NextAttemptAt = &resume
+	fc.saveTaskLocked(task)
+	time.AfterFunc(time.Until(resume), func() { fc.requeueDeferred(task) })
+
+	fc.metrics.mu.Lock()
+	fc.metrics.TasksDeferred++
+	fc.metrics.mu.Unlock()
+	slog.Info("Tâche reportée après une fenêtre de capacité planifiée", "task_id", task.ID,
+		"window_id", cw.ID, "resume_at", resume.Format(time.RFC3339))
+	return true
+}
+
+// requeueDeferred remet en queue une tâche reportée dont la fenêtre est passée
+func (fc *FogCompute) requeueDeferred(task *Task) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.requeueDeferredLocked(task)
+}
+
+// requeueDeferredLocked remet en queue une tâche encore reportée; fc.mu doit être détenu
+func (fc *FogCompute) requeueDeferredLocked(task *Task) {
+	if task.Status != "deferred" {
+		return
+	}
+	task.Status = "queued"
+	task.NextAttemptAt = nil
+	fc.enqueueLocked(task)
+}
+
+// resumeDeferred remet en queue toutes les tâches reportées, après
+// l'annulation d'une fenêtre; celles qu'une autre fenêtre concerne encore
+// seront reportées de nouveau par les workers
+func (fc *FogCompute) resumeDeferred() {
+	fc.mu.RLock()
+	deferred := make([]*Task, 0)
+	for _, t := range fc.tasks {
+		if t.Status == "deferred" {
+			deferred = append(deferred, t)
+		}
+	}
+	fc.mu.RUnlock()
+
+	for _, t := range deferred {
+		fc.requeueDeferred(t)
+	}
+}
+
+// applyCalendar retire de la capacité disponible la part indisponible
+// pendant les fenêtres actives, et la restitue à leur fin
+func (fc *FogCompute) applyCalendar(now time.Time) {
+	cpu, ram := fc.calendar.share(now)
+	target := capacityCut{CPU: (1 - cpu) * fc.capacity.CPU, RAM: (1 - ram) * fc.capacity.RAM}
+
+	fc.mu.Lock()
+	previous := fc.capacityCut
+	fc.availableCPU -= target.CPU - previous.CPU
+	fc.availableRAM -= target.RAM - previous.RAM
+	fc.capacityCut = target
+	fc.mu.Unlock()
+
+	if target != previous {
+		slog.Info("Capacité planifiée appliquée", "cpu_share", cpu, "ram_share", ram)
+	}
+}
+
+// runCalendar applique les fenêtres de capacité au fil du temps
+func (fc *FogCompute) runCalendar(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			fc.calendar.Prune(now)
+			fc.applyCalendar(now)
+		}
+	}
+}
+
+// checkCalendar pré-draine le nœud avant une fenêtre de capacité réduite: une
+// tâche qui ne peut pas attendre la fin de la fenêtre et qui ne tiendrait pas
+// dans la capacité réduite est refusée, pour être déléguée à un pair
+func checkCalendar(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	now := time.Now()
+	if _, ok := fc.deferralWindow(ac.Task, now); ok {
+		return nil
+	}
+	cw, ok := restrictingWindow(fc.calendar.Windows(now), ac.Task, now, now.Add(fc.expectedRuntime(ac.Task)))
+	if !ok {
+		return nil
+	}
+	reason := fmt.Sprintf("Capacité planifiée réduite de %s à %s: CPU=%.2f, RAM=%.2f",
+		cw.Start.Format(time.RFC3339), cw.End.Format(time.RFC3339), cw.cpuShare(), cw.ramShare())
+	if cw.Reason != "" {
+		reason += " (" + cw.Reason + ")"
+	}
+	return &Rejection{
+		Reason:     reason,
+		Status:     http.StatusServiceUnavailable,
+		RetryAfter: time.Until(cw.End),
+	}
+}
+
+// CalendarStatus est la réponse de GET /calendar
+type CalendarStatus struct {
+	Windows  []CapacityWindow `json:"windows"`
+	Cut      capacityCut      `json:"active_cut"` // Capacité actuellement retirée
+	Predrain string           `json:"predrain"`
+	Deferred int              `json:"deferred_tasks"`
+}
+
+// calendarStatus décrit le calendrier et son effet courant
+func (fc *FogCompute) calendarStatus() CalendarStatus {
+	status := CalendarStatus{Windows: fc.calendar.Windows(time.Now()), Predrain: fc.calendar.lead.String()}
+
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	status.Cut = fc.capacityCut
+	for _, t := range fc.tasks {
+		if t.Status == "deferred" {
+			status.Deferred++
+		}
+	}
+	return status
+}
+
+// handleGetCalendar retourne les fenêtres de capacité planifiées
+func (fc *FogCompute) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.calendarStatus())
+}
+
+// handleAddCapacityWindow planifie une fenêtre de capacité
+func (fc *FogCompute) handleAddCapacityWindow(w http.ResponseWriter, r *http.Request) {
+	var cw CapacityWindow
+	if err := json.NewDecoder(r.Body).Decode(&cw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cw, err := fc.calendar.Add(cw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Info("Fenêtre de capacité planifiée", "window_id", cw.ID, "start", cw.Start.Format(time.RFC3339),
+		"end", cw.End.Format(time.RFC3339), "cpu_share", cw.cpuShare(), "ram_share", cw.ramShare(), "reason", cw.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cw)
+}
+
+// handleDeleteCapacityWindow annule une fenêtre de capacité
+func (fc *FogCompute) handleDeleteCapacityWindow(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !fc.calendar.Remove(id) {
+		http.Error(w, "Fenêtre de capacité non trouvée", http.StatusNotFound)
+		return
+	}
+	fc.applyCalendar(time.Now())
+	fc.resumeDeferred()
+	slog.Info("Fenêtre de capacité annulée", "window_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,425 @@
+package fognode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Termes du SmartScore, dans l'ordre des vecteurs de poids et de caractéristiques
+var scoreTerms = [...]string{"priority", "criticality", "latency", "network", "resources", "storage", "energy"}
+
+// scoreVector associe une valeur à chaque terme du SmartScore
+type scoreVector [len(scoreTerms)]float64
+
+// defaultScoreWeights sont les poids historiques du SmartScore
+var defaultScoreWeights = scoreVector{1, 10, 0.1, 0.05, 5, 0.001, 2}
+
+// scoreWeights sont les poids courants du SmartScore (SCORE_WEIGHTS),
+// rechargeables: lus par currentScoreWeights
+var scoreWeights = defaultScoreWeights
+
+// dot retourne le produit scalaire de deux vecteurs
+func (v scoreVector) dot(o scoreVector) float64 {
+	sum := 0.0
+	for i := range v {
+		sum += v[i] * o[i]
+	}
+	return sum
+}
+
+// named retourne le vecteur indexé par nom de terme
+func (v scoreVector) named() map[string]float64 {
+	out := make(map[string]float64, len(v))
+	for i, name := range scoreTerms {
+		out[name] = v[i]
+	}
+	return out
+}
+
+// env formate le vecteur pour SCORE_WEIGHTS
+func (v scoreVector) env() string {
+	parts := make([]string, len(v))
+	for i, name := range scoreTerms {
+		parts[i] = name + "=" + strconv.FormatFloat(v[i], 'g', 4, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// loadScoreWeights lit SCORE_WEIGHTS ("criticality=8,latency=0.2"); les termes
+// absents gardent leur poids historique
+func loadScoreWeights() scoreVector {
+	w := defaultScoreWeights
+	for _, kv := range getEnvList("SCORE_WEIGHTS") {
+		name, value, _ := strings.Cut(kv, "=")
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		i := termIndex(strings.TrimSpace(name))
+		if err != nil || i < 0 {
+			slog.Warn("Poids SCORE_WEIGHTS invalide ignoré", "value", kv)
+			continue
+		}
+		w[i] = f
+	}
+	return w
+}
+
+// termIndex retourne la position d'un terme, ou -1
+func termIndex(name string) int {
+	for i, t := range scoreTerms {
+		if t == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// calibrationSample est le devenir d'une tâche terminée, rapproché de son score
+type calibrationSample struct {
+	features scoreVector
+	score    float64
+	wait     time.Duration  // Attente en queue avant la dernière exécution
+	latency  time.Duration  // Soumission → fin d'exécution
+	slack    *time.Duration // Marge accordée par l'échéance à la soumission
+	missed   bool
+}
+
+// Calibration conserve les derniers couples (score, latence, échéance) et
+// évalue si les poids du SmartScore prédisent bien l'urgence des tâches
+type Calibration struct {
+	samples    []calibrationSample
+	next       int
+	max        int
+	minSamples int
+	mu         sync.Mutex
+}
+
+// NewCalibration crée l'historique (CALIBRATION_SAMPLES, CALIBRATION_MIN_SAMPLES)
+func NewCalibration() *Calibration {
+	return &Calibration{
+		max:        getEnvInt("CALIBRATION_SAMPLES", 2000),
+		minSamples: getEnvInt("CALIBRATION_MIN_SAMPLES", 30),
+	}
+}
+
+// Record enregistre le devenir d'une tâche terminée
+func (c *Calibration) Record(task *Task, started, completed time.Time) {
+	if c.max <= 0 {
+		return
+	}
+	s := calibrationSample{
+		features: task.scoreFeatures(),
+		score:    task.SmartScore,
+		wait:     started.Sub(task.queuedAt),
+		latency:  completed.Sub(task.SubmittedAt),
+	}
+	if task.Deadline != nil {
+		slack := task.Deadline.Sub(task.SubmittedAt)
+		s.slack = &slack
+		s.missed = completed.After(*task.Deadline)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.samples) < c.max {
+		c.samples = append(c.samples, s)
+		return
+	}
+	c.samples[c.next] = s
+	c.next = (c.next + 1) % c.max
+}
+
+// ScoreBand résume le devenir des tâches d'une tranche de scores
+type ScoreBand struct {
+	MinScore         float64 `json:"min_score"`
+	MaxScore         float64 `json:"max_score"`
+	Tasks            int     `json:"tasks"`
+	WithDeadline     int     `json:"with_deadline"`
+	DeadlineMissRate float64 `json:"deadline_miss_rate"`
+	AvgWaitMs        float64 `json:"avg_wait_ms"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// WeightSuggestion propose des poids ajustés sur l'historique
+type WeightSuggestion struct {
+	Weights            map[string]float64 `json:"weights"`
+	Ratios             map[string]float64 `json:"ratios"`              // Poids suggéré / poids courant
+	UrgencyCorrelation float64            `json:"urgency_correlation"` // Corrélation obtenue avec les poids suggérés
+	Env                string             `json:"env"`                 // Valeur de SCORE_WEIGHTS à appliquer
+}
+
+// CalibrationReport évalue les poids courants du SmartScore
+type CalibrationReport struct {
+	Samples      int                `json:"samples"`
+	WithDeadline int                `json:"with_deadline"`
+	Weights      map[string]float64 `json:"weights"`
+	// Corrélation de rang entre score et marge d'échéance: proche de 1, les
+	// tâches les plus urgentes reçoivent les scores les plus bas
+	UrgencyCorrelation *float64 `json:"urgency_correlation,omitempty"`
+	// Corrélation de rang entre score et attente en queue: proche de 1, le score
+	// détermine effectivement l'ordre d'exécution
+	WaitCorrelation *float64          `json:"wait_correlation,omitempty"`
+	Bands           []ScoreBand       `json:"score_quartiles"`
+	Suggestion      *WeightSuggestion `json:"suggestion,omitempty"`
+	Note            string            `json:"note,omitempty"`
+}
+
+// Report évalue l'historique avec les poids donnés
+func (c *Calibration) Report(weights scoreVector) CalibrationReport {
+	c.mu.Lock()
+	samples := make([]calibrationSample, len(c.samples))
+	copy(samples, c.samples)
+	c.mu.Unlock()
+
+	report := CalibrationReport{Samples: len(samples), Weights: weights.named(), Bands: make([]ScoreBand, 0)}
+	if len(samples) == 0 {
+		report.Note = "aucune tâche terminée"
+		return report
+	}
+
+	scores := make([]float64, len(samples))
+	waits := make([]float64, len(samples))
+	for i, s := range samples {
+		scores[i] = s.score
+		waits[i] = s.wait.Seconds()
+	}
+	if r, ok := spearman(scores, waits); ok {
+		report.WaitCorrelation = &r
+	}
+	report.Bands = scoreBands(samples)
+
+	// L'urgence n'est connue que pour les tâches à échéance
+	urgent := make([]calibrationSample, 0, len(samples))
+	for _, s := range samples {
+		if s.slack != nil {
+			urgent = append(urgent, s)
+		}
+	}
+	report.WithDeadline = len(urgent)
+	if len(urgent) < c.minSamples {
+		report.Note = fmt.Sprintf("%d tâches à échéance sur %d requises pour évaluer l'urgence", len(urgent), c.minSamples)
+		return report
+	}
+
+	slacks := make([]float64, len(urgent))
+	urgentScores := make([]float64, len(urgent))
+	for i, s := range urgent {
+		slacks[i] = s.slack.Seconds()
+		urgentScores[i] = weights.dot(s.features)
+	}
+	if r, ok := spearman(urgentScores, slacks); ok {
+		report.UrgencyCorrelation = &r
+	}
+	report.Suggestion = suggestWeights(urgent, slacks, weights)
+	return report
+}
+
+// scoreBands découpe les échantillons en quartiles de score
+func scoreBands(samples []calibrationSample) []ScoreBand {
+	sorted := make([]calibrationSample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].score < sorted[j].score })
+
+	bands := make([]ScoreBand, 0, 4)
+	for q := 0; q < 4; q++ {
+		lo, hi := q*len(sorted)/4, (q+1)*len(sorted)/4
+		if lo == hi {
+			continue
+		}
+		band := ScoreBand{MinScore: sorted[lo].score, MaxScore: sorted[hi-1].score, Tasks: hi - lo}
+		missed := 0
+		var wait, latency time.Duration
+		for _, s := range sorted[lo:hi] {
+			wait += s.wait
+			latency += s.latency
+			if s.slack != nil {
+				band.WithDeadline++
+				if s.missed {
+					missed++
+				}
+			}
+		}
+		if band.WithDeadline > 0 {
+			band.DeadlineMissRate = float64(missed) / float64(band.WithDeadline)
+		}
+		band.AvgWaitMs = float64(wait.Milliseconds()) / float64(band.Tasks)
+		band.AvgLatencyMs = float64(latency.Milliseconds()) / float64(band.Tasks)
+		bands = append(bands, band)
+	}
+	return bands
+}
+
+// suggestWeights ajuste par régression ridge la marge d'échéance sur les termes
+// du score: un terme associé à des tâches plus urgentes doit davantage faire
+// baisser le score. Les poids obtenus sont remis à l'échelle des poids courants
+// pour que l'étendue des scores reste comparable.
+func suggestWeights(samples []calibrationSample, target []float64, current scoreVector) *WeightSuggestion {
+	const p = len(scoreTerms)
+	n := float64(len(samples))
+
+	var mean, std scoreVector
+	for _, s := range samples {
+		for j := 0; j < p; j++ {
+			mean[j] += s.features[j] / n
+		}
+	}
+	for _, s := range samples {
+		for j := 0; j < p; j++ {
+			d := s.features[j] - mean[j]
+			std[j] += d * d / n
+		}
+	}
+	for j := range std {
+		std[j] = math.Sqrt(std[j])
+	}
+	yMean := 0.0
+	for _, y := range target {
+		yMean += y / n
+	}
+
+	// Équations normales sur les termes standardisés; un terme constant n'apporte
+	// aucune information et garde son poids courant
+	var a [p][p + 1]float64
+	for i, s := range samples {
+		var z scoreVector
+		for j := 0; j < p; j++ {
+			if std[j] > 0 {
+				z[j] = (s.features[j] - mean[j]) / std[j]
+			}
+		}
+		for j := 0; j < p; j++ {
+			for k := 0; k < p; k++ {
+				a[j][k] += z[j] * z[k]
+			}
+			a[j][p] += z[j] * (target[i] - yMean)
+		}
+	}
+	for j := 0; j < p; j++ {
+		a[j][j] += 1e-3 * n // Régularisation: termes corrélés entre eux
+	}
+	beta, ok := solve(a)
+	if !ok {
+		return nil
+	}
+
+	var fitted scoreVector
+	currentSpread, fittedSpread := 0.0, 0.0
+	for j := 0; j < p; j++ {
+		if std[j] == 0 {
+			continue
+		}
+		fitted[j] = beta[j] / std[j]
+		currentSpread += math.Abs(current[j]) * std[j]
+		fittedSpread += math.Abs(fitted[j]) * std[j]
+	}
+	if fittedSpread == 0 {
+		return nil
+	}
+	scale := currentSpread / fittedSpread
+
+	suggested := current
+	ratios := make(map[string]float64, p)
+	for j := 0; j < p; j++ {
+		if std[j] > 0 {
+			suggested[j] = fitted[j] * scale
+		}
+		if current[j] != 0 {
+			ratios[scoreTerms[j]] = suggested[j] / current[j]
+		}
+	}
+
+	scores := make([]float64, len(samples))
+	for i, s := range samples {
+		scores[i] = suggested.dot(s.features)
+	}
+	r, _ := spearman(scores, target)
+	return &WeightSuggestion{Weights: suggested.named(), Ratios: ratios, UrgencyCorrelation: r, Env: suggested.env()}
+}
+
+// solve résout un système linéaire par élimination de Gauss avec pivot partiel
+func solve(a [len(scoreTerms)][len(scoreTerms) + 1]float64) (scoreVector, bool) {
+	const p = len(scoreTerms)
+	var x scoreVector
+	for col := 0; col < p; col++ {
+		pivot := col
+		for r := col + 1; r < p; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return x, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := col + 1; r < p; r++ {
+			f := a[r][col] / a[col][col]
+			for k := col; k <= p; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+		}
+	}
+	for r := p - 1; r >= 0; r-- {
+		sum := a[r][p]
+		for k := r + 1; k < p; k++ {
+			sum -= a[r][k] * x[k]
+		}
+		x[r] = sum / a[r][r]
+	}
+	return x, true
+}
+
+// ranks retourne le rang de chaque valeur, les ex-aequo recevant leur rang moyen
+func ranks(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return values[idx[i]] < values[idx[j]] })
+
+	out := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		avg := float64(i+j) / 2
+		for k := i; k <= j; k++ {
+			out[idx[k]] = avg
+		}
+		i = j + 1
+	}
+	return out
+}
+
+// spearman retourne la corrélation de rang de deux séries; false si l'une est constante
+func spearman(x, y []float64) (float64, bool) {
+	if len(x) < 2 || len(x) != len(y) {
+		return 0, false
+	}
+	rx, ry := ranks(x), ranks(y)
+	n := float64(len(x))
+	mx, my := (n-1)/2, (n-1)/2
+	var cov, vx, vy float64
+	for i := range rx {
+		cov += (rx[i] - mx) * (ry[i] - my)
+		vx += (rx[i] - mx) * (rx[i] - mx)
+		vy += (ry[i] - my) * (ry[i] - my)
+	}
+	if vx == 0 || vy == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(vx*vy), true
+}
+
+// handleGetCalibration évalue la capacité des poids courants du SmartScore à
+// prédire l'urgence des tâches et propose des poids ajustés
+func (fc *FogCompute) handleGetCalibration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.calibration.Report(currentScoreWeights()))
+}
@@ -0,0 +1,265 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Événements notifiés aux callbacks des tâches
+const (
+	CallbackCompleted = "completed"
+	CallbackFailed    = "failed"
+	CallbackRejected  = "rejected"
+
+	CallbackGroupCompleted = "group_completed" // Toutes les tâches d'un groupe sont terminées
+)
+
+// En-têtes des notifications de callback
+const (
+	CallbackEventHeader     = "X-Fog-Event"
+	CallbackSignatureHeader = "X-Fog-Signature" // sha256=<HMAC-SHA256 du corps avec CALLBACK_SECRET>
+	CallbackAttemptHeader   = "X-Fog-Delivery-Attempt"
+)
+
+// TaskCallback est le corps POSTé au callback_url d'une tâche terminée,
+// en échec ou rejetée
+type TaskCallback struct {
+	Event  string    `json:"event"`
+	NodeID string    `json:"node_id"`
+	Reason string    `json:"reason,omitempty"` // Raison du rejet
+	SentAt time.Time `json:"sent_at"`
+	Task   Task      `json:"task"`
+}
+
+// CallbackStats décrit l'activité des notifications de callback
+type CallbackStats struct {
+	Pending   int    `json:"pending"`   // Notifications en attente (y compris entre deux essais)
+	Delivered int    `json:"delivered"` // Notifications acceptées (2xx)
+	Retries   int    `json:"retries"`
+	Failed    int    `json:"failed"`  // Abandonnées après le dernier essai ou refus définitif
+	Dropped   int    `json:"dropped"` // Perdues faute de place dans la file
+	LastError string `json:"last_error,omitempty"`
+}
+
+// callbackDelivery est une notification à livrer
+type callbackDelivery struct {
+	url     string
+	body    []byte
+	event   string
+	taskID  string
+	attempt int
+}
+
+// Callbacks livre les notifications de fin de tâche aux callback_url, avec
+// réessais à délai croissant. Les livraisons en attente ne survivent pas à un
+// redémarrage du nœud.
+type Callbacks struct {
+	allowedHosts []string // Hôtes autorisés (vide: tous)
+	secret       []byte
+	maxAttempts  int
+	backoff      time.Duration
+	workers      int
+	queue        chan callbackDelivery
+	client       *http.Client
+
+	pending   int
+	delivered int
+	retries   int
+	failed    int
+	dropped   int
+	lastError string
+	mu        sync.Mutex
+}
+
+// NewCallbacks lit CALLBACK_ALLOWED_HOSTS, CALLBACK_SECRET,
+// CALLBACK_MAX_ATTEMPTS, CALLBACK_BACKOFF, CALLBACK_TIMEOUT, CALLBACK_WORKERS
+// et CALLBACK_QUEUE_SIZE
+func NewCallbacks() *Callbacks {
+	workers := getEnvInt("CALLBACK_WORKERS", 4)
+	if workers < 1 {
+		workers = 1
+	}
+	return &Callbacks{
+		allowedHosts: getEnvList("CALLBACK_ALLOWED_HOSTS"),
+		secret:       []byte(getEnv("CALLBACK_SECRET", "")),
+		maxAttempts:  getEnvInt("CALLBACK_MAX_ATTEMPTS", 5),
+		backoff:      getEnvDuration("CALLBACK_BACKOFF", time.Second),
+		workers:      workers,
+		queue:        make(chan callbackDelivery, getEnvInt("CALLBACK_QUEUE_SIZE", 1000)),
+		client:       &http.Client{Timeout: getEnvDuration("CALLBACK_TIMEOUT", 5*time.Second)},
+	}
+}
+
+// Validate vérifie le callback_url d'une tâche soumise
+func (c *Callbacks) Validate(raw string) *Rejection {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &Rejection{Reason: fmt.Sprintf("callback_url invalide: %q", raw), Status: http.StatusBadRequest}
+	}
+	if len(c.allowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range c.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return nil
+		}
+	}
+	return &Rejection{Reason: fmt.Sprintf("Hôte de callback non autorisé: %s", host), Status: http.StatusBadRequest}
+}
+
+// Notify programme la notification d'une tâche munie d'un callback_url
+func (c *Callbacks) Notify(event, nodeID, reason string, task Task) {
+	if task.CallbackURL == "" {
+		return
+	}
+	body, err := json.Marshal(TaskCallback{Event: event, NodeID: nodeID, Reason: reason, SentAt: time.Now(), Task: task})
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.pending++
+	c.mu.Unlock()
+	c.enqueue(callbackDelivery{url: task.CallbackURL, body: body, event: event, taskID: task.ID, attempt: 1})
+}
+
+// NotifyGroup programme la notification de la fin d'un groupe de tâches
+func (c *Callbacks) NotifyGroup(url string, gc GroupCallback) {
+	body, err := json.Marshal(gc)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.pending++
+	c.mu.Unlock()
+	c.enqueue(callbackDelivery{url: url, body: body, event: gc.Event, taskID: "group:" + gc.Group.ID, attempt: 1})
+}
+
+// enqueue place une livraison dans la file, sans jamais bloquer l'appelant
+func (c *Callbacks) enqueue(d callbackDelivery) {
+	select {
+	case c.queue <- d:
+	default:
+		c.mu.Lock()
+		c.pending--
+		c.dropped++
+		c.mu.Unlock()
+		slog.Warn("File des callbacks pleine: notification perdue", "task_id", d.taskID, "event", d.event)
+	}
+}
+
+// Run démarre les livreurs de notifications jusqu'à l'arrêt du nœud
+func (c *Callbacks) Run(ctx context.Context) {
+	for i := 0; i < c.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-c.queue:
+					c.deliver(ctx, d)
+				}
+			}
+		}()
+	}
+}
+
+// deliver tente une livraison et planifie le réessai suivant en cas d'échec
+// temporaire (erreur réseau, 408, 429 ou 5xx)
+func (c *Callbacks) deliver(ctx context.Context, d callbackDelivery) {
+	status, err := c.post(ctx, d)
+	if err == nil {
+		c.mu.Lock()
+		c.pending--
+		c.delivered++
+		c.mu.Unlock()
+		return
+	}
+
+	retryable := status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+	if !retryable || d.attempt >= c.maxAttempts {
+		c.mu.Lock()
+		c.pending--
+		c.failed++
+		c.lastError = err.Error()
+		c.mu.Unlock()
+		slog.Warn("Notification de callback abandonnée", "task_id", d.taskID, "event", d.event,
+			"attempts", d.attempt, "error", err)
+		return
+	}
+
+	delay := c.backoff << (d.attempt - 1)
+	c.mu.Lock()
+	c.retries++
+	c.lastError = err.Error()
+	c.mu.Unlock()
+	d.attempt++
+	time.AfterFunc(delay, func() { c.enqueue(d) })
+}
+
+// post envoie la notification; retourne le statut HTTP reçu (0 sans réponse)
+func (c *Callbacks) post(ctx context.Context, d callbackDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackEventHeader, d.event)
+	req.Header.Set(CallbackAttemptHeader, strconv.Itoa(d.attempt))
+	if len(c.secret) > 0 {
+		mac := hmac.New(sha256.New, c.secret)
+		mac.Write(d.body)
+		req.Header.Set(CallbackSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("statut %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Stats retourne les compteurs des notifications de callback
+func (c *Callbacks) Stats() CallbackStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CallbackStats{
+		Pending:   c.pending,
+		Delivered: c.delivered,
+		Retries:   c.retries,
+		Failed:    c.failed,
+		Dropped:   c.dropped,
+		LastError: c.lastError,
+	}
+}
+
+// notifyOutcome notifie le callback d'une tâche arrivée à son état final
+func (fc *FogCompute) notifyOutcome(task *Task) {
+	event := CallbackCompleted
+	if task.Status != "completed" {
+		event = CallbackFailed
+	}
+	fc.callbacks.Notify(event, fc.node.ID, "", *task)
+	fc.waiters.Done(task.ID)
+	go fc.checkGroupCompletion(task.GroupID)
+}
@@ -0,0 +1,120 @@
+package fognode
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+)
+
+// acceleratorDevices associe un fichier de périphérique à l'accélérateur qu'il révèle
+var acceleratorDevices = map[string]string{
+	"/dev/nvidia0":        "nvidia-gpu",
+	"/dev/nvhost-gpu":     "nvidia-jetson",
+	"/dev/apex_0":         "google-coral-tpu",
+	"/dev/hailo0":         "hailo-npu",
+	"/dev/dri/renderD128": "gpu-render",
+}
+
+// TaskTypeCapability décrit un type de tâche supporté par le nœud
+type TaskTypeCapability struct {
+	Type           string `json:"type"`
+	HandlerVersion string `json:"handler_version"`
+	Source         string `json:"source"` // builtin, module ou fichier du plugin
+}
+
+// Capabilities décrit l'identité du nœud et ce qu'il sait exécuter
+type Capabilities struct {
+	NodeID        string               `json:"node_id"`
+	Location      string               `json:"location"`
+	APIVersion    string               `json:"api_version"`
+	APIPrefix     string               `json:"api_prefix"` // Préfixe des routes courantes; les routes sans préfixe sont dépréciées
+	Version       string               `json:"software_version"`
+	TaskTypes     []TaskTypeCapability `json:"task_types"`
+	Accelerators  []string             `json:"accelerators"`
+	Protocols     []string             `json:"protocols"`
+	Workers       int                  `json:"workers"`
+	Reserved      int                  `json:"reserved_critical_workers"`
+	Scheduler     string               `json:"scheduler"`
+	Admission     []string             `json:"admission_policies"`
+	TaskStore     string               `json:"task_store"`
+	IDScheme      string               `json:"id_scheme"`
+	ProcessLimits ProcessLimits        `json:"process_limits"`
+	Sandbox       SandboxPolicies      `json:"sandbox"`
+	ResultLimits  ResultLimits         `json:"result_limits"`
+	OS            string               `json:"os"`
+	Arch          string               `json:"arch"`
+	NumCPU        int                  `json:"num_cpu"`
+}
+
+// detectAccelerators retourne les accélérateurs déclarés via ACCELERATORS
+// ainsi que ceux détectés à partir des périphériques présents
+func detectAccelerators() []string {
+	seen := make(map[string]bool)
+	accelerators := make([]string, 0)
+
+	for _, a := range getEnvList("ACCELERATORS") {
+		if !seen[a] {
+			seen[a] = true
+			accelerators = append(accelerators, a)
+		}
+	}
+
+	for device, name := range acceleratorDevices {
+		if _, err := os.Stat(device); err == nil && !seen[name] {
+			seen[name] = true
+			accelerators = append(accelerators, name)
+		}
+	}
+
+	sort.Strings(accelerators)
+	return accelerators
+}
+
+// capabilities construit la description des capacités du nœud
+func (fc *FogCompute) capabilities() Capabilities {
+	taskTypes := handlerCapabilities()
+
+	// Schémas des listeners ouverts (https dès que TLS_CERT_FILE est défini)
+	protocols := append([]string{"http/1.1"}, fc.transports...)
+	// Encodages des corps: JSON et ceux de la négociation de contenu
+	protocols = append(protocols, "json")
+	protocols = append(protocols, codecNames()...)
+	if fc.mqtt.Enabled() {
+		protocols = append(protocols, "mqtt/3.1.1")
+	}
+
+	fc.mu.RLock()
+	workers := fc.numWorkers
+	fc.mu.RUnlock()
+
+	return Capabilities{
+		NodeID:        fc.node.ID,
+		Location:      fc.node.Location,
+		APIVersion:    APIVersion,
+		APIPrefix:     APIPrefix,
+		Version:       Version,
+		TaskTypes:     taskTypes,
+		Accelerators:  fc.accelerators,
+		Protocols:     protocols,
+		Workers:       workers,
+		Reserved:      fc.reservedWorkers,
+		Scheduler:     fc.scheduler.Name(),
+		Admission:     fc.admission.Names(),
+		TaskStore:     fc.store.Name(),
+		IDScheme:      fc.idScheme.Name(),
+		ProcessLimits: fc.procLimits,
+		Sandbox:       fc.sandbox,
+		ResultLimits:  fc.resultLimits,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+	}
+}
+
+// handleGetCapabilities retourne l'identité et les capacités du nœud
+func (fc *FogCompute) handleGetCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.capabilities())
+}
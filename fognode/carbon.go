@@ -0,0 +1,331 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CarbonPoint est l'intensité carbone du réseau électrique à partir d'un instant
+type CarbonPoint struct {
+	At        time.Time `json:"at"`
+	Intensity float64   `json:"intensity"` // gCO2eq/kWh
+}
+
+// CarbonStats décrit l'ordonnancement bas carbone (/metrics)
+type CarbonStats struct {
+	Enabled      bool         `json:"enabled"`
+	Intensity    *float64     `json:"intensity,omitempty"` // Intensité courante, gCO2eq/kWh
+	UpdatedAt    *time.Time   `json:"updated_at,omitempty"`
+	LowWindow    *CarbonPoint `json:"low_window,omitempty"` // Meilleur créneau prévu dans CARBON_MAX_DELAY
+	Points       int          `json:"forecast_points"`
+	Shifted      int64        `json:"shifted"` // Tâches décalées vers un créneau bas carbone
+	Pending      int          `json:"pending"` // Tâches décalées pas encore exécutées
+	EmittedGrams float64      `json:"emitted_g"`
+	SavedGrams   float64      `json:"saved_g"` // Émissions évitées par les décalages (négatives si la prévision s'est trompée)
+	LastError    string       `json:"last_error,omitempty"`
+}
+
+// carbonResponse accepte les deux formes d'API courantes: point courant et
+// prévision façon Electricity Maps (carbonIntensity, datetime, forecast), ou
+// liste de créneaux façon National Grid (data[].from, data[].intensity)
+type carbonResponse struct {
+	CarbonIntensity *float64 `json:"carbonIntensity"`
+	Datetime        string   `json:"datetime"`
+	Forecast        []struct {
+		CarbonIntensity float64 `json:"carbonIntensity"`
+		Datetime        string  `json:"datetime"`
+	} `json:"forecast"`
+	Data []struct {
+		From      string `json:"from"`
+		Intensity struct {
+			Forecast *float64 `json:"forecast"`
+			Actual   *float64 `json:"actual"`
+		} `json:"intensity"`
+	} `json:"data"`
+}
+
+// points extrait les intensités de la réponse; un point sans horodatage vaut pour fetchedAt
+func (r carbonResponse) points(fetchedAt time.Time) []CarbonPoint {
+	var points []CarbonPoint
+	if r.CarbonIntensity != nil {
+		at, err := time.Parse(time.RFC3339, r.Datetime)
+		if err != nil {
+			at = fetchedAt
+		}
+		points = append(points, CarbonPoint{At: at, Intensity: *r.CarbonIntensity})
+	}
+	for _, f := range r.Forecast {
+		if at, err := time.Parse(time.RFC3339, f.Datetime); err == nil {
+			points = append(points, CarbonPoint{At: at, Intensity: f.CarbonIntensity})
+		}
+	}
+	for _, d := range r.Data {
+		intensity := d.Intensity.Actual
+		if intensity == nil {
+			intensity = d.Intensity.Forecast
+		}
+		// National Grid horodate à la minute, sans secondes
+		at, err := time.Parse("2006-01-02T15:04Z", d.From)
+		if err != nil {
+			at, err = time.Parse(time.RFC3339, d.From)
+		}
+		if intensity != nil && err == nil {
+			points = append(points, CarbonPoint{At: at, Intensity: *intensity})
+		}
+	}
+	return points
+}
+
+// CarbonMonitor relève l'intensité carbone du réseau (CARBON_API_URL, et
+// CARBON_FORECAST_URL si la prévision est servie à part) et décale les tâches
+// qui peuvent attendre (criticité < CARBON_LOW_CRITICALITY, sans échéance)
+// vers le créneau le moins carboné des CARBON_MAX_DELAY à venir, s'il réduit
+// l'intensité d'au moins CARBON_MIN_REDUCTION. Une tâche n'est décalée qu'une
+// fois; l'écart d'intensité entre le décalage et l'exécution, multiplié par
+// l'énergie de l'exécution, donne les grammes de CO2 évités.
+type CarbonMonitor struct {
+	url            string
+	forecastURL    string
+	header         string
+	token          string
+	interval       time.Duration
+	maxDelay       time.Duration
+	minReduction   float64
+	lowCriticality int
+	client         *http.Client
+
+	points    []CarbonPoint // Triés par instant
+	fetchedAt time.Time
+	lastError string
+	pending   map[string]float64 // Tâches décalées: intensité au moment du décalage
+	shifted   int64
+	emittedG  float64
+	savedG    float64
+	mu        sync.Mutex
+}
+
+// NewCarbonMonitor lit CARBON_API_URL, CARBON_FORECAST_URL,
+// CARBON_API_HEADER, CARBON_API_TOKEN, CARBON_POLL_INTERVAL,
+// CARBON_MAX_DELAY, CARBON_MIN_REDUCTION, CARBON_LOW_CRITICALITY et
+// CARBON_TIMEOUT; sans CARBON_API_URL l'ordonnancement bas carbone est désactivé
+func NewCarbonMonitor() *CarbonMonitor {
+	return &CarbonMonitor{
+		url:            getEnv("CARBON_API_URL", ""),
+		forecastURL:    getEnv("CARBON_FORECAST_URL", ""),
+		header:         getEnv("CARBON_API_HEADER", "auth-token"),
+		token:          getEnv("CARBON_API_TOKEN", ""),
+		interval:       getEnvDuration("CARBON_POLL_INTERVAL", 15*time.Minute),
+		maxDelay:       getEnvDuration("CARBON_MAX_DELAY", 6*time.Hour),
+		minReduction:   getEnvFloat("CARBON_MIN_REDUCTION", 0.2),
+		lowCriticality: getEnvInt("CARBON_LOW_CRITICALITY", 3),
+		client:         &http.Client{Timeout: getEnvDuration("CARBON_TIMEOUT", 10*time.Second)},
+		pending:        make(map[string]float64),
+	}
+}
+
+// Enabled indique si une API d'intensité carbone est configurée
+func (cm *CarbonMonitor) Enabled() bool {
+	return cm.url != ""
+}
+
+// Run relève l'intensité carbone jusqu'à l'annulation de ctx
+func (cm *CarbonMonitor) Run(ctx context.Context) {
+	if !cm.Enabled() {
+		return
+	}
+	cm.refresh(ctx)
+	ticker := time.NewTicker(cm.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cm.refresh(ctx)
+		}
+	}
+}
+
+// refresh remplace les intensités connues par celles de l'API
+func (cm *CarbonMonitor) refresh(ctx context.Context) {
+	now := time.Now()
+	points, err := cm.fetch(ctx, cm.url, now)
+	if err == nil && cm.forecastURL != "" {
+		var forecast []CarbonPoint
+		if forecast, err = cm.fetch(ctx, cm.forecastURL, now); err == nil {
+			points = append(points, forecast...)
+		}
+	}
+	if err == nil && len(points) == 0 {
+		err = fmt.Errorf("%s: aucune intensité carbone dans la réponse", cm.url)
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if err != nil {
+		if cm.lastError == "" {
+			slog.Warn("Relevé de l'intensité carbone impossible", "error", err)
+		}
+		cm.lastError = err.Error()
+		return
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].At.Before(points[j].At) })
+	cm.points, cm.fetchedAt, cm.lastError = points, now, ""
+}
+
+// fetch lit les intensités servies par une URL de l'API
+func (cm *CarbonMonitor) fetch(ctx context.Context, url string, now time.Time) ([]CarbonPoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, cm.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if cm.token != "" {
+		req.Header.Set(cm.header, cm.token)
+	}
+	resp, err := cm.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: statut %d", url, resp.StatusCode)
+	}
+	var body carbonResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return body.points(now), nil
+}
+
+// currentLocked retourne l'intensité en vigueur, false si les relevés sont
+// trop anciens ou ne couvrent pas l'instant; cm.mu doit être détenu
+func (cm *CarbonMonitor) currentLocked(now time.Time) (float64, bool) {
+	if cm.fetchedAt.IsZero() || now.Sub(cm.fetchedAt) > 3*cm.interval {
+		return 0, false
+	}
+	current, ok := 0.0, false
+	for _, p := range cm.points {
+		if p.At.After(now) {
+			break
+		}
+		current, ok = p.Intensity, true
+	}
+	return current, ok
+}
+
+// lowWindowLocked retourne le créneau prévu le moins carboné dans CARBON_MAX_DELAY; cm.mu doit être détenu
+func (cm *CarbonMonitor) lowWindowLocked(now time.Time) (CarbonPoint, bool) {
+	best, ok := CarbonPoint{}, false
+	for _, p := range cm.points {
+		if !p.At.After(now) {
+			continue
+		}
+		if p.At.After(now.Add(cm.maxDelay)) {
+			break
+		}
+		if !ok || p.Intensity < best.Intensity {
+			best, ok = p, true
+		}
+	}
+	return best, ok
+}
+
+// shift retourne le créneau où exécuter la tâche, s'il réduit assez
+// l'intensité carbone; la tâche est alors comptée comme décalée
+func (cm *CarbonMonitor) shift(taskID string, now time.Time) (CarbonPoint, float64, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if _, already := cm.pending[taskID]; already {
+		return CarbonPoint{}, 0, false
+	}
+	current, ok := cm.currentLocked(now)
+	if !ok {
+		return CarbonPoint{}, 0, false
+	}
+	low, ok := cm.lowWindowLocked(now)
+	if !ok || low.Intensity > current*(1-cm.minReduction) {
+		return CarbonPoint{}, 0, false
+	}
+	cm.pending[taskID] = current
+	cm.shifted++
+	return low, current, true
+}
+
+// Executed impute les émissions d'une exécution et, pour une tâche décalée,
+// les émissions évitées par rapport à une exécution au moment du décalage
+func (cm *CarbonMonitor) Executed(taskID string, energyWh float64) {
+	if !cm.Enabled() {
+		return
+	}
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	baseline, shifted := cm.pending[taskID]
+	delete(cm.pending, taskID)
+	current, ok := cm.currentLocked(time.Now())
+	if !ok || energyWh <= 0 {
+		return
+	}
+	cm.emittedG += current * energyWh / 1000
+	if shifted {
+		cm.savedG += (baseline - current) * energyWh / 1000
+	}
+}
+
+// Stats retourne l'état de l'ordonnancement bas carbone
+func (cm *CarbonMonitor) Stats() CarbonStats {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	now := time.Now()
+	stats := CarbonStats{
+		Enabled:      cm.Enabled(),
+		Points:       len(cm.points),
+		Shifted:      cm.shifted,
+		Pending:      len(cm.pending),
+		EmittedGrams: cm.emittedG,
+		SavedGrams:   cm.savedG,
+		LastError:    cm.lastError,
+	}
+	if current, ok := cm.currentLocked(now); ok {
+		stats.Intensity = &current
+	}
+	if !cm.fetchedAt.IsZero() {
+		fetchedAt := cm.fetchedAt
+		stats.UpdatedAt = &fetchedAt
+	}
+	if low, ok := cm.lowWindowLocked(now); ok {
+		stats.LowWindow = &low
+	}
+	return stats
+}
+
+// deferForCarbonLocked reporte une tâche qui peut attendre au créneau bas
+// carbone prévu; ses ressources sont libérées d'ici là. fc.mu doit être détenu.
+func (fc *FogCompute) deferForCarbonLocked(task *Task) bool {
+	cm := fc.carbon
+	if !cm.Enabled() || task.Deadline != nil || task.Criticality >= cm.lowCriticality {
+		return false
+	}
+	low, current, ok := cm.shift(task.ID, time.Now())
+	if !ok {
+		return false
+	}
+	fc.releaseLocked(task)
+	task.Status = "deferred"
+	resume := low.At
+	task.NextAttemptAt = &resume
+	fc.saveTaskLocked(task)
+	time.AfterFunc(time.Until(resume), func() { fc.requeueDeferred(task) })
+	slog.Info("Tâche décalée vers un créneau bas carbone", "task_id", task.ID,
+		"intensity", current, "window_intensity", low.Intensity, "resume_at", resume.Format(time.RFC3339))
+	return true
+}
@@ -0,0 +1,347 @@
+package fognode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Codec CBOR (RFC 8949) restreint au modèle de données de JSON: les corps
+// CBOR sont traduits en JSON à l'entrée et les réponses JSON en CBOR à la
+// sortie, de sorte que les handlers et les tags json des structs restent la
+// seule description des messages.
+
+// maxCodecDepth borne l'imbrication des documents binaires décodés
+const maxCodecDepth = 128
+
+var errCodecTruncated = errors.New("document tronqué")
+
+// encodeCBOR encode une valeur JSON décodée avec UseNumber (nil, bool,
+// json.Number, string, []interface{}, map[string]interface{})
+func encodeCBOR(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if n >= 0 {
+				cborHead(buf, 0, uint64(n))
+			} else {
+				cborHead(buf, 1, uint64(-1-n))
+			}
+			return nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			cborHead(buf, 0, u)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		if float64(float32(f)) == f {
+			buf.WriteByte(0xfa)
+			binary.Write(buf, binary.BigEndian, math.Float32bits(float32(f)))
+		} else {
+			buf.WriteByte(0xfb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		}
+	case string:
+		cborHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		cborHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		cborHead(buf, 5, uint64(len(v)))
+		for _, k := range sortedKeys(v) {
+			cborHead(buf, 3, uint64(len(k)))
+			buf.WriteString(k)
+			if err := encodeCBOR(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("type %T non encodable en CBOR", v)
+	}
+	return nil
+}
+
+// cborHead écrit l'en-tête d'un élément: type majeur et argument, sur le
+// moins d'octets possible
+func cborHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// sortedKeys retourne les clés d'une map dans l'ordre, pour un encodage
+// déterministe
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeCBOR décode un document CBOR en valeur sérialisable en JSON: les
+// chaînes d'octets deviennent des []byte (base64 en JSON) et les dates
+// (tag 1) des chaînes RFC 3339
+func decodeCBOR(data []byte) (interface{}, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.value(0)
+	if _, end := v.(cborBreak); end && err == nil {
+		err = errors.New("fin inattendue")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CBOR invalide à l'octet %d: %w", d.pos, err)
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("CBOR invalide: %d octets après le document", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// cborBreak marque la fin d'un élément de longueur indéfinie
+type cborBreak struct{}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCodecTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head lit l'en-tête d'un élément; indefinite signale une longueur indéfinie
+func (d *cborDecoder) head() (major, info byte, arg uint64, indefinite bool, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), false, nil
+	case info == 31:
+		return major, info, 0, true, nil
+	case info > 27:
+		return 0, 0, 0, false, fmt.Errorf("argument réservé %d", info)
+	}
+	raw, err := d.read(1 << (info - 24))
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	for _, c := range raw {
+		arg = arg<<8 | uint64(c)
+	}
+	return major, info, arg, false, nil
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCodecDepth {
+		return nil, errors.New("imbrication trop profonde")
+	}
+	major, info, arg, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite && (major < 2 || major == 6) {
+		return nil, fmt.Errorf("longueur indéfinie interdite pour le type majeur %d", major)
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("entier négatif hors limites")
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		var s []byte
+		if indefinite {
+			s, err = d.chunks(major)
+		} else {
+			s, err = d.read(arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if major == 2 {
+			return append([]byte(nil), s...), nil
+		}
+		return string(s), nil
+	case 4:
+		list := make([]interface{}, 0, min(arg, uint64(len(d.data)-d.pos)))
+		for i := uint64(0); indefinite || i < arg; i++ {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, end := item.(cborBreak); end {
+				if !indefinite {
+					return nil, errors.New("fin inattendue")
+				}
+				break
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case 5:
+		obj := make(map[string]interface{}, min(arg, uint64(len(d.data)-d.pos)/2))
+		for i := uint64(0); indefinite || i < arg; i++ {
+			key, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, end := key.(cborBreak); end {
+				if !indefinite {
+					return nil, errors.New("fin inattendue")
+				}
+				break
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("clé de map %T: seules les chaînes sont acceptées", key)
+			}
+			if obj[name], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+			if _, end := obj[name].(cborBreak); end {
+				return nil, errors.New("fin inattendue")
+			}
+		}
+		return obj, nil
+	case 6:
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, end := item.(cborBreak); end {
+			return nil, errors.New("fin inattendue")
+		}
+		if arg == 1 { // Date en secondes depuis l'époque Unix
+			switch t := item.(type) {
+			case int64:
+				return time.Unix(t, 0).UTC().Format(time.RFC3339Nano), nil
+			case float64:
+				sec, frac := math.Modf(t)
+				return time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano), nil
+			}
+		}
+		return item, nil // Autres tags: la valeur seule
+	}
+
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return finiteFloat(halfToFloat(uint16(arg)))
+	case 26:
+		return finiteFloat(float64(math.Float32frombits(uint32(arg))))
+	case 27:
+		return finiteFloat(math.Float64frombits(arg))
+	case 31:
+		return cborBreak{}, nil
+	}
+	return nil, fmt.Errorf("valeur simple %d non supportée", arg)
+}
+
+// chunks lit les morceaux d'une chaîne de longueur indéfinie
+func (d *cborDecoder) chunks(major byte) ([]byte, error) {
+	var s []byte
+	for {
+		m, _, arg, indefinite, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == 7 && indefinite {
+			return s, nil
+		}
+		if m != major || indefinite {
+			return nil, errors.New("morceau de chaîne invalide")
+		}
+		chunk, err := d.read(arg)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+// halfToFloat convertit un flottant demi-précision (IEEE 754 binary16)
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// finiteFloat refuse NaN et les infinis, que JSON ne représente pas
+func finiteFloat(f float64) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.New("flottant non fini")
+	}
+	return f, nil
+}
@@ -0,0 +1,167 @@
+package fognode
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ntpEpochOffset est le nombre de secondes entre 1900 (époque NTP) et 1970 (époque Unix)
+const ntpEpochOffset = 2208988800
+
+// ClockStatus décrit l'état de synchronisation de l'horloge locale
+type ClockStatus struct {
+	Synchronized bool      `json:"synchronized"`
+	Source       string    `json:"source"`       // "ntp", "kernel" ou "unknown"
+	SkewMs       float64   `json:"skew_ms"`      // Décalage estimé par rapport à la référence
+	EstErrorMs   float64   `json:"est_error_ms"` // Erreur estimée annoncée par la source
+	MaxErrorMs   float64   `json:"max_error_ms"` // Erreur maximale annoncée par la source
+	CheckedAt    time.Time `json:"checked_at"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// ClockMonitor surveille périodiquement la dérive de l'horloge du nœud
+type ClockMonitor struct {
+	ntpServer string
+	maxSkew   time.Duration
+	interval  time.Duration
+	status    ClockStatus
+	mu        sync.RWMutex
+}
+
+// NewClockMonitor crée un moniteur d'horloge configuré depuis l'environnement
+func NewClockMonitor() *ClockMonitor {
+	cm := &ClockMonitor{
+		ntpServer: getEnv("NTP_SERVER", ""),
+		maxSkew:   getEnvDuration("CLOCK_MAX_SKEW", 500*time.Millisecond),
+		interval:  getEnvDuration("CLOCK_CHECK_INTERVAL", time.Minute),
+	}
+	cm.check()
+	return cm
+}
+
+// Run vérifie l'horloge à intervalle régulier jusqu'à l'annulation du contexte
+func (cm *ClockMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(cm.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cm.check()
+		}
+	}
+}
+
+// Status retourne le dernier état connu de l'horloge
+func (cm *ClockMonitor) Status() ClockStatus {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.status
+}
+
+// Synchronized indique si l'horloge est considérée comme fiable
+func (cm *ClockMonitor) Synchronized() bool {
+	return cm.Status().Synchronized
+}
+
+// check met à jour l'état en interrogeant le serveur NTP configuré,
+// ou à défaut l'état de discipline du noyau
+func (cm *ClockMonitor) check() {
+	var status ClockStatus
+	if cm.ntpServer != "" {
+		status = cm.queryNTP()
+	} else {
+		status = kernelClockStatus()
+	}
+	status.CheckedAt = time.Now()
+
+	cm.mu.Lock()
+	previous := cm.status
+	cm.status = status
+	cm.mu.Unlock()
+
+	if previous.Synchronized != status.Synchronized || previous.CheckedAt.IsZero() {
+		slog.Info("État de l'horloge", "synchronized", status.Synchronized,
+			"source", status.Source, "skew_ms", status.SkewMs)
+	}
+}
+
+// queryNTP effectue une requête SNTP et estime le décalage de l'horloge locale
+func (cm *ClockMonitor) queryNTP() ClockStatus {
+	status := ClockStatus{Source: "ntp"}
+
+	addr := cm.ntpServer
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+
+	conn, err := net.DialTimeout("udp", addr, 2*time.Second)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // LI=0, VN=4, Mode=3 (client)
+
+	t1 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	resp := make([]byte, 48)
+	if _, err := conn.Read(resp); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	t4 := time.Now()
+
+	leap := resp[0] >> 6
+	stratum := resp[1]
+	if leap == 3 || stratum == 0 {
+		status.Error = fmt.Sprintf("serveur NTP non synchronisé (leap=%d, stratum=%d)", leap, stratum)
+		return status
+	}
+
+	t2 := ntpTime(resp[32:40])
+	t3 := ntpTime(resp[40:48])
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay := t4.Sub(t1) - t3.Sub(t2)
+
+	status.SkewMs = float64(offset) / float64(time.Millisecond)
+	status.MaxErrorMs = float64(delay/2) / float64(time.Millisecond)
+	status.EstErrorMs = status.MaxErrorMs
+	status.Synchronized = math.Abs(float64(offset)) <= float64(cm.maxSkew)
+	return status
+}
+
+// ntpTime convertit un horodatage NTP 64 bits en time.Time
+func ntpTime(b []byte) time.Time {
+	secs := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(secs)-ntpEpochOffset, nsec)
+}
+
+// clockHeadersMiddleware ajoute l'horodatage serveur et l'estimation de dérive à chaque réponse
+func (fc *FogCompute) clockHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := fc.clock.Status()
+		w.Header().Set("X-Server-Time", time.Now().UTC().Format(time.RFC3339Nano))
+		w.Header().Set("X-Clock-Skew-Ms", strconv.FormatFloat(status.SkewMs, 'f', 1, 64))
+		w.Header().Set("X-Clock-Synchronized", strconv.FormatBool(status.Synchronized))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package fognode
+
+import "syscall"
+
+const (
+	staUnsync = 0x0040 // STA_UNSYNC: horloge non synchronisée
+	staNano   = 0x2000 // STA_NANO: offset en nanosecondes plutôt qu'en microsecondes
+	timeError = 5      // TIME_ERROR: état retourné par adjtimex quand l'horloge n'est pas disciplinée
+)
+
+// kernelClockStatus lit l'état de discipline NTP du noyau via adjtimex
+func kernelClockStatus() ClockStatus {
+	status := ClockStatus{Source: "kernel"}
+
+	var tx syscall.Timex
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		status.Source = "unknown"
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Synchronized = state != timeError && tx.Status&staUnsync == 0
+	// Offset est le décalage restant à corriger par la discipline du noyau;
+	// Esterror et Maxerror sont des bornes d'erreur, pas un décalage
+	offsetUnit := 1000.0 // microsecondes -> millisecondes
+	if tx.Status&staNano != 0 {
+		offsetUnit = 1e6
+	}
+	status.SkewMs = float64(tx.Offset) / offsetUnit
+	status.EstErrorMs = float64(tx.Esterror) / 1000.0
+	status.MaxErrorMs = float64(tx.Maxerror) / 1000.0
+	return status
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package fognode
+
+// kernelClockStatus n'est pas disponible hors Linux: l'état reste inconnu
+// tant qu'aucun serveur NTP n'est configuré
+func kernelClockStatus() ClockStatus {
+	return ClockStatus{Source: "unknown"}
+}
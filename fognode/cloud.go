@@ -0,0 +1,172 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CloudTierStats décrit l'usage du niveau cloud de repli
+type CloudTierStats struct {
+	Enabled         bool       `json:"enabled"`
+	Offloaded       int        `json:"offloaded"`
+	Failed          int        `json:"failed"`
+	AddedLatencyMs  float64    `json:"avg_added_latency_ms"` // Aller-retour moyen de l'escalade
+	AddedLatencySum float64    `json:"-"`                    // Secondes cumulées (exposition Prometheus)
+	LastError       string     `json:"last_error,omitempty"`
+	LastOffloadedAt *time.Time `json:"last_offloaded_at,omitempty"`
+}
+
+// CloudTier est le dernier niveau de la hiérarchie edge → fog → cloud: les
+// tâches qu'aucun nœud fog ne peut accepter sont soumises à l'endpoint cloud
+type CloudTier struct {
+	url      string
+	token    string
+	user     string
+	password string
+	client   *http.Client
+
+	offloaded   int
+	failed      int
+	latencySum  time.Duration
+	lastError   string
+	lastOffload *time.Time
+	mu          sync.Mutex
+}
+
+// NewCloudTier lit CLOUD_URL et ses identifiants (CLOUD_TOKEN, ou CLOUD_USER et
+// CLOUD_PASSWORD), ainsi que CLOUD_TIMEOUT
+func NewCloudTier() *CloudTier {
+	return &CloudTier{
+		url:      getEnv("CLOUD_URL", ""),
+		token:    getEnv("CLOUD_TOKEN", ""),
+		user:     getEnv("CLOUD_USER", ""),
+		password: getEnv("CLOUD_PASSWORD", ""),
+		client:   &http.Client{Timeout: getEnvDuration("CLOUD_TIMEOUT", 10*time.Second)},
+	}
+}
+
+// Enabled indique si un endpoint cloud de repli est configuré
+func (c *CloudTier) Enabled() bool {
+	return c.url != ""
+}
+
+// Submit soumet la tâche à l'endpoint cloud et retourne sa réponse ainsi que
+// l'URL de suivi éventuelle (en-tête Location)
+func (c *CloudTier) Submit(ctx context.Context, span *Span, task *Task, nodeID string) (json.RawMessage, string, error) {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return nil, "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ForwardedByHeader, nodeID)
+	req.Header.Set(TraceParentHeader, span.Context().traceParent())
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err = fmt.Errorf("statut %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.failed++
+		c.lastError = err.Error()
+		c.mu.Unlock()
+		return nil, "", err
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	elapsed := time.Since(start)
+
+	now := time.Now()
+	c.mu.Lock()
+	c.offloaded++
+	c.latencySum += elapsed
+	c.lastOffload = &now
+	c.mu.Unlock()
+
+	if !json.Valid(raw) {
+		raw = nil
+	}
+	return raw, resp.Header.Get("Location"), nil
+}
+
+// Stats retourne les compteurs du niveau cloud
+func (c *CloudTier) Stats() CloudTierStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CloudTierStats{
+		Enabled:         c.Enabled(),
+		Offloaded:       c.offloaded,
+		Failed:          c.failed,
+		AddedLatencySum: c.latencySum.Seconds(),
+		LastError:       c.lastError,
+		LastOffloadedAt: c.lastOffload,
+	}
+	if c.offloaded > 0 {
+		stats.AddedLatencyMs = float64(c.latencySum.Milliseconds()) / float64(c.offloaded)
+	}
+	return stats
+}
+
+// CloudOffloadedTask est la réponse au client dont la tâche a été escaladée au cloud
+type CloudOffloadedTask struct {
+	Task
+	OffloadedTo string          `json:"offloaded_to"`
+	TaskURL     string          `json:"task_url,omitempty"`
+	Cloud       json.RawMessage `json:"cloud_response,omitempty"`
+}
+
+// escalateToCloud soumet au cloud une tâche refusée pour surcharge qu'aucun
+// pair n'a pu accepter. Retourne false si la tâche doit être rejetée localement.
+func (fc *FogCompute) escalateToCloud(w http.ResponseWriter, r *http.Request, span *Span, task *Task, rej *Rejection) bool {
+	if !fc.cloud.Enabled() || !fc.offload.Policies[rej.Policy] {
+		return false
+	}
+	// Lien montant coupé: le cloud n'est pas joignable, inutile d'attendre CLOUD_TIMEOUT
+	if fc.uplink.Offline() {
+		return false
+	}
+	// Comme pour les pairs, le nœud d'origine d'une tâche déléguée décide seul de l'escalade
+	if n := len(task.Provenance); n > 0 && task.Provenance[n-1].Source == SourcePeer {
+		return false
+	}
+
+	response, taskURL, err := fc.cloud.Submit(r.Context(), span, task, fc.node.ID)
+	if err != nil {
+		slog.Warn("Escalade cloud impossible", "task_id", task.ID, "error", err)
+		return false
+	}
+
+	task.Status = "offloaded_cloud"
+	span.SetAttr("offload.peer_id", "cloud")
+	slog.Info("Tâche escaladée au cloud", "task_id", task.ID, "reason", rej.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	if taskURL != "" {
+		w.Header().Set("Location", taskURL)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(CloudOffloadedTask{Task: *task, OffloadedTo: "cloud", TaskURL: taskURL, Cloud: response})
+	return true
+}
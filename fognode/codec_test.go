@@ -0,0 +1,220 @@
+package fognode
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeJSON décode un document JSON comme le fait la négociation de contenu
+func decodeJSON(t *testing.T, doc string) interface{} {
+	t.Helper()
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("JSON %s: %v", doc, err)
+	}
+	return v
+}
+
+// canonicalJSON normalise une valeur décodée pour la comparer à son JSON
+func canonicalJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal(%#v): %v", v, err)
+	}
+	var out bytes.Buffer
+	if err := json.Compact(&out, raw); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+var codecRoundTrips = []string{
+	`null`, `true`, `false`, `0`, `23`, `24`, `255`, `256`, `65536`, `4294967296`,
+	`-1`, `-24`, `-25`, `-129`, `-9223372036854775808`, `18446744073709551615`,
+	`1.5`, `-0.25`, `3.141592653589793`, `1e+300`,
+	`""`, `"été"`, `"` + strings.Repeat("x", 300) + `"`,
+	`[]`, `[1,"a",null,[true]]`,
+	`{}`, `{"b":1,"a":{"c":[1,2,{"d":"e"}]}}`,
+	`{"type":"edge_analytics","payload":{"values":[21.5,22,23.25]},"criticality":4}`,
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []*binaryCodec{cborCodec, msgpackCodec} {
+		for _, doc := range codecRoundTrips {
+			var buf bytes.Buffer
+			if err := codec.encode(&buf, decodeJSON(t, doc)); err != nil {
+				t.Fatalf("%s: encodage de %s: %v", codec.Name, doc, err)
+			}
+			v, err := codec.decode(buf.Bytes())
+			if err != nil {
+				t.Fatalf("%s: décodage de %s (%x): %v", codec.Name, doc, buf.Bytes(), err)
+			}
+			if got, want := canonicalJSON(t, v), canonicalJSON(t, decodeJSON(t, doc)); got != want {
+				t.Errorf("%s: %s relu %s", codec.Name, want, got)
+			}
+		}
+	}
+}
+
+func TestCBOREncodeDeterministic(t *testing.T) {
+	var buf bytes.Buffer
+	encodeCBOR(&buf, decodeJSON(t, `{"b":1,"a":[1.5,-1]}`))
+	want := []byte{0xa2, 0x61, 'a', 0x82, 0xfa, 0x3f, 0xc0, 0x00, 0x00, 0x20, 0x61, 'b', 0x01}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("CBOR %x, attendu %x", buf.Bytes(), want)
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string // JSON attendu, vide si le décodage doit échouer
+	}{
+		{"vide", nil, ""},
+		{"entier sur 2 octets tronqué", []byte{0x19, 0x01}, ""},
+		{"chaîne tronquée", []byte{0x63, 'a', 'b'}, ""},
+		{"tableau tronqué", []byte{0x82, 0x01}, ""},
+		{"map tronquée", []byte{0xa1, 0x61, 'a'}, ""},
+		{"longueur démesurée", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, ""},
+		{"octets après le document", []byte{0x01, 0x02}, ""},
+		{"break seul", []byte{0xff}, ""},
+		{"break dans un tableau défini", []byte{0x82, 0x01, 0xff}, ""},
+		{"break comme valeur de map", []byte{0xbf, 0x61, 'a', 0xff}, ""},
+		{"break dans un tag", []byte{0xc1, 0xff}, ""},
+		{"tableau indéfini", []byte{0x9f, 0x01, 0x9f, 0x02, 0xff, 0xff}, `[1,[2]]`},
+		{"tableau indéfini sans fin", []byte{0x9f, 0x01}, ""},
+		{"map indéfinie", []byte{0xbf, 0x61, 'a', 0x01, 0xff}, `{"a":1}`},
+		{"chaîne indéfinie", []byte{0x7f, 0x62, 'e', 't', 0x61, 'e', 0xff}, `"ete"`},
+		{"chaîne indéfinie, morceau d'un autre type", []byte{0x7f, 0x41, 'a', 0xff}, ""},
+		{"chaîne indéfinie imbriquée", []byte{0x7f, 0x7f, 0xff, 0xff}, ""},
+		{"entier de longueur indéfinie", []byte{0x1f}, ""},
+		{"argument réservé", []byte{0x1c}, ""},
+		{"clé entière", []byte{0xa1, 0x01, 0x02}, ""},
+		{"clé tableau", []byte{0xa1, 0x80, 0x02}, ""},
+		{"NaN demi-précision", []byte{0xf9, 0x7e, 0x00}, ""},
+		{"infini simple précision", []byte{0xfa, 0x7f, 0x80, 0x00, 0x00}, ""},
+		{"-infini double précision", []byte{0xfb, 0xff, 0xf0, 0, 0, 0, 0, 0, 0}, ""},
+		{"demi-précision", []byte{0xf9, 0x3e, 0x00}, `1.5`},
+		{"undefined", []byte{0xf7}, `null`},
+		{"valeur simple inconnue", []byte{0xf0}, ""},
+		{"entier négatif hors limites", []byte{0x3b, 0x80, 0, 0, 0, 0, 0, 0, 0}, ""},
+		{"chaîne d'octets", []byte{0x43, 0x01, 0x02, 0x03}, `"AQID"`},
+		{"date epoch", []byte{0xc1, 0x1a, 0x65, 0x53, 0xf1, 0x00}, `"2023-11-14T22:13:20Z"`},
+		{"autre tag", []byte{0xd8, 0x20, 0x61, 'u'}, `"u"`},
+	}
+	for _, tt := range tests {
+		v, err := decodeCBOR(tt.in)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: %x accepté: %#v", tt.name, tt.in, v)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: %x refusé: %v", tt.name, tt.in, err)
+		case tt.want != "" && canonicalJSON(t, v) != tt.want:
+			t.Errorf("%s: %x décodé %s, attendu %s", tt.name, tt.in, canonicalJSON(t, v), tt.want)
+		}
+	}
+}
+
+func TestDecodeMsgPack(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"vide", nil, ""},
+		{"uint16 tronqué", []byte{0xcd, 0x01}, ""},
+		{"chaîne tronquée", []byte{0xa3, 'a', 'b'}, ""},
+		{"tableau tronqué", []byte{0x92, 0x01}, ""},
+		{"map tronquée", []byte{0x81, 0xa1, 'a'}, ""},
+		{"longueur démesurée", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, ""},
+		{"binaire tronqué", []byte{0xc4, 0x05, 0x01}, ""},
+		{"octets après le document", []byte{0x01, 0x02}, ""},
+		{"code réservé", []byte{0xc1}, ""},
+		{"clé entière", []byte{0x81, 0x01, 0x02}, ""},
+		{"clé nil", []byte{0x81, 0xc0, 0x02}, ""},
+		{"NaN", []byte{0xca, 0x7f, 0xc0, 0x00, 0x00}, ""},
+		{"infini", []byte{0xcb, 0x7f, 0xf0, 0, 0, 0, 0, 0, 0}, ""},
+		{"extension inconnue", []byte{0xd4, 0x05, 0x00}, ""},
+		{"horodatage de taille invalide", []byte{0xd5, 0xff, 0x00, 0x00}, ""},
+		{"entier négatif", []byte{0xd1, 0xff, 0x7f}, `-129`},
+		{"uint64", []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, `18446744073709551615`},
+		{"binaire", []byte{0xc4, 0x03, 0x01, 0x02, 0x03}, `"AQID"`},
+		{"horodatage 32 bits", []byte{0xd6, 0xff, 0x65, 0x53, 0xf1, 0x00}, `"2023-11-14T22:13:20Z"`},
+		{"horodatage 64 bits", []byte{0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x65, 0x53, 0xf1, 0x00}, `"2023-11-14T22:13:20.000000001Z"`},
+	}
+	for _, tt := range tests {
+		v, err := decodeMsgPack(tt.in)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: %x accepté: %#v", tt.name, tt.in, v)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: %x refusé: %v", tt.name, tt.in, err)
+		case tt.want != "" && canonicalJSON(t, v) != tt.want:
+			t.Errorf("%s: %x décodé %s, attendu %s", tt.name, tt.in, canonicalJSON(t, v), tt.want)
+		}
+	}
+}
+
+func TestCodecDepthLimit(t *testing.T) {
+	nested := func(open byte, depth int) []byte {
+		doc := bytes.Repeat([]byte{open}, depth)
+		return append(doc, 0x01)
+	}
+	decoders := map[string]struct {
+		decode func([]byte) (interface{}, error)
+		array  byte
+	}{
+		"cbor":    {decodeCBOR, 0x81},
+		"msgpack": {decodeMsgPack, 0x91},
+	}
+	for name, d := range decoders {
+		if _, err := d.decode(nested(d.array, maxCodecDepth)); err != nil {
+			t.Errorf("%s: %d niveaux refusés: %v", name, maxCodecDepth, err)
+		}
+		_, err := d.decode(nested(d.array, maxCodecDepth+1))
+		if err == nil || !strings.Contains(err.Error(), "imbrication") {
+			t.Errorf("%s: %d niveaux: %v", name, maxCodecDepth+1, err)
+		}
+		// Une imbrication démesurée échoue sans épuiser la pile
+		if _, err := d.decode(nested(d.array, 1<<20)); err == nil {
+			t.Errorf("%s: imbrication démesurée acceptée", name)
+		}
+	}
+}
+
+func TestAcceptedCodec(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   *binaryCodec
+	}{
+		{"", nil},
+		{"application/json", nil},
+		{"*/*", nil},
+		{"application/cbor", cborCodec},
+		{"application/x-msgpack", msgpackCodec},
+		{"application/json, application/cbor", nil},
+		{"application/cbor, application/json", cborCodec},
+		{"application/json;q=0.5, application/msgpack", msgpackCodec},
+		{"application/cbor;q=0, application/json", nil},
+		{"application/cbor;q=abc", nil},
+		{"text/html", nil},
+	}
+	for _, tt := range tests {
+		if got := acceptedCodec(tt.accept); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("acceptedCodec(%q) = %v, attendu %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCodecNames(t *testing.T) {
+	if got, want := codecNames(), []string{"cbor", "msgpack"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("codecNames() = %v, attendu %v", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package fognode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConfigFile porte les réglages du fichier de configuration (CONFIG_FILE).
+// Chaque clé désigne une variable d'environnement: "max_load" ou, dans une
+// section, "capacity: {cpu}" deviennent MAX_LOAD et CAPACITY_CPU. Une
+// variable d'environnement définie l'emporte sur le fichier.
+//
+// Le format est déduit de l'extension: un sous-ensemble de YAML (.yaml,
+// .yml: mappings imbriqués par indentation, scalaires, listes "- x" ou
+// "[a, b]") ou de TOML (.toml: sections [a.b], "clé = valeur", tableaux sur
+// une ligne). Les listes deviennent des valeurs séparées par des virgules.
+type ConfigFile struct {
+	path   string
+	values map[string]string // Variable d'environnement → valeur
+	lines  map[string]int    // Ligne de définition, pour les messages
+	used   map[string]bool
+	errors []string // Valeurs invalides, fatales au démarrage
+	mu     sync.Mutex
+}
+
+// config est le fichier de configuration du processus; vide sans CONFIG_FILE
+var config = &ConfigFile{}
+
+// loadConfigFile lit le fichier désigné par CONFIG_FILE, s'il y en a un
+func loadConfigFile() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	c := &ConfigFile{path: path, used: make(map[string]bool)}
+	var err error
+	if c.values, c.lines, err = parseConfigFile(path); err != nil {
+		return err
+	}
+	config = c
+	return nil
+}
+
+// parseConfigFile lit un fichier de configuration selon son extension
+func parseConfigFile(path string) (map[string]string, map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var values map[string]string
+	var lines map[string]int
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		values, lines, err = parseTOMLConfig(string(data))
+	case ".yaml", ".yml":
+		values, lines, err = parseYAMLConfig(string(data))
+	default:
+		return nil, nil, fmt.Errorf("%s: extension attendue .yaml, .yml ou .toml", path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, lines, nil
+}
+
+// errNoConfigFile refuse un rechargement sans CONFIG_FILE
+var errNoConfigFile = errors.New("aucun fichier de configuration (CONFIG_FILE)")
+
+// Reload relit le fichier de configuration et efface les erreurs relevées;
+// restore rétablit les valeurs précédentes si les nouvelles sont refusées
+func (c *ConfigFile) Reload() (restore func(), err error) {
+	if c.path == "" {
+		return nil, errNoConfigFile
+	}
+	values, lines, err := parseConfigFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previousValues, previousLines := c.values, c.lines
+	c.values, c.lines, c.errors = values, lines, nil
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.values, c.lines, c.errors = previousValues, previousLines, nil
+	}, nil
+}
+
+// lookup retourne la valeur d'un réglage: la variable d'environnement, ou à
+// défaut celle du fichier; fromFile indique sa provenance
+func (c *ConfigFile) lookup(key string) (value string, fromFile bool) {
+	c.mu.Lock()
+	v, ok := c.values[key]
+	if ok {
+		c.used[key] = true // Lue, même si l'environnement l'emporte
+	}
+	c.mu.Unlock()
+	if env := os.Getenv(key); env != "" {
+		return env, false
+	}
+	return v, ok
+}
+
+// invalid signale une valeur illisible: fatale au démarrage si elle vient
+// du fichier, ignorée avec un avertissement si elle vient de l'environnement
+func (c *ConfigFile) invalid(key, value string, fromFile bool, def interface{}) {
+	if fromFile {
+		c.errorf(key, "valeur invalide %q", value)
+		return
+	}
+	slog.Warn("Valeur invalide, utilisation du défaut", "key", key, "value", value, "default", def)
+}
+
+// errorf enregistre une erreur de configuration, fatale au démarrage
+func (c *ConfigFile) errorf(key, format string, args ...interface{}) {
+	msg := key + ": " + fmt.Sprintf(format, args...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if line, ok := c.lines[key]; ok {
+		msg = fmt.Sprintf("%s:%d: %s", c.path, line, msg)
+	}
+	c.errors = append(c.errors, msg)
+}
+
+// Validate retourne les erreurs de configuration relevées depuis le démarrage
+func (c *ConfigFile) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(c.errors, "; "))
+}
+
+// WarnUnused signale les clés du fichier qu'aucun réglage n'a lues: clé mal
+// orthographiée ou option d'un mode non activé
+func (c *ConfigFile) WarnUnused() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unused := make([]string, 0)
+	for key := range c.values {
+		if !c.used[key] {
+			unused = append(unused, key)
+		}
+	}
+	if len(unused) == 0 {
+		return
+	}
+	sort.Strings(unused)
+	slog.Warn("Clés de configuration inconnues ou inutilisées", "file", c.path, "keys", unused)
+}
+
+// configKey convertit un chemin de clés du fichier en nom de variable d'environnement
+func configKey(path []string) string {
+	key := strings.Join(path, "_")
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// stripConfigComment retire un commentaire "#" hors guillemets
+func stripConfigComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// configScalar lit une valeur scalaire ou un tableau sur une ligne ("[a, b]")
+func configScalar(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "[") {
+		if !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("tableau non fermé: %s", raw)
+		}
+		items := make([]string, 0)
+		for _, item := range strings.Split(raw[1:len(raw)-1], ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := configScalar(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	}
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') {
+		if raw[len(raw)-1] != raw[0] {
+			return "", fmt.Errorf("chaîne non fermée: %s", raw)
+		}
+		if raw[0] == '"' {
+			return strconv.Unquote(raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	}
+	return raw, nil
+}
+
+// parseYAMLConfig lit le sous-ensemble de YAML des fichiers de configuration
+func parseYAMLConfig(data string) (map[string]string, map[string]int, error) {
+	type level struct {
+		indent int
+		key    string
+	}
+	values, lines := make(map[string]string), make(map[string]int)
+	var stack []level
+	listKey := "" // Clé dont les lignes "- x" suivantes forment la liste
+	for n, line := range strings.Split(data, "\n") {
+		lineNo := n + 1
+		line = strings.TrimRight(stripConfigComment(line), " \t\r")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content == "---" {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return nil, nil, fmt.Errorf("ligne %d: indentation par tabulation", lineNo)
+		}
+		indent := len(line) - len(content)
+
+		if strings.HasPrefix(content, "- ") || content == "-" {
+			if listKey == "" {
+				return nil, nil, fmt.Errorf("ligne %d: élément de liste hors d'une clé", lineNo)
+			}
+			item, err := configScalar(strings.TrimPrefix(content, "-"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("ligne %d: %w", lineNo, err)
+			}
+			if values[listKey] != "" {
+				item = values[listKey] + "," + item
+			}
+			values[listKey] = item
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		name, raw, ok := strings.Cut(content, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, nil, fmt.Errorf("ligne %d: \"clé: valeur\" attendu", lineNo)
+		}
+		path := make([]string, 0, len(stack)+1)
+		for _, l := range stack {
+			path = append(path, l.key)
+		}
+		path = append(path, strings.TrimSpace(name))
+		key := configKey(path)
+		stack = append(stack, level{indent: indent, key: strings.TrimSpace(name)})
+		listKey = ""
+		if strings.TrimSpace(raw) == "" {
+			listKey = key // Section ou liste: déterminée par les lignes suivantes
+			lines[key] = lineNo
+			continue
+		}
+		v, err := configScalar(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ligne %d: %w", lineNo, err)
+		}
+		values[key], lines[key] = v, lineNo
+	}
+	for key := range lines {
+		if _, ok := values[key]; !ok {
+			delete(lines, key) // Section sans valeur propre
+		}
+	}
+	return values, lines, nil
+}
+
+// parseTOMLConfig lit le sous-ensemble de TOML des fichiers de configuration
+func parseTOMLConfig(data string) (map[string]string, map[string]int, error) {
+	values, lines := make(map[string]string), make(map[string]int)
+	var section []string
+	for n, line := range strings.Split(data, "\n") {
+		lineNo := n + 1
+		line = strings.TrimSpace(stripConfigComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, nil, fmt.Errorf("ligne %d: section invalide %s", lineNo, line)
+			}
+			section = strings.Split(strings.TrimSpace(line[1:len(line)-1]), ".")
+			continue
+		}
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, nil, fmt.Errorf("ligne %d: \"clé = valeur\" attendu", lineNo)
+		}
+		v, err := configScalar(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ligne %d: %w", lineNo, err)
+		}
+		path := append(append([]string{}, section...), strings.Split(strings.TrimSpace(name), ".")...)
+		key := configKey(path)
+		values[key], lines[key] = v, lineNo
+	}
+	return values, lines, nil
+}
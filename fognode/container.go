@@ -0,0 +1,234 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerTaskType est le type des tâches exécutées dans un conteneur
+const containerTaskType = "container"
+
+// Codes de sortie propres au runtime (docker run): le conteneur n'a pas tourné
+const (
+	containerExitRuntimeError  = 125
+	containerExitNotExecutable = 126
+	containerExitNotFound      = 127
+)
+
+// ContainerExecutor lance les tâches container avec un runtime compatible
+// Docker (docker ou podman, qui dialoguent avec l'API du démon ou de
+// containerd). Les limites CPU et mémoire du conteneur découlent des coûts
+// déclarés par la tâche, convertis par CPUsPerUnit et MemoryMBPerUnit.
+type ContainerExecutor struct {
+	Runtime         string   // Binaire du runtime (CONTAINER_RUNTIME)
+	Images          []string // Images autorisées; un préfixe finissant par "/" ou "*" couvre un dépôt, "*" seul toutes
+	Network         string   // Réseau des conteneurs ("none": aucun accès)
+	CPUsPerUnit     float64  // Cœurs accordés pour 1.0 de coût CPU
+	MemoryMBPerUnit float64  // Mo accordés pour 1.0 de coût RAM
+	MaxOutputBytes  int      // Sortie standard conservée; au-delà l'exécution échoue
+}
+
+// containerName retient les caractères admis dans un nom de conteneur
+var containerName = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// containers est l'exécuteur du type container, nil s'il n'est pas proposé
+var containers *ContainerExecutor
+
+// loadContainerExecutor lit CONTAINER_* et enregistre le handler du type
+// container. Sans CONTAINER_IMAGES, aucune image n'est autorisée et le type
+// n'est pas proposé.
+func loadContainerExecutor() {
+	ce := &ContainerExecutor{
+		Runtime:         getEnv("CONTAINER_RUNTIME", "docker"),
+		Images:          getEnvList("CONTAINER_IMAGES"),
+		Network:         getEnv("CONTAINER_NETWORK", "none"),
+		CPUsPerUnit:     getEnvFloat("CONTAINER_CPUS_PER_UNIT", 1.0),
+		MemoryMBPerUnit: getEnvFloat("CONTAINER_MEMORY_MB_PER_UNIT", 1024),
+		MaxOutputBytes:  getEnvInt("CONTAINER_MAX_OUTPUT_KB", 1<<10) << 10,
+	}
+	if ce.CPUsPerUnit <= 0 || ce.MemoryMBPerUnit <= 0 || ce.MaxOutputBytes <= 0 {
+		config.errorf("CONTAINER_*", "les conversions et limites des conteneurs doivent être positives")
+		return
+	}
+	if len(ce.Images) == 0 {
+		return
+	}
+	if _, err := exec.LookPath(ce.Runtime); err != nil {
+		slog.Warn("Runtime de conteneurs introuvable: les tâches container échoueront", "runtime", ce.Runtime, "error", err)
+	}
+	containers = ce
+	registerHandler(containerTaskType, registeredHandler{fn: ce.run, version: "1.0.0", source: "builtin"})
+}
+
+// ContainerPayload est le payload d'une tâche container
+type ContainerPayload struct {
+	Image string            `json:"image"`
+	Args  []string          `json:"args,omitempty"`  // Commande et arguments passés après l'image
+	Env   map[string]string `json:"env,omitempty"`   // Variables d'environnement du conteneur
+	Input json.RawMessage   `json:"input,omitempty"` // Document JSON écrit sur l'entrée standard
+}
+
+// allowed indique si l'image figure dans CONTAINER_IMAGES
+func (ce *ContainerExecutor) allowed(image string) bool {
+	for _, pattern := range ce.Images {
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasSuffix(pattern, "*") || strings.HasSuffix(pattern, "/"):
+			if strings.HasPrefix(image, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case image == pattern || strings.HasPrefix(image, pattern+":") || strings.HasPrefix(image, pattern+"@"):
+			return true // Nom sans étiquette: toutes ses étiquettes et empreintes
+		}
+	}
+	return false
+}
+
+// decodeContainerPayload lit le payload d'une tâche container
+func (ce *ContainerExecutor) decodeContainerPayload(payload map[string]interface{}) (ContainerPayload, error) {
+	var p ContainerPayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("payload container invalide: %w", err)
+	}
+	if p.Image == "" || strings.HasPrefix(p.Image, "-") {
+		return p, errors.New("payload container: image requise")
+	}
+	if !ce.allowed(p.Image) {
+		return p, fmt.Errorf("image %q non autorisée (CONTAINER_IMAGES)", p.Image)
+	}
+	return p, nil
+}
+
+// validateContainerTask refuse dès la soumission une tâche container sans
+// image ou dont l'image n'est pas autorisée
+func validateContainerTask(task *Task) *Rejection {
+	if task.Type != containerTaskType {
+		return nil
+	}
+	taskHandlersMu.RLock()
+	h, ok := taskHandlers[containerTaskType]
+	taskHandlersMu.RUnlock()
+	if !ok || h.source != "builtin" || containers == nil {
+		return nil // Type non proposé, ou remplacé par un module ou un plugin
+	}
+	if _, err := containers.decodeContainerPayload(task.Payload); err != nil {
+		return &Rejection{Reason: err.Error(), Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// runArgs construit la ligne de commande "run" du conteneur d'une tentative
+func (ce *ContainerExecutor) runArgs(te TaskExecution, p ContainerPayload, name string) []string {
+	cpus := math.Max(te.Costs.CPU*ce.CPUsPerUnit, 0.01)
+	memoryMB := math.Max(math.Ceil(te.Costs.RAM*ce.MemoryMBPerUnit), 6) // Minimum accepté par Docker
+	args := []string{"run", "--rm", "-i",
+		"--name", name,
+		"--label", "fog.task_id=" + te.TaskID,
+		"--network", ce.Network,
+		"--cpus", strconv.FormatFloat(cpus, 'f', 2, 64),
+		"--memory", fmt.Sprintf("%.0fm", memoryMB),
+		"--memory-swap", fmt.Sprintf("%.0fm", memoryMB), // Pas de swap au-delà de la limite
+	}
+	if len(te.CPUs) > 0 {
+		cores := make([]string, len(te.CPUs))
+		for i, c := range te.CPUs {
+			cores[i] = strconv.Itoa(c)
+		}
+		args = append(args, "--cpuset-cpus", strings.Join(cores, ","))
+	}
+	args = append(args, te.Sandbox.ContainerSecurityOpts()...)
+	keys := make([]string, 0, len(p.Env))
+	for k := range p.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--env", k+"="+p.Env[k])
+	}
+	args = append(args, p.Image) // Une image commençant par "-" est refusée au décodage
+	return append(args, p.Args...)
+}
+
+// run exécute une tâche dans un conteneur: le document input est écrit sur
+// son entrée standard, sa sortie standard devient le résultat (décodée si
+// c'est du JSON) avec son code de sortie. Un code non nul fait échouer la
+// tâche. À l'expiration ou à la préemption, le conteneur est supprimé.
+func (ce *ContainerExecutor) run(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	p, err := ce.decodeContainerPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	te, ok := taskExecution(ctx)
+	if !ok {
+		return nil, errors.New("exécution container hors d'un worker")
+	}
+	name := containerName.ReplaceAllString(fmt.Sprintf("fog-%s-%d", te.TaskID, te.Attempt), "_")
+
+	stdout := &limitedBuffer{max: ce.MaxOutputBytes}
+	stderr := &limitedBuffer{max: 4 << 10}
+	cmd := exec.CommandContext(ctx, ce.Runtime, ce.runArgs(te, p, name)...)
+	cmd.Stdin = bytes.NewReader(p.Input)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.Cancel = func() error {
+		// Tuer le client ne suffit pas: le conteneur survivrait au démon
+		if err := exec.Command(ce.Runtime, "rm", "-f", name).Run(); err != nil {
+			slog.Warn("Suppression du conteneur impossible", "container", name, "error", err)
+		}
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode, err = exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("runtime %s: %w", ce.Runtime, err)
+	}
+	switch exitCode {
+	case 0:
+	case containerExitRuntimeError, containerExitNotExecutable, containerExitNotFound:
+		return nil, fmt.Errorf("conteneur non démarré (code %d): %s", exitCode, strings.TrimSpace(stderr.String()))
+	default:
+		return nil, fmt.Errorf("conteneur terminé avec le code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("sortie du conteneur supérieure à %d Kio (CONTAINER_MAX_OUTPUT_KB)", ce.MaxOutputBytes>>10)
+	}
+
+	result := map[string]interface{}{
+		"operation":   containerTaskType,
+		"status":      "success",
+		"image":       p.Image,
+		"exit_code":   exitCode,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"output":      decodeOutput(stdout.Bytes()),
+	}
+	if stderr.Len() > 0 {
+		result["stderr"] = stderr.String()
+	}
+	return result, nil
+}
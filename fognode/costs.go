@@ -0,0 +1,255 @@
+package fognode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// costClientIdleTTL est la durée après laquelle un client inactif est oublié
+const costClientIdleTTL = time.Hour
+
+// ResourceUsage est la consommation mesurée d'une exécution, dans les mêmes
+// unités que les coûts déclarés (fractions du nœud pour CPU/RAM, Wh pour l'énergie)
+type ResourceUsage struct {
+	CPU        float64 `json:"cpu"`
+	RAM        float64 `json:"ram"`
+	Energy     float64 `json:"energy"`
+	DurationMs int64   `json:"duration_ms"`
+}
+
+// usageProbe capture l'état du processus au début d'une exécution
+type usageProbe struct {
+	start      time.Time
+	cpu        time.Duration
+	alloc      uint64
+	concurrent int
+}
+
+// startUsageProbe démarre la mesure d'une exécution; concurrent est le nombre
+// de tâches en cours, entre lesquelles le temps CPU du processus est réparti
+func startUsageProbe(concurrent int) usageProbe {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if concurrent < 1 {
+		concurrent = 1
+	}
+	return usageProbe{start: time.Now(), cpu: processCPUTime(), alloc: ms.TotalAlloc, concurrent: concurrent}
+}
+
+// stop termine la mesure. Les handlers s'exécutent dans le processus du nœud:
+// la consommation est une approximation fondée sur le temps CPU et les
+// allocations du processus pendant la fenêtre d'exécution.
+func (p usageProbe) stop(ca *CostAccounting) ResourceUsage {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	wall := time.Since(p.start)
+
+	usage := ResourceUsage{DurationMs: wall.Milliseconds()}
+	if wall > 0 {
+		cpu := float64(processCPUTime()-p.cpu) / float64(p.concurrent)
+		usage.CPU = cpu / (float64(wall) * float64(runtime.NumCPU()))
+	}
+	usage.RAM = float64(ms.TotalAlloc-p.alloc) / float64(p.concurrent) / ca.nodeRAMBytes
+	usage.Energy = usage.CPU * ca.nodeWatts * wall.Hours()
+	return usage
+}
+
+// costStats cumule coûts déclarés et mesurés pour un type de tâche ou un client
+type costStats struct {
+	samples        int
+	underDeclared  int
+	declaredCPU    float64
+	declaredRAM    float64
+	declaredEnergy float64
+	actualCPU      float64
+	actualRAM      float64
+	actualEnergy   float64
+	lastSeen       time.Time
+}
+
+// CostReport est le rapport de précision des coûts déclarés
+type CostReport struct {
+	Samples            int     `json:"samples"`
+	DeclaredCPU        float64 `json:"declared_cpu"`
+	ActualCPU          float64 `json:"actual_cpu"`
+	DeclaredRAM        float64 `json:"declared_ram"`
+	ActualRAM          float64 `json:"actual_ram"`
+	DeclaredEnergy     float64 `json:"declared_energy"`
+	ActualEnergy       float64 `json:"actual_energy"`
+	CPUAccuracy        float64 `json:"cpu_accuracy"` // Mesuré / déclaré (1 = exact, >1 = sous-déclaré)
+	RAMAccuracy        float64 `json:"ram_accuracy"`
+	EnergyAccuracy     float64 `json:"energy_accuracy"`
+	UnderDeclaredRatio float64 `json:"under_declared_ratio"` // Part des tâches sous-déclarées
+	Flagged            bool    `json:"flagged,omitempty"`
+}
+
+// CostAccounting rapproche les coûts déclarés à la soumission de la
+// consommation mesurée, par type de tâche et par client, et signale les clients
+// qui sous-déclarent systématiquement pour passer l'admission
+type CostAccounting struct {
+	nodeRAMBytes float64 // RAM correspondant à un coût RAM de 1.0
+	nodeWatts    float64 // Puissance du nœud à pleine charge CPU
+	tolerance    float64 // Écart admis avant de compter une sous-déclaration
+	flagRatio    float64 // Part de sous-déclarations au-delà de laquelle un client est signalé
+	minSamples   int     // Échantillons requis avant de signaler un client
+
+	byType   map[string]*costStats
+	byClient map[string]*costStats
+	flagged  map[string]bool
+	mu       sync.Mutex
+}
+
+// NewCostAccounting crée le rapprochement des coûts configuré depuis l'environnement
+func NewCostAccounting() *CostAccounting {
+	return &CostAccounting{
+		nodeRAMBytes: float64(getEnvInt("COST_NODE_RAM_MB", 1024)) * 1024 * 1024,
+		nodeWatts:    getEnvFloat("COST_NODE_WATTS", 10),
+		tolerance:    getEnvFloat("COST_UNDERDECLARE_TOLERANCE", 0.5),
+		flagRatio:    getEnvFloat("COST_FLAG_RATIO", 0.5),
+		minSamples:   getEnvInt("COST_MIN_SAMPLES", 10),
+		byType:       make(map[string]*costStats),
+		byClient:     make(map[string]*costStats),
+		flagged:      make(map[string]bool),
+	}
+}
+
+// underDeclared indique si la consommation dépasse le coût déclaré au-delà de la tolérance
+func (ca *CostAccounting) underDeclared(declared, actual float64) bool {
+	return actual > declared*(1+ca.tolerance)
+}
+
+// add cumule un échantillon dans les statistiques
+func (s *costStats) add(task *Task, usage ResourceUsage, under bool, now time.Time) {
+	s.samples++
+	if under {
+		s.underDeclared++
+	}
+	s.declaredCPU += task.CPUCost
+	s.declaredRAM += task.RAMCost
+	s.declaredEnergy += task.EnergyCost
+	s.actualCPU += usage.CPU
+	s.actualRAM += usage.RAM
+	s.actualEnergy += usage.Energy
+	s.lastSeen = now
+}
+
+// Record enregistre la consommation mesurée d'une tâche terminée
+func (ca *CostAccounting) Record(task *Task, usage ResourceUsage) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	now := time.Now()
+	under := ca.underDeclared(task.CPUCost, usage.CPU) ||
+		ca.underDeclared(task.RAMCost, usage.RAM) ||
+		ca.underDeclared(task.EnergyCost, usage.Energy)
+
+	t, ok := ca.byType[task.Type]
+	if !ok {
+		t = &costStats{}
+		ca.byType[task.Type] = t
+	}
+	t.add(task, usage, under, now)
+
+	if task.shapingKey == "" {
+		return
+	}
+	c, ok := ca.byClient[task.shapingKey]
+	if !ok {
+		c = &costStats{}
+		ca.byClient[task.shapingKey] = c
+	}
+	c.add(task, usage, under, now)
+
+	flagged := ca.isFlagged(c)
+	if flagged && !ca.flagged[task.shapingKey] {
+		slog.Warn("Client signalé pour sous-déclaration des coûts", "client", task.shapingKey,
+			"under_declared", c.underDeclared, "samples", c.samples)
+	}
+	ca.flagged[task.shapingKey] = flagged
+}
+
+// isFlagged indique si un client sous-déclare systématiquement; ca.mu doit être détenu
+func (ca *CostAccounting) isFlagged(s *costStats) bool {
+	return s.samples >= ca.minSamples && float64(s.underDeclared)/float64(s.samples) >= ca.flagRatio
+}
+
+// ratio retourne mesuré/déclaré, ou 0 si rien n'a été déclaré
+func ratio(actual, declared float64) float64 {
+	if declared <= 0 {
+		return 0
+	}
+	return actual / declared
+}
+
+// report construit le rapport d'un ensemble de statistiques; ca.mu doit être détenu
+func (ca *CostAccounting) report(s *costStats) CostReport {
+	n := float64(s.samples)
+	return CostReport{
+		Samples:            s.samples,
+		DeclaredCPU:        s.declaredCPU / n,
+		ActualCPU:          s.actualCPU / n,
+		DeclaredRAM:        s.declaredRAM / n,
+		ActualRAM:          s.actualRAM / n,
+		DeclaredEnergy:     s.declaredEnergy / n,
+		ActualEnergy:       s.actualEnergy / n,
+		CPUAccuracy:        ratio(s.actualCPU, s.declaredCPU),
+		RAMAccuracy:        ratio(s.actualRAM, s.declaredRAM),
+		EnergyAccuracy:     ratio(s.actualEnergy, s.declaredEnergy),
+		UnderDeclaredRatio: float64(s.underDeclared) / n,
+		Flagged:            ca.isFlagged(s),
+	}
+}
+
+// Prune oublie les clients inactifs depuis longtemps
+func (ca *CostAccounting) Prune() {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	now := time.Now()
+	for key, s := range ca.byClient {
+		if now.Sub(s.lastSeen) > costClientIdleTTL {
+			delete(ca.byClient, key)
+			delete(ca.flagged, key)
+		}
+	}
+}
+
+// Report retourne les rapports par type, par client et la liste des clients signalés
+func (ca *CostAccounting) Report() map[string]interface{} {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	byType := make(map[string]CostReport, len(ca.byType))
+	for t, s := range ca.byType {
+		report := ca.report(s)
+		report.Flagged = false // Le signalement ne concerne que les clients
+		byType[t] = report
+	}
+	byClient := make(map[string]CostReport, len(ca.byClient))
+	flagged := make([]string, 0)
+	for key, s := range ca.byClient {
+		report := ca.report(s)
+		byClient[key] = report
+		if report.Flagged {
+			flagged = append(flagged, key)
+		}
+	}
+	sort.Strings(flagged)
+
+	return map[string]interface{}{
+		"by_type":         byType,
+		"by_client":       byClient,
+		"flagged_clients": flagged,
+	}
+}
+
+// handleGetCosts retourne les rapports de précision des coûts déclarés
+func (fc *FogCompute) handleGetCosts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.costs.Report())
+}
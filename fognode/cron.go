@@ -0,0 +1,157 @@
+package fognode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec est une expression cron à cinq champs (minute, heure, jour du
+// mois, mois, jour de la semaine), évaluée dans le fuseau du nœud. Chaque
+// champ accepte *, des valeurs, des plages (1-5), des pas (*/15, 0-30/10) et
+// des listes (1,15); le dimanche vaut 0 ou 7.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // Bit i: valeur i acceptée
+	anyDom, anyDow                bool   // Champ à *: seul l'autre champ de jour compte
+}
+
+// cronMacros sont les raccourcis acceptés à la place des cinq champs
+var cronMacros = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// parseCron analyse une expression cron
+func parseCron(expr string) (*cronSpec, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expression cron invalide %q: 5 champs attendus", expr)
+	}
+	spec := &cronSpec{anyDom: fields[2] == "*", anyDow: fields[4] == "*"}
+	bounds := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&spec.minute, 0, 59},
+		{&spec.hour, 0, 23},
+		{&spec.dom, 1, 31},
+		{&spec.month, 1, 12},
+		{&spec.dow, 0, 7},
+	}
+	for i, field := range fields {
+		bits, err := parseCronField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("expression cron invalide %q: %v", expr, err)
+		}
+		*bounds[i].bits = bits
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1 // 7 est aussi le dimanche
+	}
+	return spec, nil
+}
+
+// parseCronField analyse un champ cron borné par [min, max]
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("pas invalide %q", part)
+			}
+			rng, step = r, n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("valeur invalide %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("valeur invalide %q", part)
+				}
+			} else if step > 1 {
+				hi = max // 5/15: de 5 à la fin, par pas de 15
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("valeur hors de [%d, %d]: %q", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// dayMatches applique la règle cron des jours: si le jour du mois et le jour
+// de la semaine sont tous deux restreints, l'un ou l'autre suffit
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next retourne la première échéance strictement postérieure à after, ou
+// l'instant zéro si l'expression ne correspond à aucune date (31 février).
+// L'expression porte sur l'heure murale du fuseau de after: aux changements
+// d'heure, une heure murale sautée (printemps) échoit juste après le
+// changement, et une heure murale répétée (automne) n'échoit qu'une fois.
+func (c *cronSpec) Next(after time.Time) time.Time {
+	loc := after.Location()
+	w := wallClock(after).Truncate(time.Minute).Add(time.Minute)
+	limit := w.AddDate(5, 0, 0)
+	for w.Before(limit) {
+		if c.month&(1<<uint(w.Month())) == 0 {
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(w) {
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(w.Hour())) == 0 {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minute&(1<<uint(w.Minute())) == 0 {
+			w = w.Add(time.Minute)
+			continue
+		}
+		// Heure murale sautée: time.Date la reporte après le changement
+		t := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+		if !t.After(after) {
+			w = w.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// wallClock retourne l'heure murale de t, sans fuseau ni changement d'heure
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
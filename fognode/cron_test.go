@@ -0,0 +1,154 @@
+package fognode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{
+		"* * * * *", "*/15 * * * *", "0-30/10 8-18 * * 1-5", "5/15 * * * *",
+		"0 0 1,15 * *", "0 12 * * 7", "@daily", " @hourly ",
+	}
+	for _, expr := range valid {
+		if _, err := parseCron(expr); err != nil {
+			t.Errorf("parseCron(%q): %v", expr, err)
+		}
+	}
+	invalid := []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * 32 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *",
+		"a * * * *", "1-b * * * *", "*/x * * * *", "@never",
+	}
+	for _, expr := range invalid {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) accepté", expr)
+		}
+	}
+}
+
+func TestParseCronFields(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     []int
+	}{
+		{"*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"0-30/10", 0, 59, []int{0, 10, 20, 30}},
+		{"5/20", 0, 59, []int{5, 25, 45}},
+		{"8-11", 0, 23, []int{8, 9, 10, 11}},
+		{"1,15,31", 1, 31, []int{1, 15, 31}},
+		{"1-5,0", 0, 7, []int{0, 1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		bits, err := parseCronField(tt.field, tt.min, tt.max)
+		if err != nil {
+			t.Fatalf("parseCronField(%q): %v", tt.field, err)
+		}
+		var want uint64
+		for _, v := range tt.want {
+			want |= 1 << uint(v)
+		}
+		if bits != want {
+			t.Errorf("parseCronField(%q) = %b, attendu %b", tt.field, bits, want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Mercredi 14 octobre 2026, 10:07:30 UTC
+	from := time.Date(2026, 10, 14, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		name, expr string
+		want       time.Time
+	}{
+		{"chaque minute", "* * * * *", time.Date(2026, 10, 14, 10, 8, 0, 0, time.UTC)},
+		{"pas", "*/15 * * * *", time.Date(2026, 10, 14, 10, 15, 0, 0, time.UTC)},
+		{"plage et pas", "0-30/10 11-12 * * *", time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)},
+		{"heure passée: lendemain", "0 9 * * *", time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)},
+		{"dimanche 0", "0 0 * * 0", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"dimanche 7", "0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"jours ouvrés", "30 8 * * 1-5", time.Date(2026, 10, 15, 8, 30, 0, 0, time.UTC)},
+		{"jour du mois seul", "0 0 20 * *", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		// Jour du mois et jour de la semaine restreints: l'un ou l'autre
+		{"règle OU, jour de la semaine", "0 0 20 * 5", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{"règle OU, jour du mois", "0 0 15 * 1", time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{"mois suivant", "0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"29 février", "0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", "@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"31 février", "0 0 31 2 *", time.Time{}},
+		{"31 avril", "0 0 31 4 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := spec.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%s (%q): Next = %v, attendu %v", tt.name, tt.expr, got, tt.want)
+		}
+	}
+
+	// Une échéance exacte n'est pas retournée deux fois
+	spec, _ := parseCron("*/15 * * * *")
+	at := time.Date(2026, 10, 14, 10, 15, 0, 0, time.UTC)
+	if got := spec.Next(at); !got.Equal(at.Add(15 * time.Minute)) {
+		t.Errorf("Next(%v) = %v", at, got)
+	}
+}
+
+// cronRuns retourne les échéances successives de expr dans [from, to)
+func cronRuns(t *testing.T, expr string, from, to time.Time) []time.Time {
+	t.Helper()
+	spec, err := parseCron(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []time.Time
+	for next := spec.Next(from); !next.IsZero() && next.Before(to); next = spec.Next(next) {
+		if len(runs) > 0 && !next.After(runs[len(runs)-1]) {
+			t.Fatalf("%s: échéance %v non croissante", expr, next)
+		}
+		runs = append(runs, next)
+	}
+	return runs
+}
+
+func TestCronNextDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("base des fuseaux indisponible: %v", err)
+	}
+
+	// Printemps: le 29 mars 2026, 02:00 CET devient 03:00 CEST
+	springFrom := time.Date(2026, 3, 28, 12, 0, 0, 0, paris)
+	springTo := time.Date(2026, 3, 30, 12, 0, 0, 0, paris)
+	runs := cronRuns(t, "30 2 * * *", springFrom, springTo)
+	if len(runs) != 2 {
+		t.Fatalf("heure sautée: %v, attendu une échéance par jour", runs)
+	}
+	gap := time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC) // 03:30 CEST
+	if !runs[0].Equal(gap) || !runs[1].Equal(time.Date(2026, 3, 30, 0, 30, 0, 0, time.UTC)) {
+		t.Errorf("heure sautée: %v", runs)
+	}
+	if runs := cronRuns(t, "0 3 * * *", springFrom, springTo); len(runs) != 2 {
+		t.Errorf("03:00 le jour du changement: %v", runs)
+	}
+
+	// Automne: le 25 octobre 2026, 03:00 CEST redevient 02:00 CET
+	fallFrom := time.Date(2026, 10, 24, 12, 0, 0, 0, paris)
+	fallTo := time.Date(2026, 10, 26, 12, 0, 0, 0, paris)
+	if runs := cronRuns(t, "30 2 * * *", fallFrom, fallTo); len(runs) != 2 {
+		t.Errorf("heure répétée: %v, attendu une échéance par jour", runs)
+	}
+	// Les échéances horaires restent une par heure murale
+	runs = cronRuns(t, "0 * * * *", time.Date(2026, 10, 25, 0, 30, 0, 0, paris), time.Date(2026, 10, 25, 5, 30, 0, 0, paris))
+	if len(runs) != 5 {
+		t.Errorf("échéances horaires à l'automne: %v", runs)
+	}
+	for _, run := range runs {
+		if run.In(paris).Minute() != 0 {
+			t.Errorf("échéance %v hors de l'heure pile", run)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package fognode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FailedTask est une entrée de la file des tâches en échec (dead-letter
+// queue): admise puis en échec définitif à l'exécution, là où une tâche
+// rejetée n'a jamais été admise
+type FailedTask struct {
+	Task     Task      `json:"task"`
+	FailedAt time.Time `json:"failed_at"`
+	Error    string    `json:"error"`
+	TimedOut bool      `json:"timed_out"`
+	Attempts int       `json:"attempts"` // Tentatives consommées, réessais automatiques inclus
+}
+
+// isDeadLetter indique si une tâche est en échec définitif
+func isDeadLetter(task *Task) bool {
+	return (task.Status == "failed" || task.Status == "failed_timeout") && task.CompletedAt != nil
+}
+
+// failedTask décrit une tâche en échec définitif
+func failedTask(task *Task) FailedTask {
+	ft := FailedTask{
+		Task:     *task,
+		FailedAt: *task.CompletedAt,
+		TimedOut: task.Status == "failed_timeout",
+		Attempts: task.Attempts,
+	}
+	if result, ok := task.Result.(map[string]string); ok {
+		ft.Error = result["error"]
+	} else if result, ok := task.Result.(map[string]interface{}); ok {
+		ft.Error = fmt.Sprint(result["error"])
+	}
+	return ft
+}
+
+// failedQueueSizeLocked compte les tâches en échec définitif; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) failedQueueSizeLocked() int {
+	n := 0
+	for _, t := range fc.tasks {
+		if isDeadLetter(t) {
+			n++
+		}
+	}
+	return n
+}
+
+// handleGetFailedTasks liste les tâches en échec définitif, des plus récentes aux plus anciennes
+func (fc *FogCompute) handleGetFailedTasks(w http.ResponseWriter, r *http.Request) {
+	tenant, all := fc.requestTenant(r)
+	fc.mu.RLock()
+	failedTasks := make([]FailedTask, 0)
+	for _, t := range fc.tasks {
+		if isDeadLetter(t) && visibleTo(t, tenant, all) {
+			failedTasks = append(failedTasks, failedTask(t))
+		}
+	}
+	fc.mu.RUnlock()
+	sort.Slice(failedTasks, func(i, j int) bool { return failedTasks[i].FailedAt.After(failedTasks[j].FailedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total": len(failedTasks),
+		"tasks": failedTasks,
+	})
+}
+
+// handleGetFailedTask détaille l'échec d'une tâche: erreur, tentatives, provenance
+func (fc *FogCompute) handleGetFailedTask(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+
+	fc.mu.RLock()
+	task, ok := fc.tasks[taskID]
+	if !ok || !isDeadLetter(task) || !visibleTo(task, tenant, all) {
+		fc.mu.RUnlock()
+		http.Error(w, "Tâche en échec non trouvée", http.StatusNotFound)
+		return
+	}
+	ft := failedTask(task)
+	fc.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ft)
+}
+
+// handleRetryFailedTask remet en queue une tâche en échec définitif, avec un
+// nouveau budget de tentatives
+func (fc *FogCompute) handleRetryFailedTask(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	task, ok := fc.tasks[taskID]
+	if !ok || !isDeadLetter(task) || !visibleTo(task, tenant, all) {
+		http.Error(w, "Tâche en échec non trouvée", http.StatusNotFound)
+		return
+	}
+	if fc.draining {
+		http.Error(w, "Nœud en cours de drainage: soumissions suspendues", http.StatusServiceUnavailable)
+		return
+	}
+	if task.CPUCost > fc.availableCPU || task.RAMCost > fc.availableRAM || task.StorageCost > fc.availableStorage {
+		http.Error(w, "Ressources insuffisantes pour réessayer la tâche", http.StatusServiceUnavailable)
+		return
+	}
+	queueClass := admissionClass(task)
+	if full, reason := fc.queueClassFull(queueClass); full {
+		http.Error(w, reason, http.StatusServiceUnavailable)
+		return
+	}
+	tenantAC := &AdmissionContext{Task: task, Request: r, Tenant: fc.tenants.usageOf(tenantOf(task))}
+	if rej := checkTenant(fc, tenantAC); rej != nil {
+		rej.write(w)
+		return
+	}
+
+	failure := failedTask(task)
+	task.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceRetry, Client: r.RemoteAddr, RetryOf: taskID})
+	task.QueueClass = queueClass
+	task.Attempts = 0
+	task.Result = nil
+	task.CompletedAt = nil
+	task.NextAttemptAt = nil
+	task.SubmittedAt = time.Now()
+	task.SmartScore = task.calculateScore()
+	fc.enqueueWhenReadyLocked(task)
+	fc.journal.Record(task)
+
+	slog.Info("Réessai de la tâche en échec", "task_id", taskID, "owner", task.Owner, "client", requestClient(r),
+		"previous_attempts", failure.Attempts, "error", failure.Error)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tâche resoumise avec succès",
+		"task":    task,
+	})
+}
+
+// handlePurgeFailedTasks oublie les tâches en échec définitif, ou seulement
+// celle de ?id=
+func (fc *FogCompute) handlePurgeFailedTasks(w http.ResponseWriter, r *http.Request) {
+	only := r.URL.Query().Get("id")
+	fc.mu.Lock()
+	count := 0
+	for id, t := range fc.tasks {
+		if isDeadLetter(t) && (only == "" || id == only) {
+			delete(fc.tasks, id)
+			fc.storeErr("delete", id, fc.store.DeleteTask(id))
+			count++
+		}
+	}
+	fc.mu.Unlock()
+
+	if only != "" && count == 0 {
+		http.Error(w, "Tâche en échec non trouvée", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tâches en échec effacées",
+		"count":   count,
+	})
+}
@@ -0,0 +1,131 @@
+package fognode
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DelayedStats décrit les tâches en attente de leur execute_at (/metrics)
+type DelayedStats struct {
+	Staged      int        `json:"staged"`
+	Released    int64      `json:"released"` // Passées dans la queue à leur échéance
+	NextRelease *time.Time `json:"next_release,omitempty"`
+	MaxAhead    string     `json:"max_ahead"`
+}
+
+// DelayedTasks tient les tâches soumises avec un execute_at futur dans un
+// tas ordonné par échéance: elles n'entrent dans la queue du scheduler, et ne
+// réservent de ressources, qu'une fois éligibles. Utile pour repousser les
+// téléversements non urgents aux heures creuses.
+type DelayedTasks struct {
+	maxAhead time.Duration // Report maximal accepté (0: illimité)
+	wake     chan struct{} // Une tâche plus proche que la tête a été ajoutée
+
+	// Protégés par fc.mu
+	staged   *TaskHeap
+	released int64
+}
+
+// NewDelayedTasks lit DELAYED_MAX_AHEAD
+func NewDelayedTasks() *DelayedTasks {
+	return &DelayedTasks{
+		maxAhead: getEnvDuration("DELAYED_MAX_AHEAD", 7*24*time.Hour),
+		wake:     make(chan struct{}, 1),
+		staged:   NewTaskHeap(func(a, b *Task) bool { return a.ExecuteAt.Before(*b.ExecuteAt) }),
+	}
+}
+
+// checkExecuteAt refuse avec 422 un execute_at trop lointain ou postérieur à l'échéance
+func (d *DelayedTasks) checkExecuteAt(task *Task) *Rejection {
+	if task.ExecuteAt == nil {
+		return nil
+	}
+	if task.Deadline != nil && task.ExecuteAt.After(*task.Deadline) {
+		return &Rejection{Policy: "delayed", Reason: "execute_at postérieur à l'échéance de la tâche", Status: http.StatusUnprocessableEntity}
+	}
+	if d.maxAhead > 0 && time.Until(*task.ExecuteAt) > d.maxAhead {
+		return &Rejection{Policy: "delayed", Reason: fmt.Sprintf("execute_at au-delà de %s", d.maxAhead), Status: http.StatusUnprocessableEntity}
+	}
+	return nil
+}
+
+// stageLocked met de côté une tâche dont execute_at n'est pas atteint; false
+// si elle est éligible dès maintenant. fc.mu doit être détenu.
+func (fc *FogCompute) stageLocked(task *Task) bool {
+	if task.ExecuteAt == nil || !task.ExecuteAt.After(time.Now()) {
+		return false
+	}
+	d := fc.delayed
+	task.Status = "delayed"
+	fc.tasks[task.ID] = task
+	heap.Push(d.staged, task)
+	fc.saveTaskLocked(task)
+	if d.staged.items[0] == task {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// runDelayed fait passer dans la queue les tâches dont execute_at est atteint
+func (fc *FogCompute) runDelayed(ctx context.Context) {
+	d := fc.delayed
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		fc.mu.Lock()
+		now := time.Now()
+		for d.staged.Len() > 0 && !d.staged.items[0].ExecuteAt.After(now) {
+			task := heap.Pop(d.staged).(*Task)
+			if task.Status != "delayed" {
+				continue // Purgée ou remise en queue entre-temps
+			}
+			d.released++
+			task.Status = "queued"
+			fc.enqueueLocked(task)
+			slog.Info("Tâche différée mise en queue", "task_id", task.ID,
+				"execute_at", task.ExecuteAt.Format(time.RFC3339), "late_ms", now.Sub(*task.ExecuteAt).Milliseconds())
+		}
+		wait := time.Hour
+		if d.staged.Len() > 0 {
+			wait = d.staged.items[0].ExecuteAt.Sub(now)
+		}
+		fc.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// delayedStatsLocked retourne l'état des tâches différées; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) delayedStatsLocked() DelayedStats {
+	d := fc.delayed
+	stats := DelayedStats{Released: d.released, MaxAhead: d.maxAhead.String()}
+	for _, t := range d.staged.items {
+		if t.Status == "delayed" {
+			stats.Staged++
+		}
+	}
+	if d.staged.Len() > 0 {
+		next := *d.staged.items[0].ExecuteAt
+		stats.NextRelease = &next
+	}
+	return stats
+}
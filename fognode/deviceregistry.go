@@ -0,0 +1,258 @@
+package fognode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// deviceIDPattern borne les identifiants d'appareils enregistrés
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// DeviceInfo décrit un appareil (capteur, passerelle...); les tâches qui le
+// référencent par device_id en reçoivent une copie
+type DeviceInfo struct {
+	Type     string            `json:"type,omitempty"`     // Nature de l'appareil (thermometer, camera, gateway...)
+	Location string            `json:"location,omitempty"` // Emplacement (site, bâtiment, coordonnées...)
+	Sensors  []string          `json:"sensors,omitempty"`  // Capteurs portés, tels que nommés dans la télémétrie
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Device est un appareil du registre
+type Device struct {
+	ID string `json:"id"`
+	DeviceInfo
+	RegisteredAt time.Time  `json:"registered_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	LastSeen     *time.Time `json:"last_seen,omitempty"` // Dernière tâche soumise par l'appareil
+	Tasks        int64      `json:"tasks"`               // Tâches soumises depuis l'enregistrement
+}
+
+// DeviceRegistry tient les appareils connus du nœud, persistés dans
+// DATA_DIR/devices.json. Une tâche dont le device_id est enregistré est
+// enrichie de ses métadonnées; avec DEVICE_REGISTRY_STRICT, une tâche dont
+// le device_id est inconnu est refusée.
+type DeviceRegistry struct {
+	path    string
+	strict  bool
+	devices map[string]*Device
+	dirty   bool // last_seen modifié depuis la dernière écriture
+	mu      sync.Mutex
+}
+
+// NewDeviceRegistry lit DEVICE_REGISTRY_STRICT et recharge les appareils persistés
+func NewDeviceRegistry() *DeviceRegistry {
+	dr := &DeviceRegistry{
+		path:    filepath.Join(getEnv("DATA_DIR", "data"), "devices.json"),
+		strict:  getEnvBool("DEVICE_REGISTRY_STRICT", false),
+		devices: make(map[string]*Device),
+	}
+	var devices []*Device
+	if err := readJSONFile(dr.path, &devices); err != nil && !os.IsNotExist(err) {
+		slog.Error("Lecture du registre d'appareils impossible", "path", dr.path, "error", err)
+	}
+	for _, d := range devices {
+		dr.devices[d.ID] = d
+	}
+	return dr
+}
+
+// Register enregistre un appareil, ou remplace sa description; created
+// indique un appareil jusque-là inconnu
+func (dr *DeviceRegistry) Register(id string, info DeviceInfo) (device Device, created bool) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	now := time.Now()
+	d, ok := dr.devices[id]
+	if !ok {
+		d = &Device{ID: id, RegisteredAt: now}
+		dr.devices[id] = d
+		slog.Info("Appareil enregistré", "device_id", id, "device_type", info.Type, "location", info.Location)
+	}
+	d.DeviceInfo = info
+	d.UpdatedAt = now
+	dr.saveLocked()
+	return *d, !ok
+}
+
+// Remove retire un appareil; false s'il est inconnu
+func (dr *DeviceRegistry) Remove(id string) bool {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	if _, ok := dr.devices[id]; !ok {
+		return false
+	}
+	delete(dr.devices, id)
+	dr.saveLocked()
+	slog.Info("Appareil retiré du registre", "device_id", id)
+	return true
+}
+
+// Get retourne un appareil enregistré
+func (dr *DeviceRegistry) Get(id string) (Device, bool) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	d, ok := dr.devices[id]
+	if !ok {
+		return Device{}, false
+	}
+	return *d, true
+}
+
+// Devices retourne les appareils enregistrés, filtrés par type et
+// emplacement quand ils sont renseignés
+func (dr *DeviceRegistry) Devices(deviceType, location string) []Device {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	devices := make([]Device, 0, len(dr.devices))
+	for _, d := range dr.devices {
+		if (deviceType == "" || d.Type == deviceType) && (location == "" || d.Location == location) {
+			devices = append(devices, *d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices
+}
+
+// Resolve rattache une tâche soumise à son appareil: last_seen est mis à jour
+// et la tâche reçoit les métadonnées de l'appareil. Une tâche sans device_id
+// ou d'un appareil inconnu ne porte aucune métadonnée (celles fournies par le
+// client sont ignorées); en mode strict, l'appareil inconnu est refusé.
+func (dr *DeviceRegistry) Resolve(task *Task) *Rejection {
+	task.Device = nil
+	if task.DeviceID == "" {
+		return nil
+	}
+
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	d, ok := dr.devices[task.DeviceID]
+	if !ok {
+		if dr.strict {
+			return &Rejection{Reason: "Appareil inconnu du registre: " + task.DeviceID, Status: http.StatusBadRequest}
+		}
+		return nil
+	}
+	now := time.Now()
+	d.LastSeen = &now
+	d.Tasks++
+	dr.dirty = true
+
+	info := d.DeviceInfo
+	info.Sensors = append([]string(nil), d.Sensors...)
+	if d.Metadata != nil {
+		info.Metadata = make(map[string]string, len(d.Metadata))
+		for k, v := range d.Metadata {
+			info.Metadata[k] = v
+		}
+	}
+	task.Device = &info
+	return nil
+}
+
+// Save persiste les last_seen modifiés depuis la dernière écriture
+func (dr *DeviceRegistry) Save() {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	if dr.dirty {
+		dr.saveLocked()
+	}
+}
+
+// saveLocked persiste les appareils; dr.mu doit être détenu
+func (dr *DeviceRegistry) saveLocked() {
+	devices := make([]*Device, 0, len(dr.devices))
+	for _, d := range dr.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	if err := writeJSONFile(dr.path, devices); err != nil {
+		slog.Error("Écriture du registre d'appareils impossible", "path", dr.path, "error", err)
+		return
+	}
+	dr.dirty = false
+}
+
+// Summary retourne le nombre d'appareils enregistrés et de ceux vus depuis since
+func (dr *DeviceRegistry) Summary(since time.Time) map[string]int {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	active := 0
+	for _, d := range dr.devices {
+		if d.LastSeen != nil && d.LastSeen.After(since) {
+			active++
+		}
+	}
+	return map[string]int{"registered": len(dr.devices), "active": active}
+}
+
+// DeviceRegistration est le corps de POST /devices
+type DeviceRegistration struct {
+	ID string `json:"id"`
+	DeviceInfo
+}
+
+// handleRegisterDevice enregistre un appareil, ou met à jour sa description
+func (fc *FogCompute) handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	var reg DeviceRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !deviceIDPattern.MatchString(reg.ID) {
+		http.Error(w, "id invalide: lettres, chiffres, '.', '_', ':' ou '-' (128 caractères au plus)", http.StatusBadRequest)
+		return
+	}
+
+	device, created := fc.devices.Register(reg.ID, reg.DeviceInfo)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(device)
+}
+
+// handleGetDevices liste les appareils enregistrés (?type= et ?location= filtrent)
+func (fc *FogCompute) handleGetDevices(w http.ResponseWriter, r *http.Request) {
+	devices := fc.devices.Devices(r.URL.Query().Get("type"), r.URL.Query().Get("location"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":   len(devices),
+		"devices": devices,
+	})
+}
+
+// handleGetDevice retourne un appareil enregistré
+func (fc *FogCompute) handleGetDevice(w http.ResponseWriter, r *http.Request) {
+	device, ok := fc.devices.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Appareil non enregistré", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(device)
+}
+
+// handleDeleteDevice retire un appareil du registre
+func (fc *FogCompute) handleDeleteDevice(w http.ResponseWriter, r *http.Request) {
+	if !fc.devices.Remove(mux.Vars(r)["id"]) {
+		http.Error(w, "Appareil non enregistré", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package fognode
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// deviceIdleTTL est la durée après laquelle un appareil inactif est oublié
+const deviceIdleTTL = 10 * time.Minute
+
+// DeviceStats regroupe les compteurs de soumission d'un appareil source
+type DeviceStats struct {
+	Submitted int       `json:"submitted"`
+	Accepted  int       `json:"accepted"`
+	Shaped    int       `json:"shaped"` // Soumissions refusées par le lissage de débit
+	Queued    int       `json:"queued"` // Tâches actuellement en attente dans la queue
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// deviceBucket est l'état de lissage d'un appareil (token bucket + compteurs)
+type deviceBucket struct {
+	tokens float64
+	last   time.Time
+	stats  DeviceStats
+}
+
+// DeviceShaper applique un lissage de débit par appareil et un partage
+// équitable de la queue, pour qu'un capteur bavard n'en évince pas d'autres
+type DeviceShaper struct {
+	rate      float64 // Tâches par seconde et par appareil (0 = illimité)
+	burst     float64 // Rafale maximale autorisée par appareil
+	fairShare bool    // Limiter la part de queue de chaque appareil sous contention
+	devices   map[string]*deviceBucket
+	mu        sync.Mutex
+}
+
+// NewDeviceShaper crée le lisseur configuré depuis l'environnement
+func NewDeviceShaper() *DeviceShaper {
+	return &DeviceShaper{
+		rate:      getEnvFloat("DEVICE_RATE_LIMIT", 0),
+		burst:     getEnvFloat("DEVICE_BURST", 10),
+		fairShare: getEnvBool("DEVICE_FAIR_SHARE", true),
+		devices:   make(map[string]*deviceBucket),
+	}
+}
+
+// deviceKey identifie l'appareil source d'une soumission: device_id s'il est
+// fourni, sinon l'adresse IP du client (ou le topic pour une soumission MQTT)
+func deviceKey(task *Task, r *http.Request) string {
+	if task.DeviceID != "" {
+		return task.DeviceID
+	}
+	if r == nil {
+		if n := len(task.Provenance); n > 0 {
+			return task.Provenance[n-1].Source + ":" + task.Provenance[n-1].Topic
+		}
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// bucket retourne (en le créant si besoin) l'état d'un appareil; ds.mu doit être détenu
+func (ds *DeviceShaper) bucket(key string, now time.Time) *deviceBucket {
+	b, ok := ds.devices[key]
+	if !ok {
+		b = &deviceBucket{tokens: ds.burst, last: now}
+		ds.devices[key] = b
+	}
+	return b
+}
+
+// Admit décide si une soumission de l'appareil peut être acceptée. En cas de
+// refus, retourne la raison et le délai conseillé avant de réessayer.
+func (ds *DeviceShaper) Admit(key string, queueSize, queueCap int) (bool, string, time.Duration) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	now := time.Now()
+	b := ds.bucket(key, now)
+	b.stats.Submitted++
+	b.stats.LastSeen = now
+
+	// Token bucket: recharge proportionnelle au temps écoulé
+	if ds.rate > 0 {
+		b.tokens = math.Min(ds.burst, b.tokens+now.Sub(b.last).Seconds()*ds.rate)
+		b.last = now
+		if b.tokens < 1 {
+			b.stats.Shaped++
+			wait := time.Duration((1 - b.tokens) / ds.rate * float64(time.Second))
+			return false, fmt.Sprintf("Débit dépassé pour l'appareil %s: limite=%.2f tâches/s", key, ds.rate), wait
+		}
+	}
+
+	// Partage équitable: sous contention, aucun appareil ne peut occuper plus
+	// que sa part de la queue
+	if ds.fairShare && queueCap > 0 && queueSize >= queueCap/2 {
+		active := 0
+		for _, other := range ds.devices {
+			if other.stats.Queued > 0 {
+				active++
+			}
+		}
+		if b.stats.Queued == 0 {
+			active++
+		}
+		share := int(math.Ceil(float64(queueCap) / float64(active)))
+		if b.stats.Queued >= share {
+			b.stats.Shaped++
+			return false, fmt.Sprintf("Part équitable de la queue atteinte pour l'appareil %s: %d/%d tâches", key, b.stats.Queued, share), time.Second
+		}
+	}
+
+	if ds.rate > 0 {
+		b.tokens--
+	}
+	return true, "", 0
+}
+
+// Accepted enregistre qu'une tâche de l'appareil a été mise en queue
+func (ds *DeviceShaper) Accepted(key string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	b := ds.bucket(key, time.Now())
+	b.stats.Accepted++
+	b.stats.Queued++
+}
+
+// Dequeued enregistre qu'une tâche de l'appareil a quitté la queue
+func (ds *DeviceShaper) Dequeued(key string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if b, ok := ds.devices[key]; ok && b.stats.Queued > 0 {
+		b.stats.Queued--
+	}
+}
+
+// Prune oublie les appareils inactifs sans tâche en attente
+func (ds *DeviceShaper) Prune() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	cutoff := time.Now().Add(-deviceIdleTTL)
+	for key, b := range ds.devices {
+		if b.stats.Queued == 0 && b.stats.LastSeen.Before(cutoff) {
+			delete(ds.devices, key)
+		}
+	}
+}
+
+// Queued retourne le nombre de tâches de l'appareil actuellement en queue
+func (ds *DeviceShaper) Queued(key string) int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if b, ok := ds.devices[key]; ok {
+		return b.stats.Queued
+	}
+	return 0
+}
+
+// Stats retourne une copie des compteurs par appareil
+func (ds *DeviceShaper) Stats() map[string]DeviceStats {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	stats := make(map[string]DeviceStats, len(ds.devices))
+	for key, b := range ds.devices {
+		stats[key] = b.stats
+	}
+	return stats
+}
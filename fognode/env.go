@@ -0,0 +1,84 @@
+package fognode
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getEnv retourne la variable d'environnement, à défaut la valeur du fichier
+// de configuration, ou une valeur par défaut
+func getEnv(key, def string) string {
+	if v, _ := config.lookup(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// getEnvInt lit un entier depuis l'environnement
+func getEnvInt(key string, def int) int {
+	v, fromFile := config.lookup(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		config.invalid(key, v, fromFile, def)
+		return def
+	}
+	return n
+}
+
+// getEnvFloat lit un flottant depuis l'environnement
+func getEnvFloat(key string, def float64) float64 {
+	v, fromFile := config.lookup(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		config.invalid(key, v, fromFile, def)
+		return def
+	}
+	return f
+}
+
+// getEnvBool lit un booléen depuis l'environnement
+func getEnvBool(key string, def bool) bool {
+	v, fromFile := config.lookup(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		config.invalid(key, v, fromFile, def)
+		return def
+	}
+	return b
+}
+
+// getEnvDuration lit une durée (ex: "500ms", "2s") depuis l'environnement
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v, fromFile := config.lookup(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		config.invalid(key, v, fromFile, def)
+		return def
+	}
+	return d
+}
+
+// getEnvList lit une liste séparée par des virgules depuis l'environnement
+func getEnvList(key string) []string {
+	items := make([]string, 0)
+	v, _ := config.lookup(key)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,279 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types d'événements du cycle de vie des tâches diffusés sur GET /events
+const (
+	EventQueued    = "queued"
+	EventStarted   = "started"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+	EventRejected  = "rejected"
+	EventPreempted = "preempted"
+	EventProgress  = "progress"
+)
+
+// TaskEvent est un événement du cycle de vie d'une tâche. Les horodatages
+// sont ceux de l'horloge du nœud: clock_skew_ms, dans l'événement "hello"
+// d'ouverture du flux, permet de les recaler.
+type TaskEvent struct {
+	Seq        uint64        `json:"seq"` // Identifiant SSE (id:), croissant sur la vie du processus
+	Type       string        `json:"type"`
+	At         time.Time     `json:"at"`
+	TaskID     string        `json:"task_id"`
+	TaskType   string        `json:"task_type,omitempty"`
+	Status     string        `json:"status,omitempty"`
+	Priority   int           `json:"priority"`
+	QueueClass string        `json:"queue_class,omitempty"`
+	Tenant     string        `json:"tenant,omitempty"`
+	Attempt    int           `json:"attempt,omitempty"`
+	QueueMs    int64         `json:"queue_ms,omitempty"`    // Attente en queue (started)
+	DurationMs int64         `json:"duration_ms,omitempty"` // Durée d'exécution (completed, failed)
+	Reason     string        `json:"reason,omitempty"`      // Raison du rejet
+	Progress   *TaskProgress `json:"progress,omitempty"`    // Avancement déclaré (progress)
+}
+
+// EventStreamHello ouvre chaque flux SSE
+type EventStreamHello struct {
+	NodeID       string    `json:"node_id"`
+	ServerTime   time.Time `json:"server_time"`
+	ClockSkewMs  float64   `json:"clock_skew_ms"`
+	ClockSynced  bool      `json:"clock_synchronized"`
+	LastSeq      uint64    `json:"last_seq"`
+	ReplayedFrom uint64    `json:"replayed_from,omitempty"` // Premier événement rejoué depuis Last-Event-ID
+}
+
+// EventStats décrit la diffusion des événements
+type EventStats struct {
+	Subscribers int    `json:"subscribers"`
+	Published   uint64 `json:"published"`
+	Dropped     int64  `json:"dropped"` // Événements non remis à un abonné trop lent
+}
+
+// eventSub est un abonné au flux d'événements
+type eventSub struct {
+	ch chan TaskEvent
+}
+
+// EventHub diffuse les événements des tâches aux abonnés SSE et en conserve
+// les derniers pour la reprise après reconnexion (Last-Event-ID). Un abonné
+// trop lent perd des événements plutôt que de ralentir les workers.
+type EventHub struct {
+	heartbeat time.Duration
+	subBuffer int
+	history   []TaskEvent // Derniers événements, du plus ancien au plus récent
+	maxHist   int
+	seq       uint64
+	subs      map[*eventSub]struct{}
+	dropped   int64
+	done      chan struct{}
+	// forward reçoit chaque événement publié (hors verrou); il ne doit pas bloquer
+	forward func(TaskEvent)
+	mu      sync.Mutex
+}
+
+// NewEventHub lit EVENTS_HISTORY, EVENTS_SUBSCRIBER_BUFFER et EVENTS_HEARTBEAT
+func NewEventHub() *EventHub {
+	heartbeat := getEnvDuration("EVENTS_HEARTBEAT", 15*time.Second)
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &EventHub{
+		heartbeat: heartbeat,
+		subBuffer: getEnvInt("EVENTS_SUBSCRIBER_BUFFER", 256),
+		maxHist:   getEnvInt("EVENTS_HISTORY", 1000),
+		subs:      make(map[*eventSub]struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Run ferme les flux ouverts à l'arrêt du nœud, sans quoi l'arrêt gracieux
+// des listeners attendrait leur déconnexion
+func (h *EventHub) Run(ctx context.Context) {
+	<-ctx.Done()
+	close(h.done)
+}
+
+// Publish numérote et diffuse un événement, sans jamais bloquer l'appelant
+func (h *EventHub) Publish(ev TaskEvent) {
+	h.mu.Lock()
+	defer func() {
+		h.mu.Unlock()
+		if h.forward != nil {
+			h.forward(ev)
+		}
+	}()
+
+	h.seq++
+	ev.Seq = h.seq
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	if h.maxHist > 0 {
+		h.history = append(h.history, ev)
+		if len(h.history) > h.maxHist {
+			h.history = h.history[len(h.history)-h.maxHist:]
+		}
+	}
+	for sub := range h.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			h.dropped++
+		}
+	}
+}
+
+// subscribe inscrit un abonné et retourne les événements postérieurs à after
+// encore en mémoire, ainsi que le dernier numéro attribué
+func (h *EventHub) subscribe(after uint64) (*eventSub, []TaskEvent, uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &eventSub{ch: make(chan TaskEvent, h.subBuffer)}
+	h.subs[sub] = struct{}{}
+	var replay []TaskEvent
+	if after > 0 {
+		for _, ev := range h.history {
+			if ev.Seq > after {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	return sub, replay, h.seq
+}
+
+// unsubscribe désinscrit un abonné
+func (h *EventHub) unsubscribe(sub *eventSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+// Stats retourne l'état de la diffusion
+func (h *EventHub) Stats() EventStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return EventStats{Subscribers: len(h.subs), Published: h.seq, Dropped: h.dropped}
+}
+
+// taskEvent construit l'événement d'une tâche
+func taskEvent(kind string, task *Task) TaskEvent {
+	return TaskEvent{
+		Type:       kind,
+		TaskID:     task.ID,
+		TaskType:   task.Type,
+		Status:     task.Status,
+		Priority:   task.Priority,
+		QueueClass: task.QueueClass,
+		Tenant:     tenantOf(task),
+		Attempt:    task.Attempts,
+	}
+}
+
+// writeSSE écrit un événement au format text/event-stream
+func writeSSE(w http.ResponseWriter, id uint64, event string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id > 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
+
+// handleEvents diffuse en Server-Sent Events le cycle de vie des tâches
+// (?types=queued,completed pour filtrer, ?task_id= pour suivre une tâche).
+// Après une reconnexion, Last-Event-ID rejoue les événements manqués encore
+// en mémoire.
+func (fc *FogCompute) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming non supporté", http.StatusInternalServerError)
+		return
+	}
+
+	var types map[string]bool
+	if raw := r.URL.Query().Get("types"); raw != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(raw, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+	taskID := r.URL.Query().Get("task_id")
+	tenant, all := fc.requestTenant(r)
+	wanted := func(ev TaskEvent) bool {
+		return (types == nil || types[ev.Type]) && (taskID == "" || ev.TaskID == taskID) && (all || ev.Tenant == tenant)
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	after, _ := strconv.ParseUint(lastID, 10, 64)
+
+	sub, replay, lastSeq := fc.events.subscribe(after)
+	defer fc.events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Désactiver la mise en tampon des proxys nginx
+	w.WriteHeader(http.StatusOK)
+
+	clock := fc.clock.Status()
+	hello := EventStreamHello{
+		NodeID:      fc.node.ID,
+		ServerTime:  time.Now(),
+		ClockSkewMs: clock.SkewMs,
+		ClockSynced: clock.Synchronized,
+		LastSeq:     lastSeq,
+	}
+	if len(replay) > 0 {
+		hello.ReplayedFrom = replay[0].Seq
+	}
+	fmt.Fprintf(w, "retry: 3000\n")
+	writeSSE(w, 0, "hello", hello)
+	for _, ev := range replay {
+		if wanted(ev) {
+			writeSSE(w, ev.Seq, ev.Type, ev)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(fc.events.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-fc.events.done:
+			return
+		case <-heartbeat.C:
+			// Commentaire SSE: maintient la connexion ouverte à travers les proxys
+			if _, err := fmt.Fprintf(w, ": ping %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev := <-sub.ch:
+			if !wanted(ev) {
+				continue
+			}
+			if err := writeSSE(w, ev.Seq, ev.Type, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execTaskType est le type des tâches qui lancent une commande locale autorisée
+const execTaskType = "exec"
+
+// CommandExecutor lance les tâches exec: une commande de la liste
+// EXEC_COMMANDS, sans shell, avec les arguments du payload. La mémoire
+// virtuelle et la taille des fichiers écrits découlent des coûts RAM et
+// stockage déclarés par la tâche, dans la limite de plafonds toujours
+// appliqués: une tâche qui ne déclare rien reçoit les plafonds.
+type CommandExecutor struct {
+	Commands        map[string]string // Nom exposé → chemin absolu de l'exécutable
+	Timeout         time.Duration     // Plafond de durée, en plus du timeout de la tâche
+	MemoryMBPerUnit float64           // Mo de mémoire virtuelle pour 1.0 de coût RAM (0 = plafond seul)
+	MaxMemoryMB     int               // Plafond de mémoire virtuelle d'une commande
+	MaxFileMB       int               // Plafond de taille d'un fichier écrit par une commande
+	MaxOutputBytes  int               // Sortie standard conservée; au-delà l'exécution échoue
+}
+
+// commands est l'exécuteur du type exec, nil s'il n'est pas proposé
+var commands *CommandExecutor
+
+// loadCommandExecutor lit EXEC_* et enregistre le handler du type exec.
+// EXEC_COMMANDS associe un nom à un exécutable ("resume=/opt/edge/resume.sh");
+// sans liste, le type n'est pas proposé.
+func loadCommandExecutor() {
+	ce := &CommandExecutor{
+		Commands:        make(map[string]string),
+		Timeout:         getEnvDuration("EXEC_TIMEOUT", 5*time.Minute),
+		MemoryMBPerUnit: getEnvFloat("EXEC_MEMORY_MB_PER_UNIT", 1024),
+		MaxMemoryMB:     getEnvInt("EXEC_MAX_MEMORY_MB", 1024),
+		MaxFileMB:       getEnvInt("EXEC_MAX_FILE_MB", 256),
+		MaxOutputBytes:  getEnvInt("EXEC_MAX_OUTPUT_KB", 1<<10) << 10,
+	}
+	if ce.Timeout <= 0 || ce.MemoryMBPerUnit < 0 || ce.MaxMemoryMB <= 0 || ce.MaxFileMB <= 0 || ce.MaxOutputBytes <= 0 {
+		config.errorf("EXEC_*", "les limites des commandes doivent être positives")
+		return
+	}
+	for _, entry := range getEnvList("EXEC_COMMANDS") {
+		name, path, ok := strings.Cut(entry, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || name == "" || !filepath.IsAbs(path) {
+			config.errorf("EXEC_COMMANDS", "entrée %q: nom=/chemin/absolu attendu", entry)
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+			slog.Warn("Commande autorisée introuvable ou non exécutable", "command", name, "path", path)
+		}
+		ce.Commands[name] = path
+	}
+	if len(ce.Commands) == 0 {
+		return
+	}
+	commands = ce
+	registerHandler(execTaskType, registeredHandler{fn: ce.run, version: "1.0.0", source: "builtin"})
+}
+
+// ExecPayload est le payload d'une tâche exec
+type ExecPayload struct {
+	Command string          `json:"command"`         // Nom de la commande dans EXEC_COMMANDS
+	Args    []string        `json:"args,omitempty"`  // Arguments, passés tels quels (sans shell)
+	Input   json.RawMessage `json:"input,omitempty"` // Document JSON écrit sur l'entrée standard
+}
+
+// decodeExecPayload lit le payload d'une tâche exec et résout sa commande
+func (ce *CommandExecutor) decodeExecPayload(payload map[string]interface{}) (ExecPayload, string, error) {
+	var p ExecPayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return p, "", err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, "", fmt.Errorf("payload exec invalide: %w", err)
+	}
+	if p.Command == "" {
+		return p, "", errors.New("payload exec: command requise")
+	}
+	path, ok := ce.Commands[p.Command]
+	if !ok {
+		return p, "", fmt.Errorf("commande %q non autorisée (EXEC_COMMANDS)", p.Command)
+	}
+	return p, path, nil
+}
+
+// validateExecTask refuse dès la soumission une tâche exec dont la commande
+// n'est pas autorisée
+func validateExecTask(task *Task) *Rejection {
+	if task.Type != execTaskType {
+		return nil
+	}
+	taskHandlersMu.RLock()
+	h, ok := taskHandlers[execTaskType]
+	taskHandlersMu.RUnlock()
+	if !ok || h.source != "builtin" || commands == nil {
+		return nil // Type non proposé, ou remplacé par un module ou un plugin
+	}
+	if _, _, err := commands.decodeExecPayload(task.Payload); err != nil {
+		return &Rejection{Reason: err.Error(), Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// limits retourne la mémoire virtuelle et la taille de fichier accordées à
+// une commande, en octets: les coûts déclarés, bornés par les plafonds, ou
+// les plafonds pour un coût nul ou absent
+func (ce *CommandExecutor) limits(costs TaskCosts) (memory, fileSize uint64) {
+	memoryMB := float64(ce.MaxMemoryMB)
+	if declared := math.Ceil(costs.RAM * ce.MemoryMBPerUnit); declared > 0 && declared < memoryMB {
+		memoryMB = declared
+	}
+	fileMB := float64(ce.MaxFileMB)
+	if declared := math.Ceil(costs.StorageMB); declared > 0 && declared < fileMB {
+		fileMB = declared
+	}
+	return uint64(memoryMB) << 20, uint64(fileMB) << 20
+}
+
+// run exécute la commande d'une tâche exec dans un répertoire temporaire,
+// supprimé ensuite, avec un environnement réduit (PATH, FOG_TASK_ID,
+// FOG_ATTEMPT, FOG_OUTPUT_DIR). Le document input est écrit sur son entrée
+// standard, sa sortie standard devient le résultat et les fichiers laissés
+// dans FOG_OUTPUT_DIR sont enregistrés comme blobs (files). À l'expiration ou
+// à la préemption, la commande et les processus qu'elle a lancés sont tués.
+func (ce *CommandExecutor) run(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	p, path, err := ce.decodeExecPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	te, ok := taskExecution(ctx)
+	if !ok {
+		return nil, errors.New("exécution exec hors d'un worker")
+	}
+	ctx, cancel := context.WithTimeout(ctx, ce.Timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "fog-exec-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	outputDir := filepath.Join(dir, "output")
+	if err := os.Mkdir(outputDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	stdout := &limitedBuffer{max: ce.MaxOutputBytes}
+	stderr := &limitedBuffer{max: 4 << 10}
+	cmd := exec.CommandContext(ctx, path, p.Args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		"FOG_TASK_ID=" + te.TaskID,
+		"FOG_ATTEMPT=" + strconv.Itoa(te.Attempt),
+		"FOG_OUTPUT_DIR=" + outputDir,
+	}
+	cmd.Stdin = bytes.NewReader(p.Input)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = 5 * time.Second
+
+	memory, fileSize := ce.limits(te.Costs)
+	// prlimit pose les limites avant l'exécution de la commande; sans lui,
+	// elles sont posées juste après son démarrage et peuvent manquer ses
+	// tout premiers processus fils
+	limited := prlimitCommand(cmd, memory, fileSize)
+	start := time.Now()
+	if err := te.Start(cmd); err != nil {
+		return nil, fmt.Errorf("démarrage de %s: %w", p.Command, err)
+	}
+	if !limited {
+		if err := applyResourceLimits(cmd.Process.Pid, memory, fileSize); err != nil {
+			slog.Warn("Application des limites de ressources impossible", "command", p.Command, "pid", cmd.Process.Pid, "error", err)
+		}
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode, err = exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("commande %s: %w", p.Command, err)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("commande %s terminée avec le code %d: %s", p.Command, exitCode, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("sortie de %s supérieure à %d Kio (EXEC_MAX_OUTPUT_KB)", p.Command, ce.MaxOutputBytes>>10)
+	}
+
+	result := map[string]interface{}{
+		"operation":   execTaskType,
+		"status":      "success",
+		"command":     p.Command,
+		"exit_code":   exitCode,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"output":      decodeOutput(stdout.Bytes()),
+	}
+	if stderr.Len() > 0 {
+		result["stderr"] = stderr.String()
+	}
+	files, err := storeOutputFiles(ctx, outputDir)
+	if err != nil {
+		return nil, fmt.Errorf("fichiers produits par %s: %w", p.Command, err)
+	}
+	if len(files) > 0 {
+		result["files"] = files
+	}
+	return result, nil
+}
+
+// storeOutputFiles enregistre comme blobs de résultat les fichiers réguliers
+// laissés par une commande dans son répertoire de sortie
+func storeOutputFiles(ctx context.Context, dir string) (map[string]BlobRef, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]BlobRef)
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		ref, err := storeOutputFile(ctx, filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = ref
+	}
+	return files, nil
+}
+
+// storeOutputFile enregistre un fichier comme blob, son type de contenu
+// déduit de son extension ou, à défaut, de ses premiers octets
+func storeOutputFile(ctx context.Context, path string) (BlobRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BlobRef{}, err
+	}
+	defer f.Close()
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		contentType = http.DetectContentType(head[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return BlobRef{}, err
+		}
+	}
+	return storeResultBlob(ctx, filepath.Base(path), contentType, f)
+}
+
+// prlimitCommand fait lancer la commande par prlimit avec les limites de
+// mémoire virtuelle et de taille de fichier données (octets, 0 = aucune);
+// retourne false si prlimit est introuvable
+func prlimitCommand(cmd *exec.Cmd, memoryBytes, fileSizeBytes uint64) bool {
+	args := make([]string, 0, 3)
+	if memoryBytes > 0 {
+		args = append(args, "--as="+strconv.FormatUint(memoryBytes, 10))
+	}
+	if fileSizeBytes > 0 {
+		args = append(args, "--fsize="+strconv.FormatUint(fileSizeBytes, 10))
+	}
+	if len(args) == 0 {
+		return true
+	}
+	return wrap(cmd, "prlimit", append(args, "--")...) == nil
+}
@@ -0,0 +1,29 @@
+package fognode
+
+import "testing"
+
+func TestCommandExecutorLimits(t *testing.T) {
+	ce := &CommandExecutor{MemoryMBPerUnit: 1024, MaxMemoryMB: 512, MaxFileMB: 64}
+	tests := []struct {
+		name           string
+		costs          TaskCosts
+		memoryMB, file uint64
+	}{
+		{"coûts absents", TaskCosts{}, 512, 64},
+		{"coûts déclarés", TaskCosts{RAM: 0.25, StorageMB: 10}, 256, 10},
+		{"coûts au-delà des plafonds", TaskCosts{RAM: 4, StorageMB: 1e6}, 512, 64},
+		{"coûts négatifs", TaskCosts{RAM: -1, StorageMB: -1}, 512, 64},
+	}
+	for _, tt := range tests {
+		memory, file := ce.limits(tt.costs)
+		if memory != tt.memoryMB<<20 || file != tt.file<<20 {
+			t.Errorf("%s: limites %d/%d Mo, attendu %d/%d", tt.name, memory>>20, file>>20, tt.memoryMB, tt.file)
+		}
+	}
+
+	// Sans mémoire par unité de coût, seul le plafond s'applique
+	ce.MemoryMBPerUnit = 0
+	if memory, _ := ce.limits(TaskCosts{RAM: 0.25}); memory != 512<<20 {
+		t.Errorf("mémoire sans EXEC_MEMORY_MB_PER_UNIT: %d Mo", memory>>20)
+	}
+}
@@ -0,0 +1,141 @@
+package fognode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GroupResult est le résultat d'une tâche terminée d'un groupe
+type GroupResult struct {
+	TaskID string      `json:"task_id"`
+	Status string      `json:"status"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+// GroupStatus décrit l'avancement d'un groupe de tâches (GET /groups/{id})
+type GroupStatus struct {
+	ID        string         `json:"id"`
+	Total     int            `json:"total"`
+	Counts    map[string]int `json:"counts"`    // Tâches par statut
+	Finished  int            `json:"finished"`  // Tâches dans un état final, réussies ou non
+	Progress  float64        `json:"progress"`  // Part des tâches dans un état final
+	Completed bool           `json:"completed"` // Toutes les tâches connues sont dans un état final
+	Succeeded bool           `json:"succeeded"` // Toutes les tâches ont réussi
+	Results   []GroupResult  `json:"results"`   // Tâches terminées, dans l'ordre de soumission
+}
+
+// GroupCallback est le corps POSTé au group_callback_url quand un groupe se termine
+type GroupCallback struct {
+	Event  string      `json:"event"`
+	NodeID string      `json:"node_id"`
+	SentAt time.Time   `json:"sent_at"`
+	Group  GroupStatus `json:"group"`
+}
+
+// TaskGroups retient les groupes déjà notifiés: un groupe qui reçoit de
+// nouvelles tâches après sa fin est notifié de nouveau à sa nouvelle fin.
+// Cet état ne survit pas à un redémarrage.
+type TaskGroups struct {
+	mu       sync.Mutex
+	notified map[string]int // Taille du groupe lors de la dernière notification
+}
+
+// NewTaskGroups crée le suivi des notifications de groupes
+func NewTaskGroups() *TaskGroups {
+	return &TaskGroups{notified: make(map[string]int)}
+}
+
+// groupStatusLocked calcule l'état d'un groupe visible du client, et le
+// callback de groupe de sa première tâche qui en déclare un; fc.mu doit être
+// détenu, au moins en lecture
+func (fc *FogCompute) groupStatusLocked(groupID, tenant string, all bool) (GroupStatus, string) {
+	status := GroupStatus{ID: groupID, Counts: make(map[string]int), Results: make([]GroupResult, 0)}
+	var members []*Task
+	for _, t := range fc.tasks {
+		if t.GroupID == groupID && visibleTo(t, tenant, all) {
+			members = append(members, t)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].SubmittedAt.Before(members[j].SubmittedAt) })
+
+	callbackURL := ""
+	succeeded := 0
+	for _, t := range members {
+		status.Counts[t.Status]++
+		if callbackURL == "" {
+			callbackURL = t.GroupCallbackURL
+		}
+		state := dependencyState(t)
+		if state == WorkflowWaiting {
+			continue
+		}
+		status.Finished++
+		if state == WorkflowCompleted {
+			succeeded++
+		}
+		status.Results = append(status.Results, GroupResult{TaskID: t.ID, Status: t.Status, Result: t.Result})
+	}
+	status.Total = len(members)
+	if status.Total > 0 {
+		status.Progress = float64(status.Finished) / float64(status.Total)
+		status.Completed = status.Finished == status.Total
+		status.Succeeded = succeeded == status.Total
+	}
+	return status, callbackURL
+}
+
+// checkGroupCompletion notifie le callback d'un groupe dont toutes les
+// tâches sont désormais dans un état final
+func (fc *FogCompute) checkGroupCompletion(groupID string) {
+	if groupID == "" {
+		return
+	}
+	fc.mu.RLock()
+	status, callbackURL := fc.groupStatusLocked(groupID, "", true)
+	fc.mu.RUnlock()
+	if !status.Completed {
+		return
+	}
+
+	g := fc.groups
+	g.mu.Lock()
+	if g.notified[groupID] == status.Total {
+		g.mu.Unlock()
+		return
+	}
+	g.notified[groupID] = status.Total
+	g.mu.Unlock()
+
+	slog.Info("Groupe de tâches terminé", "group_id", groupID, "total", status.Total, "succeeded", status.Succeeded)
+	if callbackURL != "" {
+		fc.callbacks.NotifyGroup(callbackURL, GroupCallback{
+			Event:  CallbackGroupCompleted,
+			NodeID: fc.node.ID,
+			SentAt: time.Now(),
+			Group:  status,
+		})
+	}
+}
+
+// handleGetGroup retourne l'état agrégé d'un groupe de tâches
+func (fc *FogCompute) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+
+	fc.mu.RLock()
+	status, _ := fc.groupStatusLocked(groupID, tenant, all)
+	fc.mu.RUnlock()
+
+	if status.Total == 0 {
+		http.Error(w, "Groupe non trouvé", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,174 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"sync"
+)
+
+// Handler exécute une tâche à partir de son payload; le résultat devient
+// task.Result. ctx expire au timeout de la tâche ou à sa préemption.
+type Handler func(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error)
+
+// Symboles exportés par un plugin de handlers (go build -buildmode=plugin):
+//
+//	var FogHandlers = map[string]func(context.Context, map[string]interface{}) (map[string]interface{}, error){...}
+//	var FogHandlerVersion = "1.2.0" // Facultatif
+const (
+	pluginHandlersSymbol = "FogHandlers"
+	pluginVersionSymbol  = "FogHandlerVersion"
+)
+
+// TaskExecution décrit la tentative en cours aux handlers qui lancent un
+// processus ou un conteneur: ses coûts déclarés en fixent les limites
+type TaskExecution struct {
+	TaskID  string
+	Type    string
+	Tenant  string // Namespace de la tâche
+	Attempt int
+	Costs   TaskCosts     // Coûts déclarés ou par défaut de la tâche
+	Sandbox SandboxPolicy // Confinement du type de tâche (SANDBOX_*)
+	CPUs    []int         // Cœurs autorisés (WORKER_CPUS, mode basse énergie)
+	// Start démarre un sous-processus sous le confinement du type de tâche
+	// et les limites d'exécution du nœud (affinité, nice, ionice)
+	Start func(cmd *exec.Cmd) error
+	// PutBlob enregistre un résultat volumineux dans le stockage de blobs
+	// (storeResultBlob)
+	PutBlob func(ctx context.Context, name, contentType string, r io.Reader) (BlobRef, error)
+}
+
+// executionContextKey porte la TaskExecution dans le contexte d'un handler
+type executionContextKey struct{}
+
+// taskExecution retourne la tentative exécutée par le handler appelant
+func taskExecution(ctx context.Context) (TaskExecution, bool) {
+	te, ok := ctx.Value(executionContextKey{}).(TaskExecution)
+	return te, ok
+}
+
+// taskExecution décrit la tentative en cours d'une tâche
+func (fc *FogCompute) taskExecution(task *Task) TaskExecution {
+	cpus := fc.procLimits.CPUs
+	if low := fc.energyWorkers.CPUs(); low != nil {
+		cpus = low // Basse énergie: travail concentré sur moins de cœurs
+	}
+	return TaskExecution{
+		TaskID:  task.ID,
+		Type:    task.Type,
+		Tenant:  tenantOf(task),
+		Attempt: task.Attempts,
+		Costs:   TaskCosts{CPU: task.CPUCost, RAM: task.RAMCost, StorageMB: task.StorageCost},
+		Sandbox: fc.sandbox.For(task.Type),
+		CPUs:    cpus,
+		Start: func(cmd *exec.Cmd) error {
+			return fc.startSubprocess(task.Type, cmd)
+		},
+		PutBlob: func(ctx context.Context, name, contentType string, r io.Reader) (BlobRef, error) {
+			return fc.blobs.put(ctx, task.ID, name, contentType, r)
+		},
+	}
+}
+
+// decodeOutput retourne la sortie d'un module, d'un conteneur ou d'une
+// commande: décodée si c'est du JSON, sinon telle quelle
+func decodeOutput(stdout []byte) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal(stdout, &decoded); err == nil {
+		return decoded
+	}
+	return string(stdout)
+}
+
+// registeredHandler est un handler enregistré, avec sa version et son origine
+type registeredHandler struct {
+	fn      Handler
+	version string
+	source  string // "builtin", "module" ou le nom du fichier du plugin
+}
+
+var (
+	taskHandlersMu sync.RWMutex
+	// taskHandlers associe chaque type de tâche à son handler
+	taskHandlers = map[string]registeredHandler{
+		"data_aggregation": {fn: aggregateData, version: "1.0.0", source: "builtin"},
+		"edge_analytics":   {fn: performAnalytics, version: "1.0.0", source: "builtin"},
+		"preprocessing":    {fn: preprocessData, version: "1.0.0", source: "builtin"},
+		"caching":          {fn: cacheData, version: "1.0.0", source: "builtin"},
+	}
+)
+
+// RegisterHandler enregistre le handler d'un type de tâche, en version
+// 1.0.0; un module compilé avec le nœud l'appelle depuis son init(). Un
+// handler déjà enregistré pour ce type est remplacé.
+func RegisterHandler(taskType string, fn Handler) {
+	RegisterHandlerVersion(taskType, "1.0.0", fn)
+}
+
+// RegisterHandlerVersion enregistre un handler avec la version annoncée dans
+// les capacités du nœud
+func RegisterHandlerVersion(taskType, version string, fn Handler) {
+	registerHandler(taskType, registeredHandler{fn: fn, version: version, source: "module"})
+}
+
+func registerHandler(taskType string, h registeredHandler) {
+	taskHandlersMu.Lock()
+	defer taskHandlersMu.Unlock()
+	taskHandlers[taskType] = h
+}
+
+// lookupHandler retourne le handler d'un type de tâche
+func lookupHandler(taskType string) (Handler, bool) {
+	taskHandlersMu.RLock()
+	defer taskHandlersMu.RUnlock()
+	h, ok := taskHandlers[taskType]
+	return h.fn, ok
+}
+
+// handlerCapabilities liste les types de tâches supportés, triés
+func handlerCapabilities() []TaskTypeCapability {
+	taskHandlersMu.RLock()
+	defer taskHandlersMu.RUnlock()
+	types := make([]TaskTypeCapability, 0, len(taskHandlers))
+	for t, h := range taskHandlers {
+		types = append(types, TaskTypeCapability{Type: t, HandlerVersion: h.version, Source: h.source})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// loadHandlerPlugins charge les plugins de HANDLER_PLUGINS (chemins de
+// fichiers .so séparés par des virgules) et enregistre leurs handlers. Les
+// plugins Go demandent un binaire construit avec cgo sous Linux, macOS ou
+// FreeBSD, et la même version de Go que le plugin.
+func loadHandlerPlugins() error {
+	for _, path := range getEnvList("HANDLER_PLUGINS") {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		sym, err := p.Lookup(pluginHandlersSymbol)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		handlers, ok := sym.(*map[string]func(context.Context, map[string]interface{}) (map[string]interface{}, error))
+		if !ok {
+			return fmt.Errorf("plugin %s: %s de type %T inattendu", path, pluginHandlersSymbol, sym)
+		}
+		version := "1.0.0"
+		if sym, err := p.Lookup(pluginVersionSymbol); err == nil {
+			if v, ok := sym.(*string); ok && *v != "" {
+				version = *v
+			}
+		}
+		for taskType, fn := range *handlers {
+			registerHandler(taskType, registeredHandler{fn: fn, version: version, source: filepath.Base(path)})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package fognode
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// hostCounters sont les compteurs cumulés lus sur l'hôte; l'utilisation CPU
+// et le débit réseau se déduisent de deux relevés successifs
+type hostCounters struct {
+	source    string  // "proc" ou "cgroup" (limites du conteneur)
+	cpuBusy   float64 // Secondes CPU consommées
+	cpuTotal  float64 // Secondes CPU disponibles (temps écoulé × cœurs alloués)
+	memTotal  uint64  // Octets
+	memAvail  uint64
+	diskTotal uint64 // Octets du système de fichiers de DATA_DIR
+	diskFree  uint64
+	netRx     uint64 // Octets reçus, toutes interfaces hors loopback
+	netTx     uint64
+}
+
+// HostSample est la mesure courante des ressources réelles de l'hôte
+type HostSample struct {
+	Source      string    `json:"source"`
+	CPU         float64   `json:"cpu"`           // Utilisation CPU (0-1) de l'hôte ou du conteneur
+	Memory      float64   `json:"memory"`        // Part de la mémoire utilisée (0-1)
+	MemTotalMB  float64   `json:"mem_total_mb"`
+	MemAvailMB  float64   `json:"mem_available_mb"`
+	Disk        float64   `json:"disk"` // Part du disque de DATA_DIR utilisée (0-1)
+	DiskTotalMB float64   `json:"disk_total_mb"`
+	DiskFreeMB  float64   `json:"disk_free_mb"`
+	NetRxBps    float64   `json:"net_rx_bytes_per_sec"`
+	NetTxBps    float64   `json:"net_tx_bytes_per_sec"`
+	SampledAt   time.Time `json:"sampled_at"`
+}
+
+// HostStats décrit l'échantillonnage des ressources de l'hôte (/metrics)
+type HostStats struct {
+	Enabled   bool        `json:"enabled"`
+	Interval  string      `json:"interval"`
+	CPUMax    float64     `json:"cpu_max"`
+	Sample    *HostSample `json:"sample,omitempty"`
+	LastError string      `json:"last_error,omitempty"`
+}
+
+// HostSampler mesure périodiquement le CPU, la mémoire, le disque et le réseau
+// réellement utilisés (via /proc, et les fichiers cgroup v2 dans un conteneur
+// limité), pour que la charge et l'admission ne reposent pas que sur les coûts
+// déclarés par les tâches
+type HostSampler struct {
+	enabled  bool
+	interval time.Duration
+	cpuMax   float64 // Utilisation CPU mesurée au-delà de laquelle les tâches sont refusées
+	dataDir  string
+
+	prev      *hostCounters
+	prevAt    time.Time
+	sample    *HostSample
+	lastError string
+	mu        sync.Mutex
+}
+
+// NewHostSampler lit HOST_SAMPLING, HOST_SAMPLE_INTERVAL et HOST_CPU_MAX
+func NewHostSampler() *HostSampler {
+	return &HostSampler{
+		enabled:  getEnvBool("HOST_SAMPLING", true),
+		interval: getEnvDuration("HOST_SAMPLE_INTERVAL", 5*time.Second),
+		cpuMax:   getEnvFloat("HOST_CPU_MAX", 0.95),
+		dataDir:  getEnv("DATA_DIR", "data"),
+	}
+}
+
+// Run échantillonne l'hôte jusqu'à l'annulation de ctx
+func (hs *HostSampler) Run(ctx context.Context) {
+	if !hs.enabled {
+		return
+	}
+	hs.collect()
+	ticker := time.NewTicker(hs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hs.collect()
+		}
+	}
+}
+
+// collect lit les compteurs de l'hôte et calcule l'échantillon courant
+func (hs *HostSampler) collect() {
+	dir, err := filepath.Abs(hs.dataDir)
+	if err != nil {
+		dir = "."
+	}
+	now := time.Now()
+	c, err := readHostCounters(dir)
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if err != nil {
+		if hs.lastError == "" {
+			slog.Warn("Mesure des ressources de l'hôte impossible", "error", err)
+		}
+		hs.lastError = err.Error()
+		return
+	}
+	hs.lastError = ""
+
+	s := &HostSample{
+		Source:      c.source,
+		MemTotalMB:  float64(c.memTotal) / (1 << 20),
+		MemAvailMB:  float64(c.memAvail) / (1 << 20),
+		DiskTotalMB: float64(c.diskTotal) / (1 << 20),
+		DiskFreeMB:  float64(c.diskFree) / (1 << 20),
+		SampledAt:   now,
+	}
+	if c.memTotal > 0 {
+		s.Memory = 1 - float64(c.memAvail)/float64(c.memTotal)
+	}
+	if c.diskTotal > 0 {
+		s.Disk = 1 - float64(c.diskFree)/float64(c.diskTotal)
+	}
+	if p := hs.prev; p != nil && p.source == c.source {
+		if total := c.cpuTotal - p.cpuTotal; total > 0 {
+			s.CPU = clamp01((c.cpuBusy - p.cpuBusy) / total)
+		}
+		if elapsed := now.Sub(hs.prevAt).Seconds(); elapsed > 0 && c.netRx >= p.netRx && c.netTx >= p.netTx {
+			s.NetRxBps = float64(c.netRx-p.netRx) / elapsed
+			s.NetTxBps = float64(c.netTx-p.netTx) / elapsed
+		}
+	} else if hs.sample != nil {
+		s.CPU = hs.sample.CPU
+	}
+	hs.prev, hs.prevAt, hs.sample = &c, now, s
+}
+
+// clamp01 borne une valeur à [0, 1]
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Sample retourne la dernière mesure si elle est récente (moins de trois
+// périodes), nil sinon: les décisions retombent alors sur la comptabilité
+func (hs *HostSampler) Sample() *HostSample {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.sample == nil || time.Since(hs.sample.SampledAt) > 3*hs.interval {
+		return nil
+	}
+	s := *hs.sample
+	return &s
+}
+
+// Stats retourne l'état de l'échantillonnage
+func (hs *HostSampler) Stats() HostStats {
+	sample := hs.Sample()
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	return HostStats{
+		Enabled:   hs.enabled,
+		Interval:  hs.interval.String(),
+		CPUMax:    hs.cpuMax,
+		Sample:    sample,
+		LastError: hs.lastError,
+	}
+}
+
+// checkHostResources refuse une tâche que l'hôte ne peut pas réellement
+// accueillir, quelle que soit la comptabilité des coûts déclarés: CPU saturé
+// par d'autres processus, mémoire ou disque de DATA_DIR insuffisants
+func (fc *FogCompute) checkHostResources(ac *AdmissionContext) *Rejection {
+	h, t := ac.Host, ac.Task
+	if h == nil {
+		return nil
+	}
+	switch {
+	case h.CPU >= fc.host.cpuMax:
+		rejection := reject("CPU de l'hôte saturé: %.0f%% mesuré (max %.0f%%)", h.CPU*100, fc.host.cpuMax*100)
+		rejection.RetryAfter = fc.host.interval
+		return rejection
+	case h.MemTotalMB > 0 && t.RAMCost > 1-h.Memory:
+		return reject("Mémoire de l'hôte insuffisante: RAM=%.2f demandée, %.2f libre (%.0f MB)", t.RAMCost, 1-h.Memory, h.MemAvailMB)
+	case h.DiskTotalMB > 0 && t.StorageCost > h.DiskFreeMB:
+		return reject("Disque de l'hôte insuffisant: Storage=%.2f MB demandés, %.2f MB libres", t.StorageCost, h.DiskFreeMB)
+	}
+	return nil
+}
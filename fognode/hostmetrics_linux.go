@@ -0,0 +1,212 @@
+package fognode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cgroupRoot est la hiérarchie cgroup v2 du processus (montée à la racine
+// dans un conteneur)
+const cgroupRoot = "/sys/fs/cgroup"
+
+// readHostCounters lit les compteurs de l'hôte; dans un conteneur dont le
+// cgroup v2 limite le CPU ou la mémoire, ce sont ces limites qui comptent
+func readHostCounters(dataDir string) (hostCounters, error) {
+	var c hostCounters
+	c.source = "proc"
+
+	if busy, cores, ok := readCgroupCPU(); ok {
+		c.source = "cgroup"
+		c.cpuBusy = busy
+		c.cpuTotal = float64(time.Now().UnixNano()) / 1e9 * cores
+	} else {
+		busy, total, err := readProcStat()
+		if err != nil {
+			return c, err
+		}
+		c.cpuBusy, c.cpuTotal = busy, total
+	}
+
+	if total, used, ok := readCgroupMemory(); ok {
+		c.memTotal = total
+		if used < total {
+			c.memAvail = total - used
+		}
+	} else {
+		total, avail, err := readMeminfo()
+		if err != nil {
+			return c, err
+		}
+		c.memTotal, c.memAvail = total, avail
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dataDir, &fs); err != nil {
+		// DATA_DIR n'est créé qu'à la première écriture
+		err = syscall.Statfs(".", &fs)
+		if err != nil {
+			return c, fmt.Errorf("statfs %s: %w", dataDir, err)
+		}
+	}
+	c.diskTotal = fs.Blocks * uint64(fs.Bsize)
+	c.diskFree = fs.Bavail * uint64(fs.Bsize)
+
+	// Le réseau est facultatif: certains bacs à sable ne montent pas /proc/net
+	c.netRx, c.netTx, _ = readNetDev()
+	return c, nil
+}
+
+// readProcStat retourne les jiffies occupés et totaux de /proc/stat, en secondes
+func readProcStat() (busy, total float64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal (guest inclus dans user)
+		var idle float64
+		for i, v := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			n, _ := strconv.ParseFloat(v, 64)
+			total += n
+			if i == 3 || i == 4 {
+				idle += n
+			}
+		}
+		const hz = 100 // USER_HZ
+		return (total - idle) / hz, total / hz, nil
+	}
+	return 0, 0, errors.New("ligne cpu absente de /proc/stat")
+}
+
+// readCgroupCPU retourne le temps CPU consommé par le cgroup et les cœurs
+// alloués par cpu.max; ok est faux sans limite CPU
+func readCgroupCPU() (busy, cores float64, ok bool) {
+	raw, err := os.ReadFile(cgroupRoot + "/cpu.max")
+	if err != nil {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(raw))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, 0, false
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || period <= 0 {
+		return 0, 0, false
+	}
+	stat, err := os.ReadFile(cgroupRoot + "/cpu.stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if v, found := strings.CutPrefix(line, "usage_usec "); found {
+			usec, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return 0, 0, false
+			}
+			return usec / 1e6, quota / period, true
+		}
+	}
+	return 0, 0, false
+}
+
+// readCgroupMemory retourne la limite memory.max du cgroup et l'usage hors
+// cache de pages récupérable; ok est faux sans limite mémoire
+func readCgroupMemory() (total, used uint64, ok bool) {
+	raw, err := os.ReadFile(cgroupRoot + "/memory.max")
+	if err != nil || strings.TrimSpace(string(raw)) == "max" {
+		return 0, 0, false
+	}
+	total, err = strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	raw, err = os.ReadFile(cgroupRoot + "/memory.current")
+	if err != nil {
+		return 0, 0, false
+	}
+	used, err = strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if stat, err := os.ReadFile(cgroupRoot + "/memory.stat"); err == nil {
+		for _, line := range strings.Split(string(stat), "\n") {
+			if v, found := strings.CutPrefix(line, "inactive_file "); found {
+				if inactive, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil && inactive < used {
+					used -= inactive
+				}
+			}
+		}
+	}
+	return total, used, true
+}
+
+// readMeminfo retourne MemTotal et MemAvailable de /proc/meminfo, en octets
+func readMeminfo() (total, avail uint64, err error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb << 10
+		case "MemAvailable:":
+			avail = kb << 10
+		}
+	}
+	if total == 0 {
+		return 0, 0, errors.New("MemTotal absent de /proc/meminfo")
+	}
+	return total, avail, nil
+}
+
+// readNetDev additionne les octets reçus et émis des interfaces hors loopback
+func readNetDev() (rx, tx uint64, err error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		iface, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		r, _ := strconv.ParseUint(fields[0], 10, 64)
+		t, _ := strconv.ParseUint(fields[8], 10, 64)
+		rx += r
+		tx += t
+	}
+	return rx, tx, scanner.Err()
+}
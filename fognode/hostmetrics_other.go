@@ -0,0 +1,11 @@
+//go:build !linux
+
+package fognode
+
+import "errors"
+
+// readHostCounters n'est disponible que sous Linux: la charge et l'admission
+// reposent alors sur la seule comptabilité des coûts déclarés
+func readHostCounters(dataDir string) (hostCounters, error) {
+	return hostCounters{}, errors.New("mesure des ressources de l'hôte non supportée sur cette plateforme")
+}
@@ -0,0 +1,206 @@
+package fognode
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDScheme attribue l'identifiant des tâches soumises. L'identifiant doit être
+// unique dans tout le déploiement fédéré et utilisable tel quel dans les URLs.
+type IDScheme interface {
+	// Name retourne le nom du schéma (valeur de ID_SCHEME)
+	Name() string
+	// NewID retourne l'identifiant de la tâche, ou un refus si l'identifiant
+	// fourni par le client n'est pas acceptable
+	NewID(fc *FogCompute, task *Task) (string, *Rejection)
+}
+
+var (
+	idSchemeFactoriesMu sync.Mutex
+	// idSchemeFactories associe chaque nom de schéma à son constructeur
+	idSchemeFactories = map[string]func() (IDScheme, error){
+		"timestamp": func() (IDScheme, error) { return timestampIDs{}, nil },
+		"ulid":      func() (IDScheme, error) { return &ulidIDs{}, nil },
+		"external":  func() (IDScheme, error) { return newExternalIDs() },
+	}
+)
+
+// RegisterIDScheme enregistre un schéma spécifique à un déploiement,
+// utilisable ensuite dans ID_SCHEME. À appeler avant la création du nœud.
+func RegisterIDScheme(name string, factory func() (IDScheme, error)) {
+	idSchemeFactoriesMu.Lock()
+	defer idSchemeFactoriesMu.Unlock()
+	idSchemeFactories[name] = factory
+}
+
+// loadIDScheme crée le schéma configuré via ID_SCHEME (défaut: timestamp)
+func loadIDScheme() IDScheme {
+	name := getEnv("ID_SCHEME", "timestamp")
+
+	idSchemeFactoriesMu.Lock()
+	factory, ok := idSchemeFactories[name]
+	idSchemeFactoriesMu.Unlock()
+	if !ok {
+		slog.Warn("Schéma d'identifiants inconnu", "scheme", name, "default", "timestamp")
+		return timestampIDs{}
+	}
+	scheme, err := factory()
+	if err != nil {
+		slog.Error("Schéma d'identifiants invalide", "scheme", name, "error", err, "default", "timestamp")
+		return timestampIDs{}
+	}
+	return scheme
+}
+
+// idUnsafe retire d'un segment d'identifiant les caractères non sûrs dans une URL
+var idUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// IDNamespace préfixe les identifiants générés. ID_PREFIX accepte les
+// variables {node}, {site}, {tenant} (TENANT) et {device}:
+// "{site}.{node}" donne par exemple "edge-site-1.fog-node-1-01J9...".
+type IDNamespace struct {
+	template string
+	node     string
+	site     string
+	tenant   string
+}
+
+// loadIDNamespace lit ID_PREFIX (défaut: "task")
+func loadIDNamespace(nodeID, location string) IDNamespace {
+	return IDNamespace{
+		template: getEnv("ID_PREFIX", "task"),
+		node:     nodeID,
+		site:     location,
+		tenant:   getEnv("TENANT", ""),
+	}
+}
+
+// prefix retourne le préfixe des identifiants d'une tâche
+func (ns IDNamespace) prefix(task *Task) string {
+	safe := func(s string) string { return idUnsafe.ReplaceAllString(s, "_") }
+	return strings.NewReplacer(
+		"{node}", safe(ns.node),
+		"{site}", safe(ns.site),
+		"{tenant}", safe(ns.tenant),
+		"{device}", safe(task.DeviceID),
+	).Replace(ns.template)
+}
+
+// timestampIDs est le schéma historique: préfixe et horodatage en nanosecondes
+type timestampIDs struct{}
+
+func (timestampIDs) Name() string { return "timestamp" }
+
+func (timestampIDs) NewID(fc *FogCompute, task *Task) (string, *Rejection) {
+	return fc.idNamespace.prefix(task) + "-" + strconv.FormatInt(time.Now().UnixNano(), 10), nil
+}
+
+// crockford est l'alphabet base32 des ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidIDs génère des ULID monotones: triables par date de création et uniques
+// sans coordination entre nœuds (80 bits aléatoires)
+type ulidIDs struct {
+	lastMs uint64
+	last   [10]byte // Partie aléatoire du dernier ULID, incrémentée dans la même milliseconde
+	mu     sync.Mutex
+}
+
+func (u *ulidIDs) Name() string { return "ulid" }
+
+func (u *ulidIDs) NewID(fc *FogCompute, task *Task) (string, *Rejection) {
+	return fc.idNamespace.prefix(task) + "-" + u.next(time.Now()), nil
+}
+
+// next retourne le prochain ULID
+func (u *ulidIDs) next(now time.Time) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ms := uint64(now.UnixMilli())
+	if ms <= u.lastMs {
+		// Même milliseconde (ou horloge reculée): incrément de la partie aléatoire
+		ms = u.lastMs
+		for i := len(u.last) - 1; i >= 0; i-- {
+			u.last[i]++
+			if u.last[i] != 0 {
+				break
+			}
+		}
+	} else {
+		rand.Read(u.last[:])
+	}
+	u.lastMs = ms
+
+	var raw [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(raw[:6], ts[2:])
+	copy(raw[6:], u.last[:])
+	return encodeCrockford(raw)
+}
+
+// encodeCrockford encode 128 bits en 26 caractères base32 Crockford
+func encodeCrockford(raw [16]byte) string {
+	out := make([]byte, 26)
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// defaultExternalIDPattern accepte les identifiants sûrs dans une URL
+const defaultExternalIDPattern = `^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`
+
+// externalIDs accepte l'identifiant fourni par le client (champ id), validé
+// par ID_PATTERN et unique sur le nœud. Sans identifiant, un ULID est généré,
+// sauf si ID_EXTERNAL_REQUIRED impose au client d'en fournir un.
+type externalIDs struct {
+	pattern  *regexp.Regexp
+	required bool
+	fallback ulidIDs
+}
+
+func newExternalIDs() (*externalIDs, error) {
+	pattern, err := regexp.Compile(getEnv("ID_PATTERN", defaultExternalIDPattern))
+	if err != nil {
+		return nil, fmt.Errorf("ID_PATTERN: %w", err)
+	}
+	return &externalIDs{pattern: pattern, required: getEnvBool("ID_EXTERNAL_REQUIRED", false)}, nil
+}
+
+func (e *externalIDs) Name() string { return "external" }
+
+func (e *externalIDs) NewID(fc *FogCompute, task *Task) (string, *Rejection) {
+	id := task.ID
+	if id == "" {
+		if e.required {
+			return "", &Rejection{Reason: "Identifiant de tâche requis (champ id)", Status: http.StatusBadRequest}
+		}
+		return e.fallback.NewID(fc, task)
+	}
+	// Même avec un ID_PATTERN permissif, l'identifiant doit rester utilisable dans /tasks/{id}
+	if !e.pattern.MatchString(id) || idUnsafe.MatchString(strings.ReplaceAll(id, ":", "")) || id == "." || id == ".." {
+		return "", &Rejection{Reason: fmt.Sprintf("Identifiant de tâche invalide: %q", id), Status: http.StatusBadRequest}
+	}
+
+	fc.mu.RLock()
+	_, exists := fc.tasks[id]
+	fc.mu.RUnlock()
+	if exists {
+		return "", &Rejection{Reason: fmt.Sprintf("Identifiant de tâche déjà utilisé: %q", id), Status: http.StatusConflict}
+	}
+	return id, nil
+}
@@ -0,0 +1,116 @@
+package fognode
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestULIDMonotonic(t *testing.T) {
+	var u ulidIDs
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	seen := make(map[string]bool)
+	prev := ""
+	for i := 0; i < 10000; i++ {
+		// Plusieurs ULID par milliseconde, et une horloge qui recule
+		at := now.Add(time.Duration(i/100) * time.Millisecond)
+		if i%1000 == 999 {
+			at = at.Add(-time.Second)
+		}
+		id := u.next(at)
+		if len(id) != 26 || strings.Trim(id, crockford) != "" {
+			t.Fatalf("ULID mal formé %q", id)
+		}
+		if id <= prev {
+			t.Fatalf("ULID non croissant: %q après %q", id, prev)
+		}
+		if seen[id] {
+			t.Fatalf("ULID dupliqué %q", id)
+		}
+		seen[id], prev = true, id
+	}
+
+	// Les 10 premiers caractères encodent l'horodatage en millisecondes
+	var fresh ulidIDs
+	if a, b := fresh.next(now), fresh.next(now.Add(time.Millisecond)); a[:10] >= b[:10] {
+		t.Errorf("horodatages %q puis %q", a[:10], b[:10])
+	}
+}
+
+func TestEncodeCrockford(t *testing.T) {
+	var zero, ones [16]byte
+	for i := range ones {
+		ones[i] = 0xff
+	}
+	if got := encodeCrockford(zero); got != strings.Repeat("0", 26) {
+		t.Errorf("encodeCrockford(0) = %q", got)
+	}
+	if got := encodeCrockford(ones); got != "7"+strings.Repeat("Z", 25) {
+		t.Errorf("encodeCrockford(2^128-1) = %q", got)
+	}
+}
+
+func TestIDNamespacePrefix(t *testing.T) {
+	ns := IDNamespace{template: "{tenant}.{site}.{node}.{device}", node: "fog node/1", site: "serre-A", tenant: "usine a?b"}
+	got := ns.prefix(&Task{DeviceID: "th:12/../x"})
+	if want := "usine_a_b.serre-A.fog_node_1.th_12_.._x"; got != want {
+		t.Errorf("prefix = %q, attendu %q", got, want)
+	}
+	if idUnsafe.MatchString(got) {
+		t.Errorf("préfixe %q non sûr", got)
+	}
+	if got := (IDNamespace{template: "task"}).prefix(&Task{}); got != "task" {
+		t.Errorf("prefix sans variable = %q", got)
+	}
+}
+
+func TestExternalIDs(t *testing.T) {
+	fc := &FogCompute{tasks: map[string]*Task{"used": {}}, idNamespace: IDNamespace{template: "task"}}
+	tests := []struct {
+		pattern string
+		id      string
+		status  int // 0: accepté
+	}{
+		{defaultExternalIDPattern, "site:42", 0},
+		{defaultExternalIDPattern, "capteur-7.mesure_3", 0},
+		{defaultExternalIDPattern, "-tiret", http.StatusBadRequest},
+		{defaultExternalIDPattern, "a/b", http.StatusBadRequest},
+		{defaultExternalIDPattern, "avec espace", http.StatusBadRequest},
+		{defaultExternalIDPattern, strings.Repeat("x", 129), http.StatusBadRequest},
+		{defaultExternalIDPattern, "used", http.StatusConflict},
+		// Un ID_PATTERN plus strict restreint les identifiants acceptés
+		{`^site-[0-9]+$`, "site-42", 0},
+		{`^site-[0-9]+$`, "site:42", http.StatusBadRequest},
+		// Un ID_PATTERN permissif ne laisse pas passer ce qui casse /tasks/{id}
+		{`.*`, "a/b", http.StatusBadRequest},
+		{`.*`, "a?b", http.StatusBadRequest},
+		{`.*`, "%2F", http.StatusBadRequest},
+		{`.*`, "é", http.StatusBadRequest},
+		{`.*`, "..", http.StatusBadRequest},
+		{`.*`, "a:b.c", 0},
+	}
+	for _, tt := range tests {
+		e := &externalIDs{pattern: regexp.MustCompile(tt.pattern)}
+		id, rejection := e.NewID(fc, &Task{ID: tt.id})
+		switch {
+		case tt.status == 0 && rejection != nil:
+			t.Errorf("%s / %q refusé: %s", tt.pattern, tt.id, rejection.Reason)
+		case tt.status == 0 && id != tt.id:
+			t.Errorf("%s / %q: identifiant %q", tt.pattern, tt.id, id)
+		case tt.status != 0 && (rejection == nil || rejection.Status != tt.status):
+			t.Errorf("%s / %q: refus %+v, attendu %d", tt.pattern, tt.id, rejection, tt.status)
+		}
+	}
+
+	// Sans identifiant: ULID généré, sauf si ID_EXTERNAL_REQUIRED
+	e := &externalIDs{pattern: regexp.MustCompile(defaultExternalIDPattern)}
+	if id, rejection := e.NewID(fc, &Task{}); rejection != nil || !strings.HasPrefix(id, "task-") || len(id) != len("task-")+26 {
+		t.Errorf("identifiant généré %q (%v)", id, rejection)
+	}
+	e.required = true
+	if _, rejection := e.NewID(fc, &Task{}); rejection == nil || rejection.Status != http.StatusBadRequest {
+		t.Errorf("identifiant absent accepté avec ID_EXTERNAL_REQUIRED: %+v", rejection)
+	}
+}
@@ -0,0 +1,215 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// inferenceTaskType est le type des tâches qui évaluent un modèle ONNX du
+// magasin de modèles du nœud
+const inferenceTaskType = "inference"
+
+// InferenceLimits borne l'évaluation des modèles
+type InferenceLimits struct {
+	Timeout     time.Duration // Plafond de durée d'une évaluation, en plus du timeout de la tâche
+	Threads     int           // Threads d'une évaluation (parallélisme intra-opérateur)
+	MaxModels   int           // Modèles gardés chargés en mémoire
+	MaxElements int           // Éléments d'un tenseur d'entrée ou de sortie
+}
+
+// InferenceTensor est un tenseur d'entrée ou de sortie: ses valeurs à plat,
+// dans l'ordre des lignes. Les entrées sont converties dans le type
+// d'élément déclaré par le modèle.
+type InferenceTensor struct {
+	DType string    `json:"dtype,omitempty"` // Type d'élément (float32, float64, int64...), renseigné en sortie
+	Shape []int64   `json:"shape"`
+	Data  []float64 `json:"data"`
+}
+
+// InferencePayload est le payload d'une tâche inference
+type InferencePayload struct {
+	Model   string                     `json:"model"`             // Modèle du magasin: "nom@version", ou "nom" pour sa dernière version
+	Inputs  map[string]InferenceTensor `json:"inputs"`            // Tenseur de chaque entrée du modèle, par nom
+	Outputs []string                   `json:"outputs,omitempty"` // Sorties retournées; défaut: toutes
+}
+
+// InferenceRuntime évalue un modèle; l'implémentation ONNX Runtime n'existe
+// que dans un binaire construit avec cgo (inference_onnx.go)
+type InferenceRuntime interface {
+	// Check vérifie qu'un fichier est un modèle utilisable, sans le garder chargé
+	Check(modelPath string) error
+	// Inputs retourne les noms des entrées du modèle
+	Inputs(modelPath string) ([]string, error)
+	// Run évalue le modèle et retourne ses sorties, par nom
+	Run(ctx context.Context, modelPath string, inputs map[string]InferenceTensor) (map[string]InferenceTensor, error)
+}
+
+var (
+	inferenceLimits InferenceLimits
+	// inference est le runtime du type inference, nil s'il n'est pas proposé
+	inference InferenceRuntime
+)
+
+// loadInference lit INFERENCE_* et ONNXRUNTIME_LIB et enregistre
+// le handler du type inference. Sans ONNXRUNTIME_LIB (chemin de la
+// bibliothèque partagée onnxruntime), le type n'est pas proposé.
+func loadInference() {
+	inferenceLimits = InferenceLimits{
+		Timeout:     getEnvDuration("INFERENCE_TIMEOUT", 30*time.Second),
+		Threads:     getEnvInt("INFERENCE_THREADS", 1),
+		MaxModels:   getEnvInt("INFERENCE_MAX_MODELS", 8),
+		MaxElements: getEnvInt("INFERENCE_MAX_ELEMENTS", 1<<20),
+	}
+	if inferenceLimits.Timeout <= 0 || inferenceLimits.Threads <= 0 || inferenceLimits.MaxModels <= 0 || inferenceLimits.MaxElements <= 0 {
+		config.errorf("INFERENCE_*", "les limites d'inférence doivent être positives")
+		return
+	}
+	lib := getEnv("ONNXRUNTIME_LIB", "")
+	if lib == "" {
+		return
+	}
+	rt, err := newONNXRuntime(lib, inferenceLimits)
+	if err != nil {
+		config.errorf("ONNXRUNTIME_LIB", "%v", err)
+		return
+	}
+	inference = rt
+	registerHandler(inferenceTaskType, registeredHandler{fn: runInference, version: "1.0.0", source: "builtin"})
+}
+
+// decodeInferencePayload lit le payload d'une tâche inference et résout son
+// modèle: sa version et son fichier
+func decodeInferencePayload(payload map[string]interface{}) (InferencePayload, ModelVersion, string, error) {
+	var p InferencePayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return p, ModelVersion{}, "", err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, ModelVersion{}, "", fmt.Errorf("payload inference invalide: %w", err)
+	}
+	if p.Model == "" {
+		return p, ModelVersion{}, "", errors.New("payload inference: model requis")
+	}
+	if len(p.Inputs) == 0 {
+		return p, ModelVersion{}, "", errors.New("payload inference: inputs requis")
+	}
+	for name, t := range p.Inputs {
+		n := int64(1)
+		for _, d := range t.Shape {
+			if d <= 0 {
+				return p, ModelVersion{}, "", fmt.Errorf("entrée %s: dimensions positives attendues, %v", name, t.Shape)
+			}
+			n *= d
+			if n > int64(inferenceLimits.MaxElements) {
+				return p, ModelVersion{}, "", fmt.Errorf("entrée %s: plus de %d éléments (INFERENCE_MAX_ELEMENTS)", name, inferenceLimits.MaxElements)
+			}
+		}
+		if n != int64(len(t.Data)) {
+			return p, ModelVersion{}, "", fmt.Errorf("entrée %s: %d valeurs pour la forme %v (%d attendues)", name, len(t.Data), t.Shape, n)
+		}
+	}
+	version, path, err := models.Resolve(p.Model)
+	if err != nil {
+		return p, ModelVersion{}, "", err
+	}
+	return p, version, path, nil
+}
+
+// validateInferenceTask refuse dès la soumission une tâche inference dont le
+// modèle est inconnu ou dont les entrées ne correspondent pas à ses tenseurs
+func validateInferenceTask(task *Task) *Rejection {
+	if task.Type != inferenceTaskType {
+		return nil
+	}
+	taskHandlersMu.RLock()
+	h, ok := taskHandlers[inferenceTaskType]
+	taskHandlersMu.RUnlock()
+	if !ok || h.source != "builtin" || inference == nil {
+		return nil // Type non proposé, ou remplacé par un module ou un plugin
+	}
+	p, _, path, err := decodeInferencePayload(task.Payload)
+	if err == nil {
+		err = checkInferenceInputs(p, path)
+	}
+	if err != nil {
+		return &Rejection{Reason: err.Error(), Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// checkInferenceInputs vérifie que le payload fournit toutes les entrées du
+// modèle, et elles seules
+func checkInferenceInputs(p InferencePayload, path string) error {
+	names, err := inference.Inputs(path)
+	if err != nil {
+		return fmt.Errorf("modèle %s: %w", p.Model, err)
+	}
+	for _, name := range names {
+		if _, ok := p.Inputs[name]; !ok {
+			return fmt.Errorf("modèle %s: entrée %s requise (entrées: %v)", p.Model, name, names)
+		}
+	}
+	if len(p.Inputs) != len(names) {
+		return fmt.Errorf("modèle %s: entrées inconnues (entrées: %v)", p.Model, names)
+	}
+	return nil
+}
+
+// runInference évalue le modèle d'une tâche inference sur les tenseurs du
+// payload et retourne ses sorties (toutes, ou celles de outputs), avec la
+// version évaluée. Le modèle reste chargé pour les tâches suivantes tant que
+// son fichier ne change pas.
+func runInference(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	p, version, path, err := decodeInferencePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInferenceInputs(p, path); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, inferenceLimits.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	outputs, err := inference.Run(ctx, path, p.Inputs)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inférence %s: %w", p.Model, err)
+	}
+	if len(p.Outputs) > 0 {
+		selected := make(map[string]InferenceTensor, len(p.Outputs))
+		for _, name := range p.Outputs {
+			t, ok := outputs[name]
+			if !ok {
+				return nil, fmt.Errorf("modèle %s: sortie %s inconnue", p.Model, name)
+			}
+			selected[name] = t
+		}
+		outputs = selected
+	}
+	for name, t := range outputs {
+		for _, v := range t.Data {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("modèle %s: sortie %s non finie", p.Model, name)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"operation":   inferenceTaskType,
+		"status":      "success",
+		"model":       version.Name,
+		"version":     version.Version,
+		"sha256":      version.SHA256,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"outputs":     outputs,
+	}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package fognode
+
+import "errors"
+
+// newONNXRuntime échoue: la bibliothèque onnxruntime ne se charge que dans
+// un binaire construit avec cgo
+func newONNXRuntime(lib string, limits InferenceLimits) (InferenceRuntime, error) {
+	return nil, errors.New("runtime ONNX indisponible: binaire construit sans cgo (CGO_ENABLED=0)")
+}
@@ -0,0 +1,299 @@
+//go:build cgo
+
+package fognode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// onnxModel est un modèle chargé; il reste utilisable par les évaluations
+// en cours après son remplacement et n'est libéré qu'à la fin de la dernière
+type onnxModel struct {
+	session  *ort.DynamicAdvancedSession
+	inputs   []ort.InputOutputInfo
+	outputs  []ort.InputOutputInfo
+	modTime  time.Time
+	size     int64
+	lastUsed time.Time
+	users    int
+	retired  bool
+}
+
+// onnxRuntime évalue les modèles avec la bibliothèque partagée onnxruntime
+type onnxRuntime struct {
+	limits InferenceLimits
+	mu     sync.Mutex
+	loaded map[string]*onnxModel // Fichier du modèle → modèle chargé
+}
+
+// newONNXRuntime charge la bibliothèque partagée onnxruntime
+func newONNXRuntime(lib string, limits InferenceLimits) (InferenceRuntime, error) {
+	ort.SetSharedLibraryPath(lib)
+	if err := ort.InitializeEnvironment(); err != nil {
+		return nil, fmt.Errorf("chargement de %s: %w", lib, err)
+	}
+	return &onnxRuntime{limits: limits, loaded: make(map[string]*onnxModel)}, nil
+}
+
+// acquire retourne le modèle chargé d'un fichier, chargé ou rechargé si le
+// fichier a changé; release doit être appelé à la fin de son utilisation
+func (rt *onnxRuntime) acquire(path string) (*onnxModel, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if m, ok := rt.loaded[path]; ok {
+		if m.modTime.Equal(info.ModTime()) && m.size == info.Size() {
+			m.users++
+			m.lastUsed = time.Now()
+			return m, nil
+		}
+		rt.retireLocked(path, m)
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer options.Destroy()
+	if err := options.SetIntraOpNumThreads(rt.limits.Threads); err != nil {
+		return nil, err
+	}
+	if err := options.SetInterOpNumThreads(1); err != nil {
+		return nil, err
+	}
+	session, err := ort.NewDynamicAdvancedSession(path, ioNames(inputs), ioNames(outputs), options)
+	if err != nil {
+		return nil, err
+	}
+	m := &onnxModel{
+		session:  session,
+		inputs:   inputs,
+		outputs:  outputs,
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		lastUsed: time.Now(),
+		users:    1,
+	}
+	rt.loaded[path] = m
+	for len(rt.loaded) > rt.limits.MaxModels {
+		oldestPath, oldest := "", (*onnxModel)(nil)
+		for p, candidate := range rt.loaded {
+			if candidate != m && (oldest == nil || candidate.lastUsed.Before(oldest.lastUsed)) {
+				oldestPath, oldest = p, candidate
+			}
+		}
+		rt.retireLocked(oldestPath, oldest)
+	}
+	return m, nil
+}
+
+// retireLocked retire un modèle chargé, libéré dès qu'il n'est plus utilisé;
+// rt.mu doit être détenu
+func (rt *onnxRuntime) retireLocked(path string, m *onnxModel) {
+	delete(rt.loaded, path)
+	m.retired = true
+	if m.users == 0 {
+		m.session.Destroy()
+	}
+}
+
+// release signale la fin d'une utilisation d'un modèle
+func (rt *onnxRuntime) release(m *onnxModel) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	m.users--
+	if m.retired && m.users == 0 {
+		m.session.Destroy()
+	}
+}
+
+// Check vérifie qu'un fichier est un modèle lisible par onnxruntime
+func (rt *onnxRuntime) Check(path string) error {
+	_, _, err := ort.GetInputOutputInfo(path)
+	return err
+}
+
+// Inputs retourne les noms des entrées d'un modèle
+func (rt *onnxRuntime) Inputs(path string) ([]string, error) {
+	m, err := rt.acquire(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rt.release(m)
+	return ioNames(m.inputs), nil
+}
+
+// Run évalue un modèle; l'évaluation est interrompue à l'annulation du contexte
+func (rt *onnxRuntime) Run(ctx context.Context, path string, inputs map[string]InferenceTensor) (map[string]InferenceTensor, error) {
+	m, err := rt.acquire(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rt.release(m)
+
+	values := make([]ort.Value, 0, len(m.inputs))
+	defer func() {
+		for _, v := range values {
+			v.Destroy()
+		}
+	}()
+	for _, info := range m.inputs {
+		v, err := newInputTensor(info, inputs[info.Name])
+		if err != nil {
+			return nil, fmt.Errorf("entrée %s: %w", info.Name, err)
+		}
+		values = append(values, v)
+	}
+	outputs := make([]ort.Value, len(m.outputs)) // Allouées par onnxruntime
+	defer func() {
+		for _, v := range outputs {
+			if v != nil {
+				v.Destroy()
+			}
+		}
+	}()
+
+	runOptions, err := ort.NewRunOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer runOptions.Destroy()
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			runOptions.Terminate()
+		case <-done:
+		}
+	}()
+	err = m.session.RunWithOptions(values, outputs, runOptions)
+	close(done)
+	<-stopped // runOptions n'est plus utilisé quand il est libéré
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]InferenceTensor, len(outputs))
+	for i, v := range outputs {
+		t, err := outputTensor(v, rt.limits.MaxElements)
+		if err != nil {
+			return nil, fmt.Errorf("sortie %s: %w", m.outputs[i].Name, err)
+		}
+		result[m.outputs[i].Name] = t
+	}
+	return result, nil
+}
+
+// ioNames retourne les noms des entrées ou des sorties d'un modèle
+func ioNames(infos []ort.InputOutputInfo) []string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	return names
+}
+
+// newInputTensor crée le tenseur d'une entrée dans le type d'élément déclaré
+// par le modèle, après vérification de sa forme
+func newInputTensor(info ort.InputOutputInfo, t InferenceTensor) (ort.Value, error) {
+	if info.OrtValueType != ort.ONNXTypeTensor {
+		return nil, fmt.Errorf("entrée de type %v non supportée", info.OrtValueType)
+	}
+	if len(info.Dimensions) != len(t.Shape) {
+		return nil, fmt.Errorf("forme %v, le modèle attend %v", t.Shape, info.Dimensions)
+	}
+	for i, d := range info.Dimensions {
+		if d > 0 && d != t.Shape[i] { // Dimension négative: libre
+			return nil, fmt.Errorf("forme %v, le modèle attend %v", t.Shape, info.Dimensions)
+		}
+	}
+	shape := ort.NewShape(t.Shape...)
+	switch info.DataType {
+	case ort.TensorElementDataTypeFloat:
+		return ort.NewTensor(shape, convertElements[float32](t.Data))
+	case ort.TensorElementDataTypeDouble:
+		return ort.NewTensor(shape, t.Data)
+	case ort.TensorElementDataTypeInt64:
+		return ort.NewTensor(shape, convertElements[int64](t.Data))
+	case ort.TensorElementDataTypeInt32:
+		return ort.NewTensor(shape, convertElements[int32](t.Data))
+	case ort.TensorElementDataTypeUint8:
+		return ort.NewTensor(shape, convertElements[uint8](t.Data))
+	case ort.TensorElementDataTypeInt8:
+		return ort.NewTensor(shape, convertElements[int8](t.Data))
+	case ort.TensorElementDataTypeBool:
+		data := make([]bool, len(t.Data))
+		for i, v := range t.Data {
+			data[i] = v != 0
+		}
+		return ort.NewTensor(shape, data)
+	}
+	return nil, fmt.Errorf("type d'élément %s non supporté", info.DataType)
+}
+
+// convertElements convertit les valeurs JSON d'un tenseur dans un type d'élément
+func convertElements[T float32 | int64 | int32 | uint8 | int8](data []float64) []T {
+	out := make([]T, len(data))
+	for i, v := range data {
+		out[i] = T(v)
+	}
+	return out
+}
+
+// outputTensor convertit une sortie du modèle en tenseur JSON
+func outputTensor(v ort.Value, maxElements int) (InferenceTensor, error) {
+	shape := v.GetShape()
+	if shape.FlattenedSize() > int64(maxElements) {
+		return InferenceTensor{}, fmt.Errorf("plus de %d éléments (INFERENCE_MAX_ELEMENTS)", maxElements)
+	}
+	t := InferenceTensor{Shape: []int64(shape.Clone())}
+	switch v := v.(type) {
+	case *ort.Tensor[float32]:
+		t.DType, t.Data = "float32", widenElements(v.GetData())
+	case *ort.Tensor[float64]:
+		t.DType, t.Data = "float64", widenElements(v.GetData())
+	case *ort.Tensor[int64]:
+		t.DType, t.Data = "int64", widenElements(v.GetData())
+	case *ort.Tensor[int32]:
+		t.DType, t.Data = "int32", widenElements(v.GetData())
+	case *ort.Tensor[uint8]:
+		t.DType, t.Data = "uint8", widenElements(v.GetData())
+	case *ort.Tensor[int8]:
+		t.DType, t.Data = "int8", widenElements(v.GetData())
+	case *ort.Tensor[bool]:
+		t.DType = "bool"
+		for _, b := range v.GetData() {
+			if b {
+				t.Data = append(t.Data, 1)
+			} else {
+				t.Data = append(t.Data, 0)
+			}
+		}
+	default:
+		return InferenceTensor{}, fmt.Errorf("valeur de type %v non supportée", v.GetONNXType())
+	}
+	return t, nil
+}
+
+// widenElements convertit les éléments d'un tenseur de sortie en float64
+func widenElements[T float32 | float64 | int64 | int32 | uint8 | int8](data []T) []float64 {
+	out := make([]float64, len(data))
+	for i, v := range data {
+		out[i] = float64(v)
+	}
+	return out
+}
@@ -0,0 +1,275 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// intervalCounters sont les compteurs cumulés suivis par intervalle
+var intervalCounters = []string{
+	"processed", "rejected", "failed", "timed_out", "retried", "offloaded",
+	"redirected", "deferred", "deadlines_met", "deadlines_missed", "expired_dropped",
+}
+
+// defaultMetricWindows sont les fenêtres glissantes exposées dans /metrics
+const defaultMetricWindows = "1m,5m,15m"
+
+// maxDeltaConsumers borne le nombre de consommateurs de /metrics/delta suivis
+const maxDeltaConsumers = 256
+
+// CounterSample est un relevé des compteurs cumulés. Les compteurs ne
+// décroissent jamais pendant la vie du processus; ils repartent de zéro au
+// redémarrage, que StartedAt permet de détecter.
+type CounterSample struct {
+	At         time.Time        `json:"at"`
+	Counts     map[string]int64 `json:"counts"`
+	LatencySum time.Duration    `json:"-"` // Somme des latences des tâches réussies
+}
+
+// IntervalCounts est l'accroissement des compteurs sur un intervalle
+type IntervalCounts struct {
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Seconds      float64            `json:"seconds"`
+	Counts       map[string]int64   `json:"counts"`
+	Rates        map[string]float64 `json:"rates_per_second"`
+	AvgLatencyMs float64            `json:"avg_latency_ms"` // Moyenne exacte sur l'intervalle (tâches réussies)
+	// Reset signale que l'intervalle part du démarrage du processus: premier
+	// relevé de ce consommateur, ou redémarrage depuis le précédent
+	Reset bool `json:"reset,omitempty"`
+	// Partial signale une fenêtre plus longue que l'historique disponible
+	Partial bool `json:"partial,omitempty"`
+}
+
+// metricWindow est une fenêtre glissante, nommée comme dans METRICS_WINDOWS
+type metricWindow struct {
+	name string
+	d    time.Duration
+}
+
+// deltaCursor est le dernier relevé servi à un consommateur de /metrics/delta
+type deltaCursor struct {
+	sample CounterSample
+	seen   time.Time
+}
+
+// MetricIntervals conserve un historique des compteurs cumulés (un relevé
+// toutes les METRICS_SAMPLE_INTERVAL) pour en dériver des comptes par
+// intervalle, sans base de séries temporelles côté tableau de bord
+type MetricIntervals struct {
+	startedAt time.Time
+	step      time.Duration
+	windows   []metricWindow
+	samples   []CounterSample // Du plus ancien au plus récent
+	maxSample int
+	consumers map[string]*deltaCursor
+	mu        sync.Mutex
+}
+
+// NewMetricIntervals lit METRICS_SAMPLE_INTERVAL et METRICS_WINDOWS
+func NewMetricIntervals(startedAt time.Time) *MetricIntervals {
+	step := getEnvDuration("METRICS_SAMPLE_INTERVAL", 10*time.Second)
+	if step <= 0 {
+		step = 10 * time.Second
+	}
+	names := getEnvList("METRICS_WINDOWS")
+	if len(names) == 0 {
+		names = strings.Split(defaultMetricWindows, ",")
+	}
+	windows := make([]metricWindow, 0, len(names))
+	longest := time.Duration(0)
+	for _, name := range names {
+		d, err := time.ParseDuration(name)
+		if err != nil || d <= 0 {
+			continue
+		}
+		windows = append(windows, metricWindow{name: name, d: d})
+		if d > longest {
+			longest = d
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].d < windows[j].d })
+
+	return &MetricIntervals{
+		startedAt: startedAt,
+		step:      step,
+		windows:   windows,
+		maxSample: int(longest/step) + 2,
+		consumers: make(map[string]*deltaCursor),
+	}
+}
+
+// Record ajoute un relevé à l'historique
+func (mi *MetricIntervals) Record(s CounterSample) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.samples = append(mi.samples, s)
+	if len(mi.samples) > mi.maxSample {
+		mi.samples = mi.samples[len(mi.samples)-mi.maxSample:]
+	}
+}
+
+// Run relève les compteurs à chaque pas jusqu'à l'arrêt du nœud
+func (mi *MetricIntervals) Run(ctx context.Context, sample func() CounterSample) {
+	mi.Record(sample())
+	ticker := time.NewTicker(mi.step)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mi.Record(sample())
+		}
+	}
+}
+
+// delta calcule l'accroissement entre deux relevés
+func delta(from, to CounterSample) IntervalCounts {
+	ic := IntervalCounts{
+		From:    from.At,
+		To:      to.At,
+		Seconds: to.At.Sub(from.At).Seconds(),
+		Counts:  make(map[string]int64, len(intervalCounters)),
+		Rates:   make(map[string]float64, len(intervalCounters)),
+	}
+	for _, name := range intervalCounters {
+		n := to.Counts[name] - from.Counts[name]
+		if n < 0 {
+			n = 0 // Impossible tant que les compteurs restent monotones
+		}
+		ic.Counts[name] = n
+		if ic.Seconds > 0 {
+			ic.Rates[name] = float64(n) / ic.Seconds
+		}
+	}
+	if processed := ic.Counts["processed"]; processed > 0 {
+		ic.AvgLatencyMs = float64((to.LatencySum - from.LatencySum).Milliseconds()) / float64(processed)
+	}
+	return ic
+}
+
+// zeroSample est le relevé implicite au démarrage du processus
+func (mi *MetricIntervals) zeroSample() CounterSample {
+	return CounterSample{At: mi.startedAt, Counts: map[string]int64{}}
+}
+
+// Windows retourne l'accroissement des compteurs sur chaque fenêtre glissante
+// se terminant au relevé courant
+func (mi *MetricIntervals) Windows(current CounterSample) map[string]IntervalCounts {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	out := make(map[string]IntervalCounts, len(mi.windows))
+	for _, w := range mi.windows {
+		cutoff := current.At.Add(-w.d)
+		from := mi.zeroSample()
+		if len(mi.samples) > 0 && !mi.startedAt.After(cutoff) {
+			from = mi.samples[0]
+		}
+		// Le relevé le plus récent antérieur au début de la fenêtre
+		for _, s := range mi.samples {
+			if s.At.After(cutoff) {
+				break
+			}
+			from = s
+		}
+		ic := delta(from, current)
+		ic.Partial = from.At.After(cutoff)
+		out[w.name] = ic
+	}
+	return out
+}
+
+// Since retourne l'accroissement des compteurs depuis le précédent appel du
+// même consommateur, puis avance son curseur. Au premier appel (ou après un
+// redémarrage), l'intervalle part du démarrage et Reset est positionné.
+func (mi *MetricIntervals) Since(consumer string, current CounterSample) IntervalCounts {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	cursor, ok := mi.consumers[consumer]
+	if !ok {
+		mi.evictLocked()
+		cursor = &deltaCursor{sample: mi.zeroSample()}
+		mi.consumers[consumer] = cursor
+	}
+	ic := delta(cursor.sample, current)
+	ic.Reset = !ok
+	cursor.sample = current
+	cursor.seen = current.At
+	return ic
+}
+
+// evictLocked oublie le consommateur le plus ancien quand la limite est
+// atteinte; mi.mu doit être détenu
+func (mi *MetricIntervals) evictLocked() {
+	if len(mi.consumers) < maxDeltaConsumers {
+		return
+	}
+	oldest := ""
+	for name, c := range mi.consumers {
+		if oldest == "" || c.seen.Before(mi.consumers[oldest].seen) {
+			oldest = name
+		}
+	}
+	delete(mi.consumers, oldest)
+}
+
+// counterSample relève les compteurs cumulés du nœud
+func (fc *FogCompute) counterSample() CounterSample {
+	fc.metrics.mu.RLock()
+	defer fc.metrics.mu.RUnlock()
+	return CounterSample{
+		At: time.Now(),
+		Counts: map[string]int64{
+			"processed":        int64(fc.metrics.TasksProcessed),
+			"rejected":         int64(fc.metrics.TasksRejected),
+			"failed":           int64(fc.metrics.TasksFailed),
+			"timed_out":        int64(fc.metrics.TasksTimedOut),
+			"retried":          int64(fc.metrics.TasksRetried),
+			"offloaded":        int64(fc.metrics.TasksOffloaded),
+			"redirected":       int64(fc.metrics.TasksRedirected),
+			"deferred":         int64(fc.metrics.TasksDeferred),
+			"deadlines_met":    int64(fc.metrics.DeadlinesMet),
+			"deadlines_missed": int64(fc.metrics.DeadlinesMissed),
+			"expired_dropped":  int64(fc.metrics.ExpiredDropped),
+		},
+		LatencySum: fc.metrics.LatencySum,
+	}
+}
+
+// MetricsDelta est la réponse de GET /metrics/delta
+type MetricsDelta struct {
+	NodeID     string           `json:"node_id"`
+	Consumer   string           `json:"consumer"`
+	StartedAt  time.Time        `json:"started_at"` // Démarrage du processus: remise à zéro des cumuls
+	Cumulative map[string]int64 `json:"cumulative"`
+	Interval   IntervalCounts   `json:"interval"`
+}
+
+// handleGetMetricsDelta retourne les cumuls et leur accroissement depuis le
+// précédent relevé du consommateur (?consumer=, défaut: adresse du client)
+func (fc *FogCompute) handleGetMetricsDelta(w http.ResponseWriter, r *http.Request) {
+	consumer := r.URL.Query().Get("consumer")
+	if consumer == "" {
+		consumer, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	current := fc.counterSample()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MetricsDelta{
+		NodeID:     fc.node.ID,
+		Consumer:   consumer,
+		StartedAt:  fc.startedAt,
+		Cumulative: current.Counts,
+		Interval:   fc.intervals.Since(consumer, current),
+	})
+}
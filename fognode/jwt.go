@@ -0,0 +1,427 @@
+package fognode
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksMinRefetch espace les rechargements du JWKS déclenchés par un kid inconnu
+const jwksMinRefetch = 30 * time.Second
+
+// JWTAuth valide les jetons "Authorization: Bearer" d'un fournisseur OIDC:
+// signature contre les clés du JWKS, émetteur, audience et validité. Une
+// revendication du jeton (sub par défaut) donne l'identité du client.
+type JWTAuth struct {
+	issuer        string
+	audience      string
+	jwksURL       string
+	identityClaim string
+	scopesClaim   string
+	scopePrefix   string
+	defaultScopes map[string]bool
+	leeway        time.Duration
+	refresh       time.Duration
+	client        *http.Client
+
+	keys      map[string]crypto.PublicKey // Clés du JWKS par kid
+	fetchedAt time.Time
+	fetchErr  string
+	rejected  map[string]int64 // Jetons refusés par motif
+	mu        sync.Mutex
+}
+
+// JWTStats décrit la validation des jetons
+type JWTStats struct {
+	Issuer    string           `json:"issuer,omitempty"`
+	JWKSURL   string           `json:"jwks_url,omitempty"`
+	Keys      int              `json:"keys"`
+	FetchedAt *time.Time       `json:"fetched_at,omitempty"`
+	LastError string           `json:"last_error,omitempty"`
+	Rejected  map[string]int64 `json:"rejected"`
+}
+
+// NewJWTAuth lit la configuration OIDC_*; retourne nil sans OIDC_ISSUER ni
+// OIDC_JWKS_URL. OIDC_AUDIENCE est obligatoire: sans contrôle de l'audience,
+// un jeton émis par le fournisseur pour n'importe quelle autre application
+// serait accepté.
+func NewJWTAuth() *JWTAuth {
+	issuer := strings.TrimSuffix(getEnv("OIDC_ISSUER", ""), "/")
+	jwksURL := getEnv("OIDC_JWKS_URL", "")
+	if issuer == "" && jwksURL == "" {
+		return nil
+	}
+	if getEnv("OIDC_AUDIENCE", "") == "" {
+		config.errorf("OIDC_AUDIENCE", "audience requise avec OIDC_ISSUER ou OIDC_JWKS_URL")
+		return nil
+	}
+	if issuer == "" {
+		slog.Warn("OIDC_ISSUER absent: l'émetteur des jetons n'est pas vérifié", "jwks_url", jwksURL)
+	}
+
+	j := &JWTAuth{
+		issuer:        issuer,
+		audience:      getEnv("OIDC_AUDIENCE", ""),
+		jwksURL:       jwksURL,
+		identityClaim: getEnv("OIDC_IDENTITY_CLAIM", "sub"),
+		scopesClaim:   getEnv("OIDC_SCOPES_CLAIM", "scope"),
+		scopePrefix:   getEnv("OIDC_SCOPE_PREFIX", ""),
+		defaultScopes: make(map[string]bool),
+		leeway:        getEnvDuration("OIDC_CLOCK_LEEWAY", 30*time.Second),
+		refresh:       getEnvDuration("OIDC_JWKS_REFRESH", 10*time.Minute),
+		client:        &http.Client{Timeout: getEnvDuration("OIDC_TIMEOUT", 5*time.Second)},
+		keys:          make(map[string]crypto.PublicKey),
+		rejected:      make(map[string]int64),
+	}
+	for _, scope := range strings.Split(getEnv("OIDC_DEFAULT_SCOPES", "read+submit"), "+") {
+		if !grantScope(j.defaultScopes, scope) {
+			slog.Warn("Portée OIDC_DEFAULT_SCOPES inconnue ignorée", "scope", scope)
+		}
+	}
+	slog.Info("Authentification par jeton OIDC activée", "issuer", issuer, "jwks_url", jwksURL, "audience", j.audience)
+	return j
+}
+
+// jwtHeader est l'en-tête d'un jeton JWS compact
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Authenticate valide le jeton et retourne l'identité qu'il porte
+func (j *JWTAuth) Authenticate(ctx context.Context, token string) (*ClientIdentity, error) {
+	identity, reason, err := j.verify(ctx, token)
+	if err != nil {
+		j.mu.Lock()
+		j.rejected[reason]++
+		j.mu.Unlock()
+		return nil, err
+	}
+	return identity, nil
+}
+
+// verify contrôle le jeton; reason classe le refus pour les statistiques
+func (j *JWTAuth) verify(ctx context.Context, token string) (*ClientIdentity, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, "malformed", errors.New("jeton JWT mal formé")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, "malformed", fmt.Errorf("en-tête JWT illisible: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "malformed", fmt.Errorf("signature JWT illisible: %w", err)
+	}
+
+	key, err := j.key(ctx, header.Kid)
+	if err != nil {
+		return nil, "unknown_key", err
+	}
+	if err := verifyJWS(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, "signature", err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, "malformed", fmt.Errorf("revendications JWT illisibles: %w", err)
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(j.leeway)) {
+		return nil, "expired", errors.New("jeton expiré ou sans expiration")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(j.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, "not_yet_valid", errors.New("jeton pas encore valide")
+	}
+	if j.issuer != "" && strings.TrimSuffix(claimString(claims["iss"]), "/") != j.issuer {
+		return nil, "issuer", fmt.Errorf("émetteur %q inattendu", claimString(claims["iss"]))
+	}
+	if !claimContains(claims["aud"], j.audience) {
+		return nil, "audience", fmt.Errorf("audience %s absente du jeton", j.audience)
+	}
+
+	name := claimString(claims[j.identityClaim])
+	if name == "" {
+		return nil, "identity", fmt.Errorf("revendication %s absente du jeton", j.identityClaim)
+	}
+	identity := &ClientIdentity{Name: name, Method: "jwt", scopes: make(map[string]bool)}
+	for _, scope := range claimStrings(claims[j.scopesClaim]) {
+		if strings.HasPrefix(scope, j.scopePrefix) {
+			grantScope(identity.scopes, strings.TrimPrefix(scope, j.scopePrefix))
+		}
+	}
+	if len(identity.scopes) == 0 {
+		for scope := range j.defaultScopes {
+			identity.scopes[scope] = true
+		}
+	}
+	return identity, "", nil
+}
+
+// key retourne la clé publique d'identifiant kid, en rechargeant le JWKS
+// quand il est périmé ou que le kid est inconnu (rotation des clés)
+func (j *JWTAuth) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > j.refresh
+	if ok && !stale {
+		return key, nil
+	}
+	if stale || time.Since(j.fetchedAt) > jwksMinRefetch {
+		if err := j.fetchLocked(ctx); err != nil {
+			j.fetchErr = err.Error()
+			slog.Warn("Chargement du JWKS impossible", "jwks_url", j.jwksURL, "error", err)
+		} else {
+			j.fetchErr = ""
+		}
+		// Après un échec, les clés connues restent utilisées jusqu'au prochain essai
+		j.fetchedAt = time.Now()
+	}
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("clé %q absente du JWKS", kid)
+}
+
+// fetchLocked charge le JWKS, après avoir découvert son URL auprès de
+// l'émetteur si OIDC_JWKS_URL n'est pas fourni; j.mu doit être détenu
+func (j *JWTAuth) fetchLocked(ctx context.Context) error {
+	if j.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := j.getJSON(ctx, j.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return fmt.Errorf("découverte OIDC: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return errors.New("découverte OIDC: jwks_uri absent")
+		}
+		j.jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := j.getJSON(ctx, j.jwksURL, &set); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			slog.Warn("Clé du JWKS ignorée", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("aucune clé de signature utilisable dans le JWKS")
+	}
+	j.keys = keys
+	return nil
+}
+
+// getJSON lit le document JSON d'une URL
+func (j *JWTAuth) getJSON(ctx context.Context, url string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, j.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: statut %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// Stats retourne l'état de la validation des jetons
+func (j *JWTAuth) Stats() JWTStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stats := JWTStats{
+		Issuer:    j.issuer,
+		JWKSURL:   j.jwksURL,
+		Keys:      len(j.keys),
+		LastError: j.fetchErr,
+		Rejected:  make(map[string]int64, len(j.rejected)),
+	}
+	if !j.fetchedAt.IsZero() {
+		fetchedAt := j.fetchedAt
+		stats.FetchedAt = &fetchedAt
+	}
+	for reason, n := range j.rejected {
+		stats.Rejected[reason] = n
+	}
+	return stats
+}
+
+// jsonWebKey est une clé publique RSA ou EC d'un JWKS
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey construit la clé publique décrite par le JWK
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("courbe %q non supportée", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point hors de la courbe")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("type de clé %q non supporté", k.Kty)
+	}
+}
+
+// verifyJWS vérifie la signature d'un jeton; seuls RS256/384/512 et
+// ES256/384 sont acceptés ("none" et HMAC sont refusés)
+func verifyJWS(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var h hash.Hash
+	var id crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		h, id = sha256.New(), crypto.SHA256
+	case "RS384", "ES384":
+		h, id = sha512.New384(), crypto.SHA384
+	case "RS512":
+		h, id = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("algorithme %q non supporté", alg)
+	}
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			return fmt.Errorf("algorithme %s incompatible avec une clé RSA", alg)
+		}
+		return rsa.VerifyPKCS1v15(pub, id, digest, signature)
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(signature) != 2*size {
+			return fmt.Errorf("signature %s invalide pour la courbe %s", alg, pub.Curve.Params().Name)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature ECDSA invalide")
+		}
+		return nil
+	default:
+		return errors.New("type de clé inattendu")
+	}
+}
+
+// decodeJWTPart décode une partie base64url JSON d'un jeton
+func decodeJWTPart(part string, out interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// claimString retourne une revendication textuelle (ou numérique)
+func claimString(v interface{}) string {
+	switch c := v.(type) {
+	case string:
+		return c
+	case float64:
+		return fmt.Sprintf("%.0f", c)
+	default:
+		return ""
+	}
+}
+
+// claimStrings retourne une revendication liste, ou chaîne séparée par des
+// espaces (scope OAuth2)
+func claimStrings(v interface{}) []string {
+	switch c := v.(type) {
+	case string:
+		return strings.Fields(c)
+	case []interface{}:
+		values := make([]string, 0, len(c))
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// claimContains indique si la revendication (chaîne ou liste) contient value
+func claimContains(v interface{}, value string) bool {
+	for _, s := range claimStrings(v) {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
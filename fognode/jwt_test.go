@@ -0,0 +1,89 @@
+package fognode
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signTestJWT signe des revendications en ES256 avec la clé kid "k1"
+func signTestJWT(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(jwtHeader{Alg: "ES256", Kid: "k1"})
+	body, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAuthAudience(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &JWTAuth{
+		audience:      "fog-nodes",
+		identityClaim: "sub",
+		scopesClaim:   "scope",
+		defaultScopes: map[string]bool{ScopeRead: true},
+		refresh:       time.Hour,
+		fetchedAt:     time.Now(),
+		keys:          map[string]crypto.PublicKey{"k1": &key.PublicKey},
+		rejected:      make(map[string]int64),
+	}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name   string
+		aud    interface{}
+		reason string
+	}{
+		{"audience du nœud", "fog-nodes", ""},
+		{"audience dans une liste", []string{"autre-app", "fog-nodes"}, ""},
+		{"autre application", "autre-app", "audience"},
+		{"sans audience", nil, "audience"},
+	}
+	for _, tt := range tests {
+		claims := map[string]interface{}{"sub": "passerelle-1", "exp": exp}
+		if tt.aud != nil {
+			claims["aud"] = tt.aud
+		}
+		identity, reason, err := j.verify(context.Background(), signTestJWT(t, key, claims))
+		if reason != tt.reason {
+			t.Errorf("%s: motif %q (%v), attendu %q", tt.name, reason, err, tt.reason)
+		}
+		if tt.reason == "" && (identity == nil || identity.Name != "passerelle-1") {
+			t.Errorf("%s: identité %+v", tt.name, identity)
+		}
+	}
+}
+
+func TestNewJWTAuthRequiresAudience(t *testing.T) {
+	saved := config
+	config = &ConfigFile{}
+	defer func() { config = saved }()
+
+	t.Setenv("OIDC_JWKS_URL", "https://idp.example/jwks")
+	t.Setenv("OIDC_AUDIENCE", "")
+	if j := NewJWTAuth(); j != nil {
+		t.Fatal("OIDC sans OIDC_AUDIENCE accepté")
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "OIDC_AUDIENCE") {
+		t.Errorf("erreur de configuration attendue, obtenu %v", err)
+	}
+}
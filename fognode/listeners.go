@@ -0,0 +1,133 @@
+package fognode
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listener associe un serveur HTTP à la manière d'ouvrir son socket
+type listener struct {
+	name   string
+	addr   string
+	server *http.Server
+	listen func() (net.Listener, error)
+}
+
+// transport retourne le schéma sous lequel le listener sert l'API
+func (l *listener) transport() string {
+	switch {
+	case strings.HasPrefix(l.addr, "unix:"):
+		return "http+unix"
+	case nodeTLS.Enabled():
+		return "https"
+	default:
+		return "http"
+	}
+}
+
+// listenerTransports retourne les schémas distincts des listeners, annoncés
+// dans /capabilities
+func listenerTransports(listeners []*listener) []string {
+	seen := make(map[string]bool)
+	transports := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		if t := l.transport(); !seen[t] {
+			seen[t] = true
+			transports = append(transports, t)
+		}
+	}
+	return transports
+}
+
+// tcpListener sert le handler sur une adresse TCP, en TLS si TLS_CERT_FILE
+// est défini
+func tcpListener(name, addr string, handler http.Handler) *listener {
+	return &listener{
+		name:   name,
+		addr:   addr,
+		server: newHTTPServer(addr, handler),
+		listen: func() (net.Listener, error) {
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			return nodeTLS.listen(ln), nil
+		},
+	}
+}
+
+// unixListener sert le handler sur un socket Unix, pour les processus
+// co-localisés (sidecars) qui évitent ainsi la pile TCP et le pare-feu
+func unixListener(name, path string, mode os.FileMode, handler http.Handler) *listener {
+	return &listener{
+		name:   name,
+		addr:   "unix:" + path,
+		server: newHTTPServer("", handler),
+		listen: func() (net.Listener, error) {
+			// Un socket laissé par une exécution précédente empêcherait le bind
+			if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+				os.Remove(path)
+			}
+			ln, err := net.Listen("unix", path)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				ln.Close()
+				return nil, err
+			}
+			return ln, nil
+		},
+	}
+}
+
+// parseFileMode lit un mode de fichier octal (ex: "0660")
+func parseFileMode(s string, def os.FileMode) os.FileMode {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		slog.Warn("Mode de fichier invalide", "value", s, "default", def.String())
+		return def
+	}
+	return os.FileMode(m)
+}
+
+// serveAll ouvre tous les sockets (échec fatal si l'un d'eux ne peut pas
+// l'être), puis sert chaque listener jusqu'à son arrêt
+func serveAll(listeners []*listener) {
+	sockets := make([]net.Listener, len(listeners))
+	for i, l := range listeners {
+		ln, err := l.listen()
+		if err != nil {
+			fatal("Ouverture du listener impossible", "listener", l.name, "addr", l.addr, "error", err)
+		}
+		sockets[i] = ln
+	}
+
+	var wg sync.WaitGroup
+	for i, l := range listeners {
+		wg.Add(1)
+		go func(l *listener, ln net.Listener) {
+			defer wg.Done()
+			slog.Info("Listener en écoute", "listener", l.name, "addr", l.addr, "tls", l.transport() == "https")
+			if err := l.server.Serve(ln); err != http.ErrServerClosed {
+				fatal("Erreur serveur", "listener", l.name, "error", err)
+			}
+		}(l, sockets[i])
+	}
+	wg.Wait()
+}
+
+// shutdownAll arrête proprement tous les listeners
+func shutdownAll(ctx context.Context, listeners []*listener) {
+	for _, l := range listeners {
+		if err := l.server.Shutdown(ctx); err != nil {
+			slog.Error("Erreur d'arrêt du listener", "listener", l.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package fognode
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadBreakdown détaille la charge composite du nœud
+type LoadBreakdown struct {
+	Load        float64 `json:"load"`        // Charge composite (0-1), comparée au seuil d'admission
+	Reservation float64 `json:"reservation"` // Part du CPU réservée par les tâches admises non terminées
+	CPU         float64 `json:"cpu"`         // Utilisation CPU mesurée de l'hôte
+	Measured    bool    `json:"measured"`    // false: pas de mesure récente, la réservation en tient lieu
+	Wait        float64 `json:"wait"`        // Attente en queue rapportée à LOAD_WAIT_TARGET (0-1)
+	WaitMs      int64   `json:"wait_ms"`
+	Threshold   float64 `json:"threshold"`
+}
+
+// LoadModel calcule la charge du nœud comme moyenne pondérée (LOAD_WEIGHTS)
+// de la réservation CPU des tâches admises, de l'utilisation CPU mesurée et
+// de l'attente en queue. L'attente est la moyenne glissante des attentes des
+// tâches retirées de la queue, ou le temps écoulé depuis le dernier retrait si
+// la queue ne se vide plus.
+type LoadModel struct {
+	reservation float64 // Poids
+	cpu         float64
+	wait        float64
+	waitTarget  time.Duration // Attente qui compte pour une charge pleine
+	threshold   float64       // Charge au-delà de laquelle la politique load refuse les tâches
+
+	// Protégés par fc.mu
+	waitAvg      time.Duration
+	lastDequeued time.Time // Dernier retrait, ou arrivée d'une tâche dans la queue vide
+}
+
+// loadLoadModel lit LOAD_WEIGHTS ("reservation=0.4,cpu=0.4,wait=0.2"),
+// LOAD_WAIT_TARGET et MAX_LOAD
+func loadLoadModel() *LoadModel {
+	m := &LoadModel{
+		reservation:  0.4,
+		cpu:          0.4,
+		wait:         0.2,
+		waitTarget:   getEnvDuration("LOAD_WAIT_TARGET", 10*time.Second),
+		threshold:    getEnvFloat("MAX_LOAD", MaxLoadThreshold),
+		lastDequeued: time.Now(),
+	}
+	if m.threshold <= 0 {
+		config.errorf("MAX_LOAD", "seuil strictement positif attendu (%g)", m.threshold)
+	}
+	for _, kv := range getEnvList("LOAD_WEIGHTS") {
+		name, value, _ := strings.Cut(kv, "=")
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || f < 0 {
+			slog.Warn("Poids LOAD_WEIGHTS invalide ignoré", "value", kv)
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "reservation":
+			m.reservation = f
+		case "cpu":
+			m.cpu = f
+		case "wait":
+			m.wait = f
+		default:
+			slog.Warn("Poids LOAD_WEIGHTS invalide ignoré", "value", kv)
+		}
+	}
+	if m.reservation+m.cpu+m.wait <= 0 {
+		slog.Warn("LOAD_WEIGHTS tous nuls: poids par défaut")
+		m.reservation, m.cpu, m.wait = 0.4, 0.4, 0.2
+	}
+	if m.waitTarget <= 0 {
+		m.waitTarget = 10 * time.Second
+	}
+	return m
+}
+
+// enqueuedLocked note l'arrivée d'une tâche dans la queue vide, d'où se
+// mesure l'attente tant qu'aucune tâche n'en sort; fc.mu doit être détenu
+func (m *LoadModel) enqueuedLocked(wasEmpty bool, now time.Time) {
+	if wasEmpty {
+		m.lastDequeued = now
+	}
+}
+
+// dequeuedLocked prend en compte l'attente d'une tâche retirée de la queue; fc.mu doit être détenu
+func (m *LoadModel) dequeuedLocked(wait time.Duration, now time.Time) {
+	const alpha = 0.2
+	m.waitAvg = time.Duration(alpha*float64(wait) + (1-alpha)*float64(m.waitAvg))
+	m.lastDequeued = now
+}
+
+// loadLocked calcule la charge composite; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) loadLocked(host *HostSample, now time.Time) LoadBreakdown {
+	m := fc.load
+	b := LoadBreakdown{
+		Reservation: clamp01(1 - fc.availableCPU/fc.capacity.CPU),
+		Threshold:   m.threshold,
+	}
+	// Sans mesure, l'utilisation CPU est estimée par la réservation
+	b.CPU = b.Reservation
+	if host != nil {
+		b.CPU, b.Measured = host.CPU, true
+	}
+
+	wait := m.waitAvg
+	if fc.scheduler.Len() > 0 {
+		if stalled := now.Sub(m.lastDequeued); stalled > wait {
+			wait = stalled
+		}
+	} else {
+		wait = 0
+	}
+	b.WaitMs = wait.Milliseconds()
+	b.Wait = clamp01(float64(wait) / float64(m.waitTarget))
+
+	b.Load = (m.reservation*b.Reservation + m.cpu*b.CPU + m.wait*b.Wait) / (m.reservation + m.cpu + m.wait)
+	return b
+}
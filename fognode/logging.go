@@ -0,0 +1,38 @@
+package fognode
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging configure la journalisation structurée (LOG_FORMAT=json|text,
+// LOG_LEVEL=debug|info|warn|error). Chaque entrée porte l'identifiant du nœud;
+// les appels résiduels au paquet log passent par le même handler.
+func setupLogging(nodeID string) {
+	level := getEnv("LOG_LEVEL", "info")
+	var lvl slog.Level
+	invalid := lvl.UnmarshalText([]byte(level)) != nil
+	if invalid {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	if strings.EqualFold(getEnv("LOG_FORMAT", "json"), "text") {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler).With("node_id", nodeID))
+
+	if invalid {
+		slog.Warn("LOG_LEVEL invalide, utilisation de info", "value", level)
+	}
+}
+
+// fatal journalise une erreur irrécupérable puis arrête le processus
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
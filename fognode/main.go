@@ -0,0 +1,1718 @@
+// Package fognode est le nœud fog computing: scheduler, exécution des tâches,
+// API HTTP et coopération entre nœuds. Le binaire cmd/fog-compute n'en est
+// que le point d'entrée; les clients Go utilisent fogclient.
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	MaxLoadThreshold = 0.8 // Seuil de charge composite par défaut au-delà duquel les tâches sont rejetées (MAX_LOAD)
+	MaxQueueSize     = 50  // Limite par défaut de chaque classe d'admission de la queue
+	NumWorkers       = 5   // Taille du pool de workers
+	APIVersion       = "1.0"
+)
+
+// Version est la version du binaire, injectée au build via -ldflags "-X fog-compute/fognode.Version=..."
+var Version = "dev"
+
+// FogNode représente un nœud de fog computing
+type FogNode struct {
+	ID       string    `json:"id"`
+	Location string    `json:"location"`
+	Status   string    `json:"status"`
+	Load     float64   `json:"load"`
+	LastSeen time.Time `json:"last_seen"`
+	Clock    *ClockStatus `json:"clock,omitempty"`
+	PowerMode string    `json:"power_mode"` // Mode d'alimentation: normal, saver ou critical
+}
+
+// Task représente une tâche computationnelle
+type Task struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	DeviceID    string                 `json:"device_id,omitempty"`     // Appareil source de la tâche
+	Device      *DeviceInfo            `json:"device,omitempty"`        // Métadonnées de l'appareil source, depuis le registre d'appareils
+	Payload     map[string]interface{} `json:"payload"`
+	Priority    int                    `json:"priority"`                // Priorité originale du client
+	Criticality int                    `json:"criticality"`             // 1-5, plus élevé = plus critique
+	SmartScore  float64                `json:"smart_score"`             // Score intelligent calculé
+	EstimatedLatency time.Duration     `json:"estimated_latency,omitempty"`
+	CPUCost     float64                `json:"cpu_cost,omitempty"`      // Utilisation CPU estimée (0.0-1.0)
+	RAMCost     float64                `json:"ram_cost,omitempty"`      // Utilisation RAM estimée (0.0-1.0)
+	StorageCost float64                `json:"storage_cost,omitempty"`  // Utilisation stockage estimée (MB)
+	EnergyCost  float64                `json:"energy_cost,omitempty"`   // Consommation énergie estimée (Wh)
+	NetworkLatency time.Duration       `json:"network_latency,omitempty"` // Latence réseau vers le nœud
+	Deadline    *time.Time             `json:"deadline,omitempty"`      // Échéance optionnelle fournie par le client
+	ExecuteAt   *time.Time             `json:"execute_at,omitempty"`    // Mise en queue différée jusqu'à cette date
+	TimeoutMs   int64                  `json:"timeout_ms,omitempty"`    // Durée d'exécution maximale (0 = défaut du nœud)
+	Retry       *RetryPolicy           `json:"retry,omitempty"`         // Réessais automatiques en cas d'échec
+	Attempts    int                    `json:"attempts"`                // Nombre de tentatives d'exécution
+	NextAttemptAt *time.Time           `json:"next_attempt_at,omitempty"` // Prochain réessai planifié
+	ActualUsage *ResourceUsage         `json:"actual_usage,omitempty"`  // Consommation mesurée de la dernière exécution
+	TraceID     string                 `json:"trace_id,omitempty"`      // Trace OpenTelemetry de la soumission
+	QueueClass  string                 `json:"queue_class,omitempty"`   // Classe d'admission (critical, normal, best_effort)
+	PriorityClass string               `json:"priority_class,omitempty"` // Classe de priorité (critical, standard, batch), déduite de la criticité si absente
+	Provenance  []ProvenanceHop        `json:"provenance,omitempty"`    // Parcours de la tâche (source, sauts entre nœuds, réessais)
+	CallbackURL string                 `json:"callback_url,omitempty"`  // Notifié par POST à la fin, à l'échec ou au rejet de la tâche
+	Annotations []Annotation           `json:"annotations,omitempty"`   // Notes des opérateurs (triage)
+	Owner       string                 `json:"owner,omitempty"`         // Identité authentifiée du client soumetteur (clé d'API ou jeton OIDC)
+	Tenant      string                 `json:"tenant,omitempty"`        // Namespace de la tâche (quotas et visibilité)
+	DependsOn   []string               `json:"depends_on,omitempty"`    // Tâches qui doivent réussir avant la mise en queue
+	WorkflowID  string                 `json:"workflow_id,omitempty"`   // Workflow (DAG) de la tâche: celui de ses parents, ou l'ID de sa racine
+	GroupID     string                 `json:"group_id,omitempty"`      // Groupe de tâches suivi ensemble (GET /groups/{id})
+	GroupCallbackURL string            `json:"group_callback_url,omitempty"` // Notifié par POST quand toutes les tâches du groupe sont terminées
+	Progress    *TaskProgress          `json:"progress,omitempty"`      // Avancement déclaré par le handler ou l'exécuteur distant
+	Status      string                 `json:"status"`
+	Result      interface{}            `json:"result,omitempty"`
+	SubmittedAt time.Time              `json:"submitted_at"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+
+	shapingKey string // Clé de lissage de débit (propriétaire authentifié, device_id ou IP client)
+	trace      spanContext // Span de soumission, parent des spans d'attente et d'exécution
+	queuedAt   time.Time   // Dernière mise en queue
+	startedAt  time.Time   // Début de l'exécution en cours
+	cancel     context.CancelFunc // Interrompt l'exécution en cours (préemption)
+	preempted   bool    // Exécution interrompue par une tâche critique, pas encore remise en queue
+	preemptedBy string  // Tâche critique dont la fin remet celle-ci en queue
+	preempting  []*Task // Tâches préemptées par celle-ci, remises en queue quand elle libère ses ressources
+}
+
+// RejectedTask représente une tâche rejetée avec sa raison
+type RejectedTask struct {
+	Task         Task      `json:"task"`
+	RejectedAt   time.Time `json:"rejected_at"`
+	RejectionReason string `json:"rejection_reason"`
+	NodeLoad     float64   `json:"node_load"`
+	QueueSize    int       `json:"queue_size"`
+	AutoRetries  int        `json:"auto_retries,omitempty"`      // Réévaluations automatiques encore refusées
+	NextRetryAt  *time.Time `json:"next_retry_at,omitempty"`     // Prochaine réévaluation automatique
+	LastRetryReason string  `json:"last_retry_reason,omitempty"` // Motif du dernier refus à la réévaluation
+}
+
+// TaskHeap implémente un min-heap de tâches selon une fonction d'ordre
+// fournie par le Scheduler (ex: SmartScore plus bas = exécuté en premier)
+type TaskHeap struct {
+	items []*Task
+	less  func(a, b *Task) bool
+}
+
+// NewTaskHeap crée un heap vide ordonné par less
+func NewTaskHeap(less func(a, b *Task) bool) *TaskHeap {
+	return &TaskHeap{items: make([]*Task, 0), less: less}
+}
+
+func (h TaskHeap) Len() int           { return len(h.items) }
+func (h TaskHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h TaskHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *TaskHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(*Task))
+}
+
+func (h *TaskHeap) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	h.items = old[0 : n-1]
+	return item
+}
+
+// calculateScore calcule le score intelligent de planification
+// Score plus bas = doit être exécuté en premier
+// Considère: priorité, criticité, latence, utilisation des ressources, efficacité énergétique
+// Les poids de chaque terme sont ajustables via SCORE_WEIGHTS (voir /calibration)
+func (t *Task) calculateScore() float64 {
+	w := currentScoreWeights()
+	w[energyTerm] *= currentEnergyBoost() // Hors mode d'alimentation normal, l'énergie pèse davantage
+	return w.dot(t.scoreFeatures())
+}
+
+// scoreFeatures retourne les termes bruts du score, dans l'ordre de scoreTerms
+func (t *Task) scoreFeatures() scoreVector {
+	return scoreVector{
+		float64(t.Priority),
+		float64(5 - t.Criticality), // Criticité plus haute réduit le score
+		t.EstimatedLatency.Seconds(),
+		t.NetworkLatency.Seconds(),
+
+		// Efficacité des ressources: préfère les tâches qui utilisent moins de ressources
+		t.CPUCost + t.RAMCost,
+		t.StorageCost,
+
+		// Efficacité énergétique: préfère la faible consommation d'énergie
+		t.EnergyCost,
+	}
+}
+
+// TaskCosts sont les coûts de ressources appliqués aux tâches qui ne les déclarent pas
+type TaskCosts struct {
+	CPU       float64 `json:"cpu"`
+	RAM       float64 `json:"ram"`
+	StorageMB float64 `json:"storage_mb"`
+}
+
+// defaultTaskCostsType est l'entrée des types sans coûts propres
+const defaultTaskCostsType = "default"
+
+// taskDefaultCosts associe à chaque type de tâche ses coûts par défaut
+// (DEFAULT_COSTS_<TYPE>_CPU, _RAM, _STORAGE_MB), rechargeables: lus par defaultCostsFor
+var taskDefaultCosts = map[string]TaskCosts{
+	"data_aggregation":    {CPU: 0.2, RAM: 0.15, StorageMB: 50},
+	"edge_analytics":      {CPU: 0.4, RAM: 0.3, StorageMB: 100},
+	"preprocessing":       {CPU: 0.1, RAM: 0.1, StorageMB: 25},
+	"caching":             {CPU: 0.05, RAM: 0.05, StorageMB: 10},
+	defaultTaskCostsType: {CPU: 0.2, RAM: 0.15, StorageMB: 50},
+}
+
+// loadTaskDefaultCosts applique aux coûts par défaut les réglages
+// DEFAULT_COSTS_<TYPE>_CPU, _RAM et _STORAGE_MB; un coût négatif est une
+// erreur de configuration
+func loadTaskDefaultCosts() map[string]TaskCosts {
+	costs := make(map[string]TaskCosts, len(taskDefaultCosts))
+	for taskType, c := range taskDefaultCosts {
+		prefix := "DEFAULT_COSTS_" + strings.ToUpper(taskType) + "_"
+		c.CPU = getEnvFloat(prefix+"CPU", c.CPU)
+		c.RAM = getEnvFloat(prefix+"RAM", c.RAM)
+		c.StorageMB = getEnvFloat(prefix+"STORAGE_MB", c.StorageMB)
+		if c.CPU < 0 || c.RAM < 0 || c.StorageMB < 0 {
+			config.errorf(prefix+"*", "coûts négatifs pour %s", taskType)
+			continue
+		}
+		costs[taskType] = c
+	}
+	return costs
+}
+
+// applyDefaultCosts complète les coûts de ressources non déclarés selon le type de tâche
+func (t *Task) applyDefaultCosts() {
+	costs := defaultCostsFor(t.Type)
+	if t.CPUCost == 0 {
+		t.CPUCost = costs.CPU
+	}
+	if t.RAMCost == 0 {
+		t.RAMCost = costs.RAM
+	}
+	if t.StorageCost == 0 {
+		t.StorageCost = costs.StorageMB
+	}
+	if t.EnergyCost == 0 {
+		t.EnergyCost = t.CPUCost * 0.5
+	}
+	if t.NetworkLatency == 0 {
+		t.NetworkLatency = 10 * time.Millisecond
+	}
+}
+
+// ResourceCapacity est la capacité du nœud, dans les unités des coûts déclarés
+// par les tâches (1.0 de CPU ou de RAM: un nœud de référence)
+type ResourceCapacity struct {
+	CPU       float64 `json:"cpu"`
+	RAM       float64 `json:"ram"`
+	StorageMB float64 `json:"storage_mb"`
+}
+
+// loadResourceCapacity lit CAPACITY_CPU, CAPACITY_RAM et CAPACITY_STORAGE_MB
+func loadResourceCapacity() ResourceCapacity {
+	c := ResourceCapacity{
+		CPU:       getEnvFloat("CAPACITY_CPU", 1.0),
+		RAM:       getEnvFloat("CAPACITY_RAM", 1.0),
+		StorageMB: getEnvFloat("CAPACITY_STORAGE_MB", 1000.0),
+	}
+	if c.CPU <= 0 || c.RAM <= 0 || c.StorageMB <= 0 {
+		config.errorf("CAPACITY_*", "capacités strictement positives attendues (cpu=%g, ram=%g, storage_mb=%g)", c.CPU, c.RAM, c.StorageMB)
+	}
+	return c
+}
+
+// loadNumWorkers lit NUM_WORKERS, la taille initiale du pool de workers
+func loadNumWorkers() int {
+	n := getEnvInt("NUM_WORKERS", NumWorkers)
+	if n < 1 {
+		config.errorf("NUM_WORKERS", "au moins un worker attendu (%d)", n)
+		n = NumWorkers
+	}
+	return n
+}
+
+// FogCompute gère les opérations de fog computing
+type FogCompute struct {
+	node    FogNode
+	tasks   map[string]*Task
+	scheduler Scheduler // Politique d'ordonnancement de la queue
+	admission *AdmissionChain // Chaîne ordonnée des politiques d'admission
+	rejectedTasks []RejectedTask  // Queue pour les tâches rejetées
+	rejectedEviction *RejectedEviction // Limite de la queue des rejets (REJECTED_QUEUE_MAX)
+	schedTrace    *SchedulerTrace // Décisions récentes du scheduler (SCHEDULER_TRACE)
+	mu      sync.RWMutex
+	cond    *sync.Cond
+	metrics Metrics
+	capacity        ResourceCapacity // Capacité totale du nœud (CAPACITY_*)
+	// Ressources disponibles
+	availableCPU    float64
+	availableRAM    float64
+	availableStorage float64
+	energyLevel     float64 // Niveau d'énergie actuel (0.0-1.0)
+	power           *PowerManager // Batterie: décharge par worker occupé, recharge par la source
+	powerModes      *PowerModes   // Modes économie et critique selon le niveau de la batterie
+	accelerators    []string // Accélérateurs matériels détectés au démarrage
+	transports      []string // Schémas des listeners ouverts (http, https, http+unix), dans /capabilities
+	clock           *ClockMonitor
+	rejectUnsyncedDeadlines bool // Rejeter les tâches à échéance si l'horloge n'est pas synchronisée
+	shaper          *DeviceShaper
+	numWorkers      int          // Nombre de workers demandé (PUT /admin/workers)
+	maxWorkers      int          // Borne de numWorkers (MAX_WORKERS)
+	runningWorkers  map[int]bool // Workers démarrés et pas encore arrêtés
+	reservedWorkers int // Workers réservés aux tâches critiques (criticité >= 4)
+	reservedBusy    int // Workers réservés en cours d'exécution
+	reservedServed  int // Tâches exécutées par les workers réservés
+	costs           *CostAccounting // Rapprochement coûts déclarés / mesurés
+	latency         *LatencyHistograms // Durées d'exécution par type (exposition Prometheus)
+	tracer          *Tracer            // Spans OpenTelemetry des tâches
+	calibration     *Calibration       // Historique score / devenir pour calibrer le SmartScore
+	admissionTokens map[string]*admissionToken // Réservations en attente du téléversement de leur payload
+	tokenTTL        time.Duration // Validité d'un jeton d'admission
+	store           TaskStore          // Persistance des tâches pour la reprise après redémarrage
+	taskRetention   time.Duration      // Durée de conservation des tâches terminées
+	failedRetention time.Duration      // Durée de conservation des tâches en échec définitif (dead-letter queue)
+	retention       *TaskRetention     // Plafond des tâches terminées en mémoire et archive des tâches oubliées
+	queueLimits     map[string]int // Limite de queue par classe d'admission
+	classQueued     map[string]int // Nombre de tâches en queue par classe
+	peers           *PeerManager
+	nodes           *NodeRegistry // Nœuds qui s'annoncent à ce nœud (registre du cluster)
+	devices         *DeviceRegistry // Appareils connus: métadonnées des tâches par device_id
+	qos             *HTTPQoS      // Limites de concurrence HTTP par classe de trafic
+	timeouts        *RequestTimeouts // Délai de traitement des requêtes par route
+	auth            *APIAuth      // Clés d'API et portées (API_KEYS)
+	tenants         *Tenants      // Namespaces, leurs quotas et leur occupation (TENANTS)
+	offload         OffloadPolicy // Délégation aux pairs des tâches refusées pour surcharge
+	idScheme        IDScheme      // Attribution des identifiants de tâches (ID_SCHEME)
+	idNamespace     IDNamespace   // Préfixe des identifiants générés (ID_PREFIX)
+	cloud           *CloudTier    // Repli cloud quand aucun nœud fog ne peut accepter la tâche
+	calendar        *ResourceCalendar // Fenêtres de capacité planifiées par l'opérateur
+	schedules       *TaskSchedules    // Tâches récurrentes (cron ou intervalle)
+	waiters         *TaskWaiters      // Soumissions synchrones en attente de leur tâche
+	batchMax        int               // Tâches au plus par soumission groupée (0: illimité)
+	groups          *TaskGroups       // Groupes de tâches déjà notifiés
+	delayed         *DelayedTasks     // Tâches en attente de leur execute_at
+	intervals       *MetricIntervals  // Historique des compteurs pour les comptes par intervalle
+	capacityCut     capacityCut       // Capacité retirée par les fenêtres actives
+	mqtt            *MQTTBridge   // Ingestion des tâches et publication des résultats par MQTT
+	callbacks       *Callbacks    // Notifications de fin de tâche aux callback_url
+	events          *EventHub     // Flux SSE du cycle de vie des tâches (GET /events)
+	journal         *SubmissionJournal // Soumissions admises, rejouables (REPLAY)
+	startedAt       time.Time
+	uplink          *Uplink
+	updater         *Updater
+	draining        bool // Plus aucune admission: le nœud se vide avant maintenance
+	stopping        bool // Arrêt en cours: les workers ne prennent plus de tâche
+	maintenance     Maintenance // Pause et drainage demandés par l'opérateur
+	workers         sync.WaitGroup // Workers actifs, attendus à l'arrêt
+	shutdownDrain   time.Duration // Délai laissé aux exécutions en cours à l'arrêt
+	inFlight        int  // Tâches en cours d'exécution par les workers
+	dropMissedDeadlines bool // Rejeter/abandonner les tâches dont l'échéance est déjà passée
+	procLimits      ProcessLimits // Affinité et priorités des sous-processus des exécuteurs
+	energyWorkers   *EnergyWorkerPolicy // Workers et cœurs actifs en basse énergie (ENERGY_WORKER_POLICY)
+	standby         *StandbyPair        // Appariement actif/standby et réplication de l'état des tâches
+	host            *HostSampler        // Ressources réellement utilisées sur l'hôte
+	carbon          *CarbonMonitor      // Intensité carbone du réseau et décalage des tâches qui peuvent attendre
+	load            *LoadModel          // Calcul de la charge composite et seuil d'admission
+	maxQueueWait    time.Duration       // Plus longue attente en queue observée (vieillissement)
+	preemption      *Preemption         // Interruption de tâches peu critiques pour les tâches de criticité 5
+	backpressure    *Backpressure       // File de débordement: 202 différé plutôt que 503
+	rejectedRetry   *RejectedRetrier    // Resoumission automatique des tâches rejetées
+	sandbox         SandboxPolicies // Confinement seccomp/AppArmor/SELinux par type de tâche
+	resultLimits    ResultLimits    // Taille maximale des résultats et politique de dépassement
+	blobs           *ResultBlobs    // Stockage des résultats volumineux écrits par les handlers
+	legacy          *LegacyRoutes   // Routes sans préfixe, dépréciées au profit de /api/v1
+	negotiation     *ContentNegotiation // Corps et réponses CBOR ou MessagePack
+	openAPIOnce     sync.Once       // Construction du document de GET /openapi.json
+	openAPIDoc      []byte
+	defaultTimeout  time.Duration // Timeout d'exécution des tâches sans timeout_ms
+	defaultRetry    RetryPolicy   // Politique de réessai des tâches sans politique propre
+}
+
+// Metrics suit les métriques de performance
+type Metrics struct {
+	TasksProcessed int           `json:"tasks_processed"`
+	TasksRejected  int           `json:"tasks_rejected"`  // Compteur de tâches rejetées
+	AvgLatency     time.Duration `json:"avg_latency"`
+	LatencySum     time.Duration `json:"-"` // Somme des latences des tâches réussies (moyennes par intervalle)
+	CurrentLoad    float64       `json:"current_load"`
+	DeadlinesMet    int `json:"deadlines_met"`
+	DeadlinesMissed int `json:"deadlines_missed"` // Tâches terminées après leur échéance
+	ExpiredDropped  int `json:"expired_dropped"`  // Tâches abandonnées car échues avant exécution
+	TasksTimedOut   int `json:"tasks_timed_out"`  // Tâches interrompues par leur timeout d'exécution
+	TasksFailed     int `json:"tasks_failed"`     // Tâches en échec définitif (réessais épuisés)
+	TasksRetried    int `json:"tasks_retried"`    // Réessais automatiques planifiés
+	TasksOffloaded  int `json:"tasks_offloaded"`  // Tâches déléguées à un pair au lieu d'être rejetées
+	TasksRedirected int `json:"tasks_redirected"` // Soumissions redirigées (307) vers un pair
+	TasksDeferred   int `json:"tasks_deferred"`   // Tâches reportées après une fenêtre de capacité planifiée
+	mu             sync.RWMutex
+}
+
+// NewFogCompute crée une nouvelle instance de fog computing
+func NewFogCompute(nodeID, location string) *FogCompute {
+	numWorkers := loadNumWorkers()
+	reservedWorkers := loadReservedWorkers(numWorkers)
+	capacity := loadResourceCapacity()
+	startedAt := time.Now()
+	fc := &FogCompute{
+		node: FogNode{
+			ID:       nodeID,
+			Location: location,
+			Status:   "active",
+			PowerMode: PowerModeNormal,
+			Load:     0.0,
+			LastSeen: time.Now(),
+		},
+		tasks:   make(map[string]*Task),
+		scheduler: withCriticalLane(withPriorityClasses(loadScheduler()), reservedWorkers),
+		reservedWorkers: reservedWorkers,
+		numWorkers:      numWorkers,
+		maxWorkers:      getEnvInt("MAX_WORKERS", 4*numWorkers),
+		runningWorkers:  make(map[int]bool),
+		admission: loadAdmissionChain(),
+		rejectedTasks: make([]RejectedTask, 0),  // Initialiser la queue des tâches rejetées
+		rejectedEviction: NewRejectedEviction(),
+		schedTrace:    NewSchedulerTrace(),
+		metrics: Metrics{
+			TasksProcessed: 0,
+			TasksRejected:  0,
+			AvgLatency:     0,
+			CurrentLoad:    0.0,
+		},
+		// Initialiser les ressources disponibles
+		capacity:         capacity,
+		availableCPU:     capacity.CPU,
+		availableRAM:     capacity.RAM,
+		availableStorage: capacity.StorageMB,
+		energyLevel:      clamp01(getEnvFloat("ENERGY_INITIAL_LEVEL", 1.0)), // Niveau de charge au démarrage
+		power:            NewPowerManager(),
+		powerModes:       loadPowerModes(),
+		accelerators:     detectAccelerators(),
+		clock:            NewClockMonitor(),
+		rejectUnsyncedDeadlines: getEnvBool("REJECT_UNSYNCED_DEADLINES", false),
+		shaper:           NewDeviceShaper(),
+		costs:            NewCostAccounting(),
+		latency:          NewLatencyHistograms(),
+		tracer:           NewTracer(nodeID),
+		calibration:      NewCalibration(),
+		admissionTokens:  make(map[string]*admissionToken),
+		tokenTTL:         getEnvDuration("ADMISSION_TOKEN_TTL", 30*time.Second),
+		store:            loadTaskStore(),
+		taskRetention:    getEnvDuration("TASK_RETENTION", 24*time.Hour),
+		failedRetention:  getEnvDuration("FAILED_RETENTION", 7*24*time.Hour),
+		retention:        NewTaskRetention(),
+		queueLimits:      loadQueueLimits(),
+		classQueued:      make(map[string]int),
+		peers:            NewPeerManager(),
+		nodes:            NewNodeRegistry(),
+		devices:          NewDeviceRegistry(),
+		qos:              NewHTTPQoS(),
+		timeouts:         NewRequestTimeouts(),
+		auth:             NewAPIAuth(),
+		legacy:           NewLegacyRoutes(),
+		negotiation:      NewContentNegotiation(),
+		tenants:          loadTenants(),
+		offload:          loadOffloadPolicy(),
+		idScheme:         loadIDScheme(),
+		idNamespace:      loadIDNamespace(nodeID, location),
+		cloud:            NewCloudTier(),
+		calendar:         NewResourceCalendar(),
+		schedules:        NewTaskSchedules(),
+		waiters:          NewTaskWaiters(),
+		batchMax:         getEnvInt("BATCH_MAX_TASKS", 500),
+		groups:           NewTaskGroups(),
+		delayed:          NewDelayedTasks(),
+		mqtt:             NewMQTTBridge(nodeID),
+		callbacks:        NewCallbacks(),
+		events:           NewEventHub(),
+		journal:          NewSubmissionJournal(nodeID),
+		startedAt:        startedAt,
+		intervals:        NewMetricIntervals(startedAt),
+		uplink:           NewUplink(nodeID),
+		dropMissedDeadlines: getEnvBool("DROP_MISSED_DEADLINES", false),
+		procLimits:       loadProcessLimits(),
+		host:             NewHostSampler(),
+		carbon:           NewCarbonMonitor(),
+		preemption:       loadPreemption(),
+		backpressure:     loadBackpressure(),
+		rejectedRetry:    loadRejectedRetrier(),
+		load:             loadLoadModel(),
+		sandbox:          loadSandboxPolicies(),
+		resultLimits:     loadResultLimits(),
+		blobs:            loadResultBlobs(),
+		defaultTimeout:   getEnvDuration("TASK_DEFAULT_TIMEOUT", 30*time.Second),
+		shutdownDrain:    getEnvDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second),
+		defaultRetry:     defaultRetryPolicy(),
+	}
+	fc.energyWorkers = loadEnergyWorkerPolicy(fc.procLimits.CPUs)
+	fc.cond = sync.NewCond(&fc.mu)
+	fc.updatePowerModeLocked(time.Now()) // Batterie déjà basse au démarrage (ENERGY_INITIAL_LEVEL)
+	fc.peers.onPeerDead = fc.redispatchOrphans
+	fc.nodes.onRegister = func(n RegisteredNode) { fc.discoverNodes([]RegisteredNode{n}) }
+	fc.events.forward = fc.uplink.ForwardEvent
+	fc.standby = NewStandbyPair()
+	if fc.standby.Enabled() {
+		fc.store = replicatedStore{TaskStore: fc.store, pair: fc.standby}
+	}
+	if fc.standby.Standby() {
+		// L'état est celui répliqué par l'actif: rien à restaurer, aucune admission avant la reprise
+		fc.draining = true
+	} else {
+		fc.restoreTasks()
+	}
+	fc.applyCalendar(time.Now())
+	return fc
+}
+
+// rejectTask sauvegarde une tâche rejetée dans la queue des rejets
+func (fc *FogCompute) rejectTask(task Task, reason string, load float64, queueSize int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	rejectedTask := RejectedTask{
+		Task:            task,
+		RejectedAt:      time.Now(),
+		RejectionReason: reason,
+		NodeLoad:        load,
+		QueueSize:       queueSize,
+	}
+
+	fc.rejectedTasks = append(fc.rejectedTasks, rejectedTask)
+	fc.storeErr("save_rejected", task.ID, fc.store.SaveRejected(rejectedTask))
+	fc.evictRejectedLocked()
+	
+	fc.metrics.mu.Lock()
+	fc.metrics.TasksRejected++
+	fc.metrics.mu.Unlock()
+
+	task.Status = "rejected"
+	fc.callbacks.Notify(CallbackRejected, fc.node.ID, reason, task)
+	ev := taskEvent(EventRejected, &task)
+	ev.Reason = reason
+	fc.events.Publish(ev)
+
+	slog.Info("Tâche rejetée et sauvegardée", "task_id", task.ID, "owner", task.Owner, "priority", task.Priority,
+		"smart_score", task.SmartScore, "reason", reason, "load", load, "queue_size", queueSize)
+}
+
+// enqueueLocked réserve les ressources d'une tâche admise et la place dans la
+// priority queue; fc.mu doit être détenu
+func (fc *FogCompute) enqueueLocked(task *Task) {
+	fc.preemptForLocked(task)
+	fc.reserveLocked(task)
+
+	fc.tasks[task.ID] = task
+	task.queuedAt = time.Now()
+	fc.load.enqueuedLocked(fc.scheduler.Len() == 0, task.queuedAt)
+	fc.scheduler.Enqueue(task)
+	fc.classQueued[task.QueueClass]++
+	fc.tenants.usageLocked(tenantOf(task)).Queued++
+	fc.cond.Broadcast() // Réveiller les workers en attente: les workers réservés ne prennent pas toutes les tâches
+	fc.shaper.Accepted(task.shapingKey)
+	fc.saveTaskLocked(task)
+	fc.events.Publish(taskEvent(EventQueued, task))
+}
+
+// Start commence le traitement des tâches
+func (fc *FogCompute) Start(ctx context.Context) {
+	slog.Info("Démarrage du nœud fog computing", "scheduler", fc.scheduler.Name())
+	
+	// Démarrer le pool de workers
+	fc.mu.Lock()
+	fc.startWorkersLocked()
+	fc.mu.Unlock()
+	// Les workers bloqués sur une queue vide ne voient pas ctx: ils sont
+	// réveillés à l'annulation, et s'arrêtent avant de prendre une autre tâche
+	go func() {
+		<-ctx.Done()
+		fc.stopWorkers()
+	}()
+	go fc.runPower(ctx)
+	go fc.runEnergyWorkers(ctx)
+	go fc.runStandby(ctx)
+	go fc.host.Run(ctx)
+	go fc.carbon.Run(ctx)
+	go fc.runAging(ctx)
+	go fc.runOverflow(ctx)
+	go fc.runRejectedRetry(ctx)
+
+	// Démarrer le mise à jour des métriques
+	go fc.updateMetrics(ctx)
+	go fc.intervals.Run(ctx, fc.counterSample)
+	fc.callbacks.Run(ctx)
+	go fc.events.Run(ctx)
+
+	// Surveiller la synchronisation de l'horloge
+	go fc.clock.Run(ctx)
+
+	// Heartbeats gossip et détection de pannes des pairs
+	go fc.peers.Run(ctx, fc.selfObservation)
+
+	// Enregistrement et heartbeats auprès des registres de nœuds
+	go fc.announce(ctx)
+
+	// Suivi des tâches déléguées aux pairs
+	go fc.reconcileOffloads(ctx)
+
+	// Fenêtres de capacité planifiées
+	go fc.runCalendar(ctx)
+	go fc.runSchedules(ctx)
+	go fc.runDelayed(ctx)
+
+	// Soumissions des appareils IoT par MQTT
+	go fc.mqtt.Run(ctx, fc.ingestMQTT)
+
+	// Stockage et retransmission vers le cloud
+	go fc.uplink.Run(ctx)
+	go telemetry.Run(ctx)
+	go fc.reportTelemetry(ctx)
+
+	// Export des spans vers le collecteur OpenTelemetry
+	go fc.tracer.Run(ctx)
+
+	// Nettoyage des résultats volumineux déportés sur disque
+	go fc.pruneSpilledResults(ctx)
+
+	// Oubli des tâches terminées au-delà de la rétention
+	go fc.collectTasks(ctx)
+
+	// Mises à jour automatiques du binaire
+	if fc.updater != nil {
+		go fc.updater.Run(ctx)
+	}
+}
+
+// reserveLocked réserve les ressources d'une tâche; fc.mu doit être détenu
+func (fc *FogCompute) reserveLocked(task *Task) {
+	fc.availableCPU -= task.CPUCost
+	fc.availableRAM -= task.RAMCost
+	fc.availableStorage -= task.StorageCost
+	fc.tenants.reserveLocked(task)
+}
+
+// releaseLocked libère les ressources réservées par une tâche; fc.mu doit être détenu
+func (fc *FogCompute) releaseLocked(task *Task) {
+	fc.availableCPU += task.CPUCost
+	fc.availableRAM += task.RAMCost
+	fc.availableStorage += task.StorageCost
+	fc.tenants.releaseLocked(task)
+	fc.releasePreemptedLocked(task)
+}
+
+// setDraining active ou désactive le refus de toute nouvelle admission
+func (fc *FogCompute) setDraining(draining bool) {
+	fc.mu.Lock()
+	fc.draining = draining || fc.maintenance.holdAdmission // Un drainage demandé par l'opérateur dure jusqu'à /admin/resume
+	fc.mu.Unlock()
+	slog.Info("Mode drainage", "draining", draining)
+}
+
+// waitIdle attend que la queue soit vide et qu'aucune tâche ne soit en cours,
+// dans la limite du délai donné; retourne false si le délai est dépassé
+func (fc *FogCompute) waitIdle(ctx context.Context, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		fc.mu.RLock()
+		idle := fc.scheduler.Len() == 0 && fc.inFlight == 0
+		fc.mu.RUnlock()
+		if idle {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// worker traite les tâches depuis la priority queue jusqu'à stopWorkers ou
+// son retrait du pool; une tâche retirée de la queue est toujours menée à son terme
+func (fc *FogCompute) worker(workerID int) {
+	defer fc.workers.Done()
+	reserved := workerID < fc.reservedWorkers
+	logger := slog.With("worker_id", workerID)
+	logger.Info("Worker démarré", "reserved_critical", reserved)
+	
+	for {
+		fc.mu.Lock()
+		for !fc.mayTakeTaskLocked(workerID, reserved) && !fc.stopping && !fc.retiredLocked(workerID) {
+			fc.cond.Wait() // Attendre que des tâches soient disponibles
+		}
+		if fc.stopping || fc.retiredLocked(workerID) {
+			// Les tâches restantes sont persistées en queue pour le redémarrage,
+			// ou laissées aux autres workers
+			retired := !fc.stopping
+			delete(fc.runningWorkers, workerID)
+			fc.mu.Unlock()
+			logger.Info("Worker arrêté", "retired", retired)
+			return
+		}
+		task := fc.nextFor(reserved)
+		wait := time.Since(task.queuedAt)
+		fc.load.dequeuedLocked(wait, time.Now())
+		if wait > fc.maxQueueWait {
+			fc.maxQueueWait = wait
+		}
+		fc.classQueued[task.QueueClass]--
+		fc.tenants.usageLocked(tenantOf(task)).Queued--
+		expired := fc.dropMissedDeadlines && task.Deadline != nil && time.Now().After(*task.Deadline)
+		if expired {
+			// Inutile d'exécuter une tâche dont l'échéance est déjà passée
+			task.Status = "deadline_missed"
+			fc.releaseLocked(task)
+			fc.saveTaskLocked(task)
+			fc.resolveDependentsLocked(task)
+			fc.notifyOutcome(task)
+			fc.events.Publish(taskEvent(EventFailed, task))
+		}
+		// Avant et pendant une fenêtre de capacité réduite, les tâches qui peuvent attendre sont reportées après elle
+		deferred := !expired && (fc.deferLocked(task) || fc.deferForPowerLocked(task) || fc.deferForCarbonLocked(task))
+		fc.traceDecisionLocked(workerID, reserved, task, expired, deferred)
+		fc.mu.Unlock()
+		fc.shaper.Dequeued(task.shapingKey)
+		if expired {
+			fc.publishExpiration(task)
+		}
+		if deferred {
+			continue
+		}
+
+		// Le span d'attente couvre la mise en queue jusqu'au choix par le scheduler
+		queued := fc.tracer.StartAt("task.queued", SpanKindInternal, task.trace, task.queuedAt)
+		queued.SetAttr("task.id", task.ID)
+		queued.SetAttr("scheduler", fc.scheduler.Name())
+		queued.SetAttr("queue_class", task.QueueClass)
+		if expired {
+			queued.SetError("échéance dépassée avant exécution")
+		}
+		queued.End()
+
+		if expired {
+			fc.metrics.mu.Lock()
+			fc.metrics.ExpiredDropped++
+			fc.metrics.mu.Unlock()
+			logger.Warn("Tâche abandonnée: échéance dépassée avant exécution", "task_id", task.ID, "deadline", task.Deadline.Format(time.RFC3339))
+			continue
+		}
+
+		if reserved {
+			fc.mu.Lock()
+			fc.reservedBusy++
+			fc.reservedServed++
+			fc.mu.Unlock()
+		}
+		fc.processTask(logger, task)
+		if reserved {
+			fc.mu.Lock()
+			fc.reservedBusy--
+			fc.mu.Unlock()
+		}
+	}
+}
+
+// processTask exécute une tâche unique
+func (fc *FogCompute) processTask(logger *slog.Logger, task *Task) {
+	startTime := time.Now()
+
+	// Exécuter le handler sous timeout: une tâche trop longue ne bloque plus le worker
+	timeout := fc.defaultTimeout
+	if task.TimeoutMs > 0 {
+		timeout = time.Duration(task.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	defer cancel()
+
+	fc.mu.Lock()
+	task.Status = "processing"
+	task.Attempts++
+	task.startedAt, task.cancel = startTime, cancel
+	task.Progress = nil
+	fc.inFlight++
+	probe := startUsageProbe(fc.inFlight)
+	fc.saveTaskLocked(task)
+	started := taskEvent(EventStarted, task)
+	started.At = startTime
+	if !task.queuedAt.IsZero() {
+		started.QueueMs = startTime.Sub(task.queuedAt).Milliseconds()
+	}
+	fc.events.Publish(started)
+	fc.mu.Unlock()
+
+	span := fc.tracer.Start("task.process", SpanKindInternal, task.trace)
+	span.SetAttr("task.id", task.ID)
+	span.SetAttr("task.type", task.Type)
+	span.SetAttr("task.attempt", task.Attempts)
+
+	logger = logger.With("task_id", task.ID)
+	logger.Info("Traitement tâche", "type", task.Type, "priority", task.Priority,
+		"criticality", task.Criticality, "smart_score", task.SmartScore)
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	execCtx := fc.withProgress(ctx, task, task.Attempts)
+	go func() {
+		result, err := fc.executeTask(execCtx, task)
+		done <- outcome{result, err}
+	}()
+
+	var result interface{}
+	var execErr error
+	timedOut := false
+	select {
+	case o := <-done:
+		result, execErr = o.result, o.err
+		if execErr == nil {
+			// Un handler trop bavard ne doit pas faire gonfler la mémoire ni les réponses
+			result, execErr = fc.limitResult(task, result)
+		}
+		if execErr != nil {
+			result = map[string]string{"error": execErr.Error()}
+		}
+	case <-ctx.Done():
+		timedOut = true
+		result = map[string]interface{}{
+			"error":      "délai d'exécution dépassé",
+			"timeout_ms": timeout.Milliseconds(),
+		}
+	}
+
+	completedAt := time.Now()
+	latency := completedAt.Sub(startTime)
+	usage := probe.stop(fc.costs)
+
+	fc.mu.Lock()
+	if task.preempted {
+		// Les ressources sont déjà passées à la tâche critique
+		fc.inFlight--
+		preemptedBy := task.preemptedBy
+		fc.preemptedLocked(task)
+		fc.mu.Unlock()
+		span.SetAttr("task.status", "preempted")
+		span.EndAt(completedAt)
+		logger.Warn("Exécution interrompue par préemption", "elapsed_ms", latency.Milliseconds(), "preempted_by", preemptedBy)
+		return
+	}
+	task.cancel = nil
+	task.ActualUsage = &usage
+	task.Status = "completed"
+	if timedOut {
+		task.Status = "failed_timeout"
+	} else if execErr != nil {
+		task.Status = "failed"
+	}
+	task.Result = result
+	task.CompletedAt = &completedAt
+	if task.Progress != nil && task.Status == "completed" {
+		task.Progress.Fraction = 1
+	}
+	fc.inFlight--
+	fc.releaseLocked(task)
+	energyWh := executionEnergy(task, usage)
+	fc.power.executedLocked(energyWh)
+
+	// Les échecs sont réessayés avec un délai croissant tant que la politique le permet
+	failed := timedOut || execErr != nil
+	retryDelay, retrying := time.Duration(0), false
+	if failed {
+		retryDelay, retrying = fc.planRetryLocked(task)
+		if retrying {
+			task.CompletedAt = nil
+		}
+	}
+
+	fc.saveTaskLocked(task)
+	if !retrying {
+		fc.resolveDependentsLocked(task)
+	}
+	completed := *task
+	fc.mu.Unlock()
+
+	fc.costs.Record(&completed, usage)
+	fc.energyWorkers.Executed(energyWh, !failed)
+	fc.carbon.Executed(task.ID, energyWh)
+	fc.latency.Observe(task.Type, latency)
+
+	span.SetAttr("task.status", completed.Status)
+	if failed {
+		span.SetError(fmt.Sprint(result))
+	}
+	span.EndAt(completedAt)
+
+	if retrying {
+		time.AfterFunc(retryDelay, func() { fc.requeueRetry(task, retryDelay) })
+
+		fc.metrics.mu.Lock()
+		fc.metrics.TasksRetried++
+		if timedOut {
+			fc.metrics.TasksTimedOut++
+		}
+		fc.metrics.mu.Unlock()
+		logger.Warn("Tâche en échec, réessai planifié", "attempt", completed.Attempts, "retry_in", retryDelay.String())
+		return
+	}
+
+	fc.calibration.Record(&completed, startTime, completedAt)
+
+	// Le résultat est transmis au cloud, ou tamponné si le lien est coupé
+	if fc.uplink.ForwardsResult(completed.Type) {
+		fc.uplink.Enqueue("result", completed)
+	}
+	fc.publishResult(&completed)
+	fc.notifyOutcome(&completed)
+	finished := taskEvent(EventCompleted, &completed)
+	if failed {
+		finished.Type = EventFailed
+	}
+	finished.At = completedAt
+	finished.DurationMs = latency.Milliseconds()
+	fc.events.Publish(finished)
+
+	if failed {
+		fc.metrics.mu.Lock()
+		fc.metrics.TasksFailed++
+		if timedOut {
+			fc.metrics.TasksTimedOut++
+		}
+		fc.metrics.mu.Unlock()
+		if timedOut {
+			logger.Error("Tâche interrompue (timeout)", "timeout", timeout.String())
+		} else {
+			logger.Error("Tâche en échec", "error", execErr)
+		}
+		return
+	}
+
+	// Mettre à jour les métriques
+	fc.metrics.mu.Lock()
+	fc.metrics.TasksProcessed++
+	if task.Deadline != nil {
+		if completedAt.After(*task.Deadline) {
+			fc.metrics.DeadlinesMissed++
+		} else {
+			fc.metrics.DeadlinesMet++
+		}
+	}
+	fc.metrics.LatencySum += latency
+	if fc.metrics.AvgLatency == 0 {
+		fc.metrics.AvgLatency = latency
+	} else {
+		fc.metrics.AvgLatency = (fc.metrics.AvgLatency + latency) / 2
+	}
+	fc.metrics.mu.Unlock()
+
+	logger.Info("Tâche complétée", "latency_ms", latency.Milliseconds(),
+		"priority", task.Priority, "smart_score", task.SmartScore)
+}
+
+// executeTask exécute le handler correspondant au type de la tâche. Le
+// résultat est ignoré par processTask si ctx a expiré entre-temps.
+func (fc *FogCompute) executeTask(ctx context.Context, task *Task) (interface{}, error) {
+	handler, ok := lookupHandler(task.Type)
+	if !ok {
+		return nil, fmt.Errorf("type de tâche inconnu: %q", task.Type)
+	}
+	ctx = context.WithValue(ctx, executionContextKey{}, fc.taskExecution(task))
+	result, err := handler(ctx, task.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// taskWorkDurations est la durée de traitement simulée de chaque type de tâche
+// sur un nœud de référence
+var taskWorkDurations = map[string]time.Duration{
+	"data_aggregation": 100 * time.Millisecond,
+	"edge_analytics":   200 * time.Millisecond,
+	"preprocessing":    50 * time.Millisecond,
+	"caching":          30 * time.Millisecond,
+}
+
+// simulateWork attend la durée donnée, ou l'annulation du contexte
+func simulateWork(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// updateMetrics met à jour périodiquement les métriques du nœud
+func (fc *FogCompute) updateMetrics(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			host := fc.host.Sample()
+			fc.mu.Lock()
+			fc.node.Load = fc.loadLocked(host, time.Now()).Load
+			fc.node.LastSeen = time.Now()
+			fc.mu.Unlock()
+
+			fc.metrics.mu.Lock()
+			fc.metrics.CurrentLoad = fc.node.Load
+			fc.metrics.mu.Unlock()
+
+			fc.shaper.Prune()
+			fc.costs.Prune()
+			fc.expireAdmissionTokens()
+			fc.nodes.Prune()
+			fc.devices.Save()
+			dataCache.Sweep(time.Now())
+		}
+	}
+}
+
+// prepareSubmission valide une tâche soumise avant son admission: callback,
+// identifiant, execute_at et dépendances, qui doivent exister avant l'admission
+func (fc *FogCompute) prepareSubmission(task *Task) *Rejection {
+	if rej := fc.callbacks.Validate(task.CallbackURL); rej != nil {
+		return rej
+	}
+	if rej := fc.callbacks.Validate(task.GroupCallbackURL); rej != nil {
+		return rej
+	}
+	if rej := fc.devices.Resolve(task); rej != nil {
+		return rej
+	}
+	if rej := validateWASMTask(task); rej != nil {
+		return rej
+	}
+	if rej := validateContainerTask(task); rej != nil {
+		return rej
+	}
+	if rej := validateExecTask(task); rej != nil {
+		return rej
+	}
+	if rej := validateInferenceTask(task); rej != nil {
+		return rej
+	}
+	if rej := validateScriptTask(task); rej != nil {
+		return rej
+	}
+	id, rej := fc.idScheme.NewID(fc, task)
+	if rej != nil {
+		return rej
+	}
+	task.ID = id
+	task.SubmittedAt = time.Now()
+
+	if rej := fc.delayed.checkExecuteAt(task); rej != nil {
+		return rej
+	}
+	return fc.linkDependencies(task)
+}
+
+// Gestionnaires HTTP
+func (fc *FogCompute) handleSubmitTask(w http.ResponseWriter, r *http.Request) {
+	// Le span de soumission prolonge la trace de la passerelle amont, si elle en fournit une
+	parent, _ := parseTraceParent(r.Header.Get(TraceParentHeader))
+	span := fc.tracer.Start("POST /tasks", SpanKindServer, parent)
+	defer span.End()
+	w.Header().Set(TraceParentHeader, span.Context().traceParent())
+
+	// Mode redirect: un nœud surchargé renvoie le client vers un pair sans lire le payload
+	if fc.redirectOverloaded(w, r, span) {
+		return
+	}
+
+	var task Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		span.SetError(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task.trace = span.Context()
+	task.TraceID = task.trace.traceIDString()
+
+	// Soumission en deux temps: l'admission a déjà été accordée au jeton
+	if token := r.Header.Get(AdmissionTokenHeader); token != "" {
+		fc.submitWithToken(w, span, token, &task)
+		return
+	}
+
+	task.addHop(httpHop(fc.node.ID, r))
+
+	// Définir les valeurs par défaut pour les coûts de ressources
+	task.applyDefaultCosts()
+
+	// NOUVEAU: Calculer et assigner le SmartScore AVANT toute vérification
+	task.SmartScore = task.calculateScore()
+
+	if rej := fc.prepareSubmission(&task); rej != nil {
+		span.SetError(rej.Reason)
+		rej.write(w)
+		return
+	}
+
+	// Planification intelligente: vérifier la charge actuelle et les ressources disponibles
+	ac := fc.admissionContext(&task, r)
+
+	// Un nœud en drainage n'admet plus rien: le client doit aller ailleurs
+	if ac.Draining {
+		span.SetError("drainage")
+		http.Error(w, "Nœud en cours de drainage: soumissions suspendues", http.StatusServiceUnavailable)
+		return
+	}
+
+	span.SetAttr("task.id", task.ID)
+	span.SetAttr("task.type", task.Type)
+	span.SetAttr("queue_class", task.QueueClass)
+
+	// Faire passer la tâche par la chaîne d'admission configurée
+	if rej := fc.admission.Admit(fc, ac); rej != nil {
+		// Surcharge locale: un pair moins chargé peut prendre la tâche, le rejet
+		// n'est qu'un dernier recours, après la file de débordement si BACKPRESSURE est activé
+		if fc.tryOffload(w, r, span, &task, rej) || fc.escalateToCloud(w, r, span, &task, rej) ||
+			fc.overflowTask(w, span, &task, rej, ac) {
+			return
+		}
+		span.SetAttr("http.status_code", rej.Status)
+		span.SetError(rej.Reason)
+		task.Status = "rejected"
+		fc.rejectTask(task, rej.Reason, ac.Load, ac.QueueSize)
+		rej.write(w)
+		return
+	}
+
+	// Soumission synchrone: l'attente est enregistrée avant que la tâche puisse se terminer
+	wait, synchronous := fc.syncWait(r)
+	var done chan struct{}
+	if synchronous {
+		done = fc.waiters.Wait(task.ID)
+	}
+
+	// Une tâche dont les dépendances ne sont pas terminées attend sans réserver de ressources
+	fc.mu.Lock()
+	fc.enqueueWhenReadyLocked(&task)
+	fc.mu.Unlock()
+	fc.journal.Record(&task)
+
+	slog.Info("Tâche soumise", "task_id", task.ID, "status", task.Status, "owner", task.Owner, "type", task.Type, "priority", task.Priority,
+		"criticality", task.Criticality, "smart_score", task.SmartScore, "estimated_latency_ms", task.EstimatedLatency.Milliseconds(),
+		"cpu", task.CPUCost, "ram", task.RAMCost, "storage", task.StorageCost, "energy", task.EnergyCost, "trace_id", task.TraceID)
+
+	if synchronous {
+		fc.respondWhenDone(w, r, task.ID, done, wait)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+func (fc *FogCompute) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID := vars["id"]
+
+	tenant, all := fc.requestTenant(r)
+	fc.mu.RLock()
+	task, exists := fc.tasks[taskID]
+	fc.mu.RUnlock()
+	if !exists {
+		// Oubliée par la rétention: l'archive en garde le dernier état
+		if task, exists = fc.archivedTask(taskID); exists {
+			w.Header().Set("X-Fog-Archived", "true")
+		}
+	}
+
+	// Une tâche d'un autre namespace est introuvable pour le client
+	if !exists || !visibleTo(task, tenant, all) {
+		http.Error(w, "Tâche non trouvée", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// handleGetRejectedTasks retourne les tâches rejetées du namespace du client
+func (fc *FogCompute) handleGetRejectedTasks(w http.ResponseWriter, r *http.Request) {
+	tenant, all := fc.requestTenant(r)
+	fc.mu.RLock()
+	rejectedTasks := make([]RejectedTask, 0, len(fc.rejectedTasks))
+	for _, rt := range fc.rejectedTasks {
+		if visibleTo(&rt.Task, tenant, all) {
+			rejectedTasks = append(rejectedTasks, rt)
+		}
+	}
+	fc.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total": len(rejectedTasks),
+		"tasks": rejectedTasks,
+	})
+}
+
+// handleRetryRejectedTask permet de réessayer une tâche rejetée spécifique
+func (fc *FogCompute) handleRetryRejectedTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID := vars["id"]
+	tenant, all := fc.requestTenant(r)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	// Trouver la tâche rejetée
+	var foundIndex = -1
+	var taskToRetry Task
+	
+	for i, rt := range fc.rejectedTasks {
+		if rt.Task.ID == taskID && visibleTo(&rt.Task, tenant, all) {
+			foundIndex = i
+			taskToRetry = rt.Task
+			break
+		}
+	}
+
+	if foundIndex == -1 {
+		http.Error(w, "Tâche rejetée non trouvée", http.StatusNotFound)
+		return
+	}
+
+	if fc.draining {
+		http.Error(w, "Nœud en cours de drainage: soumissions suspendues", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Vérifier si les ressources sont maintenant disponibles
+	if taskToRetry.CPUCost > fc.availableCPU || taskToRetry.RAMCost > fc.availableRAM || 
+	   taskToRetry.StorageCost > fc.availableStorage {
+		http.Error(w, "Ressources toujours insuffisantes pour réessayer la tâche", http.StatusServiceUnavailable)
+		return
+	}
+
+	taskToRetry.QueueClass = admissionClass(&taskToRetry)
+	if full, reason := fc.queueClassFull(taskToRetry.QueueClass); full {
+		http.Error(w, reason, http.StatusServiceUnavailable)
+		return
+	}
+	tenantAC := &AdmissionContext{Task: &taskToRetry, Request: r, Tenant: fc.tenants.usageOf(tenantOf(&taskToRetry))}
+	if rej := checkTenant(fc, tenantAC); rej != nil {
+		rej.write(w)
+		return
+	}
+
+	// Retirer de la queue des rejets
+	fc.rejectedTasks = append(fc.rejectedTasks[:foundIndex], fc.rejectedTasks[foundIndex+1:]...)
+	fc.storeErr("delete_rejected", taskID, fc.store.DeleteRejected(taskID))
+
+	// Mettre à jour le statut de la tâche et resoumettre
+	taskToRetry.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceRetry, Client: r.RemoteAddr, RetryOf: taskID})
+	taskToRetry.Status = "queued"
+	taskToRetry.SubmittedAt = time.Now()
+	// Recalculer le SmartScore au cas où les conditions auraient changé
+	taskToRetry.SmartScore = taskToRetry.calculateScore()
+
+	fc.enqueueWhenReadyLocked(&taskToRetry)
+	fc.journal.Record(&taskToRetry)
+
+	slog.Info("Réessai de la tâche rejetée", "task_id", taskID, "owner", taskToRetry.Owner, "client", requestClient(r),
+		"priority", taskToRetry.Priority, "smart_score", taskToRetry.SmartScore)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tâche resoumise avec succès",
+		"task":    taskToRetry,
+	})
+}
+
+// handleClearRejectedTasks efface toutes les tâches rejetées
+func (fc *FogCompute) handleClearRejectedTasks(w http.ResponseWriter, r *http.Request) {
+	fc.mu.Lock()
+	count := len(fc.rejectedTasks)
+	fc.rejectedTasks = make([]RejectedTask, 0)
+	fc.storeErr("clear_rejected", "", fc.store.ClearRejected())
+	fc.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Tâches rejetées effacées",
+		"count":   count,
+	})
+}
+
+func (fc *FogCompute) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	fc.mu.RLock()
+	node := fc.node
+	fc.mu.RUnlock()
+
+	clock := fc.clock.Status()
+	node.Clock = &clock
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(node)
+}
+
+func (fc *FogCompute) handleGetMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.metricsSnapshot())
+}
+
+// metricsSnapshot rassemble les métriques courantes du nœud
+func (fc *FogCompute) metricsSnapshot() map[string]interface{} {
+	fc.metrics.mu.RLock()
+	tasksProcessed := fc.metrics.TasksProcessed
+	tasksRejected := fc.metrics.TasksRejected
+	avgLatency := fc.metrics.AvgLatency
+	currentLoad := fc.metrics.CurrentLoad
+	deadlinesMet := fc.metrics.DeadlinesMet
+	deadlinesMissed := fc.metrics.DeadlinesMissed
+	expiredDropped := fc.metrics.ExpiredDropped
+	tasksTimedOut := fc.metrics.TasksTimedOut
+	tasksFailed := fc.metrics.TasksFailed
+	tasksRetried := fc.metrics.TasksRetried
+	tasksOffloaded := fc.metrics.TasksOffloaded
+	tasksRedirected := fc.metrics.TasksRedirected
+	tasksDeferred := fc.metrics.TasksDeferred
+	fc.metrics.mu.RUnlock()
+
+	fc.mu.RLock()
+	rejectedCount := len(fc.rejectedTasks)
+	failedCount := fc.failedQueueSizeLocked()
+	retention := fc.retention.Stats(fc.retainedLocked())
+	rejectedEviction := fc.rejectedEvictionStats()
+	admissionTokens := len(fc.admissionTokens)
+	reservedWorkers := fc.reservedWorkerStats()
+	workerPool := fc.workerPoolStatusLocked()
+	queueClassStats := fc.queueClassStats()
+	tenants := fc.tenantStatusLocked()
+	now := time.Now()
+	load := fc.loadLocked(fc.host.Sample(), now)
+	power := fc.powerStatusLocked(now)
+	queueWait := fc.queueWaitStatsLocked(now)
+	preemption := fc.preemptionStatsLocked()
+	backpressure := fc.backpressureStatsLocked(now)
+	delayed := fc.delayedStatsLocked()
+	maintenance := fc.maintenanceStatusLocked()
+	rejectedRetry := fc.rejectedRetryStatsLocked()
+	powerMode := fc.powerModeStatsLocked()
+	overdueQueued := 0
+	for _, t := range fc.tasks {
+		if t.Status == "queued" && t.Deadline != nil && now.After(*t.Deadline) {
+			overdueQueued++
+		}
+	}
+	fc.mu.RUnlock()
+
+	return map[string]interface{}{
+		"tasks_processed":      tasksProcessed,
+		"tasks_rejected":       tasksRejected,
+		"rejected_queue_size":  rejectedCount,
+		"failed_queue_size":    failedCount,
+		"task_retention":       retention,
+		"rejected_queue":       rejectedEviction,
+		"rejected_retry":       rejectedRetry,
+		"avg_latency_ms":       avgLatency.Milliseconds(),
+		"current_load":         currentLoad,
+		"deadlines_met":        deadlinesMet,
+		"deadlines_missed":     deadlinesMissed,
+		"expired_dropped":      expiredDropped,
+		"overdue_queued":       overdueQueued,
+		"queue_wait":           queueWait,
+		"preemption":           preemption,
+		"overflow":             backpressure,
+		"delayed":              delayed,
+		"maintenance":          maintenance,
+		"tasks_timed_out":      tasksTimedOut,
+		"tasks_failed":         tasksFailed,
+		"tasks_retried":        tasksRetried,
+		"tasks_offloaded":      tasksOffloaded,
+		"tasks_redirected":     tasksRedirected,
+		"tasks_deferred":       tasksDeferred,
+		"devices":              fc.shaper.Stats(),
+		"device_registry":      fc.devices.Summary(time.Now().Add(-deviceIdleTTL)),
+		"rejections_by_policy": fc.admission.Rejections(),
+		"admission_tokens":     admissionTokens,
+		"queue_classes":        queueClassStats,
+		"priority_classes":     fc.priorityClassStatsLocked(),
+		"reserved_workers":     reservedWorkers,
+		"worker_pool":          workerPool,
+		"tenants":              tenants,
+		"power":                power,
+		"power_mode":           powerMode,
+		"energy_workers":       fc.energyWorkers.Stats(),
+		"cache":                dataCache.Stats(),
+		"telemetry":            telemetry.Stats(),
+		"standby":              fc.standby.Status(),
+		"host":                 fc.host.Stats(),
+		"carbon":               fc.carbon.Stats(),
+		"load":                 load,
+		"peers":                fc.peers.Summary(),
+		"nodes":                fc.nodes.Summary(),
+		"http_qos":             fc.qos.Stats(),
+		"http_timeouts":        fc.timeouts.Stats(),
+		"auth":                 fc.auth.Stats(),
+		"api_versions":         fc.legacy.Stats(),
+		"content_negotiation":  fc.negotiation.Stats(),
+		"tls":                  nodeTLS.Stats(),
+		"uplink":               fc.uplink.Status(),
+		"cloud":                fc.cloud.Stats(),
+		"mqtt":                 fc.mqtt.Stats(),
+		"callbacks":            fc.callbacks.Stats(),
+		"events":               fc.events.Stats(),
+		"journal":              fc.journal.Stats(),
+		"calendar":             fc.calendarStatus(),
+		"started_at":           fc.startedAt,
+		"intervals":            fc.intervals.Windows(fc.counterSample()),
+		"tracing":              fc.tracer.Stats(),
+	}
+}
+
+// handleHealth indique que le processus est vivant (/healthz, et /health
+// conservé pour les sondes existantes), sans préjuger de sa disponibilité
+func (fc *FogCompute) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "healthy",
+		"node":   fc.node.ID,
+	})
+}
+
+// ReadinessStatus est la réponse de GET /readyz
+type ReadinessStatus struct {
+	Status string            `json:"status"` // ready ou not_ready
+	Node   string            `json:"node"`
+	Checks map[string]string `json:"checks"` // "ok" ou la raison de l'indisponibilité
+}
+
+// handleReady indique si le nœud accepte de nouvelles tâches: pas de
+// drainage, stockage joignable et charge sous le seuil d'admission. Un nœud
+// saturé ou en drainage répond 503 pour que l'équilibreur l'évite.
+func (fc *FogCompute) handleReady(w http.ResponseWriter, r *http.Request) {
+	fc.mu.RLock()
+	draining := fc.draining
+	load, threshold := fc.node.Load, fc.load.threshold
+	fc.mu.RUnlock()
+
+	status := ReadinessStatus{Status: "ready", Node: fc.node.ID, Checks: map[string]string{
+		"admission": "ok",
+		"storage":   "ok",
+		"load":      "ok",
+	}}
+	if draining {
+		status.Checks["admission"] = "drainage en cours"
+	}
+	if err := pingStore(fc.store); err != nil {
+		status.Checks["storage"] = fmt.Sprintf("%s injoignable: %v", fc.store.Name(), err)
+	}
+	if load > threshold {
+		status.Checks["load"] = fmt.Sprintf("charge %.2f au-delà du seuil %.2f", load, threshold)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	for _, check := range status.Checks {
+		if check != "ok" {
+			status.Status = "not_ready"
+		}
+	}
+	if status.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// newRouter crée un routeur avec les middlewares communs à tous les listeners
+func (fc *FogCompute) newRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	// Corps CBOR ou MessagePack traduits en JSON avant tout middleware qui lit
+	// le corps, réponses encodées selon Accept
+	r.Use(fc.negotiation.middleware)
+
+	// Sous surcharge, les sondes et les tâches critiques passent avant les listings
+	r.Use(fc.qos.middleware)
+
+	// Délai de traitement par route, propagé aux appels sortants des handlers
+	r.Use(fc.timeouts.middleware)
+
+	// Horodatage serveur et dérive d'horloge dans chaque réponse
+	r.Use(fc.clockHeadersMiddleware)
+
+	// Middleware CORS
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+AdmissionTokenHeader+", "+AnnotationAuthorHeader+", "+APIKeyHeader+", "+TenantHeader)
+			
+			// Gérer les requêtes preflight
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	// Certificat client exigé sur les routes entre nœuds (TLS_CLIENT_AUTH=peer)
+	r.Use(nodeTLS.middleware)
+
+	// Clé d'API et portée de la route, après CORS pour que les refus restent lisibles des navigateurs
+	r.Use(fc.auth.middleware)
+	return r
+}
+
+// registerProbeRoutes enregistre les sondes de santé, hors versionnement de l'API
+func (fc *FogCompute) registerProbeRoutes(r *mux.Router) {
+	r.HandleFunc("/health", fc.handleHealth).Methods("GET")
+	r.HandleFunc("/healthz", fc.handleHealth).Methods("GET")
+	r.HandleFunc("/readyz", fc.handleReady).Methods("GET")
+}
+
+// registerPublicRoutes enregistre l'API de tâches exposée au réseau des appareils
+func (fc *FogCompute) registerPublicRoutes(r *mux.Router) {
+	r.HandleFunc("/status", fc.handleGetStatus).Methods("GET")
+	r.HandleFunc("/metrics", fc.handleGetMetrics).Methods("GET")
+	r.HandleFunc("/metrics/prometheus", fc.handlePrometheusMetrics).Methods("GET")
+	r.HandleFunc("/metrics/delta", fc.handleGetMetricsDelta).Methods("GET")
+	r.HandleFunc("/events", fc.handleEvents).Methods("GET")
+	r.HandleFunc("/capabilities", fc.handleGetCapabilities).Methods("GET")
+	r.HandleFunc("/openapi.json", fc.handleOpenAPI).Methods("GET")
+	r.HandleFunc("/costs", fc.handleGetCosts).Methods("GET")
+	r.HandleFunc("/calibration", fc.handleGetCalibration).Methods("GET")
+	r.HandleFunc("/peers", fc.handleGetPeers).Methods("GET")
+	r.HandleFunc("/uplink", fc.handleGetUplink).Methods("GET")
+	r.HandleFunc("/calendar", fc.handleGetCalendar).Methods("GET")
+	r.HandleFunc("/tenants", fc.handleGetTenants).Methods("GET")
+	r.HandleFunc("/power", fc.handleGetPower).Methods("GET")
+	r.HandleFunc("/standby", fc.handleGetStandby).Methods("GET")
+	r.HandleFunc("/standby/replicate", fc.handleReplicate).Methods("POST")
+	r.HandleFunc("/gossip", fc.handleGossip).Methods("POST")
+	r.HandleFunc("/nodes", fc.handleGetNodes).Methods("GET")
+	r.HandleFunc("/nodes", fc.handleRegisterNode).Methods("POST")
+	r.HandleFunc("/nodes/{id}/heartbeat", fc.handleNodeHeartbeat).Methods("POST")
+	r.HandleFunc("/nodes/{id}", fc.handleDeregisterNode).Methods("DELETE")
+	r.HandleFunc("/devices", fc.handleGetDevices).Methods("GET")
+	r.HandleFunc("/devices", fc.handleRegisterDevice).Methods("POST")
+	r.HandleFunc("/devices/{id}", fc.handleGetDevice).Methods("GET")
+	r.HandleFunc("/devices/{id}", fc.handleDeleteDevice).Methods("DELETE")
+	r.HandleFunc("/tasks", fc.handleSubmitTask).Methods("POST")
+	r.HandleFunc("/tasks", fc.handleListTasks).Methods("GET")
+	r.HandleFunc("/tasks/admission", fc.handleRequestAdmission).Methods("POST")
+	r.HandleFunc("/tasks/sync", fc.handleSubmitTask).Methods("POST")
+	r.HandleFunc("/tasks/batch", fc.handleSubmitBatch).Methods("POST")
+	r.HandleFunc("/tasks/{id}", fc.handleGetTask).Methods("GET")
+	r.HandleFunc("/tasks/{id}/result", fc.handleGetTaskResult).Methods("GET")
+	r.HandleFunc("/tasks/{id}/annotations", fc.handleAnnotateTask).Methods("POST")
+	r.HandleFunc("/tasks/{id}/progress", fc.handleTaskProgress).Methods("POST")
+	r.HandleFunc("/workflows/{id}", fc.handleGetWorkflow).Methods("GET")
+	r.HandleFunc("/groups/{id}", fc.handleGetGroup).Methods("GET")
+	r.HandleFunc("/cache/{key}", fc.handleGetCacheEntry).Methods("GET")
+	r.HandleFunc("/cache/{key}", fc.handleDeleteCacheEntry).Methods("DELETE")
+	r.HandleFunc("/telemetry", fc.handleGetTelemetry).Methods("GET")
+	r.HandleFunc("/telemetry", fc.handleIngestTelemetry).Methods("POST")
+	r.HandleFunc("/models", fc.handleGetModels).Methods("GET")
+	r.HandleFunc("/models/{name}", fc.handleGetModel).Methods("GET")
+	r.HandleFunc("/models/{name}", fc.handlePushModel).Methods("POST")
+	r.HandleFunc("/models/{name}", fc.handleDeleteModel).Methods("DELETE")
+	r.HandleFunc("/models/{name}/{version}", fc.handleGetModelVersion).Methods("GET")
+	r.HandleFunc("/models/{name}/{version}", fc.handleDeleteModel).Methods("DELETE")
+	r.HandleFunc("/schedules", fc.handleGetSchedules).Methods("GET")
+	r.HandleFunc("/schedules", fc.handleCreateSchedule).Methods("POST")
+	r.HandleFunc("/schedules/{id}/pause", fc.handlePauseSchedule).Methods("POST")
+	r.HandleFunc("/schedules/{id}/resume", fc.handleResumeSchedule).Methods("POST")
+	r.HandleFunc("/schedules/{id}", fc.handleDeleteSchedule).Methods("DELETE")
+	
+	// Endpoints pour gérer les tâches rejetées
+	r.HandleFunc("/rejected-tasks", fc.handleGetRejectedTasks).Methods("GET")
+	r.HandleFunc("/rejected-tasks/{id}/retry", fc.handleRetryRejectedTask).Methods("POST")
+	r.HandleFunc("/rejected-tasks/{id}/annotations", fc.handleAnnotateRejectedTask).Methods("POST")
+
+	// Endpoints pour gérer les tâches en échec (dead-letter queue)
+	r.HandleFunc("/failed-tasks", fc.handleGetFailedTasks).Methods("GET")
+	r.HandleFunc("/failed-tasks/{id}", fc.handleGetFailedTask).Methods("GET")
+	r.HandleFunc("/failed-tasks/{id}/retry", fc.handleRetryFailedTask).Methods("POST")
+}
+
+// registerAdminRoutes enregistre les routes dangereuses (maintenance, purge)
+func (fc *FogCompute) registerAdminRoutes(r *mux.Router) {
+	r.HandleFunc("/rejected-tasks", fc.handleClearRejectedTasks).Methods("DELETE")
+	r.HandleFunc("/failed-tasks", fc.handlePurgeFailedTasks).Methods("DELETE")
+	r.HandleFunc("/admin/update", fc.handleGetUpdate).Methods("GET")
+	r.HandleFunc("/admin/update", fc.handleApplyUpdate).Methods("POST")
+	r.HandleFunc("/admin/calendar", fc.handleAddCapacityWindow).Methods("POST")
+	r.HandleFunc("/admin/calendar/{id}", fc.handleDeleteCapacityWindow).Methods("DELETE")
+	r.HandleFunc("/admin/scheduler-trace", fc.handleGetSchedulerTrace).Methods("GET")
+	r.HandleFunc("/admin/scheduler-trace", fc.handleSetSchedulerTrace).Methods("POST")
+	r.HandleFunc("/admin/workers", fc.handleGetWorkers).Methods("GET")
+	r.HandleFunc("/admin/workers", fc.handleSetWorkers).Methods("PUT")
+	r.HandleFunc("/admin/pause", fc.handlePause).Methods("POST")
+	r.HandleFunc("/admin/resume", fc.handleResume).Methods("POST")
+	r.HandleFunc("/admin/drain", fc.handleDrain).Methods("POST")
+	r.HandleFunc("/admin/maintenance", fc.handleGetMaintenance).Methods("GET")
+	r.HandleFunc("/uplink/flush", fc.handleFlushUplink).Methods("POST")
+	r.HandleFunc("/admin/reload", fc.handleReload).Methods("POST")
+}
+
+// registerDebugRoutes expose le profilage pprof; réservé au listener d'administration
+func registerDebugRoutes(r *mux.Router) {
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+}
+
+// Run configure et démarre le nœud à partir de l'environnement et du fichier
+// de configuration, jusqu'à SIGINT ou SIGTERM
+func Run() {
+	// Le fichier de configuration fournit les réglages absents de l'environnement
+	configErr := loadConfigFile()
+
+	nodeID := getEnv("NODE_ID", "fog-node-1")
+	location := getEnv("LOCATION", "edge-site-1")
+	port := getEnv("PORT", "8080")
+
+	setupLogging(nodeID)
+	if configErr != nil {
+		fatal("Fichier de configuration illisible", "error", configErr)
+	}
+	scoreWeights = loadScoreWeights()
+	taskAging = loadAgingPolicy()
+	taskDefaultCosts = loadTaskDefaultCosts()
+	loadWASM()
+	loadContainerExecutor()
+	loadCommandExecutor()
+	loadScripts()
+	loadDataCache()
+	loadTelemetry()
+	loadModelStore()
+	loadInference()
+	if err := loadHandlerPlugins(); err != nil {
+		fatal("Chargement des plugins de handlers impossible", "error", err)
+	}
+
+	var err error
+	if nodeTLS, err = loadTLSSettings(); err != nil {
+		fatal("Configuration TLS invalide", "error", err)
+	}
+
+	// Mode simulation: expérience hors ligne sur une topologie de nœuds virtuels
+	if getEnvBool("SIMULATION", false) || getEnv("SIMULATION_CONFIG", "") != "" {
+		if err := runSimulation(); err != nil {
+			fatal("Simulation impossible", "error", err)
+		}
+		return
+	}
+
+	// Mode rejeu: journal des soumissions rejoué contre un nœud ou le simulateur
+	if getEnv("REPLAY", "") != "" {
+		if err := runReplay(); err != nil {
+			fatal("Rejeu impossible", "error", err)
+		}
+		return
+	}
+
+	fc := NewFogCompute(nodeID, location)
+	if err := config.Validate(); err != nil {
+		fatal("Configuration invalide", "error", err)
+	}
+	defer fc.store.Close()
+	defer fc.journal.Close()
+	listenAddr := getEnv("LISTEN_ADDR", ":"+port)
+	fc.updater = NewUpdater(fc, listenAddr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fc.Start(ctx)
+
+	// Configuration des routes HTTP: l'API publique, et les routes
+	// d'administration sur un listener séparé si ADMIN_ADDR est défini, sous
+	// /api/v1 et sans préfixe pendant la fenêtre de dépréciation
+	adminAddr := getEnv("ADMIN_ADDR", "")
+	r := fc.newRouter()
+	fc.registerProbeRoutes(r)
+	fc.registerAPI(r, fc.registerPublicRoutes)
+
+	listeners := []*listener{tcpListener("public", listenAddr, r)}
+
+	if adminAddr != "" {
+		admin := fc.newRouter()
+		fc.registerAPI(admin, fc.registerAdminRoutes)
+		registerDebugRoutes(admin)
+		listeners = append(listeners, tcpListener("admin", adminAddr, admin))
+	} else {
+		fc.registerAPI(r, fc.registerAdminRoutes)
+	}
+
+	// Socket Unix optionnel pour les clients co-localisés
+	if socketPath := getEnv("UNIX_SOCKET", ""); socketPath != "" {
+		mode := parseFileMode(getEnv("UNIX_SOCKET_MODE", "0660"), 0o660)
+		listeners = append(listeners, unixListener("unix", socketPath, mode, r))
+	}
+	fc.transports = listenerTransports(listeners)
+
+	// Rechargement de la configuration sur SIGHUP
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for range sighup {
+			if _, err := fc.reloadConfig(); err != nil {
+				slog.Error("Rechargement de la configuration refusé", "error", err)
+			}
+		}
+	}()
+
+	// Arrêt gracieux
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+		<-sigint
+
+		slog.Info("Arrêt du serveur")
+		// Plus aucune admission; les exécutions en cours se terminent avant l'arrêt des listeners
+		fc.drainForShutdown(fc.shutdownDrain)
+		cancel()
+		if err := telemetry.Save(); err != nil {
+			slog.Error("Sauvegarde de la télémétrie impossible", "error", err)
+		}
+		fc.devices.Save()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+
+		fc.leaveCluster(shutdownCtx)
+		shutdownAll(shutdownCtx, listeners)
+	}()
+
+	config.WarnUnused()
+	slog.Info("Nœud fog computing démarré", "listeners", len(listeners))
+	serveAll(listeners)
+}
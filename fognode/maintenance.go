@@ -0,0 +1,167 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Maintenance est l'état de pause et de drainage fixé par l'opérateur
+// (/admin/pause, /admin/resume, /admin/drain). Il n'est pas persisté: un
+// nœud redémarré reprend l'exécution et les admissions. Protégé par fc.mu.
+type Maintenance struct {
+	paused        bool // Les workers ne prennent plus de tâche
+	holdAdmission bool // Les admissions restent fermées malgré la fin d'un drainage automatique (mise à jour)
+	pausedAt      *time.Time
+	drainStarted  *time.Time
+	drainedAt     *time.Time // Queue vide et plus aucune exécution depuis le début du drainage
+}
+
+// MaintenanceStatus est la réponse des routes de maintenance
+type MaintenanceStatus struct {
+	Paused               bool       `json:"paused"`
+	AcceptingSubmissions bool       `json:"accepting_submissions"`
+	Draining             bool       `json:"draining"`
+	Drained              bool       `json:"drained"` // Drainage terminé: queue vide, aucune exécution en cours
+	Queued               int        `json:"queued"`
+	InFlight             int        `json:"in_flight"`
+	PausedAt             *time.Time `json:"paused_at,omitempty"`
+	DrainStartedAt       *time.Time `json:"drain_started_at,omitempty"`
+	DrainedAt            *time.Time `json:"drained_at,omitempty"`
+}
+
+// maintenanceStatusLocked retourne l'état de maintenance; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) maintenanceStatusLocked() MaintenanceStatus {
+	m := fc.maintenance
+	status := MaintenanceStatus{
+		Paused:               m.paused,
+		AcceptingSubmissions: !fc.draining,
+		Draining:             fc.draining,
+		Queued:               fc.scheduler.Len(),
+		InFlight:             fc.inFlight,
+		PausedAt:             m.pausedAt,
+		DrainStartedAt:       m.drainStarted,
+		DrainedAt:            m.drainedAt,
+	}
+	status.Drained = fc.draining && status.Queued == 0 && status.InFlight == 0
+	return status
+}
+
+// pause arrête la distribution des tâches aux workers; les exécutions en
+// cours se terminent. Sans accept, les admissions sont aussi fermées.
+func (fc *FogCompute) pause(accept bool) MaintenanceStatus {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if !fc.maintenance.paused {
+		now := time.Now()
+		fc.maintenance.paused, fc.maintenance.pausedAt = true, &now
+	}
+	if !accept {
+		fc.maintenance.holdAdmission = true
+		fc.draining = true
+	}
+	return fc.maintenanceStatusLocked()
+}
+
+// drain ferme les admissions et laisse la queue se vider; la fin du
+// drainage est journalisée et datée (drained_at)
+func (fc *FogCompute) drain() MaintenanceStatus {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.maintenance.holdAdmission = true
+	fc.draining = true
+	if fc.maintenance.drainStarted == nil {
+		now := time.Now()
+		fc.maintenance.drainStarted, fc.maintenance.drainedAt = &now, nil
+		go fc.watchDrain(now)
+	}
+	return fc.maintenanceStatusLocked()
+}
+
+// resume relance la distribution et rouvre les admissions, sauf pendant un
+// arrêt ou sur un standby qui n'a pas encore repris
+func (fc *FogCompute) resume() MaintenanceStatus {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.maintenance = Maintenance{}
+	if !fc.stopping && !fc.standby.Standby() {
+		fc.draining = false
+	}
+	fc.cond.Broadcast()
+	return fc.maintenanceStatusLocked()
+}
+
+// watchDrain date la fin du drainage commencé à started
+func (fc *FogCompute) watchDrain(started time.Time) {
+	for {
+		idle := fc.waitIdle(context.Background(), time.Minute)
+		fc.mu.Lock()
+		current := fc.maintenance.drainStarted != nil && fc.maintenance.drainStarted.Equal(started)
+		if current && idle {
+			now := time.Now()
+			fc.maintenance.drainedAt = &now
+			slog.Info("Drainage terminé: queue vide, aucune exécution en cours", "elapsed", now.Sub(started).String())
+		}
+		fc.mu.Unlock()
+		if !current || idle {
+			return
+		}
+	}
+}
+
+// handlePause suspend la distribution des tâches ({"accept_submissions": false}
+// pour refuser aussi les soumissions)
+func (fc *FogCompute) handlePause(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		AcceptSubmissions *bool `json:"accept_submissions"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	accept := body.AcceptSubmissions == nil || *body.AcceptSubmissions
+	status := fc.pause(accept)
+	slog.Info("Nœud en pause", "accept_submissions", status.AcceptingSubmissions, "queued", status.Queued, "client", requestClient(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleResume lève la pause et le drainage
+func (fc *FogCompute) handleResume(w http.ResponseWriter, r *http.Request) {
+	status := fc.resume()
+	slog.Info("Nœud relancé", "accept_submissions", status.AcceptingSubmissions, "queued", status.Queued, "client", requestClient(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleDrain ferme les admissions avant maintenance
+func (fc *FogCompute) handleDrain(w http.ResponseWriter, r *http.Request) {
+	status := fc.drain()
+	slog.Info("Drainage demandé", "queued", status.Queued, "in_flight", status.InFlight, "client", requestClient(r))
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Drained {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleGetMaintenance retourne l'état de pause et de drainage; ?wait=30s
+// attend la fin du drainage dans la limite donnée
+func (fc *FogCompute) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	if wait, err := time.ParseDuration(r.URL.Query().Get("wait")); err == nil && wait > 0 {
+		fc.mu.RLock()
+		draining := fc.draining
+		fc.mu.RUnlock()
+		if draining {
+			fc.waitIdle(r.Context(), wait)
+		}
+	}
+	fc.mu.RLock()
+	status := fc.maintenanceStatusLocked()
+	fc.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
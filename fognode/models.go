@@ -0,0 +1,398 @@
+package fognode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ModelMetadataHeader porte les métadonnées libres d'un modèle poussé
+// (objet JSON de chaînes)
+const ModelMetadataHeader = "X-Fog-Model-Metadata"
+
+// modelNamePattern restreint les noms et versions de modèles: ni séparateur
+// de chemin, ni "..", ni "@"
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+var (
+	errModelNotFound = errors.New("modèle introuvable")
+	errModelExists   = errors.New("version de modèle déjà présente")
+	errModelTooLarge = errors.New("modèle trop volumineux")
+)
+
+// ModelVersion décrit une version d'un modèle du magasin
+type ModelVersion struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Format      string            `json:"format"`
+	SHA256      string            `json:"sha256"`
+	SizeBytes   int64             `json:"size_bytes"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ModelSummary décrit un modèle et ses versions, de la plus ancienne à la plus récente
+type ModelSummary struct {
+	Name     string         `json:"name"`
+	Latest   string         `json:"latest"`
+	Versions []ModelVersion `json:"versions"`
+}
+
+// ModelStore est le magasin de modèles du nœud: chaque version d'un modèle
+// est un fichier MODEL_DIR/<nom>/<version>.onnx accompagné de ses
+// métadonnées <version>.json. Les handlers désignent un modèle par
+// "nom@version", ou par "nom" pour sa version la plus récente.
+type ModelStore struct {
+	Dir      string
+	MaxBytes int64      // Taille maximale d'un modèle poussé
+	mu       sync.Mutex // Sérialise les ajouts et suppressions
+}
+
+// models est le magasin de modèles du nœud (loadModelStore)
+var models *ModelStore
+
+// loadModelStore lit MODEL_DIR et MODEL_MAX_MB et range dans le magasin les
+// modèles déposés directement dans MODEL_DIR (<nom>.onnx), en version 1
+func loadModelStore() {
+	models = &ModelStore{
+		Dir:      getEnv("MODEL_DIR", filepath.Join(getEnv("DATA_DIR", "data"), "models")),
+		MaxBytes: int64(getEnvInt("MODEL_MAX_MB", 256)) << 20,
+	}
+	if models.MaxBytes <= 0 {
+		config.errorf("MODEL_MAX_MB", "la taille maximale d'un modèle doit être positive")
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(models.Dir, "*.onnx"))
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".onnx")
+		if err := models.adopt(name, path); err != nil {
+			slog.Warn("Modèle non rangé dans le magasin", "path", path, "error", err)
+			continue
+		}
+		slog.Info("Modèle rangé dans le magasin", "model", name, "version", "1")
+	}
+}
+
+// adopt range un fichier de modèle en version 1 et le supprime
+func (s *ModelStore) adopt(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := s.Add(name, "1", f, "", "", nil); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// parseModelRef sépare le nom et la version d'une référence "nom@version"
+func parseModelRef(ref string) (name, version string, err error) {
+	name, version, versioned := strings.Cut(ref, "@")
+	if !modelNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("nom de modèle invalide %q", name)
+	}
+	if versioned && !modelNamePattern.MatchString(version) {
+		return "", "", fmt.Errorf("version de modèle invalide %q", version)
+	}
+	return name, version, nil
+}
+
+// modelPath retourne le fichier d'une version de modèle
+func (s *ModelStore) modelPath(name, version string) string {
+	return filepath.Join(s.Dir, name, version+".onnx")
+}
+
+// Versions retourne les versions d'un modèle, de la plus ancienne à la plus récente
+func (s *ModelStore) Versions(name string) ([]ModelVersion, error) {
+	if !modelNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("nom de modèle invalide %q", name)
+	}
+	files, err := filepath.Glob(filepath.Join(s.Dir, name, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]ModelVersion, 0, len(files))
+	for _, path := range files {
+		var v ModelVersion
+		if err := readJSONFile(path, &v); err != nil {
+			slog.Warn("Métadonnées de modèle illisibles", "path", path, "error", err)
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].CreatedAt.Equal(versions[j].CreatedAt) {
+			return versions[i].CreatedAt.Before(versions[j].CreatedAt)
+		}
+		return versions[i].Version < versions[j].Version
+	})
+	return versions, nil
+}
+
+// Resolve retourne la version désignée par une référence "nom@version" ou
+// "nom", et le fichier du modèle
+func (s *ModelStore) Resolve(ref string) (ModelVersion, string, error) {
+	name, version, err := parseModelRef(ref)
+	if err != nil {
+		return ModelVersion{}, "", err
+	}
+	if version == "" {
+		versions, err := s.Versions(name)
+		if err != nil {
+			return ModelVersion{}, "", err
+		}
+		if len(versions) == 0 {
+			return ModelVersion{}, "", fmt.Errorf("%w: %s", errModelNotFound, ref)
+		}
+		v := versions[len(versions)-1]
+		return v, s.modelPath(v.Name, v.Version), nil
+	}
+	var v ModelVersion
+	if err := readJSONFile(filepath.Join(s.Dir, name, version+".json"), &v); err != nil {
+		return ModelVersion{}, "", fmt.Errorf("%w: %s", errModelNotFound, ref)
+	}
+	return v, s.modelPath(name, version), nil
+}
+
+// List retourne les modèles du magasin, par nom
+func (s *ModelStore) List() ([]ModelSummary, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	summaries := make([]ModelSummary, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() || !modelNamePattern.MatchString(e.Name()) {
+			continue
+		}
+		versions, err := s.Versions(e.Name())
+		if err != nil || len(versions) == 0 {
+			continue
+		}
+		summaries = append(summaries, ModelSummary{Name: e.Name(), Latest: versions[len(versions)-1].Version, Versions: versions})
+	}
+	return summaries, nil
+}
+
+// Add enregistre une nouvelle version d'un modèle lue depuis r; sans
+// version, la suivante de la plus grande version numérique. Une empreinte
+// attendue est vérifiée avant l'enregistrement.
+func (s *ModelStore) Add(name, version string, r io.Reader, expectedSHA256, description string, metadata map[string]string) (ModelVersion, error) {
+	if !modelNamePattern.MatchString(name) {
+		return ModelVersion{}, fmt.Errorf("nom de modèle invalide %q", name)
+	}
+	if version != "" && !modelNamePattern.MatchString(version) {
+		return ModelVersion{}, fmt.Errorf("version de modèle invalide %q", version)
+	}
+	dir := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ModelVersion{}, err
+	}
+	defer os.Remove(dir) // Sans effet s'il contient des versions
+	tmp, err := os.CreateTemp(dir, ".upload-")
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, s.MaxBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || size > s.MaxBytes {
+		return ModelVersion{}, fmt.Errorf("%w: au plus %d Mo (MODEL_MAX_MB)", errModelTooLarge, s.MaxBytes>>20)
+	}
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	if size == 0 {
+		return ModelVersion{}, errors.New("modèle vide")
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, sum) {
+		return ModelVersion{}, fmt.Errorf("empreinte sha256 %s, %s attendue", sum, expectedSHA256)
+	}
+	if inference != nil {
+		if err := inference.Check(tmp.Name()); err != nil {
+			return ModelVersion{}, fmt.Errorf("modèle ONNX invalide: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.Versions(name)
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	if version == "" {
+		next := 1
+		for _, v := range versions {
+			if n, err := strconv.Atoi(v.Version); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+		version = strconv.Itoa(next)
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return ModelVersion{}, fmt.Errorf("%w: %s@%s", errModelExists, name, version)
+		}
+	}
+	v := ModelVersion{
+		Name:        name,
+		Version:     version,
+		Format:      "onnx",
+		SHA256:      sum,
+		SizeBytes:   size,
+		Description: description,
+		Metadata:    metadata,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := os.Rename(tmp.Name(), s.modelPath(name, version)); err != nil {
+		return ModelVersion{}, err
+	}
+	if err := writeJSONFile(filepath.Join(dir, version+".json"), v); err != nil {
+		os.Remove(s.modelPath(name, version))
+		return ModelVersion{}, err
+	}
+	return v, nil
+}
+
+// Delete supprime une version d'un modèle, ou toutes sans version; retourne
+// le nombre de versions supprimées
+func (s *ModelStore) Delete(name, version string) (int, error) {
+	if !modelNamePattern.MatchString(name) || (version != "" && !modelNamePattern.MatchString(version)) {
+		return 0, errModelNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.Versions(name)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, v := range versions {
+		if version != "" && v.Version != version {
+			continue
+		}
+		// Les métadonnées d'abord: une suppression interrompue ne laisse
+		// qu'un fichier de modèle invisible
+		if err := os.Remove(filepath.Join(s.Dir, name, v.Version+".json")); err != nil {
+			return deleted, err
+		}
+		os.Remove(s.modelPath(name, v.Version))
+		deleted++
+	}
+	if deleted == 0 {
+		return 0, errModelNotFound
+	}
+	if deleted == len(versions) {
+		os.RemoveAll(filepath.Join(s.Dir, name))
+	}
+	return deleted, nil
+}
+
+// handleGetModels liste les modèles du magasin et leurs versions
+func (fc *FogCompute) handleGetModels(w http.ResponseWriter, r *http.Request) {
+	list, err := models.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":  len(list),
+		"models": list,
+	})
+}
+
+// handleGetModel retourne les versions d'un modèle
+func (fc *FogCompute) handleGetModel(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	versions, err := models.Versions(name)
+	if err != nil || len(versions) == 0 {
+		http.Error(w, "Modèle non trouvé", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ModelSummary{Name: name, Latest: versions[len(versions)-1].Version, Versions: versions})
+}
+
+// handleGetModelVersion retourne les métadonnées d'une version d'un modèle
+func (fc *FogCompute) handleGetModelVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	v, _, err := models.Resolve(vars["name"] + "@" + vars["version"])
+	if err != nil {
+		http.Error(w, "Version de modèle non trouvée", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handlePushModel enregistre le corps de la requête comme nouvelle version
+// d'un modèle: ?version= (défaut: la suivante), ?sha256= (empreinte
+// vérifiée), ?description= et les métadonnées de l'en-tête X-Fog-Model-Metadata
+func (fc *FogCompute) handlePushModel(w http.ResponseWriter, r *http.Request) {
+	var metadata map[string]string
+	if raw := r.Header.Get(ModelMetadataHeader); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			http.Error(w, ModelMetadataHeader+": objet JSON de chaînes attendu", http.StatusBadRequest)
+			return
+		}
+	}
+	q := r.URL.Query()
+	body := http.MaxBytesReader(w, r.Body, models.MaxBytes)
+	v, err := models.Add(mux.Vars(r)["name"], q.Get("version"), body, q.Get("sha256"), q.Get("description"), metadata)
+	switch {
+	case errors.Is(err, errModelExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, errModelTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Info("Modèle ajouté", "model", v.Name, "version", v.Version, "sha256", v.SHA256, "size_bytes", v.SizeBytes, "client", requestClient(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/models/"+v.Name+"/"+v.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleDeleteModel supprime une version d'un modèle, ou toutes sans version
+func (fc *FogCompute) handleDeleteModel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deleted, err := models.Delete(vars["name"], vars["version"])
+	if errors.Is(err, errModelNotFound) {
+		http.Error(w, "Modèle non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Modèle supprimé", "model", vars["name"], "version", vars["version"], "versions", deleted, "client", requestClient(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,699 @@
+package fognode
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types de paquets MQTT 3.1.1
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPubrec     = 5
+	mqttPubrel     = 6
+	mqttPubcomp    = 7
+	mqttSubscribe  = 8
+	mqttSuback     = 9
+	mqttPingreq    = 12
+	mqttPingresp   = 13
+	mqttDisconnect = 14
+)
+
+const (
+	mqttMaxPacket    = 1 << 20 // Taille maximale d'un message de tâche accepté
+	mqttMaxBackoff   = 30 * time.Second
+	mqttWriteTimeout = 5 * time.Second
+)
+
+// Événements publiés sur le topic de résultats
+const (
+	MQTTAccepted  = "accepted"
+	MQTTRejected  = "rejected"
+	MQTTCompleted = "completed"
+	MQTTFailed    = "failed"
+	MQTTExpired   = "expired" // Échéance dépassée en queue: tâche abandonnée sans exécution
+)
+
+// mqttEvents sont les événements publiables, tous publiés sans MQTT_NOTIFY_EVENTS
+var mqttEvents = []string{MQTTAccepted, MQTTRejected, MQTTCompleted, MQTTFailed, MQTTExpired}
+
+// MQTTTaskEvent est publié sur le topic de résultats à chaque étape d'une
+// tâche reçue par MQTT: admission (ou rejet), puis résultat ou expiration.
+// Un rejet ou une expiration porte de quoi ralentir l'appareil: délai avant
+// réessai, débit autorisé par appareil et tâches de l'appareil encore en queue.
+type MQTTTaskEvent struct {
+	Event           string      `json:"event"`
+	TaskID          string      `json:"task_id,omitempty"`
+	DeviceID        string      `json:"device_id,omitempty"`
+	NodeID          string      `json:"node_id"`
+	Status          string      `json:"status"`
+	Reason          string      `json:"reason,omitempty"`
+	RetryAfterMs    int64       `json:"retry_after_ms,omitempty"`
+	RateLimitPerSec float64     `json:"rate_limit_per_sec,omitempty"` // DEVICE_RATE_LIMIT
+	DeviceQueued    *int        `json:"device_queued,omitempty"`
+	Deadline        *time.Time  `json:"deadline,omitempty"`
+	Result          interface{} `json:"result,omitempty"`
+	CompletedAt     *time.Time  `json:"completed_at,omitempty"`
+}
+
+// MQTTStats décrit l'état du pont MQTT
+type MQTTStats struct {
+	Enabled       bool       `json:"enabled"`
+	Connected     bool       `json:"connected"`
+	Broker        string     `json:"broker,omitempty"`
+	TaskTopic     string     `json:"task_topic,omitempty"`
+	Received      int        `json:"received"`       // Messages de tâche reçus
+	Invalid       int        `json:"invalid"`        // Messages illisibles
+	Published     int        `json:"published"`      // Événements publiés
+	Suppressed    int        `json:"suppressed"`     // Événements exclus par MQTT_NOTIFY_EVENTS
+	PublishFailed int        `json:"publish_failed"` // Événements perdus (broker injoignable)
+	Reconnects    int        `json:"reconnects"`
+	LastError     string     `json:"last_error,omitempty"`
+	ConnectedAt   *time.Time `json:"connected_at,omitempty"`
+}
+
+// MQTTBridge relie le nœud à un broker MQTT local: les messages publiés sur le
+// topic de tâches deviennent des soumissions, et l'admission puis le résultat
+// de chaque tâche sont publiés en retour, pour les appareils IoT qui ne
+// parlent que MQTT. Client MQTT 3.1.1 minimal (QoS 0 et 1, session propre).
+type MQTTBridge struct {
+	broker      string // URL du broker (tcp://, mqtt://, ssl:// ou mqtts://)
+	username    string
+	password    string
+	clientID    string
+	taskTopic   string
+	resultTopic string // Modèle: {node}, {device}, {id}
+	nodeID      string
+	qos         byte
+	keepAlive   time.Duration
+	notify      map[string]bool // Événements publiés (MQTT_NOTIFY_EVENTS)
+
+	conn      net.Conn
+	writeMu   sync.Mutex // Sérialise les écritures sur la connexion
+	packetID  uint16
+	connected bool
+
+	received      int
+	invalid       int
+	published     int
+	suppressed    int
+	publishFailed int
+	reconnects    int
+	lastError     string
+	connectedAt   *time.Time
+	mu            sync.Mutex
+}
+
+// NewMQTTBridge lit MQTT_BROKER, MQTT_USERNAME, MQTT_PASSWORD, MQTT_CLIENT_ID,
+// MQTT_TASK_TOPIC, MQTT_RESULT_TOPIC, MQTT_QOS, MQTT_KEEPALIVE et
+// MQTT_NOTIFY_EVENTS
+func NewMQTTBridge(nodeID string) *MQTTBridge {
+	node := mqttTopicLevel(nodeID)
+	qos := getEnvInt("MQTT_QOS", 1)
+	if qos < 0 || qos > 1 {
+		slog.Warn("MQTT_QOS non supporté", "value", qos, "default", 1)
+		qos = 1
+	}
+	events := getEnvList("MQTT_NOTIFY_EVENTS")
+	if len(events) == 0 {
+		events = mqttEvents
+	}
+	notify := make(map[string]bool, len(events))
+	for _, event := range events {
+		notify[event] = true
+	}
+	return &MQTTBridge{
+		broker:      getEnv("MQTT_BROKER", ""),
+		username:    getEnv("MQTT_USERNAME", ""),
+		password:    getEnv("MQTT_PASSWORD", ""),
+		clientID:    getEnv("MQTT_CLIENT_ID", "fog-"+nodeID),
+		taskTopic:   strings.ReplaceAll(getEnv("MQTT_TASK_TOPIC", "fog/{node}/tasks"), "{node}", node),
+		resultTopic: strings.ReplaceAll(getEnv("MQTT_RESULT_TOPIC", "fog/{node}/results/{device}"), "{node}", node),
+		nodeID:      nodeID,
+		qos:         byte(qos),
+		keepAlive:   getEnvDuration("MQTT_KEEPALIVE", 30*time.Second),
+		notify:      notify,
+	}
+}
+
+// Enabled indique si un broker MQTT est configuré
+func (m *MQTTBridge) Enabled() bool {
+	return m.broker != ""
+}
+
+// mqttTopicLevel rend une valeur utilisable comme niveau de topic (sans
+// séparateur ni joker)
+func mqttTopicLevel(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
+
+// topicFor retourne le topic de résultats d'une tâche
+func (m *MQTTBridge) topicFor(taskID, deviceID string) string {
+	return strings.NewReplacer(
+		"{device}", mqttTopicLevel(deviceID),
+		"{id}", mqttTopicLevel(taskID),
+	).Replace(m.resultTopic)
+}
+
+// Stats retourne l'état du pont MQTT
+func (m *MQTTBridge) Stats() MQTTStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := MQTTStats{
+		Enabled:       m.Enabled(),
+		Connected:     m.connected,
+		Received:      m.received,
+		Invalid:       m.invalid,
+		Published:     m.published,
+		Suppressed:    m.suppressed,
+		PublishFailed: m.publishFailed,
+		Reconnects:    m.reconnects,
+		LastError:     m.lastError,
+		ConnectedAt:   m.connectedAt,
+	}
+	if m.Enabled() {
+		stats.Broker = redactURL(m.broker)
+		stats.TaskTopic = m.taskTopic
+	}
+	return stats
+}
+
+// redactURL masque le mot de passe éventuel d'une URL de broker
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// Run maintient la connexion au broker et traite les messages de tâches
+// jusqu'à l'arrêt du nœud; la connexion est rétablie avec un délai croissant
+func (m *MQTTBridge) Run(ctx context.Context, handle func(topic string, payload []byte)) {
+	if !m.Enabled() {
+		return
+	}
+	backoff := time.Second
+	for {
+		err := m.session(ctx, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		m.mu.Lock()
+		m.connected = false
+		m.reconnects++
+		if err != nil {
+			m.lastError = err.Error()
+		}
+		m.mu.Unlock()
+		slog.Warn("Connexion MQTT perdue", "broker", redactURL(m.broker), "error", err, "retry_in", backoff.String())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > mqttMaxBackoff {
+			backoff = mqttMaxBackoff
+		}
+	}
+}
+
+// session ouvre une connexion, s'abonne au topic de tâches et lit les paquets
+// jusqu'à une erreur. La connexion est fermée à l'arrêt du nœud (DISCONNECT).
+func (m *MQTTBridge) session(ctx context.Context, handle func(topic string, payload []byte)) error {
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if err := m.handshake(conn, r); err != nil {
+		return err
+	}
+
+	m.writeMu.Lock()
+	m.conn = conn
+	m.writeMu.Unlock()
+	defer func() {
+		m.writeMu.Lock()
+		m.conn = nil
+		m.writeMu.Unlock()
+	}()
+
+	if err := m.write(mqttSubscribe<<4|0x2, m.subscribePacket()); err != nil {
+		return err
+	}
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-sessionCtx.Done()
+		if ctx.Err() != nil {
+			m.write(mqttDisconnect<<4, nil)
+		}
+		conn.Close()
+	}()
+	go m.keepAliveLoop(sessionCtx)
+
+	for {
+		// Sans trafic ni PINGRESP pendant 1,5 keepalive, le broker est considéré perdu
+		if m.keepAlive > 0 {
+			conn.SetReadDeadline(time.Now().Add(m.keepAlive * 3 / 2))
+		}
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		switch header >> 4 {
+		case mqttPublish:
+			topic, payload, err := m.ack(header, body)
+			if err != nil {
+				return err
+			}
+			m.mu.Lock()
+			m.received++
+			m.mu.Unlock()
+			handle(topic, payload)
+		case mqttSuback:
+			if len(body) < 3 || body[2] == 0x80 {
+				return fmt.Errorf("abonnement refusé par le broker: %s", m.taskTopic)
+			}
+			slog.Info("Abonné au topic de tâches MQTT", "topic", m.taskTopic, "qos", body[2])
+		case mqttPubrel:
+			// Livraison QoS 2 accordée malgré l'abonnement en QoS 1: terminer l'échange
+			if len(body) >= 2 {
+				m.write(mqttPubcomp<<4, body[:2])
+			}
+		}
+	}
+}
+
+// dial ouvre la connexion réseau vers le broker
+func (m *MQTTBridge) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(m.broker)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("MQTT_BROKER invalide: %q", m.broker)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if u.Scheme == "ssl" || u.Scheme == "mqtts" || u.Scheme == "tls" {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return dialer.DialContext(ctx, "tcp", host)
+	case "ssl", "mqtts", "tls":
+		return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", host)
+	}
+	return nil, fmt.Errorf("schéma MQTT non supporté: %q", u.Scheme)
+}
+
+// handshake envoie CONNECT et attend le CONNACK du broker
+func (m *MQTTBridge) handshake(conn net.Conn, r *bufio.Reader) error {
+	username, password := m.username, m.password
+	if u, err := url.Parse(m.broker); err == nil && u.User != nil && username == "" {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	// En-tête variable: nom et niveau du protocole, drapeaux, keepalive
+	flags := byte(0x02) // Session propre
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(m.keepAlive/time.Second))
+	body = appendMQTTString(body, m.clientID)
+	if username != "" {
+		body = appendMQTTString(body, username)
+		if password != "" {
+			body = appendMQTTString(body, password)
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write(encodeMQTTPacket(mqttConnect<<4, body)); err != nil {
+		return err
+	}
+	header, ack, err := readMQTTPacket(r)
+	if err != nil {
+		return err
+	}
+	if header>>4 != mqttConnack || len(ack) < 2 {
+		return errors.New("réponse inattendue du broker au CONNECT")
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("connexion refusée par le broker (code %d)", ack[1])
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	m.connected = true
+	m.lastError = ""
+	m.connectedAt = &now
+	m.mu.Unlock()
+	slog.Info("Connecté au broker MQTT", "broker", redactURL(m.broker), "client_id", m.clientID)
+	return nil
+}
+
+// subscribePacket construit le SUBSCRIBE du topic de tâches
+func (m *MQTTBridge) subscribePacket() []byte {
+	body := binary.BigEndian.AppendUint16(nil, m.nextPacketID())
+	body = appendMQTTString(body, m.taskTopic)
+	return append(body, m.qos)
+}
+
+// ack décode un PUBLISH reçu et l'acquitte selon sa QoS
+func (m *MQTTBridge) ack(header byte, body []byte) (string, []byte, error) {
+	topic, rest, err := readMQTTString(body)
+	if err != nil {
+		return "", nil, err
+	}
+	qos := (header >> 1) & 0x3
+	if qos == 0 {
+		return topic, rest, nil
+	}
+	if len(rest) < 2 {
+		return "", nil, errors.New("PUBLISH sans identifiant de paquet")
+	}
+	id, payload := rest[:2], rest[2:]
+	if qos == 2 {
+		return topic, payload, m.write(mqttPubrec<<4, id)
+	}
+	return topic, payload, m.write(mqttPuback<<4, id)
+}
+
+// keepAliveLoop envoie un PINGREQ à chaque demi-période de keepalive
+func (m *MQTTBridge) keepAliveLoop(ctx context.Context) {
+	if m.keepAlive <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.write(mqttPingreq<<4, nil)
+		}
+	}
+}
+
+// Publish publie un événement de tâche; il est perdu si le broker est injoignable
+func (m *MQTTBridge) Publish(event MQTTTaskEvent) {
+	if !m.notify[event.Event] {
+		m.mu.Lock()
+		m.suppressed++
+		m.mu.Unlock()
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	topic := m.topicFor(event.TaskID, event.DeviceID)
+
+	header := byte(mqttPublish<<4) | m.qos<<1
+	body := appendMQTTString(nil, topic)
+	if m.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, m.nextPacketID())
+	}
+	body = append(body, payload...)
+
+	err = m.write(header, body)
+	m.mu.Lock()
+	if err != nil {
+		m.publishFailed++
+		m.lastError = err.Error()
+	} else {
+		m.published++
+	}
+	m.mu.Unlock()
+	if err != nil {
+		slog.Warn("Publication MQTT impossible", "topic", topic, "task_id", event.TaskID, "error", err)
+	}
+}
+
+// write envoie un paquet sur la connexion courante
+func (m *MQTTBridge) write(header byte, body []byte) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	if m.conn == nil {
+		return errors.New("broker MQTT non connecté")
+	}
+	m.conn.SetWriteDeadline(time.Now().Add(mqttWriteTimeout))
+	_, err := m.conn.Write(encodeMQTTPacket(header, body))
+	return err
+}
+
+// nextPacketID retourne un identifiant de paquet non nul
+func (m *MQTTBridge) nextPacketID() uint16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.packetID++
+	if m.packetID == 0 {
+		m.packetID = 1
+	}
+	return m.packetID
+}
+
+// encodeMQTTPacket préfixe le corps de l'en-tête fixe et de sa longueur restante
+func encodeMQTTPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// readMQTTPacket lit un paquet complet: en-tête fixe et corps
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("longueur de paquet MQTT invalide")
+		}
+		multiplier *= 128
+	}
+	if length > mqttMaxPacket {
+		return 0, nil, fmt.Errorf("paquet MQTT trop volumineux: %d octets", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// appendMQTTString ajoute une chaîne préfixée de sa longueur
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTString lit une chaîne préfixée de sa longueur
+func readMQTTString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("chaîne MQTT tronquée")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("chaîne MQTT tronquée")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// mqttOrigin indique si la tâche a été reçue par MQTT sur ce nœud
+func (fc *FogCompute) mqttOrigin(task *Task) bool {
+	for _, hop := range task.Provenance {
+		if hop.Source == SourceMQTT && hop.NodeID == fc.node.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// ingestMQTT convertit un message du topic de tâches en soumission: même
+// chaîne que POST /tasks, l'acceptation ou le rejet étant publié sur le topic
+// de résultats au lieu d'une réponse HTTP
+func (fc *FogCompute) ingestMQTT(topic string, payload []byte) {
+	span := fc.tracer.Start("mqtt "+topic, SpanKindConsumer, spanContext{})
+	defer span.End()
+
+	var task Task
+	if err := json.Unmarshal(payload, &task); err != nil {
+		span.SetError(err.Error())
+		fc.mqtt.mu.Lock()
+		fc.mqtt.invalid++
+		fc.mqtt.mu.Unlock()
+		slog.Warn("Message MQTT invalide", "topic", topic, "error", err)
+		fc.mqtt.Publish(MQTTTaskEvent{Event: MQTTRejected, NodeID: fc.node.ID, Status: "rejected", Reason: "Message invalide: " + err.Error()})
+		return
+	}
+	task.trace = span.Context()
+	task.TraceID = task.trace.traceIDString()
+	task.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceMQTT, Client: task.DeviceID, Topic: topic})
+
+	task.applyDefaultCosts()
+	task.SmartScore = task.calculateScore()
+
+	if rej := fc.callbacks.Validate(task.CallbackURL); rej != nil {
+		span.SetError(rej.Reason)
+		fc.publishRejection(&task, rej)
+		return
+	}
+	if rej := fc.devices.Resolve(&task); rej != nil {
+		span.SetError(rej.Reason)
+		fc.publishRejection(&task, rej)
+		return
+	}
+	id, rej := fc.idScheme.NewID(fc, &task)
+	if rej != nil {
+		span.SetError(rej.Reason)
+		fc.publishRejection(&task, rej)
+		return
+	}
+	task.ID = id
+	task.SubmittedAt = time.Now()
+
+	ac := fc.admissionContext(&task, nil)
+	if ac.Draining {
+		span.SetError("drainage")
+		fc.publishRejection(&task, &Rejection{Reason: "Nœud en cours de drainage: soumissions suspendues"})
+		return
+	}
+
+	span.SetAttr("task.id", task.ID)
+	span.SetAttr("task.type", task.Type)
+	span.SetAttr("queue_class", task.QueueClass)
+
+	if rej := fc.admission.Admit(fc, ac); rej != nil {
+		span.SetError(rej.Reason)
+		task.Status = "rejected"
+		fc.rejectTask(task, rej.Reason, ac.Load, ac.QueueSize)
+		fc.publishRejection(&task, rej)
+		return
+	}
+
+	task.Status = "queued"
+
+	fc.mu.Lock()
+	fc.enqueueLocked(&task)
+	fc.mu.Unlock()
+	fc.journal.Record(&task)
+
+	slog.Info("Tâche soumise par MQTT", "task_id", task.ID, "topic", topic, "type", task.Type,
+		"priority", task.Priority, "criticality", task.Criticality, "smart_score", task.SmartScore, "trace_id", task.TraceID)
+
+	fc.mqtt.Publish(MQTTTaskEvent{Event: MQTTAccepted, TaskID: task.ID, DeviceID: task.DeviceID, NodeID: fc.node.ID, Status: task.Status})
+}
+
+// publishRejection publie le refus d'une tâche reçue par MQTT
+func (fc *FogCompute) publishRejection(task *Task, rej *Rejection) {
+	queued := fc.shaper.Queued(task.shapingKey)
+	fc.mqtt.Publish(MQTTTaskEvent{
+		Event:           MQTTRejected,
+		TaskID:          task.ID,
+		DeviceID:        task.DeviceID,
+		NodeID:          fc.node.ID,
+		Status:          "rejected",
+		Reason:          rej.Reason,
+		RetryAfterMs:    rej.RetryAfter.Milliseconds(),
+		RateLimitPerSec: fc.shaper.rate,
+		DeviceQueued:    &queued,
+	})
+}
+
+// publishExpiration prévient l'appareil qu'une tâche reçue par MQTT a été
+// abandonnée en queue, son échéance étant dépassée avant son exécution
+func (fc *FogCompute) publishExpiration(task *Task) {
+	if !fc.mqtt.Enabled() || !fc.mqttOrigin(task) {
+		return
+	}
+	queued := fc.shaper.Queued(task.shapingKey)
+	fc.mqtt.Publish(MQTTTaskEvent{
+		Event:           MQTTExpired,
+		TaskID:          task.ID,
+		DeviceID:        task.DeviceID,
+		NodeID:          fc.node.ID,
+		Status:          "deadline_missed",
+		Reason:          "Échéance dépassée avant exécution",
+		RateLimitPerSec: fc.shaper.rate,
+		DeviceQueued:    &queued,
+		Deadline:        task.Deadline,
+	})
+}
+
+// publishResult publie le résultat final d'une tâche reçue par MQTT
+func (fc *FogCompute) publishResult(task *Task) {
+	if !fc.mqtt.Enabled() || !fc.mqttOrigin(task) {
+		return
+	}
+	event := MQTTCompleted
+	if task.Status != "completed" {
+		event = MQTTFailed
+	}
+	fc.mqtt.Publish(MQTTTaskEvent{
+		Event:       event,
+		TaskID:      task.ID,
+		DeviceID:    task.DeviceID,
+		NodeID:      fc.node.ID,
+		Status:      task.Status,
+		Result:      task.Result,
+		CompletedAt: task.CompletedAt,
+	})
+}
@@ -0,0 +1,347 @@
+package fognode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Codec MessagePack restreint au modèle de données de JSON, comme le codec
+// CBOR: les corps sont traduits en JSON à l'entrée et les réponses JSON en
+// MessagePack à la sortie.
+
+// encodeMsgPack encode une valeur JSON décodée avec UseNumber
+func encodeMsgPack(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			msgpackInt(buf, n)
+			return nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			buf.WriteByte(0xcf)
+			binary.Write(buf, binary.BigEndian, u)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		if float64(float32(f)) == f {
+			buf.WriteByte(0xca)
+			binary.Write(buf, binary.BigEndian, math.Float32bits(float32(f)))
+		} else {
+			buf.WriteByte(0xcb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		}
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.WriteByte(0xd9)
+			buf.WriteByte(byte(n))
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdb)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		buf.WriteString(v)
+	case []interface{}:
+		msgpackLength(buf, len(v), 0x90, 0xdc)
+		for _, item := range v {
+			if err := encodeMsgPack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		msgpackLength(buf, len(v), 0x80, 0xde)
+		for _, k := range sortedKeys(v) {
+			encodeMsgPack(buf, k)
+			if err := encodeMsgPack(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("type %T non encodable en MessagePack", v)
+	}
+	return nil
+}
+
+// msgpackInt écrit un entier sur le moins d'octets possible
+func msgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n < 128:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= 0 && n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n >= 0 && n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n >= 0 && n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	case n >= 0:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, uint64(n))
+	case n >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// msgpackLength écrit l'en-tête d'un tableau ou d'une map: forme fixe sous
+// 16 éléments, puis 16 ou 32 bits (code16, code16+1)
+func msgpackLength(buf *bytes.Buffer, n int, fix, code16 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code16 + 1)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// decodeMsgPack décode un document MessagePack en valeur sérialisable en
+// JSON: les binaires deviennent des []byte (base64 en JSON) et les
+// horodatages (extension -1) des chaînes RFC 3339
+func decodeMsgPack(data []byte) (interface{}, error) {
+	d := &msgpackDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("MessagePack invalide à l'octet %d: %w", d.pos, err)
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("MessagePack invalide: %d octets après le document", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCodecTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// uint lit un entier non signé big-endian de size octets
+func (d *msgpackDecoder) uint(size uint64) (uint64, error) {
+	raw, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range raw {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (d *msgpackDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCodecDepth {
+		return nil, errors.New("imbrication trop profonde")
+	}
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.object(uint64(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.array(uint64(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.str(uint64(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6: // bin 8/16/32
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xc7, 0xc8, 0xc9: // ext 8/16/32
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1/2/4/8/16
+		return d.ext(1 << (c - 0xd4))
+	case 0xca:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return finiteFloat(float64(math.Float32frombits(uint32(n))))
+	case 0xcb:
+		n, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return finiteFloat(math.Float64frombits(n))
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8/16/32/64
+		n, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8/16/32/64
+		size := uint64(1) << (c - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb: // str 8/16/32
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd: // array 16/32
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf: // map 16/32
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(n, depth)
+	}
+	return nil, fmt.Errorf("code 0x%02x non supporté", c)
+}
+
+func (d *msgpackDecoder) str(n uint64) (interface{}, error) {
+	raw, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (d *msgpackDecoder) array(n uint64, depth int) (interface{}, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCodecTruncated
+	}
+	list := make([]interface{}, 0, n)
+	for i := uint64(0); i < n; i++ {
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (d *msgpackDecoder) object(n uint64, depth int) (interface{}, error) {
+	if n > uint64(len(d.data)-d.pos)/2 {
+		return nil, errCodecTruncated
+	}
+	obj := make(map[string]interface{}, n)
+	for i := uint64(0); i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("clé de map %T: seules les chaînes sont acceptées", key)
+		}
+		if obj[name], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// ext lit une extension de n octets; seul l'horodatage (-1) est reconnu
+func (d *msgpackDecoder) ext(n uint64) (interface{}, error) {
+	typ, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ[0]) != -1 {
+		return nil, fmt.Errorf("extension %d non supportée", int8(typ[0]))
+	}
+	var t time.Time
+	switch n {
+	case 4:
+		t = time.Unix(int64(binary.BigEndian.Uint32(raw)), 0)
+	case 8:
+		v := binary.BigEndian.Uint64(raw)
+		t = time.Unix(int64(v&0x3ffffffff), int64(v>>34))
+	case 12:
+		t = time.Unix(int64(binary.BigEndian.Uint64(raw[4:])), int64(binary.BigEndian.Uint32(raw[:4])))
+	default:
+		return nil, fmt.Errorf("horodatage de %d octets invalide", n)
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
@@ -0,0 +1,205 @@
+package fognode
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// binaryCodec est un encodage binaire équivalent à JSON, négocié par
+// Content-Type (corps soumis) et Accept (réponses)
+type binaryCodec struct {
+	Name      string
+	MediaType string // Type des réponses encodées
+	encode    func(buf *bytes.Buffer, v interface{}) error
+	decode    func(data []byte) (interface{}, error)
+}
+
+var (
+	cborCodec    = &binaryCodec{Name: "cbor", MediaType: "application/cbor", encode: encodeCBOR, decode: decodeCBOR}
+	msgpackCodec = &binaryCodec{Name: "msgpack", MediaType: "application/msgpack", encode: encodeMsgPack, decode: decodeMsgPack}
+
+	// binaryCodecs associe les types de contenu reconnus à leur codec
+	binaryCodecs = map[string]*binaryCodec{
+		"application/cbor":        cborCodec,
+		"application/msgpack":     msgpackCodec,
+		"application/x-msgpack":   msgpackCodec,
+		"application/vnd.msgpack": msgpackCodec,
+	}
+)
+
+// codecNames retourne les noms des encodages binaires reconnus, triés
+func codecNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, codec := range binaryCodecs {
+		if !seen[codec.Name] {
+			seen[codec.Name] = true
+			names = append(names, codec.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContentNegotiation traduit les corps CBOR et MessagePack en JSON avant les
+// handlers, et leurs réponses JSON dans l'encodage demandé par Accept: les
+// passerelles sur batterie en LTE-M échangent des messages plus courts sans
+// que les handlers changent. Les réponses qui ne sont pas du JSON (erreurs,
+// flux SSE, blobs, métriques Prometheus) sont transmises telles quelles.
+type ContentNegotiation struct {
+	mu    sync.Mutex
+	stats map[string]*CodecStats
+}
+
+// CodecStats décrit l'usage d'un encodage binaire dans /metrics
+type CodecStats struct {
+	Requests     int64 `json:"requests"`      // Corps soumis décodés
+	Responses    int64 `json:"responses"`     // Réponses encodées
+	Invalid      int64 `json:"invalid"`       // Corps soumis indécodables (400)
+	JSONBytes    int64 `json:"json_bytes"`    // Taille JSON des réponses encodées
+	EncodedBytes int64 `json:"encoded_bytes"` // Taille encodée des mêmes réponses
+}
+
+// NewContentNegotiation crée la négociation de contenu
+func NewContentNegotiation() *ContentNegotiation {
+	return &ContentNegotiation{stats: make(map[string]*CodecStats)}
+}
+
+// count met à jour les compteurs d'un codec
+func (cn *ContentNegotiation) count(codec *binaryCodec, update func(*CodecStats)) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	s := cn.stats[codec.Name]
+	if s == nil {
+		s = &CodecStats{}
+		cn.stats[codec.Name] = s
+	}
+	update(s)
+}
+
+// Stats retourne les compteurs par encodage
+func (cn *ContentNegotiation) Stats() map[string]CodecStats {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	stats := make(map[string]CodecStats, len(cn.stats))
+	for name, s := range cn.stats {
+		stats[name] = *s
+	}
+	return stats
+}
+
+// middleware décode les corps binaires et encode les réponses selon Accept
+func (cn *ContentNegotiation) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if codec := binaryCodecs[mediaType]; codec != nil && r.Body != nil {
+			body, err := cn.decodeBody(codec, r.Body)
+			if err != nil {
+				cn.count(codec, func(s *CodecStats) { s.Invalid++ })
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			cn.count(codec, func(s *CodecStats) { s.Requests++ })
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Del("Content-Length")
+		}
+
+		w.Header().Add("Vary", "Accept")
+		codec := acceptedCodec(r.Header.Get("Accept"))
+		if codec == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		jw := &jsonRewriter{ResponseWriter: w}
+		jw.rewrite = func(body []byte) []byte {
+			return cn.encodeBody(codec, jw.Header(), body)
+		}
+		next.ServeHTTP(jw, r)
+		jw.finish()
+	})
+}
+
+// decodeBody traduit un corps binaire en JSON
+func (cn *ContentNegotiation) decodeBody(codec *binaryCodec, body io.ReadCloser) ([]byte, error) {
+	defer body.Close()
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	v, err := codec.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// encodeBody traduit une réponse JSON dans l'encodage demandé; une réponse
+// illisible reste en JSON
+func (cn *ContentNegotiation) encodeBody(codec *binaryCodec, header http.Header, body []byte) []byte {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return body
+	}
+	var out bytes.Buffer
+	if codec.encode(&out, v) != nil {
+		return body
+	}
+	header.Set("Content-Type", codec.MediaType)
+	cn.count(codec, func(s *CodecStats) {
+		s.Responses++
+		s.JSONBytes += int64(len(body))
+		s.EncodedBytes += int64(out.Len())
+	})
+	return out.Bytes()
+}
+
+// acceptedCodec retourne l'encodage binaire préféré par l'en-tête Accept, nil
+// si JSON est préféré ou si Accept est absent; à qualité égale, le premier
+// type de l'en-tête l'emporte
+func acceptedCodec(accept string) *binaryCodec {
+	if accept == "" {
+		return nil
+	}
+	type candidate struct {
+		codec *binaryCodec // nil: JSON
+		q     float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		switch codec := binaryCodecs[mediaType]; {
+		case codec != nil:
+			candidates = append(candidates, candidate{codec, q})
+		case mediaType == "application/json" || mediaType == "application/*" || mediaType == "*/*":
+			candidates = append(candidates, candidate{nil, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].codec
+}
@@ -0,0 +1,425 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// États d'un nœud enregistré
+const (
+	NodeAlive = "alive"
+	NodeStale = "stale" // Heartbeat en retard: plus proposé aux délégations
+)
+
+// NodeCapacity décrit ce qu'un nœud peut accueillir, au dernier heartbeat
+type NodeCapacity struct {
+	Workers      int      `json:"workers"`
+	NumCPU       int      `json:"num_cpu"`
+	CPU          float64  `json:"available_cpu"`
+	RAM          float64  `json:"available_ram"`
+	StorageMB    float64  `json:"available_storage_mb"`
+	Energy       float64  `json:"energy_level"`
+	Accelerators []string `json:"accelerators,omitempty"`
+	TaskTypes    []string `json:"task_types,omitempty"`
+	// Fenêtres de capacité planifiées (maintenance, bridage...)
+	Planned []CapacityWindow `json:"planned_windows,omitempty"`
+}
+
+// NodeRegistration est envoyée par un nœud qui rejoint le cluster
+type NodeRegistration struct {
+	ID       string       `json:"id"`
+	URL      string       `json:"url,omitempty"` // Adresse joignable par les pairs (ADVERTISE_URL)
+	Location string       `json:"location"`
+	Version  string       `json:"software_version,omitempty"`
+	Capacity NodeCapacity `json:"capacity"`
+}
+
+// NodeHeartbeat est envoyé périodiquement par un nœud enregistré
+type NodeHeartbeat struct {
+	Load     float64       `json:"load"`
+	Queued   int           `json:"queued"`
+	Capacity *NodeCapacity `json:"capacity,omitempty"` // Absente: capacité inchangée
+}
+
+// RegisteredNode est un nœud de la vue du cluster
+type RegisteredNode struct {
+	NodeRegistration
+	Status        string    `json:"status"`
+	Load          float64   `json:"load"`
+	Queued        int       `json:"queued"`
+	RegisteredAt  time.Time `json:"registered_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Self          bool      `json:"self,omitempty"`
+}
+
+// ClusterView est la vue du cluster retournée par GET /nodes et aux nœuds enregistrés
+type ClusterView struct {
+	Total int              `json:"total"`
+	Alive int              `json:"alive"`
+	Nodes []RegisteredNode `json:"nodes"`
+}
+
+// NodeRegistry tient le registre des nœuds qui s'annoncent à ce nœud. Un nœud
+// sans heartbeat depuis NODE_TTL devient stale, puis est oublié après NODE_EXPIRY.
+type NodeRegistry struct {
+	ttl    time.Duration
+	expiry time.Duration
+	nodes  map[string]*RegisteredNode // Indexés par ID
+	// onRegister est appelé (hors verrou) à chaque nouvel enregistrement
+	onRegister func(node RegisteredNode)
+	mu         sync.Mutex
+}
+
+// NewNodeRegistry crée le registre configuré depuis l'environnement
+func NewNodeRegistry() *NodeRegistry {
+	return &NodeRegistry{
+		ttl:    getEnvDuration("NODE_TTL", 15*time.Second),
+		expiry: getEnvDuration("NODE_EXPIRY", 5*time.Minute),
+		nodes:  make(map[string]*RegisteredNode),
+	}
+}
+
+// Register enregistre un nœud, ou met à jour son enregistrement; created
+// indique un nœud jusque-là inconnu
+func (nr *NodeRegistry) Register(reg NodeRegistration) (node RegisteredNode, created bool) {
+	nr.mu.Lock()
+	now := time.Now()
+	n, ok := nr.nodes[reg.ID]
+	if !ok {
+		n = &RegisteredNode{RegisteredAt: now}
+		nr.nodes[reg.ID] = n
+	}
+	n.NodeRegistration = reg
+	n.Status = NodeAlive
+	n.LastHeartbeat = now
+	node = *n
+	onRegister := nr.onRegister
+	nr.mu.Unlock()
+
+	if !ok {
+		slog.Info("Nœud enregistré", "peer_id", reg.ID, "peer_url", reg.URL, "location", reg.Location)
+	}
+	if onRegister != nil {
+		onRegister(node)
+	}
+	return node, !ok
+}
+
+// Heartbeat rafraîchit un nœud enregistré; false si le nœud est inconnu (il
+// doit alors se réenregistrer)
+func (nr *NodeRegistry) Heartbeat(id string, hb NodeHeartbeat) (RegisteredNode, bool) {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+
+	n, ok := nr.nodes[id]
+	if !ok {
+		return RegisteredNode{}, false
+	}
+	if n.Status != NodeAlive {
+		slog.Info("Nœud de nouveau joignable", "peer_id", id)
+	}
+	n.Status = NodeAlive
+	n.LastHeartbeat = time.Now()
+	n.Load = hb.Load
+	n.Queued = hb.Queued
+	if hb.Capacity != nil {
+		n.Capacity = *hb.Capacity
+	}
+	return *n, true
+}
+
+// Deregister retire un nœud qui quitte le cluster
+func (nr *NodeRegistry) Deregister(id string) bool {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+
+	if _, ok := nr.nodes[id]; !ok {
+		return false
+	}
+	delete(nr.nodes, id)
+	slog.Info("Nœud désenregistré", "peer_id", id)
+	return true
+}
+
+// Prune marque stale les nœuds silencieux et oublie ceux expirés
+func (nr *NodeRegistry) Prune() {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+
+	now := time.Now()
+	for id, n := range nr.nodes {
+		silent := now.Sub(n.LastHeartbeat)
+		switch {
+		case silent > nr.expiry:
+			delete(nr.nodes, id)
+			slog.Warn("Nœud expiré, retiré du registre", "peer_id", id, "silent", silent.String())
+		case silent > nr.ttl && n.Status == NodeAlive:
+			n.Status = NodeStale
+			slog.Warn("Nœud sans heartbeat", "peer_id", id, "silent", silent.String())
+		}
+	}
+}
+
+// Nodes retourne les nœuds enregistrés, les stale seulement si includeStale
+func (nr *NodeRegistry) Nodes(includeStale bool) []RegisteredNode {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+
+	nodes := make([]RegisteredNode, 0, len(nr.nodes))
+	for _, n := range nr.nodes {
+		if includeStale || n.Status == NodeAlive {
+			nodes = append(nodes, *n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// Summary retourne le nombre de nœuds enregistrés par état
+func (nr *NodeRegistry) Summary() map[string]int {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+
+	summary := map[string]int{NodeAlive: 0, NodeStale: 0}
+	for _, n := range nr.nodes {
+		summary[n.Status]++
+	}
+	return summary
+}
+
+// nodeCapacity décrit la capacité courante de ce nœud
+func (fc *FogCompute) nodeCapacity() NodeCapacity {
+	taskTypes := make([]string, 0)
+	for _, t := range handlerCapabilities() {
+		taskTypes = append(taskTypes, t.Type)
+	}
+	planned := fc.calendar.Windows(time.Now())
+
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return NodeCapacity{
+		Workers:      fc.numWorkers,
+		NumCPU:       runtime.NumCPU(),
+		CPU:          fc.availableCPU,
+		RAM:          fc.availableRAM,
+		StorageMB:    fc.availableStorage,
+		Energy:       fc.energyLevel,
+		Accelerators: fc.accelerators,
+		TaskTypes:    taskTypes,
+		Planned:      planned,
+	}
+}
+
+// selfNode décrit ce nœud dans la vue du cluster
+func (fc *FogCompute) selfNode() RegisteredNode {
+	capacity := fc.nodeCapacity()
+
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return RegisteredNode{
+		NodeRegistration: NodeRegistration{
+			ID:       fc.node.ID,
+			URL:      fc.peers.selfURL,
+			Location: fc.node.Location,
+			Version:  Version,
+			Capacity: capacity,
+		},
+		Status:        NodeAlive,
+		Load:          fc.node.Load,
+		Queued:        fc.scheduler.Len(),
+		RegisteredAt:  fc.startedAt,
+		LastHeartbeat: time.Now(),
+		Self:          true,
+	}
+}
+
+// clusterView retourne ce nœud suivi des nœuds enregistrés
+func (fc *FogCompute) clusterView(includeStale bool) ClusterView {
+	nodes := append([]RegisteredNode{fc.selfNode()}, fc.nodes.Nodes(includeStale)...)
+	view := ClusterView{Total: len(nodes), Nodes: nodes}
+	for _, n := range nodes {
+		if n.Status == NodeAlive {
+			view.Alive++
+		}
+	}
+	return view
+}
+
+// discoverNodes ajoute aux pairs gossip les nœuds d'une vue du cluster
+func (fc *FogCompute) discoverNodes(nodes []RegisteredNode) {
+	for _, n := range nodes {
+		if n.ID != fc.node.ID && n.URL != "" {
+			fc.peers.Discover(n.URL, n.ID)
+		}
+	}
+}
+
+// errNodeUnknown signale un registre qui ne connaît pas (ou plus) ce nœud
+var errNodeUnknown = errors.New("nœud inconnu du registre")
+
+// announce enregistre ce nœud auprès des registres NODE_REGISTRY, puis leur
+// envoie un heartbeat toutes les NODE_HEARTBEAT_INTERVAL. Les nœuds de la vue
+// retournée deviennent des pairs gossip.
+func (fc *FogCompute) announce(ctx context.Context) {
+	registries := getEnvList("NODE_REGISTRY")
+	if len(registries) == 0 {
+		return
+	}
+	interval := getEnvDuration("NODE_HEARTBEAT_INTERVAL", 5*time.Second)
+	client := newNodeClient(5 * time.Second)
+	registered := make(map[string]bool)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, registry := range registries {
+			registry = strings.TrimRight(registry, "/")
+			view, err := fc.announceTo(ctx, client, registry, registered[registry])
+			if errors.Is(err, errNodeUnknown) {
+				// Registre redémarré ou nœud expiré: réenregistrement immédiat
+				view, err = fc.announceTo(ctx, client, registry, false)
+			}
+			if err != nil {
+				if registered[registry] {
+					slog.Warn("Registre de nœuds injoignable", "registry", registry, "error", err)
+				}
+				registered[registry] = false
+				continue
+			}
+			if !registered[registry] {
+				slog.Info("Nœud annoncé au registre", "registry", registry, "cluster_nodes", view.Total)
+			}
+			registered[registry] = true
+			fc.discoverNodes(view.Nodes)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// announceTo envoie un enregistrement, ou un heartbeat si le nœud est déjà
+// enregistré, et retourne la vue du cluster du registre
+func (fc *FogCompute) announceTo(ctx context.Context, client *http.Client, registry string, heartbeat bool) (ClusterView, error) {
+	self := fc.selfNode()
+	target := registry + "/nodes"
+	var body interface{} = self.NodeRegistration
+	if heartbeat {
+		target = registry + "/nodes/" + url.PathEscape(self.ID) + "/heartbeat"
+		body = NodeHeartbeat{Load: self.Load, Queued: self.Queued, Capacity: &self.Capacity}
+	}
+
+	raw, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(raw))
+	if err != nil {
+		return ClusterView{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setNodeAPIKey(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return ClusterView{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && heartbeat:
+		return ClusterView{}, errNodeUnknown
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated:
+		return ClusterView{}, fmt.Errorf("statut %d", resp.StatusCode)
+	}
+	var view ClusterView
+	err = json.NewDecoder(resp.Body).Decode(&view)
+	return view, err
+}
+
+// leaveCluster retire ce nœud des registres NODE_REGISTRY lors d'un arrêt gracieux
+func (fc *FogCompute) leaveCluster(ctx context.Context) {
+	for _, registry := range getEnvList("NODE_REGISTRY") {
+		target := strings.TrimRight(registry, "/") + "/nodes/" + url.PathEscape(fc.node.ID)
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, target, nil)
+		if err != nil {
+			continue
+		}
+		setNodeAPIKey(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			slog.Warn("Désenregistrement impossible", "registry", registry, "error", err)
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+// handleRegisterNode enregistre un nœud et lui retourne la vue du cluster
+func (fc *FogCompute) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
+	var reg NodeRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reg.URL = strings.TrimRight(reg.URL, "/")
+	if reg.ID == "" {
+		http.Error(w, "id requis", http.StatusBadRequest)
+		return
+	}
+	if reg.ID == fc.node.ID {
+		http.Error(w, "id déjà utilisé par ce nœud", http.StatusConflict)
+		return
+	}
+
+	_, created := fc.nodes.Register(reg)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(fc.clusterView(false))
+}
+
+// handleNodeHeartbeat rafraîchit un nœud enregistré; 404 lui demande de se réenregistrer
+func (fc *FogCompute) handleNodeHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var hb NodeHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := fc.nodes.Heartbeat(mux.Vars(r)["id"], hb); !ok {
+		http.Error(w, "Nœud non enregistré", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.clusterView(false))
+}
+
+// handleDeregisterNode retire un nœud qui quitte le cluster
+func (fc *FogCompute) handleDeregisterNode(w http.ResponseWriter, r *http.Request) {
+	if !fc.nodes.Deregister(mux.Vars(r)["id"]) {
+		http.Error(w, "Nœud non enregistré", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetNodes retourne la vue du cluster: ce nœud et les nœuds enregistrés
+// vivants (?all=true inclut les nœuds sans heartbeat récent)
+func (fc *FogCompute) handleGetNodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.clusterView(r.URL.Query().Get("all") == "true"))
+}
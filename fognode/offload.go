@@ -0,0 +1,298 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultOffloadPolicies sont les refus de surcharge qui déclenchent une délégation
+const defaultOffloadPolicies = "load,queue,resources,calendar"
+
+// Modes de délégation (OFFLOAD_MODE)
+const (
+	OffloadForward  = "forward"  // Le nœud relaie lui-même la tâche au pair
+	OffloadRedirect = "redirect" // Le client est redirigé (307) vers le pair
+)
+
+// RedirectedByParam marque une soumission redirigée par un nœud saturé: elle
+// n'est plus redirigée, pour éviter les boucles
+const RedirectedByParam = "redirected_by"
+
+// OffloadPolicy décide quand et vers qui déléguer une tâche que ce nœud refuserait
+type OffloadPolicy struct {
+	Enabled   bool
+	Mode      string
+	Policies  map[string]bool // Politiques d'admission dont le refus déclenche la délégation
+	RTTWeight float64         // Charge équivalente à 100ms d'aller-retour
+	Timeout   time.Duration
+	client    *http.Client
+}
+
+// loadOffloadPolicy lit OFFLOAD_ENABLED, OFFLOAD_MODE, OFFLOAD_POLICIES,
+// OFFLOAD_RTT_WEIGHT et OFFLOAD_TIMEOUT
+func loadOffloadPolicy() OffloadPolicy {
+	mode := getEnv("OFFLOAD_MODE", OffloadForward)
+	if mode != OffloadForward && mode != OffloadRedirect {
+		slog.Warn("Mode de délégation inconnu", "mode", mode, "default", OffloadForward)
+		mode = OffloadForward
+	}
+	policies := make(map[string]bool)
+	names := getEnvList("OFFLOAD_POLICIES")
+	if len(names) == 0 {
+		names = strings.Split(defaultOffloadPolicies, ",")
+	}
+	for _, name := range names {
+		policies[name] = true
+	}
+	timeout := getEnvDuration("OFFLOAD_TIMEOUT", 3*time.Second)
+	return OffloadPolicy{
+		Enabled:   getEnvBool("OFFLOAD_ENABLED", true),
+		Mode:      mode,
+		Policies:  policies,
+		RTTWeight: getEnvFloat("OFFLOAD_RTT_WEIGHT", 0.5),
+		Timeout:   timeout,
+		client:    newNodeClient(timeout),
+	}
+}
+
+// offloadCandidate est un pair susceptible d'accueillir une tâche déléguée
+type offloadCandidate struct {
+	ID      string
+	URL     string
+	Load    float64
+	RTTMs   float64
+	Planned []CapacityWindow // Fenêtres de capacité annoncées par le pair
+	cost    float64
+}
+
+// OffloadedTask est la réponse au client dont la tâche a été déléguée: la
+// tâche telle qu'acceptée par le pair, et où la suivre
+type OffloadedTask struct {
+	Task
+	OffloadedTo string `json:"offloaded_to"`
+	TaskURL     string `json:"task_url"`
+}
+
+// offloadCandidates retourne les pairs vivants sous le seuil de charge, par coût
+// croissant (charge, pénalisée par l'aller-retour). Les pairs de la vue gossip et
+// ceux du registre de nœuds sont fusionnés; les nœuds déjà traversés par la tâche
+// sont exclus pour éviter les boucles.
+func (fc *FogCompute) offloadCandidates(task *Task) []offloadCandidate {
+	byURL := make(map[string]*offloadCandidate)
+	for _, p := range fc.peers.Live() {
+		byURL[p.URL] = &offloadCandidate{ID: p.ID, URL: p.URL, Load: p.Load, RTTMs: p.RTTMs, Planned: p.Planned}
+	}
+	for _, n := range fc.nodes.Nodes(false) {
+		if n.URL == "" {
+			continue
+		}
+		if c, ok := byURL[n.URL]; ok {
+			// Deux mesures de charge: la plus pessimiste l'emporte
+			if n.Load > c.Load {
+				c.Load = n.Load
+			}
+			if len(c.Planned) == 0 {
+				c.Planned = n.Capacity.Planned
+			}
+			continue
+		}
+		byURL[n.URL] = &offloadCandidate{ID: n.ID, URL: n.URL, Load: n.Load, Planned: n.Capacity.Planned}
+	}
+
+	now := time.Now()
+	candidates := make([]offloadCandidate, 0, len(byURL))
+	for _, c := range byURL {
+		if c.ID == fc.node.ID || c.Load > fc.load.threshold || (c.ID != "" && task.visited(c.ID)) {
+			continue
+		}
+		// Un pair qui a annoncé une capacité réduite pendant l'exécution prévue est évité
+		if _, restricted := restrictingWindow(c.Planned, task, now, now.Add(fc.expectedRuntime(task))); restricted {
+			continue
+		}
+		c.cost = c.Load + fc.offload.RTTWeight*c.RTTMs/100
+		candidates = append(candidates, *c)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].cost < candidates[j].cost })
+	return candidates
+}
+
+// tryOffload délègue à un pair une tâche refusée pour surcharge. Les pairs sont
+// essayés du moins chargé au plus chargé; en cas de succès le client reçoit la
+// tâche du pair (202) et la tâche est suivie pour être redistribuée si ce pair
+// tombe. En mode redirect, le client est renvoyé vers le meilleur pair.
+// Retourne false si la tâche doit être rejetée localement.
+func (fc *FogCompute) tryOffload(w http.ResponseWriter, r *http.Request, span *Span, task *Task, rej *Rejection) bool {
+	if !fc.offload.Enabled || !fc.offload.Policies[rej.Policy] {
+		return false
+	}
+	// Une tâche déjà déléguée n'est pas relayée plus loin: son nœud d'origine,
+	// qui la suit, essaiera le pair suivant
+	if n := len(task.Provenance); n > 0 && task.Provenance[n-1].Source == SourcePeer {
+		return false
+	}
+	if fc.offload.Mode == OffloadRedirect {
+		return fc.redirectToPeer(w, r, span, task)
+	}
+
+	for _, c := range fc.offloadCandidates(task) {
+		// Le client n'attend plus: inutile d'essayer les pairs suivants
+		if r.Context().Err() != nil {
+			return false
+		}
+		remote, err := fc.forwardTask(r.Context(), span, task, c.URL)
+		if err != nil {
+			slog.Warn("Délégation refusée par le pair", "task_id", task.ID, "peer_id", c.ID, "peer_url", c.URL, "error", err)
+			continue
+		}
+
+		fc.peers.TrackOffload(*remote, c.ID, c.URL)
+		fc.metrics.mu.Lock()
+		fc.metrics.TasksOffloaded++
+		fc.metrics.mu.Unlock()
+
+		span.SetAttr("offload.peer_id", c.ID)
+		span.SetAttr("offload.task_id", remote.ID)
+		slog.Info("Tâche déléguée à un pair", "task_id", task.ID, "remote_task_id", remote.ID,
+			"peer_id", c.ID, "peer_load", c.Load, "peer_rtt_ms", c.RTTMs, "reason", rej.Reason)
+
+		taskURL := c.URL + "/tasks/" + url.PathEscape(remote.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", taskURL)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(OffloadedTask{Task: *remote, OffloadedTo: c.ID, TaskURL: taskURL})
+		return true
+	}
+	return false
+}
+
+// forwardTask soumet la tâche à un pair et retourne la tâche telle qu'il l'a acceptée
+func (fc *FogCompute) forwardTask(ctx context.Context, span *Span, task *Task, peerURL string) (*Task, error) {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, fc.offload.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peerURL+"/tasks", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ForwardedByHeader, fc.node.ID)
+	req.Header.Set(TraceParentHeader, span.Context().traceParent())
+	setNodeAPIKey(req)
+
+	resp, err := fc.offload.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("statut %d", resp.StatusCode)
+	}
+	var remote Task
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		return nil, err
+	}
+	return &remote, nil
+}
+
+// reconcileOffloads interroge périodiquement les pairs sur les tâches déléguées:
+// une tâche terminée quitte le registre des délégations, une tâche que le pair
+// ne connaît plus (redémarrage sans persistance) est réexécutée localement
+func (fc *FogCompute) reconcileOffloads(ctx context.Context) {
+	ticker := time.NewTicker(getEnvDuration("OFFLOAD_POLL_INTERVAL", 10*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, rec := range fc.peers.Offloads() {
+				fc.reconcileOffload(ctx, rec)
+			}
+		}
+	}
+}
+
+// reconcileOffload vérifie l'état d'une tâche déléguée auprès de son pair
+func (fc *FogCompute) reconcileOffload(ctx context.Context, rec offloadRecord) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rec.PeerURL+"/tasks/"+url.PathEscape(rec.Task.ID), nil)
+	if err != nil {
+		return
+	}
+	setNodeAPIKey(req)
+	req.Header.Set(TenantHeader, tenantOf(&rec.Task))
+	resp, err := fc.offload.client.Do(req)
+	if err != nil {
+		return // Pair injoignable: le détecteur de pannes tranchera
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var remote Task
+		if json.NewDecoder(resp.Body).Decode(&remote) == nil && remote.CompletedAt != nil {
+			fc.peers.CompleteOffload(rec.Task.ID)
+		}
+	case http.StatusNotFound:
+		fc.peers.CompleteOffload(rec.Task.ID)
+		slog.Warn("Tâche déléguée perdue par le pair", "task_id", rec.Task.ID, "peer_id", rec.PeerID)
+		fc.redispatchOrphans(rec.PeerID, []Task{rec.Task})
+	}
+}
+
+// redirectOverloaded renvoie vers un pair, avant même la lecture du corps, les
+// soumissions reçues alors que le nœud est surchargé: le payload ne transite
+// pas par le nœud saturé. Seul le mode redirect est concerné.
+func (fc *FogCompute) redirectOverloaded(w http.ResponseWriter, r *http.Request, span *Span) bool {
+	if !fc.offload.Enabled || fc.offload.Mode != OffloadRedirect {
+		return false
+	}
+	fc.mu.RLock()
+	overloaded := fc.node.Load > fc.load.threshold
+	fc.mu.RUnlock()
+	if !overloaded {
+		return false
+	}
+	return fc.redirectToPeer(w, r, span, &Task{})
+}
+
+// redirectToPeer répond 307 vers le meilleur pair; la méthode et le corps sont
+// conservés par le client. Retourne false sans pair disponible, ou si la
+// soumission a déjà été redirigée (ou vient d'un pair).
+func (fc *FogCompute) redirectToPeer(w http.ResponseWriter, r *http.Request, span *Span, task *Task) bool {
+	// Un jeton d'admission n'est valable que sur ce nœud
+	if r.URL.Query().Get(RedirectedByParam) != "" || r.Header.Get(ForwardedByHeader) != "" || r.Header.Get(AdmissionTokenHeader) != "" {
+		return false
+	}
+	candidates := fc.offloadCandidates(task)
+	if len(candidates) == 0 {
+		return false
+	}
+	c := candidates[0]
+
+	query := r.URL.Query()
+	query.Set(RedirectedByParam, fc.node.ID)
+	location := c.URL + r.URL.Path + "?" + query.Encode()
+
+	fc.metrics.mu.Lock()
+	fc.metrics.TasksRedirected++
+	fc.metrics.mu.Unlock()
+
+	span.SetAttr("offload.peer_id", c.ID)
+	span.SetAttr("offload.mode", OffloadRedirect)
+	slog.Info("Soumission redirigée vers un pair", "peer_id", c.ID, "peer_load", c.Load, "peer_rtt_ms", c.RTTMs)
+
+	http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+	return true
+}
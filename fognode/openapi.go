@@ -0,0 +1,483 @@
+package fognode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// apiOperation documente une route dans le document OpenAPI. Les routes sans
+// entrée dans apiOperations y figurent quand même, avec une réponse JSON libre.
+type apiOperation struct {
+	Summary     string
+	Tag         string
+	Request     interface{} // Valeur du type du corps attendu (nil: sans corps)
+	RequestOpt  bool        // Corps facultatif
+	Response    interface{} // Valeur du type de la réponse (nil: objet JSON libre)
+	ContentType string      // Type de la réponse quand elle n'est pas du JSON
+	Status      int         // Code de succès (défaut: 200)
+	Query       []apiParam
+	Codes       []int // Autres codes documentés, en plus de 401/403
+}
+
+// apiParam est un paramètre de requête (?name=)
+type apiParam struct {
+	Name        string
+	Type        string // string, integer ou boolean
+	Description string
+}
+
+// Corps des listes et des réponses ad hoc, décrits pour le document
+type (
+	taskList struct {
+		Total    int    `json:"total"`
+		Archived bool   `json:"archived"`
+		Tasks    []Task `json:"tasks"`
+	}
+	rejectedTaskList struct {
+		Total int            `json:"total"`
+		Tasks []RejectedTask `json:"tasks"`
+	}
+	failedTaskList struct {
+		Total int          `json:"total"`
+		Tasks []FailedTask `json:"tasks"`
+	}
+	retriedTask struct {
+		Message string `json:"message"`
+		Task    Task   `json:"task"`
+	}
+	purgeResult struct {
+		Message string `json:"message"`
+		Count   int    `json:"count"`
+	}
+	annotationRequest struct {
+		Author string `json:"author,omitempty"` // Défaut: en-tête X-Fog-Operator, puis identité du client
+		Note   string `json:"note"`
+	}
+	progressRequest struct {
+		Progress float64 `json:"progress"` // 0.0-1.0
+		Message  string  `json:"message,omitempty"`
+		Attempt  int     `json:"attempt,omitempty"`
+	}
+	pauseRequest struct {
+		AcceptSubmissions *bool `json:"accept_submissions,omitempty"` // false: refuser aussi les soumissions
+	}
+	workersRequest struct {
+		Workers int `json:"workers"`
+	}
+	schedulerTraceRequest struct {
+		Enabled bool `json:"enabled"`
+	}
+	updateResult struct {
+		Message string `json:"message"`
+		Version string `json:"version,omitempty"`
+		From    string `json:"from,omitempty"`
+		To      string `json:"to,omitempty"`
+	}
+)
+
+// apiOperations décrit les routes de tâches, de tâches rejetées ou en échec,
+// de métriques et d'administration, par "MÉTHODE gabarit"
+var apiOperations = map[string]apiOperation{
+	"POST /tasks": {Summary: "Soumettre une tâche", Tag: "tasks", Request: Task{}, Response: Task{},
+		Query: []apiParam{{"wait", "boolean", "true: attendre la fin de la tâche, comme POST /tasks/sync"}, {"timeout", "string", "Attente maximale de la fin (durée Go, plafonnée par SYNC_WAIT_TIMEOUT)"}},
+		Codes: []int{http.StatusAccepted, http.StatusBadRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	"POST /tasks/sync": {Summary: "Soumettre une tâche et attendre sa fin", Tag: "tasks", Request: Task{}, Response: Task{},
+		Query: []apiParam{{"timeout", "string", "Attente maximale de la fin (durée Go, plafonnée par SYNC_WAIT_TIMEOUT)"}},
+		Codes: []int{http.StatusAccepted, http.StatusBadRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	"POST /tasks/admission": {Summary: "Réserver l'admission d'une tâche avant l'envoi de son payload", Tag: "tasks", Request: Task{}, Response: AdmissionGrant{},
+		Codes: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	"POST /tasks/batch": {Summary: "Soumettre un tableau de tâches", Tag: "tasks", Request: []Task{}, Response: BatchResult{},
+		Codes: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge}},
+	"GET /tasks": {Summary: "Lister les tâches, des plus récentes aux plus anciennes", Tag: "tasks", Response: taskList{},
+		Query: []apiParam{{"status", "string", "Statut des tâches"}, {"type", "string", "Type des tâches"}, {"limit", "integer", "Nombre maximal de tâches (défaut: 100)"}, {"archived", "boolean", "Lister les tâches archivées par la rétention"}}},
+	"GET /tasks/{id}": {Summary: "État d'une tâche (X-Fog-Archived: true si elle est lue dans l'archive)", Tag: "tasks", Response: Task{},
+		Codes: []int{http.StatusNotFound}},
+	"GET /tasks/{id}/result": {Summary: "Résultat d'une tâche, ou blob de son résultat", Tag: "tasks", ContentType: "application/octet-stream",
+		Query: []apiParam{{"name", "string", "Blob à diffuser quand le résultat en contient plusieurs"}},
+		Codes: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"POST /tasks/{id}/annotations": {Summary: "Annoter une tâche", Tag: "tasks", Request: annotationRequest{}, Response: AnnotationResponse{}, Status: http.StatusCreated,
+		Codes: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"POST /tasks/{id}/progress": {Summary: "Déclarer l'avancement d'une tâche en cours", Tag: "tasks", Request: progressRequest{}, Response: TaskProgress{},
+		Codes: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	"GET /events": {Summary: "Flux Server-Sent Events du cycle de vie des tâches", Tag: "tasks", Response: TaskEvent{}, ContentType: "text/event-stream",
+		Query: []apiParam{{"types", "string", "Types d'événements, séparés par des virgules"}, {"task_id", "string", "Événements d'une seule tâche"}, {"last_event_id", "string", "Reprendre après cet événement (ou en-tête Last-Event-ID)"}}},
+
+	"GET /rejected-tasks": {Summary: "Lister les tâches rejetées", Tag: "rejected-tasks", Response: rejectedTaskList{}},
+	"POST /rejected-tasks/{id}/retry": {Summary: "Resoumettre une tâche rejetée", Tag: "rejected-tasks", Response: retriedTask{},
+		Codes: []int{http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	"POST /rejected-tasks/{id}/annotations": {Summary: "Annoter une tâche rejetée", Tag: "rejected-tasks", Request: annotationRequest{}, Response: AnnotationResponse{}, Status: http.StatusCreated,
+		Codes: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"DELETE /rejected-tasks": {Summary: "Effacer les tâches rejetées", Tag: "rejected-tasks", Response: purgeResult{}},
+
+	"GET /failed-tasks": {Summary: "Lister les tâches en échec définitif (dead-letter queue)", Tag: "failed-tasks", Response: failedTaskList{}},
+	"GET /failed-tasks/{id}": {Summary: "Détail de l'échec d'une tâche", Tag: "failed-tasks", Response: FailedTask{},
+		Codes: []int{http.StatusNotFound}},
+	"POST /failed-tasks/{id}/retry": {Summary: "Remettre en queue une tâche en échec", Tag: "failed-tasks", Response: retriedTask{},
+		Codes: []int{http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	"DELETE /failed-tasks": {Summary: "Oublier les tâches en échec", Tag: "failed-tasks", Response: purgeResult{},
+		Query: []apiParam{{"id", "string", "Oublier seulement cette tâche"}},
+		Codes: []int{http.StatusNotFound}},
+
+	"GET /metrics":            {Summary: "Métriques du nœud (JSON)", Tag: "metrics"},
+	"GET /metrics/prometheus": {Summary: "Métriques au format d'exposition Prometheus", Tag: "metrics", ContentType: "text/plain; version=0.0.4"},
+	"GET /metrics/delta": {Summary: "Compteurs cumulés et leur accroissement depuis le précédent relevé", Tag: "metrics", Response: MetricsDelta{},
+		Query: []apiParam{{"consumer", "string", "Consommateur des relevés (défaut: adresse du client)"}}},
+
+	"GET /admin/maintenance": {Summary: "État de pause et de drainage", Tag: "admin", Response: MaintenanceStatus{},
+		Query: []apiParam{{"wait", "string", "Attendre la fin du drainage dans cette limite (durée Go)"}}},
+	"POST /admin/pause": {Summary: "Suspendre la distribution des tâches", Tag: "admin", Request: pauseRequest{}, RequestOpt: true, Response: MaintenanceStatus{},
+		Codes: []int{http.StatusBadRequest}},
+	"POST /admin/resume": {Summary: "Lever la pause et le drainage", Tag: "admin", Response: MaintenanceStatus{}},
+	"POST /admin/drain": {Summary: "Fermer les admissions avant maintenance (202 tant que le nœud n'est pas vide)", Tag: "admin", Response: MaintenanceStatus{},
+		Codes: []int{http.StatusAccepted}},
+	"GET /admin/workers": {Summary: "État du pool de workers", Tag: "admin", Response: WorkerPoolStatus{}},
+	"PUT /admin/workers": {Summary: "Fixer le nombre de workers", Tag: "admin", Request: workersRequest{}, Response: WorkerPoolStatus{},
+		Codes: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+	"GET /admin/scheduler-trace": {Summary: "Dernières décisions du scheduler", Tag: "admin", Response: SchedulerTraceResponse{},
+		Query: []apiParam{{"task_id", "string", "Décisions concernant cette tâche"}, {"limit", "integer", "Nombre maximal de décisions"}}},
+	"POST /admin/scheduler-trace": {Summary: "Activer ou désactiver la trace du scheduler", Tag: "admin", Request: schedulerTraceRequest{}, Response: SchedulerTraceResponse{},
+		Codes: []int{http.StatusBadRequest}},
+	"POST /admin/calendar": {Summary: "Planifier une fenêtre de capacité", Tag: "admin", Request: CapacityWindow{}, Response: CapacityWindow{}, Status: http.StatusCreated,
+		Codes: []int{http.StatusBadRequest}},
+	"DELETE /admin/calendar/{id}": {Summary: "Annuler une fenêtre de capacité", Tag: "admin", Status: http.StatusNoContent,
+		Codes: []int{http.StatusNotFound}},
+	"GET /admin/update": {Summary: "État de la mise à jour automatique", Tag: "admin", Response: UpdateStatus{}},
+	"POST /admin/update": {Summary: "Vérifier et installer une mise à jour", Tag: "admin", Response: updateResult{},
+		Codes: []int{http.StatusAccepted, http.StatusBadGateway}},
+	"POST /admin/reload": {Summary: "Relire le fichier de configuration", Tag: "admin", Response: ReloadResult{},
+		Codes: []int{http.StatusConflict, http.StatusUnprocessableEntity}},
+
+	"GET /openapi.json": {Summary: "Ce document OpenAPI", Tag: "meta"},
+}
+
+// handleOpenAPI sert le document OpenAPI 3 de l'API du nœud, construit au
+// premier appel à partir des routes enregistrées
+func (fc *FogCompute) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	fc.openAPIOnce.Do(func() {
+		doc, err := json.Marshal(fc.openAPIDocument())
+		if err != nil {
+			slog.Error("Document OpenAPI impossible à construire", "error", err)
+			return
+		}
+		fc.openAPIDoc = doc
+	})
+	if fc.openAPIDoc == nil {
+		http.Error(w, "Document OpenAPI indisponible", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(fc.openAPIDoc)
+}
+
+// openAPIDocument décrit les routes publiques et d'administration telles que
+// registerPublicRoutes et registerAdminRoutes les enregistrent: le document
+// ne peut pas oublier une route
+func (fc *FogCompute) openAPIDocument() map[string]interface{} {
+	sg := &schemaGenerator{components: make(map[string]interface{})}
+	paths := make(map[string]map[string]interface{})
+
+	add := func(register func(*mux.Router), prefix string, admin bool) {
+		r := mux.NewRouter()
+		register(r.PathPrefix(prefix).Subrouter())
+		r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				return nil
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return nil
+			}
+			for _, method := range methods {
+				if paths[template] == nil {
+					paths[template] = make(map[string]interface{})
+				}
+				paths[template][strings.ToLower(method)] = fc.openAPIOperation(sg, method, template, admin)
+			}
+			return nil
+		})
+	}
+	add(fc.registerProbeRoutes, "", false)
+	add(fc.registerPublicRoutes, APIPrefix, false)
+	add(fc.registerAdminRoutes, APIPrefix, true)
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Fog Computing Node API",
+			"version": APIVersion,
+			"description": "API HTTP d'un nœud fog computing (binaire " + Version + "). Les routes de " + APIPrefix +
+				" restent servies sans préfixe pendant leur fenêtre de dépréciation, dans la représentation historique des tâches" +
+				" (durées en nanosecondes: estimated_latency, network_latency).",
+		},
+		"servers": []map[string]string{{"url": "/"}},
+		"tags": []map[string]string{
+			{"name": "tasks", "description": "Soumission et suivi des tâches"},
+			{"name": "rejected-tasks", "description": "Tâches refusées à l'admission"},
+			{"name": "failed-tasks", "description": "Tâches en échec définitif (dead-letter queue)"},
+			{"name": "metrics", "description": "Métriques du nœud"},
+			{"name": "admin", "description": "Maintenance, sur le listener ADMIN_ADDR s'il est défini"},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": sg.components,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]string{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+				"bearer": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// openAPIOperation décrit une route; la portée exigée (x-fog-scope) est
+// celle que le middleware d'authentification applique
+func (fc *FogCompute) openAPIOperation(sg *schemaGenerator, method, path string, admin bool) map[string]interface{} {
+	template := strings.TrimPrefix(path, APIPrefix)
+	doc := apiOperations[method+" "+template]
+	if doc.Tag == "" {
+		doc.Tag = strings.SplitN(strings.TrimPrefix(template, "/"), "/", 2)[0]
+		if admin {
+			doc.Tag = "admin"
+		}
+	}
+	if doc.Summary == "" {
+		doc.Summary = method + " " + template
+	}
+	op := map[string]interface{}{
+		"operationId": operationID(method, template),
+		"summary":     doc.Summary,
+		"tags":        []string{doc.Tag},
+	}
+	if admin {
+		op["x-fog-admin"] = true
+	}
+	if fc.auth.exempt[template] {
+		op["security"] = []interface{}{}
+	} else {
+		op["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+		op["x-fog-scope"] = requiredScope(method, template)
+	}
+
+	var params []map[string]interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, map[string]interface{}{
+				"name": strings.Trim(segment, "{}"), "in": "path", "required": true,
+				"schema": map[string]string{"type": "string"},
+			})
+		}
+	}
+	for _, q := range doc.Query {
+		params = append(params, map[string]interface{}{
+			"name": q.Name, "in": "query", "description": q.Description,
+			"schema": map[string]string{"type": q.Type},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": !doc.RequestOpt,
+			"content":  jsonContent("application/json", sg.schema(reflect.TypeOf(doc.Request))),
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if status != http.StatusNoContent {
+		contentType := doc.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		var schema map[string]interface{}
+		switch {
+		case doc.Response != nil:
+			schema = sg.schema(reflect.TypeOf(doc.Response))
+		case contentType == "application/json":
+			schema = map[string]interface{}{"type": "object", "additionalProperties": true}
+		case contentType == "application/octet-stream":
+			schema = map[string]interface{}{"type": "string", "format": "binary"}
+		default:
+			schema = map[string]interface{}{"type": "string"}
+		}
+		success["content"] = jsonContent(contentType, schema)
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	codes := doc.Codes
+	if !fc.auth.exempt[template] {
+		codes = append(codes, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	for _, code := range codes {
+		if _, ok := responses[strconv.Itoa(code)]; !ok {
+			responses[strconv.Itoa(code)] = map[string]interface{}{"description": http.StatusText(code)}
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// jsonContent décrit un contenu; le JSON est aussi négociable en CBOR et en
+// MessagePack (voir negotiation.go)
+func jsonContent(contentType string, schema map[string]interface{}) map[string]interface{} {
+	content := map[string]interface{}{contentType: map[string]interface{}{"schema": schema}}
+	if contentType == "application/json" {
+		for _, codec := range []*binaryCodec{cborCodec, msgpackCodec} {
+			content[codec.MediaType] = map[string]interface{}{"schema": schema}
+		}
+	}
+	return content
+}
+
+// operationID dérive un identifiant d'opération de la route:
+// "GET /tasks/{id}/result" donne "getTasksIdResult"
+func operationID(method, template string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(template, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '.'
+	}) {
+		b.WriteString(exportedName(part))
+	}
+	return b.String()
+}
+
+// exportedName met en majuscule la première lettre d'un nom
+func exportedName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// schemaGenerator dérive les schémas JSON des types Go par réflexion, selon
+// les règles d'encoding/json; chaque struct nommée devient un composant
+// référencé
+type schemaGenerator struct {
+	components map[string]interface{}
+}
+
+// requiredFields remplace les champs requis déduits des tags pour les types
+// soumis par les clients, dont le nœud complète les autres champs
+var requiredFields = map[reflect.Type][]string{
+	reflect.TypeOf(Task{}):           {"type"},
+	reflect.TypeOf(CapacityWindow{}): {"start", "end"},
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	rawJSONType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schema retourne le schéma de t, ou une référence à son composant
+func (sg *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Durée en nanosecondes"}
+	case rawJSONType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return sg.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": sg.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": sg.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sg.object(t)
+		}
+		name := exportedName(t.Name())
+		if _, ok := sg.components[name]; !ok {
+			sg.components[name] = nil // Réservé: les types récursifs se référencent eux-mêmes
+			sg.components[name] = sg.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	// interface{}: toute valeur JSON
+	return map[string]interface{}{}
+}
+
+// object décrit les champs d'une struct; les champs sans omitempty sont requis
+func (sg *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	sg.fields(t, properties, &required)
+	if fields, ok := requiredFields[t]; ok {
+		required = fields
+	}
+	sort.Strings(required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields ajoute les champs exportés de t, ceux des structs embarquées à plat
+func (sg *schemaGenerator) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				sg.fields(embedded, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if f.Type == durationType {
+			// Schéma des tâches de l'API versionnée: durées en millisecondes
+			name += "_ms"
+			properties[name] = map[string]interface{}{"type": "integer", "format": "int64", "description": "Millisecondes"}
+		} else {
+			properties[name] = sg.schema(f.Type)
+		}
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
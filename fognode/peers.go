@@ -0,0 +1,507 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// États d'un pair vu par le détecteur de pannes
+const (
+	PeerAlive   = "alive"
+	PeerSuspect = "suspect"
+	PeerDead    = "dead"
+)
+
+const (
+	phiWindowSize = 100                    // Nombre d'intervalles de heartbeat conservés
+	phiMinStdDev  = 100 * time.Millisecond // Écart-type minimal pour éviter un phi infini
+)
+
+// phiDetector implémente un détecteur de pannes phi-accrual: phi mesure la
+// confiance (en échelle log10) que le pair est tombé, au vu des intervalles
+// de heartbeat observés
+type phiDetector struct {
+	intervals []float64 // Intervalles observés, en secondes
+	last      time.Time
+}
+
+// heartbeat enregistre l'arrivée d'un heartbeat
+func (d *phiDetector) heartbeat(now time.Time) {
+	if !d.last.IsZero() {
+		d.intervals = append(d.intervals, now.Sub(d.last).Seconds())
+		if len(d.intervals) > phiWindowSize {
+			d.intervals = d.intervals[1:]
+		}
+	}
+	d.last = now
+}
+
+// phi retourne le niveau de suspicion courant
+func (d *phiDetector) phi(now time.Time, expected time.Duration) float64 {
+	if d.last.IsZero() {
+		return math.Inf(1)
+	}
+
+	mean, std := expected.Seconds(), expected.Seconds()/4
+	if n := len(d.intervals); n > 0 {
+		sum := 0.0
+		for _, v := range d.intervals {
+			sum += v
+		}
+		mean = sum / float64(n)
+		variance := 0.0
+		for _, v := range d.intervals {
+			variance += (v - mean) * (v - mean)
+		}
+		std = math.Sqrt(variance / float64(n))
+	}
+	std = math.Max(std, phiMinStdDev.Seconds())
+
+	elapsed := now.Sub(d.last).Seconds()
+	pLater := 0.5 * math.Erfc((elapsed-mean)/(std*math.Sqrt2))
+	if pLater <= 0 {
+		return math.Inf(1)
+	}
+	return -math.Log10(pLater)
+}
+
+// PeerObservation est la vue qu'un nœud a d'un autre nœud, échangée par gossip
+type PeerObservation struct {
+	ID      string  `json:"id"`
+	URL     string  `json:"url"`
+	Status  string  `json:"status"`
+	SeenAgo int64   `json:"seen_ago_ms"` // Ancienneté du dernier heartbeat direct
+	Load    float64 `json:"load"`
+	// Fenêtres de capacité planifiées, annoncées par le nœud lui-même
+	Planned []CapacityWindow `json:"planned_windows,omitempty"`
+}
+
+// GossipMessage est échangé entre pairs à chaque heartbeat
+type GossipMessage struct {
+	From PeerObservation   `json:"from"`
+	View []PeerObservation `json:"view"`
+}
+
+// PeerState est l'état exposé d'un pair
+type PeerState struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Location      string           `json:"location,omitempty"`
+	Status        string           `json:"status"`
+	Phi           float64          `json:"phi"`
+	Load          float64          `json:"load"`
+	RTTMs         float64          `json:"rtt_ms"` // Aller-retour gossip moyen (moyenne mobile)
+	Planned       []CapacityWindow `json:"planned_windows,omitempty"`
+	LastHeartbeat time.Time        `json:"last_heartbeat"`
+	// Nombre de pairs confirmant avoir vu ce nœud récemment alors que nous le suspectons
+	Witnesses int `json:"witnesses"`
+}
+
+// peer est l'état interne d'un pair
+type peer struct {
+	state    PeerState
+	detector phiDetector
+	// Dernière observation de ce pair rapportée par chaque autre pair
+	reports map[string]PeerObservation
+}
+
+// offloadRecord garde une copie d'une tâche déléguée à un pair, pour pouvoir
+// la redistribuer si ce pair tombe
+type offloadRecord struct {
+	Task    Task
+	PeerID  string
+	PeerURL string
+	At      time.Time
+}
+
+// PeerManager suit la santé des pairs par heartbeats et gossip
+type PeerManager struct {
+	selfURL      string
+	interval     time.Duration
+	phiThreshold float64
+	client       *http.Client
+	peers        map[string]*peer // Indexés par URL
+	offloads     map[string]offloadRecord
+	redispatched int
+	// onPeerDead est appelé (hors verrou) avec les tâches déléguées à un pair déclaré mort
+	onPeerDead func(peerID string, tasks []Task)
+	mu         sync.Mutex
+}
+
+// NewPeerManager crée le gestionnaire de pairs configuré depuis l'environnement
+func NewPeerManager() *PeerManager {
+	pm := &PeerManager{
+		selfURL:      strings.TrimRight(getEnv("ADVERTISE_URL", ""), "/"),
+		interval:     getEnvDuration("HEARTBEAT_INTERVAL", 2*time.Second),
+		phiThreshold: getEnvFloat("PHI_THRESHOLD", 8),
+		client:       newNodeClient(2 * time.Second),
+		peers:        make(map[string]*peer),
+		offloads:     make(map[string]offloadRecord),
+	}
+	for _, url := range getEnvList("PEERS") {
+		pm.addPeerLocked(strings.TrimRight(url, "/"), "")
+	}
+	return pm
+}
+
+// addPeerLocked ajoute un pair inconnu; pm.mu doit être détenu
+func (pm *PeerManager) addPeerLocked(url, id string) *peer {
+	if url == "" || url == pm.selfURL {
+		return nil
+	}
+	if p, ok := pm.peers[url]; ok {
+		if id != "" {
+			p.state.ID = id
+		}
+		return p
+	}
+	p := &peer{
+		state:   PeerState{ID: id, URL: url, Status: PeerSuspect},
+		reports: make(map[string]PeerObservation),
+	}
+	pm.peers[url] = p
+	slog.Info("Pair ajouté", "peer_url", url, "peer_id", id)
+	return p
+}
+
+// Discover ajoute un pair découvert par le registre de nœuds
+func (pm *PeerManager) Discover(url, id string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.addPeerLocked(strings.TrimRight(url, "/"), id)
+}
+
+// Run envoie périodiquement un heartbeat gossip à chaque pair et réévalue leur santé
+func (pm *PeerManager) Run(ctx context.Context, self func() PeerObservation) {
+	ticker := time.NewTicker(pm.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			msg := pm.gossipMessage(self())
+			for _, url := range pm.peerURLs() {
+				go pm.sendGossip(ctx, url, msg)
+			}
+			pm.evaluate()
+		}
+	}
+}
+
+// peerURLs retourne les URLs des pairs connus
+func (pm *PeerManager) peerURLs() []string {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	urls := make([]string, 0, len(pm.peers))
+	for url := range pm.peers {
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// gossipMessage construit notre vue du cluster
+func (pm *PeerManager) gossipMessage(self PeerObservation) GossipMessage {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	now := time.Now()
+	view := make([]PeerObservation, 0, len(pm.peers))
+	for _, p := range pm.peers {
+		obs := PeerObservation{ID: p.state.ID, URL: p.state.URL, Status: p.state.Status, Load: p.state.Load, SeenAgo: -1}
+		if !p.detector.last.IsZero() {
+			obs.SeenAgo = now.Sub(p.detector.last).Milliseconds()
+		}
+		view = append(view, obs)
+	}
+	return GossipMessage{From: self, View: view}
+}
+
+// sendGossip envoie notre vue à un pair; une réponse vaut heartbeat direct
+func (pm *PeerManager) sendGossip(ctx context.Context, url string, msg GossipMessage) {
+	body, _ := json.Marshal(msg)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/gossip", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setNodeAPIKey(req)
+
+	start := time.Now()
+	resp, err := pm.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	rtt := time.Since(start)
+
+	var reply GossipMessage
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return
+	}
+	if reply.From.URL == "" {
+		reply.From.URL = url
+	}
+	pm.receive(reply)
+	pm.observeRTT(url, rtt)
+}
+
+// observeRTT intègre un aller-retour mesuré vers un pair
+func (pm *PeerManager) observeRTT(url string, rtt time.Duration) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	p, ok := pm.peers[url]
+	if !ok {
+		return
+	}
+	ms := float64(rtt.Microseconds()) / 1000
+	if p.state.RTTMs == 0 {
+		p.state.RTTMs = ms
+	} else {
+		p.state.RTTMs = 0.8*p.state.RTTMs + 0.2*ms
+	}
+}
+
+// receive intègre un message gossip reçu d'un pair
+func (pm *PeerManager) receive(msg GossipMessage) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	now := time.Now()
+	sender := pm.addPeerLocked(strings.TrimRight(msg.From.URL, "/"), msg.From.ID)
+	if sender == nil {
+		return
+	}
+	sender.detector.heartbeat(now)
+	sender.state.LastHeartbeat = now
+	sender.state.Load = msg.From.Load
+	sender.state.Planned = msg.From.Planned
+
+	for _, obs := range msg.View {
+		p := pm.addPeerLocked(strings.TrimRight(obs.URL, "/"), obs.ID)
+		if p == nil || p == sender {
+			continue
+		}
+		p.reports[sender.state.URL] = obs
+	}
+}
+
+// evaluate recalcule phi pour chaque pair. Un pair suspect n'est déclaré mort
+// que si aucun autre pair vivant ne l'a vu récemment (vérification par gossip).
+func (pm *PeerManager) evaluate() {
+	pm.mu.Lock()
+
+	now := time.Now()
+	grace := (3 * pm.interval).Milliseconds()
+	dead := make([]string, 0)
+
+	for _, p := range pm.peers {
+		p.state.Phi = p.detector.phi(now, pm.interval)
+		if math.IsInf(p.state.Phi, 1) {
+			p.state.Phi = math.MaxFloat64
+		}
+
+		if p.state.Phi < pm.phiThreshold {
+			p.state.Status = PeerAlive
+			p.state.Witnesses = 0
+			continue
+		}
+
+		witnesses := 0
+		for reporterURL, obs := range p.reports {
+			reporter, ok := pm.peers[reporterURL]
+			if ok && reporter.state.Status == PeerAlive && obs.SeenAgo >= 0 && obs.SeenAgo < grace {
+				witnesses++
+			}
+		}
+		p.state.Witnesses = witnesses
+
+		previous := p.state.Status
+		if witnesses > 0 || p.detector.last.IsZero() {
+			// Un autre pair le voit (partition partielle), ou jamais joint: simple suspicion
+			if previous != PeerDead {
+				p.state.Status = PeerSuspect
+			}
+			continue
+		}
+		p.state.Status = PeerDead
+		if previous != PeerDead {
+			slog.Warn("Pair déclaré mort", "peer_id", p.state.ID, "peer_url", p.state.URL, "phi", p.state.Phi)
+			dead = append(dead, p.state.ID)
+		}
+	}
+
+	// Collecter les tâches déléguées aux pairs morts
+	orphaned := make(map[string][]Task)
+	for _, id := range dead {
+		for taskID, rec := range pm.offloads {
+			if rec.PeerID == id {
+				orphaned[id] = append(orphaned[id], rec.Task)
+				delete(pm.offloads, taskID)
+			}
+		}
+		pm.redispatched += len(orphaned[id])
+	}
+	onPeerDead := pm.onPeerDead
+	pm.mu.Unlock()
+
+	if onPeerDead != nil {
+		for id, tasks := range orphaned {
+			onPeerDead(id, tasks)
+		}
+	}
+}
+
+// Live retourne les pairs vivants, triés par charge croissante
+func (pm *PeerManager) Live() []PeerState {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	live := make([]PeerState, 0)
+	for _, p := range pm.peers {
+		if p.state.Status == PeerAlive {
+			live = append(live, p.state)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Load < live[j].Load })
+	return live
+}
+
+// Peers retourne l'état de tous les pairs connus
+func (pm *PeerManager) Peers() []PeerState {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	peers := make([]PeerState, 0, len(pm.peers))
+	for _, p := range pm.peers {
+		peers = append(peers, p.state)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].URL < peers[j].URL })
+	return peers
+}
+
+// TrackOffload mémorise une tâche déléguée à un pair
+func (pm *PeerManager) TrackOffload(task Task, peerID, peerURL string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.offloads[task.ID] = offloadRecord{Task: task, PeerID: peerID, PeerURL: peerURL, At: time.Now()}
+}
+
+// Offloads retourne les tâches déléguées dont la fin n'est pas encore confirmée
+func (pm *PeerManager) Offloads() []offloadRecord {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	records := make([]offloadRecord, 0, len(pm.offloads))
+	for _, rec := range pm.offloads {
+		records = append(records, rec)
+	}
+	return records
+}
+
+// CompleteOffload oublie une tâche déléguée dont le pair a confirmé la fin
+func (pm *PeerManager) CompleteOffload(taskID string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	delete(pm.offloads, taskID)
+}
+
+// Summary retourne les compteurs de santé du cluster pour les métriques
+func (pm *PeerManager) Summary() map[string]int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	summary := map[string]int{PeerAlive: 0, PeerSuspect: 0, PeerDead: 0}
+	for _, p := range pm.peers {
+		summary[p.state.Status]++
+	}
+	summary["offloaded_in_flight"] = len(pm.offloads)
+	summary["redispatched"] = pm.redispatched
+	return summary
+}
+
+// selfObservation décrit ce nœud pour les messages gossip
+func (fc *FogCompute) selfObservation() PeerObservation {
+	planned := fc.calendar.Windows(time.Now())
+
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return PeerObservation{ID: fc.node.ID, URL: fc.peers.selfURL, Status: PeerAlive, Load: fc.node.Load, Planned: planned}
+}
+
+// redispatchOrphans réadmet localement les tâches d'un pair mort par la
+// chaîne d'admission, comme toute soumission: un nœud qui draine, en standby
+// ou à l'arrêt, ou sans la capacité nécessaire, les verse dans la queue des
+// rejets (réessais automatiques, retry manuel) plutôt que de se surcharger
+func (fc *FogCompute) redispatchOrphans(peerID string, tasks []Task) {
+	for i := range tasks {
+		task := tasks[i]
+		task.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceRedispatch, Client: peerID})
+		task.QueueClass = admissionClass(&task)
+		task.PriorityClass = priorityClass(&task)
+		if task.shapingKey == "" {
+			task.shapingKey = deviceKey(&task, nil)
+		}
+
+		ac := fc.admissionSnapshot(&task, nil)
+		fc.mu.RLock()
+		stopping := fc.stopping
+		fc.mu.RUnlock()
+		var rej *Rejection
+		if ac.Draining || stopping {
+			rej = &Rejection{Reason: "Nœud en cours de drainage: tâche d'un pair mort non reprise", Status: http.StatusServiceUnavailable}
+		} else {
+			rej = fc.admission.Admit(fc, ac)
+		}
+		if rej != nil {
+			task.Status = "rejected"
+			fc.rejectTask(task, rej.Reason, ac.Load, ac.QueueSize)
+			slog.Warn("Tâche d'un pair mort refusée localement", "task_id", task.ID, "peer_id", peerID, "reason", rej.Reason)
+			continue
+		}
+
+		task.Status = "queued"
+		fc.mu.Lock()
+		fc.enqueueLocked(&task)
+		fc.mu.Unlock()
+		slog.Info("Tâche redistribuée localement après la panne d'un pair", "task_id", task.ID, "peer_id", peerID)
+	}
+}
+
+// handleGossip reçoit la vue d'un pair et répond avec la nôtre
+func (fc *FogCompute) handleGossip(w http.ResponseWriter, r *http.Request) {
+	var msg GossipMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fc.peers.receive(msg)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.peers.gossipMessage(fc.selfObservation()))
+}
+
+// handleGetPeers retourne l'état de santé des pairs connus
+func (fc *FogCompute) handleGetPeers(w http.ResponseWriter, r *http.Request) {
+	peers := fc.peers.Peers()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total": len(peers),
+		"peers": peers,
+	})
+}
@@ -0,0 +1,193 @@
+package fognode
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPhiDetector(t *testing.T) {
+	var d phiDetector
+	now := time.Now()
+	if phi := d.phi(now, time.Second); !math.IsInf(phi, 1) {
+		t.Errorf("jamais joint: phi = %v, attendu +Inf", phi)
+	}
+
+	// Heartbeats réguliers toutes les secondes
+	for i := 0; i < 10; i++ {
+		d.heartbeat(now.Add(time.Duration(i) * time.Second))
+	}
+	last := now.Add(9 * time.Second)
+	if len(d.intervals) != 9 {
+		t.Fatalf("%d intervalles, attendu 9", len(d.intervals))
+	}
+
+	// Sans retard, phi reste bas; il croît avec le silence
+	prev := -1.0
+	for _, elapsed := range []time.Duration{500 * time.Millisecond, time.Second, 1200 * time.Millisecond, 1500 * time.Millisecond} {
+		phi := d.phi(last.Add(elapsed), time.Second)
+		if phi <= prev {
+			t.Errorf("phi(%v) = %v, non croissant (précédent %v)", elapsed, phi, prev)
+		}
+		prev = phi
+	}
+	if phi := d.phi(last.Add(time.Second), time.Second); math.Abs(phi-math.Log10(2)) > 1e-9 {
+		t.Errorf("phi à l'échéance = %v, attendu log10(2)", phi)
+	}
+	// Écart type plancher: des intervalles parfaitement réguliers ne rendent
+	// pas le détecteur instantanément définitif
+	if phi := d.phi(last.Add(1100*time.Millisecond), time.Second); phi > 1 {
+		t.Errorf("phi à +100ms = %v, écart type plancher non appliqué", phi)
+	}
+	if phi := d.phi(last.Add(5*time.Second), time.Second); phi < 8 {
+		t.Errorf("phi après 5s de silence = %v, attendu au-delà du seuil", phi)
+	}
+
+	// Fenêtre glissante bornée
+	for i := 10; i < 10+2*phiWindowSize; i++ {
+		d.heartbeat(now.Add(time.Duration(i) * time.Second))
+	}
+	if len(d.intervals) != phiWindowSize {
+		t.Errorf("%d intervalles, attendu %d", len(d.intervals), phiWindowSize)
+	}
+}
+
+func TestPhiDetectorWithoutIntervals(t *testing.T) {
+	// Un seul heartbeat: l'intervalle attendu sert de moyenne
+	var d phiDetector
+	now := time.Now()
+	d.heartbeat(now)
+	if phi := d.phi(now.Add(time.Second), time.Second); math.Abs(phi-math.Log10(2)) > 1e-9 {
+		t.Errorf("phi = %v, attendu log10(2)", phi)
+	}
+}
+
+// testPeerManager crée un gestionnaire de pairs sans réseau
+func testPeerManager() *PeerManager {
+	return &PeerManager{
+		interval:     time.Second,
+		phiThreshold: 8,
+		peers:        make(map[string]*peer),
+		offloads:     make(map[string]offloadRecord),
+	}
+}
+
+// heartbeats simule des heartbeats réguliers, le dernier il y a silence
+func heartbeats(p *peer, silence time.Duration) {
+	last := time.Now().Add(-silence)
+	for i := 5; i >= 0; i-- {
+		p.detector.heartbeat(last.Add(-time.Duration(i) * time.Second))
+	}
+}
+
+func TestPeerEvaluateTransitions(t *testing.T) {
+	pm := testPeerManager()
+	var deadCalls []string
+	var orphans []Task
+	pm.onPeerDead = func(peerID string, tasks []Task) {
+		deadCalls = append(deadCalls, peerID)
+		orphans = append(orphans, tasks...)
+	}
+
+	pm.mu.Lock()
+	a := pm.addPeerLocked("http://a", "node-a")
+	b := pm.addPeerLocked("http://b", "node-b")
+	never := pm.addPeerLocked("http://never", "node-never")
+	pm.mu.Unlock()
+	pm.TrackOffload(Task{ID: "t1"}, "node-a", "http://a")
+	pm.TrackOffload(Task{ID: "t2"}, "node-b", "http://b")
+
+	heartbeats(a, 0)
+	heartbeats(b, 0)
+	pm.evaluate()
+	for _, p := range []*peer{a, b} {
+		if p.state.Status != PeerAlive {
+			t.Errorf("%s: statut %s, attendu %s", p.state.ID, p.state.Status, PeerAlive)
+		}
+	}
+	// Jamais joint: suspect, jamais mort
+	if never.state.Status != PeerSuspect {
+		t.Errorf("pair jamais joint: statut %s, attendu %s", never.state.Status, PeerSuspect)
+	}
+
+	// a se tait, mais b le voit encore: partition partielle, simple suspicion
+	a.detector = phiDetector{}
+	heartbeats(a, 10*time.Second)
+	pm.mu.Lock()
+	a.reports["http://b"] = PeerObservation{ID: "node-a", URL: "http://a", SeenAgo: 500}
+	pm.mu.Unlock()
+	pm.evaluate()
+	if a.state.Status != PeerSuspect || a.state.Witnesses != 1 {
+		t.Errorf("a: statut %s, %d témoins, attendu %s avec 1 témoin", a.state.Status, a.state.Witnesses, PeerSuspect)
+	}
+	if len(deadCalls) != 0 {
+		t.Fatalf("onPeerDead appelé pour un pair suspect: %v", deadCalls)
+	}
+
+	// Le témoignage de b est trop ancien: a est déclaré mort, ses tâches redistribuées
+	pm.mu.Lock()
+	a.reports["http://b"] = PeerObservation{ID: "node-a", URL: "http://a", SeenAgo: 10000}
+	pm.mu.Unlock()
+	pm.evaluate()
+	if a.state.Status != PeerDead {
+		t.Errorf("a: statut %s, attendu %s", a.state.Status, PeerDead)
+	}
+	if len(deadCalls) != 1 || deadCalls[0] != "node-a" || len(orphans) != 1 || orphans[0].ID != "t1" {
+		t.Fatalf("onPeerDead: pairs %v, tâches %v, attendu node-a et t1", deadCalls, orphans)
+	}
+	if b.state.Status != PeerAlive {
+		t.Errorf("b: statut %s, attendu %s", b.state.Status, PeerAlive)
+	}
+
+	// Un pair déjà mort n'est pas redistribué deux fois, ni ramené à suspect
+	pm.mu.Lock()
+	a.reports["http://b"] = PeerObservation{ID: "node-a", URL: "http://a", SeenAgo: 500}
+	pm.mu.Unlock()
+	pm.evaluate()
+	if a.state.Status != PeerDead || len(deadCalls) != 1 {
+		t.Errorf("a: statut %s, %d appels onPeerDead, attendu %s et 1 appel", a.state.Status, len(deadCalls), PeerDead)
+	}
+
+	// Un heartbeat direct le ramène en vie
+	a.detector.heartbeat(time.Now())
+	pm.evaluate()
+	if a.state.Status != PeerAlive {
+		t.Errorf("a après heartbeat: statut %s, attendu %s", a.state.Status, PeerAlive)
+	}
+	if pm.offloads["t2"].PeerID != "node-b" {
+		t.Errorf("délégation à b perdue: %v", pm.offloads)
+	}
+}
+
+func TestRedispatchOrphansAdmission(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	fc := NewFogCompute("test-node", "test-site")
+	defer fc.store.Close()
+	defer fc.journal.Close()
+
+	fc.redispatchOrphans("node-a", []Task{{ID: "orphan-1", Status: "offloaded"}})
+	fc.mu.RLock()
+	task, ok := fc.tasks["orphan-1"]
+	fc.mu.RUnlock()
+	if !ok || task.Status != "queued" {
+		t.Fatalf("tâche orpheline non reprise en queue: %+v", task)
+	}
+
+	// Un nœud qui draine ne se surcharge pas: la tâche va dans la queue des rejets
+	fc.setDraining(true)
+	fc.redispatchOrphans("node-a", []Task{{ID: "orphan-2", Status: "offloaded"}})
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	if _, ok := fc.tasks["orphan-2"]; ok {
+		t.Error("tâche orpheline mise en queue sur un nœud qui draine")
+	}
+	found := false
+	for _, rt := range fc.rejectedTasks {
+		if rt.Task.ID == "orphan-2" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("tâche orpheline absente de la queue des rejets")
+	}
+}
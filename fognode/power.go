@@ -0,0 +1,250 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profils de recharge de la batterie du nœud (ENERGY_SOURCE)
+const (
+	PowerProfileGrid  = "grid"  // Secteur: recharge constante à ENERGY_RECHARGE_W
+	PowerProfileSolar = "solar" // Panneau: courbe horaire ENERGY_SOLAR_CURVE, ou demi-sinusoïde entre lever et coucher du soleil
+	PowerProfileNone  = "none"  // Aucune recharge: la batterie ne fait que se décharger
+)
+
+// PowerStatus décrit la batterie et la source du nœud (GET /power)
+type PowerStatus struct {
+	Level       float64   `json:"level"` // Niveau de charge (0-1)
+	CapacityWh  float64   `json:"capacity_wh"`
+	RemainingWh float64   `json:"remaining_wh"`
+	Reserve     float64   `json:"reserve"` // Niveau gardé pour les tâches critiques
+	Profile     string    `json:"profile"`
+	ChargeW     float64   `json:"charge_w"`    // Puissance apportée par la source
+	DischargeW  float64   `json:"discharge_w"` // Consommation: repos + workers occupés
+	NetW        float64   `json:"net_w"`
+	BusyWorkers int       `json:"busy_workers"`
+	TimeToEmpty *string   `json:"time_to_empty,omitempty"` // Au rythme actuel, si la batterie se décharge
+	TimeToFull  *string   `json:"time_to_full,omitempty"`  // Au rythme actuel, si elle se recharge
+	ForecastW   []float64 `json:"charge_forecast_w"`       // Recharge attendue pour chacune des 24 prochaines heures
+	ConsumedWh  float64   `json:"consumed_wh"`             // Énergie prise à la batterie
+	RechargedWh float64   `json:"recharged_wh"`            // Énergie apportée par la source
+	TasksWh     float64   `json:"tasks_wh"`                // Énergie attribuée aux exécutions (mesurée ou déclarée)
+}
+
+// PowerManager modélise la batterie du nœud (fc.energyLevel): elle se
+// décharge d'une puissance de repos et d'une puissance par worker occupé, et
+// seule la source configurée (secteur, courbe solaire) la recharge. Le niveau
+// reste borné à [0, 1] et alimente l'admission (politique energy) et les
+// workers en basse énergie (ENERGY_WORKER_POLICY).
+type PowerManager struct {
+	capacityWh float64
+	profile    string
+	gridW      float64
+	solarPeakW float64 // Solaire sans courbe: puissance à midi solaire
+	sunrise    float64 // Heures locales
+	sunset     float64
+	solarCurve []float64 // Solaire: puissance (W) par heure locale, 24 valeurs
+	idleW      float64   // Consommation du nœud au repos
+	workerW    float64   // Consommation supplémentaire par worker occupé
+	reserve    float64
+
+	// Protégés par fc.mu
+	dischargeW  float64
+	consumedWh  float64
+	rechargedWh float64
+	tasksWh     float64
+}
+
+// NewPowerManager lit ENERGY_CAPACITY_WH, ENERGY_SOURCE, ENERGY_RECHARGE_W,
+// ENERGY_SOLAR_PEAK_W, ENERGY_SOLAR_SUNRISE, ENERGY_SOLAR_SUNSET,
+// ENERGY_SOLAR_CURVE, POWER_IDLE_W, POWER_WORKER_W et POWER_RESERVE
+func NewPowerManager() *PowerManager {
+	pm := &PowerManager{
+		capacityWh: getEnvFloat("ENERGY_CAPACITY_WH", 100),
+		profile:    getEnv("ENERGY_SOURCE", PowerProfileGrid),
+		gridW:      getEnvFloat("ENERGY_RECHARGE_W", 20),
+		solarPeakW: getEnvFloat("ENERGY_SOLAR_PEAK_W", 30),
+		sunrise:    getEnvFloat("ENERGY_SOLAR_SUNRISE", 6),
+		sunset:     getEnvFloat("ENERGY_SOLAR_SUNSET", 20),
+		idleW:      getEnvFloat("POWER_IDLE_W", 2),
+		workerW:    getEnvFloat("POWER_WORKER_W", 3),
+		reserve:    clamp01(getEnvFloat("POWER_RESERVE", 0.1)),
+	}
+	switch pm.profile {
+	case PowerProfileGrid, PowerProfileSolar, PowerProfileNone:
+	case "mains":
+		pm.profile = PowerProfileGrid
+	default:
+		slog.Warn("Source ENERGY_SOURCE inconnue", "source", pm.profile, "default", PowerProfileGrid)
+		pm.profile = PowerProfileGrid
+	}
+	if pm.capacityWh <= 0 {
+		slog.Warn("ENERGY_CAPACITY_WH invalide", "value", pm.capacityWh, "default", 100)
+		pm.capacityWh = 100
+	}
+	if curve := getEnvList("ENERGY_SOLAR_CURVE"); len(curve) > 0 {
+		pm.solarCurve = parseSolarCurve(curve)
+	}
+	pm.dischargeW = pm.idleW
+	return pm
+}
+
+// parseSolarCurve lit les 24 puissances horaires de ENERGY_SOLAR_CURVE; une
+// courbe invalide est ignorée au profit de la demi-sinusoïde
+func parseSolarCurve(values []string) []float64 {
+	if len(values) != 24 {
+		slog.Warn("ENERGY_SOLAR_CURVE ignorée: 24 valeurs horaires attendues", "values", len(values))
+		return nil
+	}
+	curve := make([]float64, 24)
+	for i, v := range values {
+		w, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || w < 0 {
+			slog.Warn("ENERGY_SOLAR_CURVE ignorée: puissance invalide", "hour", i, "value", v)
+			return nil
+		}
+		curve[i] = w
+	}
+	return curve
+}
+
+// chargeW retourne la puissance de recharge de la source à l'instant donné
+func (pm *PowerManager) chargeW(now time.Time) float64 {
+	switch pm.profile {
+	case PowerProfileGrid:
+		return pm.gridW
+	case PowerProfileSolar:
+		hour := float64(now.Hour()) + float64(now.Minute())/60 + float64(now.Second())/3600
+		if pm.solarCurve != nil {
+			// Interpolation linéaire entre deux heures de la courbe
+			h := int(hour)
+			frac := hour - float64(h)
+			return pm.solarCurve[h]*(1-frac) + pm.solarCurve[(h+1)%24]*frac
+		}
+		if hour <= pm.sunrise || hour >= pm.sunset {
+			return 0
+		}
+		return pm.solarPeakW * math.Sin(math.Pi*(hour-pm.sunrise)/(pm.sunset-pm.sunrise))
+	}
+	return 0
+}
+
+// executionEnergy retourne l'énergie attribuée à une exécution: celle
+// mesurée si la plateforme la mesure, sinon celle déclarée par la tâche
+func executionEnergy(task *Task, usage ResourceUsage) float64 {
+	if usage.CPU > 0 {
+		return usage.Energy
+	}
+	return task.EnergyCost
+}
+
+// executedLocked attribue l'énergie d'une exécution; la batterie, elle, se
+// décharge au rythme des workers occupés. fc.mu doit être détenu.
+func (pm *PowerManager) executedLocked(wh float64) {
+	if wh > 0 {
+		pm.tasksWh += wh
+	}
+}
+
+// runPower fait évoluer la batterie chaque seconde: recharge par la source,
+// décharge au repos et par worker occupé
+func (fc *FogCompute) runPower(ctx context.Context) {
+	pm := fc.power
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			hours := now.Sub(last).Hours()
+			last = now
+			charge := pm.chargeW(now)
+
+			fc.mu.Lock()
+			pm.dischargeW = pm.idleW + float64(fc.inFlight)*pm.workerW
+			before := fc.energyLevel
+			fc.energyLevel = clamp01(fc.energyLevel + (charge-pm.dischargeW)*hours/pm.capacityWh)
+			if delta := (fc.energyLevel - before) * pm.capacityWh; delta > 0 {
+				pm.rechargedWh += delta
+			} else {
+				pm.consumedWh -= delta
+			}
+			empty := before > 0 && fc.energyLevel == 0
+			fc.updatePowerModeLocked(now)
+			fc.mu.Unlock()
+
+			if empty {
+				slog.Warn("Batterie vide", "profile", pm.profile, "discharge_w", pm.dischargeW, "charge_w", charge)
+			}
+		}
+	}
+}
+
+// powerStatusLocked retourne l'état de la batterie; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) powerStatusLocked(now time.Time) PowerStatus {
+	pm := fc.power
+	s := PowerStatus{
+		Level:       fc.energyLevel,
+		CapacityWh:  pm.capacityWh,
+		RemainingWh: fc.energyLevel * pm.capacityWh,
+		Reserve:     pm.reserve,
+		Profile:     pm.profile,
+		ChargeW:     pm.chargeW(now),
+		DischargeW:  pm.dischargeW,
+		BusyWorkers: fc.inFlight,
+		ForecastW:   make([]float64, 24),
+		ConsumedWh:  pm.consumedWh,
+		RechargedWh: pm.rechargedWh,
+		TasksWh:     pm.tasksWh,
+	}
+	s.NetW = s.ChargeW - s.DischargeW
+	switch {
+	case s.NetW < 0:
+		d := time.Duration(s.RemainingWh / -s.NetW * float64(time.Hour)).Round(time.Second).String()
+		s.TimeToEmpty = &d
+	case s.NetW > 0 && s.Level < 1:
+		d := time.Duration((pm.capacityWh - s.RemainingWh) / s.NetW * float64(time.Hour)).Round(time.Second).String()
+		s.TimeToFull = &d
+	}
+	for h := range s.ForecastW {
+		s.ForecastW[h] = pm.chargeW(now.Add(time.Duration(h) * time.Hour))
+	}
+	return s
+}
+
+// handleGetPower retourne l'état de la batterie et de la source
+func (fc *FogCompute) handleGetPower(w http.ResponseWriter, r *http.Request) {
+	fc.mu.RLock()
+	status := fc.powerStatusLocked(time.Now())
+	fc.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// checkPower refuse une tâche dont l'énergie déclarée entamerait la réserve
+// gardée pour les tâches critiques, ou dépasserait ce qui reste en batterie
+func checkPower(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	t, p := ac.Task, ac.Power
+	available := p.RemainingWh
+	if t.Criticality < 4 {
+		available -= p.Reserve * p.CapacityWh
+	}
+	if t.EnergyCost > available {
+		rejection := reject("Énergie insuffisante: %.2f Wh demandés, %.2f Wh disponibles (batterie %.0f%%, réserve %.0f%%)",
+			t.EnergyCost, math.Max(available, 0), p.Level*100, p.Reserve*100)
+		if p.NetW > 0 {
+			// Le temps que la source recharge ce qui manque
+			rejection.RetryAfter = time.Duration((t.EnergyCost - available) / p.NetW * float64(time.Hour))
+		}
+		return rejection
+	}
+	return nil
+}
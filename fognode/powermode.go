@@ -0,0 +1,214 @@
+package fognode
+
+import (
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Modes d'alimentation du nœud, choisis d'après le niveau de la batterie
+const (
+	PowerModeNormal   = "normal"
+	PowerModeSaver    = "saver"    // Sous POWER_SAVER_LEVEL
+	PowerModeCritical = "critical" // Sous POWER_CRITICAL_LEVEL
+)
+
+// PowerModeStats décrit le mode d'alimentation courant et ses effets
+type PowerModeStats struct {
+	Mode          string    `json:"mode"`
+	Since         time.Time `json:"since"`
+	SaverLevel    float64   `json:"saver_level"`
+	CriticalLevel float64   `json:"critical_level"`
+	WorkerCap     int       `json:"worker_cap,omitempty"` // Workers généraux actifs dans ce mode (0: tous)
+	EnergyBoost   float64   `json:"energy_score_boost"`   // Multiplicateur courant du terme energy du SmartScore
+	Waiting       int       `json:"waiting"`              // Tâches reportées en attente du retour en mode normal
+	Deferred      int       `json:"deferred"`
+	Rejected      int       `json:"rejected"`
+	Transitions   int       `json:"transitions"`
+}
+
+// energyScoreBoost multiplie le poids du terme energy du SmartScore hors mode
+// normal (bits d'un float64, 0: aucun); lu sans verrou par calculateScore
+var energyScoreBoost atomic.Uint64
+
+// currentEnergyBoost retourne le multiplicateur courant du terme energy
+func currentEnergyBoost() float64 {
+	if bits := energyScoreBoost.Load(); bits != 0 {
+		return math.Float64frombits(bits)
+	}
+	return 1
+}
+
+// energyTerm est la position du terme energy dans les vecteurs du SmartScore
+var energyTerm = termIndex("energy")
+
+// PowerModes bascule le nœud en mode économie puis critique quand la batterie
+// se vide: les tâches de faible criticité sans échéance sont reportées jusqu'au
+// retour en mode normal (et refusées à l'admission en mode critique), moins de
+// workers généraux prennent des tâches, et le SmartScore favorise les tâches
+// peu gourmandes en énergie. Le retour au mode supérieur exige de repasser le
+// seuil d'au moins POWER_MODE_HYSTERESIS.
+type PowerModes struct {
+	saverLevel      float64
+	criticalLevel   float64
+	hysteresis      float64
+	lowCriticality  int // Criticité en dessous de laquelle une tâche est reportée ou refusée
+	saverWorkers    int
+	criticalWorkers int
+	boost           float64
+
+	// Protégés par fc.mu
+	mode        string
+	since       time.Time
+	waiting     []*Task
+	deferred    int
+	rejected    int
+	transitions int
+}
+
+// loadPowerModes lit POWER_SAVER_LEVEL, POWER_CRITICAL_LEVEL,
+// POWER_MODE_HYSTERESIS, POWER_LOW_CRITICALITY, POWER_SAVER_WORKERS,
+// POWER_CRITICAL_WORKERS et POWER_ENERGY_SCORE_BOOST
+func loadPowerModes() *PowerModes {
+	pm := &PowerModes{
+		saverLevel:      getEnvFloat("POWER_SAVER_LEVEL", 0.5),
+		criticalLevel:   getEnvFloat("POWER_CRITICAL_LEVEL", 0.2),
+		hysteresis:      getEnvFloat("POWER_MODE_HYSTERESIS", 0.05),
+		lowCriticality:  getEnvInt("POWER_LOW_CRITICALITY", 3),
+		saverWorkers:    getEnvInt("POWER_SAVER_WORKERS", 2),
+		criticalWorkers: getEnvInt("POWER_CRITICAL_WORKERS", 1),
+		boost:           getEnvFloat("POWER_ENERGY_SCORE_BOOST", 5),
+		mode:            PowerModeNormal,
+		since:           time.Now(),
+	}
+	if pm.criticalLevel > pm.saverLevel {
+		slog.Warn("POWER_CRITICAL_LEVEL supérieur à POWER_SAVER_LEVEL", "critical", pm.criticalLevel, "saver", pm.saverLevel)
+		pm.criticalLevel = pm.saverLevel
+	}
+	return pm
+}
+
+// nextMode retourne le mode correspondant au niveau d'énergie, avec hystérésis à la remontée
+func (pm *PowerModes) nextMode(level float64) string {
+	switch pm.mode {
+	case PowerModeCritical:
+		if level < pm.criticalLevel+pm.hysteresis {
+			return PowerModeCritical
+		}
+	case PowerModeSaver:
+		if level < pm.criticalLevel {
+			return PowerModeCritical
+		}
+		if level < pm.saverLevel+pm.hysteresis {
+			return PowerModeSaver
+		}
+	}
+	switch {
+	case level < pm.criticalLevel:
+		return PowerModeCritical
+	case level < pm.saverLevel:
+		return PowerModeSaver
+	}
+	return PowerModeNormal
+}
+
+// workerCapLocked retourne le nombre de workers généraux actifs, 0 sans limite; fc.mu doit être détenu
+func (pm *PowerModes) workerCapLocked() int {
+	switch pm.mode {
+	case PowerModeSaver:
+		return pm.saverWorkers
+	case PowerModeCritical:
+		return pm.criticalWorkers
+	}
+	return 0
+}
+
+// lowCriticalityTask indique si la tâche peut attendre le retour de l'énergie
+func (pm *PowerModes) lowCriticalityTask(task *Task) bool {
+	return task.Criticality < pm.lowCriticality
+}
+
+// updatePowerModeLocked change de mode d'après le niveau d'énergie; fc.mu doit être détenu
+func (fc *FogCompute) updatePowerModeLocked(now time.Time) {
+	pm := fc.powerModes
+	next := pm.nextMode(fc.energyLevel)
+	if next == pm.mode {
+		return
+	}
+	previous := pm.mode
+	pm.mode, pm.since = next, now
+	pm.transitions++
+	slog.Warn("Mode d'alimentation", "mode", next, "previous", previous, "energy", fc.energyLevel,
+		"worker_cap", pm.workerCapLocked())
+	fc.node.PowerMode = next
+
+	boost := 1.0
+	if next != PowerModeNormal {
+		boost = pm.boost
+	}
+	energyScoreBoost.Store(math.Float64bits(boost))
+	fc.scheduler.Rescore()
+
+	if next == PowerModeNormal {
+		for _, task := range pm.waiting {
+			fc.requeueDeferredLocked(task)
+		}
+		pm.waiting = nil
+	}
+	fc.cond.Broadcast() // Le nombre de workers actifs a changé
+}
+
+// deferForPowerLocked reporte, hors mode normal, une tâche de faible
+// criticité sans échéance jusqu'au retour en mode normal; fc.mu doit être détenu
+func (fc *FogCompute) deferForPowerLocked(task *Task) bool {
+	pm := fc.powerModes
+	if pm.mode == PowerModeNormal || !pm.lowCriticalityTask(task) || task.Deadline != nil {
+		return false
+	}
+	fc.releaseLocked(task)
+	task.Status = "deferred"
+	task.NextAttemptAt = nil
+	fc.saveTaskLocked(task)
+	pm.waiting = append(pm.waiting, task)
+	pm.deferred++
+	slog.Info("Tâche reportée jusqu'au retour de l'énergie", "task_id", task.ID, "mode", pm.mode,
+		"criticality", task.Criticality, "energy", fc.energyLevel)
+	return true
+}
+
+// checkPowerMode refuse en mode critique les tâches de faible criticité
+func checkPowerMode(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	pm := fc.powerModes
+	if ac.PowerMode != PowerModeCritical || !pm.lowCriticalityTask(ac.Task) {
+		return nil
+	}
+	fc.mu.Lock()
+	pm.rejected++
+	fc.mu.Unlock()
+	rejection := reject("Mode d'alimentation critique: tâches de criticité < %d refusées (énergie=%.2f)",
+		pm.lowCriticality, ac.EnergyLevel)
+	if ac.Power.NetW > 0 {
+		// Le temps que la source ramène la batterie au-dessus du seuil critique
+		missing := (pm.criticalLevel + pm.hysteresis - ac.EnergyLevel) * ac.Power.CapacityWh
+		rejection.RetryAfter = time.Duration(missing / ac.Power.NetW * float64(time.Hour))
+	}
+	return rejection
+}
+
+// powerModeStatsLocked retourne l'état du mode d'alimentation; fc.mu doit être détenu
+func (fc *FogCompute) powerModeStatsLocked() PowerModeStats {
+	pm := fc.powerModes
+	return PowerModeStats{
+		Mode:          pm.mode,
+		Since:         pm.since,
+		SaverLevel:    pm.saverLevel,
+		CriticalLevel: pm.criticalLevel,
+		WorkerCap:     pm.workerCapLocked(),
+		EnergyBoost:   currentEnergyBoost(),
+		Waiting:       len(pm.waiting),
+		Deferred:      pm.deferred,
+		Rejected:      pm.rejected,
+		Transitions:   pm.transitions,
+	}
+}
@@ -0,0 +1,161 @@
+package fognode
+
+import (
+	"log/slog"
+	"sort"
+)
+
+// PreemptionStats décrit la préemption des tâches (/metrics)
+type PreemptionStats struct {
+	Enabled        bool  `json:"enabled"`
+	MaxCriticality int   `json:"max_criticality"` // Criticité maximale d'une tâche préemptable
+	Preempted      int64 `json:"preempted"`
+	Held           int   `json:"held"` // Tâches préemptées attendant la fin de la tâche critique
+}
+
+// Preemption libère les ressources pour une tâche de criticité 5 quand le
+// nœud est plein: des tâches en cours de criticité <= PREEMPT_MAX_CRITICALITY
+// (les moins critiques, puis les plus récemment démarrées) sont interrompues,
+// leurs ressources passent à la tâche critique, et elles reviennent en queue
+// quand celle-ci libère les siennes.
+type Preemption struct {
+	enabled        bool
+	maxCriticality int
+
+	// Protégés par fc.mu
+	preempted int64
+}
+
+// loadPreemption lit PREEMPTION et PREEMPT_MAX_CRITICALITY
+func loadPreemption() *Preemption {
+	return &Preemption{
+		enabled:        getEnvBool("PREEMPTION", true),
+		maxCriticality: getEnvInt("PREEMPT_MAX_CRITICALITY", 2),
+	}
+}
+
+// preemptsFor indique si la tâche peut préempter des tâches en cours
+func (p *Preemption) preemptsFor(task *Task) bool {
+	return p.enabled && task.Criticality >= 5
+}
+
+// fitsLocked indique si les ressources disponibles suffisent à la tâche; fc.mu doit être détenu
+func (fc *FogCompute) fitsLocked(task *Task) bool {
+	return task.CPUCost <= fc.availableCPU && task.RAMCost <= fc.availableRAM && task.StorageCost <= fc.availableStorage
+}
+
+// victimsLocked choisit les tâches en cours dont l'interruption libère assez
+// de ressources pour la tâche critique, nil si cela ne suffit pas; fc.mu doit
+// être détenu, au moins en lecture
+func (fc *FogCompute) victimsLocked(task *Task) []*Task {
+	var candidates []*Task
+	for _, t := range fc.tasks {
+		if t.Status == "processing" && t.cancel != nil && !t.preempted && t.Criticality <= fc.preemption.maxCriticality {
+			candidates = append(candidates, t)
+		}
+	}
+	// Les moins critiques d'abord, puis celles qui ont le moins avancé
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Criticality != candidates[j].Criticality {
+			return candidates[i].Criticality < candidates[j].Criticality
+		}
+		return candidates[i].startedAt.After(candidates[j].startedAt)
+	})
+
+	cpu, ram, storage := fc.availableCPU, fc.availableRAM, fc.availableStorage
+	var victims []*Task
+	for _, t := range candidates {
+		if task.CPUCost <= cpu && task.RAMCost <= ram && task.StorageCost <= storage {
+			break
+		}
+		victims = append(victims, t)
+		cpu, ram, storage = cpu+t.CPUCost, ram+t.RAMCost, storage+t.StorageCost
+	}
+	if task.CPUCost > cpu || task.RAMCost > ram || task.StorageCost > storage {
+		return nil
+	}
+	return victims
+}
+
+// canPreempt indique si préempter des tâches en cours permettrait d'admettre la tâche
+func (fc *FogCompute) canPreempt(task *Task) bool {
+	if !fc.preemption.preemptsFor(task) {
+		return false
+	}
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return len(fc.victimsLocked(task)) > 0
+}
+
+// preemptForLocked interrompt, avant la mise en queue d'une tâche critique
+// qui ne tient pas dans les ressources disponibles, les tâches en cours
+// choisies par victimsLocked; leurs ressources sont libérées immédiatement.
+// fc.mu doit être détenu.
+func (fc *FogCompute) preemptForLocked(task *Task) {
+	if !fc.preemption.preemptsFor(task) || fc.fitsLocked(task) {
+		return
+	}
+	for _, v := range fc.victimsLocked(task) {
+		v.preempted, v.preemptedBy = true, task.ID
+		fc.releaseLocked(v)
+		v.cancel()
+		task.preempting = append(task.preempting, v)
+		fc.preemption.preempted++
+
+		ev := taskEvent(EventPreempted, v)
+		ev.Reason = "préemptée par " + task.ID
+		fc.events.Publish(ev)
+		slog.Warn("Tâche préemptée pour une tâche critique", "task_id", v.ID, "criticality", v.Criticality,
+			"preempted_by", task.ID)
+	}
+}
+
+// preemptedLocked termine l'exécution interrompue d'une tâche préemptée: elle
+// attend la fin de la tâche critique, ou revient en queue si celle-ci a déjà
+// libéré ses ressources. fc.mu doit être détenu.
+func (fc *FogCompute) preemptedLocked(task *Task) {
+	task.cancel = nil
+	task.Attempts-- // L'interruption ne consomme pas de tentative
+	task.Status = "preempted"
+	if task.preemptedBy == "" {
+		fc.resumePreemptedLocked(task)
+		return
+	}
+	fc.saveTaskLocked(task)
+}
+
+// releasePreemptedLocked remet en queue les tâches préemptées par une tâche
+// qui libère ses ressources; fc.mu doit être détenu
+func (fc *FogCompute) releasePreemptedLocked(task *Task) {
+	victims := task.preempting
+	task.preempting = nil
+	for _, v := range victims {
+		v.preemptedBy = ""
+		if v.Status == "preempted" {
+			fc.resumePreemptedLocked(v)
+		}
+	}
+}
+
+// resumePreemptedLocked remet en queue une tâche préemptée; fc.mu doit être détenu
+func (fc *FogCompute) resumePreemptedLocked(task *Task) {
+	task.preempted = false
+	task.Status = "queued"
+	fc.enqueueLocked(task)
+	slog.Info("Tâche préemptée remise en queue", "task_id", task.ID)
+}
+
+// preemptionStatsLocked retourne l'état de la préemption; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) preemptionStatsLocked() PreemptionStats {
+	stats := PreemptionStats{
+		Enabled:        fc.preemption.enabled,
+		MaxCriticality: fc.preemption.maxCriticality,
+		Preempted:      fc.preemption.preempted,
+	}
+	for _, t := range fc.tasks {
+		if t.preempted {
+			stats.Held++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,169 @@
+package fognode
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newPreemptionNode crée un nœud sans workers dont les ressources valent 1 CPU
+// et 1 RAM; les coûts des tests sont des fractions binaires, exactes en float64
+func newPreemptionNode(t *testing.T) *FogCompute {
+	t.Helper()
+	t.Setenv("DATA_DIR", t.TempDir())
+	fc := NewFogCompute("test-node", "test-site")
+	t.Cleanup(func() {
+		fc.store.Close()
+		fc.journal.Close()
+	})
+	fc.preemption = &Preemption{enabled: true, maxCriticality: 2}
+	fc.availableCPU, fc.availableRAM, fc.availableStorage = 1, 1, 100
+	return fc
+}
+
+// startRunning simule une tâche prise par un worker il y a age; fc.mu doit être détenu
+func startRunning(fc *FogCompute, id string, criticality int, cpu, ram float64, age time.Duration) *Task {
+	task := &Task{ID: id, Type: "compute", Criticality: criticality, CPUCost: cpu, RAMCost: ram, StorageCost: 1}
+	fc.reserveLocked(task)
+	fc.tasks[task.ID] = task
+	task.Status = "processing"
+	task.Attempts = 1
+	task.startedAt = time.Now().Add(-age)
+	task.cancel = func() { task.Result = "annulée" }
+	return task
+}
+
+func TestPreemption(t *testing.T) {
+	tests := []struct {
+		name        string
+		cpu, ram    float64
+		wantVictims []string
+	}{
+		// Libre: 0.25 CPU, 0.25 RAM
+		{"une victime", 0.5, 0.25, []string{"faible-récente"}},
+		{"deux victimes", 0.625, 0.25, []string{"faible-récente", "faible-ancienne"}},
+		{"trois victimes", 0.625, 0.75, []string{"faible-récente", "faible-ancienne", "moyenne"}},
+		{"aucune suffisante", 1, 0.25, nil},
+		{"tient sans préemption", 0.25, 0.25, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := newPreemptionNode(t)
+			fc.mu.Lock()
+			defer fc.mu.Unlock()
+
+			running := []*Task{
+				startRunning(fc, "faible-ancienne", 1, 0.125, 0.125, 2*time.Second),
+				startRunning(fc, "faible-récente", 1, 0.25, 0.25, time.Second),
+				startRunning(fc, "moyenne", 2, 0.25, 0.25, 3*time.Second),
+				startRunning(fc, "non-préemptable", 3, 0.125, 0.125, time.Second),
+			}
+			cpuBefore, ramBefore := fc.availableCPU, fc.availableRAM
+
+			critical := &Task{ID: "critique", Type: "compute", Criticality: 5, CPUCost: tt.cpu, RAMCost: tt.ram, StorageCost: 1}
+			fc.enqueueLocked(critical)
+
+			var victims []string
+			for _, v := range critical.preempting {
+				victims = append(victims, v.ID)
+			}
+			if !reflect.DeepEqual(victims, tt.wantVictims) {
+				t.Fatalf("victimes %v, attendu %v", victims, tt.wantVictims)
+			}
+			for _, task := range running {
+				preempted := false
+				for _, id := range tt.wantVictims {
+					preempted = preempted || id == task.ID
+				}
+				if task.preempted != preempted || (task.Result == "annulée") != preempted {
+					t.Errorf("%s: préemptée = %v, annulée = %v, attendu %v", task.ID, task.preempted, task.Result == "annulée", preempted)
+				}
+			}
+			if got := fc.preemptionStatsLocked().Preempted; got != int64(len(tt.wantVictims)) {
+				t.Errorf("%d préemptions comptées, attendu %d", got, len(tt.wantVictims))
+			}
+
+			// Les workers des victimes constatent l'interruption: aucune tentative consommée
+			for _, v := range critical.preempting {
+				fc.preemptedLocked(v)
+				if v.Status != "preempted" || v.Attempts != 0 {
+					t.Errorf("%s: statut %s, %d tentatives, attendu preempted et 0", v.ID, v.Status, v.Attempts)
+				}
+			}
+
+			// La fin de la tâche critique remet les victimes en queue, avec leurs ressources
+			fc.releaseLocked(critical)
+			for _, id := range tt.wantVictims {
+				if v := fc.tasks[id]; v.Status != "queued" || v.preempted || v.Attempts != 0 {
+					t.Errorf("%s: statut %s, préemptée = %v, %d tentatives après la tâche critique", id, v.Status, v.preempted, v.Attempts)
+				}
+			}
+			if want := 1 + len(tt.wantVictims); fc.scheduler.Len() != want {
+				t.Errorf("%d tâches en queue, attendu %d", fc.scheduler.Len(), want)
+			}
+			if fc.availableCPU != cpuBefore || fc.availableRAM != ramBefore {
+				t.Errorf("ressources %v CPU, %v RAM après la tâche critique, attendu %v, %v", fc.availableCPU, fc.availableRAM, cpuBefore, ramBefore)
+			}
+			for _, task := range running {
+				fc.releaseLocked(task)
+			}
+			if fc.availableCPU != 1 || fc.availableRAM != 1 || fc.availableStorage != 100 {
+				t.Errorf("ressources %v CPU, %v RAM, %v Mo après toutes les tâches, attendu 1, 1, 100",
+					fc.availableCPU, fc.availableRAM, fc.availableStorage)
+			}
+		})
+	}
+}
+
+// Une tâche critique terminée avant l'arrêt de sa victime: la victime revient
+// en queue dès que son worker constate l'interruption
+func TestPreemptionCriticalDoneFirst(t *testing.T) {
+	fc := newPreemptionNode(t)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	victim := startRunning(fc, "faible", 1, 0.75, 0.5, time.Second)
+	critical := &Task{ID: "critique", Type: "compute", Criticality: 5, CPUCost: 0.5, RAMCost: 0.5, StorageCost: 1}
+	fc.enqueueLocked(critical)
+	if !victim.preempted {
+		t.Fatal("tâche faible non préemptée")
+	}
+	if fc.availableCPU != 0.5 || fc.availableRAM != 0.5 {
+		t.Fatalf("ressources %v CPU, %v RAM pendant la tâche critique, attendu 0.5, 0.5", fc.availableCPU, fc.availableRAM)
+	}
+
+	fc.releaseLocked(critical)
+	if victim.Status != "processing" {
+		t.Fatalf("victime %s avant l'arrêt de son worker", victim.Status)
+	}
+	fc.preemptedLocked(victim)
+	if victim.Status != "queued" || victim.Attempts != 0 {
+		t.Errorf("victime %s, %d tentatives, attendu queued et 0", victim.Status, victim.Attempts)
+	}
+	fc.releaseLocked(victim)
+	if fc.availableCPU != 1 || fc.availableRAM != 1 {
+		t.Errorf("ressources %v CPU, %v RAM, attendu 1, 1", fc.availableCPU, fc.availableRAM)
+	}
+}
+
+// La préemption ne concerne que les tâches de criticité 5, et peut être désactivée
+func TestPreemptionDisabled(t *testing.T) {
+	for _, tt := range []struct {
+		enabled     bool
+		criticality int
+	}{{true, 4}, {false, 5}} {
+		t.Run(fmt.Sprintf("activée=%v,criticité=%d", tt.enabled, tt.criticality), func(t *testing.T) {
+			fc := newPreemptionNode(t)
+			fc.preemption.enabled = tt.enabled
+			fc.mu.Lock()
+			defer fc.mu.Unlock()
+
+			victim := startRunning(fc, "faible", 1, 1, 1, time.Second)
+			fc.enqueueLocked(&Task{ID: "urgente", Type: "compute", Criticality: tt.criticality, CPUCost: 0.5, RAMCost: 0.5, StorageCost: 1})
+			if victim.preempted {
+				t.Error("tâche faible préemptée")
+			}
+		})
+	}
+}
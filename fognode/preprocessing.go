@@ -0,0 +1,333 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// PipelineStage est une étape du pipeline de preprocessing; ses paramètres
+// dépendent de l'opération:
+//
+//	filter:       field + min/max (nombres), equals, not_equals ou exists
+//	deduplicate:  keys (champs formant l'identité d'un enregistrement; tous par défaut)
+//	normalize:    field, method (minmax ou zscore), target (défaut: field)
+//	unit_convert: field, from et to (unités connues) ou factor/offset, target
+//	downsample:   every (un enregistrement sur n), ou interval sur time_field
+//	              (défaut: timestamp) par group_by, avec method (mean, first, last) sur fields
+type PipelineStage struct {
+	Op        string          `json:"op"`
+	Field     string          `json:"field,omitempty"`
+	Target    string          `json:"target,omitempty"`
+	Min       *float64        `json:"min,omitempty"`
+	Max       *float64        `json:"max,omitempty"`
+	Equals    json.RawMessage `json:"equals,omitempty"`
+	NotEquals json.RawMessage `json:"not_equals,omitempty"`
+	Exists    *bool           `json:"exists,omitempty"`
+	Keys      []string        `json:"keys,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	From      string          `json:"from,omitempty"`
+	To        string          `json:"to,omitempty"`
+	Factor    *float64        `json:"factor,omitempty"`
+	Offset    float64         `json:"offset,omitempty"`
+	Every     int             `json:"every,omitempty"`
+	Interval  string          `json:"interval,omitempty"`
+	TimeField string          `json:"time_field,omitempty"`
+	GroupBy   string          `json:"group_by,omitempty"`
+	Fields    []string        `json:"fields,omitempty"`
+}
+
+// PreprocessingPayload est le payload d'une tâche preprocessing
+type PreprocessingPayload struct {
+	Records []map[string]interface{} `json:"records"`
+	Stages  []PipelineStage          `json:"stages"`
+}
+
+// StageReport indique le nombre d'enregistrements avant et après une étape
+type StageReport struct {
+	Op  string `json:"op"`
+	In  int    `json:"in"`
+	Out int    `json:"out"`
+}
+
+// unitConversion convertit vers une unité de référence: ref = v*factor + offset
+type unitConversion struct {
+	dimension      string
+	factor, offset float64
+}
+
+// units associe chaque unité connue à sa conversion vers l'unité de
+// référence de sa dimension (°C, Pa, m, kg, m/s)
+var units = map[string]unitConversion{
+	"c": {"température", 1, 0}, "f": {"température", 5.0 / 9, -32 * 5.0 / 9}, "k": {"température", 1, -273.15},
+	"pa": {"pression", 1, 0}, "hpa": {"pression", 100, 0}, "kpa": {"pression", 1000, 0}, "bar": {"pression", 1e5, 0}, "psi": {"pression", 6894.757, 0},
+	"mm": {"longueur", 0.001, 0}, "cm": {"longueur", 0.01, 0}, "m": {"longueur", 1, 0}, "km": {"longueur", 1000, 0}, "in": {"longueur", 0.0254, 0}, "ft": {"longueur", 0.3048, 0},
+	"g": {"masse", 0.001, 0}, "kg": {"masse", 1, 0}, "lb": {"masse", 0.45359237, 0},
+	"m/s": {"vitesse", 1, 0}, "km/h": {"vitesse", 1 / 3.6, 0}, "mph": {"vitesse", 0.44704, 0},
+}
+
+// numberField retourne la valeur numérique d'un champ d'enregistrement
+func numberField(record map[string]interface{}, field string) (float64, bool) {
+	v, ok := record[field].(float64)
+	return v, ok && !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// sameJSON compare une valeur d'enregistrement à une valeur JSON brute
+func sameJSON(v interface{}, raw json.RawMessage) bool {
+	var want interface{}
+	if json.Unmarshal(raw, &want) != nil {
+		return false
+	}
+	a, _ := json.Marshal(v)
+	b, _ := json.Marshal(want)
+	return string(a) == string(b)
+}
+
+// runStage applique une étape aux enregistrements
+func runStage(stage PipelineStage, records []map[string]interface{}) ([]map[string]interface{}, error) {
+	target := stage.Target
+	if target == "" {
+		target = stage.Field
+	}
+	switch stage.Op {
+	case "filter":
+		if stage.Field == "" {
+			return nil, fmt.Errorf("field requis")
+		}
+		out := records[:0:0]
+		for _, r := range records {
+			v, present := r[stage.Field]
+			keep := true
+			if stage.Exists != nil {
+				keep = present == *stage.Exists
+			}
+			if stage.Min != nil || stage.Max != nil {
+				n, ok := numberField(r, stage.Field)
+				keep = keep && ok && (stage.Min == nil || n >= *stage.Min) && (stage.Max == nil || n <= *stage.Max)
+			}
+			if len(stage.Equals) > 0 {
+				keep = keep && present && sameJSON(v, stage.Equals)
+			}
+			if len(stage.NotEquals) > 0 {
+				keep = keep && !sameJSON(v, stage.NotEquals)
+			}
+			if keep {
+				out = append(out, r)
+			}
+		}
+		return out, nil
+
+	case "deduplicate":
+		seen := make(map[string]bool, len(records))
+		out := records[:0:0]
+		for _, r := range records {
+			identity := interface{}(r)
+			if len(stage.Keys) > 0 {
+				key := make([]interface{}, len(stage.Keys))
+				for i, k := range stage.Keys {
+					key[i] = r[k]
+				}
+				identity = key
+			}
+			b, _ := json.Marshal(identity) // Clés d'objet triées: identité stable
+			if !seen[string(b)] {
+				seen[string(b)] = true
+				out = append(out, r)
+			}
+		}
+		return out, nil
+
+	case "normalize":
+		if stage.Field == "" {
+			return nil, fmt.Errorf("field requis")
+		}
+		var n, sum, sq float64
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, r := range records {
+			if v, ok := numberField(r, stage.Field); ok {
+				n, sum = n+1, sum+v
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+		if n == 0 {
+			return records, nil
+		}
+		mean := sum / n
+		for _, r := range records {
+			if v, ok := numberField(r, stage.Field); ok {
+				sq += (v - mean) * (v - mean)
+			}
+		}
+		std := math.Sqrt(sq / n)
+		for _, r := range records {
+			v, ok := numberField(r, stage.Field)
+			if !ok {
+				continue
+			}
+			switch stage.Method {
+			case "", "minmax":
+				r[target] = 0.0
+				if hi > lo {
+					r[target] = (v - lo) / (hi - lo)
+				}
+			case "zscore":
+				r[target] = 0.0
+				if std > 0 {
+					r[target] = (v - mean) / std
+				}
+			default:
+				return nil, fmt.Errorf("method %q inconnue: minmax ou zscore", stage.Method)
+			}
+		}
+		return records, nil
+
+	case "unit_convert":
+		if stage.Field == "" {
+			return nil, fmt.Errorf("field requis")
+		}
+		factor, offset := 1.0, stage.Offset
+		if stage.Factor != nil {
+			factor = *stage.Factor
+		} else {
+			from, okFrom := units[strings.ToLower(stage.From)]
+			to, okTo := units[strings.ToLower(stage.To)]
+			if !okFrom || !okTo {
+				return nil, fmt.Errorf("conversion %q → %q: unités inconnues (ou factor/offset)", stage.From, stage.To)
+			}
+			if from.dimension != to.dimension {
+				return nil, fmt.Errorf("conversion %q → %q: %s et %s incompatibles", stage.From, stage.To, from.dimension, to.dimension)
+			}
+			// v → référence → unité cible
+			factor, offset = from.factor/to.factor, (from.offset-to.offset)/to.factor
+		}
+		for _, r := range records {
+			if v, ok := numberField(r, stage.Field); ok {
+				r[target] = v*factor + offset
+			}
+		}
+		return records, nil
+
+	case "downsample":
+		if stage.Every > 0 {
+			out := records[:0:0]
+			for i := 0; i < len(records); i += stage.Every {
+				out = append(out, records[i])
+			}
+			return out, nil
+		}
+		return downsampleByInterval(stage, records)
+	}
+	return nil, fmt.Errorf("op %q inconnue: filter, deduplicate, normalize, unit_convert ou downsample", stage.Op)
+}
+
+// downsampleByInterval ramène les enregistrements d'une même fenêtre de temps
+// (et d'une même valeur de group_by) à un seul: le premier, le dernier, ou le
+// premier portant la moyenne des champs numériques fields
+func downsampleByInterval(stage PipelineStage, records []map[string]interface{}) ([]map[string]interface{}, error) {
+	interval, err := time.ParseDuration(stage.Interval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("every ou interval (durée positive) requis")
+	}
+	timeField := stage.TimeField
+	if timeField == "" {
+		timeField = "timestamp"
+	}
+	method := stage.Method
+	if method == "" {
+		method = "mean"
+	}
+	if method != "mean" && method != "first" && method != "last" {
+		return nil, fmt.Errorf("method %q inconnue: mean, first ou last", method)
+	}
+
+	type bucket struct {
+		record map[string]interface{}
+		sums   map[string]float64
+		counts map[string]int
+	}
+	buckets := make(map[string]*bucket)
+	order := make([]string, 0)
+	for i, r := range records {
+		raw, err := json.Marshal(r[timeField])
+		if err != nil || r[timeField] == nil {
+			return nil, fmt.Errorf("enregistrement %d: %s requis", i, timeField)
+		}
+		ts, err := parseReadingTime(raw)
+		if err != nil {
+			return nil, fmt.Errorf("enregistrement %d: %w", i, err)
+		}
+		start := ts.Truncate(interval)
+		key := fmt.Sprintf("%v|%d", r[stage.GroupBy], start.UnixNano())
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{record: r, sums: make(map[string]float64), counts: make(map[string]int)}
+			buckets[key] = b
+			order = append(order, key)
+		} else if method == "last" {
+			b.record = r
+		}
+		if method == "mean" {
+			for _, f := range stage.Fields {
+				if v, ok := numberField(r, f); ok {
+					b.sums[f] += v
+					b.counts[f]++
+				}
+			}
+		}
+	}
+
+	out := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		b := buckets[key]
+		if method == "mean" {
+			for f, n := range b.counts {
+				b.record[f] = b.sums[f] / float64(n)
+			}
+		}
+		out = append(out, b.record)
+	}
+	return out, nil
+}
+
+// preprocessData applique aux enregistrements du payload les étapes du
+// pipeline déclaré, dans l'ordre, et retourne les enregistrements obtenus.
+// Un payload sans étapes retourne les enregistrements inchangés.
+func preprocessData(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	var p PreprocessingPayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("payload preprocessing invalide: %w", err)
+	}
+	records := p.Records
+	if records == nil {
+		records = make([]map[string]interface{}, 0)
+	}
+
+	reports := make([]StageReport, 0, len(p.Stages))
+	for i, stage := range p.Stages {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		reportProgress(ctx, float64(i)/float64(len(p.Stages)), "Étape "+stage.Op)
+		in := len(records)
+		if records, err = runStage(stage, records); err != nil {
+			return nil, fmt.Errorf("étape %d (%s): %w", i, stage.Op, err)
+		}
+		reports = append(reports, StageReport{Op: stage.Op, In: in, Out: len(records)})
+	}
+
+	return map[string]interface{}{
+		"operation": "preprocessing",
+		"status":    "success",
+		"count_in":  len(p.Records),
+		"count_out": len(records),
+		"stages":    reports,
+		"records":   records,
+	}, nil
+}
@@ -0,0 +1,178 @@
+package fognode
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Classes de priorité, chacune servie par sa propre queue
+const (
+	PriorityClassCritical = "critical" // Alarmes et commandes
+	PriorityClassStandard = "standard"
+	PriorityClassBatch    = "batch" // Analytique de fond
+)
+
+// priorityClasses liste les classes de priorité dans l'ordre d'importance
+var priorityClasses = []string{PriorityClassCritical, PriorityClassStandard, PriorityClassBatch}
+
+// PriorityClassStats décrit une classe de priorité (/metrics)
+type PriorityClassStats struct {
+	Weight   int   `json:"weight"`
+	Queued   int   `json:"queued"`
+	Dequeued int64 `json:"dequeued"`
+}
+
+// priorityClass retourne la classe de priorité d'une tâche: celle demandée
+// (priority_class) si elle existe, sinon celle déduite de la criticité
+func priorityClass(task *Task) string {
+	for _, class := range priorityClasses {
+		if task.PriorityClass == class {
+			return class
+		}
+	}
+	switch {
+	case task.Criticality >= 4:
+		return PriorityClassCritical
+	case task.Criticality >= 2:
+		return PriorityClassStandard
+	default:
+		return PriorityClassBatch
+	}
+}
+
+// classScheduler donne à chaque classe de priorité sa queue, ordonnée par la
+// même politique, et les sert en tourniquet pondéré lissé: sur 10 retraits
+// avec les poids 6/3/1, 6 tâches critical, 3 standard et 1 batch, entrelacées.
+// Une classe vide cède son tour; aucune ne peut bloquer complètement les autres.
+type classScheduler struct {
+	Scheduler // Politique des queues, pour Name
+	queues    map[string]Scheduler
+	weights   map[string]int
+	credit    map[string]int // Crédit courant du tourniquet lissé
+	dequeued  map[string]int64
+}
+
+// withPriorityClasses répartit les tâches en classes de priorité quand
+// PRIORITY_CLASSES est activé; les poids viennent de PRIORITY_CLASS_WEIGHTS
+// ("critical=6,standard=3,batch=1")
+func withPriorityClasses(s Scheduler) Scheduler {
+	if !getEnvBool("PRIORITY_CLASSES", false) {
+		return s
+	}
+	cs := &classScheduler{
+		Scheduler: s,
+		queues:    make(map[string]Scheduler, len(priorityClasses)),
+		weights:   map[string]int{PriorityClassCritical: 6, PriorityClassStandard: 3, PriorityClassBatch: 1},
+		credit:    make(map[string]int, len(priorityClasses)),
+		dequeued:  make(map[string]int64, len(priorityClasses)),
+	}
+	for _, kv := range getEnvList("PRIORITY_CLASS_WEIGHTS") {
+		name, value, _ := strings.Cut(kv, "=")
+		name = strings.TrimSpace(name)
+		w, err := strconv.Atoi(strings.TrimSpace(value))
+		if _, known := cs.weights[name]; !known || err != nil || w < 1 {
+			slog.Warn("Poids PRIORITY_CLASS_WEIGHTS invalide ignoré", "value", kv)
+			continue
+		}
+		cs.weights[name] = w
+	}
+	for _, class := range priorityClasses {
+		q, err := newScheduler(s.Name())
+		if err != nil {
+			q = newSmartScoreScheduler()
+		}
+		cs.queues[class] = q
+	}
+	// La politique fournie ne sert qu'à nommer l'ensemble: une queue par classe la remplace
+	return cs
+}
+
+func (cs *classScheduler) Enqueue(task *Task) {
+	cs.queues[priorityClass(task)].Enqueue(task)
+}
+
+// pick choisit la classe servie au prochain retrait parmi les classes non
+// vides; credit est mis à jour
+func (cs *classScheduler) pick(credit map[string]int, empty func(class string) bool) string {
+	best, total := "", 0
+	for _, class := range priorityClasses {
+		if empty(class) {
+			continue
+		}
+		credit[class] += cs.weights[class]
+		total += cs.weights[class]
+		if best == "" || credit[class] > credit[best] {
+			best = class
+		}
+	}
+	if best != "" {
+		credit[best] -= total
+	}
+	return best
+}
+
+func (cs *classScheduler) Next() *Task {
+	class := cs.pick(cs.credit, func(c string) bool { return cs.queues[c].Len() == 0 })
+	if class == "" {
+		return nil
+	}
+	cs.dequeued[class]++
+	return cs.queues[class].Next()
+}
+
+// Peek rejoue le tourniquet sur une copie des crédits
+func (cs *classScheduler) Peek(n int) []*Task {
+	lists := make(map[string][]*Task, len(priorityClasses))
+	credit := make(map[string]int, len(cs.credit))
+	for _, class := range priorityClasses {
+		lists[class] = peekScheduler(cs.queues[class], n)
+		credit[class] = cs.credit[class]
+	}
+	var tasks []*Task
+	for len(tasks) < n {
+		class := cs.pick(credit, func(c string) bool { return len(lists[c]) == 0 })
+		if class == "" {
+			break
+		}
+		tasks = append(tasks, lists[class][0])
+		lists[class] = lists[class][1:]
+	}
+	return tasks
+}
+
+func (cs *classScheduler) Rescore() {
+	for _, q := range cs.queues {
+		q.Rescore()
+	}
+}
+
+func (cs *classScheduler) Len() int {
+	n := 0
+	for _, q := range cs.queues {
+		n += q.Len()
+	}
+	return n
+}
+
+// priorityClassStatsLocked retourne l'état des classes de priorité, nil si
+// elles sont désactivées; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) priorityClassStatsLocked() map[string]PriorityClassStats {
+	s := fc.scheduler
+	if lane, ok := s.(*criticalLane); ok {
+		s = lane.Scheduler
+	}
+	cs, ok := s.(*classScheduler)
+	if !ok {
+		return nil
+	}
+	stats := make(map[string]PriorityClassStats, len(priorityClasses))
+	for _, class := range priorityClasses {
+		stats[class] = PriorityClassStats{
+			Weight:   cs.weights[class],
+			Queued:   cs.queues[class].Len(),
+			Dequeued: cs.dequeued[class],
+		}
+	}
+	return stats
+}
@@ -0,0 +1,108 @@
+package fognode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newTestClassScheduler crée un classScheduler aux poids critical=5,
+// standard=3, batch=1
+func newTestClassScheduler(t *testing.T) *classScheduler {
+	t.Helper()
+	t.Setenv("PRIORITY_CLASSES", "true")
+	t.Setenv("PRIORITY_CLASS_WEIGHTS", "critical=5,standard=3,batch=1")
+	cs, ok := withPriorityClasses(newSmartScoreScheduler()).(*classScheduler)
+	if !ok {
+		t.Fatal("PRIORITY_CLASSES=true sans classScheduler")
+	}
+	return cs
+}
+
+// fillClass ajoute n tâches à une classe
+func fillClass(cs *classScheduler, class string, n int) {
+	for i := 0; i < n; i++ {
+		cs.Enqueue(&Task{ID: fmt.Sprintf("%s-%d", class, i), Type: "compute", PriorityClass: class})
+	}
+}
+
+// nextClasses retire n tâches et retourne l'initiale de leur classe
+// (c, s, b), "-" pour une queue vide
+func nextClasses(cs *classScheduler, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		task := cs.Next()
+		if task == nil {
+			b.WriteByte('-')
+			continue
+		}
+		b.WriteByte(priorityClass(task)[0])
+	}
+	return b.String()
+}
+
+func TestClassSchedulerSmoothWeights(t *testing.T) {
+	cs := newTestClassScheduler(t)
+	for _, class := range priorityClasses {
+		fillClass(cs, class, 20)
+	}
+
+	// Peek rejoue le tourniquet sans le faire avancer
+	var peeked strings.Builder
+	for _, task := range cs.Peek(9) {
+		peeked.WriteByte(priorityClass(task)[0])
+	}
+	// Deux cycles de 9 retraits: 5 critical, 3 standard, 1 batch, entrelacés
+	const cycle = "cscbcscsc"
+	if got := nextClasses(cs, 18); got != cycle+cycle {
+		t.Errorf("séquence %s, attendu %s", got, cycle+cycle)
+	}
+	if peeked.String() != cycle {
+		t.Errorf("Peek(9) = %s, attendu %s", peeked.String(), cycle)
+	}
+	for class, want := range map[string]int64{PriorityClassCritical: 10, PriorityClassStandard: 6, PriorityClassBatch: 2} {
+		if cs.dequeued[class] != want {
+			t.Errorf("%s: %d retraits, attendu %d", class, cs.dequeued[class], want)
+		}
+	}
+}
+
+func TestClassSchedulerEmptyClass(t *testing.T) {
+	tests := []struct {
+		name  string
+		empty string
+		want  string // Retraits d'un cycle tant que la classe est vide
+	}{
+		{"batch vide", PriorityClassBatch, "csccscsc"},
+		{"standard vide", PriorityClassStandard, "cccbcc"},
+		{"critical vide", PriorityClassCritical, "ssbs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestClassScheduler(t)
+			for _, class := range priorityClasses {
+				if class != tt.empty {
+					fillClass(cs, class, 20)
+				}
+			}
+			// La classe vide cède ses tours sans accumuler de crédit
+			if got := nextClasses(cs, len(tt.want)); got != tt.want {
+				t.Errorf("séquence %s, attendu %s", got, tt.want)
+			}
+			if cs.credit[tt.empty] != 0 {
+				t.Errorf("crédit de la classe vide: %d", cs.credit[tt.empty])
+			}
+			// Remplie, elle reprend sa part sans rattraper les tours cédés
+			fillClass(cs, tt.empty, 20)
+			if got := nextClasses(cs, 9); got != "cscbcscsc" {
+				t.Errorf("séquence après remplissage %s, attendu cscbcscsc", got)
+			}
+		})
+	}
+
+	cs := newTestClassScheduler(t)
+	fillClass(cs, PriorityClassBatch, 2)
+	if got := nextClasses(cs, 3); got != "bb-" {
+		t.Errorf("classe seule: %s, attendu bb-", got)
+	}
+}
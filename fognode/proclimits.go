@@ -0,0 +1,140 @@
+package fognode
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Classes d'ordonnancement E/S (ionice)
+const (
+	IOClassNone       = 0
+	IOClassRealtime   = 1
+	IOClassBestEffort = 2
+	IOClassIdle       = 3
+)
+
+// ProcessLimits décrit l'affinité CPU et la priorité appliquées aux
+// sous-processus lancés par les exécuteurs de tâches, pour qu'ils
+// n'affament pas les services co-localisés sensibles à la latence
+type ProcessLimits struct {
+	CPUs     []int `json:"cpus,omitempty"`     // Cœurs autorisés (vide = tous)
+	Nice     int   `json:"nice"`               // Valeur nice (-20..19)
+	IOClass  int   `json:"io_class,omitempty"` // Classe ionice (0 = inchangée)
+	IOLevel  int   `json:"io_level,omitempty"` // Niveau ionice dans la classe (0..7)
+	Disabled bool  `json:"-"`
+}
+
+// loadProcessLimits lit WORKER_CPUS (ex: "2,3" ou "2-3"), WORKER_NICE et
+// WORKER_IONICE (ex: "idle", "best-effort:7")
+func loadProcessLimits() ProcessLimits {
+	var pl ProcessLimits
+
+	if spec := getEnv("WORKER_CPUS", ""); spec != "" {
+		cpus, err := parseCPUList(spec)
+		if err != nil {
+			slog.Warn("WORKER_CPUS invalide: affinité ignorée", "error", err)
+		} else {
+			pl.CPUs = cpus
+		}
+	}
+
+	pl.Nice = getEnvInt("WORKER_NICE", 0)
+	if pl.Nice < -20 || pl.Nice > 19 {
+		slog.Warn("WORKER_NICE hors de [-20, 19]: valeur ignorée", "value", pl.Nice)
+		pl.Nice = 0
+	}
+
+	if spec := getEnv("WORKER_IONICE", ""); spec != "" {
+		class, level, err := parseIONice(spec)
+		if err != nil {
+			slog.Warn("WORKER_IONICE invalide: valeur ignorée", "error", err)
+		} else {
+			pl.IOClass, pl.IOLevel = class, level
+		}
+	}
+	return pl
+}
+
+// parseCPUList lit une liste de cœurs au format cpuset ("0,2,4-7")
+func parseCPUList(spec string) ([]int, error) {
+	cpus := make([]int, 0)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		b, err := strconv.Atoi(hi)
+		if err != nil {
+			return nil, err
+		}
+		if a < 0 || b < a {
+			return nil, fmt.Errorf("intervalle invalide %q", part)
+		}
+		for c := a; c <= b; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
+
+// parseIONice lit une classe ionice ("realtime", "best-effort", "idle") et un niveau optionnel
+func parseIONice(spec string) (int, int, error) {
+	name, levelStr := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, levelStr = spec[:i], spec[i+1:]
+	}
+
+	var class int
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "realtime", "rt", "1":
+		class = IOClassRealtime
+	case "best-effort", "be", "2":
+		class = IOClassBestEffort
+	case "idle", "3":
+		class = IOClassIdle
+	default:
+		return 0, 0, fmt.Errorf("classe inconnue %q", name)
+	}
+
+	level := 4
+	if levelStr != "" {
+		n, err := strconv.Atoi(levelStr)
+		if err != nil || n < 0 || n > 7 {
+			return 0, 0, fmt.Errorf("niveau invalide %q", levelStr)
+		}
+		level = n
+	}
+	return class, level, nil
+}
+
+// empty indique qu'aucune contrainte n'est configurée
+func (pl ProcessLimits) empty() bool {
+	return len(pl.CPUs) == 0 && pl.Nice == 0 && pl.IOClass == IOClassNone
+}
+
+// Start démarre la commande puis lui applique l'affinité et les priorités
+// configurées. Une erreur d'application n'empêche pas l'exécution: elle est
+// journalisée, le sous-processus tourne alors sans contrainte.
+func (pl ProcessLimits) Start(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if pl.Disabled || pl.empty() {
+		return nil
+	}
+	if err := applyProcessLimits(cmd.Process.Pid, pl); err != nil {
+		slog.Warn("Application des limites au processus impossible", "pid", cmd.Process.Pid, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+//go:build linux
+
+package fognode
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const ioprioWhoProcess = 1 // IOPRIO_WHO_PROCESS
+
+// applyProcessLimits applique affinité CPU, nice et ionice au processus pid
+func applyProcessLimits(pid int, pl ProcessLimits) error {
+	if len(pl.CPUs) > 0 {
+		var mask [16]uint64 // Jusqu'à 1024 cœurs
+		for _, cpu := range pl.CPUs {
+			if cpu >= len(mask)*64 {
+				return fmt.Errorf("cœur %d hors du masque supporté", cpu)
+			}
+			mask[cpu/64] |= 1 << (uint(cpu) % 64)
+		}
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY,
+			uintptr(pid), uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+		if errno != 0 {
+			return fmt.Errorf("sched_setaffinity: %w", errno)
+		}
+	}
+
+	if pl.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, pl.Nice); err != nil {
+			return fmt.Errorf("setpriority: %w", err)
+		}
+	}
+
+	if pl.IOClass != IOClassNone {
+		prio := pl.IOClass<<13 | pl.IOLevel
+		_, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio))
+		if errno != 0 {
+			return fmt.Errorf("ioprio_set: %w", errno)
+		}
+	}
+	return nil
+}
+
+// rlimit64 est la structure du syscall prlimit64
+type rlimit64 struct {
+	cur, max uint64
+}
+
+// applyResourceLimits borne la mémoire virtuelle (RLIMIT_AS) et la taille des
+// fichiers écrits (RLIMIT_FSIZE) du processus pid; 0 laisse une limite inchangée
+func applyResourceLimits(pid int, memoryBytes, fileSizeBytes uint64) error {
+	for _, l := range []struct {
+		resource int
+		value    uint64
+		name     string
+	}{
+		{syscall.RLIMIT_AS, memoryBytes, "RLIMIT_AS"},
+		{syscall.RLIMIT_FSIZE, fileSizeBytes, "RLIMIT_FSIZE"},
+	} {
+		if l.value == 0 {
+			continue
+		}
+		limit := rlimit64{cur: l.value, max: l.value}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+			uintptr(pid), uintptr(l.resource), uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("prlimit %s: %w", l.name, errno)
+		}
+	}
+	return nil
+}
+
+// setProcessGroup place le sous-processus dans son propre groupe, pour que
+// killProcessGroup atteigne aussi les processus qu'il lance
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcessGroup tue le groupe de processus d'un sous-processus démarré
+// par setProcessGroup
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
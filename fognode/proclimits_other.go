@@ -0,0 +1,26 @@
+//go:build !linux
+
+package fognode
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// applyProcessLimits n'est supporté que sous Linux
+func applyProcessLimits(pid int, pl ProcessLimits) error {
+	return errors.New("affinité CPU et ionice non supportés sur cette plateforme")
+}
+
+// applyResourceLimits n'est supporté que sous Linux
+func applyResourceLimits(pid int, memoryBytes, fileSizeBytes uint64) error {
+	return errors.New("limites de ressources des sous-processus non supportées sur cette plateforme")
+}
+
+// setProcessGroup est sans effet hors de Linux
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup tue le seul sous-processus hors de Linux
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
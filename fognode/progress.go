@@ -0,0 +1,113 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxProgressMessageLength borne le message d'avancement (caractères)
+const maxProgressMessageLength = 256
+
+// TaskProgress est l'avancement déclaré de l'exécution en cours d'une tâche
+type TaskProgress struct {
+	Fraction  float64   `json:"fraction"` // 0.0-1.0
+	Message   string    `json:"message,omitempty"`
+	Attempt   int       `json:"attempt"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// progressContextKey porte la fonction de rapport d'avancement dans le
+// contexte d'exécution d'un handler
+type progressContextKey struct{}
+
+// withProgress rattache au contexte d'exécution le rapport d'avancement de la
+// tentative courante de la tâche
+func (fc *FogCompute) withProgress(ctx context.Context, task *Task, attempt int) context.Context {
+	report := func(fraction float64, message string) {
+		fc.setProgress(task, attempt, fraction, message) // Un rapport tardif, après délai dépassé, est ignoré
+	}
+	return context.WithValue(ctx, progressContextKey{}, report)
+}
+
+// reportProgress permet à un handler de déclarer son avancement (fraction de
+// 0 à 1) et un message d'étape; sans effet hors d'une exécution
+func reportProgress(ctx context.Context, fraction float64, message string) {
+	if report, ok := ctx.Value(progressContextKey{}).(func(float64, string)); ok {
+		report(fraction, message)
+	}
+}
+
+// setProgress enregistre l'avancement de l'exécution en cours et le diffuse
+// sur /events. Un rapport d'une tentative passée (attempt différent, 0
+// accepte la courante) ou hors exécution est refusé avec 409. L'avancement
+// n'est pas persisté à chaque rapport: il l'est avec la fin de la tentative.
+func (fc *FogCompute) setProgress(task *Task, attempt int, fraction float64, message string) (TaskProgress, int, string) {
+	if fraction < 0 || fraction > 1 {
+		return TaskProgress{}, http.StatusBadRequest, "progress doit être compris entre 0 et 1"
+	}
+	message = strings.TrimSpace(message)
+	if runes := []rune(message); len(runes) > maxProgressMessageLength {
+		message = string(runes[:maxProgressMessageLength])
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if task.Status != "processing" {
+		return TaskProgress{}, http.StatusConflict, fmt.Sprintf("Tâche non en cours d'exécution (statut %s)", task.Status)
+	}
+	if attempt != 0 && attempt != task.Attempts {
+		return TaskProgress{}, http.StatusConflict, fmt.Sprintf("Tentative %d terminée: la tentative courante est %d", attempt, task.Attempts)
+	}
+	progress := TaskProgress{Fraction: fraction, Message: message, Attempt: task.Attempts, UpdatedAt: time.Now()}
+	task.Progress = &progress
+
+	ev := taskEvent(EventProgress, task)
+	ev.At = progress.UpdatedAt
+	snapshot := progress
+	ev.Progress = &snapshot
+	fc.events.Publish(ev)
+	return progress, http.StatusOK, ""
+}
+
+// handleTaskProgress reçoit l'avancement d'une tâche exécutée hors du nœud
+// (exécuteur distant): {"progress": 0.4, "message": "...", "attempt": 2}
+func (fc *FogCompute) handleTaskProgress(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Progress *float64 `json:"progress"`
+		Message  string   `json:"message"`
+		Attempt  int      `json:"attempt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Progress == nil {
+		http.Error(w, "progress requis", http.StatusBadRequest)
+		return
+	}
+
+	taskID := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+	fc.mu.RLock()
+	task, ok := fc.tasks[taskID]
+	fc.mu.RUnlock()
+	if !ok || !visibleTo(task, tenant, all) {
+		http.Error(w, "Tâche non trouvée", http.StatusNotFound)
+		return
+	}
+
+	progress, status, invalid := fc.setProgress(task, body.Attempt, *body.Progress, body.Message)
+	if invalid != "" {
+		http.Error(w, invalid, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
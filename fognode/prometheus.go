@@ -0,0 +1,370 @@
+package fognode
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets sont les bornes (en secondes) de l'histogramme de durée d'exécution
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// histogram cumule des observations par tranche, au format Prometheus
+type histogram struct {
+	counts []uint64 // Observations inférieures ou égales à chaque borne (cumulatif au rendu)
+	sum    float64
+	count  uint64
+}
+
+// LatencyHistograms regroupe les histogrammes de durée d'exécution par type de tâche
+type LatencyHistograms struct {
+	byType map[string]*histogram
+	mu     sync.Mutex
+}
+
+// NewLatencyHistograms crée des histogrammes vides
+func NewLatencyHistograms() *LatencyHistograms {
+	return &LatencyHistograms{byType: make(map[string]*histogram)}
+}
+
+// Observe enregistre la durée d'exécution d'une tâche
+func (lh *LatencyHistograms) Observe(taskType string, d time.Duration) {
+	lh.mu.Lock()
+	defer lh.mu.Unlock()
+
+	h, ok := lh.byType[taskType]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		lh.byType[taskType] = h
+	}
+	seconds := d.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// promWriter produit l'exposition au format texte de Prometheus
+type promWriter struct {
+	buf bytes.Buffer
+}
+
+// header écrit les lignes HELP et TYPE d'une métrique
+func (pw *promWriter) header(name, help, kind string) {
+	fmt.Fprintf(&pw.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample écrit un échantillon; labels alterne noms et valeurs
+func (pw *promWriter) sample(name string, value float64, labels ...string) {
+	pw.buf.WriteString(name)
+	if len(labels) > 0 {
+		pw.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				pw.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&pw.buf, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		pw.buf.WriteByte('}')
+	}
+	fmt.Fprintf(&pw.buf, " %g\n", value)
+}
+
+// metric écrit une métrique à un seul échantillon
+func (pw *promWriter) metric(name, help, kind string, value float64) {
+	pw.header(name, help, kind)
+	pw.sample(name, value)
+}
+
+// labelEscaper applique les seuls échappements reconnus dans les valeurs de labels
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// write écrit les histogrammes de durée d'exécution
+func (lh *LatencyHistograms) write(pw *promWriter) {
+	lh.mu.Lock()
+	defer lh.mu.Unlock()
+
+	const name = "fog_task_duration_seconds"
+	pw.header(name, "Durée d'exécution des tâches par type.", "histogram")
+	types := make([]string, 0, len(lh.byType))
+	for t := range lh.byType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		h := lh.byType[t]
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			pw.sample(name+"_bucket", float64(cumulative), "type", t, "le", fmt.Sprintf("%g", bound))
+		}
+		pw.sample(name+"_bucket", float64(h.count), "type", t, "le", "+Inf")
+		pw.sample(name+"_sum", h.sum, "type", t)
+		pw.sample(name+"_count", float64(h.count), "type", t)
+	}
+}
+
+// boolGauge convertit un booléen en valeur de gauge
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handlePrometheusMetrics expose les métriques du nœud au format texte de Prometheus
+func (fc *FogCompute) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	fc.metrics.mu.RLock()
+	m := map[string]int{
+		"processed":       fc.metrics.TasksProcessed,
+		"rejected":        fc.metrics.TasksRejected,
+		"failed":          fc.metrics.TasksFailed,
+		"timed_out":       fc.metrics.TasksTimedOut,
+		"retried":         fc.metrics.TasksRetried,
+		"offloaded":       fc.metrics.TasksOffloaded,
+		"redirected":      fc.metrics.TasksRedirected,
+		"deferred":        fc.metrics.TasksDeferred,
+		"deadlines_met":   fc.metrics.DeadlinesMet,
+		"deadlines_miss":  fc.metrics.DeadlinesMissed,
+		"expired_dropped": fc.metrics.ExpiredDropped,
+	}
+	fc.metrics.mu.RUnlock()
+
+	fc.mu.RLock()
+	load := fc.node.Load
+	queueDepth := fc.scheduler.Len()
+	inFlight := fc.inFlight
+	rejectedQueue := len(fc.rejectedTasks)
+	retention := fc.retention.Stats(fc.retainedLocked())
+	rejectedEviction := fc.rejectedEvictionStats()
+	classes := fc.queueClassStats()
+	reserved := fc.reservedWorkerStats()
+	availableCPU := fc.availableCPU
+	availableRAM := fc.availableRAM
+	availableStorage := fc.availableStorage
+	energyLevel := fc.energyLevel
+	power := fc.powerStatusLocked(time.Now())
+	queueWait := fc.queueWaitStatsLocked(time.Now())
+	preempted := fc.preemption.preempted
+	overflow := fc.backpressureStatsLocked(time.Now())
+	rejectedRetry := fc.rejectedRetryStatsLocked()
+	draining := fc.draining
+	paused := fc.maintenance.paused
+	fc.mu.RUnlock()
+
+	pw := &promWriter{}
+
+	pw.header("fog_info", "Identité du nœud.", "gauge")
+	pw.sample("fog_info", 1, "node_id", fc.node.ID, "location", fc.node.Location, "version", Version, "scheduler", fc.scheduler.Name())
+
+	pw.metric("fog_process_start_time_seconds", "Démarrage du processus (remise à zéro des compteurs).", "gauge", float64(fc.startedAt.Unix()))
+	pw.metric("fog_tasks_processed_total", "Tâches exécutées avec succès.", "counter", float64(m["processed"]))
+	pw.metric("fog_tasks_rejected_total", "Tâches rejetées à l'admission.", "counter", float64(m["rejected"]))
+	pw.metric("fog_tasks_failed_total", "Tâches en échec définitif.", "counter", float64(m["failed"]))
+	pw.metric("fog_tasks_timed_out_total", "Exécutions interrompues par leur timeout.", "counter", float64(m["timed_out"]))
+	pw.metric("fog_tasks_retried_total", "Réessais automatiques planifiés.", "counter", float64(m["retried"]))
+	pw.metric("fog_tasks_offloaded_total", "Tâches déléguées à un pair au lieu d'être rejetées.", "counter", float64(m["offloaded"]))
+	pw.metric("fog_tasks_redirected_total", "Soumissions redirigées (307) vers un pair.", "counter", float64(m["redirected"]))
+	pw.metric("fog_tasks_deferred_total", "Tâches reportées après une fenêtre de capacité planifiée.", "counter", float64(m["deferred"]))
+
+	cloud := fc.cloud.Stats()
+	pw.metric("fog_cloud_offloaded_total", "Tâches escaladées au cloud faute de nœud fog disponible.", "counter", float64(cloud.Offloaded))
+	pw.metric("fog_cloud_offload_failures_total", "Escalades cloud en échec.", "counter", float64(cloud.Failed))
+	pw.metric("fog_cloud_offload_added_latency_seconds_total", "Latence cumulée ajoutée par les escalades cloud.", "counter", cloud.AddedLatencySum)
+	pw.metric("fog_deadlines_met_total", "Tâches terminées avant leur échéance.", "counter", float64(m["deadlines_met"]))
+	pw.metric("fog_deadlines_missed_total", "Tâches terminées après leur échéance.", "counter", float64(m["deadlines_miss"]))
+	pw.metric("fog_tasks_expired_dropped_total", "Tâches abandonnées car échues avant exécution.", "counter", float64(m["expired_dropped"]))
+
+	pw.header("fog_admission_rejections_total", "Rejets par politique d'admission.", "counter")
+	rejections := fc.admission.Rejections()
+	for _, name := range fc.admission.Names() {
+		pw.sample("fog_admission_rejections_total", float64(rejections[name]), "policy", name)
+	}
+
+	pw.metric("fog_node_load", "Charge courante du nœud (0-1).", "gauge", load)
+	pw.metric("fog_queue_depth", "Tâches en attente d'exécution.", "gauge", float64(queueDepth))
+	pw.metric("fog_tasks_in_flight", "Tâches en cours d'exécution.", "gauge", float64(inFlight))
+	pw.metric("fog_rejected_queue_size", "Tâches rejetées conservées pour réessai.", "gauge", float64(rejectedQueue))
+	pw.metric("fog_tasks_retained", "Tâches terminées conservées en mémoire.", "gauge", float64(retention.Retained))
+	pw.metric("fog_tasks_collected_total", "Tâches terminées oubliées par la rétention.", "counter", float64(retention.Collected))
+	pw.header("fog_rejected_evictions_total", "Tâches rejetées évincées de la queue pleine, par politique.", "counter")
+	for _, policy := range sortedRoutes(rejectedEviction.Evictions) {
+		pw.sample("fog_rejected_evictions_total", float64(rejectedEviction.Evictions[policy]), "policy", policy)
+	}
+	pw.metric("fog_rejected_spilled_total", "Tâches rejetées évincées déportées sur disque.", "counter", float64(rejectedEviction.Spilled))
+	pw.metric("fog_available_cpu", "CPU disponible (fraction du nœud).", "gauge", availableCPU)
+	pw.metric("fog_available_ram", "RAM disponible (fraction du nœud).", "gauge", availableRAM)
+	pw.metric("fog_available_storage_mb", "Stockage disponible (MB).", "gauge", availableStorage)
+	pw.metric("fog_energy_level", "Niveau d'énergie (0-1).", "gauge", energyLevel)
+	pw.metric("fog_battery_remaining_wh", "Énergie restante en batterie (Wh).", "gauge", power.RemainingWh)
+	pw.metric("fog_power_charge_watts", "Puissance apportée par la source.", "gauge", power.ChargeW)
+	pw.metric("fog_power_discharge_watts", "Puissance consommée (repos et workers occupés).", "gauge", power.DischargeW)
+	pw.metric("fog_battery_consumed_wh_total", "Énergie prise à la batterie.", "counter", power.ConsumedWh)
+	pw.metric("fog_battery_recharged_wh_total", "Énergie apportée à la batterie par la source.", "counter", power.RechargedWh)
+	pw.metric("fog_draining", "Nœud en cours de drainage.", "gauge", boolGauge(draining))
+	pw.metric("fog_paused", "Distribution des tâches aux workers suspendue.", "gauge", boolGauge(paused))
+
+	if host := fc.host.Sample(); host != nil {
+		pw.metric("fog_host_cpu_utilization", "Utilisation CPU mesurée de l'hôte ou du conteneur (0-1).", "gauge", host.CPU)
+		pw.metric("fog_host_memory_used_ratio", "Part de la mémoire de l'hôte utilisée (0-1).", "gauge", host.Memory)
+		pw.metric("fog_host_memory_available_bytes", "Mémoire disponible de l'hôte.", "gauge", host.MemAvailMB*(1<<20))
+		pw.metric("fog_host_disk_used_ratio", "Part du disque de DATA_DIR utilisée (0-1).", "gauge", host.Disk)
+		pw.metric("fog_host_disk_free_bytes", "Espace libre du disque de DATA_DIR.", "gauge", host.DiskFreeMB*(1<<20))
+		pw.metric("fog_host_network_receive_bytes_per_second", "Débit réseau reçu, interfaces hors loopback.", "gauge", host.NetRxBps)
+		pw.metric("fog_host_network_transmit_bytes_per_second", "Débit réseau émis, interfaces hors loopback.", "gauge", host.NetTxBps)
+	}
+	if carbon := fc.carbon.Stats(); carbon.Enabled {
+		if carbon.Intensity != nil {
+			pw.metric("fog_carbon_intensity", "Intensité carbone du réseau électrique (gCO2eq/kWh).", "gauge", *carbon.Intensity)
+		}
+		pw.metric("fog_carbon_shifted_tasks_total", "Tâches décalées vers un créneau bas carbone.", "counter", float64(carbon.Shifted))
+		pw.metric("fog_carbon_emitted_grams_total", "Émissions imputées aux exécutions (gCO2eq).", "counter", carbon.EmittedGrams)
+		pw.metric("fog_carbon_saved_grams_total", "Émissions évitées par les décalages (gCO2eq).", "gauge", carbon.SavedGrams)
+	}
+
+	pw.metric("fog_queue_oldest_wait_seconds", "Attente de la plus ancienne tâche en queue.", "gauge", float64(queueWait.OldestMs)/1000)
+	pw.metric("fog_tasks_preempted_total", "Exécutions interrompues au profit d'une tâche de criticité 5.", "counter", float64(preempted))
+	pw.metric("fog_overflow_queue_size", "Soumissions en débordement en attente d'admission.", "gauge", float64(overflow.Size))
+	pw.metric("fog_overflow_refused_total", "Soumissions refusées avec 429, file de débordement pleine.", "counter", float64(overflow.Refused))
+	pw.metric("fog_rejected_resubmitted_total", "Tâches rejetées resoumises automatiquement.", "counter", float64(rejectedRetry.Resubmitted))
+	pw.metric("fog_queue_max_wait_seconds", "Plus longue attente en queue observée depuis le démarrage.", "gauge", float64(queueWait.MaxMs)/1000)
+
+	pw.header("fog_queue_class_depth", "Tâches en attente par classe d'admission.", "gauge")
+	for _, class := range queueClasses {
+		pw.sample("fog_queue_class_depth", float64(classes[class].Queued), "class", class)
+	}
+	pw.header("fog_queue_class_limit", "Limite de la queue par classe d'admission.", "gauge")
+	for _, class := range queueClasses {
+		pw.sample("fog_queue_class_limit", float64(classes[class].Limit), "class", class)
+	}
+
+	pw.metric("fog_reserved_workers", "Workers réservés aux tâches critiques.", "gauge", float64(reserved.Reserved))
+	pw.metric("fog_reserved_workers_busy", "Workers réservés en cours d'exécution.", "gauge", float64(reserved.Busy))
+	pw.metric("fog_reserved_workers_utilization", "Part des workers réservés occupés (0-1).", "gauge", reserved.Utilization)
+	pw.metric("fog_reserved_tasks_served_total", "Tâches exécutées par les workers réservés.", "counter", float64(reserved.Served))
+
+	registry := fc.devices.Summary(time.Now().Add(-deviceIdleTTL))
+	pw.metric("fog_devices_registered", "Appareils du registre d'appareils.", "gauge", float64(registry["registered"]))
+	pw.metric("fog_devices_active", "Appareils enregistrés ayant soumis une tâche ces 10 dernières minutes.", "gauge", float64(registry["active"]))
+
+	cache := dataCache.Stats()
+	pw.metric("fog_cache_entries", "Entrées du cache des tâches caching.", "gauge", float64(cache.Entries))
+	pw.metric("fog_cache_bytes", "Taille des entrées du cache.", "gauge", float64(cache.Bytes))
+	pw.metric("fog_cache_hits_total", "Lectures du cache trouvant une entrée.", "counter", float64(cache.Hits))
+	pw.metric("fog_cache_misses_total", "Lectures du cache sans entrée (absente ou expirée).", "counter", float64(cache.Misses))
+	pw.metric("fog_cache_evictions_total", "Entrées du cache évincées faute de place.", "counter", float64(cache.Evictions))
+	pw.metric("fog_cache_expirations_total", "Entrées du cache supprimées à leur TTL.", "counter", float64(cache.Expirations))
+
+	telemetryStats := telemetry.Stats()
+	pw.metric("fog_telemetry_sensors", "Capteurs du store de télémétrie.", "gauge", float64(telemetryStats.Sensors))
+	pw.metric("fog_telemetry_raw_points", "Lectures brutes conservées par le store de télémétrie.", "gauge", float64(telemetryStats.RawPoints))
+	pw.metric("fog_telemetry_rollups", "Fenêtres résumées conservées par le store de télémétrie.", "gauge", float64(telemetryStats.Rollups))
+	pw.metric("fog_telemetry_ingested_total", "Lectures reçues par POST /telemetry.", "counter", float64(telemetryStats.Ingested))
+	pw.metric("fog_telemetry_downsampled_total", "Lectures brutes résumées dans une fenêtre.", "counter", float64(telemetryStats.Downsampled))
+
+	uplink := fc.uplink.Status()
+	pw.metric("fog_uplink_online", "Lien montant vers le cloud joignable.", "gauge", boolGauge(uplink.Online))
+	pw.metric("fog_uplink_buffered_records", "Enregistrements en attente d'envoi au cloud.", "gauge", float64(uplink.BufferedRecords))
+	pw.metric("fog_uplink_dropped_total", "Enregistrements abandonnés faute de place.", "counter", float64(uplink.Dropped))
+	pw.metric("fog_uplink_sent_total", "Enregistrements transmis au cloud.", "counter", float64(uplink.Sent))
+	pw.metric("fog_uplink_rejected_total", "Enregistrements refusés par le cloud et abandonnés.", "counter", float64(uplink.Rejected))
+	pw.metric("fog_uplink_sync_lag_seconds", "Âge du plus ancien enregistrement non transmis au cloud.", "gauge", uplink.SyncLagSeconds)
+	pw.metric("fog_uplink_outages_total", "Pertes du lien montant vers le cloud.", "counter", float64(uplink.Outages))
+
+	mqtt := fc.mqtt.Stats()
+	pw.metric("fog_mqtt_connected", "Connexion au broker MQTT établie.", "gauge", boolGauge(mqtt.Connected))
+	pw.metric("fog_mqtt_messages_received_total", "Messages de tâches reçus par MQTT.", "counter", float64(mqtt.Received))
+	pw.metric("fog_mqtt_published_total", "Événements de tâches publiés par MQTT.", "counter", float64(mqtt.Published))
+	pw.metric("fog_mqtt_publish_failures_total", "Événements MQTT perdus faute de connexion au broker.", "counter", float64(mqtt.PublishFailed))
+
+	journal := fc.journal.Stats()
+	pw.metric("fog_journal_records_total", "Soumissions admises enregistrées dans le journal de rejeu.", "counter", float64(journal.Recorded))
+	pw.metric("fog_journal_errors_total", "Échecs d'écriture du journal de rejeu.", "counter", float64(journal.Errors))
+
+	events := fc.events.Stats()
+	pw.metric("fog_event_subscribers", "Abonnés au flux SSE /events.", "gauge", float64(events.Subscribers))
+	pw.metric("fog_events_published_total", "Événements du cycle de vie des tâches diffusés.", "counter", float64(events.Published))
+	pw.metric("fog_events_dropped_total", "Événements non remis à un abonné SSE trop lent.", "counter", float64(events.Dropped))
+
+	callbacks := fc.callbacks.Stats()
+	pw.metric("fog_callbacks_pending", "Notifications de callback en attente de livraison.", "gauge", float64(callbacks.Pending))
+	pw.metric("fog_callbacks_delivered_total", "Notifications de callback acceptées.", "counter", float64(callbacks.Delivered))
+	pw.metric("fog_callbacks_retries_total", "Réessais de notifications de callback.", "counter", float64(callbacks.Retries))
+	pw.metric("fog_callbacks_failed_total", "Notifications de callback abandonnées.", "counter", float64(callbacks.Failed))
+	pw.metric("fog_callbacks_dropped_total", "Notifications de callback perdues faute de place dans la file.", "counter", float64(callbacks.Dropped))
+
+	pw.header("fog_peers", "Pairs connus par état.", "gauge")
+	peers := fc.peers.Summary()
+	for _, status := range []string{PeerAlive, PeerSuspect, PeerDead} {
+		pw.sample("fog_peers", float64(peers[status]), "status", status)
+	}
+
+	pw.header("fog_registered_nodes", "Nœuds enregistrés auprès de ce nœud par état.", "gauge")
+	nodes := fc.nodes.Summary()
+	for _, status := range []string{NodeAlive, NodeStale} {
+		pw.sample("fog_registered_nodes", float64(nodes[status]), "status", status)
+	}
+
+	qos := fc.qos.Stats()
+	pw.header("fog_http_requests_in_flight", "Requêtes HTTP en cours par classe de trafic.", "gauge")
+	for _, class := range trafficClasses {
+		pw.sample("fog_http_requests_in_flight", float64(qos[class].InFlight), "class", class)
+	}
+	pw.header("fog_http_requests_shed_total", "Requêtes HTTP refusées (503) par classe de trafic saturée.", "counter")
+	for _, class := range trafficClasses {
+		pw.sample("fog_http_requests_shed_total", float64(qos[class].Shed), "class", class)
+	}
+
+	pw.header("fog_http_handler_timeouts_total", "Requêtes arrivées au délai de traitement de leur route.", "counter")
+	timeouts := fc.timeouts.Stats()
+	for _, route := range sortedRoutes(timeouts) {
+		pw.sample("fog_http_handler_timeouts_total", float64(timeouts[route]), "route", route)
+	}
+
+	legacy := fc.legacy.Stats()
+	pw.metric("fog_legacy_api_requests_total", "Requêtes reçues sur les routes sans préfixe, dépréciées au profit de "+APIPrefix+".", "counter", float64(legacy.Requests))
+	if codecs := fc.negotiation.Stats(); len(codecs) > 0 {
+		names := make([]string, 0, len(codecs))
+		for name := range codecs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pw.header("fog_codec_requests_total", "Corps soumis en encodage binaire (CBOR, MessagePack), par encodage.", "counter")
+		for _, name := range names {
+			pw.sample("fog_codec_requests_total", float64(codecs[name].Requests), "codec", name)
+		}
+		pw.header("fog_codec_responses_total", "Réponses encodées en binaire selon Accept, par encodage.", "counter")
+		for _, name := range names {
+			pw.sample("fog_codec_responses_total", float64(codecs[name].Responses), "codec", name)
+		}
+		pw.header("fog_codec_saved_bytes_total", "Octets économisés par l'encodage binaire des réponses, par rapport à JSON.", "counter")
+		for _, name := range names {
+			pw.sample("fog_codec_saved_bytes_total", float64(codecs[name].JSONBytes-codecs[name].EncodedBytes), "codec", name)
+		}
+	}
+
+	auth := fc.auth.Stats()
+	pw.header("fog_auth_unauthorized_total", "Requêtes refusées faute de clé d'API valide (401).", "counter")
+	pw.sample("fog_auth_unauthorized_total", float64(auth.Unauthorized))
+	pw.header("fog_auth_forbidden_total", "Requêtes refusées pour portée de clé d'API insuffisante (403).", "counter")
+	pw.sample("fog_auth_forbidden_total", float64(auth.Forbidden))
+	if auth.JWT != nil {
+		pw.header("fog_auth_jwt_rejected_total", "Jetons OIDC refusés, par motif.", "counter")
+		for _, reason := range sortedRoutes(auth.JWT.Rejected) {
+			pw.sample("fog_auth_jwt_rejected_total", float64(auth.JWT.Rejected[reason]), "reason", reason)
+		}
+	}
+
+	fc.latency.write(pw)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(pw.buf.Bytes())
+}
@@ -0,0 +1,75 @@
+package fognode
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Sources possibles d'une tâche
+const (
+	SourceHTTP       = "http"
+	SourceUnix       = "unix"
+	SourcePeer       = "peer"
+	SourceRetry      = "retry"
+	SourceRedispatch = "redispatch"
+	SourceMQTT       = "mqtt"
+	SourceSchedule   = "schedule"
+)
+
+// ForwardedByHeader est positionné par un nœud fog qui transmet une tâche à un pair
+const ForwardedByHeader = "X-Fog-Forwarded-By"
+
+// maxProvenanceHops borne la chaîne de provenance (protection contre les boucles)
+const maxProvenanceHops = 32
+
+// ProvenanceHop est une étape du parcours d'une tâche à travers les nœuds
+type ProvenanceHop struct {
+	NodeID  string    `json:"node_id"`
+	Source  string    `json:"source"`
+	Client  string    `json:"client,omitempty"`   // Adresse ou identité de l'émetteur
+	Agent   string    `json:"agent,omitempty"`    // User-Agent HTTP
+	Topic   string    `json:"topic,omitempty"`    // Topic d'ingestion (MQTT)
+	RetryOf string    `json:"retry_of,omitempty"` // Tâche dont celle-ci est un réessai
+	At      time.Time `json:"at"`
+}
+
+// addHop ajoute une étape à la chaîne de provenance de la tâche
+func (t *Task) addHop(hop ProvenanceHop) {
+	if hop.At.IsZero() {
+		hop.At = time.Now()
+	}
+	if len(t.Provenance) >= maxProvenanceHops {
+		t.Provenance = t.Provenance[len(t.Provenance)-maxProvenanceHops+1:]
+	}
+	t.Provenance = append(t.Provenance, hop)
+}
+
+// visited indique si la tâche est déjà passée par le nœud donné
+func (t *Task) visited(nodeID string) bool {
+	for _, hop := range t.Provenance {
+		if hop.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// httpHop décrit la réception d'une tâche par une requête HTTP
+func httpHop(nodeID string, r *http.Request) ProvenanceHop {
+	hop := ProvenanceHop{
+		NodeID: nodeID,
+		Source: SourceHTTP,
+		Client: r.RemoteAddr,
+		Agent:  r.UserAgent(),
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "unix" {
+		hop.Source = SourceUnix
+		hop.Client = addr.String()
+	}
+	if peer := r.Header.Get(ForwardedByHeader); peer != "" {
+		hop.Source = SourcePeer
+		hop.Client = peer
+	}
+	return hop
+}
@@ -0,0 +1,205 @@
+package fognode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Classes de trafic HTTP, par ordre d'importance sous surcharge
+const (
+	TrafficMonitoring = "monitoring" // Sondes de santé et métriques
+	TrafficCritical   = "critical"   // Soumission de tâches de criticité ≥ 4
+	TrafficSubmit     = "submit"     // Autres soumissions de tâches
+	TrafficDefault    = "default"    // Consultations ponctuelles et administration
+	TrafficBulk       = "bulk"       // Listings volumineux
+)
+
+// trafficClasses liste les classes de trafic dans l'ordre d'importance
+var trafficClasses = []string{TrafficMonitoring, TrafficCritical, TrafficSubmit, TrafficDefault, TrafficBulk}
+
+// defaultTrafficLimits sont les requêtes simultanées admises par classe (0: illimité)
+var defaultTrafficLimits = map[string]int{
+	TrafficMonitoring: 0,
+	TrafficCritical:   0,
+	TrafficSubmit:     64,
+	TrafficDefault:    32,
+	TrafficBulk:       4,
+}
+
+// monitoringRoutes et bulkRoutes classent les routes GET par modèle de chemin
+var (
+	monitoringRoutes = map[string]bool{"/health": true, "/healthz": true, "/readyz": true, "/metrics": true, "/metrics/prometheus": true, "/metrics/delta": true, "/events": true}
+	bulkRoutes       = map[string]bool{"/rejected-tasks": true, "/failed-tasks": true, "/nodes": true, "/peers": true, "/costs": true, "/calibration": true, "/uplink": true}
+)
+
+// qosPeekLimit borne la lecture anticipée d'une soumission pour en lire la criticité
+const qosPeekLimit = 1 << 20
+
+// TrafficClassStats décrit l'occupation d'une classe de trafic
+type TrafficClassStats struct {
+	InFlight int   `json:"in_flight"`
+	Limit    int   `json:"limit"` // 0: illimité
+	Served   int64 `json:"served"`
+	Shed     int64 `json:"shed"` // Requêtes refusées (503) faute de place
+}
+
+// trafficLane borne la concurrence d'une classe de trafic
+type trafficLane struct {
+	slots    chan struct{} // nil: illimité
+	inFlight int
+	served   int64
+	shed     int64
+}
+
+// HTTPQoS priorise les requêtes au niveau du middleware: chaque classe de
+// trafic a sa propre limite de concurrence, de sorte que les listings
+// volumineux saturent leur voie sans affamer les sondes ni les tâches critiques
+type HTTPQoS struct {
+	lanes map[string]*trafficLane
+	wait  time.Duration // Attente maximale d'une place avant refus
+	mu    sync.Mutex
+}
+
+// NewHTTPQoS lit les limites QOS_LIMITS ("bulk=2,submit=128") et QOS_QUEUE_WAIT
+func NewHTTPQoS() *HTTPQoS {
+	limits := make(map[string]int, len(defaultTrafficLimits))
+	for class, limit := range defaultTrafficLimits {
+		limits[class] = limit
+	}
+	for _, kv := range getEnvList("QOS_LIMITS") {
+		class, value, _ := strings.Cut(kv, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		class = strings.TrimSpace(class)
+		if _, known := limits[class]; !known || err != nil || limit < 0 {
+			slog.Warn("Limite QOS_LIMITS invalide ignorée", "value", kv)
+			continue
+		}
+		limits[class] = limit
+	}
+
+	q := &HTTPQoS{
+		lanes: make(map[string]*trafficLane, len(limits)),
+		wait:  getEnvDuration("QOS_QUEUE_WAIT", 50*time.Millisecond),
+	}
+	for class, limit := range limits {
+		lane := &trafficLane{}
+		if limit > 0 {
+			lane.slots = make(chan struct{}, limit)
+		}
+		q.lanes[class] = lane
+	}
+	return q
+}
+
+// acquire réserve une place dans la voie de la classe; false si la voie est
+// restée pleine pendant toute l'attente
+func (q *HTTPQoS) acquire(class string) bool {
+	lane := q.lanes[class]
+	if lane.slots != nil {
+		select {
+		case lane.slots <- struct{}{}:
+		default:
+			timer := time.NewTimer(q.wait)
+			defer timer.Stop()
+			select {
+			case lane.slots <- struct{}{}:
+			case <-timer.C:
+				q.mu.Lock()
+				lane.shed++
+				q.mu.Unlock()
+				return false
+			}
+		}
+	}
+	q.mu.Lock()
+	lane.inFlight++
+	lane.served++
+	q.mu.Unlock()
+	return true
+}
+
+// release libère la place occupée par une requête terminée
+func (q *HTTPQoS) release(class string) {
+	lane := q.lanes[class]
+	q.mu.Lock()
+	lane.inFlight--
+	q.mu.Unlock()
+	if lane.slots != nil {
+		<-lane.slots
+	}
+}
+
+// Stats retourne l'occupation de chaque classe de trafic
+func (q *HTTPQoS) Stats() map[string]TrafficClassStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := make(map[string]TrafficClassStats, len(q.lanes))
+	for class, lane := range q.lanes {
+		stats[class] = TrafficClassStats{InFlight: lane.inFlight, Limit: cap(lane.slots), Served: lane.served, Shed: lane.shed}
+	}
+	return stats
+}
+
+// classify détermine la classe de trafic d'une requête routée. Pour une
+// soumission, la criticité est lue dans le corps, qui est ensuite restitué
+// intact au handler.
+func classify(r *http.Request) string {
+	_, template := routeKey(r)
+
+	switch {
+	case r.Method == http.MethodGet && monitoringRoutes[template]:
+		return TrafficMonitoring
+	case r.Method == http.MethodGet && bulkRoutes[template]:
+		return TrafficBulk
+	case r.Method == http.MethodPost && (template == "/tasks" || template == "/tasks/sync" || template == "/tasks/batch" || template == "/tasks/admission"):
+		if peekCriticality(r) >= 4 {
+			return TrafficCritical
+		}
+		return TrafficSubmit
+	}
+	return TrafficDefault
+}
+
+// peekCriticality lit la criticité annoncée dans le corps d'une soumission
+func peekCriticality(r *http.Request) int {
+	if r.Body == nil {
+		return 0
+	}
+	head, err := io.ReadAll(io.LimitReader(r.Body, qosPeekLimit))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil {
+		return 0
+	}
+
+	var probe struct {
+		Criticality int `json:"criticality"`
+	}
+	json.Unmarshal(head, &probe)
+	return probe.Criticality
+}
+
+// middleware applique la limite de concurrence de la classe de chaque requête
+func (q *HTTPQoS) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := classify(r)
+		if !q.acquire(class) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, fmt.Sprintf("Nœud saturé: trafic %s limité", class), http.StatusServiceUnavailable)
+			return
+		}
+		defer q.release(class)
+		next.ServeHTTP(w, r)
+	})
+}
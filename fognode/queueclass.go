@@ -0,0 +1,106 @@
+package fognode
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Classes d'admission de la queue
+const (
+	QueueClassCritical   = "critical"
+	QueueClassNormal     = "normal"
+	QueueClassBestEffort = "best_effort"
+)
+
+// queueClasses liste les classes d'admission dans l'ordre d'importance
+var queueClasses = []string{QueueClassCritical, QueueClassNormal, QueueClassBestEffort}
+
+// QueueClassStats décrit l'occupation d'une classe d'admission
+type QueueClassStats struct {
+	Queued int `json:"queued"`
+	Limit  int `json:"limit"`
+}
+
+// admissionClass détermine la classe d'admission d'une tâche selon sa criticité
+func admissionClass(task *Task) string {
+	switch {
+	case task.Criticality >= 4:
+		return QueueClassCritical
+	case task.Criticality >= 2:
+		return QueueClassNormal
+	default:
+		return QueueClassBestEffort
+	}
+}
+
+// loadQueueLimits lit la limite de queue de chaque classe depuis l'environnement
+// (QUEUE_LIMIT_CRITICAL, QUEUE_LIMIT_NORMAL, QUEUE_LIMIT_BEST_EFFORT), QUEUE_LIMIT
+// étant la limite commune par défaut
+func loadQueueLimits() map[string]int {
+	common := getEnvInt("QUEUE_LIMIT", MaxQueueSize)
+	limits := make(map[string]int, len(queueClasses))
+	for _, class := range queueClasses {
+		key := "QUEUE_LIMIT_" + strings.ToUpper(class)
+		limits[class] = getEnvInt(key, common)
+		if limits[class] < 0 {
+			config.errorf(key, "limite négative (%d)", limits[class])
+		}
+	}
+	return limits
+}
+
+// queueClassFull indique si la classe de la tâche a atteint sa limite; fc.mu doit être détenu
+func (fc *FogCompute) queueClassFull(class string) (bool, string) {
+	queued, limit := fc.classQueued[class], fc.queueLimits[class]
+	if queued >= limit {
+		return true, fmt.Sprintf("Queue %s pleine: %d/%d tâches", class, queued, limit)
+	}
+	return false, ""
+}
+
+// queueClassStats retourne l'occupation de chaque classe; fc.mu doit être détenu
+func (fc *FogCompute) queueClassStats() map[string]QueueClassStats {
+	stats := make(map[string]QueueClassStats, len(queueClasses))
+	for _, class := range queueClasses {
+		stats[class] = QueueClassStats{Queued: fc.classQueued[class], Limit: fc.queueLimits[class]}
+	}
+	return stats
+}
+
+// ReservedWorkerStats décrit l'occupation des workers réservés aux tâches critiques
+type ReservedWorkerStats struct {
+	Reserved    int     `json:"reserved"`
+	Busy        int     `json:"busy"`
+	Utilization float64 `json:"utilization"`  // Part des workers réservés occupés (0-1)
+	Served      int     `json:"tasks_served"` // Tâches exécutées par les workers réservés
+	Queued      int     `json:"queued"`       // Tâches critiques en attente
+}
+
+// loadReservedWorkers lit RESERVED_CRITICAL_WORKERS, le nombre de workers qui
+// n'exécutent que des tâches critiques; au moins un worker reste général
+func loadReservedWorkers(numWorkers int) int {
+	n := getEnvInt("RESERVED_CRITICAL_WORKERS", 0)
+	if n >= numWorkers {
+		slog.Warn("RESERVED_CRITICAL_WORKERS trop élevé", "value", n, "max", numWorkers-1)
+		n = numWorkers - 1
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// reservedWorkerStats retourne l'occupation des workers réservés; fc.mu doit être détenu
+func (fc *FogCompute) reservedWorkerStats() ReservedWorkerStats {
+	stats := ReservedWorkerStats{
+		Reserved: fc.reservedWorkers,
+		Busy:     fc.reservedBusy,
+		Served:   fc.reservedServed,
+	}
+	if fc.reservedWorkers > 0 {
+		stats.Utilization = float64(fc.reservedBusy) / float64(fc.reservedWorkers)
+		stats.Queued = fc.queuedFor(true)
+	}
+	return stats
+}
@@ -0,0 +1,187 @@
+package fognode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Politiques d'éviction de la queue des tâches rejetées
+const (
+	EvictOldest            = "oldest"             // Rejet le plus ancien
+	EvictLowestCriticality = "lowest_criticality" // Criticité la plus basse, puis la plus ancienne
+	EvictLowestPriority    = "lowest_priority"    // Priorité la plus basse (valeur la plus haute), puis la plus ancienne
+)
+
+// RejectedEviction borne la queue des tâches rejetées: au-delà de max
+// entrées, la tâche désignée par la politique est oubliée, ou déportée sur
+// disque (REJECTED_SPILL) pour qu'une longue panne n'épuise pas la mémoire
+// sans perdre la trace des rejets
+type RejectedEviction struct {
+	max       int
+	policy    string
+	evictions map[string]int64 // Évictions par politique
+
+	spillPath   string // Fichier JSON Lines des rejets évincés, vide sans déport
+	spillMax    int64  // Taille au-delà de laquelle le fichier est remplacé (l'ancien garde le suffixe .1)
+	spill       *os.File
+	spillSize   int64
+	spilled     int64
+	spillErrors int64
+}
+
+// RejectedEvictionStats décrit la limite de la queue des rejets et ses évictions
+type RejectedEvictionStats struct {
+	Max         int              `json:"max"`
+	Policy      string           `json:"policy"`
+	Evictions   map[string]int64 `json:"evictions"`
+	SpillPath   string           `json:"spill_path,omitempty"`
+	Spilled     int64            `json:"spilled"` // Rejets évincés écrits sur disque
+	SpillErrors int64            `json:"spill_errors,omitempty"`
+}
+
+// NewRejectedEviction lit REJECTED_QUEUE_MAX, REJECTED_EVICTION_POLICY,
+// REJECTED_SPILL et REJECTED_SPILL_MAX_MB
+func NewRejectedEviction() *RejectedEviction {
+	e := &RejectedEviction{
+		max:       getEnvInt("REJECTED_QUEUE_MAX", 10000),
+		policy:    getEnv("REJECTED_EVICTION_POLICY", EvictOldest),
+		evictions: make(map[string]int64),
+		spillMax:  int64(getEnvInt("REJECTED_SPILL_MAX_MB", 64)) << 20,
+	}
+	if getEnvBool("REJECTED_SPILL", false) {
+		e.spillPath = filepath.Join(getEnv("DATA_DIR", "data"), "rejected-spill.jsonl")
+	}
+	switch e.policy {
+	case EvictOldest, EvictLowestCriticality, EvictLowestPriority:
+	default:
+		slog.Warn("Politique d'éviction des rejets inconnue", "policy", e.policy, "default", EvictOldest)
+		e.policy = EvictOldest
+	}
+	return e
+}
+
+// evictsBefore indique si a doit être évincée avant b
+func (e *RejectedEviction) evictsBefore(a, b *RejectedTask) bool {
+	switch e.policy {
+	case EvictLowestCriticality:
+		if a.Task.Criticality != b.Task.Criticality {
+			return a.Task.Criticality < b.Task.Criticality
+		}
+	case EvictLowestPriority:
+		if a.Task.Priority != b.Task.Priority {
+			return a.Task.Priority > b.Task.Priority
+		}
+	}
+	return a.RejectedAt.Before(b.RejectedAt)
+}
+
+// evictRejectedLocked évince les tâches rejetées au-delà de la limite
+// (appelé avec fc.mu verrouillé)
+func (fc *FogCompute) evictRejectedLocked() {
+	e := fc.rejectedEviction
+	if e.max <= 0 {
+		return
+	}
+	for len(fc.rejectedTasks) > e.max {
+		// Les rejets sont ajoutés dans l'ordre: le plus ancien est en tête, et
+		// l'avancer suffit, comme dans un tampon circulaire
+		victim := 0
+		if e.policy != EvictOldest {
+			for i := 1; i < len(fc.rejectedTasks); i++ {
+				if e.evictsBefore(&fc.rejectedTasks[i], &fc.rejectedTasks[victim]) {
+					victim = i
+				}
+			}
+		}
+		evicted := fc.rejectedTasks[victim]
+		if victim == 0 {
+			fc.rejectedTasks[0] = RejectedTask{} // Libérer la tâche évincée du tableau sous-jacent
+			fc.rejectedTasks = fc.rejectedTasks[1:]
+		} else {
+			fc.rejectedTasks = append(fc.rejectedTasks[:victim], fc.rejectedTasks[victim+1:]...)
+		}
+		fc.storeErr("delete_rejected", evicted.Task.ID, fc.store.DeleteRejected(evicted.Task.ID))
+		e.spillEvicted(evicted)
+
+		e.evictions[e.policy]++
+		slog.Warn("Queue des rejets pleine: tâche rejetée évincée", "task_id", evicted.Task.ID,
+			"policy", e.policy, "criticality", evicted.Task.Criticality, "priority", evicted.Task.Priority,
+			"rejected_at", evicted.RejectedAt, "max", e.max)
+	}
+}
+
+// spillEvicted ajoute une tâche évincée au fichier de déport; fc.mu doit être détenu
+func (e *RejectedEviction) spillEvicted(rt RejectedTask) {
+	if e.spillPath == "" {
+		return
+	}
+	line, err := json.Marshal(rt)
+	if err == nil {
+		err = e.openSpill(int64(len(line)) + 1)
+	}
+	if err == nil {
+		_, err = e.spill.Write(append(line, '\n'))
+	}
+	if err != nil {
+		if e.spillErrors == 0 {
+			slog.Error("Déport des rejets évincés impossible", "path", e.spillPath, "error", err)
+		}
+		e.spillErrors++
+		return
+	}
+	e.spillSize += int64(len(line)) + 1
+	e.spilled++
+}
+
+// openSpill ouvre le fichier de déport, et le remplace s'il ne peut plus
+// recevoir n octets sans dépasser REJECTED_SPILL_MAX_MB
+func (e *RejectedEviction) openSpill(n int64) error {
+	if e.spill != nil && (e.spillMax <= 0 || e.spillSize+n <= e.spillMax) {
+		return nil
+	}
+	if e.spill != nil {
+		e.spill.Close()
+		e.spill = nil
+		if err := os.Rename(e.spillPath, e.spillPath+".1"); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(e.spillPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(e.spillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	e.spill, e.spillSize = f, info.Size()
+	if e.spillMax > 0 && e.spillSize+n > e.spillMax && e.spillSize > 0 {
+		// Fichier d'un démarrage précédent déjà plein
+		return e.openSpill(n)
+	}
+	return nil
+}
+
+// rejectedEvictionStats retourne la limite et les évictions de la queue des
+// rejets (appelé avec fc.mu verrouillé en lecture)
+func (fc *FogCompute) rejectedEvictionStats() RejectedEvictionStats {
+	e := fc.rejectedEviction
+	stats := RejectedEvictionStats{
+		Max:         e.max,
+		Policy:      e.policy,
+		Evictions:   make(map[string]int64, len(e.evictions)),
+		SpillPath:   e.spillPath,
+		Spilled:     e.spilled,
+		SpillErrors: e.spillErrors,
+	}
+	for policy, n := range e.evictions {
+		stats.Evictions[policy] = n
+	}
+	return stats
+}
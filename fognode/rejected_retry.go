@@ -0,0 +1,159 @@
+package fognode
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// RejectedRetryStats décrit les réessais automatiques des tâches rejetées (/metrics)
+type RejectedRetryStats struct {
+	Enabled     bool   `json:"enabled"`
+	Interval    string `json:"interval"`
+	MaxRetries  int    `json:"max_retries"`
+	Resubmitted int64  `json:"resubmitted"` // Tâches rejetées remises en queue
+	Refused     int64  `json:"refused"`     // Réévaluations encore refusées
+	Exhausted   int64  `json:"exhausted"`   // Tâches laissées au réessai manuel, réessais épuisés
+}
+
+// RejectedRetrier réévalue périodiquement (REJECTED_RETRY_INTERVAL) la queue
+// des rejets: une tâche que la chaîne d'admission accepte désormais (charge
+// retombée, ressources libérées) est remise en queue comme par POST
+// /tasks/rejected/{id}/retry. Une tâche encore refusée attend un délai
+// exponentiel avec gigue avant sa prochaine réévaluation, au plus
+// REJECTED_RETRY_MAX fois; elle reste ensuite disponible pour le réessai manuel.
+type RejectedRetrier struct {
+	enabled bool
+	every   time.Duration
+	max     int
+	backoff RetryPolicy // Croissance du délai entre réévaluations
+
+	// Protégés par fc.mu
+	resubmitted int64
+	refused     int64
+	exhausted   int64
+}
+
+// loadRejectedRetrier lit REJECTED_AUTO_RETRY, REJECTED_RETRY_INTERVAL,
+// REJECTED_RETRY_MAX, REJECTED_RETRY_BACKOFF et REJECTED_RETRY_MAX_BACKOFF
+func loadRejectedRetrier() *RejectedRetrier {
+	return &RejectedRetrier{
+		enabled: getEnvBool("REJECTED_AUTO_RETRY", false),
+		every:   getEnvDuration("REJECTED_RETRY_INTERVAL", 10*time.Second),
+		max:     getEnvInt("REJECTED_RETRY_MAX", 3),
+		backoff: RetryPolicy{
+			BackoffMs:    getEnvDuration("REJECTED_RETRY_BACKOFF", 30*time.Second).Milliseconds(),
+			MaxBackoffMs: getEnvDuration("REJECTED_RETRY_MAX_BACKOFF", 10*time.Minute).Milliseconds(),
+			Multiplier:   2,
+		},
+	}
+}
+
+// delay retourne l'attente avant la réévaluation suivante, après `refusals`
+// refus, avec une gigue de ±50% pour ne pas réadmettre tous les rejets d'une
+// même surcharge au même instant
+func (rr *RejectedRetrier) delay(refusals int) time.Duration {
+	d := rr.backoff.delay(refusals)
+	return d/2 + time.Duration(rand.Int63n(int64(d)+1))
+}
+
+// runRejectedRetry réévalue la queue des rejets jusqu'à l'annulation de ctx
+func (fc *FogCompute) runRejectedRetry(ctx context.Context) {
+	rr := fc.rejectedRetry
+	if !rr.enabled || rr.every <= 0 {
+		return
+	}
+	ticker := time.NewTicker(rr.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			fc.retryRejected(now)
+		}
+	}
+}
+
+// retryRejected réévalue les tâches rejetées dont le délai est écoulé
+func (fc *FogCompute) retryRejected(now time.Time) {
+	rr := fc.rejectedRetry
+	fc.mu.RLock()
+	if fc.draining {
+		fc.mu.RUnlock()
+		return
+	}
+	var due []Task
+	for _, rt := range fc.rejectedTasks {
+		if rt.AutoRetries < rr.max && (rt.NextRetryAt == nil || !now.Before(*rt.NextRetryAt)) {
+			due = append(due, rt.Task)
+		}
+	}
+	fc.mu.RUnlock()
+
+	for i := range due {
+		task := &due[i]
+		task.QueueClass = admissionClass(task)
+		task.PriorityClass = priorityClass(task)
+		rej := fc.admission.Check(fc, fc.admissionSnapshot(task, nil))
+
+		fc.mu.Lock()
+		index := -1
+		for j := range fc.rejectedTasks {
+			if fc.rejectedTasks[j].Task.ID == task.ID {
+				index = j
+				break
+			}
+		}
+		if index == -1 || fc.draining {
+			// Réessayée à la main ou effacée entre-temps
+			fc.mu.Unlock()
+			continue
+		}
+		if rej != nil {
+			rt := &fc.rejectedTasks[index]
+			rt.AutoRetries++
+			rt.LastRetryReason = rej.Reason
+			rr.refused++
+			if rt.AutoRetries >= rr.max {
+				rt.NextRetryAt = nil
+				rr.exhausted++
+				slog.Info("Réessais automatiques épuisés pour la tâche rejetée", "task_id", task.ID,
+					"retries", rt.AutoRetries, "reason", rej.Reason)
+			} else {
+				next := now.Add(rr.delay(rt.AutoRetries))
+				rt.NextRetryAt = &next
+			}
+			fc.storeErr("save_rejected", task.ID, fc.store.SaveRejected(*rt))
+			fc.mu.Unlock()
+			continue
+		}
+
+		fc.rejectedTasks = append(fc.rejectedTasks[:index], fc.rejectedTasks[index+1:]...)
+		fc.storeErr("delete_rejected", task.ID, fc.store.DeleteRejected(task.ID))
+		task.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceRetry, Client: "auto", RetryOf: task.ID})
+		task.SubmittedAt = now
+		task.SmartScore = task.calculateScore()
+		fc.enqueueWhenReadyLocked(task)
+		rr.resubmitted++
+		fc.mu.Unlock()
+		fc.journal.Record(task)
+
+		slog.Info("Tâche rejetée resoumise automatiquement", "task_id", task.ID, "owner", task.Owner,
+			"priority", task.Priority, "smart_score", task.SmartScore)
+	}
+}
+
+// rejectedRetryStatsLocked retourne l'état des réessais automatiques; fc.mu doit être détenu, au moins en lecture
+func (fc *FogCompute) rejectedRetryStatsLocked() RejectedRetryStats {
+	rr := fc.rejectedRetry
+	return RejectedRetryStats{
+		Enabled:     rr.enabled,
+		Interval:    rr.every.String(),
+		MaxRetries:  rr.max,
+		Resubmitted: rr.resubmitted,
+		Refused:     rr.refused,
+		Exhausted:   rr.exhausted,
+	}
+}
@@ -0,0 +1,113 @@
+package fognode
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// tunablesMu protège les réglages rechargeables lus hors de fc.mu
+	// (scoreWeights, taskDefaultCosts)
+	tunablesMu sync.RWMutex
+	// reloadMu sérialise les rechargements (SIGHUP et /admin/reload)
+	reloadMu sync.Mutex
+)
+
+// currentScoreWeights retourne les poids courants du SmartScore
+func currentScoreWeights() scoreVector {
+	tunablesMu.RLock()
+	defer tunablesMu.RUnlock()
+	return scoreWeights
+}
+
+// defaultCostsFor retourne les coûts par défaut d'un type de tâche
+func defaultCostsFor(taskType string) TaskCosts {
+	tunablesMu.RLock()
+	defer tunablesMu.RUnlock()
+	if costs, ok := taskDefaultCosts[taskType]; ok {
+		return costs
+	}
+	return taskDefaultCosts[defaultTaskCostsType]
+}
+
+// ReloadResult décrit les réglages appliqués par un rechargement
+type ReloadResult struct {
+	File         string               `json:"file"`
+	ReloadedAt   time.Time            `json:"reloaded_at"`
+	MaxLoad      float64              `json:"max_load"`
+	LoadWeights  map[string]float64   `json:"load_weights"`
+	QueueLimits  map[string]int       `json:"queue_limits"`
+	ScoreWeights map[string]float64   `json:"score_weights"`
+	DefaultCosts map[string]TaskCosts `json:"default_costs"`
+}
+
+// reloadConfig relit le fichier de configuration et applique, sans
+// redémarrage ni perte de la queue, les seuils (MAX_LOAD, LOAD_WEIGHTS,
+// LOAD_WAIT_TARGET, QUEUE_LIMIT*), les poids du SmartScore (SCORE_WEIGHTS) et
+// les coûts par défaut (DEFAULT_COSTS_*). Les autres réglages demandent un
+// redémarrage. Une configuration invalide est refusée en bloc: les réglages
+// en vigueur restent inchangés.
+func (fc *FogCompute) reloadConfig() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	restore, err := config.Reload()
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	load := loadLoadModel()
+	limits := loadQueueLimits()
+	weights := loadScoreWeights()
+	costs := loadTaskDefaultCosts()
+	if err := config.Validate(); err != nil {
+		restore()
+		return ReloadResult{}, err
+	}
+
+	tunablesMu.Lock()
+	scoreWeights, taskDefaultCosts = weights, costs
+	tunablesMu.Unlock()
+
+	fc.mu.Lock()
+	m := fc.load
+	m.reservation, m.cpu, m.wait = load.reservation, load.cpu, load.wait
+	m.waitTarget, m.threshold = load.waitTarget, load.threshold
+	fc.queueLimits = limits
+	fc.scheduler.Rescore() // Les tâches en queue sont réordonnées selon les nouveaux poids
+	fc.mu.Unlock()
+
+	result := ReloadResult{
+		File:         config.path,
+		ReloadedAt:   time.Now(),
+		MaxLoad:      load.threshold,
+		LoadWeights:  map[string]float64{"reservation": load.reservation, "cpu": load.cpu, "wait": load.wait},
+		QueueLimits:  limits,
+		ScoreWeights: make(map[string]float64, len(scoreTerms)),
+		DefaultCosts: costs,
+	}
+	for i, term := range scoreTerms {
+		result.ScoreWeights[term] = weights[i]
+	}
+	slog.Info("Configuration rechargée", "file", result.File, "max_load", result.MaxLoad)
+	return result, nil
+}
+
+// handleReload recharge la configuration (POST /admin/reload)
+func (fc *FogCompute) handleReload(w http.ResponseWriter, r *http.Request) {
+	result, err := fc.reloadConfig()
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, errNoConfigFile) {
+			status = http.StatusConflict
+		}
+		slog.Warn("Rechargement de la configuration refusé", "error", err, "client", requestClient(r))
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
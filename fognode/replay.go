@@ -0,0 +1,434 @@
+package fognode
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes d'enregistrement du payload dans le journal des soumissions
+const (
+	JournalPayloadHash = "hash" // Empreinte SHA-256 et taille seulement
+	JournalPayloadFull = "full" // Payload complet, rejoué tel quel
+)
+
+// SubmissionRecord est une soumission admise, telle que reçue par le nœud:
+// de quoi rejouer la même charge, au même rythme, pour reproduire un
+// comportement du scheduler observé sur le terrain
+type SubmissionRecord struct {
+	At           time.Time              `json:"at"` // Admission par le nœud
+	NodeID       string                 `json:"node_id"`
+	Source       string                 `json:"source,omitempty"` // Origine de la soumission (http, mqtt, retry...)
+	TaskID       string                 `json:"task_id"`
+	Type         string                 `json:"type"`
+	DeviceID     string                 `json:"device_id,omitempty"`
+	Priority     int                    `json:"priority"`
+	Criticality  int                    `json:"criticality"`
+	QueueClass   string                 `json:"queue_class,omitempty"`
+	CPUCost      float64                `json:"cpu_cost"`
+	RAMCost      float64                `json:"ram_cost"`
+	StorageCost  float64                `json:"storage_cost"`
+	EnergyCost   float64                `json:"energy_cost"`
+	DeadlineInMs int64                  `json:"deadline_in_ms,omitempty"` // Échéance relative à l'admission
+	TimeoutMs    int64                  `json:"timeout_ms,omitempty"`
+	Retry        *RetryPolicy           `json:"retry,omitempty"`
+	PayloadSHA   string                 `json:"payload_sha256"`
+	PayloadSize  int                    `json:"payload_bytes"`
+	Payload      map[string]interface{} `json:"payload,omitempty"` // SUBMISSION_JOURNAL_PAYLOADS=full
+}
+
+// JournalStats décrit l'enregistrement des soumissions
+type JournalStats struct {
+	Enabled   bool   `json:"enabled"`
+	Path      string `json:"path,omitempty"`
+	Payloads  string `json:"payloads,omitempty"`
+	Recorded  int64  `json:"recorded"`
+	Errors    int64  `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// SubmissionJournal enregistre les soumissions admises en JSON, une par
+// ligne. Au-delà de SUBMISSION_JOURNAL_MAX_MB, le journal est renommé en
+// .1 (l'ancien .1 est perdu) et un nouveau commence.
+type SubmissionJournal struct {
+	path      string
+	payloads  string
+	maxBytes  int64
+	nodeID    string
+	file      *os.File
+	size      int64
+	recorded  int64
+	errors    int64
+	lastError string
+	mu        sync.Mutex
+}
+
+// NewSubmissionJournal lit SUBMISSION_JOURNAL, SUBMISSION_JOURNAL_PAYLOADS et
+// SUBMISSION_JOURNAL_MAX_MB; sans chemin, rien n'est enregistré
+func NewSubmissionJournal(nodeID string) *SubmissionJournal {
+	j := &SubmissionJournal{
+		path:     getEnv("SUBMISSION_JOURNAL", ""),
+		payloads: getEnv("SUBMISSION_JOURNAL_PAYLOADS", JournalPayloadHash),
+		maxBytes: int64(getEnvInt("SUBMISSION_JOURNAL_MAX_MB", 256)) << 20,
+		nodeID:   nodeID,
+	}
+	if j.payloads != JournalPayloadHash && j.payloads != JournalPayloadFull {
+		slog.Warn("Mode d'enregistrement des payloads inconnu", "payloads", j.payloads, "default", JournalPayloadHash)
+		j.payloads = JournalPayloadHash
+	}
+	if j.path != "" {
+		slog.Info("Journal des soumissions activé", "path", j.path, "payloads", j.payloads)
+	}
+	return j
+}
+
+// Record enregistre une soumission admise
+func (j *SubmissionJournal) Record(task *Task) {
+	if j.path == "" {
+		return
+	}
+	rec := SubmissionRecord{
+		At:          time.Now(),
+		NodeID:      j.nodeID,
+		TaskID:      task.ID,
+		Type:        task.Type,
+		DeviceID:    task.DeviceID,
+		Priority:    task.Priority,
+		Criticality: task.Criticality,
+		QueueClass:  task.QueueClass,
+		CPUCost:     task.CPUCost,
+		RAMCost:     task.RAMCost,
+		StorageCost: task.StorageCost,
+		EnergyCost:  task.EnergyCost,
+		TimeoutMs:   task.TimeoutMs,
+		Retry:       task.Retry,
+	}
+	if n := len(task.Provenance); n > 0 {
+		rec.Source = task.Provenance[n-1].Source
+	}
+	if task.Deadline != nil {
+		rec.DeadlineInMs = task.Deadline.Sub(task.SubmittedAt).Milliseconds()
+	}
+	payload, _ := json.Marshal(task.Payload)
+	sum := sha256.Sum256(payload)
+	rec.PayloadSHA = hex.EncodeToString(sum[:])
+	rec.PayloadSize = len(payload)
+	if j.payloads == JournalPayloadFull {
+		rec.Payload = task.Payload
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.writeLocked(line); err != nil {
+		j.errors++
+		if j.lastError != err.Error() {
+			slog.Error("Écriture du journal des soumissions impossible", "path", j.path, "error", err)
+		}
+		j.lastError = err.Error()
+		return
+	}
+	j.recorded++
+}
+
+// writeLocked ajoute une ligne au journal, en l'ouvrant ou en le faisant
+// tourner au besoin; j.mu doit être détenu
+func (j *SubmissionJournal) writeLocked(line []byte) error {
+	if j.file != nil && j.maxBytes > 0 && j.size+int64(len(line)) > j.maxBytes {
+		j.file.Close()
+		j.file = nil
+		if err := os.Rename(j.path, j.path+".1"); err != nil {
+			return err
+		}
+	}
+	if j.file == nil {
+		if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		j.file, j.size = f, fi.Size()
+	}
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+// Close ferme le journal
+func (j *SubmissionJournal) Close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// Stats retourne l'état du journal
+func (j *SubmissionJournal) Stats() JournalStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stats := JournalStats{Enabled: j.path != "", Recorded: j.recorded, Errors: j.errors, LastError: j.lastError}
+	if stats.Enabled {
+		stats.Path = j.path
+		stats.Payloads = j.payloads
+	}
+	return stats
+}
+
+// readSubmissionJournal relit un journal, dans l'ordre d'admission; les
+// lignes illisibles (dernière ligne tronquée) sont ignorées
+func readSubmissionJournal(path string) ([]SubmissionRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []SubmissionRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec SubmissionRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.Type == "" {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, k int) bool { return records[i].At.Before(records[k].At) })
+	return records, nil
+}
+
+// task reconstruit la soumission d'un enregistrement; l'échéance est
+// recalée sur l'instant du rejeu
+func (rec SubmissionRecord) task(now time.Time) Task {
+	task := Task{
+		Type:        rec.Type,
+		DeviceID:    rec.DeviceID,
+		Payload:     rec.Payload,
+		Priority:    rec.Priority,
+		Criticality: rec.Criticality,
+		CPUCost:     rec.CPUCost,
+		RAMCost:     rec.RAMCost,
+		StorageCost: rec.StorageCost,
+		EnergyCost:  rec.EnergyCost,
+		TimeoutMs:   rec.TimeoutMs,
+		Retry:       rec.Retry,
+	}
+	if task.Payload == nil {
+		task.Payload = map[string]interface{}{}
+	}
+	if rec.DeadlineInMs > 0 {
+		deadline := now.Add(time.Duration(rec.DeadlineInMs) * time.Millisecond)
+		task.Deadline = &deadline
+	}
+	return task
+}
+
+// ReplayOutcome est le résultat du rejeu d'une soumission contre un nœud
+type ReplayOutcome struct {
+	OriginalID string `json:"original_id"`
+	TaskID     string `json:"task_id,omitempty"` // Identifiant attribué par le nœud cible
+	Status     int    `json:"status"`            // Code HTTP (0: nœud injoignable)
+	Reason     string `json:"reason,omitempty"`
+	OffsetMs   int64  `json:"offset_ms"` // Instant de soumission depuis le début du rejeu
+}
+
+// ReplayReport est le bilan d'un rejeu contre un nœud
+type ReplayReport struct {
+	Journal         string          `json:"journal"`
+	Target          string          `json:"target"`
+	Speed           float64         `json:"speed"`
+	Submitted       int             `json:"submitted"`
+	Accepted        int             `json:"accepted"`
+	ByStatus        map[int]int     `json:"by_status"`
+	MissingPayloads int             `json:"missing_payloads"` // Journal sans payload: rejouées avec un payload vide
+	DurationMs      int64           `json:"duration_ms"`
+	Outcomes        []ReplayOutcome `json:"outcomes"`
+}
+
+// runReplay rejoue le journal REPLAY contre le nœud REPLAY_TARGET, ou contre
+// le simulateur (REPLAY_TARGET=simulator), et écrit le bilan en JSON sur la
+// sortie standard
+func runReplay() error {
+	path := getEnv("REPLAY", "")
+	records, err := readSubmissionJournal(path)
+	if err != nil {
+		return fmt.Errorf("lecture de %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("journal %s vide", path)
+	}
+	speed := getEnvFloat("REPLAY_SPEED", 1)
+	target := getEnv("REPLAY_TARGET", "http://localhost:8080")
+
+	var report interface{}
+	if target == "simulator" {
+		report, err = replaySimulation(path, records, speed)
+	} else {
+		report, err = replayAgainstNode(path, records, strings.TrimRight(target, "/"), speed)
+	}
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// replayAgainstNode soumet les enregistrements au nœud cible en respectant
+// leurs écarts d'arrivée, divisés par speed (0: au plus vite)
+func replayAgainstNode(path string, records []SubmissionRecord, target string, speed float64) (*ReplayReport, error) {
+	client := newNodeClient(getEnvDuration("REPLAY_TIMEOUT", 10*time.Second))
+	concurrency := getEnvInt("REPLAY_CONCURRENCY", 32)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	report := &ReplayReport{Journal: path, Target: target, Speed: speed, ByStatus: map[int]int{}}
+	outcomes := make([]ReplayOutcome, len(records))
+
+	slog.Info("Rejeu du journal des soumissions", "journal", path, "target", target,
+		"submissions", len(records), "speed", speed)
+
+	start := time.Now()
+	first := records[0].At
+	var wg sync.WaitGroup
+	for i, rec := range records {
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(rec.At.Sub(first)) / speed))
+			time.Sleep(time.Until(due))
+		}
+		// Un payload "null" ou "{}" n'a pas besoin d'être enregistré pour être rejoué
+		if rec.Payload == nil && rec.PayloadSize > len("null") {
+			report.MissingPayloads++
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, rec SubmissionRecord) {
+			defer func() { <-slots; wg.Done() }()
+			now := time.Now()
+			outcome := ReplayOutcome{OriginalID: rec.TaskID, OffsetMs: now.Sub(start).Milliseconds()}
+			body, _ := json.Marshal(rec.task(now))
+			req, err := http.NewRequest(http.MethodPost, target+"/tasks", bytes.NewReader(body))
+			if err != nil {
+				outcome.Reason = err.Error()
+				outcomes[i] = outcome
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			setNodeAPIKey(req)
+			resp, err := client.Do(req)
+			if err != nil {
+				outcome.Reason = err.Error()
+				outcomes[i] = outcome
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			outcome.Status = resp.StatusCode
+			if resp.StatusCode < 300 {
+				var accepted Task
+				json.Unmarshal(data, &accepted)
+				outcome.TaskID = accepted.ID
+			} else {
+				outcome.Reason = strings.TrimSpace(string(data))
+			}
+			outcomes[i] = outcome
+		}(i, rec)
+	}
+	wg.Wait()
+
+	report.DurationMs = time.Since(start).Milliseconds()
+	report.Submitted = len(records)
+	report.Outcomes = outcomes
+	for _, o := range outcomes {
+		report.ByStatus[o.Status]++
+		if o.Status >= 200 && o.Status < 300 {
+			report.Accepted++
+		}
+	}
+	slog.Info("Rejeu terminé", "submitted", report.Submitted, "accepted", report.Accepted,
+		"duration_ms", report.DurationMs)
+	return report, nil
+}
+
+// journalWorkload convertit les enregistrements en charge simulée, aux
+// mêmes écarts d'arrivée divisés par speed
+func journalWorkload(records []SubmissionRecord, speed float64) []*simTask {
+	if speed <= 0 {
+		speed = 1 // Le temps simulé est virtuel: "au plus vite" n'a pas de sens
+	}
+	first := records[0].At
+	tasks := make([]*simTask, 0, len(records))
+	for _, rec := range records {
+		task := rec.task(rec.At)
+		task.ID = rec.TaskID
+		task.applyDefaultCosts()
+		task.SmartScore = task.calculateScore()
+
+		work, ok := taskWorkDurations[task.Type]
+		if !ok {
+			work = 100 * time.Millisecond
+		}
+		arrival := time.Duration(float64(rec.At.Sub(first)) / speed)
+		tasks = append(tasks, &simTask{task: task, arrival: arrival, work: work})
+	}
+	return tasks
+}
+
+// replaySimulation joue le journal sur la topologie de SIMULATION_CONFIG
+// avec chaque stratégie de placement
+func replaySimulation(path string, records []SubmissionRecord, speed float64) (map[string]interface{}, error) {
+	cfg, err := loadSimulationConfig(getEnv("SIMULATION_CONFIG", ""))
+	if err != nil {
+		return nil, err
+	}
+	workload := journalWorkload(records, speed)
+
+	slog.Info("Rejeu du journal sur le simulateur", "journal", path, "profiles", len(cfg.Profiles),
+		"tasks", len(workload), "strategies", cfg.Strategies)
+
+	results := make([]SimulationResult, 0, len(cfg.Strategies))
+	for _, strategy := range cfg.Strategies {
+		r := simulate(cfg, workload, strategy)
+		slog.Info("Stratégie simulée", "strategy", r.Strategy, "completed", r.Completed, "submitted", r.Submitted,
+			"rejected", r.Rejected, "avg_latency_ms", r.AvgLatencyMs, "p95_latency_ms", r.P95LatencyMs, "energy_wh", r.EnergyWh)
+		results = append(results, r)
+	}
+	return map[string]interface{}{
+		"journal":  path,
+		"speed":    speed,
+		"profiles": cfg.Profiles,
+		"results":  results,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// Politiques appliquées à un résultat plus volumineux que RESULT_MAX_BYTES
+const (
+	ResultTruncate = "truncate" // Ne conserver qu'un aperçu du résultat
+	ResultSpill    = "spill"    // Déporter le résultat vers le stockage objet et garder une référence
+	ResultFail     = "fail"     // Faire échouer la tâche
+)
+
+// ResultLimits borne la taille des résultats conservés en mémoire, renvoyés
+// par l'API et transmis au cloud
+type ResultLimits struct {
+	MaxBytes  int           `json:"max_bytes"`
+	Policy    string        `json:"policy"`
+	spillDir  string        // Stockage local des résultats déportés
+	spillURL  string        // Stockage objet distant (PUT <url>/<id>.json), prioritaire sur le local
+	token     string        // Jeton Bearer du stockage objet
+	retention time.Duration // Durée de conservation des résultats déportés localement
+	client    *http.Client
+}
+
+// loadResultLimits lit RESULT_MAX_BYTES, RESULT_LIMIT_POLICY, RESULT_SPILL_URL,
+// RESULT_SPILL_TOKEN et RESULT_SPILL_RETENTION
+func loadResultLimits() ResultLimits {
+	rl := ResultLimits{
+		MaxBytes:  getEnvInt("RESULT_MAX_BYTES", 1024*1024),
+		Policy:    getEnv("RESULT_LIMIT_POLICY", ResultTruncate),
+		spillDir:  filepath.Join(getEnv("DATA_DIR", "data"), "results"),
+		spillURL:  strings.TrimRight(getEnv("RESULT_SPILL_URL", ""), "/"),
+		token:     getEnv("RESULT_SPILL_TOKEN", ""),
+		retention: getEnvDuration("RESULT_SPILL_RETENTION", 24*time.Hour),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	switch rl.Policy {
+	case ResultTruncate, ResultSpill, ResultFail:
+	default:
+		slog.Warn("Politique de résultat inconnue", "policy", rl.Policy, "default", ResultTruncate)
+		rl.Policy = ResultTruncate
+	}
+	return rl
+}
+
+// limitResult applique la politique de taille au résultat d'une tâche. Il
+// retourne le résultat à conserver, ou une erreur si la tâche doit échouer.
+func (fc *FogCompute) limitResult(task *Task, result interface{}) (interface{}, error) {
+	rl := fc.resultLimits
+	if rl.MaxBytes <= 0 || result == nil {
+		return result, nil
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("résultat non sérialisable: %w", err)
+	}
+	if len(raw) <= rl.MaxBytes {
+		return result, nil
+	}
+
+	switch rl.Policy {
+	case ResultFail:
+		return nil, fmt.Errorf("résultat trop volumineux: %d octets (max %d)", len(raw), rl.MaxBytes)
+
+	case ResultSpill:
+		location, err := rl.spill(task.ID, raw)
+		if err == nil {
+			slog.Info("Résultat déporté", "task_id", task.ID, "size_bytes", len(raw), "location", location)
+			return map[string]interface{}{
+				"spilled":    true,
+				"size_bytes": len(raw),
+				"location":   location,
+			}, nil
+		}
+		// Stockage indisponible: garder au moins un aperçu plutôt que tout perdre
+		slog.Warn("Déport du résultat impossible: troncature", "task_id", task.ID, "error", err)
+	}
+
+	return map[string]interface{}{
+		"truncated":  true,
+		"size_bytes": len(raw),
+		"preview":    truncateUTF8(raw, rl.MaxBytes/2),
+	}, nil
+}
+
+// truncateUTF8 coupe les données à n octets au plus sans couper de caractère
+func truncateUTF8(data []byte, n int) string {
+	if len(data) <= n {
+		return string(data)
+	}
+	for n > 0 && !utf8.RuneStart(data[n]) {
+		n--
+	}
+	return string(data[:n])
+}
+
+// spill stocke un résultat volumineux et retourne son emplacement
+func (rl ResultLimits) spill(taskID string, raw []byte) (string, error) {
+	name := filepath.Base(taskID) + ".json"
+
+	if rl.spillURL != "" {
+		url := rl.spillURL + "/" + name
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(raw))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if rl.token != "" {
+			req.Header.Set("Authorization", "Bearer "+rl.token)
+		}
+		resp, err := rl.client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return "", fmt.Errorf("le stockage objet a répondu %s", resp.Status)
+		}
+		return url, nil
+	}
+
+	if err := os.MkdirAll(rl.spillDir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(rl.spillDir, name), raw, 0o644); err != nil {
+		return "", err
+	}
+	return APIPrefix + "/tasks/" + taskID + "/result", nil
+}
+
+// pruneSpilledResults supprime périodiquement les résultats déportés localement
+// au-delà de RESULT_SPILL_RETENTION
+func (fc *FogCompute) pruneSpilledResults(ctx context.Context) {
+	rl := fc.resultLimits
+	if rl.Policy != ResultSpill || rl.spillURL != "" || rl.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			entries, err := os.ReadDir(rl.spillDir)
+			if err != nil {
+				continue
+			}
+			cutoff := time.Now().Add(-rl.retention)
+			for _, e := range entries {
+				info, err := e.Info()
+				if err == nil && info.ModTime().Before(cutoff) {
+					os.Remove(filepath.Join(rl.spillDir, e.Name()))
+				}
+			}
+		}
+	}
+}
+
+// handleGetTaskResult diffuse un blob du résultat d'une tâche, ou à défaut
+// son résultat complet déporté localement
+func (fc *FogCompute) handleGetTaskResult(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+	fc.mu.RLock()
+	task, known := fc.tasks[taskID]
+	visible := all || (known && visibleTo(task, tenant, all))
+	var result interface{}
+	if known {
+		result = task.Result
+	}
+	fc.mu.RUnlock()
+	if !visible {
+		http.Error(w, "Résultat déporté non trouvé", http.StatusNotFound)
+		return
+	}
+	if fc.serveResultBlob(w, r, taskID, result) {
+		return
+	}
+	path := filepath.Join(fc.resultLimits.spillDir, filepath.Base(taskID)+".json")
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Résultat déporté non trouvé", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
@@ -0,0 +1,308 @@
+package fognode
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TaskRetention borne la mémoire occupée par les tâches terminées: en plus de
+// TASK_RETENTION et FAILED_RETENTION, au plus TASK_MAX_RETAINED tâches
+// terminées restent en mémoire. Les tâches oubliées sont, avec TASK_ARCHIVE,
+// ajoutées à une archive sur disque que GET /tasks?archived=true et
+// GET /tasks/{id} consultent encore.
+type TaskRetention struct {
+	MaxRetained int           // Tâches terminées conservées au plus (0 = sans limite)
+	Interval    time.Duration // Cadence du ramasse-miettes
+	archivePath string        // Archive JSON lines des tâches oubliées; vide: pas d'archive
+	archiveMax  int64         // Taille au-delà de laquelle l'archive passe en .1
+
+	mu        sync.Mutex // Sérialise l'écriture de l'archive et les compteurs
+	collected int
+	archived  int
+	lastRun   time.Time
+}
+
+// RetentionStats décrit la rétention des tâches dans /metrics
+type RetentionStats struct {
+	Retained    int        `json:"retained"` // Tâches terminées en mémoire
+	MaxRetained int        `json:"max_retained"`
+	Collected   int        `json:"collected"` // Tâches oubliées depuis le démarrage
+	Archived    int        `json:"archived"`
+	Archive     bool       `json:"archive"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+}
+
+// NewTaskRetention lit TASK_MAX_RETAINED, TASK_GC_INTERVAL, TASK_ARCHIVE et
+// TASK_ARCHIVE_MAX_MB
+func NewTaskRetention() *TaskRetention {
+	tr := &TaskRetention{
+		MaxRetained: getEnvInt("TASK_MAX_RETAINED", 10000),
+		Interval:    getEnvDuration("TASK_GC_INTERVAL", time.Minute),
+		archiveMax:  int64(getEnvInt("TASK_ARCHIVE_MAX_MB", 100)) << 20,
+	}
+	if getEnvBool("TASK_ARCHIVE", false) {
+		tr.archivePath = filepath.Join(getEnv("DATA_DIR", "data"), "tasks-archive.jsonl")
+	}
+	if tr.MaxRetained < 0 || tr.Interval <= 0 || tr.archiveMax <= 0 {
+		config.errorf("TASK_MAX_RETAINED", "TASK_MAX_RETAINED, TASK_GC_INTERVAL et TASK_ARCHIVE_MAX_MB doivent être positifs")
+	}
+	return tr
+}
+
+// collectTasks lance le ramasse-miettes des tâches terminées toutes les
+// TASK_GC_INTERVAL
+func (fc *FogCompute) collectTasks(ctx context.Context) {
+	ticker := time.NewTicker(fc.retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fc.pruneTasks()
+		}
+	}
+}
+
+// pruneTasks oublie les tâches terminées depuis plus de TASK_RETENTION, les
+// tâches en échec définitif depuis plus de FAILED_RETENTION, puis les plus
+// anciennes tâches terminées au-delà de TASK_MAX_RETAINED. Les tâches en échec
+// définitif ne comptent pas dans ce plafond: elles attendent une décision
+// d'opérateur (/failed-tasks). Leurs blobs de résultat sont supprimés.
+func (fc *FogCompute) pruneTasks() {
+	now := time.Now()
+	cutoff, failedCutoff := now.Add(-fc.taskRetention), now.Add(-fc.failedRetention)
+
+	fc.mu.Lock()
+	var pruned, finished []*Task
+	for _, t := range fc.tasks {
+		if t.CompletedAt == nil {
+			continue
+		}
+		retention, before := fc.taskRetention, cutoff
+		if isDeadLetter(t) {
+			retention, before = fc.failedRetention, failedCutoff
+		}
+		if retention > 0 && t.CompletedAt.Before(before) {
+			pruned = append(pruned, t)
+		} else if !isDeadLetter(t) {
+			finished = append(finished, t)
+		}
+	}
+	if max := fc.retention.MaxRetained; max > 0 && len(finished) > max {
+		sort.Slice(finished, func(i, j int) bool { return finished[i].CompletedAt.Before(*finished[j].CompletedAt) })
+		pruned = append(pruned, finished[:len(finished)-max]...)
+	}
+	snapshots := make([]Task, 0, len(pruned))
+	for _, t := range pruned {
+		delete(fc.tasks, t.ID)
+		fc.storeErr("delete", t.ID, fc.store.DeleteTask(t.ID))
+		snapshots = append(snapshots, *t)
+	}
+	fc.mu.Unlock()
+
+	fc.retention.record(snapshots)
+	for i := range snapshots {
+		for _, ref := range resultBlobs(snapshots[i].ID, snapshots[i].Result) {
+			if err := fc.blobs.store.Delete(context.Background(), ref.Key); err != nil {
+				slog.Warn("Suppression du blob de résultat impossible", "task_id", snapshots[i].ID, "blob", ref.Key, "error", err)
+			}
+		}
+	}
+	if len(snapshots) > 0 {
+		slog.Debug("Tâches terminées oubliées", "count", len(snapshots))
+	}
+}
+
+// record compte les tâches oubliées et les ajoute à l'archive
+func (tr *TaskRetention) record(tasks []Task) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.lastRun = time.Now()
+	tr.collected += len(tasks)
+	if tr.archivePath == "" || len(tasks) == 0 {
+		return
+	}
+	if err := tr.appendLocked(tasks); err != nil {
+		slog.Error("Archivage des tâches impossible", "path", tr.archivePath, "count", len(tasks), "error", err)
+		return
+	}
+	tr.archived += len(tasks)
+}
+
+// appendLocked ajoute des tâches à l'archive, qui passe en .1 (remplaçant la
+// précédente) au-delà de TASK_ARCHIVE_MAX_MB; tr.mu doit être détenu
+func (tr *TaskRetention) appendLocked(tasks []Task) error {
+	if info, err := os.Stat(tr.archivePath); err == nil && info.Size() >= tr.archiveMax {
+		if err := os.Rename(tr.archivePath, tr.archivePath+".1"); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(tr.archivePath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(tr.archivePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range tasks {
+		if err := enc.Encode(&tasks[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// scanArchive parcourt l'archive, de la plus ancienne tâche à la plus récente;
+// fn retourne false pour arrêter
+func (tr *TaskRetention) scanArchive(fn func(*Task) bool) {
+	if tr.archivePath == "" {
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for _, path := range []string{tr.archivePath + ".1", tr.archivePath} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+		for scanner.Scan() {
+			var t Task
+			if json.Unmarshal(scanner.Bytes(), &t) != nil {
+				continue
+			}
+			if !fn(&t) {
+				f.Close()
+				return
+			}
+		}
+		f.Close()
+	}
+}
+
+// retainedLocked compte les tâches terminées soumises au plafond
+// TASK_MAX_RETAINED; fc.mu doit être détenu
+func (fc *FogCompute) retainedLocked() int {
+	n := 0
+	for _, t := range fc.tasks {
+		if t.CompletedAt != nil && !isDeadLetter(t) {
+			n++
+		}
+	}
+	return n
+}
+
+// Stats retourne l'état de la rétention; retained est compté par l'appelant
+func (tr *TaskRetention) Stats(retained int) RetentionStats {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var lastRun *time.Time
+	if !tr.lastRun.IsZero() {
+		last := tr.lastRun
+		lastRun = &last
+	}
+	return RetentionStats{
+		Retained:    retained,
+		MaxRetained: tr.MaxRetained,
+		Collected:   tr.collected,
+		Archived:    tr.archived,
+		Archive:     tr.archivePath != "",
+		LastRun:     lastRun,
+	}
+}
+
+// handleListTasks liste les tâches du namespace du client, des plus récentes
+// aux plus anciennes: celles en mémoire, ou avec ?archived=true celles que la
+// rétention a archivées. Filtres ?status= et ?type=, ?limit= (défaut 100, au
+// plus 1000).
+func (fc *FogCompute) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status, taskType := q.Get("status"), q.Get("type")
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit doit être un entier positif", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, 1000)
+	}
+	archived := q.Get("archived") == "true"
+	if archived && fc.retention.archivePath == "" {
+		http.Error(w, "Archive des tâches désactivée (TASK_ARCHIVE)", http.StatusNotFound)
+		return
+	}
+	tenant, all := fc.requestTenant(r)
+	match := func(t *Task) bool {
+		return visibleTo(t, tenant, all) && (status == "" || t.Status == status) && (taskType == "" || t.Type == taskType)
+	}
+
+	tasks := make([]Task, 0)
+	total := 0
+	if archived {
+		fc.retention.scanArchive(func(t *Task) bool {
+			if match(t) {
+				total++
+				tasks = append(tasks, *t)
+				if len(tasks) > limit {
+					tasks = tasks[1:] // Garder les plus récentes
+				}
+			}
+			return true
+		})
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+		}
+	} else {
+		fc.mu.RLock()
+		for _, t := range fc.tasks {
+			if match(t) {
+				tasks = append(tasks, *t)
+			}
+		}
+		fc.mu.RUnlock()
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].SubmittedAt.After(tasks[j].SubmittedAt) })
+		total = len(tasks)
+	}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    total,
+		"archived": archived,
+		"tasks":    tasks,
+	})
+}
+
+// archivedTask retrouve dans l'archive une tâche oubliée par la rétention
+func (fc *FogCompute) archivedTask(id string) (*Task, bool) {
+	var found *Task
+	fc.retention.scanArchive(func(t *Task) bool {
+		if t.ID == id {
+			found = t // La dernière occurrence l'emporte
+		}
+		return true
+	})
+	return found, found != nil
+}
@@ -0,0 +1,92 @@
+package fognode
+
+import (
+	"log/slog"
+	"math"
+	"time"
+)
+
+// RetryPolicy décrit les réessais automatiques d'une tâche échouée ou expirée
+type RetryPolicy struct {
+	MaxAttempts  int     `json:"max_attempts"`             // Nombre total de tentatives, première incluse
+	BackoffMs    int64   `json:"backoff_ms,omitempty"`     // Délai avant le premier réessai
+	MaxBackoffMs int64   `json:"max_backoff_ms,omitempty"` // Plafond du délai
+	Multiplier   float64 `json:"multiplier,omitempty"`     // Croissance du délai entre réessais (2 par défaut)
+}
+
+// defaultRetryPolicy est appliquée aux tâches qui n'en déclarent pas
+// (RETRY_DEFAULT_MAX_ATTEMPTS, RETRY_DEFAULT_BACKOFF, RETRY_MAX_BACKOFF)
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  getEnvInt("RETRY_DEFAULT_MAX_ATTEMPTS", 1),
+		BackoffMs:    getEnvDuration("RETRY_DEFAULT_BACKOFF", time.Second).Milliseconds(),
+		MaxBackoffMs: getEnvDuration("RETRY_MAX_BACKOFF", time.Minute).Milliseconds(),
+		Multiplier:   2,
+	}
+}
+
+// delay retourne l'attente avant la tentative suivante, après `failures` échecs
+func (p RetryPolicy) delay(failures int) time.Duration {
+	backoff := float64(p.BackoffMs)
+	if backoff <= 0 {
+		backoff = 1000
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	d := backoff * math.Pow(multiplier, float64(failures-1))
+	if p.MaxBackoffMs > 0 && d > float64(p.MaxBackoffMs) {
+		d = float64(p.MaxBackoffMs)
+	}
+	return time.Duration(d) * time.Millisecond
+}
+
+// retryPolicy retourne la politique de réessai effective d'une tâche
+func (fc *FogCompute) retryPolicy(task *Task) RetryPolicy {
+	if task.Retry != nil {
+		return *task.Retry
+	}
+	return fc.defaultRetry
+}
+
+// planRetryLocked décide si une tâche échouée doit être réessayée et, le cas
+// échéant, la marque en attente de réessai; fc.mu doit être détenu
+func (fc *FogCompute) planRetryLocked(task *Task) (time.Duration, bool) {
+	policy := fc.retryPolicy(task)
+	if task.Attempts >= policy.MaxAttempts {
+		return 0, false
+	}
+	delay := policy.delay(task.Attempts)
+	next := time.Now().Add(delay)
+	task.Status = "retry_scheduled"
+	task.NextAttemptAt = &next
+	return delay, true
+}
+
+// requeueRetry remet en queue une tâche dont le délai de réessai est écoulé.
+// Si les ressources manquent encore, le réessai est reporté du même délai.
+func (fc *FogCompute) requeueRetry(task *Task, delay time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if task.Status != "retry_scheduled" {
+		return
+	}
+	if task.CPUCost > fc.availableCPU || task.RAMCost > fc.availableRAM || task.StorageCost > fc.availableStorage {
+		next := time.Now().Add(delay)
+		task.NextAttemptAt = &next
+		time.AfterFunc(delay, func() { fc.requeueRetry(task, delay) })
+		return
+	}
+
+	task.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceRetry, RetryOf: task.ID})
+	task.Status = "queued"
+	task.NextAttemptAt = nil
+	task.Result = nil
+	task.SmartScore = task.calculateScore()
+	task.QueueClass = admissionClass(task)
+	fc.enqueueLocked(task)
+
+	slog.Info("Réessai automatique de la tâche", "task_id", task.ID, "attempt", task.Attempts+1)
+}
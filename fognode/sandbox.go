@@ -0,0 +1,236 @@
+package fognode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// seccompUnconfined désactive explicitement le filtre seccomp
+const seccompUnconfined = "unconfined"
+
+// errSeccompSubprocess signale qu'un profil seccomp ne peut pas être chargé
+// pour un simple sous-processus: il faut un exécuteur conteneur
+var errSeccompSubprocess = errors.New("profil seccomp non applicable à un sous-processus: utiliser un exécuteur conteneur")
+
+// SandboxPolicy décrit le confinement des sous-processus et conteneurs d'un type
+// de tâche. Les charges fournies par les utilisateurs tournent sur une passerelle
+// à cheval entre réseaux OT et IT: elles doivent être confinées.
+type SandboxPolicy struct {
+	Seccomp         string `json:"seccomp,omitempty"`           // Profil seccomp au format OCI (chemin), ou "unconfined"
+	AppArmor        string `json:"apparmor,omitempty"`          // Profil AppArmor chargé dans le noyau
+	SELinux         string `json:"selinux,omitempty"`           // Contexte SELinux (user:role:type:level)
+	NoNewPrivileges bool   `json:"no_new_privileges,omitempty"` // Interdire l'élévation de privilèges (setuid, capabilities)
+}
+
+// SandboxPolicies associe une politique à chaque type de tâche
+type SandboxPolicies struct {
+	Default  SandboxPolicy            `json:"default"`
+	Types    map[string]SandboxPolicy `json:"types,omitempty"`
+	Required bool                     `json:"required"` // Refuser l'exécution si la politique ne peut être appliquée
+}
+
+// loadSandboxPolicies lit SANDBOX_CONFIG (fichier JSON) ou, à défaut, la
+// politique par défaut depuis SANDBOX_SECCOMP, SANDBOX_APPARMOR, SANDBOX_SELINUX
+// et SANDBOX_NO_NEW_PRIVS. Dès qu'une politique est configurée, l'exécution
+// est refusée si elle ne peut pas être appliquée, sauf SANDBOX_REQUIRED=false.
+func loadSandboxPolicies() SandboxPolicies {
+	var sp SandboxPolicies
+	if path := getEnv("SANDBOX_CONFIG", ""); path != "" {
+		if err := readJSONFile(path, &sp); err != nil {
+			slog.Error("SANDBOX_CONFIG illisible: aucun confinement configuré", "error", err)
+			sp = SandboxPolicies{}
+		}
+	} else {
+		sp.Default = SandboxPolicy{
+			Seccomp:         getEnv("SANDBOX_SECCOMP", ""),
+			AppArmor:        getEnv("SANDBOX_APPARMOR", ""),
+			SELinux:         getEnv("SANDBOX_SELINUX", ""),
+			NoNewPrivileges: getEnvBool("SANDBOX_NO_NEW_PRIVS", false),
+		}
+	}
+	sp.Required = getEnvBool("SANDBOX_REQUIRED", sp.configured())
+
+	for name, p := range sp.all() {
+		if err := p.validate(); err != nil {
+			slog.Warn("Politique de confinement invalide", "policy", name, "error", err)
+		}
+	}
+	return sp
+}
+
+// all retourne chaque politique configurée avec son nom
+func (sp SandboxPolicies) all() map[string]SandboxPolicy {
+	policies := map[string]SandboxPolicy{"default": sp.Default}
+	for t, p := range sp.Types {
+		policies[t] = p
+	}
+	return policies
+}
+
+// configured indique si au moins une politique impose un confinement
+func (sp SandboxPolicies) configured() bool {
+	for _, p := range sp.all() {
+		if !p.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// empty indique que la politique n'impose rien
+func (p SandboxPolicy) empty() bool {
+	return (p.Seccomp == "" || p.Seccomp == seccompUnconfined) && p.AppArmor == "" && p.SELinux == "" && !p.NoNewPrivileges
+}
+
+// validate vérifie que les profils référencés existent
+func (p SandboxPolicy) validate() error {
+	if p.Seccomp != "" && p.Seccomp != seccompUnconfined {
+		data, err := os.ReadFile(p.Seccomp)
+		if err != nil {
+			return fmt.Errorf("profil seccomp: %w", err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("profil seccomp %s: JSON invalide", p.Seccomp)
+		}
+	}
+	if p.AppArmor != "" {
+		if profiles, err := os.ReadFile("/sys/kernel/security/apparmor/profiles"); err == nil &&
+			!strings.Contains(string(profiles), p.AppArmor+" (") {
+			return fmt.Errorf("profil AppArmor %q non chargé", p.AppArmor)
+		}
+	}
+	if p.SELinux != "" && len(strings.Split(p.SELinux, ":")) < 3 {
+		return fmt.Errorf("contexte SELinux %q invalide (user:role:type[:level])", p.SELinux)
+	}
+	return nil
+}
+
+// For retourne la politique d'un type de tâche: ses champs complètent ceux de la politique par défaut
+func (sp SandboxPolicies) For(taskType string) SandboxPolicy {
+	p := sp.Default
+	t, ok := sp.Types[taskType]
+	if !ok {
+		return p
+	}
+	if t.Seccomp != "" {
+		p.Seccomp = t.Seccomp
+	}
+	if t.AppArmor != "" {
+		p.AppArmor = t.AppArmor
+	}
+	if t.SELinux != "" {
+		p.SELinux = t.SELinux
+	}
+	p.NoNewPrivileges = p.NoNewPrivileges || t.NoNewPrivileges
+	return p
+}
+
+// ContainerSecurityOpts retourne les options --security-opt d'un runtime
+// compatible Docker/Podman pour appliquer la politique à un conteneur
+func (p SandboxPolicy) ContainerSecurityOpts() []string {
+	opts := make([]string, 0)
+	if p.Seccomp != "" {
+		opts = append(opts, "--security-opt", "seccomp="+p.Seccomp)
+	}
+	if p.AppArmor != "" {
+		opts = append(opts, "--security-opt", "apparmor="+p.AppArmor)
+	}
+	if p.SELinux != "" {
+		fields := strings.SplitN(p.SELinux, ":", 4)
+		for i, key := range []string{"user", "role", "type", "level"} {
+			if i < len(fields) && fields[i] != "" {
+				opts = append(opts, "--security-opt", "label="+key+":"+fields[i])
+			}
+		}
+	}
+	if p.NoNewPrivileges {
+		opts = append(opts, "--security-opt", "no-new-privileges")
+	}
+	return opts
+}
+
+// wrap préfixe la commande par un lanceur qui applique le confinement puis
+// exécute (exec) la commande d'origine dans le même processus
+func wrap(cmd *exec.Cmd, launcher string, args ...string) error {
+	path, err := exec.LookPath(launcher)
+	if err != nil {
+		return fmt.Errorf("%s introuvable: %w", launcher, err)
+	}
+	wrapped := append([]string{launcher}, args...)
+	wrapped = append(wrapped, cmd.Path)
+	if len(cmd.Args) > 1 {
+		wrapped = append(wrapped, cmd.Args[1:]...)
+	}
+	cmd.Path = path
+	cmd.Args = wrapped
+	return nil
+}
+
+// WrapCommand applique la politique à un sous-processus via setpriv, aa-exec
+// et runcon. Un profil seccomp exige un exécuteur conteneur.
+func (p SandboxPolicy) WrapCommand(cmd *exec.Cmd) (err error) {
+	if p.Seccomp != "" && p.Seccomp != seccompUnconfined {
+		return errSeccompSubprocess
+	}
+	// Pas de confinement partiel: la commande est restaurée si un lanceur manque
+	path, args := cmd.Path, cmd.Args
+	defer func() {
+		if err != nil {
+			cmd.Path, cmd.Args = path, args
+		}
+	}()
+
+	// Chaque lanceur enveloppe les précédents: runcon, puis aa-exec, puis setpriv
+	// s'exécutent avant la commande, no_new_privs n'étant posé qu'en dernier
+	if p.NoNewPrivileges {
+		if err := wrap(cmd, "setpriv", "--no-new-privs"); err != nil {
+			return err
+		}
+	}
+	if p.AppArmor != "" {
+		if err := wrap(cmd, "aa-exec", "-p", p.AppArmor, "--"); err != nil {
+			return err
+		}
+	}
+	if p.SELinux != "" {
+		if err := wrap(cmd, "runcon", p.SELinux); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prepare applique la politique du type de tâche à un sous-processus avant son
+// démarrage. Si elle ne peut pas l'être, l'exécution est refusée quand le
+// confinement est requis, sinon le sous-processus tourne non confiné.
+func (sp SandboxPolicies) Prepare(taskType string, cmd *exec.Cmd) error {
+	p := sp.For(taskType)
+	if p.empty() {
+		return nil
+	}
+	if err := p.WrapCommand(cmd); err != nil {
+		if sp.Required {
+			return fmt.Errorf("confinement impossible pour %s: %w", taskType, err)
+		}
+		slog.Warn("Confinement impossible: exécution non confinée", "type", taskType, "error", err)
+	}
+	return nil
+}
+
+// startSubprocess démarre le sous-processus d'une tâche sous sa politique de
+// confinement et les limites d'exécution du nœud
+func (fc *FogCompute) startSubprocess(taskType string, cmd *exec.Cmd) error {
+	if err := fc.sandbox.Prepare(taskType, cmd); err != nil {
+		return err
+	}
+	limits := fc.procLimits
+	if cpus := fc.energyWorkers.CPUs(); cpus != nil {
+		limits.CPUs = cpus // Basse énergie: travail concentré sur moins de cœurs
+	}
+	return limits.Start(cmd)
+}
@@ -0,0 +1,236 @@
+package fognode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// schedulerTraceContenders est le nombre de tâches concurrentes retenues par décision
+const schedulerTraceContenders = 3
+
+// Issues d'une décision du scheduler
+const (
+	DecisionRun      = "run"      // Tâche exécutée
+	DecisionExpired  = "expired"  // Échéance dépassée, abandonnée sans exécution
+	DecisionDeferred = "deferred" // Reportée après une fenêtre de capacité planifiée
+)
+
+// TraceTask est l'état d'une tâche au moment d'une décision du scheduler
+type TraceTask struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	Client      string     `json:"client,omitempty"` // Clé de lissage (tourniquet fairshare)
+	Priority    int        `json:"priority"`
+	Criticality int        `json:"criticality"`
+	SmartScore  float64    `json:"smart_score"`
+	QueueClass  string     `json:"queue_class,omitempty"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	QueuedAt    time.Time  `json:"queued_at"`
+	WaitMs      int64      `json:"wait_ms"`
+}
+
+// TraceResources est l'état des ressources du nœud au moment d'une décision
+type TraceResources struct {
+	Load             float64 `json:"load"`
+	InFlight         int     `json:"in_flight"`
+	AvailableCPU     float64 `json:"available_cpu"`
+	AvailableRAM     float64 `json:"available_ram"`
+	AvailableStorage float64 `json:"available_storage"`
+	EnergyLevel      float64 `json:"energy_level"`
+}
+
+// SchedulerDecision est un retrait de la queue par un worker: la tâche
+// choisie et celles qui la suivaient, pour expliquer l'ordre d'exécution
+type SchedulerDecision struct {
+	Seq        uint64         `json:"seq"`
+	At         time.Time      `json:"at"`
+	WorkerID   int            `json:"worker_id"`
+	Reserved   bool           `json:"reserved_critical"`
+	Scheduler  string         `json:"scheduler"`
+	Outcome    string         `json:"outcome"`
+	QueueLen   int            `json:"queue_len"` // Tâches restantes après le retrait
+	Chosen     TraceTask      `json:"chosen"`
+	Contenders []TraceTask    `json:"contenders"` // Prochaines tâches dans l'ordre du scheduler
+	Resources  TraceResources `json:"resources"`
+}
+
+// SchedulerTraceResponse est la réponse de GET /admin/scheduler-trace
+type SchedulerTraceResponse struct {
+	Enabled   bool                `json:"enabled"`
+	Size      int                 `json:"size"`
+	Recorded  uint64              `json:"recorded"` // Décisions enregistrées depuis le démarrage
+	Decisions []SchedulerDecision `json:"decisions"`
+}
+
+// SchedulerTrace conserve les dernières décisions du scheduler dans un
+// tampon circulaire. Désactivée par défaut: lister les concurrents trie la
+// queue à chaque retrait.
+type SchedulerTrace struct {
+	enabled bool
+	size    int
+	ring    []SchedulerDecision
+	seq     uint64
+	mu      sync.Mutex
+}
+
+// NewSchedulerTrace lit SCHEDULER_TRACE et SCHEDULER_TRACE_SIZE
+func NewSchedulerTrace() *SchedulerTrace {
+	st := &SchedulerTrace{
+		enabled: getEnvBool("SCHEDULER_TRACE", false),
+		size:    getEnvInt("SCHEDULER_TRACE_SIZE", 1000),
+	}
+	if st.size <= 0 {
+		st.size = 1000
+	}
+	return st
+}
+
+// Enabled indique si les décisions sont enregistrées
+func (st *SchedulerTrace) Enabled() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.enabled
+}
+
+// SetEnabled active ou désactive l'enregistrement
+func (st *SchedulerTrace) SetEnabled(enabled bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.enabled = enabled
+}
+
+// record ajoute une décision au tampon
+func (st *SchedulerTrace) record(d SchedulerDecision) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.seq++
+	d.Seq = st.seq
+	if len(st.ring) < st.size {
+		st.ring = append(st.ring, d)
+		return
+	}
+	st.ring[(st.seq-1)%uint64(st.size)] = d
+}
+
+// decisions retourne les décisions du tampon, de la plus ancienne à la plus
+// récente, limitées à celles où figure taskID s'il est fourni
+func (st *SchedulerTrace) decisions(taskID string) []SchedulerDecision {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	decisions := make([]SchedulerDecision, 0, len(st.ring))
+	start := 0
+	if len(st.ring) == st.size {
+		start = int(st.seq % uint64(st.size))
+	}
+	for i := range st.ring {
+		d := st.ring[(start+i)%len(st.ring)]
+		if taskID == "" || d.involves(taskID) {
+			decisions = append(decisions, d)
+		}
+	}
+	return decisions
+}
+
+// involves indique si la tâche a été choisie ou écartée par la décision
+func (d *SchedulerDecision) involves(taskID string) bool {
+	if d.Chosen.ID == taskID {
+		return true
+	}
+	for _, c := range d.Contenders {
+		if c.ID == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// traceTask décrit une tâche en queue
+func traceTask(task *Task, now time.Time) TraceTask {
+	return TraceTask{
+		ID:          task.ID,
+		Type:        task.Type,
+		Client:      task.shapingKey,
+		Priority:    task.Priority,
+		Criticality: task.Criticality,
+		SmartScore:  task.SmartScore,
+		QueueClass:  task.QueueClass,
+		Deadline:    task.Deadline,
+		QueuedAt:    task.queuedAt,
+		WaitMs:      now.Sub(task.queuedAt).Milliseconds(),
+	}
+}
+
+// traceDecisionLocked enregistre le retrait d'une tâche par un worker, avec
+// les tâches qui la suivent; fc.mu doit être détenu
+func (fc *FogCompute) traceDecisionLocked(workerID int, reserved bool, task *Task, expired, deferred bool) {
+	if !fc.schedTrace.Enabled() {
+		return
+	}
+	outcome := DecisionRun
+	switch {
+	case expired:
+		outcome = DecisionExpired
+	case deferred:
+		outcome = DecisionDeferred
+	}
+	now := time.Now()
+	contenders := fc.peekFor(reserved, schedulerTraceContenders)
+	d := SchedulerDecision{
+		At:         now,
+		WorkerID:   workerID,
+		Reserved:   reserved,
+		Scheduler:  fc.scheduler.Name(),
+		Outcome:    outcome,
+		QueueLen:   fc.queuedFor(reserved),
+		Chosen:     traceTask(task, now),
+		Contenders: make([]TraceTask, 0, len(contenders)),
+		Resources: TraceResources{
+			Load:             fc.node.Load,
+			InFlight:         fc.inFlight,
+			AvailableCPU:     fc.availableCPU,
+			AvailableRAM:     fc.availableRAM,
+			AvailableStorage: fc.availableStorage,
+			EnergyLevel:      fc.energyLevel,
+		},
+	}
+	for _, c := range contenders {
+		d.Contenders = append(d.Contenders, traceTask(c, now))
+	}
+	fc.schedTrace.record(d)
+}
+
+// handleGetSchedulerTrace retourne les dernières décisions du scheduler
+// (?task_id= pour celles où la tâche a été choisie ou écartée, ?limit= pour
+// les plus récentes)
+func (fc *FogCompute) handleGetSchedulerTrace(w http.ResponseWriter, r *http.Request) {
+	st := fc.schedTrace
+	decisions := st.decisions(r.URL.Query().Get("task_id"))
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(decisions) {
+		decisions = decisions[len(decisions)-limit:]
+	}
+
+	st.mu.Lock()
+	response := SchedulerTraceResponse{Enabled: st.enabled, Size: st.size, Recorded: st.seq, Decisions: decisions}
+	st.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSetSchedulerTrace active ou désactive la trace ({"enabled": true})
+func (fc *FogCompute) handleSetSchedulerTrace(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, "Champ enabled requis", http.StatusBadRequest)
+		return
+	}
+	fc.schedTrace.SetEnabled(*body.Enabled)
+	slog.Info("Trace du scheduler modifiée", "enabled", *body.Enabled, "client", requestClient(r))
+	fc.handleGetSchedulerTrace(w, r)
+}
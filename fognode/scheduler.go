@@ -0,0 +1,346 @@
+package fognode
+
+import (
+	"container/heap"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// Scheduler décide de l'ordre d'exécution des tâches en queue.
+// Les méthodes sont appelées avec fc.mu détenu: les implémentations n'ont
+// pas besoin de leur propre verrou.
+type Scheduler interface {
+	// Name retourne le nom de la politique (valeur de SCHEDULER)
+	Name() string
+	// Enqueue ajoute une tâche admise
+	Enqueue(task *Task)
+	// Next retire et retourne la prochaine tâche à exécuter, ou nil si la queue est vide
+	Next() *Task
+	// Rescore recalcule l'ordre après un changement des critères de planification,
+	// et périodiquement pour le vieillissement des tâches en attente
+	Rescore()
+	// Len retourne le nombre de tâches en queue
+	Len() int
+}
+
+// schedulerPeeker est implémenté par les politiques capables de lister, sans
+// les retirer, les prochaines tâches qu'elles choisiraient (trace du scheduler)
+type schedulerPeeker interface {
+	// Peek retourne au plus n tâches, dans l'ordre où Next les retournerait
+	Peek(n int) []*Task
+}
+
+// peekHeap retourne les n premières tâches d'un tas, dans son ordre
+func peekHeap(h *TaskHeap, n int) []*Task {
+	items := append([]*Task(nil), h.items...)
+	sort.SliceStable(items, func(i, j int) bool { return h.less(items[i], items[j]) })
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// schedulerFactories associe chaque nom de politique à son constructeur
+var schedulerFactories = map[string]func() Scheduler{
+	"smartscore": func() Scheduler { return newSmartScoreScheduler() },
+	"fifo":       func() Scheduler { return &fifoScheduler{} },
+	"fairshare":  func() Scheduler { return newFairShareScheduler() },
+	"edf":        func() Scheduler { return &edfScheduler{heap: NewTaskHeap(byDeadline)} },
+}
+
+// newScheduler crée la politique de planification demandée
+func newScheduler(name string) (Scheduler, error) {
+	factory, ok := schedulerFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("politique de planification inconnue %q (disponibles: %v)", name, schedulerNames())
+	}
+	return factory(), nil
+}
+
+// schedulerNames liste les politiques disponibles
+func schedulerNames() []string {
+	names := make([]string, 0, len(schedulerFactories))
+	for name := range schedulerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadScheduler crée la politique configurée via SCHEDULER (smartscore par défaut)
+func loadScheduler() Scheduler {
+	s, err := newScheduler(getEnv("SCHEDULER", "smartscore"))
+	if err != nil {
+		slog.Warn("Politique de planification invalide, utilisation de smartscore", "error", err)
+		s = newSmartScoreScheduler()
+	}
+	return s
+}
+
+// smartScoreScheduler exécute en premier la tâche de plus petit SmartScore
+type smartScoreScheduler struct {
+	heap *TaskHeap
+}
+
+func newSmartScoreScheduler() *smartScoreScheduler {
+	return &smartScoreScheduler{heap: NewTaskHeap(bySmartScore)}
+}
+
+// bySmartScore ordonne par score intelligent croissant
+func bySmartScore(a, b *Task) bool {
+	return a.SmartScore < b.SmartScore
+}
+
+func (s *smartScoreScheduler) Name() string       { return "smartscore" }
+func (s *smartScoreScheduler) Enqueue(task *Task) { heap.Push(s.heap, task) }
+func (s *smartScoreScheduler) Len() int           { return s.heap.Len() }
+
+func (s *smartScoreScheduler) Next() *Task {
+	if s.heap.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(s.heap).(*Task)
+}
+
+func (s *smartScoreScheduler) Peek(n int) []*Task { return peekHeap(s.heap, n) }
+
+func (s *smartScoreScheduler) Rescore() {
+	for _, t := range s.heap.items {
+		t.SmartScore = t.agedScore()
+	}
+	heap.Init(s.heap)
+}
+
+// fifoScheduler exécute les tâches dans leur ordre d'arrivée
+type fifoScheduler struct {
+	queue []*Task
+}
+
+func (s *fifoScheduler) Name() string       { return "fifo" }
+func (s *fifoScheduler) Enqueue(task *Task) { s.queue = append(s.queue, task) }
+func (s *fifoScheduler) Len() int           { return len(s.queue) }
+func (s *fifoScheduler) Rescore()           {}
+
+func (s *fifoScheduler) Next() *Task {
+	if len(s.queue) == 0 {
+		return nil
+	}
+	task := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	return task
+}
+
+func (s *fifoScheduler) Peek(n int) []*Task {
+	if len(s.queue) < n {
+		n = len(s.queue)
+	}
+	return append([]*Task(nil), s.queue[:n]...)
+}
+
+// fairShareScheduler alterne entre appareils sources (round-robin) et, pour
+// chaque appareil, exécute d'abord la tâche de plus petit SmartScore
+type fairShareScheduler struct {
+	queues map[string]*TaskHeap
+	order  []string // Appareils ayant des tâches, dans l'ordre de passage
+	next   int
+	size   int
+}
+
+func newFairShareScheduler() *fairShareScheduler {
+	return &fairShareScheduler{queues: make(map[string]*TaskHeap)}
+}
+
+func (s *fairShareScheduler) Name() string { return "fairshare" }
+func (s *fairShareScheduler) Len() int     { return s.size }
+
+func (s *fairShareScheduler) Enqueue(task *Task) {
+	q, ok := s.queues[task.shapingKey]
+	if !ok {
+		q = NewTaskHeap(bySmartScore)
+		s.queues[task.shapingKey] = q
+		s.order = append(s.order, task.shapingKey)
+	}
+	heap.Push(q, task)
+	s.size++
+}
+
+func (s *fairShareScheduler) Next() *Task {
+	if s.size == 0 {
+		return nil
+	}
+	if s.next >= len(s.order) {
+		s.next = 0
+	}
+	key := s.order[s.next]
+	q := s.queues[key]
+	task := heap.Pop(q).(*Task)
+	s.size--
+
+	if q.Len() == 0 {
+		// Retirer l'appareil épuisé; l'appareil suivant prend sa place dans l'ordre
+		delete(s.queues, key)
+		s.order = append(s.order[:s.next], s.order[s.next+1:]...)
+	} else {
+		s.next++
+	}
+	return task
+}
+
+// Peek suit le tourniquet à partir de l'appareil suivant
+func (s *fairShareScheduler) Peek(n int) []*Task {
+	lists := make([][]*Task, len(s.order))
+	for i := range s.order {
+		key := s.order[(s.next+i)%len(s.order)]
+		lists[i] = peekHeap(s.queues[key], n)
+	}
+	var tasks []*Task
+	for round := 0; len(tasks) < n; round++ {
+		added := false
+		for _, list := range lists {
+			if round < len(list) && len(tasks) < n {
+				tasks = append(tasks, list[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return tasks
+}
+
+func (s *fairShareScheduler) Rescore() {
+	for _, q := range s.queues {
+		for _, t := range q.items {
+			t.SmartScore = t.agedScore()
+		}
+		heap.Init(q)
+	}
+}
+
+// edfScheduler (Earliest Deadline First) exécute d'abord la tâche dont
+// l'échéance est la plus proche; les tâches sans échéance passent ensuite,
+// par SmartScore
+type edfScheduler struct {
+	heap *TaskHeap
+}
+
+// byDeadline ordonne par échéance croissante, puis par SmartScore
+func byDeadline(a, b *Task) bool {
+	switch {
+	case a.Deadline != nil && b.Deadline != nil:
+		if !a.Deadline.Equal(*b.Deadline) {
+			return a.Deadline.Before(*b.Deadline)
+		}
+	case a.Deadline != nil:
+		return true
+	case b.Deadline != nil:
+		return false
+	}
+	return a.SmartScore < b.SmartScore
+}
+
+func (s *edfScheduler) Name() string       { return "edf" }
+func (s *edfScheduler) Enqueue(task *Task) { heap.Push(s.heap, task) }
+func (s *edfScheduler) Len() int           { return s.heap.Len() }
+
+func (s *edfScheduler) Next() *Task {
+	if s.heap.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(s.heap).(*Task)
+}
+
+func (s *edfScheduler) Peek(n int) []*Task { return peekHeap(s.heap, n) }
+
+func (s *edfScheduler) Rescore() {
+	for _, t := range s.heap.items {
+		t.SmartScore = t.agedScore()
+	}
+	heap.Init(s.heap)
+}
+
+// criticalLane isole les tâches critiques (classe critical, criticité >= 4)
+// dans une queue dédiée, ordonnée par la même politique. Les workers réservés
+// ne servent que cette queue; les autres workers la servent en priorité.
+type criticalLane struct {
+	Scheduler           // Tâches non critiques
+	critical  Scheduler // Tâches critiques
+}
+
+// withCriticalLane ajoute une queue critique à la politique quand des workers
+// sont réservés aux tâches critiques
+func withCriticalLane(s Scheduler, reserved int) Scheduler {
+	if reserved <= 0 {
+		return s
+	}
+	critical, err := newScheduler(s.Name())
+	if err != nil {
+		critical = newSmartScoreScheduler()
+	}
+	return &criticalLane{Scheduler: s, critical: critical}
+}
+
+func (l *criticalLane) Enqueue(task *Task) {
+	if admissionClass(task) == QueueClassCritical {
+		l.critical.Enqueue(task)
+		return
+	}
+	l.Scheduler.Enqueue(task)
+}
+
+func (l *criticalLane) Next() *Task {
+	if l.critical.Len() > 0 {
+		return l.critical.Next()
+	}
+	return l.Scheduler.Next()
+}
+
+func (l *criticalLane) Peek(n int) []*Task {
+	tasks := peekScheduler(l.critical, n)
+	return append(tasks, peekScheduler(l.Scheduler, n-len(tasks))...)
+}
+
+func (l *criticalLane) Rescore() {
+	l.critical.Rescore()
+	l.Scheduler.Rescore()
+}
+
+func (l *criticalLane) Len() int {
+	return l.critical.Len() + l.Scheduler.Len()
+}
+
+// queuedFor retourne le nombre de tâches qu'un worker peut prendre; fc.mu doit être détenu
+func (fc *FogCompute) queuedFor(reserved bool) int {
+	if lane, ok := fc.scheduler.(*criticalLane); ok && reserved {
+		return lane.critical.Len()
+	}
+	return fc.scheduler.Len()
+}
+
+// peekScheduler retourne les n prochaines tâches d'une politique, aucune si
+// elle ne sait pas les lister
+func peekScheduler(s Scheduler, n int) []*Task {
+	if p, ok := s.(schedulerPeeker); ok && n > 0 {
+		return p.Peek(n)
+	}
+	return nil
+}
+
+// peekFor retourne les n prochaines tâches qu'un worker pourrait prendre; fc.mu doit être détenu
+func (fc *FogCompute) peekFor(reserved bool, n int) []*Task {
+	if lane, ok := fc.scheduler.(*criticalLane); ok && reserved {
+		return peekScheduler(lane.critical, n)
+	}
+	return peekScheduler(fc.scheduler, n)
+}
+
+// nextFor retire la prochaine tâche qu'un worker peut prendre; fc.mu doit être détenu
+func (fc *FogCompute) nextFor(reserved bool) *Task {
+	if lane, ok := fc.scheduler.(*criticalLane); ok && reserved {
+		return lane.critical.Next()
+	}
+	return fc.scheduler.Next()
+}
@@ -0,0 +1,402 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// minScheduleInterval borne la fréquence d'une planification par intervalle
+const minScheduleInterval = time.Second
+
+// TaskSchedule est une tâche récurrente: à chaque échéance, son modèle est
+// soumis comme une nouvelle tâche, par la même chaîne d'admission que POST /tasks
+type TaskSchedule struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name,omitempty"`
+	Cron       string     `json:"cron,omitempty"`     // Expression cron à cinq champs, dans le fuseau du nœud
+	Interval   string     `json:"interval,omitempty"` // Ou période fixe ("30s", "5m")
+	Template   Task       `json:"task"`
+	Paused     bool       `json:"paused"`
+	NextRun    *time.Time `json:"next_run,omitempty"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	LastTaskID string     `json:"last_task_id,omitempty"`
+	LastError  string     `json:"last_error,omitempty"` // Motif du refus de la dernière tâche, vide si elle a été admise
+	Runs       int64      `json:"runs"`
+	Rejected   int64      `json:"rejected"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	cron  *cronSpec
+	every time.Duration
+}
+
+// next calcule l'échéance qui suit after
+func (s *TaskSchedule) next(after time.Time) *time.Time {
+	var t time.Time
+	if s.cron != nil {
+		t = s.cron.Next(after)
+	} else {
+		t = after.Add(s.every)
+	}
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// compile analyse la récurrence de la planification
+func (s *TaskSchedule) compile() error {
+	switch {
+	case s.Cron != "" && s.Interval != "":
+		return errors.New("cron et interval sont exclusifs")
+	case s.Cron != "":
+		spec, err := parseCron(s.Cron)
+		if err != nil {
+			return err
+		}
+		s.cron = spec
+	case s.Interval != "":
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil {
+			return fmt.Errorf("interval invalide: %v", err)
+		}
+		if d < minScheduleInterval {
+			return fmt.Errorf("interval inférieur à %s", minScheduleInterval)
+		}
+		s.every = d
+	default:
+		return errors.New("cron ou interval requis")
+	}
+	return nil
+}
+
+// TaskSchedules tient les tâches récurrentes, persistées dans
+// DATA_DIR/schedules.json. Les échéances manquées pendant un arrêt ne sont
+// pas rattrapées: la prochaine est recalculée au démarrage.
+type TaskSchedules struct {
+	path      string
+	schedules []*TaskSchedule // Par ordre de création
+	mu        sync.Mutex
+}
+
+// NewTaskSchedules recharge les planifications persistées
+func NewTaskSchedules() *TaskSchedules {
+	ts := &TaskSchedules{path: filepath.Join(getEnv("DATA_DIR", "data"), "schedules.json")}
+	if err := readJSONFile(ts.path, &ts.schedules); err != nil && !os.IsNotExist(err) {
+		slog.Error("Lecture des planifications impossible", "path", ts.path, "error", err)
+	}
+	now := time.Now()
+	kept := ts.schedules[:0]
+	for _, s := range ts.schedules {
+		if err := s.compile(); err != nil {
+			slog.Error("Planification persistée invalide ignorée", "schedule_id", s.ID, "error", err)
+			continue
+		}
+		s.NextRun = s.next(now)
+		kept = append(kept, s)
+	}
+	ts.schedules = kept
+	return ts
+}
+
+// Add enregistre une planification
+func (ts *TaskSchedules) Add(s *TaskSchedule) error {
+	if err := s.compile(); err != nil {
+		return err
+	}
+	if len(s.Template.DependsOn) > 0 {
+		return errors.New("une tâche récurrente ne peut pas avoir de dépendances")
+	}
+	s.CreatedAt = time.Now()
+	s.ID = "sched-" + strconv.FormatInt(s.CreatedAt.UnixNano(), 10)
+	s.Template.ID = ""
+	if s.NextRun = s.next(s.CreatedAt); s.NextRun == nil {
+		return errors.New("l'expression cron ne correspond à aucune date")
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.schedules = append(ts.schedules, s)
+	ts.saveLocked()
+	return nil
+}
+
+// Get retourne une copie d'une planification
+func (ts *TaskSchedules) Get(id string) (TaskSchedule, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, s := range ts.schedules {
+		if s.ID == id {
+			return *s, true
+		}
+	}
+	return TaskSchedule{}, false
+}
+
+// List retourne une copie des planifications
+func (ts *TaskSchedules) List() []TaskSchedule {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	list := make([]TaskSchedule, 0, len(ts.schedules))
+	for _, s := range ts.schedules {
+		list = append(list, *s)
+	}
+	return list
+}
+
+// SetPaused suspend ou reprend une planification; une reprise repart de
+// maintenant, sans rattraper les échéances suspendues
+func (ts *TaskSchedules) SetPaused(id string, paused bool) (TaskSchedule, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, s := range ts.schedules {
+		if s.ID == id {
+			if s.Paused && !paused {
+				s.NextRun = s.next(time.Now())
+			}
+			s.Paused = paused
+			ts.saveLocked()
+			return *s, true
+		}
+	}
+	return TaskSchedule{}, false
+}
+
+// Remove supprime une planification; false si elle est inconnue
+func (ts *TaskSchedules) Remove(id string) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for i, s := range ts.schedules {
+		if s.ID == id {
+			ts.schedules = append(ts.schedules[:i], ts.schedules[i+1:]...)
+			ts.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// due retourne les planifications échues et avance leur prochaine échéance
+func (ts *TaskSchedules) due(now time.Time) []TaskSchedule {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	var due []TaskSchedule
+	for _, s := range ts.schedules {
+		if s.Paused || s.NextRun == nil || now.Before(*s.NextRun) {
+			continue
+		}
+		due = append(due, *s)
+		// Suivre le rythme prévu sans dériver, sans rattraper les échéances manquées
+		if s.NextRun = s.next(*s.NextRun); s.NextRun != nil && !s.NextRun.After(now) {
+			s.NextRun = s.next(now)
+		}
+	}
+	return due
+}
+
+// recordRun note le résultat d'une échéance
+func (ts *TaskSchedules) recordRun(id, taskID string, at time.Time, rej *Rejection) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, s := range ts.schedules {
+		if s.ID != id {
+			continue
+		}
+		s.Runs++
+		s.LastRun = &at
+		s.LastTaskID = taskID
+		s.LastError = ""
+		if rej != nil {
+			s.Rejected++
+			s.LastError = rej.Reason
+		}
+		ts.saveLocked()
+		return
+	}
+}
+
+func (ts *TaskSchedules) saveLocked() {
+	if err := writeJSONFile(ts.path, ts.schedules); err != nil {
+		slog.Error("Écriture des planifications impossible", "path", ts.path, "error", err)
+	}
+}
+
+// runSchedules soumet les tâches récurrentes à leurs échéances
+func (fc *FogCompute) runSchedules(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, s := range fc.schedules.due(now) {
+				taskID, rej := fc.materialize(s, now)
+				fc.schedules.recordRun(s.ID, taskID, now, rej)
+			}
+		}
+	}
+}
+
+// materialize soumet une instance d'une tâche récurrente: même chaîne que
+// POST /tasks, un refus étant noté sur la planification et dans la queue des rejets
+func (fc *FogCompute) materialize(s TaskSchedule, now time.Time) (string, *Rejection) {
+	span := fc.tracer.Start("schedule "+s.ID, SpanKindInternal, spanContext{})
+	defer span.End()
+
+	task := s.Template
+	task.Provenance = append([]ProvenanceHop(nil), s.Template.Provenance...)
+	task.trace = span.Context()
+	task.TraceID = task.trace.traceIDString()
+	task.addHop(ProvenanceHop{NodeID: fc.node.ID, Source: SourceSchedule, Client: s.ID})
+	task.applyDefaultCosts()
+	task.SmartScore = task.calculateScore()
+
+	// Identifiant propre à l'échéance, pour les schémas d'identifiants fournis par le client
+	task.ID = fmt.Sprintf("%s-%d", s.ID, s.Runs+1)
+	id, rej := fc.idScheme.NewID(fc, &task)
+	if rej != nil {
+		span.SetError(rej.Reason)
+		slog.Warn("Tâche récurrente non soumise", "schedule_id", s.ID, "reason", rej.Reason)
+		return "", rej
+	}
+	task.ID = id
+	task.SubmittedAt = now
+	// Propriétaire et namespace ont été fixés à la création de la planification
+	task.QueueClass = admissionClass(&task)
+	task.PriorityClass = priorityClass(&task)
+	task.shapingKey = "schedule:" + s.ID
+
+	span.SetAttr("task.id", task.ID)
+	span.SetAttr("task.type", task.Type)
+	ac := fc.admissionSnapshot(&task, nil)
+	if ac.Draining {
+		rej = &Rejection{Reason: "Nœud en cours de drainage: soumissions suspendues", Status: http.StatusServiceUnavailable}
+	} else {
+		rej = fc.admission.Admit(fc, ac)
+	}
+	if rej != nil {
+		span.SetError(rej.Reason)
+		task.Status = "rejected"
+		fc.rejectTask(task, rej.Reason, ac.Load, ac.QueueSize)
+		return task.ID, rej
+	}
+
+	fc.mu.Lock()
+	fc.enqueueWhenReadyLocked(&task)
+	fc.mu.Unlock()
+	fc.journal.Record(&task)
+
+	slog.Info("Tâche récurrente soumise", "schedule_id", s.ID, "task_id", task.ID, "type", task.Type,
+		"run", s.Runs+1, "trace_id", task.TraceID)
+	return task.ID, nil
+}
+
+// handleCreateSchedule enregistre une tâche récurrente
+// ({"task": {...}, "cron": "*/5 * * * *"} ou {"task": {...}, "interval": "30s"})
+func (fc *FogCompute) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var s TaskSchedule
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rej := fc.callbacks.Validate(s.Template.CallbackURL); rej != nil {
+		rej.write(w)
+		return
+	}
+	fc.assignOwner(&s.Template, r)
+	fc.assignTenant(&s.Template, r)
+	s.Paused = false
+	s.Runs, s.Rejected, s.LastRun, s.LastTaskID, s.LastError = 0, 0, nil, "", ""
+	if err := fc.schedules.Add(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Info("Tâche récurrente planifiée", "schedule_id", s.ID, "type", s.Template.Type, "cron", s.Cron,
+		"interval", s.Interval, "next_run", s.NextRun.Format(time.RFC3339), "client", requestClient(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/schedules/"+s.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+// handleGetSchedules liste les tâches récurrentes du namespace du client
+func (fc *FogCompute) handleGetSchedules(w http.ResponseWriter, r *http.Request) {
+	tenant, all := fc.requestTenant(r)
+	schedules := make([]TaskSchedule, 0)
+	for _, s := range fc.schedules.List() {
+		if visibleTo(&s.Template, tenant, all) {
+			schedules = append(schedules, s)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":     len(schedules),
+		"schedules": schedules,
+	})
+}
+
+// visibleSchedule vérifie qu'une planification existe dans le namespace du client
+func (fc *FogCompute) visibleSchedule(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+	s, ok := fc.schedules.Get(id)
+	if !ok || !visibleTo(&s.Template, tenant, all) {
+		http.Error(w, "Planification non trouvée", http.StatusNotFound)
+		return "", false
+	}
+	return id, true
+}
+
+// handlePauseSchedule suspend une tâche récurrente
+func (fc *FogCompute) handlePauseSchedule(w http.ResponseWriter, r *http.Request) {
+	fc.setSchedulePaused(w, r, true)
+}
+
+// handleResumeSchedule reprend une tâche récurrente suspendue
+func (fc *FogCompute) handleResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	fc.setSchedulePaused(w, r, false)
+}
+
+func (fc *FogCompute) setSchedulePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	id, ok := fc.visibleSchedule(w, r)
+	if !ok {
+		return
+	}
+	s, ok := fc.schedules.SetPaused(id, paused)
+	if !ok {
+		http.Error(w, "Planification non trouvée", http.StatusNotFound)
+		return
+	}
+	slog.Info("Tâche récurrente mise à jour", "schedule_id", id, "paused", paused, "client", requestClient(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// handleDeleteSchedule supprime une tâche récurrente; ses tâches déjà soumises suivent leur cours
+func (fc *FogCompute) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := fc.visibleSchedule(w, r)
+	if !ok {
+		return
+	}
+	if !fc.schedules.Remove(id) {
+		http.Error(w, "Planification non trouvée", http.StatusNotFound)
+		return
+	}
+	slog.Info("Tâche récurrente supprimée", "schedule_id", id, "client", requestClient(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,342 @@
+package fognode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// scriptTaskType est le type des tâches qui portent un court programme Lua
+const scriptTaskType = "script"
+
+// Limites fixes de l'interpréteur: profondeur d'appel, pile de données,
+// profondeur des valeurs converties et taille des journaux print()
+const (
+	scriptCallStackSize   = 200
+	scriptRegistrySize    = 1024
+	scriptRegistryMaxSize = 256 << 10
+	scriptMaxDepth        = 32
+	scriptMaxLogBytes     = 4 << 10
+	scriptCacheSize       = 256
+)
+
+// ScriptLimits borne l'exécution des scripts Lua des tâches
+type ScriptLimits struct {
+	MaxSourceBytes  int           // Taille du programme
+	MaxStringBytes  int           // Plus longue chaîne produite (.., string.rep, string.format, string.gsub, table.concat)
+	MaxInstructions int           // Instructions exécutées
+	MaxMemoryBytes  int           // Mémoire allouée par le nœud pendant l'exécution
+	Timeout         time.Duration // Plafond de durée, en plus du timeout de la tâche
+}
+
+// scriptLimits sont les limites appliquées par le handler script (loadScripts)
+var scriptLimits ScriptLimits
+
+// scriptCache conserve les programmes compilés, par empreinte de leur
+// source: un programme envoyé avec chaque message n'est compilé qu'une fois
+var scriptCache = struct {
+	sync.Mutex
+	protos map[[sha256.Size]byte]*lua.FunctionProto
+}{protos: make(map[[sha256.Size]byte]*lua.FunctionProto)}
+
+// loadScripts lit les limites SCRIPT_* et enregistre le handler du type
+// script si SCRIPT_ENABLED=true
+func loadScripts() {
+	scriptLimits = ScriptLimits{
+		MaxSourceBytes:  getEnvInt("SCRIPT_MAX_SOURCE_KB", 64) << 10,
+		MaxStringBytes:  getEnvInt("SCRIPT_MAX_STRING_KB", 1<<10) << 10,
+		MaxInstructions: getEnvInt("SCRIPT_MAX_INSTRUCTIONS", 10_000_000),
+		MaxMemoryBytes:  getEnvInt("SCRIPT_MAX_MEMORY_MB", 64) << 20,
+		Timeout:         getEnvDuration("SCRIPT_TIMEOUT", time.Second),
+	}
+	if scriptLimits.MaxSourceBytes <= 0 || scriptLimits.MaxStringBytes <= 0 || scriptLimits.MaxInstructions <= 0 ||
+		scriptLimits.MaxMemoryBytes <= 0 || scriptLimits.Timeout <= 0 {
+		config.errorf("SCRIPT_*", "les limites des scripts doivent être positives")
+		return
+	}
+	if !getEnvBool("SCRIPT_ENABLED", false) {
+		return
+	}
+	registerHandler(scriptTaskType, registeredHandler{fn: runScript, version: "1.0.0", source: "builtin"})
+}
+
+// ScriptPayload est le payload d'une tâche script
+type ScriptPayload struct {
+	Script string      `json:"script"`          // Programme Lua; sa valeur de retour devient le résultat
+	Input  interface{} `json:"input,omitempty"` // Document exposé au programme dans la variable globale input
+}
+
+// compileScript retourne le programme compilé d'un payload script
+func compileScript(payload map[string]interface{}) (ScriptPayload, *lua.FunctionProto, error) {
+	var p ScriptPayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return p, nil, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, nil, fmt.Errorf("payload script invalide: %w", err)
+	}
+	if strings.TrimSpace(p.Script) == "" {
+		return p, nil, errors.New("payload script: script requis")
+	}
+	if len(p.Script) > scriptLimits.MaxSourceBytes {
+		return p, nil, fmt.Errorf("script trop long: au plus %d Kio (SCRIPT_MAX_SOURCE_KB)", scriptLimits.MaxSourceBytes>>10)
+	}
+
+	sum := sha256.Sum256([]byte(p.Script))
+	scriptCache.Lock()
+	proto, ok := scriptCache.protos[sum]
+	scriptCache.Unlock()
+	if ok {
+		return p, proto, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(p.Script), "script")
+	if err != nil {
+		return p, nil, fmt.Errorf("script invalide: %w", err)
+	}
+	if proto, err = lua.Compile(chunk, "script"); err != nil {
+		return p, nil, fmt.Errorf("script invalide: %w", err)
+	}
+	scriptCache.Lock()
+	if len(scriptCache.protos) >= scriptCacheSize {
+		scriptCache.protos = make(map[[sha256.Size]byte]*lua.FunctionProto) // Vidé d'un bloc: les scripts courants reviennent vite
+	}
+	scriptCache.protos[sum] = proto
+	scriptCache.Unlock()
+	return p, proto, nil
+}
+
+// validateScriptTask refuse dès la soumission une tâche script dont le
+// programme est absent, trop long ou ne compile pas
+func validateScriptTask(task *Task) *Rejection {
+	if task.Type != scriptTaskType {
+		return nil
+	}
+	taskHandlersMu.RLock()
+	h, ok := taskHandlers[scriptTaskType]
+	taskHandlersMu.RUnlock()
+	if !ok || h.source != "builtin" {
+		return nil // Type désactivé, ou remplacé par un module ou un plugin
+	}
+	if _, _, err := compileScript(task.Payload); err != nil {
+		return &Rejection{Reason: err.Error(), Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// newScriptState crée un interpréteur réduit aux bibliothèques base, table,
+// string et math, sans accès aux fichiers, aux processus ni au chargement de
+// modules; print() écrit dans logs. Les fonctions de bibliothèque qui peuvent
+// produire une longue chaîne d'un seul appel sont bornées par
+// SCRIPT_MAX_STRING_KB; le reste (concaténations, tables) est surveillé
+// pendant l'exécution par scriptGuard.
+func newScriptState(logs *limitedBuffer) *lua.LState {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   scriptCallStackSize,
+		RegistrySize:    scriptRegistrySize,
+		RegistryMaxSize: scriptRegistryMaxSize,
+	})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage", "getfenv", "setfenv"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		logs.Write([]byte(strings.Join(parts, "\t") + "\n"))
+		return 0
+	}))
+	if str, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		str.RawSetString("rep", L.NewFunction(scriptRep))
+		str.RawSetString("gsub", L.NewFunction(scriptGsub))
+		if format, ok := str.RawGetString("format").(*lua.LFunction); ok {
+			str.RawSetString("format", L.NewFunction(scriptFormat(format.GFunction)))
+		}
+	}
+	if tab, ok := L.GetGlobal(lua.TabLibName).(*lua.LTable); ok {
+		if concat, ok := tab.RawGetString("concat").(*lua.LFunction); ok {
+			tab.RawSetString("concat", L.NewFunction(scriptConcat(concat.GFunction)))
+		}
+	}
+	return L
+}
+
+// runScript exécute le programme Lua d'une tâche script: le payload input est
+// exposé dans la variable globale input, la valeur retournée par le programme
+// devient result.output et les appels à print() result.logs. Le programme est
+// interrompu au timeout de la tâche ou à SCRIPT_TIMEOUT, ou au-delà de
+// SCRIPT_MAX_INSTRUCTIONS, SCRIPT_MAX_STRING_KB et SCRIPT_MAX_MEMORY_MB.
+func runScript(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	p, proto, err := compileScript(payload)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, scriptLimits.Timeout)
+	defer cancel()
+
+	logs := &limitedBuffer{max: scriptMaxLogBytes}
+	L := newScriptState(logs)
+	defer L.Close()
+	guard := newScriptGuard(ctx, L)
+	L.SetContext(guard)
+	input, err := toLua(L, p.Input, 0)
+	if err != nil {
+		return nil, err
+	}
+	L.SetGlobal("input", input)
+
+	start := time.Now()
+	guard.start()
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if guard.err != nil {
+			return nil, guard.err
+		}
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			return nil, fmt.Errorf("erreur du script: %s", apiErr.Object.String())
+		}
+		return nil, fmt.Errorf("erreur du script: %w", err)
+	}
+	output, err := fromLua(L.Get(-1), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"operation":   scriptTaskType,
+		"status":      "success",
+		"duration_ms": time.Since(start).Milliseconds(),
+		"output":      output,
+	}
+	if logs.Len() > 0 {
+		result["logs"] = logs.String()
+	}
+	return result, nil
+}
+
+// toLua convertit un document JSON décodé en valeur Lua
+func toLua(L *lua.LState, v interface{}, depth int) (lua.LValue, error) {
+	if depth > scriptMaxDepth {
+		return lua.LNil, fmt.Errorf("input trop imbriqué: au plus %d niveaux", scriptMaxDepth)
+	}
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil, nil
+	case bool:
+		return lua.LBool(v), nil
+	case float64:
+		return lua.LNumber(v), nil
+	case string:
+		return lua.LString(v), nil
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			lv, err := toLua(L, item, depth+1)
+			if err != nil {
+				return lua.LNil, err
+			}
+			t.Append(lv)
+		}
+		return t, nil
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for k, item := range v {
+			lv, err := toLua(L, item, depth+1)
+			if err != nil {
+				return lua.LNil, err
+			}
+			t.RawSetString(k, lv)
+		}
+		return t, nil
+	}
+	return lua.LNil, fmt.Errorf("input: type %T non supporté", v)
+}
+
+// fromLua convertit la valeur retournée par un script en document JSON: une
+// table indexée de 1 à n devient un tableau, toute autre un objet
+func fromLua(v lua.LValue, depth int) (interface{}, error) {
+	if depth > scriptMaxDepth {
+		return nil, fmt.Errorf("résultat trop imbriqué: au plus %d niveaux", scriptMaxDepth)
+	}
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("résultat: nombre %v non représentable en JSON", f)
+		}
+		return f, nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if n := v.MaxN(); n > 0 && n == countTable(v) {
+			items := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := fromLua(v.RawGetInt(i), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return items, nil
+		}
+		obj := make(map[string]interface{})
+		var convErr error
+		v.ForEach(func(key, value lua.LValue) {
+			if convErr != nil {
+				return
+			}
+			item, err := fromLua(value, depth+1)
+			if err != nil {
+				convErr = err
+				return
+			}
+			obj[key.String()] = item
+		})
+		if convErr != nil {
+			return nil, convErr
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("résultat: valeur Lua de type %s non convertible en JSON", v.Type())
+}
+
+// countTable compte les entrées d'une table Lua
+func countTable(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
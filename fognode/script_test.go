@@ -0,0 +1,111 @@
+package fognode
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withScriptLimits applique des limites de test le temps d'un test
+func withScriptLimits(t *testing.T, limits ScriptLimits) {
+	t.Helper()
+	previous := scriptLimits
+	scriptLimits = limits
+	t.Cleanup(func() { scriptLimits = previous })
+}
+
+var testScriptLimits = ScriptLimits{
+	MaxSourceBytes:  64 << 10,
+	MaxStringBytes:  1 << 20,
+	MaxInstructions: 50_000_000,
+	MaxMemoryBytes:  32 << 20,
+	Timeout:         20 * time.Second,
+}
+
+func runTestScript(script string) (map[string]interface{}, error) {
+	return runScript(context.Background(), map[string]interface{}{"script": script})
+}
+
+func TestScriptMemoryBombs(t *testing.T) {
+	withScriptLimits(t, testScriptLimits)
+	tests := []struct {
+		name, script, limit string
+	}{
+		{"doublement par ..", `local s = "x" while true do s = s .. s end`, "SCRIPT_MAX_STRING_KB"},
+		{"doublement dans une fonction", `local function f(s) return f(s .. s) end return f("x")`, "SCRIPT_MAX_STRING_KB"},
+		{"string.rep", `return string.rep("x", 1e9)`, "SCRIPT_MAX_STRING_KB"},
+		{"string.format", `return string.format(string.rep("%999999s", 100), "x")`, "SCRIPT_MAX_STRING_KB"},
+		{"string.format échappé", `local s = string.rep("\0", 800000) return string.format("%q", s)`, "SCRIPT_MAX_STRING_KB"},
+		{"table.concat", `local t = {} for i = 1, 2000 do t[i] = string.rep("x", 1000) end return table.concat(t)`, "SCRIPT_MAX_STRING_KB"},
+		{"string.gsub chaîne", `local s = string.rep("x", 2000) return string.gsub(s, "x", s)`, "SCRIPT_MAX_STRING_KB"},
+		{"string.gsub capture", `local s = string.rep("x", 2000) return string.gsub(s, "x", string.rep("%0", 600))`, "SCRIPT_MAX_STRING_KB"},
+		{"string.gsub fonction", `local s = string.rep("x", 2000) return string.gsub(s, "x", function() return s end)`, "SCRIPT_MAX_STRING_KB"},
+		{"string.gsub table", `local s = string.rep("x", 2000) return string.gsub(s, "x", {x = s})`, "SCRIPT_MAX_STRING_KB"},
+		{"tables", `local t, s = {}, string.rep("x", 1000) for i = 1, 1e8 do t[i] = s .. i end`, "SCRIPT_MAX_MEMORY_MB"},
+		{"tables de valeurs", `local t, s = {}, string.rep("x", 10000) for i = 1, 1e8 do t[i] = {string.byte(s, 1, -1)} end`, "SCRIPT_MAX_MEMORY_MB"},
+	}
+	for _, tt := range tests {
+		_, err := runTestScript(tt.script)
+		if err == nil || !strings.Contains(err.Error(), tt.limit) {
+			t.Errorf("%s: erreur %v, attendu un refus %s", tt.name, err, tt.limit)
+		}
+	}
+}
+
+func TestScriptInstructionLimit(t *testing.T) {
+	limits := testScriptLimits
+	limits.MaxInstructions = 100_000
+	withScriptLimits(t, limits)
+
+	if _, err := runTestScript(`while true do end`); err == nil || !strings.Contains(err.Error(), "SCRIPT_MAX_INSTRUCTIONS") {
+		t.Errorf("boucle infinie: erreur %v, attendu SCRIPT_MAX_INSTRUCTIONS", err)
+	}
+	if _, err := runTestScript(`local n = 0 for i = 1, 1000 do n = n + i end return n`); err != nil {
+		t.Errorf("script court refusé: %v", err)
+	}
+}
+
+func TestScriptStringFunctions(t *testing.T) {
+	withScriptLimits(t, testScriptLimits)
+	tests := []struct {
+		script string
+		want   interface{}
+	}{
+		{`return (string.gsub("hello world", "o", "0"))`, "hell0 w0rld"},
+		{`return select(2, string.gsub("hello world", "o", "0"))`, 2.0},
+		{`return (string.gsub("abc", "%w", "%0%0"))`, "aabbcc"},
+		{`return (string.gsub("hello world", "(%w+)", "<%1>"))`, "<hello> <world>"},
+		{`return (string.gsub("abc", "b", "%1"))`, "abc"},
+		{`return (string.gsub("50", "%d+", "%% %x"))`, "% %x"},
+		{`return (string.gsub("hello world", "%w+", "x", 1))`, "x world"},
+		{`return (string.gsub("$name a $age ans, $rien", "%$(%w+)", {name = "Ada", age = 36}))`, "Ada a 36 ans, $rien"},
+		{`return (string.gsub("a1b2", "%d", function(d) if d == "1" then return "un" end end))`, "aunb2"},
+		{`return (string.gsub("cle=valeur", "(%w+)=(%w+)", function(k, v) return v .. "=" .. k end))`, "valeur=cle"},
+		{`return (string.gsub("ab", "()", "%1"))`, "1a2b3"},
+		{`return string.format("%5.2f|%-4s|%d|%%", 3.14159, "ab", 42)`, " 3.14|ab  |42|%"},
+		{`return table.concat({1, 2, "trois"}, ",")`, "1,2,trois"},
+		{`return table.concat({1, 2, 3, 4}, "-", 2, 3)`, "2-3"},
+		{`return #string.rep("ab", 1000)`, 2000.0},
+		{`local s = "x" for i = 1, 10 do s = s .. s end return #s`, 1024.0},
+	}
+	for _, tt := range tests {
+		result, err := runTestScript(tt.script)
+		if err != nil {
+			t.Errorf("%s: %v", tt.script, err)
+			continue
+		}
+		if result["output"] != tt.want {
+			t.Errorf("%s = %#v, attendu %#v", tt.script, result["output"], tt.want)
+		}
+	}
+
+	for _, script := range []string{
+		`return string.gsub("abc", "b", "%2")`,
+		`return string.format("%[1]s", "x")`,
+	} {
+		if _, err := runTestScript(script); err == nil {
+			t.Errorf("%s accepté", script)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package fognode
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/pm"
+)
+
+// Fréquence des vérifications du garde, en instructions: taille des chaînes
+// des registres, puis mémoire allouée
+const (
+	scriptCheckEvery  = 8
+	scriptMemoryEvery = 256
+)
+
+// heapAllocsMetric est le cumul des allocations du tas du processus
+const heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+// scriptAborted est le canal Done retourné une fois une limite dépassée
+var scriptAborted = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// scriptGuard est le contexte de l'interpréteur. gopher-lua consulte Done
+// avant chaque instruction: le garde y compte les instructions et vérifie
+// régulièrement la taille des chaînes des registres (concaténations ..) et
+// la mémoire allouée par le nœud depuis le début du script. Une limite
+// dépassée ferme Done: l'interpréteur lève alors l'erreur retournée par Err.
+type scriptGuard struct {
+	context.Context
+	L            *lua.LState
+	instructions int
+	allocStart   uint64
+	sample       []metrics.Sample
+	err          error
+}
+
+// newScriptGuard crée le garde de L, dérivé de ctx
+func newScriptGuard(ctx context.Context, L *lua.LState) *scriptGuard {
+	return &scriptGuard{Context: ctx, L: L, sample: []metrics.Sample{{Name: heapAllocsMetric}}}
+}
+
+// start fixe l'origine de la mémoire allouée, juste avant l'exécution
+func (g *scriptGuard) start() {
+	g.allocStart = g.allocated()
+}
+
+// allocated retourne le cumul des allocations du tas du processus
+func (g *scriptGuard) allocated() uint64 {
+	metrics.Read(g.sample)
+	if g.sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return g.sample[0].Value.Uint64()
+}
+
+func (g *scriptGuard) Done() <-chan struct{} {
+	if g.err == nil {
+		g.instructions++
+		switch {
+		case g.instructions > scriptLimits.MaxInstructions:
+			g.err = fmt.Errorf("script interrompu: plus de %d instructions (SCRIPT_MAX_INSTRUCTIONS)", scriptLimits.MaxInstructions)
+		case g.instructions%scriptCheckEvery == 0:
+			g.err = g.check()
+		}
+	}
+	if g.err != nil {
+		return scriptAborted
+	}
+	return g.Context.Done()
+}
+
+func (g *scriptGuard) Err() error {
+	if g.err != nil {
+		return g.err
+	}
+	return g.Context.Err()
+}
+
+// check vérifie les chaînes de la fonction en cours et, toutes les
+// scriptMemoryEvery instructions, la mémoire allouée depuis le début du script
+func (g *scriptGuard) check() error {
+	for i := g.L.GetTop(); i > 0; i-- {
+		if s, ok := g.L.Get(i).(lua.LString); ok && len(s) > scriptLimits.MaxStringBytes {
+			return fmt.Errorf("script interrompu: chaîne supérieure à %d Kio (SCRIPT_MAX_STRING_KB)", scriptLimits.MaxStringBytes>>10)
+		}
+	}
+	if g.instructions%scriptMemoryEvery == 0 && g.allocated()-g.allocStart > uint64(scriptLimits.MaxMemoryBytes) {
+		return fmt.Errorf("script interrompu: plus de %d Mo alloués (SCRIPT_MAX_MEMORY_MB)", scriptLimits.MaxMemoryBytes>>20)
+	}
+	return nil
+}
+
+// checkScriptString lève une erreur si une fonction de bibliothèque
+// produirait une chaîne de plus de SCRIPT_MAX_STRING_KB
+func checkScriptString(L *lua.LState, fn string, size int) {
+	if size > scriptLimits.MaxStringBytes {
+		L.RaiseError("%s: résultat supérieur à %d Kio (SCRIPT_MAX_STRING_KB)", fn, scriptLimits.MaxStringBytes>>10)
+	}
+}
+
+// scriptRep remplace string.rep: le résultat est borné avant d'être alloué
+func scriptRep(L *lua.LState) int {
+	s, n := L.CheckString(1), L.OptInt(2, 1)
+	if n <= 0 || s == "" {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if n > scriptLimits.MaxStringBytes/len(s) {
+		checkScriptString(L, "string.rep", scriptLimits.MaxStringBytes+1)
+	}
+	L.Push(lua.LString(strings.Repeat(s, n)))
+	return 1
+}
+
+// scriptFormat enveloppe string.format: la longueur du résultat est majorée
+// avant l'appel, d'après les largeurs, précisions et arguments de chaque
+// spécification (fmt refuse les largeurs au-delà d'un million)
+func scriptFormat(format lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		f := L.CheckString(1)
+		bound, arg := len(f), 2
+		for i := 0; i < len(f); i++ {
+			if f[i] != '%' {
+				continue
+			}
+			if i++; i < len(f) && f[i] == '%' {
+				continue
+			}
+			num := 0
+			for ; i < len(f) && strings.IndexByte("-+ #0123456789.", f[i]) >= 0; i++ {
+				if c := f[i]; c >= '0' && c <= '9' {
+					num = min(num*10+int(c-'0'), 1e6)
+				} else {
+					bound, num = bound+num, 0
+				}
+			}
+			bound += num
+			if i < len(f) && (f[i] == '[' || f[i] == '*') {
+				L.RaiseError("string.format: index d'argument et largeur * non supportés")
+			}
+			// Une chaîne échappée (%q, %x) peut quadrupler; les autres valeurs restent courtes
+			switch v := L.Get(arg); {
+			case v.Type() != lua.LTString:
+				bound += 400
+			case i < len(f) && f[i] == 's':
+				bound += len(v.String())
+			default:
+				bound += 4*len(v.String()) + 32
+			}
+			arg++
+		}
+		checkScriptString(L, "string.format", bound)
+		return format(L)
+	}
+}
+
+// scriptConcat enveloppe table.concat: la longueur du résultat est calculée
+// avant l'appel
+func scriptConcat(concat lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		tbl := L.CheckTable(1)
+		sep := L.OptString(2, "")
+		i, j := max(L.OptInt(3, 1), 1), min(L.OptInt(4, tbl.Len()), tbl.Len())
+		size := 0
+		for k := i; k <= j && size <= scriptLimits.MaxStringBytes; k++ {
+			v := tbl.RawGetInt(k)
+			if !lua.LVCanConvToString(v) {
+				break // Erreur levée par table.concat
+			}
+			size += len(lua.LVAsString(v))
+			if k != j {
+				size += len(sep)
+			}
+		}
+		checkScriptString(L, "table.concat", size)
+		return concat(L)
+	}
+}
+
+// scriptGsub remplace string.gsub, avec le même comportement que celui de
+// gopher-lua, mais construit le résultat au fil des remplacements et
+// l'interrompt au-delà de SCRIPT_MAX_STRING_KB, quelle que soit la forme du
+// remplacement (chaîne, table ou fonction)
+func scriptGsub(L *lua.LState) int {
+	str := L.CheckString(1)
+	pattern := L.CheckString(2)
+	L.CheckTypes(3, lua.LTString, lua.LTTable, lua.LTFunction)
+	repl := L.CheckAny(3)
+	limit := L.OptInt(4, -1)
+
+	matches, err := pm.Find(pattern, []byte(str), 0, limit)
+	if err != nil {
+		L.RaiseError(err.Error())
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m.Capture(0), m.Capture(1)
+		b.WriteString(str[last:start])
+		last = end
+		switch repl := repl.(type) {
+		case lua.LString:
+			gsubExpand(L, &b, str, string(repl), m)
+			checkScriptString(L, "string.gsub", b.Len())
+			continue
+		case *lua.LTable:
+			idx := 0
+			if m.CaptureLength() > 2 {
+				idx = 2
+			}
+			if v := L.GetTable(repl, gsubCapture(L, str, m, idx)); !lua.LVIsFalse(v) {
+				b.WriteString(lua.LVAsString(v))
+			} else {
+				b.WriteString(str[start:end])
+			}
+		case *lua.LFunction:
+			L.Push(repl)
+			nargs := 1
+			if m.CaptureLength() > 2 {
+				nargs = 0
+				for i := 2; i < m.CaptureLength(); i += 2 {
+					L.Push(gsubCapture(L, str, m, i))
+					nargs++
+				}
+			} else {
+				L.Push(gsubCapture(L, str, m, 0))
+			}
+			L.Call(nargs, 1)
+			v := L.Get(-1)
+			L.Pop(1)
+			if !lua.LVIsFalse(v) {
+				b.WriteString(lua.LVAsString(v))
+			} else {
+				b.WriteString(str[start:end])
+			}
+		}
+		checkScriptString(L, "string.gsub", b.Len())
+	}
+	b.WriteString(str[last:])
+	checkScriptString(L, "string.gsub", b.Len())
+	L.Push(lua.LString(b.String()))
+	L.Push(lua.LNumber(len(matches)))
+	return 2
+}
+
+// gsubExpand écrit le remplacement repl d'une correspondance: %0 à %9 sont
+// les captures, %% un %
+func gsubExpand(L *lua.LState, b *strings.Builder, str, repl string, m *pm.MatchData) {
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		if c != '%' || i == len(repl)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch d := repl[i]; {
+		case d == '%':
+			b.WriteByte('%')
+		case d >= '0' && d <= '9':
+			b.WriteString(lua.LVAsString(gsubCapture(L, str, m, 2*int(d-'0'))))
+		default:
+			b.WriteByte('%')
+			b.WriteByte(d)
+		}
+		if b.Len() > scriptLimits.MaxStringBytes {
+			return
+		}
+	}
+}
+
+// gsubCapture retourne la capture d'indice idx d'une correspondance; sans
+// capture, %1 désigne la correspondance entière
+func gsubCapture(L *lua.LState, str string, m *pm.MatchData, idx int) lua.LValue {
+	if idx >= m.CaptureLength() {
+		if idx != 2 {
+			L.RaiseError("invalid capture index")
+		}
+		idx = 0
+	}
+	if m.IsPosCapture(idx) {
+		return lua.LNumber(m.Capture(idx))
+	}
+	return lua.LString(str[m.Capture(idx):m.Capture(idx+1)])
+}
@@ -0,0 +1,67 @@
+package fognode
+
+import (
+	"log/slog"
+	"time"
+)
+
+// stopWorkers empêche les workers de prendre une nouvelle tâche et réveille
+// ceux qui attendent une queue non vide; chacun termine sa tâche en cours
+func (fc *FogCompute) stopWorkers() {
+	fc.mu.Lock()
+	fc.stopping = true
+	fc.cond.Broadcast()
+	fc.mu.Unlock()
+}
+
+// waitWorkers attend l'arrêt de tous les workers, dans la limite du délai
+// donné; retourne false si le délai est dépassé
+func (fc *FogCompute) waitWorkers(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		fc.workers.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// drainForShutdown refuse les soumissions, laisse les exécutions en cours se
+// terminer dans la limite du délai donné, puis persiste la queue restante
+func (fc *FogCompute) drainForShutdown(timeout time.Duration) {
+	fc.setDraining(true)
+	fc.stopWorkers()
+
+	fc.waitWorkers(timeout)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	queued := 0
+	for _, task := range fc.tasks {
+		// Une exécution interrompue reste "processing" et repart de la queue au redémarrage
+		if task.Status == "queued" || task.Status == "processing" {
+			fc.saveTaskLocked(task)
+		}
+		if task.Status == "queued" {
+			queued++
+		}
+	}
+
+	switch {
+	case fc.store.Name() == "memory" && queued+fc.inFlight > 0:
+		slog.Warn("Arrêt avec le stockage memory: tâches en attente perdues", "queued", queued, "in_flight", fc.inFlight)
+	case fc.inFlight > 0:
+		slog.Warn("Délai de drainage dépassé: exécutions interrompues, reprises au redémarrage",
+			"in_flight", fc.inFlight, "queued", queued, "timeout", timeout.String())
+	default:
+		slog.Info("Drainage terminé", "queued", queued, "store", fc.store.Name())
+	}
+}
@@ -0,0 +1,564 @@
+package fognode
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// NodeProfile décrit une classe de matériel virtuel pour la simulation
+type NodeProfile struct {
+	Name      string  `json:"name"`
+	Count     int     `json:"count"`
+	CPU       float64 `json:"cpu"`        // Capacité CPU, en nœuds de référence (1.0 = classe Pi)
+	RAM       float64 `json:"ram"`        // Capacité RAM, en nœuds de référence
+	Storage   float64 `json:"storage"`    // Stockage disponible (MB)
+	Speed     float64 `json:"speed"`      // Vitesse d'exécution relative au nœud de référence
+	Workers   int     `json:"workers"`    // Tâches exécutées en parallèle
+	QueueSize int     `json:"queue_size"` // Tâches en attente au-delà desquelles le nœud rejette
+	IdleWatts float64 `json:"idle_watts"` // Consommation au repos
+	MaxWatts  float64 `json:"max_watts"`  // Consommation à pleine charge CPU
+	BatteryWh float64 `json:"battery_wh"` // Réserve d'énergie (0 = alimentation secteur)
+}
+
+// builtinProfiles sont les profils utilisables par leur seul nom
+var builtinProfiles = map[string]NodeProfile{
+	"pi": {
+		Name: "pi", CPU: 1, RAM: 1, Storage: 1000, Speed: 1, Workers: NumWorkers,
+		QueueSize: MaxQueueSize, IdleWatts: 2.5, MaxWatts: 6.5, BatteryWh: 20,
+	},
+	"jetson": {
+		Name: "jetson", CPU: 4, RAM: 4, Storage: 16000, Speed: 2.5, Workers: 8,
+		QueueSize: 100, IdleWatts: 5, MaxWatts: 15, BatteryWh: 100,
+	},
+	"microdc": {
+		Name: "microdc", CPU: 32, RAM: 64, Storage: 500000, Speed: 4, Workers: 64,
+		QueueSize: 1000, IdleWatts: 150, MaxWatts: 600,
+	},
+}
+
+// SimWorkload décrit la charge partagée soumise à la topologie simulée
+type SimWorkload struct {
+	Tasks         int                `json:"tasks"`
+	RatePerSec    float64            `json:"rate_per_sec"`   // Taux d'arrivée moyen (processus de Poisson)
+	Seed          int64              `json:"seed"`           // Graine: la même charge est rejouée pour chaque stratégie
+	Mix           map[string]float64 `json:"mix"`            // Poids relatif de chaque type de tâche
+	CriticalShare float64            `json:"critical_share"` // Part des tâches de criticité ≥ 4
+}
+
+// SimulationConfig est la configuration d'une expérience (SIMULATION_CONFIG)
+type SimulationConfig struct {
+	Profiles   []NodeProfile `json:"profiles"`
+	Workload   SimWorkload   `json:"workload"`
+	Strategies []string      `json:"strategies"`
+}
+
+// SimNodeResult est le bilan d'un nœud virtuel
+type SimNodeResult struct {
+	Name      string  `json:"name"`
+	Profile   string  `json:"profile"`
+	Completed int     `json:"completed"`
+	Rejected  int     `json:"rejected"`
+	EnergyWh  float64 `json:"energy_wh"`
+	Depleted  bool    `json:"depleted,omitempty"` // Batterie épuisée pendant l'expérience
+}
+
+// SimulationResult est le bilan d'une stratégie de placement
+type SimulationResult struct {
+	Strategy     string          `json:"strategy"`
+	Submitted    int             `json:"submitted"`
+	Completed    int             `json:"completed"`
+	Rejected     int             `json:"rejected"`
+	AvgLatencyMs float64         `json:"avg_latency_ms"`
+	P95LatencyMs float64         `json:"p95_latency_ms"`
+	MakespanMs   float64         `json:"makespan_ms"`
+	EnergyWh     float64         `json:"energy_wh"`
+	TasksPerWh   float64         `json:"tasks_per_wh"`
+	Nodes        []SimNodeResult `json:"nodes"`
+}
+
+// placementStrategies associe chaque stratégie à sa fonction de choix du nœud
+var placementStrategies = map[string]func(s *simulation, task *simTask) *simNode{
+	"round_robin":  placeRoundRobin,
+	"random":       placeRandom,
+	"least_loaded": placeLeastLoaded,
+	"energy_aware": placeEnergyAware,
+}
+
+// defaultSimulationConfig est l'expérience jouée sans SIMULATION_CONFIG
+func defaultSimulationConfig() SimulationConfig {
+	return SimulationConfig{
+		Profiles: []NodeProfile{{Name: "pi", Count: 3}, {Name: "jetson", Count: 2}, {Name: "microdc", Count: 1}},
+		Workload: SimWorkload{Tasks: 2000, RatePerSec: 50, Seed: 1, CriticalShare: 0.2},
+	}
+}
+
+// loadSimulationConfig lit la configuration et complète les profils à partir
+// des profils intégrés de même nom
+func loadSimulationConfig(path string) (SimulationConfig, error) {
+	cfg := defaultSimulationConfig()
+	if path != "" {
+		var fromFile SimulationConfig
+		if err := readJSONFile(path, &fromFile); err != nil {
+			return cfg, fmt.Errorf("lecture de %s: %w", path, err)
+		}
+		if len(fromFile.Profiles) > 0 {
+			cfg.Profiles = fromFile.Profiles
+		}
+		if fromFile.Workload.Tasks > 0 {
+			cfg.Workload = fromFile.Workload
+		}
+		cfg.Strategies = fromFile.Strategies
+	}
+
+	for i, p := range cfg.Profiles {
+		cfg.Profiles[i] = p.withDefaults()
+		if cfg.Profiles[i].CPU <= 0 || cfg.Profiles[i].Workers <= 0 {
+			return cfg, fmt.Errorf("profil %q incomplet: cpu et workers requis", p.Name)
+		}
+	}
+	if cfg.Workload.RatePerSec <= 0 {
+		cfg.Workload.RatePerSec = 50
+	}
+	if len(cfg.Workload.Mix) == 0 {
+		cfg.Workload.Mix = make(map[string]float64, len(taskWorkDurations))
+		for t := range taskWorkDurations {
+			cfg.Workload.Mix[t] = 1
+		}
+	}
+	if len(cfg.Strategies) == 0 {
+		for name := range placementStrategies {
+			cfg.Strategies = append(cfg.Strategies, name)
+		}
+		sort.Strings(cfg.Strategies)
+	}
+	for _, name := range cfg.Strategies {
+		if _, ok := placementStrategies[name]; !ok {
+			return cfg, fmt.Errorf("stratégie de placement inconnue %q", name)
+		}
+	}
+	return cfg, nil
+}
+
+// withDefaults complète les champs non renseignés avec le profil intégré de même nom
+func (p NodeProfile) withDefaults() NodeProfile {
+	base, ok := builtinProfiles[p.Name]
+	if !ok {
+		base = NodeProfile{Speed: 1, QueueSize: MaxQueueSize}
+	}
+	if p.Count == 0 {
+		p.Count = 1
+	}
+	if p.CPU == 0 {
+		p.CPU = base.CPU
+	}
+	if p.RAM == 0 {
+		p.RAM = base.RAM
+	}
+	if p.Storage == 0 {
+		p.Storage = base.Storage
+	}
+	if p.Speed == 0 {
+		p.Speed = base.Speed
+	}
+	if p.Workers == 0 {
+		p.Workers = base.Workers
+	}
+	if p.QueueSize == 0 {
+		p.QueueSize = base.QueueSize
+	}
+	if p.IdleWatts == 0 {
+		p.IdleWatts = base.IdleWatts
+	}
+	if p.MaxWatts == 0 {
+		p.MaxWatts = base.MaxWatts
+	}
+	if p.BatteryWh == 0 {
+		p.BatteryWh = base.BatteryWh
+	}
+	return p
+}
+
+// simTask est une tâche de la charge simulée
+type simTask struct {
+	task    Task
+	arrival time.Duration
+	work    time.Duration // Durée sur le nœud de référence
+}
+
+// simNode est l'état d'un nœud virtuel
+type simNode struct {
+	name        string
+	profile     NodeProfile
+	cpuFree     float64
+	ramFree     float64
+	storageFree float64
+	running     int
+	queue       []*simTask
+	energyWh    float64 // Réserve restante (batterie uniquement)
+	usedWh      float64
+	last        time.Duration
+	completed   int
+	rejected    int
+	depleted    bool
+}
+
+// simEvent est la fin d'exécution d'une tâche sur un nœud
+type simEvent struct {
+	at   time.Duration
+	node *simNode
+	task *simTask
+}
+
+type simEventHeap []simEvent
+
+func (h simEventHeap) Len() int            { return len(h) }
+func (h simEventHeap) Less(i, j int) bool  { return h[i].at < h[j].at }
+func (h simEventHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *simEventHeap) Push(x interface{}) { *h = append(*h, x.(simEvent)) }
+func (h *simEventHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// simulation est l'exécution d'une stratégie sur la topologie
+type simulation struct {
+	nodes     []*simNode
+	events    simEventHeap
+	rng       *rand.Rand
+	next      int // Curseur du round-robin
+	latencies []time.Duration
+	now       time.Duration
+}
+
+// generateWorkload produit la charge déterministe décrite par la configuration
+func generateWorkload(w SimWorkload) []*simTask {
+	rng := rand.New(rand.NewSource(w.Seed))
+
+	types := make([]string, 0, len(w.Mix))
+	total := 0.0
+	for t, weight := range w.Mix {
+		if weight > 0 {
+			types = append(types, t)
+			total += weight
+		}
+	}
+	sort.Strings(types)
+
+	tasks := make([]*simTask, 0, w.Tasks)
+	at := time.Duration(0)
+	for i := 0; i < w.Tasks; i++ {
+		at += time.Duration(rng.ExpFloat64() / w.RatePerSec * float64(time.Second))
+
+		pick := rng.Float64() * total
+		taskType := types[len(types)-1]
+		for _, t := range types {
+			if pick < w.Mix[t] {
+				taskType = t
+				break
+			}
+			pick -= w.Mix[t]
+		}
+
+		criticality := 1 + rng.Intn(3)
+		if rng.Float64() < w.CriticalShare {
+			criticality = 4 + rng.Intn(2)
+		}
+		task := Task{
+			ID:          fmt.Sprintf("sim-%d", i),
+			Type:        taskType,
+			Priority:    1 + rng.Intn(5),
+			Criticality: criticality,
+		}
+		task.applyDefaultCosts()
+		task.SmartScore = task.calculateScore()
+
+		work, ok := taskWorkDurations[taskType]
+		if !ok {
+			work = 100 * time.Millisecond
+		}
+		tasks = append(tasks, &simTask{task: task, arrival: at, work: work})
+	}
+	return tasks
+}
+
+// newSimulation crée la topologie d'une expérience
+func newSimulation(profiles []NodeProfile, seed int64) *simulation {
+	s := &simulation{rng: rand.New(rand.NewSource(seed))}
+	for _, p := range profiles {
+		for i := 0; i < p.Count; i++ {
+			s.nodes = append(s.nodes, &simNode{
+				name:        fmt.Sprintf("%s-%d", p.Name, i+1),
+				profile:     p,
+				cpuFree:     p.CPU,
+				ramFree:     p.RAM,
+				storageFree: p.Storage,
+				energyWh:    p.BatteryWh,
+			})
+		}
+	}
+	return s
+}
+
+// load retourne la charge d'un nœud (CPU réservé + remplissage de la queue)
+func (n *simNode) load() float64 {
+	return (n.profile.CPU-n.cpuFree)/n.profile.CPU + float64(len(n.queue))/float64(n.profile.QueueSize)
+}
+
+// fits indique si la tâche peut démarrer immédiatement sur le nœud
+func (n *simNode) fits(t *simTask) bool {
+	return n.running < n.profile.Workers && t.task.CPUCost <= n.cpuFree &&
+		t.task.RAMCost <= n.ramFree && t.task.StorageCost <= n.storageFree
+}
+
+// canEverRun indique si les capacités du nœud suffisent à la tâche
+func (n *simNode) canEverRun(t *simTask) bool {
+	return t.task.CPUCost <= n.profile.CPU && t.task.RAMCost <= n.profile.RAM && t.task.StorageCost <= n.profile.Storage
+}
+
+// advance intègre la consommation d'énergie du nœud jusqu'à l'instant t
+func (n *simNode) advance(t time.Duration) {
+	if t <= n.last {
+		return
+	}
+	util := (n.profile.CPU - n.cpuFree) / n.profile.CPU
+	wh := (n.profile.IdleWatts + (n.profile.MaxWatts-n.profile.IdleWatts)*math.Min(1, util)) * (t - n.last).Hours()
+	n.last = t
+	if n.depleted {
+		return
+	}
+	n.usedWh += wh
+	if n.profile.BatteryWh > 0 {
+		n.energyWh -= wh
+		if n.energyWh <= 0 {
+			n.depleted = true
+		}
+	}
+}
+
+// advanceAll intègre la consommation de tous les nœuds jusqu'à l'instant t
+func (s *simulation) advanceAll(t time.Duration) {
+	for _, n := range s.nodes {
+		n.advance(t)
+	}
+	s.now = t
+}
+
+// start lance une tâche sur un nœud
+func (s *simulation) start(n *simNode, t *simTask) {
+	n.cpuFree -= t.task.CPUCost
+	n.ramFree -= t.task.RAMCost
+	n.storageFree -= t.task.StorageCost
+	n.running++
+	duration := time.Duration(float64(t.work) / n.profile.Speed)
+	heap.Push(&s.events, simEvent{at: s.now + duration, node: n, task: t})
+}
+
+// complete termine une tâche et démarre les tâches en attente qui tiennent,
+// dans l'ordre du SmartScore comme sur un nœud réel
+func (s *simulation) complete(e simEvent) {
+	n := e.node
+	n.cpuFree += e.task.task.CPUCost
+	n.ramFree += e.task.task.RAMCost
+	n.storageFree += e.task.task.StorageCost
+	n.running--
+	n.completed++
+	s.latencies = append(s.latencies, s.now-e.task.arrival)
+
+	sort.SliceStable(n.queue, func(i, j int) bool { return n.queue[i].task.SmartScore < n.queue[j].task.SmartScore })
+	remaining := n.queue[:0]
+	for _, t := range n.queue {
+		if !n.depleted && n.fits(t) {
+			s.start(n, t)
+		} else {
+			remaining = append(remaining, t)
+		}
+	}
+	n.queue = remaining
+}
+
+// submit place une tâche arrivée sur le nœud choisi par la stratégie
+func (s *simulation) submit(place func(*simulation, *simTask) *simNode, t *simTask) bool {
+	n := place(s, t)
+	if n == nil {
+		return false
+	}
+	switch {
+	case n.depleted || !n.canEverRun(t):
+	case n.fits(t):
+		s.start(n, t)
+		return true
+	case len(n.queue) < n.profile.QueueSize:
+		n.queue = append(n.queue, t)
+		return true
+	}
+	n.rejected++
+	return false
+}
+
+// alive retourne les nœuds dont la batterie n'est pas épuisée
+func (s *simulation) alive() []*simNode {
+	nodes := make([]*simNode, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		if !n.depleted {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// placeRoundRobin répartit les tâches à tour de rôle
+func placeRoundRobin(s *simulation, t *simTask) *simNode {
+	nodes := s.alive()
+	if len(nodes) == 0 {
+		return nil
+	}
+	s.next++
+	return nodes[s.next%len(nodes)]
+}
+
+// placeRandom choisit un nœud au hasard
+func placeRandom(s *simulation, t *simTask) *simNode {
+	nodes := s.alive()
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[s.rng.Intn(len(nodes))]
+}
+
+// placeLeastLoaded choisit le nœud le moins chargé
+func placeLeastLoaded(s *simulation, t *simTask) *simNode {
+	var best *simNode
+	for _, n := range s.alive() {
+		if n.canEverRun(t) && (best == nil || n.load() < best.load()) {
+			best = n
+		}
+	}
+	return best
+}
+
+// placeEnergyAware choisit, parmi les nœuds pouvant démarrer la tâche, celui
+// dont le coût énergétique marginal est le plus faible
+func placeEnergyAware(s *simulation, t *simTask) *simNode {
+	var best *simNode
+	bestWh := math.Inf(1)
+	for _, n := range s.alive() {
+		if !n.fits(t) {
+			continue
+		}
+		watts := (n.profile.MaxWatts - n.profile.IdleWatts) * t.task.CPUCost / n.profile.CPU
+		wh := watts * (time.Duration(float64(t.work) / n.profile.Speed)).Hours()
+		if wh < bestWh || (wh == bestWh && n.load() < best.load()) {
+			best, bestWh = n, wh
+		}
+	}
+	if best == nil {
+		return placeLeastLoaded(s, t)
+	}
+	return best
+}
+
+// percentile retourne le quantile q d'une liste de durées triées
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// simulate joue la charge avec une stratégie de placement
+func simulate(cfg SimulationConfig, workload []*simTask, strategy string) SimulationResult {
+	s := newSimulation(cfg.Profiles, cfg.Workload.Seed)
+	place := placementStrategies[strategy]
+
+	for _, t := range workload {
+		for s.events.Len() > 0 && s.events[0].at <= t.arrival {
+			e := heap.Pop(&s.events).(simEvent)
+			s.advanceAll(e.at)
+			s.complete(e)
+		}
+		s.advanceAll(t.arrival)
+		s.submit(place, t)
+	}
+	for s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(simEvent)
+		s.advanceAll(e.at)
+		s.complete(e)
+	}
+
+	result := SimulationResult{
+		Strategy:   strategy,
+		Submitted:  len(workload),
+		Completed:  len(s.latencies),
+		MakespanMs: float64(s.now) / float64(time.Millisecond),
+	}
+	for _, n := range s.nodes {
+		// Les tâches restées en queue d'un nœud épuisé ne seront jamais exécutées
+		n.rejected += len(n.queue)
+		result.Rejected += n.rejected
+		result.EnergyWh += n.usedWh
+		result.Nodes = append(result.Nodes, SimNodeResult{
+			Name:      n.name,
+			Profile:   n.profile.Name,
+			Completed: n.completed,
+			Rejected:  n.rejected,
+			EnergyWh:  n.usedWh,
+			Depleted:  n.depleted,
+		})
+	}
+
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	var total time.Duration
+	for _, l := range s.latencies {
+		total += l
+	}
+	if len(s.latencies) > 0 {
+		result.AvgLatencyMs = float64(total) / float64(len(s.latencies)) / float64(time.Millisecond)
+	}
+	result.P95LatencyMs = float64(percentile(s.latencies, 0.95)) / float64(time.Millisecond)
+	if result.EnergyWh > 0 {
+		result.TasksPerWh = float64(result.Completed) / result.EnergyWh
+	}
+	return result
+}
+
+// runSimulation joue l'expérience configurée pour chaque stratégie de
+// placement et écrit le comparatif en JSON sur la sortie standard
+func runSimulation() error {
+	cfg, err := loadSimulationConfig(getEnv("SIMULATION_CONFIG", ""))
+	if err != nil {
+		return err
+	}
+	workload := generateWorkload(cfg.Workload)
+
+	slog.Info("Simulation", "profiles", len(cfg.Profiles), "tasks", len(workload),
+		"rate_per_sec", cfg.Workload.RatePerSec, "strategies", cfg.Strategies)
+
+	results := make([]SimulationResult, 0, len(cfg.Strategies))
+	for _, strategy := range cfg.Strategies {
+		r := simulate(cfg, workload, strategy)
+		slog.Info("Stratégie simulée", "strategy", r.Strategy, "completed", r.Completed, "submitted", r.Submitted,
+			"rejected", r.Rejected, "avg_latency_ms", r.AvgLatencyMs, "p95_latency_ms", r.P95LatencyMs, "energy_wh", r.EnergyWh)
+		results = append(results, r)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"profiles": cfg.Profiles,
+		"workload": cfg.Workload,
+		"results":  results,
+	})
+}
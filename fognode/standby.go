@@ -0,0 +1,530 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rôles d'un nœud d'une paire active/standby
+const (
+	RoleActive  = "active"
+	RoleStandby = "standby"
+)
+
+// Opérations de réplication, miroir des écritures du TaskStore
+const (
+	ReplicaSave           = "save"
+	ReplicaDelete         = "delete"
+	ReplicaSaveRejected   = "save_rejected"
+	ReplicaDeleteRejected = "delete_rejected"
+	ReplicaClearRejected  = "clear_rejected"
+)
+
+// ReplicationOp est une écriture de l'état des tâches de l'actif
+type ReplicationOp struct {
+	Op       string        `json:"op"`
+	Task     *storedTask   `json:"task,omitempty"`
+	Rejected *RejectedTask `json:"rejected,omitempty"`
+	ID       string        `json:"id,omitempty"`
+}
+
+// ReplicationBatch est envoyé par l'actif au standby à chaque heartbeat;
+// Full remplace tout l'état répliqué (instantané)
+type ReplicationBatch struct {
+	NodeID string          `json:"node_id"`
+	Seq    uint64          `json:"seq"`
+	Full   bool            `json:"full,omitempty"`
+	SentAt time.Time       `json:"sent_at"`
+	Ops    []ReplicationOp `json:"ops,omitempty"`
+}
+
+// ReplicationAck répond à un lot; Resync demande un instantané complet
+type ReplicationAck struct {
+	Role   string `json:"role"`
+	Seq    uint64 `json:"seq"`
+	Resync bool   `json:"resync,omitempty"`
+}
+
+// StandbyStatus décrit le rôle du nœud dans sa paire (GET /standby)
+type StandbyStatus struct {
+	Enabled         bool       `json:"enabled"`
+	Role            string     `json:"role,omitempty"`
+	Peer            string     `json:"peer,omitempty"`
+	PeerNodeID      string     `json:"peer_node_id,omitempty"`
+	LastReplication *time.Time `json:"last_replication,omitempty"` // Dernier lot envoyé (actif) ou reçu (standby)
+	LagMs           int64      `json:"lag_ms,omitempty"`           // Standby: âge du dernier lot reçu
+	Synced          bool       `json:"synced"`                     // Standby: instantané complet reçu
+	ReplicaTasks    int        `json:"replica_tasks"`
+	ReplicaRejected int        `json:"replica_rejected"`
+	Pending         int        `json:"pending_ops"` // Actif: écritures en attente d'envoi
+	Sent            uint64     `json:"batches_sent"`
+	Snapshots       int        `json:"snapshots"`
+	Failures        int        `json:"failures"`
+	Conflicts       int        `json:"conflicts"` // Pair lui aussi actif (double actif)
+	Takeovers       int        `json:"takeovers"`
+	TookOverAt      *time.Time `json:"took_over_at,omitempty"`
+	TookOverFrom    string     `json:"took_over_from,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// StandbyPair apparie deux nœuds d'un site: l'actif réplique chaque écriture
+// de l'état des tâches vers le standby, qui reste hors service tant qu'il
+// reçoit ses heartbeats. Sans nouvelles de l'actif pendant
+// STANDBY_FAILOVER_TIMEOUT, et si son /health ne répond plus, le standby
+// devient actif: il reprend l'adresse virtuelle par STANDBY_TAKEOVER_CMD et
+// remet en queue les tâches répliquées.
+type StandbyPair struct {
+	paired          bool
+	role            string // Protégé par mu: le standby devient actif à la reprise
+	peer            string
+	heartbeat       time.Duration
+	failoverTimeout time.Duration
+	takeoverCmd     string
+	client          *http.Client
+
+	ops       chan ReplicationOp
+	needFull  bool
+	seq       uint64
+	sent      uint64
+	snapshots int
+	failures  int
+	conflicts int
+	lastSent  time.Time
+	lastError string
+
+	// Standby: état répliqué de l'actif
+	peerNodeID   string
+	lastHeard    time.Time
+	synced       bool
+	replica      map[string]storedTask
+	rejected     map[string]RejectedTask
+	takeovers    int
+	tookOverAt   *time.Time
+	tookOverFrom string
+	mu           sync.Mutex
+}
+
+// NewStandbyPair lit STANDBY_ROLE, STANDBY_PEER, STANDBY_HEARTBEAT,
+// STANDBY_FAILOVER_TIMEOUT, STANDBY_TAKEOVER_CMD et STANDBY_BUFFER. Un actif
+// dont le pair se déclare déjà actif (reprise pendant son absence) démarre
+// en standby, sans reprendre ses propres tâches persistées.
+func NewStandbyPair() *StandbyPair {
+	sp := &StandbyPair{
+		role:            getEnv("STANDBY_ROLE", ""),
+		peer:            strings.TrimRight(getEnv("STANDBY_PEER", ""), "/"),
+		heartbeat:       getEnvDuration("STANDBY_HEARTBEAT", time.Second),
+		failoverTimeout: getEnvDuration("STANDBY_FAILOVER_TIMEOUT", 5*time.Second),
+		takeoverCmd:     getEnv("STANDBY_TAKEOVER_CMD", ""),
+		client:          newNodeClient(2 * time.Second),
+		ops:             make(chan ReplicationOp, getEnvInt("STANDBY_BUFFER", 10000)),
+		needFull:        true,
+		replica:         make(map[string]storedTask),
+		rejected:        make(map[string]RejectedTask),
+		lastHeard:       time.Now(),
+	}
+	switch {
+	case sp.role == "":
+		return sp
+	case sp.role != RoleActive && sp.role != RoleStandby:
+		slog.Warn("STANDBY_ROLE inconnu: appariement désactivé", "role", sp.role)
+		sp.role = ""
+		return sp
+	case sp.peer == "":
+		slog.Warn("STANDBY_PEER manquant: appariement désactivé", "role", sp.role)
+		sp.role = ""
+		return sp
+	}
+	sp.paired = true
+
+	if sp.role == RoleActive {
+		if status, err := sp.peerStatus(context.Background()); err == nil && status.Role == RoleActive {
+			slog.Warn("Le pair a repris le service: démarrage en standby", "peer", sp.peer)
+			sp.role = RoleStandby
+		}
+	}
+	slog.Info("Appariement actif/standby", "role", sp.role, "peer", sp.peer,
+		"failover_timeout", sp.failoverTimeout.String())
+	return sp
+}
+
+// Enabled indique si le nœud fait partie d'une paire
+func (sp *StandbyPair) Enabled() bool {
+	return sp.paired
+}
+
+// Standby indique si le nœud est en attente de reprise
+func (sp *StandbyPair) Standby() bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.role == RoleStandby
+}
+
+// record transmet une écriture au standby sans bloquer l'appelant (fc.mu
+// détenu); un tampon plein force un instantané complet
+func (sp *StandbyPair) record(op ReplicationOp) {
+	if !sp.Enabled() || sp.Standby() {
+		return
+	}
+	select {
+	case sp.ops <- op:
+	default:
+		sp.mu.Lock()
+		sp.needFull = true
+		sp.mu.Unlock()
+	}
+}
+
+// peerStatus interroge GET /standby du pair
+func (sp *StandbyPair) peerStatus(ctx context.Context) (StandbyStatus, error) {
+	var status StandbyStatus
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sp.peer+"/standby", nil)
+	if err != nil {
+		return status, err
+	}
+	setNodeAPIKey(req)
+	resp, err := sp.client.Do(req)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("statut %d", resp.StatusCode)
+	}
+	return status, json.NewDecoder(resp.Body).Decode(&status)
+}
+
+// runStandby réplique l'état vers le standby tant que le nœud est actif, et
+// surveille l'actif tant qu'il est en standby
+func (fc *FogCompute) runStandby(ctx context.Context) {
+	sp := fc.standby
+	if !sp.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(sp.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if sp.Standby() {
+			fc.watchActive(ctx)
+		} else {
+			fc.replicate(ctx)
+		}
+	}
+}
+
+// replicate envoie au standby les écritures en attente, ou un instantané
+// complet après un échec, un débordement ou à la demande du standby
+func (fc *FogCompute) replicate(ctx context.Context) {
+	sp := fc.standby
+	sp.mu.Lock()
+	full := sp.needFull
+	sp.seq++
+	batch := ReplicationBatch{NodeID: fc.node.ID, Seq: sp.seq, Full: full, SentAt: time.Now()}
+	sp.mu.Unlock()
+
+	if full {
+		// Les écritures déjà en attente sont antérieures à l'instantané
+	drain:
+		for {
+			select {
+			case <-sp.ops:
+			default:
+				break drain
+			}
+		}
+		batch.Ops = fc.replicaSnapshot()
+	} else {
+		for len(batch.Ops) < cap(sp.ops) {
+			select {
+			case op := <-sp.ops:
+				batch.Ops = append(batch.Ops, op)
+				continue
+			default:
+			}
+			break
+		}
+	}
+
+	ack, err := sp.send(ctx, batch)
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	switch {
+	case err != nil:
+		// Le standby a pu perdre des écritures: il recevra un instantané
+		sp.failures++
+		sp.needFull = true
+		if sp.lastError == "" {
+			slog.Warn("Réplication vers le standby impossible", "peer", sp.peer, "error", err)
+		}
+		sp.lastError = err.Error()
+	case ack.Role == RoleActive:
+		sp.conflicts++
+		sp.needFull = true
+		sp.lastError = "le pair est lui aussi actif"
+		slog.Error("Double actif: le pair a repris le service, arbitrage de l'opérateur requis", "peer", sp.peer)
+	default:
+		if sp.lastError != "" {
+			slog.Info("Réplication vers le standby rétablie", "peer", sp.peer)
+		}
+		sp.lastError = ""
+		sp.sent++
+		sp.lastSent = batch.SentAt
+		sp.needFull = ack.Resync
+		if full {
+			sp.snapshots++
+		}
+	}
+}
+
+// send POSTe un lot au standby
+func (sp *StandbyPair) send(ctx context.Context, batch ReplicationBatch) (ReplicationAck, error) {
+	var ack ReplicationAck
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return ack, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sp.peer+"/standby/replicate", bytes.NewReader(body))
+	if err != nil {
+		return ack, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setNodeAPIKey(req)
+	resp, err := sp.client.Do(req)
+	if err != nil {
+		return ack, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return ack, fmt.Errorf("statut %d", resp.StatusCode)
+	}
+	return ack, json.NewDecoder(resp.Body).Decode(&ack)
+}
+
+// replicaSnapshot retourne l'état complet des tâches comme suite d'écritures
+func (fc *FogCompute) replicaSnapshot() []ReplicationOp {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	ops := make([]ReplicationOp, 0, len(fc.tasks)+len(fc.rejectedTasks))
+	for _, task := range fc.tasks {
+		ops = append(ops, ReplicationOp{Op: ReplicaSave, Task: &storedTask{Task: *task, ShapingKey: task.shapingKey}})
+	}
+	for i := range fc.rejectedTasks {
+		ops = append(ops, ReplicationOp{Op: ReplicaSaveRejected, Rejected: &fc.rejectedTasks[i]})
+	}
+	return ops
+}
+
+// apply applique un lot reçu de l'actif à l'état répliqué
+func (sp *StandbyPair) apply(batch ReplicationBatch) ReplicationAck {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.role != RoleStandby {
+		return ReplicationAck{Role: sp.role, Seq: batch.Seq}
+	}
+	sp.lastHeard = time.Now()
+	sp.peerNodeID = batch.NodeID
+	if batch.Full {
+		sp.replica = make(map[string]storedTask, len(batch.Ops))
+		sp.rejected = make(map[string]RejectedTask)
+		sp.synced = true
+	}
+	for _, op := range batch.Ops {
+		switch op.Op {
+		case ReplicaSave:
+			if op.Task != nil {
+				sp.replica[op.Task.Task.ID] = *op.Task
+			}
+		case ReplicaDelete:
+			delete(sp.replica, op.ID)
+		case ReplicaSaveRejected:
+			if op.Rejected != nil {
+				sp.rejected[op.Rejected.Task.ID] = *op.Rejected
+			}
+		case ReplicaDeleteRejected:
+			delete(sp.rejected, op.ID)
+		case ReplicaClearRejected:
+			sp.rejected = make(map[string]RejectedTask)
+		}
+	}
+	return ReplicationAck{Role: sp.role, Seq: batch.Seq, Resync: !sp.synced}
+}
+
+// watchActive déclenche la reprise quand l'actif est silencieux depuis
+// STANDBY_FAILOVER_TIMEOUT et que son /health ne répond plus
+func (fc *FogCompute) watchActive(ctx context.Context) {
+	sp := fc.standby
+	sp.mu.Lock()
+	silent := time.Since(sp.lastHeard)
+	sp.mu.Unlock()
+	if silent < sp.failoverTimeout {
+		return
+	}
+
+	// Un lien de réplication coupé ne suffit pas: l'actif doit aussi être injoignable
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sp.peer+"/health", nil)
+	if err == nil {
+		if resp, err := sp.client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				sp.mu.Lock()
+				sp.lastError = "actif joignable mais réplication interrompue"
+				sp.mu.Unlock()
+				return
+			}
+		}
+	}
+	fc.takeOver(ctx, silent)
+}
+
+// takeOver fait du standby le nœud actif: adresse virtuelle reprise par
+// STANDBY_TAKEOVER_CMD, tâches répliquées restaurées et remises en queue,
+// puis admissions rouvertes
+func (fc *FogCompute) takeOver(ctx context.Context, silent time.Duration) {
+	sp := fc.standby
+	sp.mu.Lock()
+	stored := make([]storedTask, 0, len(sp.replica))
+	for _, st := range sp.replica {
+		stored = append(stored, st)
+	}
+	rejected := make([]RejectedTask, 0, len(sp.rejected))
+	for _, rt := range sp.rejected {
+		rejected = append(rejected, rt)
+	}
+	from := sp.peerNodeID
+	now := time.Now()
+	sp.role = RoleActive
+	sp.needFull = true
+	sp.synced = false
+	sp.replica = make(map[string]storedTask)
+	sp.rejected = make(map[string]RejectedTask)
+	sp.takeovers++
+	sp.tookOverAt = &now
+	sp.tookOverFrom = from
+	sp.mu.Unlock()
+
+	slog.Warn("Actif injoignable: reprise du service", "peer", sp.peer, "peer_node_id", from,
+		"silent", silent.Round(time.Millisecond).String(), "tasks", len(stored), "rejected", len(rejected))
+
+	if sp.takeoverCmd != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", sp.takeoverCmd)
+		cmd.Env = append(os.Environ(), "FOG_NODE_ID="+fc.node.ID, "FOG_PEER_NODE_ID="+from, "FOG_PEER_URL="+sp.peer)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			slog.Error("Commande de reprise en échec", "error", err, "output", strings.TrimSpace(string(out)))
+			sp.mu.Lock()
+			sp.lastError = "STANDBY_TAKEOVER_CMD: " + err.Error()
+			sp.mu.Unlock()
+		}
+	}
+
+	sortStoredTasks(stored)
+	sortRejected(rejected)
+	requeued, retries := fc.restoreFrom(stored, rejected, true)
+	slog.Info("Tâches répliquées reprises", "tasks", len(stored), "requeued", requeued,
+		"retry_scheduled", retries, "rejected", len(rejected))
+	fc.setDraining(false)
+}
+
+// Status retourne l'état de l'appariement
+func (sp *StandbyPair) Status() StandbyStatus {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	status := StandbyStatus{
+		Enabled:         sp.Enabled(),
+		Role:            sp.role,
+		Peer:            sp.peer,
+		PeerNodeID:      sp.peerNodeID,
+		Synced:          sp.synced,
+		ReplicaTasks:    len(sp.replica),
+		ReplicaRejected: len(sp.rejected),
+		Pending:         len(sp.ops),
+		Sent:            sp.sent,
+		Snapshots:       sp.snapshots,
+		Failures:        sp.failures,
+		Conflicts:       sp.conflicts,
+		Takeovers:       sp.takeovers,
+		TookOverAt:      sp.tookOverAt,
+		TookOverFrom:    sp.tookOverFrom,
+		LastError:       sp.lastError,
+	}
+	switch {
+	case sp.role == RoleActive && !sp.lastSent.IsZero():
+		last := sp.lastSent
+		status.LastReplication = &last
+	case sp.role == RoleStandby && sp.peerNodeID != "":
+		last := sp.lastHeard
+		status.LastReplication = &last
+		status.LagMs = time.Since(last).Milliseconds()
+	}
+	return status
+}
+
+// handleGetStandby retourne le rôle du nœud dans sa paire
+func (fc *FogCompute) handleGetStandby(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.standby.Status())
+}
+
+// handleReplicate reçoit un lot de réplication de l'actif; 409 si ce nœud
+// n'est pas (ou plus) en standby
+func (fc *FogCompute) handleReplicate(w http.ResponseWriter, r *http.Request) {
+	var batch ReplicationBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, "Lot de réplication invalide", http.StatusBadRequest)
+		return
+	}
+	ack := fc.standby.apply(batch)
+	w.Header().Set("Content-Type", "application/json")
+	if ack.Role != RoleStandby {
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(ack)
+}
+
+// replicatedStore double le stockage des tâches d'une réplication vers le
+// standby de la paire
+type replicatedStore struct {
+	TaskStore
+	pair *StandbyPair
+}
+
+func (s replicatedStore) SaveTask(task *Task) error {
+	s.pair.record(ReplicationOp{Op: ReplicaSave, Task: &storedTask{Task: *task, ShapingKey: task.shapingKey}})
+	return s.TaskStore.SaveTask(task)
+}
+
+func (s replicatedStore) Ping() error {
+	return pingStore(s.TaskStore)
+}
+
+func (s replicatedStore) DeleteTask(id string) error {
+	s.pair.record(ReplicationOp{Op: ReplicaDelete, ID: id})
+	return s.TaskStore.DeleteTask(id)
+}
+
+func (s replicatedStore) SaveRejected(rt RejectedTask) error {
+	s.pair.record(ReplicationOp{Op: ReplicaSaveRejected, Rejected: &rt})
+	return s.TaskStore.SaveRejected(rt)
+}
+
+func (s replicatedStore) DeleteRejected(id string) error {
+	s.pair.record(ReplicationOp{Op: ReplicaDeleteRejected, ID: id})
+	return s.TaskStore.DeleteRejected(id)
+}
+
+func (s replicatedStore) ClearRejected() error {
+	s.pair.record(ReplicationOp{Op: ReplicaClearRejected})
+	return s.TaskStore.ClearRejected()
+}
@@ -0,0 +1,224 @@
+package fognode
+
+import (
+	"encoding/hex"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// storedTask est l'entrée persistée d'une tâche: la tâche et l'état interne
+// nécessaire à sa reprise
+type storedTask struct {
+	Task       Task   `json:"task"`
+	ShapingKey string `json:"shaping_key,omitempty"`
+}
+
+// TaskStore conserve les tâches soumises et les tâches rejetées, pour
+// reconstruire la queue et les réservations après un redémarrage. Chaque
+// matériel choisit son compromis durabilité/performance: mémoire (aucune
+// durabilité), journal fichier, bbolt, Redis ou PostgreSQL. Les écritures sont
+// faites avec fc.mu détenu: les implémentations doivent rester rapides.
+type TaskStore interface {
+	// Name retourne le nom du backend (valeur de TASK_STORE)
+	Name() string
+	// LoadTasks retourne la dernière version de chaque tâche, par ordre de soumission
+	LoadTasks() ([]storedTask, error)
+	// SaveTask enregistre l'état courant d'une tâche
+	SaveTask(task *Task) error
+	// DeleteTask oublie une tâche
+	DeleteTask(id string) error
+	// LoadRejected retourne les tâches rejetées, par ordre de rejet
+	LoadRejected() ([]RejectedTask, error)
+	// SaveRejected enregistre une tâche rejetée
+	SaveRejected(rt RejectedTask) error
+	// DeleteRejected retire une tâche rejetée (resoumise)
+	DeleteRejected(id string) error
+	// ClearRejected efface toutes les tâches rejetées
+	ClearRejected() error
+	// Close libère les ressources du backend
+	Close() error
+}
+
+var (
+	taskStoreFactoriesMu sync.Mutex
+	// taskStoreFactories associe chaque nom de backend à son constructeur
+	taskStoreFactories = map[string]func() (TaskStore, error){
+		"memory":   func() (TaskStore, error) { return memoryStore{}, nil },
+		"file":     func() (TaskStore, error) { return newFileStore() },
+		"bolt":     func() (TaskStore, error) { return newBoltStore() },
+		"redis":    func() (TaskStore, error) { return newRedisStore() },
+		"postgres": func() (TaskStore, error) { return newPostgresStore() },
+	}
+)
+
+// RegisterTaskStore enregistre un backend spécifique à un déploiement,
+// utilisable ensuite dans TASK_STORE. À appeler avant la création du nœud.
+func RegisterTaskStore(name string, factory func() (TaskStore, error)) {
+	taskStoreFactoriesMu.Lock()
+	defer taskStoreFactoriesMu.Unlock()
+	taskStoreFactories[name] = factory
+}
+
+// loadTaskStore ouvre le backend configuré via TASK_STORE (file par défaut).
+// Un backend indisponible ne doit pas empêcher le nœud de servir: il démarre
+// alors sans durabilité.
+func loadTaskStore() TaskStore {
+	name := getEnv("TASK_STORE", "file")
+
+	taskStoreFactoriesMu.Lock()
+	factory, ok := taskStoreFactories[name]
+	if !ok {
+		slog.Warn("Backend de stockage inconnu, utilisation de file", "store", name)
+		name, factory = "file", taskStoreFactories["file"]
+	}
+	taskStoreFactoriesMu.Unlock()
+
+	store, err := factory()
+	if err != nil {
+		slog.Error("Ouverture du stockage des tâches impossible: tâches non persistées", "store", name, "error", err)
+		return memoryStore{}
+	}
+	return store
+}
+
+// sortStoredTasks ordonne les tâches par date de soumission
+func sortStoredTasks(tasks []storedTask) {
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Task.SubmittedAt.Before(tasks[j].Task.SubmittedAt) })
+}
+
+// sortRejected ordonne les tâches rejetées par date de rejet
+func sortRejected(rejected []RejectedTask) {
+	sort.SliceStable(rejected, func(i, j int) bool { return rejected[i].RejectedAt.Before(rejected[j].RejectedAt) })
+}
+
+// StorePinger est implémenté par les backends qui peuvent devenir
+// injoignables (base distante, fichier fermé): Ping vérifie qu'ils répondent
+type StorePinger interface {
+	Ping() error
+}
+
+// pingStore vérifie que le stockage répond; un backend sans Ping est réputé joignable
+func pingStore(store TaskStore) error {
+	if p, ok := store.(StorePinger); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
+// memoryStore ne conserve rien au-delà des structures du nœud: aucune
+// durabilité, aucun coût d'écriture
+type memoryStore struct{}
+
+func (memoryStore) Name() string                          { return "memory" }
+func (memoryStore) LoadTasks() ([]storedTask, error)      { return nil, nil }
+func (memoryStore) SaveTask(*Task) error                  { return nil }
+func (memoryStore) DeleteTask(string) error               { return nil }
+func (memoryStore) LoadRejected() ([]RejectedTask, error) { return nil, nil }
+func (memoryStore) SaveRejected(RejectedTask) error       { return nil }
+func (memoryStore) DeleteRejected(string) error           { return nil }
+func (memoryStore) ClearRejected() error                  { return nil }
+func (memoryStore) Close() error                          { return nil }
+
+// storeErr journalise l'échec d'une écriture du stockage
+func (fc *FogCompute) storeErr(op, id string, err error) {
+	if err != nil {
+		slog.Error("Écriture du stockage des tâches impossible", "op", op, "task_id", id, "store", fc.store.Name(), "error", err)
+	}
+}
+
+// saveTaskLocked persiste l'état courant d'une tâche; fc.mu doit être détenu
+func (fc *FogCompute) saveTaskLocked(task *Task) {
+	fc.storeErr("save", task.ID, fc.store.SaveTask(task))
+}
+
+// restoreTasks reconstruit l'état du nœud depuis le stockage: les tâches en
+// attente ou interrompues en cours d'exécution sont remises en queue avec leurs
+// réservations de ressources, les réessais planifiés sont réarmés et les tâches
+// terminées restent consultables
+func (fc *FogCompute) restoreTasks() {
+	stored, err := fc.store.LoadTasks()
+	if err != nil {
+		slog.Error("Lecture des tâches persistées impossible", "store", fc.store.Name(), "error", err)
+	}
+	rejected, err := fc.store.LoadRejected()
+	if err != nil {
+		slog.Error("Lecture des tâches rejetées persistées impossible", "store", fc.store.Name(), "error", err)
+	}
+	requeued, retries := fc.restoreFrom(stored, rejected, false)
+	if len(stored) > 0 || len(rejected) > 0 {
+		slog.Info("Tâches restaurées depuis le stockage", "store", fc.store.Name(), "tasks", len(stored),
+			"requeued", requeued, "retry_scheduled", retries, "rejected", len(rejected))
+	}
+}
+
+// restoreFrom installe des tâches persistées ou répliquées dans l'état du
+// nœud; persist les écrit aussi dans le stockage du nœud
+func (fc *FogCompute) restoreFrom(stored []storedTask, rejected []RejectedTask, persist bool) (requeued, retries int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.rejectedTasks = append(fc.rejectedTasks, rejected...)
+	fc.evictRejectedLocked()
+	if persist {
+		for _, rt := range rejected {
+			fc.storeErr("save_rejected", rt.Task.ID, fc.store.SaveRejected(rt))
+		}
+	}
+
+	for i := range stored {
+		task := &stored[i].Task
+		task.shapingKey = stored[i].ShapingKey
+		if _, err := hex.Decode(task.trace.TraceID[:], []byte(task.TraceID)); err == nil {
+			task.trace.Sampled = true // Les spans suivants rejoignent la trace de soumission
+		}
+
+		switch task.Status {
+		case "queued", "processing", "preempted":
+			// Une exécution interrompue par l'arrêt est reprise depuis la queue
+			task.Status = "queued"
+			fc.enqueueLocked(task)
+			requeued++
+		case "retry_scheduled":
+			// Les ressources sont de nouveau réservées au moment du réessai
+			fc.tasks[task.ID] = task
+			delay := time.Duration(0)
+			if task.NextAttemptAt != nil {
+				delay = time.Until(*task.NextAttemptAt)
+			}
+			if delay < 0 {
+				delay = 0
+			}
+			retryDelay := fc.retryPolicy(task).delay(task.Attempts)
+			time.AfterFunc(delay, func() { fc.requeueRetry(task, retryDelay) })
+			retries++
+		case "delayed":
+			// Mise en queue différée: remise de côté jusqu'à execute_at
+			if !fc.stageLocked(task) {
+				task.Status = "queued"
+				fc.enqueueLocked(task)
+				requeued++
+			}
+		case "overflow":
+			// Acceptée en débordement: réadmise quand la charge le permet
+			fc.restoreOverflowLocked(task)
+		case "deferred":
+			// Reportée après une fenêtre de capacité: remise en queue à sa fin
+			fc.tasks[task.ID] = task
+			resume := time.Duration(0)
+			if task.NextAttemptAt != nil {
+				resume = time.Until(*task.NextAttemptAt)
+			}
+			time.AfterFunc(resume, func() { fc.requeueDeferred(task) })
+		default:
+			fc.tasks[task.ID] = task
+		}
+		if persist {
+			fc.saveTaskLocked(task)
+		}
+	}
+	// Les parents ont pu se terminer pendant l'arrêt
+	fc.resolveWaitingLocked()
+	return requeued, retries
+}
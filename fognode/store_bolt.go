@@ -0,0 +1,134 @@
+package fognode
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltTasksBucket    = []byte("tasks")
+	boltRejectedBucket = []byte("rejected")
+)
+
+// boltStore conserve les tâches dans une base bbolt locale: écritures
+// transactionnelles et fichier unique, adapté aux cartes SD et eMMC
+type boltStore struct {
+	db *bolt.DB
+}
+
+// newBoltStore ouvre la base TASK_STORE_PATH (défaut: $DATA_DIR/tasks.db)
+func newBoltStore() (*boltStore, error) {
+	path := getEnv("TASK_STORE_PATH", filepath.Join(getEnv("DATA_DIR", "data"), "tasks.db"))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{boltTasksBucket, boltRejectedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Name() string { return "bolt" }
+
+// put enregistre une valeur JSON sous sa clé
+func (s *boltStore) put(bucket []byte, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), raw)
+	})
+}
+
+// remove supprime une clé
+func (s *boltStore) remove(bucket []byte, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+}
+
+// each décode chaque valeur d'un bucket
+func (s *boltStore) each(bucket []byte, fn func(raw []byte)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, raw []byte) error {
+			fn(raw)
+			return nil
+		})
+	})
+}
+
+func (s *boltStore) LoadTasks() ([]storedTask, error) {
+	tasks := make([]storedTask, 0)
+	err := s.each(boltTasksBucket, func(raw []byte) {
+		var st storedTask
+		if json.Unmarshal(raw, &st) == nil {
+			tasks = append(tasks, st)
+		}
+	})
+	sortStoredTasks(tasks)
+	return tasks, err
+}
+
+func (s *boltStore) SaveTask(task *Task) error {
+	return s.put(boltTasksBucket, task.ID, storedTask{Task: *task, ShapingKey: task.shapingKey})
+}
+
+func (s *boltStore) DeleteTask(id string) error {
+	return s.remove(boltTasksBucket, id)
+}
+
+func (s *boltStore) LoadRejected() ([]RejectedTask, error) {
+	rejected := make([]RejectedTask, 0)
+	err := s.each(boltRejectedBucket, func(raw []byte) {
+		var rt RejectedTask
+		if json.Unmarshal(raw, &rt) == nil {
+			rejected = append(rejected, rt)
+		}
+	})
+	sortRejected(rejected)
+	return rejected, err
+}
+
+func (s *boltStore) SaveRejected(rt RejectedTask) error {
+	return s.put(boltRejectedBucket, rt.Task.ID, rt)
+}
+
+func (s *boltStore) DeleteRejected(id string) error {
+	return s.remove(boltRejectedBucket, id)
+}
+
+func (s *boltStore) ClearRejected() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltRejectedBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(boltRejectedBucket)
+		return err
+	})
+}
+
+func (s *boltStore) Ping() error {
+	return s.db.View(func(*bolt.Tx) error { return nil })
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
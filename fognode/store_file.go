@@ -0,0 +1,256 @@
+package fognode
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Opérations du journal fichier
+const (
+	journalTask           = "task"
+	journalDeleteTask     = "delete_task"
+	journalRejected       = "rejected"
+	journalDeleteRejected = "delete_rejected"
+	journalClearRejected  = "clear_rejected"
+)
+
+// journalEntry est une ligne du journal
+type journalEntry struct {
+	Op       string          `json:"op"`
+	ID       string          `json:"id,omitempty"`
+	Task     json.RawMessage `json:"task,omitempty"`     // storedTask
+	Rejected json.RawMessage `json:"rejected,omitempty"` // RejectedTask
+}
+
+// fileStore journalise chaque changement en fin de fichier (la dernière entrée
+// d'une tâche fait foi), avec fsync à chaque écriture. Le journal est compacté
+// au chargement et dès qu'il contient surtout des versions périmées.
+type fileStore struct {
+	path     string
+	file     *os.File
+	entries  int                        // Lignes du journal courant
+	tasks    map[string]json.RawMessage // Dernière version de chaque tâche, pour le compactage
+	rejected map[string]json.RawMessage
+	mu       sync.Mutex
+}
+
+// newFileStore ouvre le journal TASK_STORE_PATH (défaut: $DATA_DIR/tasks.jsonl)
+func newFileStore() (*fileStore, error) {
+	s := &fileStore{
+		path:     getEnv("TASK_STORE_PATH", filepath.Join(getEnv("DATA_DIR", "data"), "tasks.jsonl")),
+		tasks:    make(map[string]json.RawMessage),
+		rejected: make(map[string]json.RawMessage),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.rewriteLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStore) Name() string { return "file" }
+
+// replay relit le journal; une dernière ligne tronquée par un arrêt brutal est ignorée
+func (s *fileStore) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Op == "" {
+			// Première version du journal: une storedTask par ligne
+			var st storedTask
+			if json.Unmarshal(scanner.Bytes(), &st) != nil || st.Task.ID == "" {
+				continue
+			}
+			e = journalEntry{Op: journalTask, ID: st.Task.ID, Task: append(json.RawMessage(nil), scanner.Bytes()...)}
+		}
+		s.applyLocked(e)
+	}
+	return scanner.Err()
+}
+
+// applyLocked applique une entrée à l'état en mémoire; s.mu doit être détenu
+func (s *fileStore) applyLocked(e journalEntry) {
+	switch e.Op {
+	case journalTask:
+		s.tasks[e.ID] = e.Task
+	case journalDeleteTask:
+		delete(s.tasks, e.ID)
+	case journalRejected:
+		s.rejected[e.ID] = e.Rejected
+	case journalDeleteRejected:
+		delete(s.rejected, e.ID)
+	case journalClearRejected:
+		s.rejected = make(map[string]json.RawMessage)
+	}
+}
+
+// rewriteLocked remplace atomiquement le journal par l'état courant et le rouvre
+// en ajout; s.mu doit être détenu
+func (s *fileStore) rewriteLocked() error {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	entries := 0
+	write := func(e journalEntry) {
+		line, _ := json.Marshal(e)
+		w.Write(line)
+		w.WriteByte('\n')
+		entries++
+	}
+	for id, raw := range s.tasks {
+		write(journalEntry{Op: journalTask, ID: id, Task: raw})
+	}
+	for id, raw := range s.rejected {
+		write(journalEntry{Op: journalRejected, ID: id, Rejected: raw})
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.entries = entries
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	return err
+}
+
+// append journalise une entrée, puis compacte si le journal a trop grossi
+func (s *fileStore) append(e journalEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.applyLocked(e)
+	s.entries++
+	if live := len(s.tasks) + len(s.rejected); s.entries > 1000 && s.entries > 4*live {
+		return s.rewriteLocked()
+	}
+	return nil
+}
+
+func (s *fileStore) LoadTasks() ([]storedTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := make([]storedTask, 0, len(s.tasks))
+	for _, raw := range s.tasks {
+		var st storedTask
+		if err := json.Unmarshal(raw, &st); err == nil {
+			tasks = append(tasks, st)
+		}
+	}
+	sortStoredTasks(tasks)
+	return tasks, nil
+}
+
+func (s *fileStore) SaveTask(task *Task) error {
+	raw, err := json.Marshal(storedTask{Task: *task, ShapingKey: task.shapingKey})
+	if err != nil {
+		return err
+	}
+	return s.append(journalEntry{Op: journalTask, ID: task.ID, Task: raw})
+}
+
+func (s *fileStore) DeleteTask(id string) error {
+	return s.append(journalEntry{Op: journalDeleteTask, ID: id})
+}
+
+func (s *fileStore) LoadRejected() ([]RejectedTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rejected := make([]RejectedTask, 0, len(s.rejected))
+	for _, raw := range s.rejected {
+		var rt RejectedTask
+		if err := json.Unmarshal(raw, &rt); err == nil {
+			rejected = append(rejected, rt)
+		}
+	}
+	sortRejected(rejected)
+	return rejected, nil
+}
+
+func (s *fileStore) SaveRejected(rt RejectedTask) error {
+	raw, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+	return s.append(journalEntry{Op: journalRejected, ID: rt.Task.ID, Rejected: raw})
+}
+
+func (s *fileStore) DeleteRejected(id string) error {
+	return s.append(journalEntry{Op: journalDeleteRejected, ID: id})
+}
+
+func (s *fileStore) ClearRejected() error {
+	return s.append(journalEntry{Op: journalClearRejected})
+}
+
+func (s *fileStore) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	_, err := s.file.Stat()
+	return err
+}
+
+func (s *fileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,276 @@
+package fognode
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisStore conserve les tâches dans Redis, partagé entre les nœuds d'un site
+// ou hébergé sur une passerelle plus robuste. Chaque nœud utilise ses propres
+// clés: <préfixe>:tasks et <préfixe>:rejected (hashes indexés par ID de tâche).
+type redisStore struct {
+	addr     string
+	username string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+
+	conn net.Conn
+	rd   *bufio.Reader
+	mu   sync.Mutex
+}
+
+// newRedisStore se connecte à TASK_STORE_URL (redis://[user:password@]host:port/db)
+func newRedisStore() (*redisStore, error) {
+	u, err := url.Parse(getEnv("TASK_STORE_URL", "redis://localhost:6379/0"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("TASK_STORE_URL: schéma redis attendu, %q reçu", u.Scheme)
+	}
+	s := &redisStore{
+		addr:    u.Host,
+		prefix:  getEnv("TASK_STORE_PREFIX", "fog:"+getEnv("NODE_ID", "fog-node-1")),
+		timeout: getEnvDuration("TASK_STORE_TIMEOUT", 2*time.Second),
+	}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("TASK_STORE_URL: base redis invalide %q", db)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.connectLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *redisStore) Name() string { return "redis" }
+
+// connectLocked ouvre la connexion, s'authentifie et sélectionne la base; s.mu doit être détenu
+func (s *redisStore) connectLocked() error {
+	conn, err := net.DialTimeout("tcp", s.addr, s.timeout)
+	if err != nil {
+		return err
+	}
+	s.conn, s.rd = conn, bufio.NewReader(conn)
+
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := s.roundTripLocked(args...); err != nil {
+			s.closeLocked()
+			return err
+		}
+	}
+	if s.db != 0 {
+		if _, err := s.roundTripLocked("SELECT", strconv.Itoa(s.db)); err != nil {
+			s.closeLocked()
+			return err
+		}
+	}
+	return nil
+}
+
+// closeLocked ferme la connexion courante; s.mu doit être détenu
+func (s *redisStore) closeLocked() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn, s.rd = nil, nil
+	}
+}
+
+// do exécute une commande, en se reconnectant une fois si la connexion est rompue
+func (s *redisStore) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			if err := s.connectLocked(); err != nil {
+				return nil, err
+			}
+		}
+		reply, err := s.roundTripLocked(args...)
+		var redisErr redisError
+		if err == nil || errors.As(err, &redisErr) || attempt > 0 {
+			return reply, err
+		}
+		s.closeLocked()
+	}
+}
+
+// redisError est une erreur retournée par le serveur (réponse -ERR)
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// roundTripLocked envoie une commande au format RESP et lit la réponse; s.mu doit être détenu
+func (s *redisStore) roundTripLocked(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := io.WriteString(s.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return s.readReply()
+}
+
+// readReply lit une réponse RESP: chaîne, erreur, entier, chaîne binaire ou tableau
+func (s *redisStore) readReply() (interface{}, error) {
+	line, err := s.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: réponse vide")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(s.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = s.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: réponse inattendue %q", line)
+}
+
+// hvalues retourne les valeurs d'un hash
+func (s *redisStore) hvalues(key string) ([]string, error) {
+	reply, err := s.do("HVALS", key)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if v, ok := item.(string); ok {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// hset enregistre une valeur JSON dans un hash
+func (s *redisStore) hset(key, field string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.do("HSET", key, field, string(raw))
+	return err
+}
+
+func (s *redisStore) tasksKey() string    { return s.prefix + ":tasks" }
+func (s *redisStore) rejectedKey() string { return s.prefix + ":rejected" }
+
+func (s *redisStore) LoadTasks() ([]storedTask, error) {
+	values, err := s.hvalues(s.tasksKey())
+	tasks := make([]storedTask, 0, len(values))
+	for _, v := range values {
+		var st storedTask
+		if json.Unmarshal([]byte(v), &st) == nil {
+			tasks = append(tasks, st)
+		}
+	}
+	sortStoredTasks(tasks)
+	return tasks, err
+}
+
+func (s *redisStore) SaveTask(task *Task) error {
+	return s.hset(s.tasksKey(), task.ID, storedTask{Task: *task, ShapingKey: task.shapingKey})
+}
+
+func (s *redisStore) DeleteTask(id string) error {
+	_, err := s.do("HDEL", s.tasksKey(), id)
+	return err
+}
+
+func (s *redisStore) LoadRejected() ([]RejectedTask, error) {
+	values, err := s.hvalues(s.rejectedKey())
+	rejected := make([]RejectedTask, 0, len(values))
+	for _, v := range values {
+		var rt RejectedTask
+		if json.Unmarshal([]byte(v), &rt) == nil {
+			rejected = append(rejected, rt)
+		}
+	}
+	sortRejected(rejected)
+	return rejected, err
+}
+
+func (s *redisStore) SaveRejected(rt RejectedTask) error {
+	return s.hset(s.rejectedKey(), rt.Task.ID, rt)
+}
+
+func (s *redisStore) DeleteRejected(id string) error {
+	_, err := s.do("HDEL", s.rejectedKey(), id)
+	return err
+}
+
+func (s *redisStore) ClearRejected() error {
+	_, err := s.do("DEL", s.rejectedKey())
+	return err
+}
+
+func (s *redisStore) Ping() error {
+	_, err := s.do("PING")
+	return err
+}
+
+func (s *redisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+	return nil
+}
@@ -0,0 +1,117 @@
+package fognode
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syncWaitMargin laisse à la réponse le temps de partir avant le délai du handler
+const syncWaitMargin = 100 * time.Millisecond
+
+// TaskWaiters réveille les soumissions synchrones quand leur tâche atteint
+// son état final
+type TaskWaiters struct {
+	maxWait time.Duration
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// NewTaskWaiters lit SYNC_WAIT_TIMEOUT
+func NewTaskWaiters() *TaskWaiters {
+	return &TaskWaiters{
+		maxWait: getEnvDuration("SYNC_WAIT_TIMEOUT", 10*time.Second),
+		waiters: make(map[string][]chan struct{}),
+	}
+}
+
+// Wait retourne un canal fermé à la fin de la tâche
+func (tw *TaskWaiters) Wait(taskID string) chan struct{} {
+	ch := make(chan struct{})
+	tw.mu.Lock()
+	tw.waiters[taskID] = append(tw.waiters[taskID], ch)
+	tw.mu.Unlock()
+	return ch
+}
+
+// Cancel abandonne une attente
+func (tw *TaskWaiters) Cancel(taskID string, ch chan struct{}) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	waiting := tw.waiters[taskID]
+	for i, c := range waiting {
+		if c == ch {
+			waiting = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(waiting) == 0 {
+		delete(tw.waiters, taskID)
+	} else {
+		tw.waiters[taskID] = waiting
+	}
+}
+
+// Done réveille les attentes d'une tâche terminée
+func (tw *TaskWaiters) Done(taskID string) {
+	tw.mu.Lock()
+	waiting := tw.waiters[taskID]
+	delete(tw.waiters, taskID)
+	tw.mu.Unlock()
+	for _, ch := range waiting {
+		close(ch)
+	}
+}
+
+// syncWait indique si la soumission attend la fin de la tâche (POST
+// /tasks/sync ou ?wait=true) et pour combien de temps: ?timeout= borné par
+// SYNC_WAIT_TIMEOUT et par le délai du handler
+func (fc *FogCompute) syncWait(r *http.Request) (time.Duration, bool) {
+	if !strings.HasSuffix(r.URL.Path, "/sync") && r.URL.Query().Get("wait") != "true" {
+		return 0, false
+	}
+	wait := fc.waiters.maxWait
+	if d, err := time.ParseDuration(r.URL.Query().Get("timeout")); err == nil && d > 0 && d < wait {
+		wait = d
+	}
+	if deadline, ok := r.Context().Deadline(); ok {
+		if left := time.Until(deadline) - syncWaitMargin; left < wait {
+			wait = left
+		}
+	}
+	return wait, true
+}
+
+// respondWhenDone répond avec la tâche terminée, résultat inclus (200), ou,
+// si elle n'est pas terminée dans le délai, avec son état courant (202) et
+// son adresse à interroger
+func (fc *FogCompute) respondWhenDone(w http.ResponseWriter, r *http.Request, taskID string, done chan struct{}, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	status := http.StatusOK
+	select {
+	case <-done:
+	case <-timer.C:
+		fc.waiters.Cancel(taskID, done)
+		status = http.StatusAccepted
+	case <-r.Context().Done():
+		fc.waiters.Cancel(taskID, done)
+		return
+	}
+
+	fc.mu.RLock()
+	var task Task
+	if t, ok := fc.tasks[taskID]; ok {
+		task = *t
+	}
+	fc.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusAccepted {
+		w.Header().Set("Location", apiPath(r, "/tasks/"+taskID))
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(task)
+}
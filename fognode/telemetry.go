@@ -0,0 +1,421 @@
+package fognode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TelemetryPoint est une lecture brute conservée par le store de télémétrie
+type TelemetryPoint struct {
+	At    time.Time `json:"t"`
+	Value float64   `json:"v"`
+}
+
+// TelemetryRollup résume les lectures d'un capteur sur une fenêtre de
+// TELEMETRY_ROLLUP_INTERVAL, une fois les lectures brutes sorties de leur rétention
+type TelemetryRollup struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Sum   float64   `json:"sum"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+}
+
+// telemetrySeries est la série d'un capteur: fenêtres résumées puis lectures
+// brutes récentes, chacune triée par date
+type telemetrySeries struct {
+	Raw     []TelemetryPoint  `json:"raw"`
+	Rollups []TelemetryRollup `json:"rollups"`
+}
+
+// TelemetrySeriesInfo décrit la série d'un capteur (GET /telemetry)
+type TelemetrySeriesInfo struct {
+	SensorID  string     `json:"sensor_id"`
+	RawPoints int        `json:"raw_points"`
+	Rollups   int        `json:"rollups"`
+	First     *time.Time `json:"first,omitempty"`
+	Last      *time.Time `json:"last,omitempty"`
+	LastValue *float64   `json:"last_value,omitempty"`
+}
+
+// TelemetryStats résume l'occupation du store de télémétrie
+type TelemetryStats struct {
+	Sensors        int   `json:"sensors"`
+	RawPoints      int   `json:"raw_points"`
+	Rollups        int   `json:"rollups"`
+	Ingested       int64 `json:"ingested"`
+	Downsampled    int64 `json:"downsampled"`     // Lectures brutes résumées dans une fenêtre
+	ExpiredRollups int64 `json:"expired_rollups"` // Fenêtres supprimées à la fin de la rétention
+}
+
+// TelemetryQuery sélectionne des lectures du store de télémétrie depuis le
+// payload d'une tâche data_aggregation ou edge_analytics, à la place de readings
+type TelemetryQuery struct {
+	Sensors []string `json:"sensors,omitempty"` // Défaut: tous les capteurs
+	From    string   `json:"from,omitempty"`    // RFC 3339, inclus
+	To      string   `json:"to,omitempty"`      // RFC 3339, exclu; défaut: maintenant
+	Since   string   `json:"since,omitempty"`   // Durée avant maintenant ("1h"), à la place de from
+}
+
+// TelemetryStore conserve en mémoire les lectures de capteurs reçues par
+// POST /telemetry: brutes pendant TELEMETRY_RAW_RETENTION, puis résumées par
+// fenêtres de TELEMETRY_ROLLUP_INTERVAL jusqu'à TELEMETRY_RETENTION. Il est
+// sauvegardé dans DATA_DIR/telemetry.json périodiquement et à l'arrêt.
+type TelemetryStore struct {
+	path           string
+	rawRetention   time.Duration
+	retention      time.Duration
+	rollupInterval time.Duration
+	maxPoints      int // Lectures brutes par capteur; au-delà les plus anciennes sont résumées
+	maxQuery       int // Lectures retournées par une requête
+	MaxBatch       int // Lectures d'un POST /telemetry
+
+	mu     sync.RWMutex
+	series map[string]*telemetrySeries
+	dirty  bool
+
+	ingested, downsampled, expired int64
+}
+
+// telemetry est le store de télémétrie du nœud (loadTelemetry)
+var telemetry *TelemetryStore
+
+// loadTelemetry lit TELEMETRY_* et recharge la télémétrie sauvegardée
+func loadTelemetry() {
+	telemetry = &TelemetryStore{
+		path:           filepath.Join(getEnv("DATA_DIR", "data"), "telemetry.json"),
+		rawRetention:   getEnvDuration("TELEMETRY_RAW_RETENTION", 24*time.Hour),
+		retention:      getEnvDuration("TELEMETRY_RETENTION", 7*24*time.Hour),
+		rollupInterval: getEnvDuration("TELEMETRY_ROLLUP_INTERVAL", 5*time.Minute),
+		maxPoints:      getEnvInt("TELEMETRY_MAX_POINTS", 100000),
+		maxQuery:       getEnvInt("TELEMETRY_MAX_QUERY_POINTS", 1000000),
+		MaxBatch:       getEnvInt("TELEMETRY_MAX_BATCH", 10000),
+		series:         make(map[string]*telemetrySeries),
+	}
+	t := telemetry
+	if t.rawRetention <= 0 || t.rollupInterval <= 0 || t.retention < t.rawRetention || t.maxPoints <= 0 || t.maxQuery <= 0 || t.MaxBatch <= 0 {
+		config.errorf("TELEMETRY_*", "les limites de télémétrie doivent être positives, TELEMETRY_RETENTION au moins égale à TELEMETRY_RAW_RETENTION")
+		return
+	}
+	if err := readJSONFile(t.path, &t.series); err != nil && !os.IsNotExist(err) {
+		slog.Error("Lecture de la télémétrie sauvegardée impossible", "path", t.path, "error", err)
+		t.series = make(map[string]*telemetrySeries)
+	}
+	if len(t.series) > 0 {
+		slog.Info("Télémétrie rechargée", "sensors", len(t.series))
+	}
+}
+
+// Insert ajoute des lectures; une lecture sans horodatage est datée de now.
+// Le lot est refusé en entier si une lecture est invalide.
+func (t *TelemetryStore) Insert(readings []SensorReading, now time.Time) error {
+	points := make([]TelemetryPoint, len(readings))
+	for i, r := range readings {
+		if r.SensorID == "" {
+			return fmt.Errorf("lecture %d: sensor_id requis", i)
+		}
+		if r.Value == nil || math.IsNaN(*r.Value) || math.IsInf(*r.Value, 0) {
+			return fmt.Errorf("lecture %d: value numérique requise", i)
+		}
+		points[i] = TelemetryPoint{At: now, Value: *r.Value}
+		if len(r.Timestamp) > 0 {
+			ts, err := parseReadingTime(r.Timestamp)
+			if err != nil {
+				return fmt.Errorf("lecture %d: %w", i, err)
+			}
+			points[i].At = ts.UTC()
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rawCutoff := now.Add(-t.rawRetention)
+	for i, r := range readings {
+		s, ok := t.series[r.SensorID]
+		if !ok {
+			s = &telemetrySeries{}
+			t.series[r.SensorID] = s
+		}
+		p := points[i]
+		if p.At.Before(rawCutoff) {
+			t.rollupLocked(s, p) // Déjà hors de la rétention brute
+			continue
+		}
+		// Les lectures arrivent le plus souvent dans l'ordre: ajout en fin
+		at := sort.Search(len(s.Raw), func(j int) bool { return s.Raw[j].At.After(p.At) })
+		s.Raw = append(s.Raw, TelemetryPoint{})
+		copy(s.Raw[at+1:], s.Raw[at:])
+		s.Raw[at] = p
+		if len(s.Raw) > t.maxPoints {
+			t.foldLocked(s, len(s.Raw)-t.maxPoints*9/10) // Par paquets: la série n'est pas recopiée à chaque lecture
+		}
+	}
+	t.ingested += int64(len(readings))
+	t.dirty = true
+	return nil
+}
+
+// rollupLocked intègre une lecture à la fenêtre qui la contient; t.mu doit être détenu
+func (t *TelemetryStore) rollupLocked(s *telemetrySeries, p TelemetryPoint) {
+	start := p.At.Truncate(t.rollupInterval)
+	i := sort.Search(len(s.Rollups), func(j int) bool { return !s.Rollups[j].Start.Before(start) })
+	if i == len(s.Rollups) || !s.Rollups[i].Start.Equal(start) {
+		s.Rollups = append(s.Rollups, TelemetryRollup{})
+		copy(s.Rollups[i+1:], s.Rollups[i:])
+		s.Rollups[i] = TelemetryRollup{Start: start, Min: p.Value, Max: p.Value}
+	}
+	r := &s.Rollups[i]
+	r.Count++
+	r.Sum += p.Value
+	r.Min = math.Min(r.Min, p.Value)
+	r.Max = math.Max(r.Max, p.Value)
+	t.downsampled++
+}
+
+// foldLocked résume les n plus anciennes lectures brutes d'une série; t.mu doit être détenu
+func (t *TelemetryStore) foldLocked(s *telemetrySeries, n int) {
+	for _, p := range s.Raw[:n] {
+		t.rollupLocked(s, p)
+	}
+	s.Raw = append(s.Raw[:0:0], s.Raw[n:]...)
+}
+
+// Compact résume les lectures brutes sorties de TELEMETRY_RAW_RETENTION et
+// supprime les fenêtres sorties de TELEMETRY_RETENTION
+func (t *TelemetryStore) Compact(now time.Time) {
+	rawCutoff, cutoff := now.Add(-t.rawRetention), now.Add(-t.retention)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, s := range t.series {
+		old := sort.Search(len(s.Raw), func(j int) bool { return !s.Raw[j].At.Before(rawCutoff) })
+		if old > 0 {
+			t.foldLocked(s, old)
+			t.dirty = true
+		}
+		expired := sort.Search(len(s.Rollups), func(j int) bool { return s.Rollups[j].Start.Add(t.rollupInterval).After(cutoff) })
+		if expired > 0 {
+			s.Rollups = append(s.Rollups[:0:0], s.Rollups[expired:]...)
+			t.expired += int64(expired)
+			t.dirty = true
+		}
+		if len(s.Raw) == 0 && len(s.Rollups) == 0 {
+			delete(t.series, id)
+		}
+	}
+}
+
+// Save écrit le store sur disque s'il a changé depuis la dernière sauvegarde
+func (t *TelemetryStore) Save() error {
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(t.series)
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Run compacte et sauvegarde le store chaque minute
+func (t *TelemetryStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Compact(time.Now())
+			if err := t.Save(); err != nil {
+				slog.Error("Sauvegarde de la télémétrie impossible", "path", t.path, "error", err)
+			}
+		}
+	}
+}
+
+// Query retourne les lectures d'une requête de tâche, triées par capteur puis
+// par date. Une fenêtre résumée compte pour une lecture à sa moyenne, datée
+// de son début.
+func (t *TelemetryStore) Query(q TelemetryQuery, now time.Time) ([]SensorReading, error) {
+	from, to := time.Time{}, now
+	var err error
+	if q.Since != "" {
+		since, err := time.ParseDuration(q.Since)
+		if err != nil || since <= 0 {
+			return nil, fmt.Errorf("query.since invalide %q: durée positive attendue (ex: 1h)", q.Since)
+		}
+		from = now.Add(-since)
+	} else if q.From != "" {
+		if from, err = time.Parse(time.RFC3339Nano, q.From); err != nil {
+			return nil, fmt.Errorf("query.from invalide: %w", err)
+		}
+	}
+	if q.To != "" {
+		if to, err = time.Parse(time.RFC3339Nano, q.To); err != nil {
+			return nil, fmt.Errorf("query.to invalide: %w", err)
+		}
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	sensors := q.Sensors
+	if len(sensors) == 0 {
+		for id := range t.series {
+			sensors = append(sensors, id)
+		}
+	}
+	sensors = append([]string(nil), sensors...)
+	sort.Strings(sensors)
+
+	readings := make([]SensorReading, 0)
+	add := func(sensor string, at time.Time, value float64) error {
+		if len(readings) >= t.maxQuery {
+			return fmt.Errorf("query: plus de %d lectures (TELEMETRY_MAX_QUERY_POINTS), restreindre la période ou les capteurs", t.maxQuery)
+		}
+		v := value
+		readings = append(readings, SensorReading{
+			SensorID:  sensor,
+			Value:     &v,
+			Timestamp: json.RawMessage(strconv.Quote(at.Format(time.RFC3339Nano))),
+		})
+		return nil
+	}
+	for _, id := range sensors {
+		s, ok := t.series[id]
+		if !ok {
+			continue
+		}
+		for _, r := range s.Rollups {
+			if !r.Start.Before(from) && r.Start.Before(to) {
+				if err := add(id, r.Start, r.Sum/float64(r.Count)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, p := range s.Raw {
+			if !p.At.Before(from) && p.At.Before(to) {
+				if err := add(id, p.At, p.Value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return readings, nil
+}
+
+// Series décrit la série de chaque capteur, par identifiant
+func (t *TelemetryStore) Series() []TelemetrySeriesInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	infos := make([]TelemetrySeriesInfo, 0, len(t.series))
+	for id, s := range t.series {
+		info := TelemetrySeriesInfo{SensorID: id, RawPoints: len(s.Raw), Rollups: len(s.Rollups)}
+		var first, last time.Time
+		switch {
+		case len(s.Rollups) > 0:
+			first = s.Rollups[0].Start
+		case len(s.Raw) > 0:
+			first = s.Raw[0].At
+		}
+		if n := len(s.Raw); n > 0 {
+			value := s.Raw[n-1].Value
+			last, info.LastValue = s.Raw[n-1].At, &value
+		} else if n := len(s.Rollups); n > 0 {
+			last = s.Rollups[n-1].Start
+		}
+		if !first.IsZero() {
+			info.First, info.Last = &first, &last
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].SensorID < infos[j].SensorID })
+	return infos
+}
+
+// Stats retourne l'occupation et les compteurs du store
+func (t *TelemetryStore) Stats() TelemetryStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	stats := TelemetryStats{
+		Sensors:        len(t.series),
+		Ingested:       t.ingested,
+		Downsampled:    t.downsampled,
+		ExpiredRollups: t.expired,
+	}
+	for _, s := range t.series {
+		stats.RawPoints += len(s.Raw)
+		stats.Rollups += len(s.Rollups)
+	}
+	return stats
+}
+
+// queryReadings retourne les lectures d'une tâche: celles du payload, ou
+// celles de sa requête au store de télémétrie
+func queryReadings(readings []SensorReading, q *TelemetryQuery) ([]SensorReading, error) {
+	if q == nil {
+		return readings, nil
+	}
+	if len(readings) > 0 {
+		return nil, fmt.Errorf("readings et query sont exclusifs")
+	}
+	return telemetry.Query(*q, time.Now())
+}
+
+// TelemetryBatch est le corps de POST /telemetry
+type TelemetryBatch struct {
+	Readings []SensorReading `json:"readings"`
+}
+
+// handleIngestTelemetry enregistre un lot de lectures de capteurs
+func (fc *FogCompute) handleIngestTelemetry(w http.ResponseWriter, r *http.Request) {
+	var batch TelemetryBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Readings) == 0 {
+		http.Error(w, "readings requis", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Readings) > telemetry.MaxBatch {
+		http.Error(w, fmt.Sprintf("au plus %d lectures par lot (TELEMETRY_MAX_BATCH)", telemetry.MaxBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := telemetry.Insert(batch.Readings, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"stored": len(batch.Readings)})
+}
+
+// handleGetTelemetry décrit les séries du store de télémétrie
+func (fc *FogCompute) handleGetTelemetry(w http.ResponseWriter, r *http.Request) {
+	series := telemetry.Series()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":  len(series),
+		"series": series,
+	})
+}
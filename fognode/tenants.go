@@ -0,0 +1,277 @@
+package fognode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TenantHeader désigne le namespace d'une soumission ou d'une consultation
+const TenantHeader = "X-Fog-Tenant"
+
+// DefaultTenant est le namespace des tâches soumises sans namespace
+const DefaultTenant = "default"
+
+// TenantQuota borne ce qu'un namespace peut occuper du nœud; 0 = illimité
+type TenantQuota struct {
+	CPU   float64 `json:"cpu,omitempty"`   // CPU réservé par ses tâches en queue ou en cours (part du nœud)
+	RAM   float64 `json:"ram,omitempty"`   // RAM réservée (part du nœud)
+	Queue int     `json:"queue,omitempty"` // Tâches en attente dans la queue
+}
+
+// TenantUsage est l'occupation courante d'un namespace
+type TenantUsage struct {
+	Queued int     `json:"queued"`
+	Active int     `json:"active"` // Tâches dont les ressources sont réservées (en queue ou en cours)
+	CPU    float64 `json:"cpu"`
+	RAM    float64 `json:"ram"`
+}
+
+// TenantStatus décrit un namespace, ses quotas et son occupation
+type TenantStatus struct {
+	Name    string      `json:"name"`
+	Quota   TenantQuota `json:"quota"`
+	Usage   TenantUsage `json:"usage"`
+	Clients []string    `json:"clients,omitempty"` // Identités rattachées (TENANT_CLIENTS)
+}
+
+// Tenants partage le nœud entre plusieurs applications: chaque tâche est
+// soumise dans un namespace dont les quotas sont appliqués à l'admission, et
+// les consultations ne voient que les tâches de leur namespace. Sans TENANTS,
+// toutes les tâches relèvent du namespace par défaut, sans quota.
+type Tenants struct {
+	quotas  map[string]TenantQuota
+	clients map[string]string       // Identité authentifiée → namespace imposé
+	usage   map[string]*TenantUsage // Protégé par fc.mu
+}
+
+// loadTenants lit TENANTS ("iot:cpu=0.4+ram=0.3+queue=20,video:queue=5") et
+// TENANT_CLIENTS ("gateway-a=iot,cam-ops=video")
+func loadTenants() *Tenants {
+	t := &Tenants{
+		quotas:  map[string]TenantQuota{DefaultTenant: {}},
+		clients: make(map[string]string),
+		usage:   make(map[string]*TenantUsage),
+	}
+	for _, entry := range getEnvList("TENANTS") {
+		name, quota, err := parseTenant(entry)
+		if err != nil {
+			slog.Warn("Namespace TENANTS invalide ignoré", "error", err)
+			continue
+		}
+		t.quotas[name] = quota
+	}
+	for _, entry := range getEnvList("TENANT_CLIENTS") {
+		client, tenant, ok := strings.Cut(entry, "=")
+		client, tenant = strings.TrimSpace(client), strings.TrimSpace(tenant)
+		if _, known := t.quotas[tenant]; !ok || client == "" || !known {
+			slog.Warn("Rattachement TENANT_CLIENTS invalide ignoré", "value", entry)
+			continue
+		}
+		t.clients[client] = tenant
+	}
+	if len(t.quotas) > 1 {
+		slog.Info("Namespaces configurés", "tenants", len(t.quotas), "bound_clients", len(t.clients))
+	}
+	return t
+}
+
+// parseTenant lit une entrée "nom:cpu=0.4+ram=0.3+queue=20" de TENANTS
+func parseTenant(entry string) (string, TenantQuota, error) {
+	name, spec, _ := strings.Cut(entry, ":")
+	name = strings.TrimSpace(name)
+	var quota TenantQuota
+	if name == "" {
+		return "", quota, fmt.Errorf("nom de namespace manquant dans %q", entry)
+	}
+	for _, item := range strings.Split(spec, "+") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(item, "=")
+		var err error
+		switch strings.TrimSpace(key) {
+		case "cpu":
+			quota.CPU, err = strconv.ParseFloat(value, 64)
+		case "ram":
+			quota.RAM, err = strconv.ParseFloat(value, 64)
+		case "queue":
+			quota.Queue, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("quota inconnu %q", key)
+		}
+		if err != nil {
+			return "", quota, fmt.Errorf("namespace %s: %v", name, err)
+		}
+	}
+	return name, quota, nil
+}
+
+// tenantOf retourne le namespace d'une tâche, celles d'avant les namespaces
+// relevant du namespace par défaut
+func tenantOf(task *Task) string {
+	if task.Tenant == "" {
+		return DefaultTenant
+	}
+	return task.Tenant
+}
+
+// Known indique si le namespace est configuré
+func (t *Tenants) Known(name string) bool {
+	_, ok := t.quotas[name]
+	return ok
+}
+
+// assignTenant fixe le namespace d'une tâche soumise: celui demandé par
+// l'en-tête X-Fog-Tenant ou le champ tenant, sinon celui de l'identité
+// rattachée, sinon le namespace par défaut. La demande d'un autre namespace
+// que celui de l'identité est vérifiée par la politique d'admission tenant.
+func (fc *FogCompute) assignTenant(task *Task, r *http.Request) {
+	if r != nil {
+		if requested := strings.TrimSpace(r.Header.Get(TenantHeader)); requested != "" {
+			task.Tenant = requested
+		}
+	}
+	if task.Tenant == "" {
+		if identity := requestIdentity(r); identity != nil {
+			task.Tenant = fc.tenants.clients[identity.Name]
+		}
+	}
+	task.Tenant = tenantOf(task)
+}
+
+// requestTenant retourne le namespace auquel une consultation est
+// restreinte: celui de l'identité rattachée, sinon celui demandé par
+// X-Fog-Tenant ou ?tenant=, sinon le namespace par défaut. all est vrai pour
+// un administrateur ou un pair qui n'en demande aucun.
+func (fc *FogCompute) requestTenant(r *http.Request) (tenant string, all bool) {
+	requested := strings.TrimSpace(r.Header.Get(TenantHeader))
+	if requested == "" {
+		requested = r.URL.Query().Get("tenant")
+	}
+	if identity := requestIdentity(r); identity != nil {
+		if bound := fc.tenants.clients[identity.Name]; bound != "" && !identity.scopes[ScopeAdmin] {
+			return bound, false
+		}
+		if requested == "" && (identity.scopes[ScopeAdmin] || identity.isPeer()) {
+			return "", true
+		}
+	}
+	if requested == "" {
+		requested = DefaultTenant
+	}
+	return requested, false
+}
+
+// visibleTo indique si la tâche est visible d'une consultation restreinte à tenant
+func visibleTo(task *Task, tenant string, all bool) bool {
+	return all || tenantOf(task) == tenant
+}
+
+// usageLocked retourne l'occupation d'un namespace; fc.mu doit être détenu
+func (t *Tenants) usageLocked(name string) *TenantUsage {
+	u, ok := t.usage[name]
+	if !ok {
+		u = &TenantUsage{}
+		t.usage[name] = u
+	}
+	return u
+}
+
+// usageOf retourne une copie de l'occupation d'un namespace; fc.mu doit être détenu, au moins en lecture
+func (t *Tenants) usageOf(name string) TenantUsage {
+	if u, ok := t.usage[name]; ok {
+		return *u
+	}
+	return TenantUsage{}
+}
+
+// reserveLocked impute les ressources réservées d'une tâche à son namespace; fc.mu doit être détenu
+func (t *Tenants) reserveLocked(task *Task) {
+	u := t.usageLocked(tenantOf(task))
+	u.Active++
+	u.CPU += task.CPUCost
+	u.RAM += task.RAMCost
+}
+
+// releaseLocked rend au namespace les ressources d'une tâche; fc.mu doit être détenu
+func (t *Tenants) releaseLocked(task *Task) {
+	u := t.usageLocked(tenantOf(task))
+	u.Active--
+	u.CPU -= task.CPUCost
+	u.RAM -= task.RAMCost
+}
+
+// checkTenant refuse les namespaces inconnus (404), ceux qu'une identité
+// rattachée à un autre namespace n'a pas le droit d'utiliser (403) et les
+// tâches qui dépasseraient un quota du namespace (429)
+func checkTenant(fc *FogCompute, ac *AdmissionContext) *Rejection {
+	t := ac.Task
+	tenant := tenantOf(t)
+	if !fc.tenants.Known(tenant) {
+		return &Rejection{Reason: fmt.Sprintf("Namespace inconnu: %s", tenant), Status: http.StatusNotFound}
+	}
+	if identity := requestIdentity(ac.Request); identity != nil && !identity.scopes[ScopeAdmin] && !identity.isPeer() {
+		if bound := fc.tenants.clients[identity.Name]; bound != "" && bound != tenant {
+			return &Rejection{Reason: fmt.Sprintf("Namespace %s interdit au client %s", tenant, identity.Name), Status: http.StatusForbidden}
+		}
+	}
+
+	quota, u := fc.tenants.quotas[tenant], ac.Tenant
+	switch {
+	case quota.Queue > 0 && u.Queued >= quota.Queue:
+		return &Rejection{Reason: fmt.Sprintf("Quota de queue du namespace %s atteint: %d/%d tâches", tenant, u.Queued, quota.Queue),
+			Status: http.StatusTooManyRequests, RetryAfter: time.Second}
+	case quota.CPU > 0 && u.CPU+t.CPUCost > quota.CPU:
+		return &Rejection{Reason: fmt.Sprintf("Quota CPU du namespace %s atteint: %.2f+%.2f/%.2f", tenant, u.CPU, t.CPUCost, quota.CPU),
+			Status: http.StatusTooManyRequests, RetryAfter: time.Second}
+	case quota.RAM > 0 && u.RAM+t.RAMCost > quota.RAM:
+		return &Rejection{Reason: fmt.Sprintf("Quota RAM du namespace %s atteint: %.2f+%.2f/%.2f", tenant, u.RAM, t.RAMCost, quota.RAM),
+			Status: http.StatusTooManyRequests, RetryAfter: time.Second}
+	}
+	return nil
+}
+
+// tenantStatusLocked retourne l'état de chaque namespace; fc.mu doit être détenu
+func (fc *FogCompute) tenantStatusLocked() []TenantStatus {
+	clients := make(map[string][]string)
+	for client, tenant := range fc.tenants.clients {
+		clients[tenant] = append(clients[tenant], client)
+	}
+	out := make([]TenantStatus, 0, len(fc.tenants.quotas))
+	for name, quota := range fc.tenants.quotas {
+		status := TenantStatus{Name: name, Quota: quota, Clients: clients[name]}
+		if u, ok := fc.tenants.usage[name]; ok {
+			status.Usage = *u
+		}
+		sort.Strings(status.Clients)
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// handleGetTenants liste les namespaces visibles du client, leurs quotas et leur occupation
+func (fc *FogCompute) handleGetTenants(w http.ResponseWriter, r *http.Request) {
+	tenant, all := fc.requestTenant(r)
+	fc.mu.RLock()
+	statuses := fc.tenantStatusLocked()
+	fc.mu.RUnlock()
+
+	visible := make([]TenantStatus, 0, len(statuses))
+	for _, s := range statuses {
+		if all || s.Name == tenant {
+			visible = append(visible, s)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":   len(visible),
+		"tenants": visible,
+	})
+}
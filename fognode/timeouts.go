@@ -0,0 +1,170 @@
+package fognode
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultRouteTimeouts sont les délais par route appliqués sans
+// HTTP_ROUTE_TIMEOUTS contraire (0: aucun délai, pour les flux longs)
+var defaultRouteTimeouts = map[string]time.Duration{
+	"GET /events": 0,
+}
+
+// RequestTimeouts borne la durée de traitement de chaque requête: le délai de
+// la route est posé sur le contexte de la requête, que les handlers
+// transmettent à leurs appels sortants (délégation aux pairs, cloud, mises à
+// jour). Un handler qui n'a encore rien répondu à l'échéance répond 503.
+type RequestTimeouts struct {
+	global   time.Duration
+	routes   map[string]time.Duration // "METHOD /modèle" ou "/modèle"
+	timedOut map[string]int64         // Requêtes arrivées à échéance, par route
+	mu       sync.Mutex
+}
+
+// NewRequestTimeouts lit HTTP_HANDLER_TIMEOUT et HTTP_ROUTE_TIMEOUTS
+// ("POST /tasks=5s,/admin/update=5m,GET /events=0")
+func NewRequestTimeouts() *RequestTimeouts {
+	rt := &RequestTimeouts{
+		global:   getEnvDuration("HTTP_HANDLER_TIMEOUT", 30*time.Second),
+		routes:   make(map[string]time.Duration, len(defaultRouteTimeouts)),
+		timedOut: make(map[string]int64),
+	}
+	for route, d := range defaultRouteTimeouts {
+		rt.routes[route] = d
+	}
+	for _, kv := range getEnvList("HTTP_ROUTE_TIMEOUTS") {
+		route, value, _ := strings.Cut(kv, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		route = strings.TrimSpace(route)
+		if err != nil || d < 0 || route == "" {
+			slog.Warn("Délai HTTP_ROUTE_TIMEOUTS invalide ignoré", "value", kv)
+			continue
+		}
+		rt.routes[route] = d
+	}
+	return rt
+}
+
+// routeKey retourne la méthode et le modèle de chemin de la route d'une requête
+func routeKey(r *http.Request) (string, string) {
+	template := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if t, err := route.GetPathTemplate(); err == nil {
+			template = t
+		}
+	}
+	// Une route versionnée partage délais, portées et classes de trafic avec
+	// sa route historique
+	if rest, ok := strings.CutPrefix(template, APIPrefix); ok && strings.HasPrefix(rest, "/") {
+		template = rest
+	}
+	return r.Method, template
+}
+
+// timeout retourne le délai applicable à une route
+func (rt *RequestTimeouts) timeout(method, template string) time.Duration {
+	if d, ok := rt.routes[method+" "+template]; ok {
+		return d
+	}
+	if d, ok := rt.routes[template]; ok {
+		return d
+	}
+	return rt.global
+}
+
+// timeoutWriter retient si le handler a commencé sa réponse
+type timeoutWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.wrote = true
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.wrote = true
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		tw.wrote = true
+		f.Flush()
+	}
+}
+
+// middleware pose le délai de la route sur le contexte de la requête
+func (rt *RequestTimeouts) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, template := routeKey(r)
+		d := rt.timeout(method, template)
+		if d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		tw := &timeoutWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		route := method + " " + template
+		rt.mu.Lock()
+		rt.timedOut[route]++
+		rt.mu.Unlock()
+		slog.Warn("Délai de traitement de la requête dépassé", "route", route, "timeout", d.String(), "responded", tw.wrote)
+		if !tw.wrote {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Délai de traitement dépassé", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// Stats retourne les requêtes arrivées à échéance, par route
+func (rt *RequestTimeouts) Stats() map[string]int64 {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	stats := make(map[string]int64, len(rt.timedOut))
+	for route, n := range rt.timedOut {
+		stats[route] = n
+	}
+	return stats
+}
+
+// sortedRoutes retourne les routes des statistiques dans l'ordre alphabétique
+func sortedRoutes(stats map[string]int64) []string {
+	routes := make([]string, 0, len(stats))
+	for route := range stats {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// newHTTPServer crée le serveur d'un listener avec les délais de connexion
+// HTTP_READ_HEADER_TIMEOUT, HTTP_READ_TIMEOUT et HTTP_IDLE_TIMEOUT. Aucun délai
+// d'écriture n'est posé au niveau de la connexion, qui couperait les flux
+// /events: la durée des réponses est bornée par RequestTimeouts.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		IdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+	}
+}
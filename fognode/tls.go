@@ -0,0 +1,283 @@
+package fognode
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Modes de vérification des certificats clients (TLS_CLIENT_AUTH)
+const (
+	ClientAuthNone     = "none"     // Aucun certificat demandé
+	ClientAuthOptional = "optional" // Vérifié s'il est présenté
+	ClientAuthPeer     = "peer"     // Exigé sur les routes entre nœuds (gossip, registre)
+	ClientAuthRequire  = "require"  // Exigé à chaque connexion (passerelles et nœuds uniquement)
+)
+
+// TLSStats décrit la configuration TLS et les refus faute de certificat client
+type TLSStats struct {
+	Enabled      bool       `json:"enabled"`
+	ClientAuth   string     `json:"client_auth"`
+	CertNotAfter *time.Time `json:"cert_not_after,omitempty"`
+	CertReloads  int        `json:"cert_reloads"`
+	MissingCert  int64      `json:"missing_client_cert"` // Routes entre nœuds refusées sans certificat
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// TLSSettings porte le certificat servi par les listeners TCP, l'autorité
+// des certificats clients et la configuration des appels sortants vers les
+// autres nœuds, qui présentent le même certificat et vérifient celui du pair
+type TLSSettings struct {
+	certFile   string
+	keyFile    string
+	clientAuth string
+	clientCAs  *x509.CertPool // Autorité des certificats clients (TLS_CLIENT_CA_FILE)
+	rootCAs    *x509.CertPool // Autorité des certificats des pairs (TLS_CA_FILE), nil: système
+	minVersion uint16
+
+	cert        *tls.Certificate
+	certModTime time.Time
+	reloads     int
+	missingCert int64
+	lastError   string
+	mu          sync.Mutex
+}
+
+// nodeTLS est la configuration TLS du nœud (désactivée sans TLS_CERT_FILE)
+var nodeTLS = &TLSSettings{clientAuth: ClientAuthNone}
+
+// loadTLSSettings lit TLS_CERT_FILE, TLS_KEY_FILE, TLS_CLIENT_CA_FILE,
+// TLS_CLIENT_AUTH, TLS_CA_FILE et TLS_MIN_VERSION
+func loadTLSSettings() (*TLSSettings, error) {
+	s := &TLSSettings{
+		certFile:   getEnv("TLS_CERT_FILE", ""),
+		keyFile:    getEnv("TLS_KEY_FILE", ""),
+		clientAuth: ClientAuthNone,
+		minVersion: tls.VersionTLS12,
+	}
+	if (s.certFile == "") != (s.keyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE et TLS_KEY_FILE doivent être définis ensemble")
+	}
+	if s.certFile != "" {
+		if err := s.reloadCert(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch v := getEnv("TLS_MIN_VERSION", "1.2"); v {
+	case "1.2":
+	case "1.3":
+		s.minVersion = tls.VersionTLS13
+	default:
+		slog.Warn("TLS_MIN_VERSION non supportée", "value", v, "default", "1.2")
+	}
+
+	if path := getEnv("TLS_CLIENT_CA_FILE", ""); path != "" {
+		pool, err := loadCertPool(path)
+		if err != nil {
+			return nil, err
+		}
+		s.clientCAs = pool
+		s.clientAuth = ClientAuthPeer
+	}
+	if mode := getEnv("TLS_CLIENT_AUTH", ""); mode != "" {
+		switch mode {
+		case ClientAuthNone, ClientAuthOptional, ClientAuthPeer, ClientAuthRequire:
+			s.clientAuth = mode
+		default:
+			return nil, fmt.Errorf("TLS_CLIENT_AUTH inconnu: %q", mode)
+		}
+	}
+	if s.clientAuth != ClientAuthNone && s.clientCAs == nil {
+		return nil, errors.New("TLS_CLIENT_AUTH exige TLS_CLIENT_CA_FILE")
+	}
+	if s.clientAuth != ClientAuthNone && s.certFile == "" {
+		return nil, errors.New("TLS_CLIENT_AUTH exige TLS_CERT_FILE et TLS_KEY_FILE")
+	}
+
+	// Les pairs sont par défaut signés par la même autorité que les clients
+	if path := getEnv("TLS_CA_FILE", ""); path != "" {
+		pool, err := loadCertPool(path)
+		if err != nil {
+			return nil, err
+		}
+		s.rootCAs = pool
+	} else {
+		s.rootCAs = s.clientCAs
+	}
+
+	if s.Enabled() {
+		slog.Info("TLS activé", "cert", s.certFile, "client_auth", s.clientAuth)
+	}
+	return s, nil
+}
+
+// loadCertPool lit un fichier PEM d'autorités de certification
+func loadCertPool(path string) (*x509.CertPool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("lecture de %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("aucun certificat PEM dans %s", path)
+	}
+	return pool, nil
+}
+
+// Enabled indique si les listeners TCP servent en TLS
+func (s *TLSSettings) Enabled() bool {
+	return s.certFile != ""
+}
+
+// reloadCert recharge le certificat et la clé si le certificat a changé sur
+// disque, pour suivre les rotations de certificats courts sans redémarrage
+func (s *TLSSettings) reloadCert() error {
+	fi, err := os.Stat(s.certFile)
+	if err != nil {
+		return fmt.Errorf("certificat TLS: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cert != nil && !fi.ModTime().After(s.certModTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		s.lastError = err.Error()
+		return fmt.Errorf("certificat TLS: %w", err)
+	}
+	if cert.Leaf == nil {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if s.cert != nil {
+		s.reloads++
+		slog.Info("Certificat TLS rechargé", "cert", s.certFile)
+	}
+	s.cert = &cert
+	s.certModTime = fi.ModTime()
+	s.lastError = ""
+	return nil
+}
+
+// certificate retourne le certificat courant, rechargé s'il a changé; en cas
+// d'échec du rechargement, le certificat précédent reste servi
+func (s *TLSSettings) certificate() *tls.Certificate {
+	if err := s.reloadCert(); err != nil {
+		slog.Warn("Rechargement du certificat TLS impossible", "error", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cert
+}
+
+// serverConfig retourne la configuration TLS des listeners
+func (s *TLSSettings) serverConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: s.minVersion,
+		ClientCAs:  s.clientCAs,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.certificate(), nil
+		},
+	}
+	switch s.clientAuth {
+	case ClientAuthOptional, ClientAuthPeer:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg
+}
+
+// listen enveloppe un listener TCP en TLS si le nœud sert en TLS
+func (s *TLSSettings) listen(ln net.Listener) net.Listener {
+	if !s.Enabled() {
+		return ln
+	}
+	return tls.NewListener(ln, s.serverConfig())
+}
+
+// Scheme retourne le schéma des URL du nœud
+func (s *TLSSettings) Scheme() string {
+	if s.Enabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// clientConfig retourne la configuration TLS des appels vers les autres
+// nœuds: autorité des pairs et, si le nœud en a un, certificat client
+func (s *TLSSettings) clientConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: s.minVersion, RootCAs: s.rootCAs}
+	if s.Enabled() {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.certificate(), nil
+		}
+	}
+	return cfg
+}
+
+// newNodeClient crée un client HTTP pour les appels vers les autres nœuds
+// (gossip, registre, délégation), qui présente le certificat client du nœud
+func newNodeClient(timeout time.Duration) *http.Client {
+	if !nodeTLS.Enabled() && nodeTLS.rootCAs == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = nodeTLS.clientConfig()
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// verifiedClientCert retourne le certificat client vérifié de la requête
+func verifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// middleware refuse (403) les routes entre nœuds sans certificat client
+// vérifié en mode peer; en mode require, la poignée de main l'a déjà exigé
+func (s *TLSSettings) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.clientAuth != ClientAuthPeer || r.TLS == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		method, template := routeKey(r)
+		if requiredScope(method, template) == ScopePeer && verifiedClientCert(r) == nil {
+			s.mu.Lock()
+			s.missingCert++
+			s.mu.Unlock()
+			slog.Warn("Certificat client requis", "route", method+" "+template, "remote", r.RemoteAddr)
+			http.Error(w, "Certificat client requis", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats retourne l'état TLS
+func (s *TLSSettings) Stats() TLSStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := TLSStats{
+		Enabled:     s.Enabled(),
+		ClientAuth:  s.clientAuth,
+		CertReloads: s.reloads,
+		MissingCert: s.missingCert,
+		LastError:   s.lastError,
+	}
+	if s.cert != nil && s.cert.Leaf != nil {
+		notAfter := s.cert.Leaf.NotAfter
+		stats.CertNotAfter = &notAfter
+	}
+	return stats
+}
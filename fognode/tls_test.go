@@ -0,0 +1,160 @@
+package fognode
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// withClientCert simule une connexion TLS dont le certificat client a été
+// vérifié par la poignée de main
+func withClientCert(r *http.Request, commonName string) *http.Request {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	r.TLS = &tls.ConnectionState{
+		HandshakeComplete: true,
+		PeerCertificates:  []*x509.Certificate{cert},
+		VerifiedChains:    [][]*x509.Certificate{{cert}},
+	}
+	return r
+}
+
+func TestClientCertIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     string // TLS_CLIENT_SCOPES
+		method     string
+		cert       string // Nom commun du certificat vérifié, vide: aucun
+		unverified bool   // Certificat présenté mais non vérifié
+		key        string
+		wantStatus int
+		wantName   string
+		wantMethod string
+	}{
+		{"pair par défaut, gossip", ScopePeer, http.MethodPost, "fog-node-2", false, "", http.StatusOK, "fog-node-2", "mtls"},
+		{"pair par défaut, soumission", ScopePeer, http.MethodGet, "fog-node-2", false, "", http.StatusOK, "fog-node-2", "mtls"},
+		{"passerelle en lecture seule", "read", http.MethodGet, "gw-1", false, "", http.StatusOK, "gw-1", "mtls"},
+		{"passerelle en lecture seule, gossip", "read", http.MethodPost, "gw-1", false, "", http.StatusForbidden, "", ""},
+		{"portée inconnue ignorée", "read+root", http.MethodGet, "gw-1", false, "", http.StatusOK, "gw-1", "mtls"},
+		{"clé d'API prioritaire", ScopePeer, http.MethodGet, "fog-node-2", false, "k-read", http.StatusOK, "lecteur", "api_key"},
+		{"certificat non vérifié", ScopePeer, http.MethodGet, "gw-1", true, "", http.StatusUnauthorized, "", ""},
+		{"sans certificat", ScopePeer, http.MethodGet, "", false, "", http.StatusUnauthorized, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_KEYS", "lecteur:k-read:read")
+			t.Setenv("TLS_CLIENT_SCOPES", tt.scopes)
+			a := NewAPIAuth()
+
+			var got *ClientIdentity
+			router := testAuthRouter(a, nil)
+			router.HandleFunc("/gossip", func(w http.ResponseWriter, r *http.Request) { got = requestIdentity(r) }).Methods(http.MethodPost)
+			router.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) { got = requestIdentity(r) }).Methods(http.MethodGet)
+
+			path := "/tasks"
+			if tt.method == http.MethodPost {
+				path = "/gossip"
+			}
+			req := httptest.NewRequest(tt.method, path, nil)
+			if tt.cert != "" {
+				withClientCert(req, tt.cert)
+				if tt.unverified {
+					req.TLS.VerifiedChains = nil
+				}
+			}
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("statut %d, attendu %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got == nil || got.Name != tt.wantName || got.Method != tt.wantMethod {
+				t.Fatalf("identité %+v, attendu %s (%s)", got, tt.wantName, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestClientCertScopes(t *testing.T) {
+	tests := []struct {
+		scopes string
+		want   []string
+		peer   bool
+	}{
+		{ScopePeer, []string{ScopePeer, ScopeRead, ScopeSubmit}, true},
+		{"read", []string{ScopeRead}, false},
+		{"read+submit", []string{ScopeRead, ScopeSubmit}, false},
+		{"admin", []string{ScopeAdmin, ScopePeer, ScopeRead, ScopeSubmit}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("TLS_CLIENT_SCOPES", tt.scopes)
+		a := NewAPIAuth()
+		identity, _ := a.authenticate(withClientCert(httptest.NewRequest(http.MethodGet, "/tasks", nil), "gw-1"))
+		if identity == nil {
+			t.Fatalf("%s: certificat vérifié refusé", tt.scopes)
+		}
+		for _, scope := range []string{ScopeRead, ScopeSubmit, ScopePeer, ScopeAdmin} {
+			want := false
+			for _, s := range tt.want {
+				want = want || s == scope
+			}
+			if identity.scopes[scope] != want {
+				t.Errorf("TLS_CLIENT_SCOPES=%s: portée %s = %v, attendu %v", tt.scopes, scope, identity.scopes[scope], want)
+			}
+		}
+		if identity.isPeer() != tt.peer {
+			t.Errorf("TLS_CLIENT_SCOPES=%s: isPeer = %v", tt.scopes, identity.isPeer())
+		}
+	}
+}
+
+func TestListenerTransports(t *testing.T) {
+	defer func(s *TLSSettings) { nodeTLS = s }(nodeTLS)
+	listeners := []*listener{
+		tcpListener("public", ":8080", nil),
+		tcpListener("admin", ":8081", nil),
+		unixListener("unix", "/run/fog.sock", 0o660, nil),
+	}
+
+	nodeTLS = &TLSSettings{clientAuth: ClientAuthNone}
+	if got, want := listenerTransports(listeners), []string{"http", "http+unix"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sans TLS: %v, attendu %v", got, want)
+	}
+	nodeTLS = &TLSSettings{certFile: "cert.pem", keyFile: "key.pem", clientAuth: ClientAuthPeer}
+	if got, want := listenerTransports(listeners), []string{"https", "http+unix"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("avec TLS: %v, attendu %v", got, want)
+	}
+	if got, want := listenerTransports(listeners[:1]), []string{"https"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TCP seul: %v, attendu %v", got, want)
+	}
+}
+
+func TestCapabilitiesProtocols(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	fc := NewFogCompute("test-node", "test-site")
+	defer fc.store.Close()
+	defer fc.journal.Close()
+
+	fc.transports = []string{"https"}
+	protocols := fc.capabilities().Protocols
+	has := func(p string) bool {
+		for _, got := range protocols {
+			if got == p {
+				return true
+			}
+		}
+		return false
+	}
+	if !has("https") || !has("http/1.1") || !has("json") || !has("cbor") || !has("msgpack") {
+		t.Errorf("protocoles annoncés: %v", protocols)
+	}
+}
@@ -0,0 +1,166 @@
+package fognode
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AdmissionTokenHeader porte le jeton d'admission lors du téléversement du payload
+const AdmissionTokenHeader = "Admission-Token"
+
+// admissionToken est une admission accordée dont les ressources sont réservées
+// en attendant le payload
+type admissionToken struct {
+	task      Task // Tâche admise, sans payload
+	expiresAt time.Time
+}
+
+// AdmissionGrant est la réponse à une demande d'admission
+type AdmissionGrant struct {
+	Token     string    `json:"token"`
+	TaskID    string    `json:"task_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Task      Task      `json:"task"`
+}
+
+// handleRequestAdmission est la première phase d'une soumission en deux temps:
+// la tâche est décrite sans son payload et passe la chaîne d'admission. Si elle
+// est admise, ses ressources sont réservées pendant ADMISSION_TOKEN_TTL et un
+// jeton est retourné; le client téléverse ensuite le payload via POST /tasks
+// avec l'en-tête Admission-Token. Un payload volumineux n'est ainsi envoyé
+// que si le nœud peut l'exécuter.
+func (fc *FogCompute) handleRequestAdmission(w http.ResponseWriter, r *http.Request) {
+	parent, _ := parseTraceParent(r.Header.Get(TraceParentHeader))
+	span := fc.tracer.Start("POST /tasks/admission", SpanKindServer, parent)
+	defer span.End()
+	w.Header().Set(TraceParentHeader, span.Context().traceParent())
+
+	var task Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		span.SetError(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task.Payload = nil // Le payload n'est attendu qu'à la seconde phase
+	task.addHop(httpHop(fc.node.ID, r))
+	task.applyDefaultCosts()
+	task.SmartScore = task.calculateScore()
+	if rej := fc.callbacks.Validate(task.CallbackURL); rej != nil {
+		span.SetError(rej.Reason)
+		rej.write(w)
+		return
+	}
+	id, rej := fc.idScheme.NewID(fc, &task)
+	if rej != nil {
+		span.SetError(rej.Reason)
+		rej.write(w)
+		return
+	}
+	task.ID = id
+
+	ac := fc.admissionContext(&task, r)
+	if ac.Draining {
+		span.SetError("drainage")
+		http.Error(w, "Nœud en cours de drainage: soumissions suspendues", http.StatusServiceUnavailable)
+		return
+	}
+
+	span.SetAttr("task.id", task.ID)
+	span.SetAttr("task.type", task.Type)
+	span.SetAttr("queue_class", task.QueueClass)
+
+	if rej := fc.admission.Admit(fc, ac); rej != nil {
+		span.SetAttr("http.status_code", rej.Status)
+		span.SetError(rej.Reason)
+		task.Status = "rejected"
+		task.SubmittedAt = time.Now()
+		fc.rejectTask(task, rej.Reason, ac.Load, ac.QueueSize)
+		rej.write(w)
+		return
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		span.SetError(err.Error())
+		http.Error(w, "Génération du jeton impossible", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(raw)
+	task.Status = "reserved"
+	grant := AdmissionGrant{Token: token, TaskID: task.ID, ExpiresAt: time.Now().Add(fc.tokenTTL), Task: task}
+
+	fc.mu.Lock()
+	fc.reserveLocked(&task)
+	fc.admissionTokens[token] = &admissionToken{task: task, expiresAt: grant.ExpiresAt}
+	fc.mu.Unlock()
+
+	slog.Info("Admission accordée, payload attendu", "task_id", task.ID, "type", task.Type,
+		"expires_at", grant.ExpiresAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// submitWithToken est la seconde phase: le payload téléversé rejoint la tâche
+// admise, qui est mise en queue sans repasser la chaîne d'admission. Seul le
+// payload est repris de la requête: les coûts et la priorité restent ceux admis.
+func (fc *FogCompute) submitWithToken(w http.ResponseWriter, span *Span, token string, upload *Task) {
+	fc.mu.Lock()
+	at, ok := fc.admissionTokens[token]
+	if ok {
+		delete(fc.admissionTokens, token)
+		if time.Now().After(at.expiresAt) {
+			fc.releaseLocked(&at.task)
+			ok = false
+		}
+	}
+	if !ok {
+		fc.mu.Unlock()
+		span.SetError("jeton d'admission invalide")
+		http.Error(w, "Jeton d'admission inconnu ou expiré: redemander l'admission", http.StatusGone)
+		return
+	}
+
+	task := at.task
+	task.Payload = upload.Payload
+	task.trace = upload.trace
+	task.TraceID = upload.TraceID
+	task.Status = "queued"
+	task.SubmittedAt = time.Now()
+	// La réservation du jeton est transférée à la tâche mise en queue
+	fc.releaseLocked(&task)
+	fc.enqueueLocked(&task)
+	response := task
+	fc.mu.Unlock()
+	fc.journal.Record(&response)
+
+	span.SetAttr("task.id", task.ID)
+	span.SetAttr("task.type", task.Type)
+	span.SetAttr("queue_class", task.QueueClass)
+
+	slog.Info("Tâche soumise avec jeton d'admission", "task_id", task.ID, "type", task.Type,
+		"priority", task.Priority, "smart_score", task.SmartScore, "trace_id", task.TraceID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// expireAdmissionTokens libère les réservations dont le payload n'est jamais arrivé
+func (fc *FogCompute) expireAdmissionTokens() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	now := time.Now()
+	for token, at := range fc.admissionTokens {
+		if now.After(at.expiresAt) {
+			delete(fc.admissionTokens, token)
+			fc.releaseLocked(&at.task)
+			slog.Info("Jeton d'admission expiré: réservation libérée", "task_id", at.task.ID)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParentHeader est l'en-tête W3C Trace Context propagé par les passerelles IoT
+const TraceParentHeader = "traceparent"
+
+// Types de span OTLP
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindConsumer = 5
+)
+
+// Codes de statut OTLP
+const (
+	SpanStatusOK    = 1
+	SpanStatusError = 2
+)
+
+// spanContext identifie un span et sa trace
+type spanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// valid indique si le contexte porte une trace
+func (sc spanContext) valid() bool {
+	return sc.TraceID != [16]byte{}
+}
+
+// traceIDString retourne l'identifiant de trace en hexadécimal
+func (sc spanContext) traceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// traceParent formate le contexte en en-tête traceparent
+func (sc spanContext) traceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// parseTraceParent lit un en-tête traceparent (version 00)
+func parseTraceParent(h string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || !sc.valid() || sc.SpanID == [8]byte{} {
+		return sc, false
+	}
+	sc.Sampled = flags&1 == 1
+	return sc, true
+}
+
+// Span est une opération tracée
+type Span struct {
+	tracer  *Tracer
+	name    string
+	kind    int
+	ctx     spanContext
+	parent  [8]byte
+	start   time.Time
+	end     time.Time
+	attrs   map[string]interface{}
+	status  int
+	message string
+}
+
+// SetAttr ajoute un attribut au span
+func (s *Span) SetAttr(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+// SetError marque le span en erreur
+func (s *Span) SetError(message string) {
+	s.status = SpanStatusError
+	s.message = message
+}
+
+// Context retourne le contexte du span, parent des spans suivants
+func (s *Span) Context() spanContext {
+	return s.ctx
+}
+
+// End termine le span et le remet à l'exportateur s'il est échantillonné
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt termine le span à l'instant donné
+func (s *Span) EndAt(t time.Time) {
+	s.end = t
+	if s.ctx.Sampled {
+		s.tracer.export(s)
+	}
+}
+
+// Tracer crée les spans des tâches et les exporte par lots vers un collecteur
+// OpenTelemetry (OTLP/HTTP JSON)
+type Tracer struct {
+	endpoint    string
+	service     string
+	instance    string
+	headers     map[string]string
+	sampleRatio float64
+	maxPending  int
+	batchSize   int
+	interval    time.Duration
+	client      *http.Client
+
+	pending  []*Span
+	exported int
+	dropped  int
+	mu       sync.Mutex
+}
+
+// NewTracer crée le traceur configuré depuis l'environnement. Sans collecteur,
+// les contextes de trace restent propagés mais aucun span n'est exporté.
+func NewTracer(nodeID string) *Tracer {
+	endpoint := getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if endpoint == "" {
+		if base := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	headers := make(map[string]string)
+	for _, kv := range getEnvList("OTEL_EXPORTER_OTLP_HEADERS") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return &Tracer{
+		endpoint:    endpoint,
+		service:     getEnv("OTEL_SERVICE_NAME", "fog-compute"),
+		instance:    nodeID,
+		headers:     headers,
+		sampleRatio: getEnvFloat("TRACE_SAMPLE_RATIO", 1),
+		maxPending:  getEnvInt("TRACE_MAX_PENDING", 2048),
+		batchSize:   getEnvInt("TRACE_BATCH_SIZE", 256),
+		interval:    getEnvDuration("TRACE_EXPORT_INTERVAL", 5*time.Second),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled indique si un collecteur est configuré
+func (t *Tracer) Enabled() bool {
+	return t.endpoint != ""
+}
+
+// Start ouvre un span. Un parent invalide démarre une nouvelle trace, échantillonnée
+// selon TRACE_SAMPLE_RATIO; sinon la décision du parent est respectée.
+func (t *Tracer) Start(name string, kind int, parent spanContext) *Span {
+	return t.StartAt(name, kind, parent, time.Now())
+}
+
+// StartAt ouvre un span commencé à l'instant donné
+func (t *Tracer) StartAt(name string, kind int, parent spanContext, start time.Time) *Span {
+	s := &Span{tracer: t, name: name, kind: kind, start: start, attrs: make(map[string]interface{})}
+	if parent.valid() {
+		s.ctx.TraceID = parent.TraceID
+		s.ctx.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.ctx.TraceID[:])
+		s.ctx.Sampled = mathrand.Float64() < t.sampleRatio
+	}
+	rand.Read(s.ctx.SpanID[:])
+	s.ctx.Sampled = s.ctx.Sampled && t.Enabled()
+	return s
+}
+
+// export place un span terminé dans le lot à envoyer
+func (t *Tracer) export(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) >= t.maxPending {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, s)
+}
+
+// Run exporte périodiquement les spans terminés
+func (t *Tracer) Run(ctx context.Context) {
+	if !t.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Dernier envoi avec un délai court pour ne pas bloquer l'arrêt
+			flushCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			t.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+// flush envoie les spans en attente par lots; les spans d'un lot refusé sont abandonnés
+func (t *Tracer) flush(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		n := len(t.pending)
+		if n == 0 {
+			t.mu.Unlock()
+			return
+		}
+		if n > t.batchSize {
+			n = t.batchSize
+		}
+		batch := t.pending[:n]
+		t.pending = t.pending[n:]
+		t.mu.Unlock()
+
+		if err := t.send(ctx, batch); err != nil {
+			t.mu.Lock()
+			t.dropped += len(batch)
+			t.mu.Unlock()
+			slog.Warn("Export des traces impossible", "error", err, "dropped_spans", len(batch))
+			return
+		}
+		t.mu.Lock()
+		t.exported += len(batch)
+		t.mu.Unlock()
+	}
+}
+
+// otlpAttributes convertit des attributs au format OTLP JSON
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch x := v.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": x}
+		case bool:
+			value = map[string]interface{}{"boolValue": x}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(x)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": x}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]interface{}{"key": k, "value": value})
+	}
+	return out
+}
+
+// send transmet un lot au collecteur
+func (t *Tracer) send(ctx context.Context, batch []*Span) error {
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, s := range batch {
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.ctx.TraceID[:]),
+			"spanId":            hex.EncodeToString(s.ctx.SpanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parent != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		if s.status != 0 {
+			span["status"] = map[string]interface{}{"code": s.status, "message": s.message}
+		}
+		spans = append(spans, span)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{
+					"service.name":        t.service,
+					"service.instance.id": t.instance,
+					"service.version":     Version,
+				}),
+			},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]interface{}{"name": "fog-compute"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("le collecteur a répondu %s", resp.Status)
+	}
+	return nil
+}
+
+// Stats retourne les compteurs d'export
+func (t *Tracer) Stats() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return map[string]interface{}{
+		"enabled":  t.Enabled(),
+		"pending":  len(t.pending),
+		"exported": t.exported,
+		"dropped":  t.dropped,
+	}
+}
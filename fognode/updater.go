@@ -0,0 +1,507 @@
+package fognode
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// États du processus de mise à jour
+const (
+	UpdateIdle        = "idle"
+	UpdateChecking    = "checking"
+	UpdateDownloading = "downloading"
+	UpdateDraining    = "draining"
+	UpdateSwapping    = "swapping"
+	UpdateFailed      = "failed"
+)
+
+// UpdateManifest décrit une version publiée sur l'endpoint de release.
+// Signature est une signature ed25519 (base64) de "<version>|<sha256>", le
+// condensé SHA-256 du binaire en hexadécimal minuscule: la version est signée
+// avec le binaire, un ancien binaire signé ne peut pas être republié sous un
+// numéro de version plus récent.
+type UpdateManifest struct {
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// signedPayload retourne le message couvert par la signature du manifeste
+func (m *UpdateManifest) signedPayload(digest []byte) []byte {
+	return []byte(m.Version + "|" + hex.EncodeToString(digest))
+}
+
+// pendingUpdate est persisté avant le redémarrage sur un nouveau binaire,
+// pour pouvoir revenir en arrière si celui-ci ne passe pas son health check
+type pendingUpdate struct {
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version"`
+	Backup          string `json:"backup"`
+	Executable      string `json:"executable"`
+	Starts          int    `json:"starts"` // Démarrages du nouveau binaire non confirmés
+}
+
+// UpdateStatus décrit l'état du sous-système de mise à jour
+type UpdateStatus struct {
+	CurrentVersion string          `json:"current_version"`
+	State          string          `json:"state"`
+	Available      *UpdateManifest `json:"available,omitempty"`
+	LastCheck      *time.Time      `json:"last_check,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	AutoApply      bool            `json:"auto_apply"`
+}
+
+// Updater vérifie l'endpoint de release, télécharge et vérifie les binaires
+// signés, draine le nœud, remplace le binaire et revient en arrière si le
+// nouveau binaire échoue à son health check
+type Updater struct {
+	fc           *FogCompute
+	releaseURL   string
+	publicKey    ed25519.PublicKey
+	interval     time.Duration
+	autoApply    bool
+	drainTimeout time.Duration
+	healthGrace  time.Duration
+	healthURL    string
+	markerPath   string
+	client       *http.Client
+
+	state     string
+	available *UpdateManifest
+	lastCheck *time.Time
+	lastError string
+	mu        sync.Mutex
+}
+
+// NewUpdater crée le gestionnaire de mise à jour configuré depuis l'environnement;
+// listenAddr est l'adresse de l'API publique, utilisée pour le health check
+func NewUpdater(fc *FogCompute, listenAddr string) *Updater {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		host, port = "", "8080"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	u := &Updater{
+		fc:           fc,
+		releaseURL:   getEnv("UPDATE_URL", ""),
+		interval:     getEnvDuration("UPDATE_CHECK_INTERVAL", time.Hour),
+		autoApply:    getEnvBool("UPDATE_AUTO_APPLY", true),
+		drainTimeout: getEnvDuration("UPDATE_DRAIN_TIMEOUT", 2*time.Minute),
+		healthGrace:  getEnvDuration("UPDATE_HEALTH_GRACE", 10*time.Second),
+		healthURL:    nodeTLS.Scheme() + "://" + net.JoinHostPort(host, port) + "/health",
+		markerPath:   filepath.Join(getEnv("DATA_DIR", "data"), "update-pending.json"),
+		client:       &http.Client{Timeout: 5 * time.Minute},
+		state:        UpdateIdle,
+	}
+	if key := getEnv("UPDATE_PUBLIC_KEY", ""); key != "" {
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			slog.Error("UPDATE_PUBLIC_KEY invalide: les mises à jour sont désactivées")
+		} else {
+			u.publicKey = ed25519.PublicKey(raw)
+		}
+	}
+	return u
+}
+
+// Enabled indique si les mises à jour sont configurées (endpoint et clé de signature)
+func (u *Updater) Enabled() bool {
+	return u.releaseURL != "" && u.publicKey != nil
+}
+
+// Run vérifie une éventuelle mise à jour en attente de confirmation, puis
+// interroge périodiquement l'endpoint de release
+func (u *Updater) Run(ctx context.Context) {
+	u.confirmPending(ctx)
+
+	if !u.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			manifest, err := u.Check(ctx)
+			if err != nil || manifest == nil || !u.autoApply {
+				continue
+			}
+			if err := u.Apply(ctx, manifest); err != nil {
+				slog.Error("Échec de la mise à jour", "version", manifest.Version, "error", err)
+			}
+		}
+	}
+}
+
+// setState met à jour l'état courant
+func (u *Updater) setState(state string, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.state = state
+	if err != nil {
+		u.lastError = err.Error()
+	}
+}
+
+// Check interroge l'endpoint de release et retourne le manifeste s'il annonce
+// une version plus récente que la version courante
+func (u *Updater) Check(ctx context.Context) (*UpdateManifest, error) {
+	if !u.Enabled() {
+		return nil, errors.New("mises à jour non configurées (UPDATE_URL, UPDATE_PUBLIC_KEY)")
+	}
+	u.setState(UpdateChecking, nil)
+
+	manifest, err := u.fetchManifest(ctx)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := time.Now()
+	u.lastCheck = &now
+	u.state = UpdateIdle
+	if err != nil {
+		u.lastError = err.Error()
+		return nil, err
+	}
+	u.lastError = ""
+	if compareVersions(manifest.Version, Version) <= 0 {
+		u.available = nil
+		return nil, nil
+	}
+	u.available = manifest
+	slog.Info("Mise à jour disponible", "current_version", Version, "version", manifest.Version)
+	return manifest, nil
+}
+
+// fetchManifest télécharge le manifeste de release
+func (u *Updater) fetchManifest(ctx context.Context) (*UpdateManifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.releaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint de release: %s", resp.Status)
+	}
+
+	var manifest UpdateManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("manifeste invalide: %w", err)
+	}
+	if manifest.Version == "" || manifest.URL == "" || manifest.SHA256 == "" || manifest.Signature == "" {
+		return nil, errors.New("manifeste incomplet")
+	}
+	return &manifest, nil
+}
+
+// Apply télécharge et vérifie le binaire, draine le nœud, remplace le binaire
+// courant et redémarre dessus
+func (u *Updater) Apply(ctx context.Context, manifest *UpdateManifest) error {
+	u.mu.Lock()
+	if u.state != UpdateIdle && u.state != UpdateFailed {
+		u.mu.Unlock()
+		return fmt.Errorf("mise à jour déjà en cours (%s)", u.state)
+	}
+	u.state = UpdateDownloading
+	u.mu.Unlock()
+
+	fail := func(err error) error {
+		u.setState(UpdateFailed, err)
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fail(err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fail(err)
+	}
+
+	staged := exe + ".new"
+	if err := u.download(ctx, manifest, staged); err != nil {
+		os.Remove(staged)
+		return fail(err)
+	}
+
+	// Drainer: plus aucune admission, attendre la fin des tâches en cours
+	u.setState(UpdateDraining, nil)
+	u.fc.setDraining(true)
+	if !u.fc.waitIdle(ctx, u.drainTimeout) {
+		u.fc.setDraining(false)
+		os.Remove(staged)
+		return fail(errors.New("délai de drainage dépassé, mise à jour annulée"))
+	}
+
+	u.setState(UpdateSwapping, nil)
+	backup := exe + ".bak"
+	marker := pendingUpdate{
+		Version:         manifest.Version,
+		PreviousVersion: Version,
+		Backup:          backup,
+		Executable:      exe,
+	}
+	if err := writeJSONFile(u.markerPath, marker); err != nil {
+		u.fc.setDraining(false)
+		os.Remove(staged)
+		return fail(err)
+	}
+	if err := os.Rename(exe, backup); err != nil {
+		u.fc.setDraining(false)
+		os.Remove(u.markerPath)
+		return fail(err)
+	}
+	if err := os.Rename(staged, exe); err != nil {
+		os.Rename(backup, exe)
+		u.fc.setDraining(false)
+		os.Remove(u.markerPath)
+		return fail(err)
+	}
+
+	slog.Info("Binaire remplacé, redémarrage", "version", manifest.Version)
+	err = restartExecutable(exe)
+
+	// Le processus n'a pas été remplacé: revenir au binaire courant et
+	// reprendre les admissions
+	slog.Error("Redémarrage sur le nouveau binaire impossible, retour au binaire courant", "version", manifest.Version, "error", err)
+	if rerr := os.Rename(backup, exe); rerr != nil {
+		err = fmt.Errorf("%w; restauration du binaire impossible: %v", err, rerr)
+	}
+	os.Remove(u.markerPath)
+	u.fc.setDraining(false)
+	return fail(err)
+}
+
+// download vérifie la signature du manifeste, puis récupère le binaire et
+// vérifie son condensé
+func (u *Updater) download(ctx context.Context, manifest *UpdateManifest, dest string) error {
+	expected, err := hex.DecodeString(manifest.SHA256)
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("sha256 invalide: %q", manifest.SHA256)
+	}
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return fmt.Errorf("signature invalide: %w", err)
+	}
+	if !ed25519.Verify(u.publicKey, manifest.signedPayload(expected), signature) {
+		return errors.New("signature du manifeste invalide (version et condensé)")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifest.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("téléchargement du binaire: %s", resp.Status)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return errors.New("condensé SHA-256 du binaire incorrect")
+	}
+	return nil
+}
+
+// confirmPending valide une mise à jour récente par un health check local,
+// ou revient au binaire précédent si le nouveau binaire est défaillant
+func (u *Updater) confirmPending(ctx context.Context) {
+	var marker pendingUpdate
+	if err := readJSONFile(u.markerPath, &marker); err != nil {
+		return
+	}
+
+	// Un démarrage précédent de ce binaire n'a jamais été confirmé: il a
+	// probablement planté, revenir en arrière immédiatement
+	if marker.Starts > 0 {
+		u.rollback(marker, "le nouveau binaire n'a pas confirmé un démarrage précédent")
+		return
+	}
+	marker.Starts++
+	writeJSONFile(u.markerPath, marker)
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(u.healthGrace):
+	}
+
+	client := newNodeClient(3 * time.Second)
+	if nodeTLS.Enabled() {
+		// Le certificat du nœud ne couvre pas forcément l'adresse de bouclage
+		client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify = true
+	}
+	resp, err := client.Get(u.healthURL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode != http.StatusOK {
+		u.rollback(marker, "health check du nouveau binaire en échec")
+		return
+	}
+
+	os.Remove(marker.Backup)
+	os.Remove(u.markerPath)
+	slog.Info("Mise à jour confirmée", "version", marker.Version)
+}
+
+// rollback restaure le binaire précédent et redémarre dessus
+func (u *Updater) rollback(marker pendingUpdate, reason string) {
+	slog.Warn("Retour à la version précédente", "version", marker.PreviousVersion, "reason", reason)
+	if err := os.Rename(marker.Backup, marker.Executable); err != nil {
+		u.setState(UpdateFailed, fmt.Errorf("retour arrière impossible: %w", err))
+		return
+	}
+	os.Remove(u.markerPath)
+	if err := restartExecutable(marker.Executable); err != nil {
+		u.setState(UpdateFailed, err)
+	}
+}
+
+// Status retourne l'état du sous-système de mise à jour
+func (u *Updater) Status() UpdateStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return UpdateStatus{
+		CurrentVersion: Version,
+		State:          u.state,
+		Available:      u.available,
+		LastCheck:      u.lastCheck,
+		LastError:      u.lastError,
+		AutoApply:      u.autoApply,
+	}
+}
+
+// restartExecutable remplace le processus courant par le binaire donné
+func restartExecutable(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
+
+// compareVersions compare deux versions de la forme [v]X.Y.Z; retourne -1, 0 ou 1
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(strings.SplitN(pa[i], "-", 2)[0])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(strings.SplitN(pb[i], "-", 2)[0])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeJSONFile écrit atomiquement une valeur JSON dans un fichier
+func writeJSONFile(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readJSONFile lit une valeur JSON depuis un fichier
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// handleGetUpdate retourne l'état des mises à jour
+func (fc *FogCompute) handleGetUpdate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.updater.Status())
+}
+
+// handleApplyUpdate déclenche une vérification et, si une version plus récente
+// existe, son installation en arrière-plan
+func (fc *FogCompute) handleApplyUpdate(w http.ResponseWriter, r *http.Request) {
+	manifest, err := fc.updater.Check(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if manifest == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Aucune mise à jour disponible",
+			"version": Version,
+		})
+		return
+	}
+
+	go func() {
+		if err := fc.updater.Apply(context.Background(), manifest); err != nil {
+			slog.Error("Échec de la mise à jour", "version", manifest.Version, "error", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Mise à jour lancée",
+		"from":    Version,
+		"to":      manifest.Version,
+	})
+}
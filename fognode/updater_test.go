@@ -0,0 +1,53 @@
+package fognode
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdaterDownloadSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaries := map[string][]byte{"/v1": []byte("binaire 1.0.0"), "/v2": []byte("binaire 2.0.0")}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binaries[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	sign := func(version, path string) *UpdateManifest {
+		digest := sha256.Sum256(binaries[path])
+		m := &UpdateManifest{Version: version, URL: srv.URL + path, SHA256: hex.EncodeToString(digest[:])}
+		m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, m.signedPayload(digest[:])))
+		return m
+	}
+	u := &Updater{publicKey: pub, client: srv.Client()}
+	dest := filepath.Join(t.TempDir(), "fog-compute.new")
+
+	if err := u.download(context.Background(), sign("2.0.0", "/v2"), dest); err != nil {
+		t.Fatalf("manifeste valide refusé: %v", err)
+	}
+
+	// Ancien binaire signé republié sous une version plus récente
+	replay := sign("1.0.0", "/v1")
+	replay.Version = "9.0.0"
+	if err := u.download(context.Background(), replay, dest); err == nil {
+		t.Error("ancien binaire accepté sous une nouvelle version")
+	}
+
+	// Binaire différent du condensé signé
+	tampered := sign("2.0.0", "/v2")
+	tampered.URL = srv.URL + "/v1"
+	if err := u.download(context.Background(), tampered, dest); err == nil {
+		t.Error("binaire modifié accepté")
+	}
+}
@@ -0,0 +1,626 @@
+package fognode
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Politiques d'abandon quand le tampon d'uplink est plein
+const (
+	DropOldest = "drop_oldest"
+	DropNewest = "drop_newest"
+)
+
+// UplinkRecord est un enregistrement destiné au cloud (résultat, événement ou télémétrie)
+type UplinkRecord struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"` // "result", "event" ou "telemetry"
+	NodeID    string          `json:"node_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// UplinkStatus décrit l'état du lien montant vers le cloud
+type UplinkStatus struct {
+	Enabled         bool       `json:"enabled"`
+	Online          bool       `json:"online"`
+	BufferedRecords int        `json:"buffered_records"`
+	BufferedBytes   int        `json:"buffered_bytes"`
+	MaxRecords      int        `json:"max_records"`
+	MaxBytes        int        `json:"max_bytes"`
+	DropPolicy      string     `json:"drop_policy"`
+	Sent            int        `json:"sent"`
+	Dropped         int        `json:"dropped"`
+	Rejected        int        `json:"rejected"`             // Enregistrements refusés par le cloud (400, 413, 422), abandonnés
+	TaskTypes       []string   `json:"task_types,omitempty"` // Types dont les résultats sont transmis (absent: tous)
+	Failures        int        `json:"consecutive_failures"`
+	NextAttempt     *time.Time `json:"next_attempt,omitempty"` // Prochaine tentative après un échec
+	EventTypes      []string   `json:"event_types,omitempty"`  // Événements transmis (UPLINK_EVENTS)
+	EventsDropped   int        `json:"events_dropped"`         // Événements perdus faute de place avant leur mise en tampon
+	OfflineSince    *time.Time `json:"offline_since,omitempty"`
+	Outages         int        `json:"outages"`                // Pertes du lien depuis le démarrage
+	SyncLagSeconds  float64    `json:"sync_lag_seconds"`       // Âge du plus ancien enregistrement non transmis
+	LastContact     *time.Time `json:"last_contact,omitempty"` // Dernière réponse du cloud (envoi ou sonde)
+	LastFlush       *time.Time `json:"last_flush,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// errUplinkRejected signale un lot que le cloud refuse et refusera encore:
+// le réessayer tel quel bloquerait le tampon
+var errUplinkRejected = errors.New("lot refusé par le cloud")
+
+// Uplink stocke durablement les enregistrements destinés au cloud quand le
+// lien est coupé et les transmet par lots dès que la connectivité revient
+type Uplink struct {
+	url        string
+	token      string
+	nodeID     string
+	path       string
+	maxRecords int
+	maxBytes   int
+	batchSize  int
+	dropPolicy string
+	interval   time.Duration
+	maxBackoff time.Duration
+	compress   bool
+	taskTypes  map[string]bool // Types dont les résultats sont transmis; vide: tous
+	eventTypes map[string]bool // Événements du cycle de vie transmis; vide: aucun
+	probeEvery time.Duration   // Sondage du lien quand le tampon est vide
+	client     *http.Client
+	kick       chan struct{}  // Tentative immédiate demandée (POST /uplink/flush)
+	events     chan TaskEvent // Événements en attente de leur mise en tampon
+
+	buffer      []UplinkRecord
+	sizes       []int // Taille sérialisée de chaque enregistrement
+	bytes       int
+	online      bool
+	sent        int
+	dropped     int
+	rejected    int
+	failures    int       // Échecs d'envoi consécutifs
+	nextAttempt time.Time // Pas de tentative avant cette date (repli exponentiel)
+	offlineAt   time.Time // Début de la coupure en cours
+	outages     int
+	lastContact time.Time
+	eventsLost  int
+	seq         int64
+	lastFlush   *time.Time
+	lastError   string
+	mu          sync.Mutex
+}
+
+// NewUplink crée le lien montant configuré depuis l'environnement et recharge
+// le tampon persisté lors d'une exécution précédente
+func NewUplink(nodeID string) *Uplink {
+	u := &Uplink{
+		url:        getEnv("UPLINK_URL", ""),
+		token:      getEnv("UPLINK_TOKEN", ""),
+		nodeID:     nodeID,
+		path:       filepath.Join(getEnv("DATA_DIR", "data"), "uplink.jsonl"),
+		maxRecords: getEnvInt("UPLINK_MAX_RECORDS", 10000),
+		maxBytes:   getEnvInt("UPLINK_MAX_BYTES", 50*1024*1024),
+		batchSize:  getEnvInt("UPLINK_BATCH_SIZE", 100),
+		dropPolicy: getEnv("UPLINK_DROP_POLICY", DropOldest),
+		interval:   getEnvDuration("UPLINK_FLUSH_INTERVAL", 5*time.Second),
+		maxBackoff: getEnvDuration("UPLINK_MAX_BACKOFF", 5*time.Minute),
+		compress:   getEnvBool("UPLINK_COMPRESS", false),
+		taskTypes:  make(map[string]bool),
+		eventTypes: make(map[string]bool),
+		probeEvery: getEnvDuration("UPLINK_PROBE_INTERVAL", 30*time.Second),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		kick:       make(chan struct{}, 1),
+		events:     make(chan TaskEvent, 1024),
+		buffer:     make([]UplinkRecord, 0),
+		sizes:      make([]int, 0),
+	}
+	for _, t := range getEnvList("UPLINK_TASK_TYPES") {
+		u.taskTypes[t] = true
+	}
+	for _, t := range getEnvList("UPLINK_EVENTS") {
+		u.eventTypes[t] = true
+	}
+	if u.dropPolicy != DropOldest && u.dropPolicy != DropNewest {
+		slog.Warn("Politique d'abandon inconnue", "policy", u.dropPolicy, "default", DropOldest)
+		u.dropPolicy = DropOldest
+	}
+	if u.Enabled() {
+		u.load()
+	}
+	return u
+}
+
+// Enabled indique si un endpoint cloud est configuré
+func (u *Uplink) Enabled() bool {
+	return u.url != ""
+}
+
+// ForwardsResult indique si les résultats des tâches de ce type sont transmis au cloud
+func (u *Uplink) ForwardsResult(taskType string) bool {
+	return u.Enabled() && (len(u.taskTypes) == 0 || u.taskTypes[taskType])
+}
+
+// Offline indique que le lien montant est configuré mais que le cloud ne
+// répond plus: le nœud continue de traiter localement et tamponne ce qu'il
+// doit transmettre
+func (u *Uplink) Offline() bool {
+	if !u.Enabled() {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !u.offlineAt.IsZero()
+}
+
+// ForwardEvent confie au tampon un événement du cycle de vie des tâches s'il
+// fait partie de UPLINK_EVENTS. Appelé par l'EventHub, il ne bloque jamais:
+// l'écriture sur disque est faite par Run.
+func (u *Uplink) ForwardEvent(ev TaskEvent) {
+	if !u.Enabled() || !u.eventTypes[ev.Type] {
+		return
+	}
+	select {
+	case u.events <- ev:
+	default:
+		u.mu.Lock()
+		u.eventsLost++
+		u.mu.Unlock()
+	}
+}
+
+// load recharge le tampon depuis le disque
+func (u *Uplink) load() {
+	f, err := os.Open(u.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Lecture du tampon d'uplink impossible", "error", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec UplinkRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		u.buffer = append(u.buffer, rec)
+		u.sizes = append(u.sizes, len(scanner.Bytes())+1)
+		u.bytes += len(scanner.Bytes()) + 1
+	}
+	if len(u.buffer) > 0 {
+		slog.Info("Tampon d'uplink rechargé", "buffered_records", len(u.buffer))
+	}
+}
+
+// persistLocked réécrit atomiquement le tampon sur disque; u.mu doit être détenu
+func (u *Uplink) persistLocked() error {
+	if err := os.MkdirAll(filepath.Dir(u.path), 0o755); err != nil {
+		return err
+	}
+	tmp := u.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, rec := range u.buffer {
+		line, _ := json.Marshal(rec)
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	return os.Rename(tmp, u.path)
+}
+
+// appendLocked ajoute un enregistrement en fin de fichier; u.mu doit être détenu
+func (u *Uplink) appendLocked(line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(u.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(u.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Enqueue place un enregistrement dans le tampon durable, en appliquant la
+// politique d'abandon si les limites sont atteintes
+func (u *Uplink) Enqueue(kind string, data interface{}) {
+	if !u.Enabled() {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Sérialisation d'un enregistrement d'uplink impossible", "error", err)
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.seq++
+	rec := UplinkRecord{
+		ID:        fmt.Sprintf("%s-%d-%d", u.nodeID, time.Now().UnixNano(), u.seq),
+		Kind:      kind,
+		NodeID:    u.nodeID,
+		CreatedAt: time.Now(),
+		Data:      raw,
+	}
+	line, _ := json.Marshal(rec)
+	size := len(line) + 1
+
+	evicted := false
+	for len(u.buffer) > 0 && (len(u.buffer) >= u.maxRecords || u.bytes+size > u.maxBytes) {
+		if u.dropPolicy == DropNewest {
+			u.dropped++
+			return
+		}
+		u.bytes -= u.sizes[0]
+		u.buffer = u.buffer[1:]
+		u.sizes = u.sizes[1:]
+		u.dropped++
+		evicted = true
+	}
+
+	u.buffer = append(u.buffer, rec)
+	u.sizes = append(u.sizes, size)
+	u.bytes += size
+
+	if evicted {
+		err = u.persistLocked()
+	} else {
+		err = u.appendLocked(line)
+	}
+	if err != nil {
+		slog.Error("Écriture du tampon d'uplink impossible", "error", err)
+	}
+}
+
+// Run transmet périodiquement le tampon au cloud. Après un échec, les
+// tentatives s'espacent (repli exponentiel jusqu'à UPLINK_MAX_BACKOFF) jusqu'au
+// retour du lien, sauf tentative immédiate demandée par Flush. Tampon vide, le
+// lien est sondé toutes les UPLINK_PROBE_INTERVAL pour détecter une coupure
+// avant le prochain envoi.
+func (u *Uplink) Run(ctx context.Context) {
+	if !u.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-u.events:
+			u.Enqueue("event", ev)
+			continue
+		case <-ticker.C:
+			u.mu.Lock()
+			waiting := time.Now().Before(u.nextAttempt)
+			u.mu.Unlock()
+			if waiting {
+				continue
+			}
+		case <-u.kick:
+		}
+		// Vider le tampon tant que les lots passent
+		for u.flush(ctx) {
+		}
+		u.probe(ctx)
+	}
+}
+
+// probe vérifie que le cloud répond quand aucun envoi ne l'a fait récemment:
+// toute réponse HTTP à un HEAD sur UPLINK_URL suffit
+func (u *Uplink) probe(ctx context.Context) {
+	u.mu.Lock()
+	due := len(u.buffer) == 0 && (!u.offlineAt.IsZero() || time.Since(u.lastContact) >= u.probeEvery)
+	u.mu.Unlock()
+	if !due {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.url, nil)
+	if err != nil {
+		return
+	}
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+	resp, err := u.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err != nil {
+		u.unreachableLocked(err)
+	} else {
+		u.reachedLocked()
+	}
+}
+
+// reachedLocked enregistre une réponse du cloud et la fin d'une éventuelle
+// coupure; u.mu doit être détenu
+func (u *Uplink) reachedLocked() {
+	now := time.Now()
+	if !u.offlineAt.IsZero() {
+		slog.Info("Uplink en ligne: synchronisation des enregistrements en attente",
+			"offline_for", now.Sub(u.offlineAt).Round(time.Second).String(), "buffered_records", len(u.buffer))
+	} else if !u.online {
+		slog.Info("Uplink en ligne", "buffered_records", len(u.buffer))
+	}
+	u.online = true
+	u.offlineAt = time.Time{}
+	u.failures = 0
+	u.nextAttempt = time.Time{}
+	u.lastContact = now
+}
+
+// unreachableLocked enregistre un échec de contact avec le cloud et espace
+// les tentatives suivantes; u.mu doit être détenu
+func (u *Uplink) unreachableLocked(err error) {
+	if u.offlineAt.IsZero() {
+		slog.Warn("Uplink hors ligne: traitement local, enregistrements tamponnés", "error", err, "buffered_records", len(u.buffer))
+		u.offlineAt = time.Now()
+		u.outages++
+	}
+	u.online = false
+	u.lastError = err.Error()
+	u.failures++
+	backoff := u.interval << min(u.failures-1, 16)
+	if backoff > u.maxBackoff || backoff <= 0 {
+		backoff = u.maxBackoff
+	}
+	u.nextAttempt = time.Now().Add(backoff)
+}
+
+// Flush demande une tentative d'envoi immédiate, sans attendre la fin du repli
+func (u *Uplink) Flush() {
+	select {
+	case u.kick <- struct{}{}:
+	default:
+	}
+}
+
+// flush envoie un lot; retourne true s'il reste des données et que l'envoi a réussi
+func (u *Uplink) flush(ctx context.Context) bool {
+	u.mu.Lock()
+	n := len(u.buffer)
+	if n == 0 {
+		u.mu.Unlock()
+		return false
+	}
+	if n > u.batchSize {
+		n = u.batchSize
+	}
+	batch := make([]UplinkRecord, n)
+	copy(batch, u.buffer[:n])
+	u.mu.Unlock()
+
+	err := u.send(ctx, batch)
+	// Lot refusé: le réduire de moitié jusqu'à isoler l'enregistrement fautif
+	for errors.Is(err, errUplinkRejected) && len(batch) > 1 {
+		batch = batch[:len(batch)/2]
+		err = u.send(ctx, batch)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if errors.Is(err, errUplinkRejected) {
+		// Le lien fonctionne: l'enregistrement refusé est abandonné pour ne pas bloquer les suivants
+		slog.Error("Enregistrement d'uplink refusé par le cloud, abandonné", "record_id", batch[0].ID, "kind", batch[0].Kind, "error", err)
+		u.rejected += u.removeLocked(batch)
+		u.reachedLocked()
+		u.lastError = err.Error()
+		if err := u.persistLocked(); err != nil {
+			slog.Error("Écriture du tampon d'uplink impossible", "error", err)
+		}
+		return len(u.buffer) > 0
+	}
+	if err != nil {
+		u.unreachableLocked(err)
+		return false
+	}
+
+	u.reachedLocked()
+	u.lastError = ""
+	now := time.Now()
+	u.lastFlush = &now
+	u.removeLocked(batch)
+	u.sent += len(batch)
+
+	if err := u.persistLocked(); err != nil {
+		slog.Error("Écriture du tampon d'uplink impossible", "error", err)
+	}
+	return len(u.buffer) > 0
+}
+
+// removeLocked retire du tampon un lot traité et retourne le nombre
+// d'enregistrements retirés; u.mu doit être détenu. Le lot est en tête du
+// tampon, sauf les enregistrements évincés entre-temps: seuls ceux qui
+// correspondent encore sont retirés.
+func (u *Uplink) removeLocked(batch []UplinkRecord) int {
+	ids := make(map[string]bool, len(batch))
+	for _, rec := range batch {
+		ids[rec.ID] = true
+	}
+	removed := 0
+	for removed < len(u.buffer) && ids[u.buffer[removed].ID] {
+		u.bytes -= u.sizes[removed]
+		removed++
+	}
+	u.buffer = u.buffer[removed:]
+	u.sizes = u.sizes[removed:]
+	return removed
+}
+
+// send transmet un lot au cloud
+func (u *Uplink) send(ctx context.Context, batch []UplinkRecord) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"node_id": u.nodeID,
+		"records": batch,
+	})
+	if err != nil {
+		return err
+	}
+	if u.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge ||
+		resp.StatusCode == http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", errUplinkRejected, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("le cloud a répondu %s", resp.Status)
+	}
+	return nil
+}
+
+// Status retourne l'état courant du lien montant
+func (u *Uplink) Status() UplinkStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var taskTypes []string
+	for t := range u.taskTypes {
+		taskTypes = append(taskTypes, t)
+	}
+	sort.Strings(taskTypes)
+	var eventTypes []string
+	for t := range u.eventTypes {
+		eventTypes = append(eventTypes, t)
+	}
+	sort.Strings(eventTypes)
+	var nextAttempt, offlineSince, lastContact *time.Time
+	if !u.nextAttempt.IsZero() {
+		next := u.nextAttempt
+		nextAttempt = &next
+	}
+	if !u.offlineAt.IsZero() {
+		since := u.offlineAt
+		offlineSince = &since
+	}
+	if !u.lastContact.IsZero() {
+		contact := u.lastContact
+		lastContact = &contact
+	}
+	lag := 0.0
+	if len(u.buffer) > 0 {
+		lag = time.Since(u.buffer[0].CreatedAt).Seconds()
+	}
+	return UplinkStatus{
+		Enabled:         u.Enabled(),
+		Online:          u.online,
+		BufferedRecords: len(u.buffer),
+		BufferedBytes:   u.bytes,
+		MaxRecords:      u.maxRecords,
+		MaxBytes:        u.maxBytes,
+		DropPolicy:      u.dropPolicy,
+		Sent:            u.sent,
+		Dropped:         u.dropped,
+		Rejected:        u.rejected,
+		TaskTypes:       taskTypes,
+		Failures:        u.failures,
+		NextAttempt:     nextAttempt,
+		EventTypes:      eventTypes,
+		EventsDropped:   u.eventsLost,
+		OfflineSince:    offlineSince,
+		Outages:         u.outages,
+		SyncLagSeconds:  lag,
+		LastContact:     lastContact,
+		LastFlush:       u.lastFlush,
+		LastError:       u.lastError,
+	}
+}
+
+// reportTelemetry place périodiquement un instantané des métriques dans l'uplink
+func (fc *FogCompute) reportTelemetry(ctx context.Context) {
+	if !fc.uplink.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(getEnvDuration("UPLINK_TELEMETRY_INTERVAL", 30*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fc.uplink.Enqueue("telemetry", fc.metricsSnapshot())
+		}
+	}
+}
+
+// handleGetUplink retourne l'état du lien montant vers le cloud
+func (fc *FogCompute) handleGetUplink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc.uplink.Status())
+}
+
+// handleFlushUplink demande une tentative d'envoi immédiate du tampon, par
+// exemple au retour d'un lien que le repli exponentiel n'a pas encore sondé
+func (fc *FogCompute) handleFlushUplink(w http.ResponseWriter, r *http.Request) {
+	if !fc.uplink.Enabled() {
+		http.Error(w, "Uplink désactivé (UPLINK_URL)", http.StatusNotFound)
+		return
+	}
+	fc.uplink.Flush()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(fc.uplink.Status())
+}
@@ -0,0 +1,15 @@
+package fognode
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime retourne le temps CPU (utilisateur + système) consommé par le processus
+func processCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
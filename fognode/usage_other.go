@@ -0,0 +1,10 @@
+//go:build !linux
+
+package fognode
+
+import "time"
+
+// processCPUTime n'est mesuré que sous Linux: la consommation CPU reste nulle
+func processCPUTime() time.Duration {
+	return 0
+}
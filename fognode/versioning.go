@@ -0,0 +1,361 @@
+package fognode
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// APIPrefix préfixe les routes de la version courante de l'API. Les mêmes
+// routes restent servies sans préfixe pendant la fenêtre de dépréciation.
+const APIPrefix = "/api/v1"
+
+// taskFieldChange est une évolution d'un champ des tâches entre leur
+// représentation historique, celle de la struct Task (routes sans préfixe,
+// stockage, échanges entre nœuds), et celle de l'API versionnée. Un champ
+// renommé change de nom; un statut renommé garde le nom "status" et convertit
+// sa valeur.
+type taskFieldChange struct {
+	Legacy    string
+	Current   string
+	toCurrent func(interface{}) interface{}
+	toLegacy  func(interface{}) interface{}
+}
+
+// taskSchemaV1 est le schéma des tâches de /api/v1: les durées, en
+// nanosecondes dans la représentation historique, y sont en millisecondes
+var taskSchemaV1 = []taskFieldChange{
+	{Legacy: "estimated_latency", Current: "estimated_latency_ms", toCurrent: nanosToMillis, toLegacy: millisToNanos},
+	{Legacy: "network_latency", Current: "network_latency_ms", toCurrent: nanosToMillis, toLegacy: millisToNanos},
+}
+
+// taskBodyRoutes sont les routes dont le corps est une tâche, ou un tableau
+// de tâches, à ramener à la représentation historique
+var taskBodyRoutes = map[string]bool{
+	"POST /tasks":           true,
+	"POST /tasks/sync":      true,
+	"POST /tasks/admission": true,
+	"POST /tasks/batch":     true,
+}
+
+// rawRoutes sont les routes /api/v1 dont la réponse n'est jamais réécrite:
+// résultats et blobs, diffusés tels quels
+var rawRoutes = map[string]bool{
+	"GET /tasks/{id}/result": true,
+}
+
+func nanosToMillis(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if ns, err := n.Int64(); err == nil {
+			return ns / int64(time.Millisecond)
+		}
+	}
+	return v
+}
+
+func millisToNanos(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if ms, err := n.Float64(); err == nil {
+			return int64(ms * float64(time.Millisecond))
+		}
+	}
+	return v
+}
+
+// LegacyRoutes suit l'usage des routes sans préfixe, dépréciées au profit de
+// /api/v1. Leurs réponses portent les en-têtes Deprecation, Sunset
+// (LEGACY_API_SUNSET) et Link vers la route versionnée; les compteurs par
+// route et par client désignent les passerelles encore à migrer.
+type LegacyRoutes struct {
+	Sunset time.Time // Date de retrait annoncée (zéro: non annoncée)
+
+	mu       sync.Mutex
+	requests int64
+	routes   map[string]int64
+	clients  map[string]int64
+}
+
+// LegacyStats décrit l'usage des routes dépréciées dans /metrics
+type LegacyStats struct {
+	Prefix   string           `json:"prefix"` // Préfixe des routes courantes
+	Sunset   *time.Time       `json:"sunset,omitempty"`
+	Requests int64            `json:"legacy_requests"`
+	Routes   map[string]int64 `json:"legacy_routes"`
+	Clients  map[string]int64 `json:"legacy_clients"`
+}
+
+// NewLegacyRoutes lit LEGACY_API_SUNSET (date RFC 3339)
+func NewLegacyRoutes() *LegacyRoutes {
+	lr := &LegacyRoutes{
+		routes:  make(map[string]int64),
+		clients: make(map[string]int64),
+	}
+	if raw := getEnv("LEGACY_API_SUNSET", ""); raw != "" {
+		sunset, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if sunset, err = time.Parse("2006-01-02", raw); err != nil {
+				config.errorf("LEGACY_API_SUNSET", "date RFC 3339 attendue: %v", err)
+			}
+		}
+		lr.Sunset = sunset
+	}
+	return lr
+}
+
+// middleware signale la dépréciation de la route et compte son usage
+func (lr *LegacyRoutes) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, template := routeKey(r)
+		w.Header().Set("Deprecation", "true")
+		if !lr.Sunset.IsZero() {
+			w.Header().Set("Sunset", lr.Sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Link", "<"+APIPrefix+r.URL.Path+`>; rel="successor-version"`)
+
+		client := r.RemoteAddr
+		if identity := requestIdentity(r); identity != nil {
+			client = identity.Name
+		} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			client = host
+		}
+		lr.mu.Lock()
+		lr.requests++
+		lr.routes[method+" "+template]++
+		lr.clients[client]++
+		lr.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats retourne l'usage des routes dépréciées
+func (lr *LegacyRoutes) Stats() LegacyStats {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	stats := LegacyStats{
+		Prefix:   APIPrefix,
+		Requests: lr.requests,
+		Routes:   make(map[string]int64, len(lr.routes)),
+		Clients:  make(map[string]int64, len(lr.clients)),
+	}
+	if !lr.Sunset.IsZero() {
+		sunset := lr.Sunset
+		stats.Sunset = &sunset
+	}
+	for route, n := range lr.routes {
+		stats.Routes[route] = n
+	}
+	for client, n := range lr.clients {
+		stats.Clients[client] = n
+	}
+	return stats
+}
+
+// registerAPI enregistre les routes de register sous APIPrefix et, pendant
+// la fenêtre de dépréciation, sans préfixe
+func (fc *FogCompute) registerAPI(r *mux.Router, register func(*mux.Router)) {
+	current := r.PathPrefix(APIPrefix).Subrouter()
+	current.Use(taskSchemaMiddleware(taskSchemaV1))
+	register(current)
+
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(fc.legacy.middleware)
+	register(legacy)
+}
+
+// apiPath retourne le chemin de l'API dans la version de la requête
+// (en-têtes Location)
+func apiPath(r *http.Request, path string) string {
+	if r != nil && strings.HasPrefix(r.URL.Path, APIPrefix+"/") {
+		return APIPrefix + path
+	}
+	return path
+}
+
+// taskSchemaMiddleware traduit les tâches entre la représentation historique
+// et le schéma d'une version de l'API: corps soumis ramenés à la
+// représentation historique, réponses JSON converties dans le schéma de la
+// version. Les autres réponses (erreurs, flux SSE, blobs) passent telles
+// quelles.
+func taskSchemaMiddleware(changes []taskFieldChange) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, template := routeKey(r)
+			route := method + " " + template
+			if rawRoutes[route] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if taskBodyRoutes[route] && r.Body != nil {
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(legacyTaskBody(body, changes)))
+			}
+
+			jw := &jsonRewriter{ResponseWriter: w, rewrite: func(body []byte) []byte {
+				return currentTaskBody(body, changes)
+			}}
+			next.ServeHTTP(jw, r)
+			jw.finish()
+		})
+	}
+}
+
+// legacyTaskBody ramène à la représentation historique la tâche, ou le
+// tableau de tâches, d'un corps soumis; un corps sans champ modifié est
+// transmis tel quel
+func legacyTaskBody(body []byte, changes []taskFieldChange) []byte {
+	touched := false
+	for _, c := range changes {
+		if c.Legacy != c.Current && bytes.Contains(body, []byte(`"`+c.Current+`"`)) {
+			touched = true
+		}
+	}
+	if !touched {
+		return body
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return body // Le handler rapporte l'erreur de syntaxe
+	}
+	tasks := []interface{}{doc}
+	if list, ok := doc.([]interface{}); ok {
+		tasks = list
+	}
+	for _, t := range tasks {
+		if task, ok := t.(map[string]interface{}); ok {
+			for _, c := range changes {
+				if v, ok := task[c.Current]; ok {
+					delete(task, c.Current)
+					task[c.Legacy] = c.toLegacy(v)
+				}
+			}
+		}
+	}
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return converted
+}
+
+// jsonRewriter retient les réponses JSON réussies pour que rewrite les
+// réécrive à la fin du handler; rewrite peut changer les en-têtes (type de
+// contenu). Les autres réponses (erreurs, flux SSE, réponses partielles)
+// sont transmises directement.
+type jsonRewriter struct {
+	http.ResponseWriter
+	rewrite  func(body []byte) []byte
+	status   int
+	decided  bool
+	buffered bool
+	buf      bytes.Buffer
+}
+
+func (jw *jsonRewriter) decide(status int) {
+	if jw.decided {
+		return
+	}
+	jw.decided = true
+	jw.status = status
+	contentType := jw.Header().Get("Content-Type")
+	jw.buffered = status >= 200 && status < 300 && status != http.StatusNoContent && status != http.StatusPartialContent &&
+		strings.HasPrefix(contentType, "application/json")
+	if !jw.buffered {
+		jw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (jw *jsonRewriter) WriteHeader(status int) {
+	jw.decide(status)
+}
+
+func (jw *jsonRewriter) Write(b []byte) (int, error) {
+	jw.decide(http.StatusOK)
+	if jw.buffered {
+		return jw.buf.Write(b)
+	}
+	return jw.ResponseWriter.Write(b)
+}
+
+// Flush transmet les réponses en flux (SSE), jamais retenues
+func (jw *jsonRewriter) Flush() {
+	jw.decide(http.StatusOK)
+	if f, ok := jw.ResponseWriter.(http.Flusher); ok && !jw.buffered {
+		f.Flush()
+	}
+}
+
+// finish écrit la réponse retenue, réécrite
+func (jw *jsonRewriter) finish() {
+	if !jw.buffered {
+		return
+	}
+	body := jw.rewrite(jw.buf.Bytes())
+	jw.Header().Del("Content-Length")
+	jw.ResponseWriter.WriteHeader(jw.status)
+	jw.ResponseWriter.Write(body)
+}
+
+// currentTaskBody convertit les tâches d'une réponse JSON dans le schéma de
+// la version; une réponse sans tâche est transmise telle quelle
+func currentTaskBody(body []byte, changes []taskFieldChange) []byte {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil || !currentTasks(doc, changes) {
+		return body
+	}
+	var out bytes.Buffer
+	if json.NewEncoder(&out).Encode(doc) != nil {
+		return body
+	}
+	return out.Bytes()
+}
+
+// currentTasks convertit dans le schéma de la version les tâches trouvées
+// dans doc (objets portant id, status et submitted_at), à toute profondeur;
+// retourne true si un champ a changé
+func currentTasks(doc interface{}, changes []taskFieldChange) bool {
+	changed := false
+	switch v := doc.(type) {
+	case []interface{}:
+		for _, item := range v {
+			changed = currentTasks(item, changes) || changed
+		}
+	case map[string]interface{}:
+		if isTaskObject(v) {
+			for _, c := range changes {
+				if value, ok := v[c.Legacy]; ok {
+					delete(v, c.Legacy)
+					v[c.Current] = c.toCurrent(value)
+					changed = true
+				}
+			}
+		}
+		for _, child := range v {
+			changed = currentTasks(child, changes) || changed
+		}
+	}
+	return changed
+}
+
+// isTaskObject reconnaît une tâche sérialisée
+func isTaskObject(obj map[string]interface{}) bool {
+	_, id := obj["id"]
+	_, status := obj["status"]
+	_, submitted := obj["submitted_at"]
+	return id && status && submitted
+}
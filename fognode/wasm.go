@@ -0,0 +1,311 @@
+package fognode
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmTaskType est le type des tâches qui exécutent un module WebAssembly
+// fourni par le client
+const wasmTaskType = "wasm"
+
+// wasmPageSize est la taille d'une page de mémoire WebAssembly
+const wasmPageSize = 64 << 10
+
+// WASMLimits borne l'exécution des modules WebAssembly des clients
+type WASMLimits struct {
+	MaxModuleBytes int           // Taille du module décodé
+	MaxMemoryPages uint32        // Mémoire linéaire, en pages de 64 Kio
+	Timeout        time.Duration // Plafond de durée d'exécution, compilation exclue, en plus du timeout de la tâche
+	MaxOutputBytes int           // Sortie standard conservée; au-delà l'exécution échoue
+}
+
+// wasmLimits sont les limites appliquées par le handler wasm (loadWASM)
+var wasmLimits WASMLimits
+
+// wasmModules est le cache des modules compilés du handler wasm (loadWASM)
+var wasmModules *wasmModuleCache
+
+// wasmModuleCache conserve les modules compilés par un runtime partagé, par
+// empreinte SHA-256: un module soumis de nouveau n'est pas recompilé. Au-delà
+// de max modules, les moins récemment utilisés sont évincés et leur code
+// compilé libéré dès la fin de leurs exécutions en cours.
+type wasmModuleCache struct {
+	mu    sync.Mutex
+	rt    wazero.Runtime
+	lru   *list.List                 // Modules, du plus récemment utilisé au plus ancien
+	items map[[32]byte]*list.Element // Empreinte → module
+	max   int
+}
+
+// wasmCompiled est un module compilé du cache
+type wasmCompiled struct {
+	sum      [32]byte
+	compiled wazero.CompiledModule
+	refs     int  // Exécutions en cours
+	evicted  bool // Retiré du cache: fermé à la fin de la dernière exécution
+	closed   bool
+}
+
+// newWASMModuleCache crée le runtime partagé, borné par limits, et son cache
+// de max modules compilés
+func newWASMModuleCache(limits WASMLimits, max int) *wasmModuleCache {
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MaxMemoryPages).
+		WithCloseOnContextDone(true))
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	return &wasmModuleCache{rt: rt, lru: list.New(), items: make(map[[32]byte]*list.Element), max: max}
+}
+
+// acquire retourne le module compilé de module, en le compilant s'il n'est
+// pas en cache; à libérer par release après l'exécution
+func (c *wasmModuleCache) acquire(ctx context.Context, module []byte) (*wasmCompiled, error) {
+	sum := sha256.Sum256(module)
+	c.mu.Lock()
+	if e := c.hitLocked(sum); e != nil {
+		c.mu.Unlock()
+		return e, nil
+	}
+	c.mu.Unlock()
+
+	compiled, err := c.rt.CompileModule(ctx, module) // Hors verrou: la compilation peut prendre plusieurs secondes
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.hitLocked(sum); e != nil {
+		// Compilé en parallèle par une autre exécution
+		compiled.Close(context.Background())
+		return e, nil
+	}
+	e := &wasmCompiled{sum: sum, compiled: compiled, refs: 1}
+	c.items[sum] = c.lru.PushFront(e)
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		old := oldest.Value.(*wasmCompiled)
+		c.lru.Remove(oldest)
+		delete(c.items, old.sum)
+		old.evicted = true
+		c.closeIdleLocked(old)
+		slog.Debug("Module wasm évincé du cache", "module_sha256", hex.EncodeToString(old.sum[:]))
+	}
+	return e, nil
+}
+
+// hitLocked retourne le module en cache d'empreinte sum, marqué utilisé; c.mu doit être détenu
+func (c *wasmModuleCache) hitLocked(sum [32]byte) *wasmCompiled {
+	el, ok := c.items[sum]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	e := el.Value.(*wasmCompiled)
+	e.refs++
+	return e
+}
+
+// release termine une exécution de e
+func (c *wasmModuleCache) release(e *wasmCompiled) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.refs--
+	c.closeIdleLocked(e)
+}
+
+// closeIdleLocked libère le code compilé d'un module évincé qui ne
+// s'exécute plus; c.mu doit être détenu
+func (c *wasmModuleCache) closeIdleLocked(e *wasmCompiled) {
+	if e.evicted && e.refs == 0 && !e.closed {
+		e.compiled.Close(context.Background())
+		e.closed = true
+	}
+}
+
+// loadWASM lit les limites WASM_* et enregistre le handler du type wasm si
+// WASM_ENABLED=true
+func loadWASM() {
+	wasmLimits = WASMLimits{
+		MaxModuleBytes: getEnvInt("WASM_MAX_MODULE_KB", 16<<10) << 10,
+		MaxMemoryPages: uint32(getEnvInt("WASM_MAX_MEMORY_MB", 64) << 20 / wasmPageSize),
+		Timeout:        getEnvDuration("WASM_TIMEOUT", 30*time.Second),
+		MaxOutputBytes: getEnvInt("WASM_MAX_OUTPUT_KB", 1<<10) << 10,
+	}
+	if wasmLimits.MaxModuleBytes <= 0 || wasmLimits.MaxMemoryPages == 0 || wasmLimits.Timeout <= 0 || wasmLimits.MaxOutputBytes <= 0 {
+		config.errorf("WASM_*", "les limites WebAssembly doivent être positives")
+		return
+	}
+	maxModules := getEnvInt("WASM_CACHE_MODULES", 16)
+	if maxModules <= 0 {
+		config.errorf("WASM_CACHE_MODULES", "%d: au moins un module", maxModules)
+		return
+	}
+	if !getEnvBool("WASM_ENABLED", false) {
+		return
+	}
+	wasmModules = newWASMModuleCache(wasmLimits, maxModules)
+	registerHandler(wasmTaskType, registeredHandler{fn: runWASM, version: "1.0.0", source: "builtin"})
+}
+
+// WASMPayload est le payload d'une tâche wasm
+type WASMPayload struct {
+	Module       string          `json:"module"`                  // Module WebAssembly encodé en base64
+	ModuleSHA256 string          `json:"module_sha256,omitempty"` // Empreinte attendue du module, vérifiée avant exécution
+	Args         []string        `json:"args,omitempty"`          // Arguments de ligne de commande (argv[1:])
+	Input        json.RawMessage `json:"input,omitempty"`         // Document JSON écrit sur l'entrée standard
+}
+
+// decodeWASMPayload lit le payload d'une tâche wasm et décode son module
+func decodeWASMPayload(payload map[string]interface{}) (WASMPayload, []byte, error) {
+	var p WASMPayload
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return p, nil, err
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, nil, fmt.Errorf("payload wasm invalide: %w", err)
+	}
+	if p.Module == "" {
+		return p, nil, errors.New("payload wasm: module requis (base64)")
+	}
+	if base64.StdEncoding.DecodedLen(len(p.Module)) > wasmLimits.MaxModuleBytes+2 {
+		return p, nil, fmt.Errorf("module wasm trop grand: au plus %d Kio (WASM_MAX_MODULE_KB)", wasmLimits.MaxModuleBytes>>10)
+	}
+	module, err := base64.StdEncoding.DecodeString(p.Module)
+	if err != nil {
+		return p, nil, fmt.Errorf("module wasm: base64 invalide: %w", err)
+	}
+	if len(module) > wasmLimits.MaxModuleBytes {
+		return p, nil, fmt.Errorf("module wasm trop grand: au plus %d Kio (WASM_MAX_MODULE_KB)", wasmLimits.MaxModuleBytes>>10)
+	}
+	if p.ModuleSHA256 != "" {
+		sum := sha256.Sum256(module)
+		if !strings.EqualFold(p.ModuleSHA256, hex.EncodeToString(sum[:])) {
+			return p, nil, errors.New("module wasm: empreinte module_sha256 différente")
+		}
+	}
+	return p, module, nil
+}
+
+// validateWASMTask refuse dès la soumission une tâche wasm dont le module
+// est absent, illisible ou trop grand
+func validateWASMTask(task *Task) *Rejection {
+	if task.Type != wasmTaskType {
+		return nil
+	}
+	if _, ok := lookupHandler(wasmTaskType); !ok {
+		return nil // WASM_ENABLED=false: la tâche échoue comme tout type sans handler
+	}
+	if _, _, err := decodeWASMPayload(task.Payload); err != nil {
+		return &Rejection{Reason: err.Error(), Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// limitedBuffer conserve au plus max octets et signale le dépassement
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil // Le module n'est pas interrompu: l'échec est constaté à la fin
+	}
+	return b.Buffer.Write(p)
+}
+
+// runWASM exécute un module WebAssembly WASI (commande, point d'entrée
+// _start): le document input est écrit sur son entrée standard et sa sortie
+// standard devient le résultat, décodée si c'est du JSON. Le module n'a accès
+// ni au système de fichiers, ni au réseau, ni à l'environnement du nœud; sa
+// mémoire est bornée par WASM_MAX_MEMORY_MB et sa durée par le timeout de la
+// tâche et WASM_TIMEOUT. La compilation d'un module nouveau peut prendre
+// plusieurs secondes; elle est ensuite réutilisée (wasmModules).
+func runWASM(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	p, module, err := decodeWASMPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := wasmModules.acquire(ctx, module) // Borné par le seul timeout de la tâche
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("module wasm invalide: %w", err)
+	}
+	defer wasmModules.release(compiled)
+	ctx, cancel := context.WithTimeout(ctx, wasmLimits.Timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{max: wasmLimits.MaxOutputBytes}
+	stderr := &limitedBuffer{max: 4 << 10}
+	cfg := wazero.NewModuleConfig().
+		WithName(""). // Anonyme: un même module peut s'exécuter plusieurs fois en parallèle
+		WithArgs(append([]string{"task"}, p.Args...)...).
+		WithStdin(bytes.NewReader(p.Input)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	start := time.Now()
+	mod, err := wasmModules.rt.InstantiateModule(ctx, compiled.compiled, cfg)
+	if mod != nil {
+		mod.Close(context.Background())
+	}
+	exitCode := uint32(0)
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode, err = exitErr.ExitCode(), nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err() // Timeout ou préemption: la tâche suit le traitement habituel
+	}
+	if err != nil {
+		return nil, fmt.Errorf("exécution wasm: %w", err)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("module wasm terminé avec le code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("sortie du module wasm supérieure à %d Kio (WASM_MAX_OUTPUT_KB)", wasmLimits.MaxOutputBytes>>10)
+	}
+
+	result := map[string]interface{}{
+		"operation":     wasmTaskType,
+		"status":        "success",
+		"module_sha256": hex.EncodeToString(compiled.sum[:]),
+		"duration_ms":   time.Since(start).Milliseconds(),
+		"output":        decodeOutput(stdout.Bytes()),
+	}
+	if stderr.Len() > 0 {
+		result["stderr"] = stderr.String()
+	}
+	slog.Debug("Module wasm exécuté", "module_sha256", result["module_sha256"], "duration_ms", result["duration_ms"])
+	return result, nil
+}
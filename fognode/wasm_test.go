@@ -0,0 +1,108 @@
+package fognode
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// testWASMModule retourne un module WASI minimal (_start vide), distinct
+// pour chaque n par une section personnalisée
+func testWASMModule(n byte) []byte {
+	return []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // En-tête
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // Type: func() -> ()
+		0x03, 0x02, 0x01, 0x00, // Fonction 0 de type 0
+		0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00, // Export _start
+		0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b, // Corps vide
+		0x00, 0x04, 0x02, 'i', 'd', n, // Section personnalisée "id"
+	}
+}
+
+var testWASMLimits = WASMLimits{
+	MaxModuleBytes: 1 << 20,
+	MaxMemoryPages: 16,
+	Timeout:        5 * time.Second,
+	MaxOutputBytes: 1 << 10,
+}
+
+func TestWASMModuleCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := newWASMModuleCache(testWASMLimits, 2)
+	defer cache.rt.Close(ctx)
+
+	acquire := func(n byte) *wasmCompiled {
+		t.Helper()
+		e, err := cache.acquire(ctx, testWASMModule(n))
+		if err != nil {
+			t.Fatalf("module %d: %v", n, err)
+		}
+		return e
+	}
+
+	// Plus de modules distincts que de places: les plus anciens sont libérés
+	var entries []*wasmCompiled
+	for n := byte(0); n < 5; n++ {
+		e := acquire(n)
+		cache.release(e)
+		entries = append(entries, e)
+	}
+	if cache.lru.Len() != 2 || len(cache.items) != 2 {
+		t.Fatalf("%d modules en cache, attendu 2", cache.lru.Len())
+	}
+	for n, e := range entries {
+		if want := n < 3; e.closed != want {
+			t.Errorf("module %d: fermé = %v, attendu %v", n, e.closed, want)
+		}
+	}
+
+	// Un module réutilisé passe en tête: le moins récemment utilisé est évincé
+	if e := acquire(3); e != entries[3] {
+		t.Error("module 3 recompilé malgré le cache")
+	} else {
+		cache.release(e)
+	}
+	cache.release(acquire(5))
+	if !entries[4].closed || entries[3].closed {
+		t.Errorf("évincement hors ordre LRU: module 3 fermé = %v, module 4 fermé = %v", entries[3].closed, entries[4].closed)
+	}
+
+	// Un module évincé pendant son exécution n'est libéré qu'à la fin de celle-ci
+	running := acquire(6)
+	cache.release(acquire(7))
+	cache.release(acquire(8))
+	if !running.evicted || running.closed {
+		t.Fatalf("module en cours: évincé = %v, fermé = %v", running.evicted, running.closed)
+	}
+	cache.release(running)
+	if !running.closed {
+		t.Error("module évincé non libéré à la fin de son exécution")
+	}
+}
+
+func TestRunWASMDistinctModules(t *testing.T) {
+	defer func(limits WASMLimits, modules *wasmModuleCache) { wasmLimits, wasmModules = limits, modules }(wasmLimits, wasmModules)
+	wasmLimits = testWASMLimits
+	wasmModules = newWASMModuleCache(testWASMLimits, 3)
+	defer wasmModules.rt.Close(context.Background())
+
+	for n := byte(0); n < 20; n++ {
+		payload := map[string]interface{}{"module": base64.StdEncoding.EncodeToString(testWASMModule(n))}
+		result, err := runWASM(context.Background(), payload)
+		if err != nil {
+			t.Fatalf("module %d: %v", n, err)
+		}
+		if result["status"] != "success" {
+			t.Fatalf("module %d: %v", n, result)
+		}
+	}
+	if got := wasmModules.lru.Len(); got != 3 {
+		t.Errorf("%d modules compilés conservés, attendu 3", got)
+	}
+	for _, el := range wasmModules.items {
+		if e := el.Value.(*wasmCompiled); e.refs != 0 {
+			t.Errorf("module %x: %d exécutions en cours après la fin des tâches", e.sum[:4], e.refs)
+		}
+	}
+}
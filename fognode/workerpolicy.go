@@ -0,0 +1,257 @@
+package fognode
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Politiques des workers en basse énergie (ENERGY_WORKER_POLICY). Sous le
+// seuil, les deux concentrent le travail sur moins de workers et de cœurs;
+// elles diffèrent par le rythme d'exécution.
+const (
+	WorkerPolicyOff        = "off"
+	WorkerPolicySpread     = "spread"       // Les workers restants exécutent en continu
+	WorkerPolicyRaceToIdle = "race_to_idle" // Lots exécutés à pleine vitesse, cœurs au repos entre deux lots
+)
+
+// EnergyEfficiency rapporte le travail utile à l'énergie consommée par les exécutions
+type EnergyEfficiency struct {
+	Executions  int     `json:"executions"`
+	Useful      int     `json:"useful"` // Exécutions réussies
+	EnergyWh    float64 `json:"energy_wh"`
+	UsefulPerWh float64 `json:"useful_per_wh"`
+}
+
+// EnergyWorkerStats décrit l'état de la politique des workers en basse énergie
+type EnergyWorkerStats struct {
+	Policy        string           `json:"policy"`
+	Threshold     float64          `json:"threshold"`
+	LowEnergy     bool             `json:"low_energy"`
+	Workers       int              `json:"workers"`        // Workers généraux
+	ActiveWorkers int              `json:"active_workers"` // Workers généraux autorisés à prendre des tâches
+	CPUs          []int            `json:"cpus,omitempty"` // Cœurs des sous-processus en basse énergie
+	BatchOpen     bool             `json:"batch_open,omitempty"`
+	Batches       int              `json:"batches,omitempty"`
+	Normal        EnergyEfficiency `json:"normal"`
+	Low           EnergyEfficiency `json:"low_energy_efficiency"`
+}
+
+// EnergyWorkerPolicy règle le nombre de workers généraux actifs sur le niveau
+// d'énergie: sous ENERGY_LOW_THRESHOLD, il décroît avec l'énergie restante
+// (jusqu'à ENERGY_MIN_WORKERS) et les sous-processus sont confinés à une part
+// des cœurs en proportion. En race_to_idle, les workers actifs attendent un
+// lot de ENERGY_BATCH_SIZE tâches (ou ENERGY_BATCH_WAIT) et vident la queue
+// d'une traite. Les workers réservés aux tâches critiques n'y sont pas soumis.
+type EnergyWorkerPolicy struct {
+	mode       string
+	threshold  float64
+	minWorkers int
+	batchSize  int
+	batchWait  time.Duration
+	cpus       []int // Cœurs ouverts aux sous-processus hors basse énergie
+
+	low          bool
+	workers      int
+	active       int
+	batchOpen    bool
+	pendingSince time.Time // race_to_idle: premières tâches vues en attente d'un lot
+	batches      int
+	normal       EnergyEfficiency
+	lowEnergy    EnergyEfficiency
+	mu           sync.Mutex
+}
+
+// loadEnergyWorkerPolicy lit ENERGY_WORKER_POLICY, ENERGY_LOW_THRESHOLD,
+// ENERGY_MIN_WORKERS, ENERGY_BATCH_SIZE et ENERGY_BATCH_WAIT; cpus sont les
+// cœurs de WORKER_CPUS (vide: tous)
+func loadEnergyWorkerPolicy(cpus []int) *EnergyWorkerPolicy {
+	mode := getEnv("ENERGY_WORKER_POLICY", WorkerPolicyOff)
+	switch mode {
+	case WorkerPolicyOff, WorkerPolicySpread, WorkerPolicyRaceToIdle:
+	default:
+		slog.Warn("Politique ENERGY_WORKER_POLICY inconnue", "policy", mode, "default", WorkerPolicyOff)
+		mode = WorkerPolicyOff
+	}
+	if len(cpus) == 0 {
+		cpus = make([]int, runtime.NumCPU())
+		for i := range cpus {
+			cpus[i] = i
+		}
+	}
+	p := &EnergyWorkerPolicy{
+		mode:       mode,
+		threshold:  getEnvFloat("ENERGY_LOW_THRESHOLD", 0.3),
+		minWorkers: getEnvInt("ENERGY_MIN_WORKERS", 1),
+		batchSize:  getEnvInt("ENERGY_BATCH_SIZE", 10),
+		batchWait:  getEnvDuration("ENERGY_BATCH_WAIT", 30*time.Second),
+		cpus:       cpus,
+	}
+	if p.minWorkers < 1 {
+		p.minWorkers = 1
+	}
+	if p.batchSize < 1 {
+		p.batchSize = 1
+	}
+	return p
+}
+
+// Enabled indique si une politique de basse énergie est configurée
+func (p *EnergyWorkerPolicy) Enabled() bool {
+	return p.mode != WorkerPolicyOff
+}
+
+// refreshLocked recalcule le nombre de workers actifs; p.mu doit être détenu
+func (p *EnergyWorkerPolicy) refreshLocked(energy float64, workers int) {
+	p.workers = workers
+	low := p.Enabled() && energy < p.threshold
+	if low != p.low {
+		slog.Info("Politique énergétique des workers", "policy", p.mode, "low_energy", low, "energy", energy)
+		p.batchOpen, p.pendingSince = false, time.Time{}
+	}
+	p.low = low
+	p.active = workers
+	if !low {
+		return
+	}
+	// Les workers actifs décroissent linéairement avec l'énergie restante sous le seuil
+	p.active = int(math.Ceil(float64(workers) * math.Max(energy, 0) / p.threshold))
+	if p.active < p.minWorkers {
+		p.active = p.minWorkers
+	}
+	if p.active > workers {
+		p.active = workers
+	}
+}
+
+// allows indique si le worker général d'indice index (parmi workers) peut
+// prendre une des queued tâches en attente au niveau d'énergie donné
+func (p *EnergyWorkerPolicy) allows(index, workers, queued int, energy float64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.refreshLocked(energy, workers)
+	if queued == 0 {
+		p.batchOpen, p.pendingSince = false, time.Time{}
+		return false
+	}
+	if !p.low {
+		return true
+	}
+	if index >= p.active {
+		return false
+	}
+	if p.mode == WorkerPolicyRaceToIdle && !p.batchOpen {
+		now := time.Now()
+		if p.pendingSince.IsZero() {
+			p.pendingSince = now
+		}
+		if queued < p.batchSize && now.Sub(p.pendingSince) < p.batchWait {
+			return false
+		}
+		// Le lot reste ouvert jusqu'à ce que la queue soit vide
+		p.batchOpen, p.pendingSince = true, time.Time{}
+		p.batches++
+	}
+	return true
+}
+
+// CPUs retourne les cœurs des sous-processus en basse énergie, nil sinon
+func (p *EnergyWorkerPolicy) CPUs() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cpusLocked()
+}
+
+// cpusLocked retourne la part des cœurs proportionnelle aux workers actifs; p.mu doit être détenu
+func (p *EnergyWorkerPolicy) cpusLocked() []int {
+	if !p.low || p.workers == 0 {
+		return nil
+	}
+	n := int(math.Ceil(float64(len(p.cpus)) * float64(p.active) / float64(p.workers)))
+	if n < 1 {
+		n = 1
+	}
+	return p.cpus[:n]
+}
+
+// Executed comptabilise une exécution et son énergie consommée (Wh)
+func (p *EnergyWorkerPolicy) Executed(energyWh float64, useful bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := &p.normal
+	if p.low {
+		e = &p.lowEnergy
+	}
+	e.Executions++
+	if useful {
+		e.Useful++
+	}
+	e.EnergyWh += energyWh
+	if e.EnergyWh > 0 {
+		e.UsefulPerWh = float64(e.Useful) / e.EnergyWh
+	}
+}
+
+// Stats retourne l'état de la politique
+func (p *EnergyWorkerPolicy) Stats() EnergyWorkerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return EnergyWorkerStats{
+		Policy:        p.mode,
+		Threshold:     p.threshold,
+		LowEnergy:     p.low,
+		Workers:       p.workers,
+		ActiveWorkers: p.active,
+		CPUs:          p.cpusLocked(),
+		BatchOpen:     p.batchOpen,
+		Batches:       p.batches,
+		Normal:        p.normal,
+		Low:           p.lowEnergy,
+	}
+}
+
+// mayTakeTaskLocked indique si un worker peut prendre une tâche: il y en a
+// une pour lui et, pour un worker général, la politique énergétique le laisse
+// actif; fc.mu doit être détenu
+func (fc *FogCompute) mayTakeTaskLocked(workerID int, reserved bool) bool {
+	if fc.maintenance.paused {
+		return false
+	}
+	queued := fc.queuedFor(reserved)
+	if reserved {
+		return queued > 0
+	}
+	// Hors mode d'alimentation normal, seuls les premiers workers généraux travaillent
+	if limit := fc.powerModes.workerCapLocked(); limit > 0 && workerID-fc.reservedWorkers >= limit {
+		return false
+	}
+	if !fc.energyWorkers.Enabled() {
+		return queued > 0
+	}
+	general := fc.numWorkers - fc.reservedWorkers
+	return fc.energyWorkers.allows(workerID-fc.reservedWorkers, general, queued, fc.energyLevel)
+}
+
+// runEnergyWorkers réveille périodiquement les workers en attente: un lot
+// race_to_idle arrive à échéance, ou l'énergie remontée rouvre des workers
+func (fc *FogCompute) runEnergyWorkers(ctx context.Context) {
+	if !fc.energyWorkers.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fc.mu.Lock()
+			fc.cond.Broadcast()
+			fc.mu.Unlock()
+		}
+	}
+}
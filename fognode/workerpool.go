@@ -0,0 +1,103 @@
+package fognode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// WorkerPoolStatus décrit le pool de workers (GET/PUT /admin/workers)
+type WorkerPoolStatus struct {
+	Workers  int `json:"workers"`  // Nombre de workers demandé
+	Running  int `json:"running"`  // Workers démarrés, y compris ceux qui terminent leur tâche avant de s'arrêter
+	Retiring int `json:"retiring"` // Workers au-delà du nombre demandé, arrêtés après leur tâche en cours
+	Reserved int `json:"reserved"` // Workers réservés aux tâches critiques, inclus dans workers
+	Min      int `json:"min"`
+	Max      int `json:"max"`
+}
+
+// startWorkersLocked démarre les workers manquants jusqu'au nombre demandé;
+// fc.mu doit être détenu. Un worker en cours d'arrêt qui redevient utile
+// reprend simplement sa boucle.
+func (fc *FogCompute) startWorkersLocked() {
+	for id := 0; id < fc.numWorkers; id++ {
+		if fc.runningWorkers[id] {
+			continue
+		}
+		fc.runningWorkers[id] = true
+		fc.workers.Add(1)
+		go fc.worker(id)
+	}
+}
+
+// retiredLocked indique si un worker est au-delà du nombre demandé; fc.mu doit être détenu
+func (fc *FogCompute) retiredLocked(workerID int) bool {
+	return workerID >= fc.numWorkers
+}
+
+// setWorkers fixe le nombre de workers: les workers ajoutés démarrent
+// aussitôt, les workers retirés s'arrêtent dès qu'ils n'ont plus de tâche en
+// cours; les tâches en attente restent en queue pour les autres
+func (fc *FogCompute) setWorkers(n int) (WorkerPoolStatus, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	min, max := fc.reservedWorkers+1, fc.maxWorkers
+	if n < min || n > max {
+		return fc.workerPoolStatusLocked(), fmt.Errorf("nombre de workers hors limites: %d (entre %d et %d)", n, min, max)
+	}
+	if fc.stopping {
+		return fc.workerPoolStatusLocked(), fmt.Errorf("nœud en cours d'arrêt")
+	}
+	fc.numWorkers = n
+	fc.startWorkersLocked()
+	fc.cond.Broadcast() // Les workers retirés en attente d'une tâche s'arrêtent
+	return fc.workerPoolStatusLocked(), nil
+}
+
+// workerPoolStatusLocked retourne l'état du pool; fc.mu doit être détenu
+func (fc *FogCompute) workerPoolStatusLocked() WorkerPoolStatus {
+	status := WorkerPoolStatus{
+		Workers:  fc.numWorkers,
+		Running:  len(fc.runningWorkers),
+		Reserved: fc.reservedWorkers,
+		Min:      fc.reservedWorkers + 1,
+		Max:      fc.maxWorkers,
+	}
+	for id := range fc.runningWorkers {
+		if fc.retiredLocked(id) {
+			status.Retiring++
+		}
+	}
+	return status
+}
+
+// handleGetWorkers retourne l'état du pool de workers
+func (fc *FogCompute) handleGetWorkers(w http.ResponseWriter, r *http.Request) {
+	fc.mu.RLock()
+	status := fc.workerPoolStatusLocked()
+	fc.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleSetWorkers fixe le nombre de workers ({"workers": 3}), par exemple
+// pour réduire la dissipation sous contrainte thermique ou sur batterie
+func (fc *FogCompute) handleSetWorkers(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Workers *int `json:"workers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Workers == nil {
+		http.Error(w, "Champ workers requis", http.StatusBadRequest)
+		return
+	}
+	status, err := fc.setWorkers(*body.Workers)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "pool": status})
+		return
+	}
+	slog.Info("Nombre de workers modifié", "workers", status.Workers, "retiring", status.Retiring, "client", requestClient(r))
+	json.NewEncoder(w).Encode(status)
+}
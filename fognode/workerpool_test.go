@@ -0,0 +1,33 @@
+package fognode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Les workers bloqués sur une queue vide s'arrêtent à l'annulation du
+// contexte de Start
+func TestStartCancelStopsIdleWorkers(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	fc := NewFogCompute("test-node", "test-site")
+	defer fc.store.Close()
+	defer fc.journal.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fc.Start(ctx)
+	// Laisser les workers atteindre l'attente sur la queue vide
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		fc.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workers toujours actifs après l'annulation du contexte")
+	}
+}
@@ -0,0 +1,210 @@
+package fognode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// États d'un workflow (GET /workflows/{id})
+const (
+	WorkflowWaiting   = "waiting"   // Aucune tâche en cours: des tâches attendent leurs dépendances
+	WorkflowRunning   = "running"   // Des tâches sont en queue ou en cours d'exécution
+	WorkflowCompleted = "completed" // Toutes les tâches ont réussi
+	WorkflowFailed    = "failed"    // Une tâche au moins a échoué; ses descendantes ne s'exécuteront pas
+)
+
+// WorkflowNode est une tâche d'un workflow, avec ses dépendances
+type WorkflowNode struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	DependsOn   []string   `json:"depends_on,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// WorkflowStatus décrit le DAG d'un workflow et l'avancement de ses tâches
+type WorkflowStatus struct {
+	ID     string         `json:"id"`
+	Status string         `json:"status"`
+	Counts map[string]int `json:"counts"` // Tâches par statut
+	Tasks  []WorkflowNode `json:"tasks"`  // Dans l'ordre de soumission
+}
+
+// dependencyState classe le statut d'une tâche parente: terminée avec
+// succès, encore à venir, ou en échec définitif
+func dependencyState(task *Task) string {
+	switch task.Status {
+	case "completed":
+		return WorkflowCompleted
+	case "waiting", "delayed", "queued", "processing", "preempted", "retry_scheduled", "deferred", "overflow", "reserved":
+		return WorkflowWaiting
+	default:
+		return WorkflowFailed
+	}
+}
+
+// linkDependencies vérifie les dépendances (depends_on) d'une tâche soumise
+// et la rattache au workflow de ses parents; le premier parent sans workflow
+// en devient la racine. Un parent inconnu, d'un autre namespace ou déjà en
+// échec est refusé avec 422.
+func (fc *FogCompute) linkDependencies(task *Task) *Rejection {
+	if len(task.DependsOn) == 0 {
+		return nil
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for _, id := range task.DependsOn {
+		parent, ok := fc.tasks[id]
+		if !ok || tenantOf(parent) != tenantOf(task) {
+			return &Rejection{Policy: "dependencies", Reason: fmt.Sprintf("Dépendance inconnue: %s", id), Status: http.StatusUnprocessableEntity}
+		}
+		if dependencyState(parent) == WorkflowFailed {
+			return &Rejection{Policy: "dependencies", Reason: fmt.Sprintf("Dépendance %s en échec (%s)", id, parent.Status), Status: http.StatusUnprocessableEntity}
+		}
+	}
+	for _, id := range task.DependsOn {
+		parent := fc.tasks[id]
+		if parent.WorkflowID == "" {
+			parent.WorkflowID = parent.ID
+			fc.saveTaskLocked(parent)
+		}
+		if task.WorkflowID == "" {
+			task.WorkflowID = parent.WorkflowID
+		}
+	}
+	return nil
+}
+
+// enqueueWhenReadyLocked met en queue une tâche admise dont toutes les
+// dépendances ont réussi et dont execute_at est atteint; sinon elle attend
+// (statut waiting ou delayed, sans réserver de ressources), ou échoue avec sa
+// dépendance. fc.mu doit être détenu.
+func (fc *FogCompute) enqueueWhenReadyLocked(task *Task) {
+	for _, id := range task.DependsOn {
+		parent, ok := fc.tasks[id]
+		if !ok {
+			fc.failDependentLocked(task, fmt.Sprintf("dépendance %s introuvable", id))
+			return
+		}
+		switch dependencyState(parent) {
+		case WorkflowFailed:
+			fc.failDependentLocked(task, fmt.Sprintf("dépendance %s en échec (%s)", id, parent.Status))
+			return
+		case WorkflowWaiting:
+			task.Status = "waiting"
+			fc.tasks[task.ID] = task
+			fc.saveTaskLocked(task)
+			return
+		}
+	}
+	if fc.stageLocked(task) {
+		return
+	}
+	task.Status = "queued"
+	fc.enqueueLocked(task)
+}
+
+// failDependentLocked fait échouer une tâche dont une dépendance a échoué, et
+// ses propres descendantes; fc.mu doit être détenu
+func (fc *FogCompute) failDependentLocked(task *Task, reason string) {
+	now := time.Now()
+	task.Status = "failed"
+	task.Result = map[string]string{"error": reason}
+	task.CompletedAt = &now
+	fc.tasks[task.ID] = task
+	fc.saveTaskLocked(task)
+	ev := taskEvent(EventFailed, task)
+	ev.Reason = reason
+	fc.events.Publish(ev)
+	slog.Warn("Tâche abandonnée: dépendance en échec", "task_id", task.ID, "workflow_id", task.WorkflowID, "reason", reason)
+	fc.waiters.Done(task.ID)
+	go fc.checkGroupCompletion(task.GroupID)
+
+	fc.resolveDependentsLocked(task)
+}
+
+// resolveDependentsLocked réévalue, quand une tâche atteint un état final,
+// les tâches qui l'attendent; fc.mu doit être détenu
+func (fc *FogCompute) resolveDependentsLocked(parent *Task) {
+	if parent.WorkflowID == "" {
+		return
+	}
+	for _, t := range fc.tasks {
+		if t.Status != "waiting" || t.WorkflowID != parent.WorkflowID {
+			continue
+		}
+		for _, id := range t.DependsOn {
+			if id == parent.ID {
+				fc.enqueueWhenReadyLocked(t)
+				break
+			}
+		}
+	}
+}
+
+// resolveWaitingLocked réévalue toutes les tâches en attente de leurs
+// dépendances, après la restauration; fc.mu doit être détenu
+func (fc *FogCompute) resolveWaitingLocked() {
+	for _, t := range fc.tasks {
+		if t.Status == "waiting" {
+			fc.enqueueWhenReadyLocked(t)
+		}
+	}
+}
+
+// handleGetWorkflow retourne le DAG d'un workflow et l'état de ses tâches
+func (fc *FogCompute) handleGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	workflowID := mux.Vars(r)["id"]
+	tenant, all := fc.requestTenant(r)
+
+	status := WorkflowStatus{ID: workflowID, Counts: make(map[string]int)}
+	states := make(map[string]int)
+	fc.mu.RLock()
+	for _, t := range fc.tasks {
+		if t.WorkflowID != workflowID || !visibleTo(t, tenant, all) {
+			continue
+		}
+		status.Tasks = append(status.Tasks, WorkflowNode{
+			ID:          t.ID,
+			Type:        t.Type,
+			Status:      t.Status,
+			DependsOn:   t.DependsOn,
+			SubmittedAt: t.SubmittedAt,
+			CompletedAt: t.CompletedAt,
+		})
+		status.Counts[t.Status]++
+		state := dependencyState(t)
+		if state == WorkflowWaiting && t.Status != "waiting" {
+			state = WorkflowRunning
+		}
+		states[state]++
+	}
+	fc.mu.RUnlock()
+
+	if len(status.Tasks) == 0 {
+		http.Error(w, "Workflow non trouvé", http.StatusNotFound)
+		return
+	}
+	sort.Slice(status.Tasks, func(i, j int) bool { return status.Tasks[i].SubmittedAt.Before(status.Tasks[j].SubmittedAt) })
+	switch {
+	case states[WorkflowFailed] > 0:
+		status.Status = WorkflowFailed
+	case states[WorkflowRunning] > 0:
+		status.Status = WorkflowRunning
+	case states[WorkflowWaiting] > 0:
+		status.Status = WorkflowWaiting
+	default:
+		status.Status = WorkflowCompleted
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -2,4 +2,13 @@ module fog-compute
 
 go 1.21
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/lib/pq v1.10.9
+	github.com/tetratelabs/wazero v1.8.2
+	github.com/yalue/onnxruntime_go v1.36.0
+	github.com/yuin/gopher-lua v1.1.1
+	go.etcd.io/bbolt v1.3.10
+)
+
+require golang.org/x/sys v0.4.0 // indirect